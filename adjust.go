@@ -4,53 +4,127 @@ import (
 	"image"
 	"image/color"
 	"math"
+	"sort"
+	"sync"
 )
 
 // Grayscale produces a grayscale version of the image.
 func Grayscale(img image.Image) *image.NRGBA {
+	return GrayscaleWithOptions(img, nil)
+}
+
+// LumaCoefficients selects the weights of the red, green and blue channels
+// used to compute the luminance.
+type LumaCoefficients int
+
+// Luma coefficients.
+const (
+	// Rec601 uses the ITU-R BT.601 weights (0.299, 0.587, 0.114).
+	Rec601 LumaCoefficients = iota
+	// Rec709 uses the ITU-R BT.709 weights (0.2126, 0.7152, 0.0722), matching the sRGB primaries.
+	Rec709
+)
+
+func (c LumaCoefficients) weights() (float64, float64, float64) {
+	if c == Rec709 {
+		return 0.2126, 0.7152, 0.0722
+	}
+	return 0.299, 0.587, 0.114
+}
+
+// GrayscaleOptions are the grayscale conversion options.
+type GrayscaleOptions struct {
+	// If Linear is true, the luminance is computed in linear light instead of
+	// mixing the sRGB-encoded values. This keeps the perceived brightness of
+	// saturated colors and gradients.
+	Linear bool
+
+	// Coefficients selects the channel weights. Rec601 is the default.
+	Coefficients LumaCoefficients
+}
+
+// GrayscaleLinear produces a grayscale version of the image computing the luminance
+// in linear light with the given coefficients.
+//
+// Example:
+//
+//	dstImage = imaging.GrayscaleLinear(srcImage, imaging.Rec709)
+//
+func GrayscaleLinear(img image.Image, coefficients LumaCoefficients) *image.NRGBA {
+	return GrayscaleWithOptions(img, &GrayscaleOptions{Linear: true, Coefficients: coefficients})
+}
+
+// GrayscaleWithOptions produces a grayscale version of the image using the given options.
+// If options is nil, it behaves like Grayscale.
+func GrayscaleWithOptions(img image.Image, options *GrayscaleOptions) *image.NRGBA {
+	if options == nil {
+		options = &GrayscaleOptions{}
+	}
+	wr, wg, wb := options.Coefficients.weights()
+
+	var toSRGB []uint8
+	if options.Linear {
+		toSRGB = linearToSRGBTable()
+	}
+
 	src := newScanner(img)
 	dst := image.NewNRGBA(image.Rect(0, 0, src.w, src.h))
-	parallel(0, src.h, func(ys <-chan int) {
+	parallel(0, src.h, src.w*costPoint, func(ys <-chan int) {
 		for y := range ys {
 			i := y * dst.Stride
 			src.scan(0, y, src.w, y+1, dst.Pix[i:i+src.w*4])
-			for x := 0; x < src.w; x++ {
-				d := dst.Pix[i : i+3 : i+3]
-				r := d[0]
-				g := d[1]
-				b := d[2]
-				f := 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
-				y := uint8(f + 0.5)
-				d[0] = y
-				d[1] = y
-				d[2] = y
-				i += 4
-			}
+			grayscaleLine(dst.Pix[i:i+src.w*4], wr, wg, wb, toSRGB)
 		}
 	})
 	return dst
 }
 
+// grayscaleLine converts a line of pixels to grayscale in place. If toSRGB is not nil,
+// the luminance is computed in linear light and converted back using the table.
+func grayscaleLine(pix []uint8, wr, wg, wb float64, toSRGB []uint8) {
+	for i := 0; i < len(pix); i += 4 {
+		d := pix[i : i+3 : i+3]
+		var y uint8
+		if toSRGB != nil {
+			r := srgbToLinearLUT[d[0]]
+			g := srgbToLinearLUT[d[1]]
+			b := srgbToLinearLUT[d[2]]
+			f := wr*float64(r) + wg*float64(g) + wb*float64(b)
+			y = toSRGB[clamp16(f)]
+		} else {
+			f := wr*float64(d[0]) + wg*float64(d[1]) + wb*float64(d[2])
+			y = uint8(f + 0.5)
+		}
+		d[0] = y
+		d[1] = y
+		d[2] = y
+	}
+}
+
 // Invert produces an inverted (negated) version of the image.
 func Invert(img image.Image) *image.NRGBA {
 	src := newScanner(img)
 	dst := image.NewNRGBA(image.Rect(0, 0, src.w, src.h))
-	parallel(0, src.h, func(ys <-chan int) {
+	parallel(0, src.h, src.w*costPoint, func(ys <-chan int) {
 		for y := range ys {
 			i := y * dst.Stride
 			src.scan(0, y, src.w, y+1, dst.Pix[i:i+src.w*4])
-			for x := 0; x < src.w; x++ {
-				d := dst.Pix[i : i+3 : i+3]
-				d[0] = 255 - d[0]
-				d[1] = 255 - d[1]
-				d[2] = 255 - d[2]
-				i += 4
-			}
+			invertLine(dst.Pix[i : i+src.w*4])
 		}
 	})
 	return dst
 }
 
+// invertLine inverts the colors of a line of pixels in place.
+func invertLine(pix []uint8) {
+	for i := 0; i < len(pix); i += 4 {
+		d := pix[i : i+3 : i+3]
+		d[0] = 255 - d[0]
+		d[1] = 255 - d[1]
+		d[2] = 255 - d[2]
+	}
+}
+
 // AdjustSaturation changes the saturation of the image using the percentage parameter and returns the adjusted image.
 // The percentage must be in the range (-100, 100).
 // The percentage = 0 gives the original image.
@@ -80,6 +154,35 @@ func AdjustSaturation(img image.Image, percentage float64) *image.NRGBA {
 	})
 }
 
+// AdjustVibrance changes the saturation of the image like AdjustSaturation, but the muted colors
+// are affected more than the already saturated ones, which helps to avoid oversaturation.
+// The percentage must be in the range (-100, 100).
+// The percentage = 0 gives the original image.
+// The percentage = 100 doubles the saturation of gray-ish colors and keeps the fully saturated colors.
+//
+// Examples:
+//  dstImage = imaging.AdjustVibrance(srcImage, 30) // Make the muted colors more vivid.
+//  dstImage = imaging.AdjustVibrance(srcImage, -30) // Mute the colors.
+//
+func AdjustVibrance(img image.Image, percentage float64) *image.NRGBA {
+	if percentage == 0 {
+		return Clone(img)
+	}
+
+	percentage = math.Min(math.Max(percentage, -100), 100)
+	amount := percentage / 100
+
+	return AdjustFunc(img, func(c color.NRGBA) color.NRGBA {
+		h, s, l := rgbToHSL(c.R, c.G, c.B)
+		s *= 1 + amount*(1-s)
+		if s > 1 {
+			s = 1
+		}
+		r, g, b := hslToRGB(h, s, l)
+		return color.NRGBA{r, g, b, c.A}
+	})
+}
+
 // AdjustHue changes the hue of the image using the shift parameter (measured in degrees) and returns the adjusted image.
 // The shift = 0 (or 360 / -360 / etc.) gives the original image.
 // The shift = 180 (or -180) corresponds to a 180° degree rotation of the color wheel and thus gives the image with its hue inverted for each pixel.
@@ -122,6 +225,11 @@ func AdjustContrast(img image.Image, percentage float64) *image.NRGBA {
 		return Clone(img)
 	}
 
+	return adjustLUT(img, contrastLUT(percentage))
+}
+
+// contrastLUT returns the lookup table used by AdjustContrast.
+func contrastLUT(percentage float64) []uint8 {
 	percentage = math.Min(math.Max(percentage, -100.0), 100.0)
 	lut := make([]uint8, 256)
 
@@ -136,8 +244,89 @@ func AdjustContrast(img image.Image, percentage float64) *image.NRGBA {
 			lut[i] = uint8(float64(i)/255.0+0.5) * 255
 		}
 	}
+	return lut
+}
+
+// AutoContrast stretches the levels of each color channel of the image to the full range.
+// For each channel, the darkest and the brightest clipPercent percent of the pixels are
+// ignored when the levels are computed and become black and white respectively, which makes
+// the result insensitive to a few outliers. The clipPercent must be in range [0, 50).
+// Fully transparent pixels are ignored.
+//
+// Example:
+//
+//	dstImage = imaging.AutoContrast(srcImage, 0.5)
+//
+func AutoContrast(img image.Image, clipPercent float64) *image.NRGBA {
+	clipPercent = math.Min(math.Max(clipPercent, 0), 49.9)
+
+	var mu sync.Mutex
+	var hist [3][256]uint64
+	var total uint64
+	src := newScanner(img)
+	parallel(0, src.h, src.w*costPoint, func(ys <-chan int) {
+		var tmpHist [3][256]uint64
+		var tmpTotal uint64
+		scanLine := make([]uint8, src.w*4)
+		for y := range ys {
+			src.scan(0, y, src.w, y+1, scanLine)
+			for i := 0; i < len(scanLine); i += 4 {
+				s := scanLine[i : i+4 : i+4]
+				if s[3] == 0 {
+					continue
+				}
+				tmpHist[0][s[0]]++
+				tmpHist[1][s[1]]++
+				tmpHist[2][s[2]]++
+				tmpTotal++
+			}
+		}
+		mu.Lock()
+		for c := range hist {
+			for i := range hist[c] {
+				hist[c][i] += tmpHist[c][i]
+			}
+		}
+		total += tmpTotal
+		mu.Unlock()
+	})
+
+	clip := uint64(float64(total) * clipPercent / 100)
+	var luts [3][]uint8
+	for c := range luts {
+		lut := make([]uint8, 256)
+		low, high := histogramPercentiles(&hist[c], clip)
+		for i := range lut {
+			if low >= high {
+				lut[i] = uint8(i)
+				continue
+			}
+			lut[i] = clamp(float64(i-low) * 255 / float64(high-low))
+		}
+		luts[c] = lut
+	}
+	return adjustLUTs(img, luts[0], luts[1], luts[2])
+}
 
-	return adjustLUT(img, lut)
+// histogramPercentiles returns the lowest and the highest values of the histogram
+// after clip counts are removed from each end.
+func histogramPercentiles(hist *[256]uint64, clip uint64) (int, int) {
+	low, high := 0, 255
+	var sum uint64
+	for ; low < 255; low++ {
+		sum += hist[low]
+		if sum > clip {
+			break
+		}
+	}
+	sum = 0
+	for ; high > 0; high-- {
+		sum += hist[high]
+		if sum > clip {
+			break
+		}
+	}
+	return low, high
 }
 
 // AdjustBrightness changes the brightness of the image using the percentage parameter and returns the adjusted image.
@@ -154,6 +343,11 @@ func AdjustBrightness(img image.Image, percentage float64) *image.NRGBA {
 		return Clone(img)
 	}
 
+	return adjustLUT(img, brightnessLUT(percentage))
+}
+
+// brightnessLUT returns the lookup table used by AdjustBrightness.
+func brightnessLUT(percentage float64) []uint8 {
 	percentage = math.Min(math.Max(percentage, -100.0), 100.0)
 	lut := make([]uint8, 256)
 
@@ -161,8 +355,7 @@ func AdjustBrightness(img image.Image, percentage float64) *image.NRGBA {
 	for i := 0; i < 256; i++ {
 		lut[i] = clamp(float64(i) + shift)
 	}
-
-	return adjustLUT(img, lut)
+	return lut
 }
 
 // AdjustGamma performs a gamma correction on the image and returns the adjusted image.
@@ -178,14 +371,196 @@ func AdjustGamma(img image.Image, gamma float64) *image.NRGBA {
 		return Clone(img)
 	}
 
+	return adjustLUT(img, gammaLUT(gamma))
+}
+
+// gammaLUT returns the lookup table used by AdjustGamma.
+func gammaLUT(gamma float64) []uint8 {
 	e := 1.0 / math.Max(gamma, 0.0001)
 	lut := make([]uint8, 256)
 
 	for i := 0; i < 256; i++ {
 		lut[i] = clamp(math.Pow(float64(i)/255.0, e) * 255.0)
 	}
+	return lut
+}
+
+// Channel is a set of color channels an adjustment is applied to.
+type Channel int
+
+// Channels.
+const (
+	// ChannelRGB applies the adjustment to the red, green and blue channels.
+	ChannelRGB Channel = iota
+	ChannelRed
+	ChannelGreen
+	ChannelBlue
+	// ChannelLuminance applies the adjustment to the luminance preserving the hue.
+	ChannelLuminance
+)
+
+// AdjustCurves adjusts the tones of the image using a curve defined by the control points
+// and returns the adjusted image. The X coordinate of each point is the input value and
+// the Y coordinate is the output value, both in range [0, 255]. A smooth monotone spline
+// is built through the points, the values before the first point and after the last one
+// are constant. If no points are given, the original image is returned.
+//
+// Example:
+//
+//	// S-curve increasing the contrast of the midtones.
+//	dstImage = imaging.AdjustCurves(srcImage, imaging.ChannelRGB, []image.Point{
+//		{0, 0}, {64, 48}, {192, 208}, {255, 255},
+//	})
+//
+func AdjustCurves(img image.Image, channel Channel, points []image.Point) *image.NRGBA {
+	if len(points) == 0 {
+		return Clone(img)
+	}
+	return adjustChannelLUT(img, channel, curveLUT(points))
+}
+
+// adjustChannelLUT applies the lookup table to the given channel of the image.
+func adjustChannelLUT(img image.Image, channel Channel, lut []uint8) *image.NRGBA {
+	identity := make([]uint8, 256)
+	for i := range identity {
+		identity[i] = uint8(i)
+	}
+
+	switch channel {
+	case ChannelRed:
+		return adjustLUTs(img, lut, identity, identity)
+	case ChannelGreen:
+		return adjustLUTs(img, identity, lut, identity)
+	case ChannelBlue:
+		return adjustLUTs(img, identity, identity, lut)
+	case ChannelLuminance:
+		return AdjustFunc(img, func(c color.NRGBA) color.NRGBA {
+			y := 0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)
+			v := float64(lut[clamp(y)])
+			if y < 0.5 {
+				return color.NRGBA{clamp(v), clamp(v), clamp(v), c.A}
+			}
+			k := v / y
+			return color.NRGBA{clamp(float64(c.R) * k), clamp(float64(c.G) * k), clamp(float64(c.B) * k), c.A}
+		})
+	default:
+		return adjustLUT(img, lut)
+	}
+}
+
+// AdjustLevels remaps the tones of the image like the levels tool of image editors
+// and returns the adjusted image. The input range [inBlack, inWhite] is stretched to
+// the output range [outBlack, outWhite], values outside of the input range are clipped.
+// The inGamma parameter adjusts the midtones: values greater than 1 brighten them and
+// values less than 1 darken them, 1 leaves them unchanged.
+// All the black and white points are in range [0, 255].
+//
+// Example:
+//
+//	dstImage = imaging.AdjustLevels(srcImage, 20, 1.2, 235, 0, 255)
+//
+func AdjustLevels(img image.Image, inBlack, inGamma, inWhite, outBlack, outWhite float64) *image.NRGBA {
+	return AdjustLevelsChannel(img, ChannelRGB, inBlack, inGamma, inWhite, outBlack, outWhite)
+}
+
+// AdjustLevelsChannel is like AdjustLevels, but only adjusts the given channel.
+//
+// Example:
+//
+//	// Reduce the blue cast in the shadows.
+//	dstImage = imaging.AdjustLevelsChannel(srcImage, imaging.ChannelBlue, 15, 1, 255, 0, 255)
+//
+func AdjustLevelsChannel(img image.Image, channel Channel, inBlack, inGamma, inWhite, outBlack, outWhite float64) *image.NRGBA {
+	if inGamma <= 0 || inWhite <= inBlack {
+		return Clone(img)
+	}
+	lut := make([]uint8, 256)
+	for i := range lut {
+		v := (float64(i) - inBlack) / (inWhite - inBlack)
+		v = math.Min(math.Max(v, 0), 1)
+		v = math.Pow(v, 1/inGamma)
+		lut[i] = clamp(outBlack + v*(outWhite-outBlack))
+	}
+	return adjustChannelLUT(img, channel, lut)
+}
+
+// curveLUT returns the lookup table of the monotone cubic (Fritsch-Carlson) spline
+// passing through the points.
+func curveLUT(points []image.Point) []uint8 {
+	pts := make([]image.Point, 0, len(points))
+	for _, p := range points {
+		pts = append(pts, image.Pt(int(clamp(float64(p.X))), int(clamp(float64(p.Y)))))
+	}
+	sort.SliceStable(pts, func(i, j int) bool { return pts[i].X < pts[j].X })
+	// Keep the last of the points with the same X.
+	n := 0
+	for i, p := range pts {
+		if i+1 < len(pts) && pts[i+1].X == p.X {
+			continue
+		}
+		pts[n] = p
+		n++
+	}
+	pts = pts[:n]
+
+	lut := make([]uint8, 256)
+	if len(pts) == 1 {
+		for i := range lut {
+			lut[i] = uint8(pts[0].Y)
+		}
+		return lut
+	}
 
-	return adjustLUT(img, lut)
+	// Secant slopes and tangents.
+	delta := make([]float64, n-1)
+	for i := range delta {
+		delta[i] = float64(pts[i+1].Y-pts[i].Y) / float64(pts[i+1].X-pts[i].X)
+	}
+	m := make([]float64, n)
+	m[0] = delta[0]
+	m[n-1] = delta[n-2]
+	for i := 1; i < n-1; i++ {
+		if delta[i-1]*delta[i] <= 0 {
+			m[i] = 0
+		} else {
+			m[i] = (delta[i-1] + delta[i]) / 2
+		}
+	}
+	for i := range delta {
+		if delta[i] == 0 {
+			m[i] = 0
+			m[i+1] = 0
+			continue
+		}
+		a := m[i] / delta[i]
+		b := m[i+1] / delta[i]
+		if h := a*a + b*b; h > 9 {
+			t := 3 / math.Sqrt(h)
+			m[i] = t * a * delta[i]
+			m[i+1] = t * b * delta[i]
+		}
+	}
+
+	k := 0
+	for x := range lut {
+		switch {
+		case x <= pts[0].X:
+			lut[x] = uint8(pts[0].Y)
+		case x >= pts[n-1].X:
+			lut[x] = uint8(pts[n-1].Y)
+		default:
+			for x > pts[k+1].X {
+				k++
+			}
+			h := float64(pts[k+1].X - pts[k].X)
+			t := float64(x-pts[k].X) / h
+			t2, t3 := t*t, t*t*t
+			y := (2*t3-3*t2+1)*float64(pts[k].Y) + (t3-2*t2+t)*h*m[k] +
+				(-2*t3+3*t2)*float64(pts[k+1].Y) + (t3-t2)*h*m[k+1]
+			lut[x] = clamp(y)
+		}
+	}
+	return lut
 }
 
 // AdjustSigmoid changes the contrast of the image using a sigmoidal function and returns the adjusted image.
@@ -203,7 +578,11 @@ func AdjustSigmoid(img image.Image, midpoint, factor float64) *image.NRGBA {
 	if factor == 0 {
 		return Clone(img)
 	}
+	return adjustLUT(img, sigmoidLUT(midpoint, factor))
+}
 
+// sigmoidLUT returns the lookup table used by AdjustSigmoid.
+func sigmoidLUT(midpoint, factor float64) []uint8 {
 	lut := make([]uint8, 256)
 	a := math.Min(math.Max(midpoint, 0.0), 1.0)
 	b := math.Abs(factor)
@@ -226,8 +605,7 @@ func AdjustSigmoid(img image.Image, midpoint, factor float64) *image.NRGBA {
 			lut[i] = clamp(f * 255.0)
 		}
 	}
-
-	return adjustLUT(img, lut)
+	return lut
 }
 
 func sigmoid(a, b, x float64) float64 {
@@ -236,18 +614,25 @@ func sigmoid(a, b, x float64) float64 {
 
 // adjustLUT applies the given lookup table to the colors of the image.
 func adjustLUT(img image.Image, lut []uint8) *image.NRGBA {
+	return adjustLUTs(img, lut, lut, lut)
+}
+
+// adjustLUTs applies a separate lookup table to each color channel.
+func adjustLUTs(img image.Image, lutR, lutG, lutB []uint8) *image.NRGBA {
 	src := newScanner(img)
 	dst := image.NewNRGBA(image.Rect(0, 0, src.w, src.h))
-	lut = lut[0:256]
-	parallel(0, src.h, func(ys <-chan int) {
+	lutR = lutR[0:256]
+	lutG = lutG[0:256]
+	lutB = lutB[0:256]
+	parallel(0, src.h, src.w*costPoint, func(ys <-chan int) {
 		for y := range ys {
 			i := y * dst.Stride
 			src.scan(0, y, src.w, y+1, dst.Pix[i:i+src.w*4])
 			for x := 0; x < src.w; x++ {
 				d := dst.Pix[i : i+3 : i+3]
-				d[0] = lut[d[0]]
-				d[1] = lut[d[1]]
-				d[2] = lut[d[2]]
+				d[0] = lutR[d[0]]
+				d[1] = lutG[d[1]]
+				d[2] = lutB[d[2]]
 				i += 4
 			}
 		}
@@ -274,24 +659,70 @@ func adjustLUT(img image.Image, lut []uint8) *image.NRGBA {
 func AdjustFunc(img image.Image, fn func(c color.NRGBA) color.NRGBA) *image.NRGBA {
 	src := newScanner(img)
 	dst := image.NewNRGBA(image.Rect(0, 0, src.w, src.h))
-	parallel(0, src.h, func(ys <-chan int) {
+	parallel(0, src.h, src.w*costHeavy, func(ys <-chan int) {
 		for y := range ys {
 			i := y * dst.Stride
 			src.scan(0, y, src.w, y+1, dst.Pix[i:i+src.w*4])
-			for x := 0; x < src.w; x++ {
-				d := dst.Pix[i : i+4 : i+4]
-				r := d[0]
-				g := d[1]
-				b := d[2]
-				a := d[3]
-				c := fn(color.NRGBA{r, g, b, a})
-				d[0] = c.R
-				d[1] = c.G
-				d[2] = c.B
-				d[3] = c.A
-				i += 4
-			}
+			funcLine(dst.Pix[i:i+src.w*4], fn)
 		}
 	})
 	return dst
 }
+
+// funcLine applies the fn function to each pixel of a line in place.
+func funcLine(pix []uint8, fn func(c color.NRGBA) color.NRGBA) {
+	for i := 0; i < len(pix); i += 4 {
+		d := pix[i : i+4 : i+4]
+		r := d[0]
+		g := d[1]
+		b := d[2]
+		a := d[3]
+		c := fn(color.NRGBA{r, g, b, a})
+		d[0] = c.R
+		d[1] = c.G
+		d[2] = c.B
+		d[3] = c.A
+	}
+}
+
+// ColorMatrix transforms the colors of the image using the 4x5 matrix given in row-major order.
+// Each output channel is computed as a weighted sum of the input R, G, B and A channels
+// plus the last column of the row multiplied by 255.
+//
+// Example:
+//
+//	// Swap the red and blue channels.
+//	dstImage = imaging.ColorMatrix(srcImage, [20]float64{
+//		0, 0, 1, 0, 0,
+//		0, 1, 0, 0, 0,
+//		1, 0, 0, 0, 0,
+//		0, 0, 0, 1, 0,
+//	})
+//
+func ColorMatrix(img image.Image, matrix [20]float64) *image.NRGBA {
+	src := newScanner(img)
+	dst := image.NewNRGBA(image.Rect(0, 0, src.w, src.h))
+	parallel(0, src.h, src.w*costPoint, func(ys <-chan int) {
+		for y := range ys {
+			i := y * dst.Stride
+			src.scan(0, y, src.w, y+1, dst.Pix[i:i+src.w*4])
+			colorMatrixLine(dst.Pix[i:i+src.w*4], &matrix)
+		}
+	})
+	return dst
+}
+
+// colorMatrixLine transforms the colors of a line of pixels in place.
+func colorMatrixLine(pix []uint8, m *[20]float64) {
+	for i := 0; i < len(pix); i += 4 {
+		d := pix[i : i+4 : i+4]
+		r := float64(d[0])
+		g := float64(d[1])
+		b := float64(d[2])
+		a := float64(d[3])
+		d[0] = clamp(m[0]*r + m[1]*g + m[2]*b + m[3]*a + m[4]*255)
+		d[1] = clamp(m[5]*r + m[6]*g + m[7]*b + m[8]*a + m[9]*255)
+		d[2] = clamp(m[10]*r + m[11]*g + m[12]*b + m[13]*a + m[14]*255)
+		d[3] = clamp(m[15]*r + m[16]*g + m[17]*b + m[18]*a + m[19]*255)
+	}
+}
@@ -44,6 +44,79 @@ func TestGrayscale(t *testing.T) {
 	}
 }
 
+func TestGrayscaleWithOptions(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 4, 1),
+		Stride: 4 * 4,
+		Pix: []uint8{
+			0xff, 0x00, 0x00, 0xff,
+			0x00, 0xff, 0x00, 0x80,
+			0x80, 0x80, 0x80, 0xff,
+			0xff, 0x00, 0xff, 0x40,
+		},
+	}
+	testCases := []struct {
+		name    string
+		options *GrayscaleOptions
+		want    []uint8
+	}{
+		{
+			"nil options",
+			nil,
+			[]uint8{
+				0x4c, 0x4c, 0x4c, 0xff,
+				0x96, 0x96, 0x96, 0x80,
+				0x80, 0x80, 0x80, 0xff,
+				0x69, 0x69, 0x69, 0x40,
+			},
+		},
+		{
+			"Rec709",
+			&GrayscaleOptions{Coefficients: Rec709},
+			[]uint8{
+				0x36, 0x36, 0x36, 0xff,
+				0xb6, 0xb6, 0xb6, 0x80,
+				0x80, 0x80, 0x80, 0xff,
+				0x49, 0x49, 0x49, 0x40,
+			},
+		},
+		{
+			"linear Rec601",
+			&GrayscaleOptions{Linear: true},
+			[]uint8{
+				0x95, 0x95, 0x95, 0xff,
+				0xc9, 0xc9, 0xc9, 0x80,
+				0x80, 0x80, 0x80, 0xff,
+				0xac, 0xac, 0xac, 0x40,
+			},
+		},
+		{
+			"linear Rec709",
+			&GrayscaleOptions{Linear: true, Coefficients: Rec709},
+			[]uint8{
+				0x7f, 0x7f, 0x7f, 0xff,
+				0xdc, 0xdc, 0xdc, 0x80,
+				0x80, 0x80, 0x80, 0xff,
+				0x91, 0x91, 0x91, 0x40,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := GrayscaleWithOptions(src, tc.options)
+			if !compareBytes(got.Pix, tc.want, 0) {
+				t.Fatalf("got pixels %#v want %#v", got.Pix, tc.want)
+			}
+		})
+	}
+
+	got := GrayscaleLinear(src, Rec709)
+	want := GrayscaleWithOptions(src, &GrayscaleOptions{Linear: true, Coefficients: Rec709})
+	if !compareNRGBA(got, want, 0) {
+		t.Fatalf("GrayscaleLinear differs from GrayscaleWithOptions")
+	}
+}
+
 func BenchmarkGrayscale(b *testing.B) {
 	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
@@ -247,6 +320,47 @@ func BenchmarkAdjustSaturation(b *testing.B) {
 	}
 }
 
+func TestAdjustVibrance(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 4, 1),
+		Stride: 4 * 4,
+		Pix: []uint8{
+			0xcc, 0x00, 0x00, 0x01,
+			0x60, 0x70, 0x80, 0xff,
+			0x33, 0x33, 0x33, 0xff,
+			0xaa, 0x33, 0xbb, 0xff,
+		},
+	}
+	sat := func(c color.NRGBA) float64 {
+		_, s, _ := rgbToHSL(c.R, c.G, c.B)
+		return s
+	}
+
+	got := AdjustVibrance(src, 100)
+	if c := got.NRGBAAt(0, 0); c != (color.NRGBA{0xcc, 0x00, 0x00, 0x01}) {
+		t.Fatalf("saturated color changed: got %v", c)
+	}
+	if c := got.NRGBAAt(2, 0); c != (color.NRGBA{0x33, 0x33, 0x33, 0xff}) {
+		t.Fatalf("gray color changed: got %v", c)
+	}
+	// The muted color gains relatively more saturation than the vivid one.
+	muted := sat(got.NRGBAAt(1, 0)) / sat(src.NRGBAAt(1, 0))
+	vivid := sat(got.NRGBAAt(3, 0)) / sat(src.NRGBAAt(3, 0))
+	if muted <= vivid || vivid < 1 {
+		t.Fatalf("got saturation gains %v (muted) and %v (vivid)", muted, vivid)
+	}
+
+	got = AdjustVibrance(src, -100)
+	if s := sat(got.NRGBAAt(1, 0)); s >= sat(src.NRGBAAt(1, 0)) {
+		t.Fatalf("saturation is not decreased: got %v", s)
+	}
+
+	got = AdjustVibrance(src, 0)
+	if !compareNRGBA(got, src, 0) {
+		t.Fatalf("got result %#v want %#v", got, src)
+	}
+}
+
 func TestAdjustHue(t *testing.T) {
 	testCases := []struct {
 		name string
@@ -685,6 +799,70 @@ func BenchmarkAdjustContrast(b *testing.B) {
 	}
 }
 
+func TestAutoContrast(t *testing.T) {
+	testCases := []struct {
+		name        string
+		src         image.Image
+		clipPercent float64
+		want        *image.NRGBA
+	}{
+		{
+			"AutoContrast 4x1 0",
+			&image.NRGBA{
+				Rect:   image.Rect(-1, -1, 3, 0),
+				Stride: 4 * 4,
+				Pix: []uint8{
+					0x40, 0x80, 0x00, 0xff, 0x80, 0x80, 0x80, 0xff,
+					0xc0, 0x80, 0xff, 0x80, 0x00, 0x00, 0x00, 0x00,
+				},
+			},
+			0,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 4, 1),
+				Stride: 4 * 4,
+				Pix: []uint8{
+					0x00, 0x80, 0x00, 0xff, 0x80, 0x80, 0x80, 0xff,
+					0xff, 0x80, 0xff, 0x80, 0x00, 0x00, 0x00, 0x00,
+				},
+			},
+		},
+		{
+			"AutoContrast 5x1 20",
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 5, 1),
+				Stride: 5 * 4,
+				Pix: []uint8{
+					0x00, 0x00, 0x00, 0xff, 0x40, 0x40, 0x40, 0xff, 0x80, 0x80, 0x80, 0xff,
+					0xc0, 0xc0, 0xc0, 0xff, 0xff, 0xff, 0xff, 0xff,
+				},
+			},
+			20,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 5, 1),
+				Stride: 5 * 4,
+				Pix: []uint8{
+					0x00, 0x00, 0x00, 0xff, 0x00, 0x00, 0x00, 0xff, 0x80, 0x80, 0x80, 0xff,
+					0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+				},
+			},
+		},
+		{
+			"AutoContrast 0x0",
+			&image.NRGBA{},
+			1,
+			&image.NRGBA{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := AutoContrast(tc.src, tc.clipPercent)
+			if !compareNRGBA(got, tc.want, 0) {
+				t.Fatalf("got result %#v want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
 func TestAdjustBrightness(t *testing.T) {
 	testCases := []struct {
 		name string
@@ -943,6 +1121,178 @@ func BenchmarkAdjustGamma(b *testing.B) {
 	}
 }
 
+func TestAdjustCurves(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 3, 1),
+		Stride: 3 * 4,
+		Pix: []uint8{
+			0x00, 0x40, 0x80, 0xff,
+			0xc0, 0xff, 0x20, 0x80,
+			0x80, 0x80, 0x80, 0xff,
+		},
+	}
+	testCases := []struct {
+		name    string
+		channel Channel
+		points  []image.Point
+		want    []uint8
+	}{
+		{
+			"AdjustCurves no points",
+			ChannelRGB,
+			nil,
+			src.Pix,
+		},
+		{
+			"AdjustCurves identity",
+			ChannelRGB,
+			[]image.Point{{0, 0}, {255, 255}},
+			src.Pix,
+		},
+		{
+			"AdjustCurves invert",
+			ChannelRGB,
+			[]image.Point{{255, 0}, {0, 255}},
+			[]uint8{
+				0xff, 0xbf, 0x7f, 0xff,
+				0x3f, 0x00, 0xdf, 0x80,
+				0x7f, 0x7f, 0x7f, 0xff,
+			},
+		},
+		{
+			"AdjustCurves constant",
+			ChannelRGB,
+			[]image.Point{{100, 10}},
+			[]uint8{
+				0x0a, 0x0a, 0x0a, 0xff,
+				0x0a, 0x0a, 0x0a, 0x80,
+				0x0a, 0x0a, 0x0a, 0xff,
+			},
+		},
+		{
+			"AdjustCurves red threshold",
+			ChannelRed,
+			[]image.Point{{127, 0}, {128, 255}},
+			[]uint8{
+				0x00, 0x40, 0x80, 0xff,
+				0xff, 0xff, 0x20, 0x80,
+				0xff, 0x80, 0x80, 0xff,
+			},
+		},
+		{
+			"AdjustCurves luminance",
+			ChannelLuminance,
+			[]image.Point{{0, 0}, {128, 64}, {255, 255}},
+			[]uint8{
+				0x00, 0x19, 0x31, 0xff,
+				0xa8, 0xdf, 0x1c, 0x80,
+				0x40, 0x40, 0x40, 0xff,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := AdjustCurves(src, tc.channel, tc.points)
+			if !compareBytes(got.Pix, tc.want, 0) {
+				t.Fatalf("got pixels %#v want %#v", got.Pix, tc.want)
+			}
+		})
+	}
+}
+
+func TestAdjustLevels(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 2, 1),
+		Stride: 2 * 4,
+		Pix: []uint8{
+			0x00, 0x40, 0x80, 0xff,
+			0xc0, 0xff, 0x20, 0x80,
+		},
+	}
+	testCases := []struct {
+		name                                          string
+		channel                                       Channel
+		inBlack, inGamma, inWhite, outBlack, outWhite float64
+		want                                          []uint8
+	}{
+		{
+			"identity",
+			ChannelRGB, 0, 1, 255, 0, 255,
+			src.Pix,
+		},
+		{
+			"input range",
+			ChannelRGB, 64, 1, 192, 0, 255,
+			[]uint8{
+				0x00, 0x00, 0x80, 0xff,
+				0xff, 0xff, 0x00, 0x80,
+			},
+		},
+		{
+			"output range",
+			ChannelRGB, 0, 1, 255, 100, 200,
+			[]uint8{
+				0x64, 0x7d, 0x96, 0xff,
+				0xaf, 0xc8, 0x71, 0x80,
+			},
+		},
+		{
+			"gamma",
+			ChannelRGB, 0, 2, 255, 0, 255,
+			[]uint8{
+				0x00, 0x80, 0xb5, 0xff,
+				0xdd, 0xff, 0x5a, 0x80,
+			},
+		},
+		{
+			"green inverted",
+			ChannelGreen, 0, 1, 255, 255, 0,
+			[]uint8{
+				0x00, 0xbf, 0x80, 0xff,
+				0xc0, 0x00, 0x20, 0x80,
+			},
+		},
+		{
+			"invalid gamma",
+			ChannelRGB, 0, 0, 255, 100, 200,
+			src.Pix,
+		},
+		{
+			"invalid input range",
+			ChannelRGB, 200, 1, 100, 0, 255,
+			src.Pix,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := AdjustLevelsChannel(src, tc.channel, tc.inBlack, tc.inGamma, tc.inWhite, tc.outBlack, tc.outWhite)
+			if !compareBytes(got.Pix, tc.want, 0) {
+				t.Fatalf("got pixels %#v want %#v", got.Pix, tc.want)
+			}
+			if tc.channel == ChannelRGB {
+				got = AdjustLevels(src, tc.inBlack, tc.inGamma, tc.inWhite, tc.outBlack, tc.outWhite)
+				if !compareBytes(got.Pix, tc.want, 0) {
+					t.Fatalf("AdjustLevels: got pixels %#v want %#v", got.Pix, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCurveLUTMonotone(t *testing.T) {
+	lut := curveLUT([]image.Point{{0, 0}, {64, 20}, {70, 200}, {192, 210}, {255, 255}})
+	for i := 1; i < len(lut); i++ {
+		if lut[i] < lut[i-1] {
+			t.Fatalf("curve is not monotone at %d: %d < %d", i, lut[i], lut[i-1])
+		}
+	}
+	for _, p := range []image.Point{{0, 0}, {64, 20}, {70, 200}, {192, 210}, {255, 255}} {
+		if int(lut[p.X]) != p.Y {
+			t.Fatalf("curve doesn't pass through %v: got %d", p, lut[p.X])
+		}
+	}
+}
+
 func TestAdjustSigmoid(t *testing.T) {
 	testCases := []struct {
 		name string
@@ -1116,3 +1466,49 @@ func BenchmarkAdjustFunc(b *testing.B) {
 		})
 	}
 }
+
+func TestColorMatrix(t *testing.T) {
+	testCases := []struct {
+		name   string
+		src    image.Image
+		matrix [20]float64
+		want   *image.NRGBA
+	}{
+		{
+			"ColorMatrix identity",
+			&image.NRGBA{
+				Rect:   image.Rect(-1, -1, 1, 0),
+				Stride: 2 * 4,
+				Pix:    []uint8{0x10, 0x20, 0x30, 0xff, 0x40, 0x50, 0x60, 0x80},
+			},
+			[20]float64{1, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 1, 0},
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 1),
+				Stride: 2 * 4,
+				Pix:    []uint8{0x10, 0x20, 0x30, 0xff, 0x40, 0x50, 0x60, 0x80},
+			},
+		},
+		{
+			"ColorMatrix swap red and blue, offset green",
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 1),
+				Stride: 2 * 4,
+				Pix:    []uint8{0x10, 0x20, 0x30, 0xff, 0x40, 0xf0, 0x60, 0x80},
+			},
+			[20]float64{0, 0, 1, 0, 0, 0, 1, 0, 0, 0.25, 1, 0, 0, 0, 0, 0, 0, 0, 1, 0},
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 1),
+				Stride: 2 * 4,
+				Pix:    []uint8{0x30, 0x60, 0x10, 0xff, 0x60, 0xff, 0x40, 0x80},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := ColorMatrix(tc.src, tc.matrix)
+			if !compareNRGBA(got, tc.want, 0) {
+				t.Fatalf("got result %#v want %#v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// alignMaxAngle is the largest rotation in degrees searched by Align.
+const alignMaxAngle = 4
+
+// Align estimates the translation and rotation that align img with ref, for example
+// the shots of a bracketed or focus bracketed sequence or the re-photographed scene
+// before diffing. It returns the offset in pixels and the counter-clockwise rotation
+// angle in degrees, such that img rotated around its center by the angle and then shifted
+// by the offset matches ref. ApplyAlignment performs this transformation.
+//
+// The search is coarse-to-fine: all offsets up to maxShift pixels in each direction and
+// the rotations up to 4 degrees are tried on a downscaled copy of the images and then
+// refined on the finer levels. The images are compared by the normalized cross-correlation
+// of their luminance, so the alignment is not affected by the differences of the exposure.
+// Transparent pixels are ignored.
+//
+// Example:
+//
+//	offset, angle := imaging.Align(ref, img, 50)
+//	aligned := imaging.ApplyAlignment(img, offset, angle)
+//
+func Align(ref, img image.Image, maxShift int) (image.Point, float64) {
+	rw, rh := ref.Bounds().Dx(), ref.Bounds().Dy()
+	iw, ih := img.Bounds().Dx(), img.Bounds().Dy()
+	if rw <= 0 || rh <= 0 || iw <= 0 || ih <= 0 {
+		return image.Point{}, 0
+	}
+	maxShift = max(maxShift, 0)
+
+	// The coarsest level is about 32 to 48 pixels on the shorter side.
+	levels := 1
+	for s := min(rw, rh, iw, ih); s > 48; s = (s + 1) / 2 {
+		levels++
+	}
+	refPyr := gaussianPyramid(alignPlanes(ref), levels)
+	imgPyr := gaussianPyramid(alignPlanes(img), levels)
+
+	scale := 1 << (levels - 1)
+	r := (maxShift + scale - 1) / scale
+	bests := make([]alignCandidate, 2*alignMaxAngle+1)
+	parallel(0, len(bests), (2*r+1)*(2*r+1)*refPyr[levels-1].w*refPyr[levels-1].h*4*costTap, func(as <-chan int) {
+		for a := range as {
+			bests[a].score = math.Inf(-1)
+			for dy := -r; dy <= r; dy++ {
+				for dx := -r; dx <= r; dx++ {
+					c := alignCandidate{dx: dx, dy: dy, angle: float64(a - alignMaxAngle)}
+					c.score = alignScore(refPyr[levels-1], imgPyr[levels-1], c)
+					if c.score > bests[a].score {
+						bests[a] = c
+					}
+				}
+			}
+		}
+	})
+	best := bests[alignMaxAngle]
+	for _, c := range bests {
+		if c.score > best.score {
+			best = c
+		}
+	}
+
+	step := 1.0
+	for l := levels - 2; l >= 0; l-- {
+		best.dx, best.dy = best.dx*2, best.dy*2
+		step /= 2
+		best = alignRefine(refPyr[l], imgPyr[l], best, step, maxShift>>l)
+	}
+	// The angle is refined further on the full resolution.
+	for step > 0.05 {
+		step /= 2
+		best = alignRefine(refPyr[0], imgPyr[0], best, step, maxShift)
+	}
+	return image.Pt(best.dx, best.dy), best.angle
+}
+
+// ApplyAlignment rotates the image counter-clockwise around its center by the angle
+// in degrees and shifts it by the offset, as returned by Align. The result has the size
+// of the image and the uncovered areas are transparent.
+//
+// Example:
+//
+//	aligned := imaging.ApplyAlignment(img, image.Pt(-4, 7), 1.5)
+//
+func ApplyAlignment(img image.Image, offset image.Point, angle float64) *image.NRGBA {
+	src := Clone(img)
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	if w <= 0 || h <= 0 {
+		return dst
+	}
+
+	cx, cy := float64(w)/2-0.5, float64(h)/2-0.5
+	sin, cos := math.Sincos(math.Pi * angle / 180)
+	parallel(0, h, w*4*costTap, func(ys <-chan int) {
+		for y := range ys {
+			for x := 0; x < w; x++ {
+				xf, yf := rotatePoint(float64(x-offset.X)-cx, float64(y-offset.Y)-cy, sin, cos)
+				interpolatePoint(dst, x, y, src, xf+cx, yf+cy, color.NRGBA{})
+			}
+		}
+	})
+	return dst
+}
+
+// alignCandidate is a transformation tried by Align: the offset at the current pyramid
+// level and the angle, with the similarity of the transformed image to the reference.
+type alignCandidate struct {
+	dx, dy int
+	angle  float64
+	score  float64
+}
+
+// alignRefine tries the neighbors of the candidate at the pyramid level: the offsets
+// differing by a pixel and the angles differing by the step.
+func alignRefine(ref, img *floatPlanes, c alignCandidate, step float64, maxShift int) alignCandidate {
+	best := c
+	best.score = math.Inf(-1)
+	for _, da := range []float64{0, -step, step} {
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				n := alignCandidate{
+					dx:    min(max(c.dx+dx, -maxShift), maxShift),
+					dy:    min(max(c.dy+dy, -maxShift), maxShift),
+					angle: min(max(c.angle+da, -alignMaxAngle), alignMaxAngle),
+				}
+				n.score = alignScore(ref, img, n)
+				if n.score > best.score {
+					best = n
+				}
+			}
+		}
+	}
+	return best
+}
+
+// alignPlanes returns the luminance premultiplied by the alpha and the alpha
+// of the image, so that the transparent pixels do not affect the downscaling.
+func alignPlanes(img image.Image) *floatPlanes {
+	src := floatPlanesFromImage(img)
+	p := newFloatPlanes(src.w, src.h, 2)
+	for i := 0; i < src.w*src.h; i++ {
+		s := src.pix[i*4 : i*4+4]
+		p.pix[i*2] = (0.299*s[0] + 0.587*s[1] + 0.114*s[2]) * s[3]
+		p.pix[i*2+1] = s[3]
+	}
+	return p
+}
+
+// alignScore returns the normalized cross-correlation of the luminance of the reference
+// and the transformed image over their overlap, weighted by the alpha. It returns -1
+// if the overlap is less than a quarter of the reference.
+func alignScore(ref, img *floatPlanes, c alignCandidate) float64 {
+	// Large levels are sampled sparsely, which is enough for the comparison.
+	stride := 1
+	for ref.w*ref.h/(stride*stride) > 1<<16 {
+		stride++
+	}
+
+	cx, cy := float64(img.w)/2-0.5, float64(img.h)/2-0.5
+	sin, cos := math.Sincos(math.Pi * c.angle / 180)
+	var sw, sa, sb, saa, sbb, sab float64
+	total := 0
+	for y := 0; y < ref.h; y += stride {
+		for x := 0; x < ref.w; x += stride {
+			total++
+			r := ref.pix[(y*ref.w+x)*2:][:2]
+			if r[1] < 0.01 {
+				continue
+			}
+			xf, yf := rotatePoint(float64(x-c.dx)-cx, float64(y-c.dy)-cy, sin, cos)
+			xf, yf = xf+cx, yf+cy
+			x0, y0 := int(math.Floor(xf)), int(math.Floor(yf))
+			if x0 < 0 || y0 < 0 || x0+1 >= img.w || y0+1 >= img.h {
+				continue
+			}
+			xq, yq := float32(xf)-float32(x0), float32(yf)-float32(y0)
+			i := (y0*img.w + x0) * 2
+			j := i + img.w*2
+			lum := (img.pix[i]*(1-xq)+img.pix[i+2]*xq)*(1-yq) + (img.pix[j]*(1-xq)+img.pix[j+2]*xq)*yq
+			alpha := (img.pix[i+1]*(1-xq)+img.pix[i+3]*xq)*(1-yq) + (img.pix[j+1]*(1-xq)+img.pix[j+3]*xq)*yq
+			if alpha < 0.01 {
+				continue
+			}
+
+			wt := float64(r[1] * alpha)
+			a, b := float64(r[0]/r[1]), float64(lum/alpha)
+			sw += wt
+			sa += wt * a
+			sb += wt * b
+			saa += wt * a * a
+			sbb += wt * b * b
+			sab += wt * a * b
+		}
+	}
+	if sw*4 < float64(total) {
+		return -1
+	}
+	cov := sab/sw - sa*sb/(sw*sw)
+	va := saa/sw - sa*sa/(sw*sw)
+	vb := sbb/sw - sb*sb/(sw*sw)
+	if va <= 1e-12 || vb <= 1e-12 {
+		return 0
+	}
+	return cov / math.Sqrt(va*vb)
+}
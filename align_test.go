@@ -0,0 +1,53 @@
+package imaging
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+func TestAlign(t *testing.T) {
+	ref := Clone(testdataFlowersSmallPNG)
+	testCases := []struct {
+		name   string
+		offset image.Point
+		angle  float64
+		factor float64
+	}{
+		{"shift", image.Pt(7, -4), 0, 1},
+		{"rotation", image.Pt(0, 0), 2.5, 1},
+		{"shift and rotation", image.Pt(-12, 9), -1.5, 1},
+		{"exposure", image.Pt(5, 3), 1, 0.4},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			moved := exposed(ApplyAlignment(ref, tc.offset, tc.angle), tc.factor)
+			offset, angle := Align(ref, moved, 20)
+			if math.Abs(angle+tc.angle) > 0.2 {
+				t.Fatalf("got angle %.2f want %.2f", angle, -tc.angle)
+			}
+			// Undoing the transformation restores the reference.
+			aligned := ApplyAlignment(moved, offset, angle)
+			want := exposed(ref, tc.factor)
+			inner := image.Rect(30, 30, 210, 130)
+			if d := meanAbsDiff(Crop(aligned, inner), Crop(want, inner)); d > 4 {
+				t.Fatalf("got offset %v angle %.2f, mean difference %.2f", offset, angle, d)
+			}
+			if tc.angle == 0 && offset != tc.offset.Mul(-1) {
+				t.Fatalf("got offset %v want %v", offset, tc.offset.Mul(-1))
+			}
+		})
+	}
+}
+
+func TestAlignMaxShift(t *testing.T) {
+	ref := Clone(testdataFlowersSmallPNG)
+	moved := ApplyAlignment(ref, image.Pt(15, 0), 0)
+	offset, _ := Align(ref, moved, 5)
+	if offset.X < -5 || offset.X > 5 || offset.Y < -5 || offset.Y > 5 {
+		t.Fatalf("got offset %v out of range", offset)
+	}
+	if offset, angle := Align(ref, &image.NRGBA{}, 5); offset != (image.Point{}) || angle != 0 {
+		t.Fatalf("got offset %v angle %.2f for the empty image", offset, angle)
+	}
+}
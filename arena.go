@@ -0,0 +1,102 @@
+package imaging
+
+import (
+	"context"
+	"image"
+	"sync"
+)
+
+// Arena allocates images from a single backing slab of memory. All the images allocated
+// from an arena are released together by calling Reset, after which the memory is reused
+// for new images. Using an arena per request in a service that processes many images
+// reduces the number of large allocations and the work done by the garbage collector.
+//
+// The images allocated from an arena must not be used after Reset is called.
+// An Arena is safe for concurrent use.
+type Arena struct {
+	mu   sync.Mutex
+	slab []uint8
+	off  int
+}
+
+// NewArena returns a new arena with a slab of sizeHint bytes. The slab grows as needed
+// when the allocated images don't fit into it.
+//
+// Example:
+//
+//	arena := imaging.NewArena(16 << 20)
+//	defer arena.Reset()
+//	thumb := arena.Resize(srcImage, 200, 0, imaging.Lanczos)
+//
+func NewArena(sizeHint int) *Arena {
+	if sizeHint < 0 {
+		sizeHint = 0
+	}
+	return &Arena{slab: make([]uint8, sizeHint)}
+}
+
+// alloc returns an empty slice with the capacity of n bytes from the slab.
+func (a *Arena) alloc(n int) []uint8 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.off+n > len(a.slab) {
+		// The images allocated from the old slab keep it alive until they are released.
+		size := 2 * len(a.slab)
+		if size < n {
+			size = n
+		}
+		a.slab = make([]uint8, size)
+		a.off = 0
+	}
+	buf := a.slab[a.off : a.off : a.off+n]
+	a.off += n
+	return buf
+}
+
+// buffer returns an image that has enough capacity in its pixel buffer for the given
+// bounds to be reused by the functions taking a dst image.
+func (a *Arena) buffer(r image.Rectangle) *image.NRGBA {
+	if r.Dx() <= 0 || r.Dy() <= 0 {
+		return &image.NRGBA{}
+	}
+	return &image.NRGBA{Pix: a.alloc(r.Dx() * r.Dy() * 4)}
+}
+
+// NewNRGBA returns a new transparent image with the given bounds allocated from the arena.
+func (a *Arena) NewNRGBA(r image.Rectangle) *image.NRGBA {
+	if r.Dx() <= 0 || r.Dy() <= 0 {
+		return &image.NRGBA{}
+	}
+	return newNRGBA(a.buffer(r), r)
+}
+
+// Clone returns a copy of the image allocated from the arena.
+func (a *Arena) Clone(img image.Image) *image.NRGBA {
+	b := img.Bounds()
+	return cloneInto(a.buffer(image.Rect(0, 0, b.Dx(), b.Dy())), img)
+}
+
+// Resize is like the Resize function but the resulting image is allocated from the arena.
+func (a *Arena) Resize(img image.Image, width, height int, filter ResampleFilter) *image.NRGBA {
+	dst := &image.NRGBA{}
+	srcW, srcH := img.Bounds().Dx(), img.Bounds().Dy()
+	if width >= 0 && height >= 0 && (width > 0 || height > 0) && srcW > 0 && srcH > 0 {
+		w, h := resizeSize(srcW, srcH, width, height)
+		n := w * h * 4
+		if filter.Support > 0 && w != srcW && h != srcH {
+			// The intermediate image of the two passes is allocated from the arena as well.
+			n += w * srcH * 4
+		}
+		dst = &image.NRGBA{Pix: a.alloc(n)}
+	}
+	return resize(context.Background(), dst, img, width, height, filter)
+}
+
+// Reset releases all the images allocated from the arena, so that its memory
+// can be reused for new images.
+func (a *Arena) Reset() {
+	a.mu.Lock()
+	a.off = 0
+	a.mu.Unlock()
+}
@@ -0,0 +1,73 @@
+package imaging
+
+import (
+	"image"
+	"testing"
+)
+
+func TestArena(t *testing.T) {
+	a := NewArena(64)
+
+	img := a.NewNRGBA(image.Rect(1, 1, 3, 3))
+	if img.Rect != image.Rect(1, 1, 3, 3) || img.Stride != 8 || len(img.Pix) != 16 || cap(img.Pix) != 16 {
+		t.Fatalf("bad image: %#v", img)
+	}
+	for _, v := range img.Pix {
+		if v != 0 {
+			t.Fatalf("image is not zeroed: %#v", img.Pix)
+		}
+	}
+
+	got := a.Resize(testdataBranchesPNG, 40, 0, Lanczos)
+	want := Resize(testdataBranchesPNG, 40, 0, Lanczos)
+	if !compareNRGBA(got, want, 0) {
+		t.Fatalf("Resize: result differs from the package-level function")
+	}
+
+	got = a.Clone(testdataFlowersSmallPNG)
+	want = Clone(testdataFlowersSmallPNG)
+	if !compareNRGBA(got, want, 0) {
+		t.Fatalf("Clone: result differs from the package-level function")
+	}
+
+	if got := a.Resize(testdataBranchesPNG, 0, 0, Lanczos); got.Bounds() != (image.Rectangle{}) {
+		t.Fatalf("got bounds %v want empty", got.Bounds())
+	}
+	if got := a.NewNRGBA(image.Rect(0, 0, 0, 5)); got.Bounds() != (image.Rectangle{}) {
+		t.Fatalf("got bounds %v want empty", got.Bounds())
+	}
+
+	// Images allocated after Reset reuse the memory and are zeroed.
+	a.Reset()
+	x := a.NewNRGBA(image.Rect(0, 0, 1, 1))
+	x.Pix[0] = 0xff
+	a.Reset()
+	y := a.NewNRGBA(image.Rect(0, 0, 1, 1))
+	if &x.Pix[0] != &y.Pix[0] {
+		t.Fatalf("memory is not reused after Reset")
+	}
+	if y.Pix[0] != 0 {
+		t.Fatalf("image is not zeroed after Reset")
+	}
+}
+
+func TestArenaNoOverlap(t *testing.T) {
+	a := NewArena(10)
+	imgs := []*image.NRGBA{
+		a.NewNRGBA(image.Rect(0, 0, 1, 1)),
+		a.NewNRGBA(image.Rect(0, 0, 2, 1)),
+		a.NewNRGBA(image.Rect(0, 0, 3, 1)),
+	}
+	for i, img := range imgs {
+		for j := range img.Pix {
+			img.Pix[j] = uint8(i + 1)
+		}
+	}
+	for i, img := range imgs {
+		for _, v := range img.Pix {
+			if v != uint8(i+1) {
+				t.Fatalf("image %d is overwritten: %v", i, img.Pix)
+			}
+		}
+	}
+}
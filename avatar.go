@@ -0,0 +1,254 @@
+package imaging
+
+import (
+	"crypto/sha256"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// AvatarPalette is the default palette of the InitialsAvatar backgrounds.
+// The colors are dark enough for the white initials to be readable.
+var AvatarPalette = []color.Color{
+	color.NRGBA{0xe5, 0x39, 0x35, 0xff},
+	color.NRGBA{0xd8, 0x1b, 0x60, 0xff},
+	color.NRGBA{0x8e, 0x24, 0xaa, 0xff},
+	color.NRGBA{0x5e, 0x35, 0xb1, 0xff},
+	color.NRGBA{0x39, 0x49, 0xab, 0xff},
+	color.NRGBA{0x1e, 0x88, 0xe5, 0xff},
+	color.NRGBA{0x00, 0x89, 0x7b, 0xff},
+	color.NRGBA{0x43, 0xa0, 0x47, 0xff},
+	color.NRGBA{0xf4, 0x51, 0x1e, 0xff},
+	color.NRGBA{0x6d, 0x4c, 0x41, 0xff},
+	color.NRGBA{0x54, 0x6e, 0x7a, 0xff},
+}
+
+var (
+	avatarFontOnce sync.Once
+	avatarFont     *sfnt.Font
+)
+
+// InitialsAvatar returns a square placeholder avatar of the given size with the initials
+// of the name (the first letters of the first and the last words) in white on a gradient
+// background. The background color is picked from the palette by the hash of the name,
+// so the same name always gets the same avatar. If the palette is empty, AvatarPalette
+// is used.
+//
+// Example:
+//
+//	avatar := imaging.InitialsAvatar("Ada Lovelace", 128, nil)
+//	// Make it round.
+//	avatar = imaging.ApplyMask(avatar, imaging.RoundedRectMask(128, 128, 64))
+//
+func InitialsAvatar(name string, size int, palette []color.Color) *image.NRGBA {
+	if size <= 0 {
+		return &image.NRGBA{}
+	}
+	if len(palette) == 0 {
+		palette = AvatarPalette
+	}
+	h := fnv.New32a()
+	h.Write([]byte(strings.ToLower(strings.TrimSpace(name))))
+	c := color.NRGBAModel.Convert(palette[h.Sum32()%uint32(len(palette))]).(color.NRGBA)
+
+	// A vertical gradient from a lighter to a darker shade of the color.
+	hue, sat, lum := rgbToHSL(c.R, c.G, c.B)
+	top, bottom := c, c
+	top.R, top.G, top.B = hslToRGB(hue, sat, lum+(1-lum)*0.15)
+	bottom.R, bottom.G, bottom.B = hslToRGB(hue, sat, lum*0.85)
+	dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+	verticalGradient(dst, top, bottom)
+
+	drawInitials(dst, initials(name), float64(size)*0.42, color.White)
+	return dst
+}
+
+// initials returns the uppercase first letters of the first and the last words of the name.
+func initials(name string) []rune {
+	var letters []rune
+	for _, word := range strings.Fields(name) {
+		for _, r := range word {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				letters = append(letters, unicode.ToUpper(r))
+				break
+			}
+		}
+	}
+	if len(letters) > 2 {
+		letters = []rune{letters[0], letters[len(letters)-1]}
+	}
+	return letters
+}
+
+// verticalGradient fills dst with a linear gradient from the top color to the bottom color.
+func verticalGradient(dst *image.NRGBA, top, bottom color.NRGBA) {
+	w, h := dst.Rect.Dx(), dst.Rect.Dy()
+	parallel(0, h, w*costPoint, func(ys <-chan int) {
+		for y := range ys {
+			t := 0.0
+			if h > 1 {
+				t = float64(y) / float64(h-1)
+			}
+			mix := func(a, b uint8) uint8 {
+				return clamp(float64(a) + (float64(b)-float64(a))*t)
+			}
+			c := [4]uint8{mix(top.R, bottom.R), mix(top.G, bottom.G), mix(top.B, bottom.B), mix(top.A, bottom.A)}
+			i := y * dst.Stride
+			for x := 0; x < w; x++ {
+				copy(dst.Pix[i:i+4], c[:])
+				i += 4
+			}
+		}
+	})
+}
+
+// drawInitials draws the letters centered on the dst image using the Go Bold font
+// of the given size in pixels.
+func drawInitials(dst *image.NRGBA, letters []rune, ppem float64, c color.Color) {
+	avatarFontOnce.Do(func() {
+		avatarFont, _ = sfnt.Parse(gobold.TTF)
+	})
+	if avatarFont == nil || len(letters) == 0 {
+		return
+	}
+
+	var buf sfnt.Buffer
+	scale := fixed.Int26_6(ppem * 64)
+	var segments []sfnt.Segment
+	var offsets []fixed.Int26_6 // The x offset of each segment.
+	var x fixed.Int26_6
+	for _, r := range letters {
+		idx, err := avatarFont.GlyphIndex(&buf, r)
+		if err != nil || idx == 0 {
+			continue
+		}
+		glyph, err := avatarFont.LoadGlyph(&buf, idx, scale, nil)
+		if err != nil {
+			continue
+		}
+		for _, s := range glyph {
+			segments = append(segments, s)
+			offsets = append(offsets, x)
+		}
+		advance, err := avatarFont.GlyphAdvance(&buf, idx, scale, 0)
+		if err == nil {
+			x += advance
+		}
+	}
+	if len(segments) == 0 {
+		return
+	}
+
+	// Center the bounding box of the outlines.
+	minX, minY := float32(1e9), float32(1e9)
+	maxX, maxY := float32(-1e9), float32(-1e9)
+	for i, s := range segments {
+		n := segmentArgs(s.Op)
+		for _, p := range s.Args[:n] {
+			px, py := float32(p.X+offsets[i])/64, float32(p.Y)/64
+			if px < minX {
+				minX = px
+			}
+			if px > maxX {
+				maxX = px
+			}
+			if py < minY {
+				minY = py
+			}
+			if py > maxY {
+				maxY = py
+			}
+		}
+	}
+	w, h := dst.Rect.Dx(), dst.Rect.Dy()
+	dx := (float32(w)-(maxX-minX))/2 - minX
+	dy := (float32(h)-(maxY-minY))/2 - minY
+
+	z := vector.NewRasterizer(w, h)
+	pt := func(i int, p fixed.Point26_6) (float32, float32) {
+		return float32(p.X+offsets[i])/64 + dx, float32(p.Y)/64 + dy
+	}
+	for i, s := range segments {
+		x0, y0 := pt(i, s.Args[0])
+		switch s.Op {
+		case sfnt.SegmentOpMoveTo:
+			z.MoveTo(x0, y0)
+		case sfnt.SegmentOpLineTo:
+			z.LineTo(x0, y0)
+		case sfnt.SegmentOpQuadTo:
+			x1, y1 := pt(i, s.Args[1])
+			z.QuadTo(x0, y0, x1, y1)
+		case sfnt.SegmentOpCubeTo:
+			x1, y1 := pt(i, s.Args[1])
+			x2, y2 := pt(i, s.Args[2])
+			z.CubeTo(x0, y0, x1, y1, x2, y2)
+		}
+	}
+	z.Draw(dst, dst.Rect, image.NewUniform(c), image.Point{})
+}
+
+// segmentArgs returns the number of points used by the segment operator.
+func segmentArgs(op sfnt.SegmentOp) int {
+	switch op {
+	case sfnt.SegmentOpQuadTo:
+		return 2
+	case sfnt.SegmentOpCubeTo:
+		return 3
+	}
+	return 1
+}
+
+// Identicon returns a square avatar of the given size with a symmetric 5x5 pattern
+// generated from the seed, such as a hash of a user ID or an email address.
+// The same seed always gets the same identicon.
+//
+// Example:
+//
+//	avatar := imaging.Identicon([]byte(user.Email), 120)
+//
+func Identicon(seed []byte, size int) *image.NRGBA {
+	if size <= 0 {
+		return &image.NRGBA{}
+	}
+	sum := sha256.Sum256(seed)
+	dst := New(size, size, color.NRGBA{0xf0, 0xf0, 0xf0, 0xff})
+
+	hue := float64(uint16(sum[0])<<8|uint16(sum[1])) / 65536
+	sat := 0.45 + float64(sum[2])/255*0.25
+	lum := 0.45 + float64(sum[3])/255*0.15
+	var fg color.NRGBA
+	fg.R, fg.G, fg.B = hslToRGB(hue, sat, lum)
+	fg.A = 0xff
+
+	// The grid takes the image without a margin of half a cell on each side.
+	const cells = 5
+	margin := float64(size) / (cells + 1) / 2
+	cell := (float64(size) - 2*margin) / cells
+	edge := func(i int) int {
+		return int(margin + float64(i)*cell + 0.5)
+	}
+	src := image.NewUniform(fg)
+	for row := 0; row < cells; row++ {
+		for col := 0; col < (cells+1)/2; col++ {
+			// The bits of the bytes after the color select the left half of the cells.
+			bit := row*((cells+1)/2) + col
+			if sum[4+bit/8]>>(bit%8)&1 == 0 {
+				continue
+			}
+			for _, c := range []int{col, cells - 1 - col} {
+				r := image.Rect(edge(c), edge(row), edge(c+1), edge(row+1))
+				draw.Draw(dst, r, src, image.Point{}, draw.Src)
+			}
+		}
+	}
+	return dst
+}
@@ -0,0 +1,92 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestInitials(t *testing.T) {
+	testCases := []struct {
+		name string
+		want string
+	}{
+		{"", ""},
+		{"ada", "A"},
+		{"Ada Lovelace", "AL"},
+		{"  ada  augusta king lovelace ", "AL"},
+		{"(Ada) 2nd", "A2"},
+		{"émile zola", "ÉZ"},
+	}
+	for _, tc := range testCases {
+		if got := string(initials(tc.name)); got != tc.want {
+			t.Errorf("initials(%q): got %q want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestInitialsAvatar(t *testing.T) {
+	a := InitialsAvatar("Ada Lovelace", 64, nil)
+	if got := a.Bounds(); got != image.Rect(0, 0, 64, 64) {
+		t.Fatalf("got bounds %v want %v", got, image.Rect(0, 0, 64, 64))
+	}
+	if !compareNRGBA(a, InitialsAvatar(" ada lovelace", 64, nil), 0) {
+		t.Errorf("the same name gives different avatars")
+	}
+
+	// The initials are white in the middle, the corners are the background.
+	white := 0
+	for y := 16; y < 48; y++ {
+		for x := 8; x < 56; x++ {
+			if a.NRGBAAt(x, y) == (color.NRGBA{255, 255, 255, 255}) {
+				white++
+			}
+		}
+	}
+	if white < 50 {
+		t.Errorf("got %d white pixels, the initials are not drawn", white)
+	}
+	for _, p := range []image.Point{{0, 0}, {63, 0}, {0, 63}, {63, 63}} {
+		if c := a.NRGBAAt(p.X, p.Y); c == (color.NRGBA{255, 255, 255, 255}) {
+			t.Errorf("corner %v is white", p)
+		}
+	}
+	if top, bottom := a.NRGBAAt(0, 0), a.NRGBAAt(0, 63); int(top.R)+int(top.G)+int(top.B) <= int(bottom.R)+int(bottom.G)+int(bottom.B) {
+		t.Errorf("the top %v is not lighter than the bottom %v", top, bottom)
+	}
+
+	red := color.NRGBA{200, 0, 0, 255}
+	b := InitialsAvatar("Bob", 32, []color.Color{red})
+	if c := b.NRGBAAt(0, 16); c.R < 150 || c.G > 30 || c.B > 30 {
+		t.Errorf("got background %v want a shade of %v", c, red)
+	}
+
+	if got := InitialsAvatar("x", 0, nil); !got.Bounds().Empty() {
+		t.Errorf("got bounds %v want empty", got.Bounds())
+	}
+}
+
+func TestIdenticon(t *testing.T) {
+	a := Identicon([]byte("alice@example.com"), 60)
+	if got := a.Bounds(); got != image.Rect(0, 0, 60, 60) {
+		t.Fatalf("got bounds %v want %v", got, image.Rect(0, 0, 60, 60))
+	}
+	if !compareNRGBA(a, Identicon([]byte("alice@example.com"), 60), 0) {
+		t.Errorf("the same seed gives different identicons")
+	}
+	if compareNRGBA(a, Identicon([]byte("bob@example.com"), 60), 0) {
+		t.Errorf("different seeds give the same identicon")
+	}
+	if !compareNRGBA(a, FlipH(a), 0) {
+		t.Errorf("the identicon is not symmetric")
+	}
+	bg := color.NRGBA{0xf0, 0xf0, 0xf0, 0xff}
+	for _, p := range []image.Point{{0, 0}, {59, 59}} {
+		if c := a.NRGBAAt(p.X, p.Y); c != bg {
+			t.Errorf("margin pixel %v: got %v want %v", p, c, bg)
+		}
+	}
+	if got := Identicon(nil, -1); !got.Bounds().Empty() {
+		t.Errorf("got bounds %v want empty", got.Bounds())
+	}
+}
@@ -0,0 +1,207 @@
+package imaging
+
+import (
+	"context"
+	"image"
+	"sync/atomic"
+	"time"
+)
+
+// Backend performs the computationally heavy image processing primitives.
+// The default backend, CPUBackend, runs them on the CPU using all available cores.
+// Other implementations may offload the work to a GPU (e.g. using Vulkan, Metal or CUDA)
+// and be selected per Processor without changing the calling code.
+//
+// The source images passed to a Backend are never nil. The returned images must have
+// the origin at (0, 0) and must not share pixels with the source images.
+type Backend interface {
+	// Resize resizes the image to exactly width x height pixels, both of which are positive.
+	Resize(img image.Image, width, height int, filter ResampleFilter) *image.NRGBA
+
+	// Convolve convolves the image with the kw x kh kernel given in row-major order.
+	// The options are never nil.
+	Convolve(img image.Image, kernel []float64, kw, kh int, options *ConvolveOptions) *image.NRGBA
+
+	// ColorMatrix transforms the colors of the image using the 4x5 matrix
+	// given in row-major order (see the ColorMatrix function).
+	ColorMatrix(img image.Image, matrix [20]float64) *image.NRGBA
+}
+
+// CPUBackend is the default Backend that runs on the CPU.
+var CPUBackend Backend = cpuBackend{}
+
+type cpuBackend struct{}
+
+func (cpuBackend) Resize(img image.Image, width, height int, filter ResampleFilter) *image.NRGBA {
+	return resize(context.Background(), nil, img, width, height, filter)
+}
+
+func (cpuBackend) Convolve(img image.Image, kernel []float64, kw, kh int, options *ConvolveOptions) *image.NRGBA {
+	return convolve(img, kernel, kw, kh, options)
+}
+
+func (cpuBackend) ColorMatrix(img image.Image, matrix [20]float64) *image.NRGBA {
+	return ColorMatrix(img, matrix)
+}
+
+// Processor runs image operations using the given Backend. The methods of Processor
+// have the same semantics as the package-level functions with the same names.
+// A Processor is safe for concurrent use if its Backend is.
+type Processor struct {
+	backend Backend
+	limiter *Limiter
+	stats   *processorStats
+
+	// The settings of the hardened processors, see Untrusted.
+	decodeOpts    []DecodeOption
+	validate      bool          // Reject the decoded images failing ValidateImage.
+	recoverPanics bool          // Turn the panics into errors or empty images.
+	slots         chan struct{} // Bounds the number of concurrent operations if not nil.
+	stripMetadata bool          // Don't write the metadata when encoding.
+}
+
+// NewProcessor returns a Processor that uses the given backend.
+// If backend is nil, CPUBackend is used.
+//
+// Example:
+//
+//	p := imaging.NewProcessor(gpuBackend)
+//	dstImage := p.Resize(srcImage, 800, 0, imaging.Lanczos)
+//
+func NewProcessor(backend Backend) *Processor {
+	if backend == nil {
+		backend = CPUBackend
+	}
+	return &Processor{backend: backend, stats: &processorStats{}}
+}
+
+// Backend returns the backend used by the processor.
+func (p *Processor) Backend() Backend {
+	return p.backend
+}
+
+// WithLimiter returns a new Processor with the same backend that waits for the limiter
+// before each operation. The new Processor has its own statistics.
+func (p *Processor) WithLimiter(limiter *Limiter) *Processor {
+	q := *p
+	q.limiter = limiter
+	q.stats = &processorStats{}
+	return &q
+}
+
+// Stats returns the processing statistics collected since the Processor was created.
+func (p *Processor) Stats() ProcessorStats {
+	return p.stats.snapshot()
+}
+
+// run calls the backend operation fn processing the given number of pixels,
+// waiting for the limiter and updating the statistics.
+// If the processor recovers panics, a panicking operation returns an empty image.
+func (p *Processor) run(pixels int, fn func() *image.NRGBA) (dst *image.NRGBA) {
+	if p.limiter != nil {
+		start := time.Now()
+		p.limiter.Wait(context.Background(), pixels)
+		atomic.AddInt64(&p.stats.throttled, int64(time.Since(start)))
+	}
+	defer p.acquire()()
+	start := time.Now()
+	err := p.protect(func() error {
+		dst = fn()
+		return nil
+	})
+	if err != nil {
+		dst = &image.NRGBA{}
+	}
+	atomic.AddInt64(&p.stats.busy, int64(time.Since(start)))
+	atomic.AddInt64(&p.stats.operations, 1)
+	atomic.AddInt64(&p.stats.pixels, int64(pixels))
+	return dst
+}
+
+// pixelCount returns the number of pixels of the image.
+func pixelCount(img image.Image) int {
+	return img.Bounds().Dx() * img.Bounds().Dy()
+}
+
+// Resize resizes the image to the specified width and height using the specified resampling
+// filter. If one of width or height is 0, the image aspect ratio is preserved.
+func (p *Processor) Resize(img image.Image, width, height int, filter ResampleFilter) *image.NRGBA {
+	if width < 0 || height < 0 || (width == 0 && height == 0) {
+		return &image.NRGBA{}
+	}
+	srcW, srcH := img.Bounds().Dx(), img.Bounds().Dy()
+	if srcW <= 0 || srcH <= 0 {
+		return &image.NRGBA{}
+	}
+	width, height = resizeSize(srcW, srcH, width, height)
+	if srcW == width && srcH == height {
+		return p.clone(img)
+	}
+	pixels := width * height
+	if n := pixelCount(img); n > pixels {
+		pixels = n
+	}
+	return p.run(pixels, func() *image.NRGBA {
+		return p.backend.Resize(img, width, height, filter)
+	})
+}
+
+// Fit scales down the image to fit the specified maximum width and height
+// preserving the aspect ratio.
+func (p *Processor) Fit(img image.Image, width, height int, filter ResampleFilter) *image.NRGBA {
+	srcW, srcH := img.Bounds().Dx(), img.Bounds().Dy()
+	if srcW <= 0 || srcH <= 0 || width <= 0 || height <= 0 {
+		return &image.NRGBA{}
+	}
+	if srcW <= width && srcH <= height {
+		return p.clone(img)
+	}
+	width, height = fitSize(srcW, srcH, width, height)
+	return p.Resize(img, width, height, filter)
+}
+
+// Convolve3x3 convolves the image with the specified 3x3 convolution kernel.
+// Default parameters are used if a nil *ConvolveOptions is passed.
+func (p *Processor) Convolve3x3(img image.Image, kernel [9]float64, options *ConvolveOptions) *image.NRGBA {
+	return p.convolve(img, kernel[:], 3, 3, options)
+}
+
+// Convolve5x5 convolves the image with the specified 5x5 convolution kernel.
+// Default parameters are used if a nil *ConvolveOptions is passed.
+func (p *Processor) Convolve5x5(img image.Image, kernel [25]float64, options *ConvolveOptions) *image.NRGBA {
+	return p.convolve(img, kernel[:], 5, 5, options)
+}
+
+// ConvolveKernel convolves the image with the specified convolution kernel given as a slice of rows.
+// If the kernel is empty or its rows have different lengths, a copy of the image is returned.
+func (p *Processor) ConvolveKernel(img image.Image, kernel [][]float64, options *ConvolveOptions) *image.NRGBA {
+	k, kw, kh, ok := flattenKernel(kernel)
+	if !ok {
+		return p.clone(img)
+	}
+	return p.convolve(img, k, kw, kh, options)
+}
+
+// clone returns a copy of the image as an operation of the processor, so it waits
+// for the limiter and is counted in the statistics like the others.
+func (p *Processor) clone(img image.Image) *image.NRGBA {
+	return p.run(pixelCount(img), func() *image.NRGBA {
+		return Clone(img)
+	})
+}
+
+func (p *Processor) convolve(img image.Image, kernel []float64, kw, kh int, options *ConvolveOptions) *image.NRGBA {
+	if options == nil {
+		options = &ConvolveOptions{}
+	}
+	return p.run(pixelCount(img), func() *image.NRGBA {
+		return p.backend.Convolve(img, kernel, kw, kh, options)
+	})
+}
+
+// ColorMatrix transforms the colors of the image using the 4x5 matrix given in row-major order.
+func (p *Processor) ColorMatrix(img image.Image, matrix [20]float64) *image.NRGBA {
+	return p.run(pixelCount(img), func() *image.NRGBA {
+		return p.backend.ColorMatrix(img, matrix)
+	})
+}
@@ -0,0 +1,102 @@
+package imaging
+
+import (
+	"image"
+	"testing"
+)
+
+type recordingBackend struct {
+	cpuBackend
+	calls []string
+}
+
+func (b *recordingBackend) Resize(img image.Image, width, height int, filter ResampleFilter) *image.NRGBA {
+	b.calls = append(b.calls, "Resize")
+	return b.cpuBackend.Resize(img, width, height, filter)
+}
+
+func (b *recordingBackend) Convolve(img image.Image, kernel []float64, kw, kh int, options *ConvolveOptions) *image.NRGBA {
+	b.calls = append(b.calls, "Convolve")
+	return b.cpuBackend.Convolve(img, kernel, kw, kh, options)
+}
+
+func (b *recordingBackend) ColorMatrix(img image.Image, matrix [20]float64) *image.NRGBA {
+	b.calls = append(b.calls, "ColorMatrix")
+	return b.cpuBackend.ColorMatrix(img, matrix)
+}
+
+func TestProcessor(t *testing.T) {
+	if NewProcessor(nil).Backend() != CPUBackend {
+		t.Fatalf("expected CPUBackend to be the default backend")
+	}
+
+	b := &recordingBackend{}
+	p := NewProcessor(b)
+
+	got := p.Resize(testdataBranchesPNG, 100, 0, Lanczos)
+	want := Resize(testdataBranchesPNG, 100, 0, Lanczos)
+	if !compareNRGBA(got, want, 0) {
+		t.Fatalf("Resize: result differs from the package-level function")
+	}
+
+	got = p.Fit(testdataBranchesPNG, 50, 50, Linear)
+	want = Fit(testdataBranchesPNG, 50, 50, Linear)
+	if !compareNRGBA(got, want, 0) {
+		t.Fatalf("Fit: result differs from the package-level function")
+	}
+
+	k3 := [9]float64{0, -1, 0, -1, 5, -1, 0, -1, 0}
+	got = p.Convolve3x3(testdataFlowersSmallPNG, k3, nil)
+	want = Convolve3x3(testdataFlowersSmallPNG, k3, nil)
+	if !compareNRGBA(got, want, 0) {
+		t.Fatalf("Convolve3x3: result differs from the package-level function")
+	}
+
+	var k5 [25]float64
+	k5[12] = 1
+	got = p.Convolve5x5(testdataFlowersSmallPNG, k5, nil)
+	want = Convolve5x5(testdataFlowersSmallPNG, k5, nil)
+	if !compareNRGBA(got, want, 0) {
+		t.Fatalf("Convolve5x5: result differs from the package-level function")
+	}
+
+	kernel := [][]float64{{1, 2, 1}}
+	options := &ConvolveOptions{Normalize: true, Edge: EdgeMirror}
+	got = p.ConvolveKernel(testdataFlowersSmallPNG, kernel, options)
+	want = ConvolveKernel(testdataFlowersSmallPNG, kernel, options)
+	if !compareNRGBA(got, want, 0) {
+		t.Fatalf("ConvolveKernel: result differs from the package-level function")
+	}
+
+	m := [20]float64{0.5, 0, 0, 0, 0.1, 0, 1, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 1, 0}
+	got = p.ColorMatrix(testdataFlowersSmallPNG, m)
+	want = ColorMatrix(testdataFlowersSmallPNG, m)
+	if !compareNRGBA(got, want, 0) {
+		t.Fatalf("ColorMatrix: result differs from the package-level function")
+	}
+
+	// Identity resizes and empty results must not reach the backend,
+	// the copies are still counted as the operations of the processor.
+	before := p.Stats()
+	p.Resize(testdataBranchesPNG, testdataBranchesPNG.Bounds().Dx(), 0, Lanczos)
+	p.Resize(testdataBranchesPNG, 0, 0, Lanczos)
+	p.Fit(testdataBranchesPNG, 10000, 10000, Lanczos)
+	p.ConvolveKernel(testdataBranchesPNG, nil, nil)
+	after := p.Stats()
+	if ops := after.Operations - before.Operations; ops != 3 {
+		t.Fatalf("got %d operations for the copies want 3", ops)
+	}
+	if pixels := after.Pixels - before.Pixels; pixels != 3*int64(pixelCount(testdataBranchesPNG)) {
+		t.Fatalf("got %d pixels for the copies want %d", pixels, 3*pixelCount(testdataBranchesPNG))
+	}
+
+	wantCalls := []string{"Resize", "Resize", "Convolve", "Convolve", "Convolve", "ColorMatrix"}
+	if len(b.calls) != len(wantCalls) {
+		t.Fatalf("got backend calls %v want %v", b.calls, wantCalls)
+	}
+	for i := range wantCalls {
+		if b.calls[i] != wantCalls[i] {
+			t.Fatalf("got backend calls %v want %v", b.calls, wantCalls)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+)
+
+// BadgeStyle is the appearance of a badge drawn by Badge.
+type BadgeStyle struct {
+	// Face is the font face of the text. If nil, basicfont.Face7x13 is used.
+	Face font.Face
+
+	// Background is the color of the badge. If nil, it's chosen automatically:
+	// translucent black over light images and translucent white over dark ones.
+	Background color.Color
+
+	// Color is the color of the text. If nil, black or white is chosen,
+	// whichever contrasts more with the badge.
+	Color color.Color
+
+	// Padding is the horizontal space in pixels between the text and the badge edges,
+	// the vertical space is half of it. The value of 0 means a third of the line height.
+	Padding int
+
+	// Margin is the distance in pixels between the image edges and the badge.
+	Margin int
+
+	// Radius is the radius of the badge corners. The value of 0 means a pill shape
+	// with fully rounded ends, a negative value means square corners.
+	Radius float64
+}
+
+// Badge draws a rounded label with the text over the image at the anchor position and
+// returns the result, e.g. a "NEW" mark or a duration stamp on a video thumbnail.
+// The text is drawn as a single line.
+//
+// Example:
+//
+//	// Duration stamp in the bottom right corner.
+//	dstImage := imaging.Badge(thumb, "12:34", imaging.BottomRight, imaging.BadgeStyle{Margin: 6})
+//
+func Badge(img image.Image, text string, anchor Anchor, style BadgeStyle) *image.NRGBA {
+	dst := Clone(img)
+	face := style.Face
+	if face == nil {
+		face = basicfont.Face7x13
+	}
+	metrics := face.Metrics()
+	lineHeight := metrics.Height.Ceil()
+	padX := style.Padding
+	if padX == 0 {
+		padX = lineHeight / 3
+	}
+	if padX < 0 {
+		padX = 0
+	}
+	padY := padX / 2
+	w := font.MeasureString(face, text).Ceil() + 2*padX
+	h := lineHeight + 2*padY
+	area := dst.Bounds().Inset(style.Margin)
+	if w <= 0 || h <= 0 || area.Empty() {
+		return dst
+	}
+	pos := anchorPt(area, w, h, anchor)
+	r := image.Rectangle{Min: pos, Max: pos.Add(image.Pt(w, h))}
+
+	// The colors are chosen by the luminance of the badge composited over the image.
+	under := meanColor(dst, r)
+	bg := style.Background
+	if bg == nil {
+		if luminance(under) > 0.5 {
+			bg = color.NRGBA{0, 0, 0, 0xb4}
+		} else {
+			bg = color.NRGBA{0xff, 0xff, 0xff, 0xc8}
+		}
+	}
+	fg := style.Color
+	if fg == nil {
+		b := color.NRGBAModel.Convert(bg).(color.NRGBA)
+		a := float64(b.A) / 255
+		mixed := color.NRGBA{
+			R: clamp(float64(b.R)*a + float64(under.R)*(1-a)),
+			G: clamp(float64(b.G)*a + float64(under.G)*(1-a)),
+			B: clamp(float64(b.B)*a + float64(under.B)*(1-a)),
+			A: 0xff,
+		}
+		if luminance(mixed) > 0.5 {
+			fg = color.Black
+		} else {
+			fg = color.White
+		}
+	}
+
+	radius := style.Radius
+	if radius == 0 {
+		radius = float64(h) / 2
+	}
+	tile := New(w, h, bg)
+	if radius > 0 {
+		tile = ApplyMask(tile, RoundedRectMask(w, h, radius))
+	}
+	draw.Draw(dst, r, tile, image.Point{}, draw.Over)
+	DrawText(dst, text, image.Pt(pos.X+padX, pos.Y+padY+metrics.Ascent.Ceil()), face, fg)
+	return dst
+}
+
+// meanColor returns the average color of the pixels of img inside r.
+func meanColor(img *image.NRGBA, r image.Rectangle) color.NRGBA {
+	r = r.Intersect(img.Rect)
+	if r.Empty() {
+		return color.NRGBA{}
+	}
+	var sum [4]uint64
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		i := img.PixOffset(r.Min.X, y)
+		for x := r.Min.X; x < r.Max.X; x++ {
+			s := img.Pix[i : i+4 : i+4]
+			sum[0] += uint64(s[0])
+			sum[1] += uint64(s[1])
+			sum[2] += uint64(s[2])
+			sum[3] += uint64(s[3])
+			i += 4
+		}
+	}
+	n := uint64(r.Dx() * r.Dy())
+	return color.NRGBA{uint8(sum[0] / n), uint8(sum[1] / n), uint8(sum[2] / n), uint8(sum[3] / n)}
+}
+
+// luminance returns the relative luminance of the color in range [0, 1].
+func luminance(c color.NRGBA) float64 {
+	return (0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)) / 255
+}
@@ -0,0 +1,85 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestBadge(t *testing.T) {
+	testCases := []struct {
+		name   string
+		src    color.NRGBA
+		style  BadgeStyle
+		anchor Anchor
+		corner image.Point // A pixel inside the badge near its corner.
+		text   color.NRGBA
+	}{
+		{
+			name:   "light image",
+			src:    color.NRGBA{240, 240, 240, 255},
+			anchor: BottomRight,
+			style:  BadgeStyle{Margin: 4, Radius: -1},
+			corner: image.Pt(95, 95),
+			text:   color.NRGBA{255, 255, 255, 255},
+		},
+		{
+			name:   "dark image",
+			src:    color.NRGBA{20, 20, 20, 255},
+			anchor: TopLeft,
+			style:  BadgeStyle{Radius: -1},
+			corner: image.Pt(0, 0),
+			text:   color.NRGBA{0, 0, 0, 255},
+		},
+		{
+			name:   "explicit colors",
+			src:    color.NRGBA{20, 20, 20, 255},
+			anchor: TopLeft,
+			style:  BadgeStyle{Background: color.NRGBA{255, 0, 0, 255}, Color: color.NRGBA{0, 0, 255, 255}, Radius: -1},
+			corner: image.Pt(0, 0),
+			text:   color.NRGBA{0, 0, 255, 255},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			src := New(100, 100, tc.src)
+			got := Badge(src, "NEW", tc.anchor, tc.style)
+			if c := got.NRGBAAt(tc.corner.X, tc.corner.Y); c == tc.src {
+				t.Errorf("the badge is not drawn at %v", tc.corner)
+			}
+			if c := got.NRGBAAt(50, 50); c != tc.src {
+				t.Errorf("the center is changed: got %v want %v", c, tc.src)
+			}
+			found := false
+			for y := 0; y < 100 && !found; y++ {
+				for x := 0; x < 100; x++ {
+					if got.NRGBAAt(x, y) == tc.text {
+						found = true
+						break
+					}
+				}
+			}
+			if !found {
+				t.Errorf("no text pixels of color %v", tc.text)
+			}
+			if src.NRGBAAt(tc.corner.X, tc.corner.Y) != tc.src {
+				t.Errorf("the source image is modified")
+			}
+		})
+	}
+}
+
+func TestBadgePill(t *testing.T) {
+	src := New(100, 100, color.NRGBA{240, 240, 240, 255})
+	got := Badge(src, "HD", TopLeft, BadgeStyle{})
+	// The corner of the bounding box is outside of the rounded end.
+	if c := got.NRGBAAt(0, 0); c != (color.NRGBA{240, 240, 240, 255}) {
+		t.Errorf("got corner %v want the background", c)
+	}
+	if c := got.NRGBAAt(12, 2); c == (color.NRGBA{240, 240, 240, 255}) {
+		t.Errorf("the badge is not drawn")
+	}
+	if !compareNRGBA(Badge(src, "x", TopLeft, BadgeStyle{Margin: 200}), src, 0) {
+		t.Errorf("a badge outside of the image changed it")
+	}
+}
@@ -0,0 +1,131 @@
+package imaging
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+// FrameScore is a frame selected by PickBestFrame with its quality scores.
+// All the scores are in the range [0, 1], higher is better.
+type FrameScore struct {
+	Index int         // Index of the frame in the source.
+	Frame image.Image // The frame as returned by the source.
+
+	Score        float64 // Overall score, a weighted sum of the other scores.
+	Sharpness    float64 // Amount of fine detail, low for blurred frames.
+	Exposure     float64 // Low for too dark, too bright or clipped frames.
+	Colorfulness float64 // Low for dull and gray frames.
+}
+
+// Weights of the scores in FrameScore.Score.
+const (
+	frameSharpnessWeight    = 0.5
+	frameExposureWeight     = 0.3
+	frameColorfulnessWeight = 0.2
+)
+
+// PickBestFrame reads all the frames from the source and returns the n frames that are
+// the best candidates for a thumbnail, sorted by their overall score, best first. The frames
+// are scored by sharpness (the variance of the Laplacian), exposure (the mean luminance and
+// the amount of clipped pixels) and colorfulness (the Hasler-Süsstrunk metric). Only the
+// current candidates are kept in memory. The reading stops at the first error returned
+// by the source.
+//
+// Example:
+//
+//	best := imaging.PickBestFrame(frames, 3)
+//	if len(best) > 0 {
+//		thumb := imaging.Thumbnail(best[0].Frame, 320, 180, imaging.Lanczos)
+//	}
+//
+func PickBestFrame(frames FrameSource, n int) []FrameScore {
+	if n <= 0 {
+		return nil
+	}
+	var best []FrameScore
+	for i := 0; ; i++ {
+		frame, err := frames.NextFrame()
+		if err != nil || frame == nil {
+			break
+		}
+		s := scoreFrame(frame)
+		s.Index = i
+		s.Frame = frame
+		// Keep the candidates sorted, the earlier frame wins a tie.
+		pos := sort.Search(len(best), func(j int) bool { return best[j].Score < s.Score })
+		if pos >= n {
+			continue
+		}
+		if len(best) < n {
+			best = append(best, FrameScore{})
+		}
+		copy(best[pos+1:], best[pos:])
+		best[pos] = s
+	}
+	return best
+}
+
+// scoreFrame computes the quality scores of the frame.
+func scoreFrame(img image.Image) FrameScore {
+	small := Fit(img, 256, 256, Box)
+	w, h := small.Rect.Dx(), small.Rect.Dy()
+	if w < 3 || h < 3 {
+		return FrameScore{}
+	}
+
+	lum := make([]float64, w*h)
+	var lumSum float64
+	var clipped int
+	var rgSum, ybSum, rgSum2, ybSum2 float64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*small.Stride + x*4
+			s := small.Pix[i : i+3 : i+3]
+			r, g, b := float64(s[0]), float64(s[1]), float64(s[2])
+			l := 0.299*r + 0.587*g + 0.114*b
+			lum[y*w+x] = l
+			lumSum += l
+			if l < 5 || l > 250 {
+				clipped++
+			}
+			rg := r - g
+			yb := 0.5*(r+g) - b
+			rgSum += rg
+			ybSum += yb
+			rgSum2 += rg * rg
+			ybSum2 += yb * yb
+		}
+	}
+	count := float64(w * h)
+
+	// The variance of the Laplacian.
+	var lapSum, lapSum2 float64
+	for y := 1; y < h-1; y++ {
+		for x := 1; x < w-1; x++ {
+			i := y*w + x
+			v := lum[i-w] + lum[i+w] + lum[i-1] + lum[i+1] - 4*lum[i]
+			lapSum += v
+			lapSum2 += v * v
+		}
+	}
+	lapCount := float64((w - 2) * (h - 2))
+	lapMean := lapSum / lapCount
+	lapVar := lapSum2/lapCount - lapMean*lapMean
+
+	mean := lumSum / count / 255
+	exposure := 1 - 2*math.Abs(mean-0.5) - float64(clipped)/count
+
+	rgMean, ybMean := rgSum/count, ybSum/count
+	rgVar := math.Max(rgSum2/count-rgMean*rgMean, 0)
+	ybVar := math.Max(ybSum2/count-ybMean*ybMean, 0)
+	colorfulness := math.Sqrt(rgVar+ybVar) + 0.3*math.Sqrt(rgMean*rgMean+ybMean*ybMean)
+
+	s := FrameScore{
+		Sharpness:    lapVar / (lapVar + 100),
+		Exposure:     math.Min(math.Max(exposure, 0), 1),
+		Colorfulness: colorfulness / (colorfulness + 50),
+	}
+	s.Score = frameSharpnessWeight*s.Sharpness + frameExposureWeight*s.Exposure + frameColorfulnessWeight*s.Colorfulness
+	return s
+}
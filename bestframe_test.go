@@ -0,0 +1,59 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"io"
+	"testing"
+)
+
+func TestScoreFrame(t *testing.T) {
+	orig := scoreFrame(testdataBranchesPNG)
+	if s := scoreFrame(Blur(testdataBranchesPNG, 3)); s.Sharpness >= orig.Sharpness {
+		t.Fatalf("blurred frame sharpness %v >= %v", s.Sharpness, orig.Sharpness)
+	}
+	if s := scoreFrame(AdjustBrightness(testdataBranchesPNG, -70)); s.Exposure >= orig.Exposure {
+		t.Fatalf("dark frame exposure %v >= %v", s.Exposure, orig.Exposure)
+	}
+	if s := scoreFrame(Grayscale(testdataBranchesPNG)); s.Colorfulness >= orig.Colorfulness || s.Colorfulness != 0 {
+		t.Fatalf("grayscale frame colorfulness %v, original %v", s.Colorfulness, orig.Colorfulness)
+	}
+	for _, v := range []float64{orig.Score, orig.Sharpness, orig.Exposure, orig.Colorfulness} {
+		if v <= 0 || v >= 1 {
+			t.Fatalf("score out of range: %+v", orig)
+		}
+	}
+	if s := scoreFrame(New(2, 2, color.White)); s != (FrameScore{}) {
+		t.Fatalf("got %+v for a tiny frame", s)
+	}
+}
+
+func TestPickBestFrame(t *testing.T) {
+	frames := []image.Image{
+		Blur(testdataBranchesPNG, 3),
+		testdataBranchesPNG,
+		AdjustBrightness(testdataBranchesPNG, -70),
+		testdataBranchesPNG,
+		New(100, 100, color.Black),
+	}
+	got := PickBestFrame(sliceFrames(frames, io.EOF), 3)
+	if len(got) != 3 {
+		t.Fatalf("got %d frames want 3", len(got))
+	}
+	// The identical frames keep their order.
+	if got[0].Index != 1 || got[1].Index != 3 || got[0].Frame != frames[1] {
+		t.Fatalf("got best frames %d, %d", got[0].Index, got[1].Index)
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i].Score > got[i-1].Score {
+			t.Fatalf("the frames are not sorted by score")
+		}
+	}
+
+	if got := PickBestFrame(sliceFrames(frames, io.EOF), 10); len(got) != len(frames) || got[len(got)-1].Index != 4 {
+		t.Fatalf("got %d frames, the worst is %d", len(got), got[len(got)-1].Index)
+	}
+	if got := PickBestFrame(sliceFrames(frames, io.EOF), 0); got != nil {
+		t.Fatalf("got %v for n = 0", got)
+	}
+}
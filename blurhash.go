@@ -0,0 +1,224 @@
+package imaging
+
+import (
+	"errors"
+	"image"
+	"math"
+	"strings"
+)
+
+// ErrInvalidBlurHash means that the BlurHash string is malformed.
+var ErrInvalidBlurHash = errors.New("imaging: invalid BlurHash")
+
+const blurHashChars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// EncodeBlurHash returns the BlurHash of the image: a short string, usually 20 to 30 characters,
+// describing a blurred version of the image that web pages show as a placeholder while
+// the image is loading (see https://blurha.sh). The numbers of the components xComp and yComp,
+// from 1 to 9, set the amount of the horizontal and vertical detail; 4 and 3 are typical for
+// the landscape images. The result is the same as that of the reference implementation,
+// which ignores the alpha channel.
+//
+// Example:
+//
+//	hash, err := imaging.EncodeBlurHash(thumbnail, 4, 3)
+//
+func EncodeBlurHash(img image.Image, xComp, yComp int) (string, error) {
+	if xComp < 1 || xComp > 9 || yComp < 1 || yComp > 9 {
+		return "", errors.New("imaging: BlurHash components must be from 1 to 9")
+	}
+	s := newScanner(img)
+	w, h := s.w, s.h
+	if w <= 0 || h <= 0 {
+		return "", errors.New("imaging: empty image")
+	}
+
+	var lin [256]float64
+	for i := range lin {
+		lin[i] = srgbToLinear(float64(i) / 255)
+	}
+	cosX := make([][]float64, xComp)
+	for i := range cosX {
+		cosX[i] = make([]float64, w)
+		for x := range cosX[i] {
+			cosX[i][x] = math.Cos(math.Pi * float64(i) * float64(x) / float64(w))
+		}
+	}
+
+	// The sums of the rows multiplied by the horizontal basis functions.
+	rows := make([][]float64, h)
+	parallel(0, h, w*xComp*costTap, func(ys <-chan int) {
+		line := make([]uint8, w*4)
+		for y := range ys {
+			s.scan(0, y, w, y+1, line)
+			sums := make([]float64, xComp*3)
+			for x := 0; x < w; x++ {
+				r, g, b := lin[line[x*4]], lin[line[x*4+1]], lin[line[x*4+2]]
+				for i := 0; i < xComp; i++ {
+					c := cosX[i][x]
+					sums[i*3] += r * c
+					sums[i*3+1] += g * c
+					sums[i*3+2] += b * c
+				}
+			}
+			rows[y] = sums
+		}
+	})
+
+	factors := make([][3]float64, xComp*yComp)
+	for j := 0; j < yComp; j++ {
+		for i := 0; i < xComp; i++ {
+			var f [3]float64
+			for y := 0; y < h; y++ {
+				c := math.Cos(math.Pi * float64(j) * float64(y) / float64(h))
+				for k := range f {
+					f[k] += rows[y][i*3+k] * c
+				}
+			}
+			scale := 2.0
+			if i == 0 && j == 0 {
+				scale = 1
+			}
+			for k := range f {
+				f[k] *= scale / float64(w*h)
+			}
+			factors[j*xComp+i] = f
+		}
+	}
+
+	sb := &strings.Builder{}
+	writeBase83(sb, (xComp-1)+(yComp-1)*9, 1)
+	maxValue := 1.0
+	if len(factors) > 1 {
+		actualMax := 0.0
+		for _, f := range factors[1:] {
+			actualMax = max(actualMax, math.Abs(f[0]), math.Abs(f[1]), math.Abs(f[2]))
+		}
+		quantizedMax := int(max(0, min(82, math.Floor(actualMax*166-0.5))))
+		maxValue = float64(quantizedMax+1) / 166
+		writeBase83(sb, quantizedMax, 1)
+	} else {
+		writeBase83(sb, 0, 1)
+	}
+
+	dc := factors[0]
+	writeBase83(sb, blurHashSRGB(dc[0])<<16|blurHashSRGB(dc[1])<<8|blurHashSRGB(dc[2]), 4)
+	for _, f := range factors[1:] {
+		var q [3]int
+		for k := range q {
+			q[k] = int(max(0, min(18, math.Floor(signPow(f[k]/maxValue, 0.5)*9+9.5))))
+		}
+		writeBase83(sb, q[0]*19*19+q[1]*19+q[2], 2)
+	}
+	return sb.String(), nil
+}
+
+// DecodeBlurHash returns the image of the given size described by the BlurHash.
+// Small sizes, such as 32x32, are enough, as the image is blurred, and can be scaled
+// up by the browser. It returns ErrInvalidBlurHash if the hash is malformed.
+//
+// Example:
+//
+//	placeholder, err := imaging.DecodeBlurHash("LEHV6nWB2yk8pyo0adR*.7kCMdnj", 32, 32)
+//
+func DecodeBlurHash(hash string, width, height int) (*image.NRGBA, error) {
+	if len(hash) < 6 {
+		return nil, ErrInvalidBlurHash
+	}
+	sizeFlag, ok := readBase83(hash[:1])
+	if !ok {
+		return nil, ErrInvalidBlurHash
+	}
+	xComp, yComp := sizeFlag%9+1, sizeFlag/9+1
+	if len(hash) != 4+2*xComp*yComp {
+		return nil, ErrInvalidBlurHash
+	}
+	quantizedMax, ok1 := readBase83(hash[1:2])
+	dc, ok2 := readBase83(hash[2:6])
+	if !ok1 || !ok2 {
+		return nil, ErrInvalidBlurHash
+	}
+	maxValue := float64(quantizedMax+1) / 166
+
+	colors := make([][3]float64, xComp*yComp)
+	colors[0] = [3]float64{
+		srgbToLinear(float64(dc>>16) / 255),
+		srgbToLinear(float64(dc>>8&0xff) / 255),
+		srgbToLinear(float64(dc&0xff) / 255),
+	}
+	for i := 1; i < len(colors); i++ {
+		v, ok := readBase83(hash[4+i*2 : 6+i*2])
+		if !ok {
+			return nil, ErrInvalidBlurHash
+		}
+		q := [3]int{v / (19 * 19), v / 19 % 19, v % 19}
+		for k := range q {
+			colors[i][k] = signPow(float64(q[k]-9)/9, 2) * maxValue
+		}
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, max(width, 0), max(height, 0)))
+	if width <= 0 || height <= 0 {
+		return dst, nil
+	}
+	cosX := make([][]float64, xComp)
+	for i := range cosX {
+		cosX[i] = make([]float64, width)
+		for x := range cosX[i] {
+			cosX[i][x] = math.Cos(math.Pi * float64(i) * float64(x) / float64(width))
+		}
+	}
+	parallel(0, height, width*xComp*yComp*costTap, func(ys <-chan int) {
+		for y := range ys {
+			for x := 0; x < width; x++ {
+				var c [3]float64
+				for j := 0; j < yComp; j++ {
+					cy := math.Cos(math.Pi * float64(j) * float64(y) / float64(height))
+					for i := 0; i < xComp; i++ {
+						basis := cosX[i][x] * cy
+						for k := range c {
+							c[k] += colors[j*xComp+i][k] * basis
+						}
+					}
+				}
+				d := dst.Pix[y*dst.Stride+x*4 : y*dst.Stride+x*4+4 : y*dst.Stride+x*4+4]
+				d[0] = uint8(blurHashSRGB(c[0]))
+				d[1] = uint8(blurHashSRGB(c[1]))
+				d[2] = uint8(blurHashSRGB(c[2]))
+				d[3] = 0xff
+			}
+		}
+	})
+	return dst, nil
+}
+
+// blurHashSRGB converts the linear value to the 8-bit sRGB value the way
+// the reference implementation does.
+func blurHashSRGB(v float64) int {
+	return int(linearToSRGB(max(0, min(1, v)))*255 + 0.5)
+}
+
+func signPow(v, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(v), exp), v)
+}
+
+// writeBase83 writes the value as the given number of base 83 digits.
+func writeBase83(sb *strings.Builder, value, length int) {
+	for i := length - 1; i >= 0; i-- {
+		digit := value / int(math.Pow(83, float64(i))) % 83
+		sb.WriteByte(blurHashChars[digit])
+	}
+}
+
+// readBase83 decodes the base 83 digits.
+func readBase83(s string) (int, bool) {
+	value := 0
+	for i := 0; i < len(s); i++ {
+		digit := strings.IndexByte(blurHashChars, s[i])
+		if digit < 0 {
+			return 0, false
+		}
+		value = value*83 + digit
+	}
+	return value, true
+}
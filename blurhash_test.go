@@ -0,0 +1,69 @@
+package imaging
+
+import (
+	"errors"
+	"image/color"
+	"testing"
+)
+
+func TestEncodeBlurHash(t *testing.T) {
+	// A uniform color has only the DC component.
+	hash, err := EncodeBlurHash(New(8, 8, color.NRGBA{255, 0, 0, 255}), 1, 1)
+	if err != nil {
+		t.Fatalf("EncodeBlurHash: %v", err)
+	}
+	if hash != "00TI:j" {
+		t.Fatalf("got hash %q", hash)
+	}
+	hash, err = EncodeBlurHash(New(8, 8, color.NRGBA{255, 0, 0, 255}), 2, 1)
+	if err != nil || len(hash) != 8 || hash[:1] != "1" || hash[2:6] != "TI:j" {
+		t.Fatalf("got hash %q, %v", hash, err)
+	}
+
+	for _, comp := range [][2]int{{0, 3}, {4, 10}} {
+		if _, err := EncodeBlurHash(testdataBranchesPNG, comp[0], comp[1]); err == nil {
+			t.Fatalf("expected an error for the components %v", comp)
+		}
+	}
+	if _, err := EncodeBlurHash(New(0, 0, color.Black), 4, 3); err == nil {
+		t.Fatalf("expected an error for the empty image")
+	}
+}
+
+func TestBlurHashRoundTrip(t *testing.T) {
+	src := testdataFlowersSmallPNG
+	hash, err := EncodeBlurHash(src, 4, 3)
+	if err != nil {
+		t.Fatalf("EncodeBlurHash: %v", err)
+	}
+	if len(hash) != 4+2*4*3 {
+		t.Fatalf("got hash %q", hash)
+	}
+	got, err := DecodeBlurHash(hash, 24, 16)
+	if err != nil {
+		t.Fatalf("DecodeBlurHash: %v", err)
+	}
+	if got.Rect.Dx() != 24 || got.Rect.Dy() != 16 {
+		t.Fatalf("got bounds %v", got.Rect)
+	}
+	// The placeholder resembles a strongly blurred image.
+	want := Resize(Blur(src, 20), 24, 16, Box)
+	if d := meanAbsDiff(got, want); d > 20 {
+		t.Fatalf("got mean difference %.2f", d)
+	}
+}
+
+func TestDecodeBlurHashInvalid(t *testing.T) {
+	for _, hash := range []string{"", "00TI:", "00TI:jf", "10TI:j", "0\"TI:j", "100TI:j\"Q"} {
+		if _, err := DecodeBlurHash(hash, 4, 4); !errors.Is(err, ErrInvalidBlurHash) {
+			t.Fatalf("got error %v for %q", err, hash)
+		}
+	}
+	got, err := DecodeBlurHash("LEHV6nWB2yk8pyo0adR*.7kCMdnj", 32, 32)
+	if err != nil || got.Rect.Dx() != 32 {
+		t.Fatalf("got %v, %v", got.Rect, err)
+	}
+	if got, err := DecodeBlurHash("00TI:j", 3, 2); err != nil || got.NRGBAAt(2, 1) != (color.NRGBA{255, 0, 0, 255}) {
+		t.Fatalf("got %v, %v", got.Pix, err)
+	}
+}
@@ -0,0 +1,311 @@
+/*
+Package cards renders images from templates, such as social cards and Open Graph images.
+
+A template has a fixed size, a background color and a list of layers drawn in order:
+image slots, text boxes and rectangles. The content of the image slots and the text
+placeholders is given at render time, so a single template is reused for many cards.
+Templates can be defined in Go or loaded from JSON:
+
+	{
+		"width": 1200,
+		"height": 630,
+		"background": "#1d2330",
+		"layers": [
+			{"type": "image", "box": {"x": 0, "y": 0, "width": 1200, "height": 630}, "image": "cover"},
+			{"type": "rect", "box": {"x": 40, "y": 400, "width": 1120, "height": 190}, "color": "#000000a0", "radius": 16},
+			{"type": "text", "box": {"x": 70, "y": 420, "width": 1060, "height": 150}, "text": "{{title}}",
+			 "font": "title", "color": "#ffffff", "align": "left"}
+		]
+	}
+*/
+package cards
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/font"
+
+	"github.com/disintegration/imaging"
+)
+
+// Template is a card template.
+type Template struct {
+	Width      int     `json:"width"`
+	Height     int     `json:"height"`
+	Background Color   `json:"background"`
+	Layers     []Layer `json:"layers"`
+}
+
+// LayerType is the type of a template layer.
+type LayerType string
+
+// Layer types.
+const (
+	// LayerImage draws the image from Data.Images named by Layer.Image into the box.
+	LayerImage LayerType = "image"
+	// LayerText draws Layer.Text wrapped to the width of the box.
+	LayerText LayerType = "text"
+	// LayerRect fills the box with Layer.Color.
+	LayerRect LayerType = "rect"
+)
+
+// Fit is the way an image is fitted into the box of an image layer.
+type Fit string
+
+// Fit modes.
+const (
+	// FitFill scales the image to fill the box and crops it according to the alignment (the default).
+	FitFill Fit = "fill"
+	// FitContain scales the image to fit the box and aligns it inside the box.
+	FitContain Fit = "contain"
+	// FitStretch scales the image to the size of the box ignoring its aspect ratio.
+	FitStretch Fit = "stretch"
+)
+
+// Box is a rectangle in the card.
+type Box struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// Rect returns the box as an image.Rectangle.
+func (b Box) Rect() image.Rectangle {
+	return image.Rect(b.X, b.Y, b.X+b.Width, b.Y+b.Height)
+}
+
+// Layer is a template layer. The fields used depend on the layer type.
+type Layer struct {
+	Type LayerType `json:"type"`
+	Box  Box       `json:"box"`
+
+	// Image is the name of the image slot of image layers.
+	Image string `json:"image,omitempty"`
+	// Fit is the fit mode of image layers.
+	Fit Fit `json:"fit,omitempty"`
+
+	// Text is the text of text layers. The placeholders like {{name}} are replaced
+	// with the values from Data.Text.
+	Text string `json:"text,omitempty"`
+	// Font is the name of the font face of text layers.
+	Font string `json:"font,omitempty"`
+
+	// Color is the color of text and rect layers.
+	Color Color `json:"color"`
+	// Radius is the corner radius of image and rect layers.
+	Radius float64 `json:"radius,omitempty"`
+	// Align is the alignment of the text in text layers and of the image in image
+	// layers: "center" (the default), "top-left", "top", "top-right", "left", "right",
+	// "bottom-left", "bottom" or "bottom-right".
+	Align string `json:"align,omitempty"`
+}
+
+// Data is the content rendered into a template.
+type Data struct {
+	Images map[string]image.Image
+	Text   map[string]string
+	Fonts  map[string]font.Face
+}
+
+// Color is a color that is encoded in JSON as "#rrggbb" or "#rrggbbaa".
+type Color color.NRGBA
+
+// RGBA implements the color.Color interface.
+func (c Color) RGBA() (r, g, b, a uint32) {
+	return color.NRGBA(c).RGBA()
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (c Color) MarshalJSON() ([]byte, error) {
+	s := fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+	if c.A != 0xff {
+		s += fmt.Sprintf("%02x", c.A)
+	}
+	return json.Marshal(s)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (c *Color) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if len(hex) != 8 || err != nil {
+		return fmt.Errorf("cards: invalid color %q", s)
+	}
+	*c = Color{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}
+	return nil
+}
+
+var anchors = map[string]imaging.Anchor{
+	"":             imaging.Center,
+	"center":       imaging.Center,
+	"top-left":     imaging.TopLeft,
+	"top":          imaging.Top,
+	"top-right":    imaging.TopRight,
+	"left":         imaging.Left,
+	"right":        imaging.Right,
+	"bottom-left":  imaging.BottomLeft,
+	"bottom":       imaging.Bottom,
+	"bottom-right": imaging.BottomRight,
+}
+
+// ErrInvalidSize means the template size is not positive.
+var ErrInvalidSize = errors.New("cards: invalid template size")
+
+// Parse parses a JSON template and validates it.
+func Parse(data []byte) (*Template, error) {
+	t := &Template{}
+	if err := json.Unmarshal(data, t); err != nil {
+		return nil, err
+	}
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Validate checks the template size and the types, fit modes and alignments of the layers.
+func (t *Template) Validate() error {
+	if t.Width <= 0 || t.Height <= 0 {
+		return ErrInvalidSize
+	}
+	for i, l := range t.Layers {
+		switch l.Type {
+		case LayerImage, LayerText, LayerRect:
+		default:
+			return fmt.Errorf("cards: layer %d: invalid type %q", i, l.Type)
+		}
+		switch l.Fit {
+		case "", FitFill, FitContain, FitStretch:
+		default:
+			return fmt.Errorf("cards: layer %d: invalid fit %q", i, l.Fit)
+		}
+		if _, ok := anchors[l.Align]; !ok {
+			return fmt.Errorf("cards: layer %d: invalid align %q", i, l.Align)
+		}
+	}
+	return nil
+}
+
+// Render renders the template with the given data. All the images and fonts used by
+// the layers must be present in the data, the missing text values are replaced with
+// empty strings.
+//
+// Example:
+//
+//	card, err := tmpl.Render(cards.Data{
+//		Images: map[string]image.Image{"cover": cover},
+//		Text:   map[string]string{"title": post.Title},
+//		Fonts:  map[string]font.Face{"title": titleFace},
+//	})
+//
+func (t *Template) Render(data Data) (*image.NRGBA, error) {
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+	dst := imaging.New(t.Width, t.Height, t.Background)
+	for i, l := range t.Layers {
+		r := l.Box.Rect()
+		if r.Empty() {
+			continue
+		}
+		anchor := anchors[l.Align]
+		switch l.Type {
+		case LayerImage:
+			img, ok := data.Images[l.Image]
+			if !ok {
+				return nil, fmt.Errorf("cards: layer %d: missing image %q", i, l.Image)
+			}
+			tile := fitImage(img, r, l.Fit, anchor)
+			if l.Radius > 0 {
+				tile = imaging.ApplyMask(tile, imaging.RoundedRectMask(tile.Rect.Dx(), tile.Rect.Dy(), l.Radius))
+			}
+			size := tile.Rect.Size()
+			pos := r.Min
+			if l.Fit == FitContain {
+				pos = anchorPoint(r, size, anchor)
+			}
+			draw.Draw(dst, image.Rectangle{Min: pos, Max: pos.Add(size)}, tile, image.Point{}, draw.Over)
+		case LayerText:
+			face, ok := data.Fonts[l.Font]
+			if !ok {
+				return nil, fmt.Errorf("cards: layer %d: missing font %q", i, l.Font)
+			}
+			imaging.DrawTextBox(dst, expand(l.Text, data.Text), r, face, l.Color, anchor)
+		case LayerRect:
+			if l.Radius > 0 {
+				tile := imaging.New(r.Dx(), r.Dy(), l.Color)
+				tile = imaging.ApplyMask(tile, imaging.RoundedRectMask(r.Dx(), r.Dy(), l.Radius))
+				draw.Draw(dst, r, tile, image.Point{}, draw.Over)
+			} else {
+				draw.Draw(dst, r, image.NewUniform(l.Color), image.Point{}, draw.Over)
+			}
+		}
+	}
+	return dst, nil
+}
+
+// fitImage scales the image for the box according to the fit mode.
+func fitImage(img image.Image, r image.Rectangle, fit Fit, anchor imaging.Anchor) *image.NRGBA {
+	switch fit {
+	case FitContain:
+		return imaging.Fit(img, r.Dx(), r.Dy(), imaging.Lanczos)
+	case FitStretch:
+		return imaging.Resize(img, r.Dx(), r.Dy(), imaging.Lanczos)
+	}
+	return imaging.Fill(img, r.Dx(), r.Dy(), anchor, imaging.Lanczos)
+}
+
+// anchorPoint returns the top-left corner of the area of the given size
+// aligned inside r according to the anchor.
+func anchorPoint(r image.Rectangle, size image.Point, anchor imaging.Anchor) image.Point {
+	x := r.Min.X + (r.Dx()-size.X)/2
+	y := r.Min.Y + (r.Dy()-size.Y)/2
+	switch anchor {
+	case imaging.TopLeft, imaging.Left, imaging.BottomLeft:
+		x = r.Min.X
+	case imaging.TopRight, imaging.Right, imaging.BottomRight:
+		x = r.Max.X - size.X
+	}
+	switch anchor {
+	case imaging.TopLeft, imaging.Top, imaging.TopRight:
+		y = r.Min.Y
+	case imaging.BottomLeft, imaging.Bottom, imaging.BottomRight:
+		y = r.Max.Y - size.Y
+	}
+	return image.Pt(x, y)
+}
+
+// expand replaces the {{name}} placeholders in the text with the values.
+func expand(text string, values map[string]string) string {
+	var b strings.Builder
+	for {
+		start := strings.Index(text, "{{")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(text[start:], "}}")
+		if end < 0 {
+			break
+		}
+		b.WriteString(text[:start])
+		b.WriteString(values[strings.TrimSpace(text[start+2:start+end])])
+		text = text[start+end+2:]
+	}
+	b.WriteString(text)
+	return b.String()
+}
@@ -0,0 +1,163 @@
+package cards
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	"testing"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+
+	"github.com/disintegration/imaging"
+)
+
+const testTemplate = `{
+	"width": 120,
+	"height": 60,
+	"background": "#102030",
+	"layers": [
+		{"type": "image", "box": {"x": 0, "y": 0, "width": 60, "height": 60}, "image": "photo"},
+		{"type": "rect", "box": {"x": 60, "y": 0, "width": 60, "height": 20}, "color": "#ff000080"},
+		{"type": "rect", "box": {"x": 60, "y": 20, "width": 60, "height": 40}, "color": "#00ff00", "radius": 10},
+		{"type": "text", "box": {"x": 60, "y": 20, "width": 60, "height": 40}, "text": "{{title}}", "font": "body",
+		 "color": "#ffffff", "align": "top-left"}
+	]
+}`
+
+func TestRender(t *testing.T) {
+	tmpl, err := Parse([]byte(testTemplate))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	data := Data{
+		Images: map[string]image.Image{"photo": imaging.New(200, 100, color.NRGBA{0, 0, 255, 255})},
+		Text:   map[string]string{"title": "Hi"},
+		Fonts:  map[string]font.Face{"body": basicfont.Face7x13},
+	}
+	card, err := tmpl.Render(data)
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if got := card.Bounds(); got != image.Rect(0, 0, 120, 60) {
+		t.Fatalf("got bounds %v want %v", got, image.Rect(0, 0, 120, 60))
+	}
+
+	testCases := []struct {
+		x, y int
+		want color.NRGBA
+	}{
+		{30, 30, color.NRGBA{0, 0, 255, 255}},        // The image.
+		{90, 10, color.NRGBA{0x88, 0x10, 0x18, 255}}, // The translucent rect over the background.
+		{61, 21, color.NRGBA{0x10, 0x20, 0x30, 255}}, // The rounded corner.
+		{110, 50, color.NRGBA{0, 255, 0, 255}},       // The rect.
+	}
+	for _, tc := range testCases {
+		got := card.NRGBAAt(tc.x, tc.y)
+		if absDiff(got.R, tc.want.R) > 1 || absDiff(got.G, tc.want.G) > 1 || absDiff(got.B, tc.want.B) > 1 || got.A != tc.want.A {
+			t.Errorf("pixel (%d, %d): got %v want %v", tc.x, tc.y, got, tc.want)
+		}
+	}
+
+	// The text is drawn in the top-left corner of its box.
+	white := 0
+	for y := 20; y < 35; y++ {
+		for x := 60; x < 80; x++ {
+			if card.NRGBAAt(x, y) == (color.NRGBA{255, 255, 255, 255}) {
+				white++
+			}
+		}
+	}
+	if white == 0 {
+		t.Errorf("the text is not drawn")
+	}
+
+	delete(data.Images, "photo")
+	if _, err := tmpl.Render(data); err == nil {
+		t.Errorf("Render with a missing image: expected error got nil")
+	}
+	data.Images["photo"] = imaging.New(1, 1, color.Black)
+	delete(data.Fonts, "body")
+	if _, err := tmpl.Render(data); err == nil {
+		t.Errorf("Render with a missing font: expected error got nil")
+	}
+}
+
+func TestRenderFitContain(t *testing.T) {
+	tmpl := &Template{
+		Width:      40,
+		Height:     20,
+		Background: Color{0, 0, 0, 255},
+		Layers: []Layer{
+			{Type: LayerImage, Box: Box{0, 0, 40, 20}, Image: "img", Fit: FitContain, Align: "right"},
+		},
+	}
+	card, err := tmpl.Render(Data{Images: map[string]image.Image{
+		"img": imaging.New(10, 10, color.NRGBA{255, 255, 255, 255}),
+	}})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if got := card.NRGBAAt(5, 10); got != (color.NRGBA{0, 0, 0, 255}) {
+		t.Errorf("left side: got %v want black", got)
+	}
+	if got := card.NRGBAAt(35, 10); got != (color.NRGBA{255, 255, 255, 255}) {
+		t.Errorf("right side: got %v want white", got)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	testCases := []string{
+		`{"width": 0, "height": 10}`,
+		`{"width": 10, "height": 10, "layers": [{"type": "circle"}]}`,
+		`{"width": 10, "height": 10, "layers": [{"type": "image", "fit": "cover"}]}`,
+		`{"width": 10, "height": 10, "layers": [{"type": "text", "align": "middle"}]}`,
+		`{"width": 10, "height": 10, "background": "#12345"}`,
+		`{"width": 10, "height": 10, "background": "red"}`,
+	}
+	for _, tc := range testCases {
+		if _, err := Parse([]byte(tc)); err == nil {
+			t.Errorf("Parse(%s): expected error got nil", tc)
+		}
+	}
+}
+
+func TestColorJSON(t *testing.T) {
+	for _, c := range []Color{{1, 2, 3, 255}, {0xab, 0xcd, 0xef, 0x10}} {
+		data, err := json.Marshal(c)
+		if err != nil {
+			t.Fatalf("Marshal: %v", err)
+		}
+		var got Color
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("Unmarshal(%s): %v", data, err)
+		}
+		if got != c {
+			t.Errorf("got %v want %v", got, c)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	testCases := []struct {
+		text, want string
+	}{
+		{"plain", "plain"},
+		{"{{a}} and {{ b }}", "A and B"},
+		{"{{missing}}!", "!"},
+		{"open {{a", "open {{a"},
+	}
+	values := map[string]string{"a": "A", "b": "B"}
+	for _, tc := range testCases {
+		if got := expand(tc.text, values); got != tc.want {
+			t.Errorf("expand(%q): got %q want %q", tc.text, got, tc.want)
+		}
+	}
+}
+
+func absDiff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
@@ -0,0 +1,283 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// CleanScanOptions are parameters of CleanScan.
+type CleanScanOptions struct {
+	// Window is the size in pixels of the neighborhood used by the adaptive threshold.
+	// The value of 0 means 1/40 of the smaller image side, but at least 15 pixels.
+	Window int
+
+	// Bias is the fraction by which a pixel must be darker than the average of its
+	// neighborhood to become black. The value of 0 means 0.15.
+	Bias float64
+
+	// MaxSkew is the maximum skew angle in degrees corrected by deskewing.
+	// The value of 0 means 5 degrees, a negative value disables deskewing.
+	MaxSkew float64
+
+	// MinSpeckle is the size in pixels of the smallest black blob that is kept, the smaller
+	// blobs are removed as noise. The value of 0 means 6 pixels, a negative value disables
+	// despeckling.
+	MinSpeckle int
+
+	// Margin is the distance in pixels kept between the content and the edges when
+	// the white borders are trimmed. A negative value disables trimming.
+	Margin int
+}
+
+// CleanScan prepares a scanned or photographed document page for OCR. It converts the image
+// to black and white using an adaptive threshold, so uneven lighting and shadows don't turn
+// into black areas, removes the isolated specks of noise, straightens the page by rotating
+// it by the detected skew angle of the text lines and trims the white borders. Default
+// parameters are used if a nil *CleanScanOptions is passed. The result contains only black
+// and white pixels.
+//
+// Example:
+//
+//	page, err := imaging.Open("scan.jpg", imaging.AutoOrientation(true))
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	clean := imaging.CleanScan(page, nil)
+//
+func CleanScan(img image.Image, options *CleanScanOptions) *image.NRGBA {
+	if options == nil {
+		options = &CleanScanOptions{}
+	}
+	lum, w, h := luminancePlane(img)
+	if w <= 0 || h <= 0 {
+		return &image.NRGBA{}
+	}
+
+	window := options.Window
+	if window <= 0 {
+		window = max(15, min(w, h)/40)
+	}
+	bias := options.Bias
+	if bias == 0 {
+		bias = 0.15
+	}
+	bin := binarize(lum, w, h, window, bias, 0)
+
+	minSpeckle := options.MinSpeckle
+	if minSpeckle == 0 {
+		minSpeckle = 6
+	}
+	if minSpeckle > 1 {
+		despeckle(bin, w, h, minSpeckle)
+	}
+
+	maxSkew := options.MaxSkew
+	if maxSkew == 0 {
+		maxSkew = 5
+	}
+	if maxSkew > 0 {
+		if angle := estimateSkew(bin, w, h, maxSkew); angle != 0 {
+			rotated := Rotate(grayPlaneToNRGBA(bin, w, h), -angle, color.White)
+			w, h = rotated.Rect.Dx(), rotated.Rect.Dy()
+			bin = make([]uint8, w*h)
+			for i := range bin {
+				if rotated.Pix[i*4] >= 128 {
+					bin[i] = 255
+				}
+			}
+		}
+	}
+
+	if options.Margin >= 0 {
+		r := darkBounds(bin, w, h)
+		if !r.Empty() {
+			r = r.Inset(-options.Margin).Intersect(image.Rect(0, 0, w, h))
+			trimmed := make([]uint8, r.Dx()*r.Dy())
+			for y := r.Min.Y; y < r.Max.Y; y++ {
+				copy(trimmed[(y-r.Min.Y)*r.Dx():], bin[y*w+r.Min.X:y*w+r.Max.X])
+			}
+			bin, w, h = trimmed, r.Dx(), r.Dy()
+		}
+	}
+	return grayPlaneToNRGBA(bin, w, h)
+}
+
+// luminancePlane returns the luminance of the image pixels in row-major order, with
+// the transparent pixels treated as white paper.
+func luminancePlane(img image.Image) ([]uint8, int, int) {
+	src := newScanner(img)
+	w, h := src.w, src.h
+	if w <= 0 || h <= 0 {
+		return nil, 0, 0
+	}
+	lum := make([]uint8, w*h)
+	parallel(0, h, w*costPoint, func(ys <-chan int) {
+		line := make([]uint8, w*4)
+		for y := range ys {
+			src.scan(0, y, w, y+1, line)
+			for x := 0; x < w; x++ {
+				s := line[x*4 : x*4+4 : x*4+4]
+				a := uint32(s[3])
+				l := (19595*uint32(s[0]) + 38470*uint32(s[1]) + 7471*uint32(s[2]) + 1<<15) >> 16
+				lum[y*w+x] = uint8((l*a + 255*(255-a) + 127) / 255)
+			}
+		}
+	})
+	return lum, w, h
+}
+
+// binarize applies the Bradley adaptive threshold: a pixel becomes black (0) if it's darker
+// than the mean of the window around it by the bias fraction and then by the offset in the
+// luminance units, otherwise white (255).
+func binarize(lum []uint8, w, h, window int, bias, offset float64) []uint8 {
+	// The integral image of the luminance.
+	sum := make([]uint64, (w+1)*(h+1))
+	for y := 0; y < h; y++ {
+		var row uint64
+		for x := 0; x < w; x++ {
+			row += uint64(lum[y*w+x])
+			sum[(y+1)*(w+1)+x+1] = sum[y*(w+1)+x+1] + row
+		}
+	}
+	r := window / 2
+	bin := make([]uint8, w*h)
+	parallel(0, h, w*costPoint, func(ys <-chan int) {
+		for y := range ys {
+			y0, y1 := max(y-r, 0), min(y+r+1, h)
+			for x := 0; x < w; x++ {
+				x0, x1 := max(x-r, 0), min(x+r+1, w)
+				total := sum[y1*(w+1)+x1] - sum[y0*(w+1)+x1] - sum[y1*(w+1)+x0] + sum[y0*(w+1)+x0]
+				count := float64((x1 - x0) * (y1 - y0))
+				if float64(lum[y*w+x])*count > float64(total)*(1-bias)-offset*count {
+					bin[y*w+x] = 255
+				}
+			}
+		}
+	})
+	return bin
+}
+
+// despeckle removes the 8-connected black blobs smaller than minSize pixels.
+func despeckle(bin []uint8, w, h, minSize int) {
+	visited := make([]bool, w*h)
+	var blob, stack []int
+	for start := range bin {
+		if bin[start] != 0 || visited[start] {
+			continue
+		}
+		blob = blob[:0]
+		stack = append(stack[:0], start)
+		visited[start] = true
+		for len(stack) > 0 {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			blob = append(blob, i)
+			x, y := i%w, i/w
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					nx, ny := x+dx, y+dy
+					if nx < 0 || nx >= w || ny < 0 || ny >= h {
+						continue
+					}
+					j := ny*w + nx
+					if bin[j] == 0 && !visited[j] {
+						visited[j] = true
+						stack = append(stack, j)
+					}
+				}
+			}
+		}
+		if len(blob) < minSize {
+			for _, i := range blob {
+				bin[i] = 255
+			}
+		}
+	}
+}
+
+// estimateSkew returns the angle in degrees, counter-clockwise, by which the text lines
+// are rotated. It finds the angle at which the projection profile of the black pixels
+// onto the vertical axis is the sharpest.
+func estimateSkew(bin []uint8, w, h int, maxSkew float64) float64 {
+	// Sample the black pixels of a large image.
+	step := 1
+	for (w/step)*(h/step) > 1000*1000 {
+		step++
+	}
+	var xs, ys []float64
+	for y := 0; y < h; y += step {
+		for x := 0; x < w; x += step {
+			if bin[y*w+x] == 0 {
+				xs = append(xs, float64(x)/float64(step))
+				ys = append(ys, float64(y)/float64(step))
+			}
+		}
+	}
+	if len(xs) == 0 {
+		return 0
+	}
+	size := int(math.Hypot(float64(w), float64(h))/float64(step)) + 2
+	bins := make([]float64, 2*size)
+	score := func(angle float64) float64 {
+		sin, cos := math.Sincos(angle * math.Pi / 180)
+		for i := range bins {
+			bins[i] = 0
+		}
+		for i := range xs {
+			bins[int(ys[i]*cos+xs[i]*sin)+size]++
+		}
+		var s float64
+		for _, b := range bins {
+			s += b * b
+		}
+		return s
+	}
+
+	best, bestScore := 0.0, score(0)
+	search := func(from, to, delta float64) {
+		for a := from; a <= to+delta/2; a += delta {
+			if s := score(a); s > bestScore {
+				best, bestScore = a, s
+			}
+		}
+	}
+	search(-maxSkew, maxSkew, 0.5)
+	search(math.Max(best-0.5, -maxSkew), math.Min(best+0.5, maxSkew), 0.05)
+	if math.Abs(best) < 0.05 {
+		return 0
+	}
+	return best
+}
+
+// darkBounds returns the bounding box of the black pixels.
+func darkBounds(bin []uint8, w, h int) image.Rectangle {
+	r := image.Rectangle{Min: image.Pt(w, h)}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if bin[y*w+x] == 0 {
+				r.Min.X = min(r.Min.X, x)
+				r.Min.Y = min(r.Min.Y, y)
+				r.Max.X = max(r.Max.X, x+1)
+				r.Max.Y = max(r.Max.Y, y+1)
+			}
+		}
+	}
+	if r.Empty() {
+		return image.Rectangle{}
+	}
+	return r
+}
+
+// grayPlaneToNRGBA converts a plane of gray values to an opaque NRGBA image.
+func grayPlaneToNRGBA(plane []uint8, w, h int) *image.NRGBA {
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for i, v := range plane {
+		d := dst.Pix[i*4 : i*4+4 : i*4+4]
+		d[0] = v
+		d[1] = v
+		d[2] = v
+		d[3] = 0xff
+	}
+	return dst
+}
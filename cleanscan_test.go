@@ -0,0 +1,120 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// testPage returns a white page with lines of "text" (dashed bars) and a light gradient
+// simulating uneven lighting.
+func testPage() *image.NRGBA {
+	page := image.NewNRGBA(image.Rect(0, 0, 300, 200))
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 300; x++ {
+			v := uint8(250 - x/6) // Darker to the right.
+			c := color.NRGBA{v, v, v, 255}
+			if y >= 40 && y < 160 && (y-40)%20 < 6 && x >= 40 && x < 260 && (x/8)%4 != 3 {
+				c = color.NRGBA{v - 150, v - 150, v - 150, 255}
+			}
+			page.SetNRGBA(x, y, c)
+		}
+	}
+	return page
+}
+
+func TestCleanScanBinarize(t *testing.T) {
+	page := testPage()
+	got := CleanScan(page, &CleanScanOptions{MaxSkew: -1, Margin: -1})
+	if got.Bounds() != page.Bounds() {
+		t.Fatalf("got bounds %v want %v", got.Bounds(), page.Bounds())
+	}
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 300; x++ {
+			c := got.NRGBAAt(x, y)
+			if (c.R != 0 && c.R != 255) || c.R != c.G || c.R != c.B || c.A != 255 {
+				t.Fatalf("pixel (%d, %d) is not black or white: %v", x, y, c)
+			}
+		}
+	}
+	// The text is black and the paper is white despite the gradient.
+	if c := got.NRGBAAt(42, 42); c.R != 0 {
+		t.Errorf("text pixel: got %v want black", c)
+	}
+	if c := got.NRGBAAt(290, 190); c.R != 255 {
+		t.Errorf("paper pixel: got %v want white", c)
+	}
+}
+
+func TestCleanScanDespeckleTrim(t *testing.T) {
+	page := testPage()
+	// Isolated specks.
+	for _, p := range []image.Point{{10, 10}, {280, 180}, {150, 25}} {
+		page.SetNRGBA(p.X, p.Y, color.NRGBA{0, 0, 0, 255})
+		page.SetNRGBA(p.X+1, p.Y, color.NRGBA{0, 0, 0, 255})
+	}
+	got := CleanScan(page, &CleanScanOptions{MaxSkew: -1, Margin: 5})
+	want := image.Rect(0, 0, 220+10, 106+10)
+	if got.Bounds() != want {
+		t.Fatalf("got bounds %v want %v", got.Bounds(), want)
+	}
+
+	kept := CleanScan(page, &CleanScanOptions{MaxSkew: -1, MinSpeckle: -1})
+	if kept.Bounds() != image.Rect(0, 0, 272, 171) {
+		t.Fatalf("without despeckling: got bounds %v want %v", kept.Bounds(), image.Rect(0, 0, 272, 171))
+	}
+}
+
+func TestCleanScanDeskew(t *testing.T) {
+	page := CleanScan(testPage(), &CleanScanOptions{MaxSkew: -1, Margin: -1})
+	for _, angle := range []float64{-3, 2} {
+		rotated := Rotate(page, angle, color.White)
+		bin := make([]uint8, rotated.Rect.Dx()*rotated.Rect.Dy())
+		for i := range bin {
+			if rotated.Pix[i*4] >= 128 {
+				bin[i] = 255
+			}
+		}
+		if got := estimateSkew(bin, rotated.Rect.Dx(), rotated.Rect.Dy(), 5); math.Abs(got-angle) > 0.2 {
+			t.Errorf("estimateSkew: got %v want %v", got, angle)
+		}
+
+		// The deskewed text lines are horizontal: only the rows at the line edges
+		// are partially black.
+		if got := mixedRows(CleanScan(rotated, nil)); got > 20 {
+			t.Errorf("angle %v: got %d rows crossing the text lines", angle, got)
+		}
+		if got := mixedRows(CleanScan(rotated, &CleanScanOptions{MaxSkew: -1})); got < 40 {
+			t.Errorf("angle %v without deskewing: got %d rows crossing the text lines", angle, got)
+		}
+	}
+}
+
+// mixedRows returns the number of rows that are neither mostly white nor mostly black.
+func mixedRows(img *image.NRGBA) int {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	mixed := 0
+	for y := 0; y < h; y++ {
+		black := 0
+		for x := 0; x < w; x++ {
+			if img.Pix[y*img.Stride+x*4] == 0 {
+				black++
+			}
+		}
+		if black > w/10 && black < w/2 {
+			mixed++
+		}
+	}
+	return mixed
+}
+
+func TestCleanScanEmpty(t *testing.T) {
+	if got := CleanScan(&image.NRGBA{}, nil); !got.Bounds().Empty() {
+		t.Errorf("got bounds %v want empty", got.Bounds())
+	}
+	blank := New(50, 40, color.White)
+	if got := CleanScan(blank, nil); !compareNRGBA(got, blank, 0) {
+		t.Errorf("a blank page is changed")
+	}
+}
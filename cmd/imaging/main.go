@@ -0,0 +1,437 @@
+// Command imaging applies the operations of the imaging package to image files.
+//
+// Usage:
+//
+//	imaging <operation> [flags] [+ <operation> [flags]...] [-o output] [-format ext] [-quality q] files...
+//
+// The operations separated by "+" are applied in order. The input files can be glob
+// patterns, which is useful on systems where the shell doesn't expand them. Each result
+// is written into the output directory (the -o flag ending with a path separator or
+// naming an existing directory) under the name of the input file, or into the output
+// file if there is a single input file.
+//
+// Examples:
+//
+//	imaging resize -w 800 -filter lanczos *.jpg -o out/
+//	imaging fit -w 400 -h 400 + sharpen -sigma 0.5 photo.jpg -o thumb.png
+//	imaging convert -format png scans/*.tif -o png/
+//
+// Operations:
+//
+//	resize   -w, -h, -filter    resize to the width and height, 0 keeps the aspect ratio
+//	fit      -w, -h, -filter    scale down to fit the bounding box
+//	fill     -w, -h, -anchor, -filter
+//	                            scale and crop to fill the box
+//	crop     -w, -h, -anchor    crop a box of the size at the anchor point
+//	rotate   -angle, -bg        rotate counter-clockwise by the angle in degrees
+//	flip     -v                 flip horizontally, or vertically with -v
+//	blur     -sigma             gaussian blur
+//	sharpen  -sigma             sharpen
+//	adjust   -brightness, -contrast, -gamma, -saturation, -hue
+//	                            color adjustments, the percentages are in range (-100, 100)
+//	grayscale                   convert to grayscale
+//	invert                      negate the colors
+//	convert                     no change, only convert the format
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stderr); err != nil {
+		if err != flag.ErrHelp {
+			fmt.Fprintln(os.Stderr, "imaging:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+// step is an operation applied to an image.
+type step func(img image.Image) image.Image
+
+// outputConfig holds the flags shared by all operations.
+type outputConfig struct {
+	output  string
+	format  string
+	quality int
+}
+
+// operation defines the flags of an operation and returns the step built from them.
+type operation func(fs *flag.FlagSet) func() (step, error)
+
+var operations = map[string]operation{
+	"resize": func(fs *flag.FlagSet) func() (step, error) {
+		w, h := sizeFlags(fs)
+		filter := filterFlag(fs)
+		return func() (step, error) {
+			f, err := parseFilter(*filter)
+			if err != nil {
+				return nil, err
+			}
+			if *w < 0 || *h < 0 || (*w == 0 && *h == 0) {
+				return nil, errors.New("resize: -w or -h must be positive")
+			}
+			return func(img image.Image) image.Image { return imaging.Resize(img, *w, *h, f) }, nil
+		}
+	},
+	"fit": func(fs *flag.FlagSet) func() (step, error) {
+		w, h := sizeFlags(fs)
+		filter := filterFlag(fs)
+		return func() (step, error) {
+			f, err := parseFilter(*filter)
+			if err != nil {
+				return nil, err
+			}
+			if *w <= 0 || *h <= 0 {
+				return nil, errors.New("fit: -w and -h must be positive")
+			}
+			return func(img image.Image) image.Image { return imaging.Fit(img, *w, *h, f) }, nil
+		}
+	},
+	"fill": func(fs *flag.FlagSet) func() (step, error) {
+		w, h := sizeFlags(fs)
+		filter, anchor := filterFlag(fs), anchorFlag(fs)
+		return func() (step, error) {
+			f, err := parseFilter(*filter)
+			if err != nil {
+				return nil, err
+			}
+			a, err := parseAnchor(*anchor)
+			if err != nil {
+				return nil, err
+			}
+			if *w <= 0 || *h <= 0 {
+				return nil, errors.New("fill: -w and -h must be positive")
+			}
+			return func(img image.Image) image.Image { return imaging.Fill(img, *w, *h, a, f) }, nil
+		}
+	},
+	"crop": func(fs *flag.FlagSet) func() (step, error) {
+		w, h := sizeFlags(fs)
+		anchor := anchorFlag(fs)
+		return func() (step, error) {
+			a, err := parseAnchor(*anchor)
+			if err != nil {
+				return nil, err
+			}
+			if *w <= 0 || *h <= 0 {
+				return nil, errors.New("crop: -w and -h must be positive")
+			}
+			return func(img image.Image) image.Image { return imaging.CropAnchor(img, *w, *h, a) }, nil
+		}
+	},
+	"rotate": func(fs *flag.FlagSet) func() (step, error) {
+		angle := fs.Float64("angle", 0, "counter-clockwise angle in degrees")
+		bg := fs.String("bg", "00000000", "background color as hex RRGGBB or RRGGBBAA")
+		return func() (step, error) {
+			c, err := parseColor(*bg)
+			if err != nil {
+				return nil, err
+			}
+			return func(img image.Image) image.Image { return imaging.Rotate(img, *angle, c) }, nil
+		}
+	},
+	"flip": func(fs *flag.FlagSet) func() (step, error) {
+		v := fs.Bool("v", false, "flip vertically")
+		return func() (step, error) {
+			return func(img image.Image) image.Image {
+				if *v {
+					return imaging.FlipV(img)
+				}
+				return imaging.FlipH(img)
+			}, nil
+		}
+	},
+	"blur": func(fs *flag.FlagSet) func() (step, error) {
+		sigma := fs.Float64("sigma", 1, "blur strength")
+		return func() (step, error) {
+			return func(img image.Image) image.Image { return imaging.Blur(img, *sigma) }, nil
+		}
+	},
+	"sharpen": func(fs *flag.FlagSet) func() (step, error) {
+		sigma := fs.Float64("sigma", 1, "sharpening strength")
+		return func() (step, error) {
+			return func(img image.Image) image.Image { return imaging.Sharpen(img, *sigma) }, nil
+		}
+	},
+	"adjust": func(fs *flag.FlagSet) func() (step, error) {
+		brightness := fs.Float64("brightness", 0, "brightness change in percent")
+		contrast := fs.Float64("contrast", 0, "contrast change in percent")
+		gamma := fs.Float64("gamma", 1, "gamma correction")
+		saturation := fs.Float64("saturation", 0, "saturation change in percent")
+		hue := fs.Float64("hue", 0, "hue shift in degrees")
+		return func() (step, error) {
+			if *gamma <= 0 {
+				return nil, errors.New("adjust: -gamma must be positive")
+			}
+			return func(img image.Image) image.Image {
+				p := imaging.NewPipeline().AdjustBrightness(*brightness).AdjustContrast(*contrast).AdjustGamma(*gamma)
+				img = p.Run(img)
+				if *saturation != 0 {
+					img = imaging.AdjustSaturation(img, *saturation)
+				}
+				if *hue != 0 {
+					img = imaging.AdjustHue(img, *hue)
+				}
+				return img
+			}, nil
+		}
+	},
+	"grayscale": func(fs *flag.FlagSet) func() (step, error) {
+		return func() (step, error) {
+			return func(img image.Image) image.Image { return imaging.Grayscale(img) }, nil
+		}
+	},
+	"invert": func(fs *flag.FlagSet) func() (step, error) {
+		return func() (step, error) {
+			return func(img image.Image) image.Image { return imaging.Invert(img) }, nil
+		}
+	},
+	"convert": func(fs *flag.FlagSet) func() (step, error) {
+		return func() (step, error) {
+			return func(img image.Image) image.Image { return img }, nil
+		}
+	},
+}
+
+func sizeFlags(fs *flag.FlagSet) (w, h *int) {
+	return fs.Int("w", 0, "width"), fs.Int("h", 0, "height")
+}
+
+func filterFlag(fs *flag.FlagSet) *string {
+	return fs.String("filter", "lanczos", "resampling filter")
+}
+
+func anchorFlag(fs *flag.FlagSet) *string {
+	return fs.String("anchor", "center", "anchor point: center, top-left, top, top-right, left, right, bottom-left, bottom, bottom-right")
+}
+
+var filters = map[string]imaging.ResampleFilter{
+	"nearest":           imaging.NearestNeighbor,
+	"box":               imaging.Box,
+	"linear":            imaging.Linear,
+	"hermite":           imaging.Hermite,
+	"mitchellnetravali": imaging.MitchellNetravali,
+	"catmullrom":        imaging.CatmullRom,
+	"bspline":           imaging.BSpline,
+	"gaussian":          imaging.Gaussian,
+	"bartlett":          imaging.Bartlett,
+	"lanczos":           imaging.Lanczos,
+	"hann":              imaging.Hann,
+	"hamming":           imaging.Hamming,
+	"blackman":          imaging.Blackman,
+	"welch":             imaging.Welch,
+	"cosine":            imaging.Cosine,
+}
+
+func parseFilter(name string) (imaging.ResampleFilter, error) {
+	f, ok := filters[strings.ToLower(name)]
+	if !ok {
+		return f, fmt.Errorf("unknown filter %q", name)
+	}
+	return f, nil
+}
+
+var anchors = map[string]imaging.Anchor{
+	"center":       imaging.Center,
+	"top-left":     imaging.TopLeft,
+	"top":          imaging.Top,
+	"top-right":    imaging.TopRight,
+	"left":         imaging.Left,
+	"right":        imaging.Right,
+	"bottom-left":  imaging.BottomLeft,
+	"bottom":       imaging.Bottom,
+	"bottom-right": imaging.BottomRight,
+}
+
+func parseAnchor(name string) (imaging.Anchor, error) {
+	a, ok := anchors[strings.ToLower(name)]
+	if !ok {
+		return a, fmt.Errorf("unknown anchor %q", name)
+	}
+	return a, nil
+}
+
+// parseColor parses a color given as hex RRGGBB or RRGGBBAA.
+func parseColor(s string) (color.Color, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if len(hex) != 8 || err != nil {
+		return nil, fmt.Errorf("invalid color %q", s)
+	}
+	return color.NRGBA{uint8(v >> 24), uint8(v >> 16), uint8(v >> 8), uint8(v)}, nil
+}
+
+// run parses the command line and processes the files.
+func run(args []string, stderr io.Writer) error {
+	steps, files, cfg, err := parseArgs(args, stderr)
+	if err != nil {
+		return err
+	}
+	inputs, err := expandGlobs(files)
+	if err != nil {
+		return err
+	}
+
+	toDir := len(inputs) > 1 || strings.HasSuffix(cfg.output, "/") || strings.HasSuffix(cfg.output, string(filepath.Separator))
+	if info, err := os.Stat(cfg.output); err == nil && info.IsDir() {
+		toDir = true
+	}
+	if toDir {
+		if err := os.MkdirAll(cfg.output, 0755); err != nil {
+			return err
+		}
+	}
+
+	var opts []imaging.EncodeOption
+	if cfg.quality > 0 {
+		opts = append(opts, imaging.JPEGQuality(cfg.quality))
+	}
+	failed := 0
+	for _, input := range inputs {
+		output := cfg.output
+		if toDir {
+			output = filepath.Join(cfg.output, outputName(input, cfg.format))
+		}
+		if err := process(input, output, steps, opts); err != nil {
+			fmt.Fprintf(stderr, "imaging: %s: %v\n", input, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to process %d of %d files", failed, len(inputs))
+	}
+	return nil
+}
+
+// parseArgs parses the operations with their flags, the output flags and the input files.
+func parseArgs(args []string, stderr io.Writer) ([]step, []string, outputConfig, error) {
+	var cfg outputConfig
+	var steps []step
+	var files []string
+	for len(args) > 0 {
+		name := args[0]
+		op, ok := operations[name]
+		if !ok {
+			return nil, nil, cfg, fmt.Errorf("unknown operation %q, the operations are: %s", name, operationNames())
+		}
+		fs := flag.NewFlagSet(name, flag.ContinueOnError)
+		fs.SetOutput(stderr)
+		fs.StringVar(&cfg.output, "o", cfg.output, "output file or directory")
+		fs.StringVar(&cfg.format, "format", cfg.format, "output format extension, e.g. png")
+		fs.IntVar(&cfg.quality, "quality", cfg.quality, "JPEG quality")
+		build := op(fs)
+		rest := args[1:]
+		for {
+			if err := fs.Parse(rest); err != nil {
+				return nil, nil, cfg, err
+			}
+			rest = fs.Args()
+			// Collect the files up to the next flag or operation.
+			for len(rest) > 0 && rest[0] != "+" && !isFlag(rest[0]) {
+				files = append(files, rest[0])
+				rest = rest[1:]
+			}
+			if len(rest) == 0 || rest[0] == "+" {
+				break
+			}
+		}
+		s, err := build()
+		if err != nil {
+			return nil, nil, cfg, err
+		}
+		steps = append(steps, s)
+		if len(rest) > 0 {
+			rest = rest[1:]
+			if len(rest) == 0 {
+				return nil, nil, cfg, errors.New("missing operation after +")
+			}
+		}
+		args = rest
+	}
+	if len(steps) == 0 {
+		return nil, nil, cfg, fmt.Errorf("no operation, the operations are: %s", operationNames())
+	}
+	if len(files) == 0 {
+		return nil, nil, cfg, errors.New("no input files")
+	}
+	if cfg.output == "" {
+		return nil, nil, cfg, errors.New("no output, use the -o flag")
+	}
+	if cfg.format != "" {
+		if _, err := imaging.FormatFromExtension(cfg.format); err != nil {
+			return nil, nil, cfg, fmt.Errorf("unsupported format %q", cfg.format)
+		}
+	}
+	return steps, files, cfg, nil
+}
+
+// isFlag reports whether the argument looks like a flag. A single "-" is not a flag.
+func isFlag(arg string) bool {
+	return len(arg) > 1 && arg[0] == '-'
+}
+
+func operationNames() string {
+	names := make([]string, 0, len(operations))
+	for name := range operations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// expandGlobs expands the glob patterns. The names that are not patterns are kept as is.
+func expandGlobs(patterns []string) ([]string, error) {
+	var files []string
+	for _, p := range patterns {
+		matches, err := filepath.Glob(p)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			if _, err := os.Stat(p); err != nil {
+				return nil, fmt.Errorf("no files match %q", p)
+			}
+			matches = []string{p}
+		}
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+// outputName returns the base name of the output file for the input file.
+func outputName(input, format string) string {
+	name := filepath.Base(input)
+	if format != "" {
+		name = strings.TrimSuffix(name, filepath.Ext(name)) + "." + strings.TrimPrefix(format, ".")
+	}
+	return name
+}
+
+// process applies the steps to the input file and saves the result.
+func process(input, output string, steps []step, opts []imaging.EncodeOption) error {
+	img, err := imaging.Open(input, imaging.AutoOrientation(true))
+	if err != nil {
+		return err
+	}
+	for _, s := range steps {
+		img = s(img)
+	}
+	return imaging.Save(img, output, opts...)
+}
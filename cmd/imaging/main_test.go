@@ -0,0 +1,102 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/disintegration/imaging"
+)
+
+func writeTestImages(t *testing.T, dir string, names ...string) {
+	for _, name := range names {
+		img := imaging.New(80, 40, color.NRGBA{200, 100, 50, 255})
+		if err := imaging.Save(img, filepath.Join(dir, name)); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imaging")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	writeTestImages(t, dir, "a.png", "b.png", "c.jpg")
+
+	testCases := []struct {
+		args []string
+		want map[string]image.Point
+	}{
+		{
+			[]string{"resize", "-w", "40", "-filter", "linear", filepath.Join(dir, "*.png"), "-o", filepath.Join(dir, "out1") + "/"},
+			map[string]image.Point{"out1/a.png": {40, 20}, "out1/b.png": {40, 20}},
+		},
+		{
+			[]string{"fit", "-w", "20", "-h", "20", "+", "flip", "-v", "+", "sharpen", filepath.Join(dir, "a.png"), "-o", filepath.Join(dir, "fit.jpg")},
+			map[string]image.Point{"fit.jpg": {20, 10}},
+		},
+		{
+			[]string{"convert", "-format", "png", "-o", filepath.Join(dir, "out2"), filepath.Join(dir, "c.jpg"), filepath.Join(dir, "a.png")},
+			map[string]image.Point{"out2/c.png": {80, 40}, "out2/a.png": {80, 40}},
+		},
+		{
+			[]string{"rotate", "-angle", "90", "+", "crop", "-w", "10", "-h", "30", "-anchor", "top", "+", "adjust", "-gamma", "1.2", "-hue", "10", filepath.Join(dir, "a.png"), "-o", filepath.Join(dir, "rot.png")},
+			map[string]image.Point{"rot.png": {10, 30}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(strings.Join(tc.args[:1], " "), func(t *testing.T) {
+			var stderr strings.Builder
+			if err := run(tc.args, &stderr); err != nil {
+				t.Fatalf("run: %v, stderr: %s", err, stderr.String())
+			}
+			for name, size := range tc.want {
+				img, err := imaging.Open(filepath.Join(dir, name))
+				if err != nil {
+					t.Fatalf("Open: %v", err)
+				}
+				if got := img.Bounds().Size(); got != size {
+					t.Errorf("%s: got size %v want %v", name, got, size)
+				}
+			}
+		})
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imaging")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	writeTestImages(t, dir, "a.png")
+	a := filepath.Join(dir, "a.png")
+	out := filepath.Join(dir, "out.png")
+
+	testCases := [][]string{
+		{},
+		{"explode", a, "-o", out},
+		{"resize", a, "-o", out},
+		{"resize", "-w", "10", a},
+		{"resize", "-w", "10", "-o", out},
+		{"resize", "-w", "10", "-filter", "sinc", a, "-o", out},
+		{"resize", "-w", "10", "+", a, "-o", out},
+		{"resize", "-w", "10", "-bad", a, "-o", out},
+		{"fill", "-w", "10", "-h", "10", "-anchor", "middle", a, "-o", out},
+		{"rotate", "-bg", "red", a, "-o", out},
+		{"convert", "-format", "webp", a, "-o", dir},
+		{"convert", filepath.Join(dir, "*.gif"), "-o", dir},
+		{"convert", a, "-o", filepath.Join(dir, "out.unknown")},
+	}
+	for _, args := range testCases {
+		if err := run(args, ioutil.Discard); err == nil {
+			t.Errorf("run(%q): expected error got nil", args)
+		}
+	}
+}
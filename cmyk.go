@@ -0,0 +1,41 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"strings"
+)
+
+// isCMYKJPEGWithoutAdobe reports whether the error is returned by image/jpeg for a 4-component
+// JPEG image without the Adobe APP14 segment that tells CMYK from YCCK.
+func isCMYKJPEGWithoutAdobe(err error) bool {
+	e, ok := err.(jpeg.UnsupportedError)
+	return ok && strings.Contains(string(e), "Adobe APP14")
+}
+
+// decodeCMYKJPEG decodes the 4-component JPEG image without the Adobe APP14 segment
+// as CMYK, like libjpeg does. Unlike the Adobe images, their values are not inverted.
+func decodeCMYKJPEG(data []byte) (image.Image, error) {
+	if len(data) < 2 {
+		return nil, image.ErrFormat
+	}
+	// Insert the Adobe segment with the transform 0 (CMYK) after the SOI marker.
+	app14 := []byte{0xff, 0xee, 0, 14, 'A', 'd', 'o', 'b', 'e', 0, 0x64, 0, 0, 0, 0, 0}
+	buf := make([]byte, 0, len(data)+len(app14))
+	buf = append(buf, data[:2]...)
+	buf = append(buf, app14...)
+	buf = append(buf, data[2:]...)
+
+	img, err := jpeg.Decode(bytes.NewReader(buf))
+	if err != nil {
+		return nil, err
+	}
+	if cmyk, ok := img.(*image.CMYK); ok {
+		// image/jpeg undoes the Adobe inversion, which these images don't have.
+		for i := range cmyk.Pix {
+			cmyk.Pix[i] = 255 - cmyk.Pix[i]
+		}
+	}
+	return img, nil
+}
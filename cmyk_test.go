@@ -0,0 +1,132 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"testing"
+)
+
+// testCMYK returns a CMYK image with varied colors.
+func testCMYK() *image.CMYK {
+	img := image.NewCMYK(image.Rect(-3, 2, 29, 19))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i*37 + i/7)
+	}
+	return img
+}
+
+// cmykDiff returns the mean absolute difference of the CMYK values of the images.
+func cmykDiff(a, b *image.CMYK) float64 {
+	var sum float64
+	w, h := a.Rect.Dx(), a.Rect.Dy()
+	for y := 0; y < h; y++ {
+		for x := 0; x < w*4; x++ {
+			sum += float64(absint(int(a.Pix[y*a.Stride+x]) - int(b.Pix[y*b.Stride+x])))
+		}
+	}
+	return sum / float64(w*h*4)
+}
+
+func TestCloneCMYK(t *testing.T) {
+	src := testCMYK()
+	// The generic path converts the colors using At.
+	want := Clone(struct{ image.Image }{src})
+	got := Clone(src)
+	if !compareNRGBA(got, want, 0) {
+		t.Fatalf("the CMYK fast path differs from the generic conversion")
+	}
+	sub := src.SubImage(image.Rect(0, 5, 10, 9))
+	if !compareNRGBA(Clone(sub), Clone(struct{ image.Image }{sub}), 0) {
+		t.Fatalf("the CMYK fast path differs for the subimage")
+	}
+
+	invalid := &image.CMYK{Rect: image.Rect(0, 0, 4, 4), Stride: 16, Pix: make([]uint8, 10)}
+	if got := Clone(invalid); !compareNRGBA(got, image.NewNRGBA(image.Rect(0, 0, 4, 4)), 0) {
+		t.Fatalf("got %v for the invalid image", got.Pix)
+	}
+}
+
+func TestJPEGKeepCMYK(t *testing.T) {
+	src := Blur(testdataFlowersSmallPNG, 1)
+	cmyk := image.NewCMYK(src.Rect)
+	for i := 0; i < len(src.Pix); i += 4 {
+		c, m, y := 255-src.Pix[i], 255-src.Pix[i+1], 255-src.Pix[i+2]
+		k := min(c, m, y)
+		copy(cmyk.Pix[i:], []uint8{c - k, m - k, y - k, k})
+	}
+
+	data, err := EncodeBytes(cmyk, JPEG, JPEGKeepCMYK(true), JPEGQuality(95))
+	if err != nil {
+		t.Fatalf("EncodeBytes: %v", err)
+	}
+	img, err := DecodeBytes(data)
+	if err != nil {
+		t.Fatalf("DecodeBytes: %v", err)
+	}
+	got, ok := img.(*image.CMYK)
+	if !ok {
+		t.Fatalf("got decoded image %T want *image.CMYK", img)
+	}
+	if d := cmykDiff(got, cmyk); d > 2 {
+		t.Fatalf("got mean difference %.2f", d)
+	}
+
+	// By default the image is converted to RGB.
+	data, err = EncodeBytes(cmyk, JPEG)
+	if err != nil {
+		t.Fatalf("EncodeBytes: %v", err)
+	}
+	if img, err := DecodeBytes(data); err != nil {
+		t.Fatalf("DecodeBytes: %v", err)
+	} else if _, ok := img.(*image.CMYK); ok {
+		t.Fatalf("got a CMYK image without JPEGKeepCMYK")
+	}
+}
+
+func TestDecodeCMYKJPEGWithoutAdobe(t *testing.T) {
+	src := testCMYK()
+	want := image.NewCMYK(image.Rect(0, 0, src.Rect.Dx(), src.Rect.Dy()))
+	for i := range want.Pix {
+		want.Pix[i] = 0x40 + uint8(i%4)*0x20
+	}
+
+	// Encoding the inverted values and removing the Adobe segment gives a plain CMYK JPEG.
+	inverted := image.NewCMYK(want.Rect)
+	for i, v := range want.Pix {
+		inverted.Pix[i] = 255 - v
+	}
+	data, err := EncodeBytes(inverted, JPEG, JPEGKeepCMYK(true), JPEGQuality(100))
+	if err != nil {
+		t.Fatalf("EncodeBytes: %v", err)
+	}
+	i := bytes.Index(data, []byte{0xff, 0xee})
+	if i < 0 {
+		t.Fatalf("the Adobe segment not found")
+	}
+	data = append(data[:i:i], data[i+16:]...)
+
+	if _, _, err := image.Decode(bytes.NewReader(data)); err == nil {
+		t.Fatalf("expected image/jpeg to reject the image")
+	}
+	for _, decode := range []func([]byte) (image.Image, error){
+		func(data []byte) (image.Image, error) { return DecodeBytes(data) },
+		func(data []byte) (image.Image, error) { return Decode(struct{ *bytes.Reader }{bytes.NewReader(data)}) },
+	} {
+		img, err := decode(data)
+		if err != nil {
+			t.Fatalf("Decode: %v", err)
+		}
+		got, ok := img.(*image.CMYK)
+		if !ok {
+			t.Fatalf("got decoded image %T want *image.CMYK", img)
+		}
+		if d := cmykDiff(got, want); d > 1 {
+			t.Fatalf("got mean difference %.2f", d)
+		}
+	}
+	// Transcode decodes the image the same way.
+	var buf bytes.Buffer
+	if err := Transcode(bytes.NewReader(data), &buf, &TranscodeOptions{Format: PNG}); err != nil {
+		t.Fatalf("Transcode: %v", err)
+	}
+}
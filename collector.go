@@ -0,0 +1,92 @@
+package imaging
+
+import (
+	"image"
+	"sync"
+)
+
+// Collector observes the pixels read by the image processing functions, so that image
+// statistics can be computed during another operation without an extra pass over the image.
+// Collectors are attached to an image using WithCollector.
+type Collector interface {
+	// Collect is called with the pixels in the non-premultiplied RGBA format, 4 bytes per pixel.
+	// It may be called concurrently from multiple goroutines and must not retain pix.
+	Collect(pix []uint8)
+}
+
+// collectingImage is an image with attached collectors.
+type collectingImage struct {
+	image.Image
+	collectors []Collector
+}
+
+// WithCollector returns an image that has the same pixels as img and passes every pixel
+// read by the functions of this package to the collectors. Each pixel is observed as many
+// times as it's read: most functions, such as Resize, Blur, Clone, Crop or AdjustFunc, read
+// each pixel of the source once, but the functions that read only some of the pixels (e.g.
+// Resize with the NearestNeighbor filter) give statistics of the read pixels only.
+// Reading the pixels with the At method of the returned image is not observed.
+//
+// Example:
+//
+//	hc := &imaging.HistogramCollector{}
+//	thumb := imaging.Resize(imaging.WithCollector(img, hc), 200, 0, imaging.Lanczos)
+//	histogram := hc.Histogram() // Histogram of img, computed during the resize.
+//
+func WithCollector(img image.Image, collectors ...Collector) image.Image {
+	return &collectingImage{Image: img, collectors: collectors}
+}
+
+// HistogramCollector is a Collector that computes the luminance histogram of the pixels.
+// The zero value is ready to use.
+type HistogramCollector struct {
+	mu     sync.Mutex
+	counts [256]uint64
+	total  uint64
+}
+
+// Collect adds the pixels to the histogram.
+func (h *HistogramCollector) Collect(pix []uint8) {
+	var counts [256]uint64
+	for i := 0; i+3 < len(pix); i += 4 {
+		s := pix[i : i+3 : i+3]
+		y := 0.299*float32(s[0]) + 0.587*float32(s[1]) + 0.114*float32(s[2])
+		counts[int(y+0.5)]++
+	}
+	h.mu.Lock()
+	for i, c := range counts {
+		h.counts[i] += c
+	}
+	h.total += uint64(len(pix) / 4)
+	h.mu.Unlock()
+}
+
+// Count returns the number of the collected pixels.
+func (h *HistogramCollector) Count() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.total
+}
+
+// Histogram returns the normalized histogram of the collected pixels in the same form
+// as the Histogram function.
+func (h *HistogramCollector) Histogram() [256]float64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var histogram [256]float64
+	if h.total == 0 {
+		return histogram
+	}
+	for i, c := range h.counts {
+		histogram[i] = float64(c) / float64(h.total)
+	}
+	return histogram
+}
+
+// Reset clears the collected data.
+func (h *HistogramCollector) Reset() {
+	h.mu.Lock()
+	h.counts = [256]uint64{}
+	h.total = 0
+	h.mu.Unlock()
+}
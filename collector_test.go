@@ -0,0 +1,56 @@
+package imaging
+
+import (
+	"image"
+	"testing"
+)
+
+func TestHistogramCollector(t *testing.T) {
+	src := testdataBranchesPNG
+	want := Histogram(src)
+	n := uint64(src.Bounds().Dx() * src.Bounds().Dy())
+
+	testCases := []struct {
+		name string
+		fn   func(img image.Image) *image.NRGBA
+	}{
+		{"Resize", func(img image.Image) *image.NRGBA { return Resize(img, 100, 0, Lanczos) }},
+		{"Resize vertical", func(img image.Image) *image.NRGBA { return Resize(img, img.Bounds().Dx(), 100, Linear) }},
+		{"Blur", func(img image.Image) *image.NRGBA { return Blur(img, 2) }},
+		{"Clone", Clone},
+		{"Grayscale", Grayscale},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			hc := &HistogramCollector{}
+			got := tc.fn(WithCollector(src, hc))
+			if !compareNRGBA(got, tc.fn(src), 0) {
+				t.Fatalf("result with a collector differs from the result without it")
+			}
+			if hc.Count() != n {
+				t.Fatalf("got %d collected pixels want %d", hc.Count(), n)
+			}
+			if hc.Histogram() != want {
+				t.Fatalf("collected histogram differs from Histogram")
+			}
+		})
+	}
+}
+
+func TestWithCollectorNested(t *testing.T) {
+	hc1 := &HistogramCollector{}
+	hc2 := &HistogramCollector{}
+	img := WithCollector(WithCollector(testdataFlowersSmallPNG, hc1), hc2)
+	if img.Bounds() != testdataFlowersSmallPNG.Bounds() {
+		t.Fatalf("got bounds %v want %v", img.Bounds(), testdataFlowersSmallPNG.Bounds())
+	}
+	Crop(img, image.Rect(0, 0, 10, 10))
+	if hc1.Count() != 100 || hc2.Count() != 100 {
+		t.Fatalf("got collected pixels %d, %d want 100", hc1.Count(), hc2.Count())
+	}
+
+	hc1.Reset()
+	if hc1.Count() != 0 || hc1.Histogram() != [256]float64{} {
+		t.Fatalf("collector is not reset")
+	}
+}
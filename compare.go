@@ -0,0 +1,265 @@
+package imaging
+
+import (
+	"image"
+	"math"
+)
+
+// DiffSummary describes the differences between two images found by AlmostEqual.
+type DiffSummary struct {
+	// SizeMismatch is true if the images have different sizes. The other fields
+	// are zero in this case.
+	SizeMismatch bool
+	// MaxDelta is the largest difference of a channel value.
+	MaxDelta int
+	// MeanDelta is the mean difference of the channel values.
+	MeanDelta float64
+	// DiffPixels is the number of pixels with a channel differing by more than
+	// the allowed delta.
+	DiffPixels int
+	// FirstDiff is the first such pixel in the row order, in the coordinates of the first
+	// image. It is only meaningful if DiffPixels is positive.
+	FirstDiff image.Point
+}
+
+// Equal reports whether the images have the same size and the same pixels. The images
+// of any type are compared as 8-bit non-premultiplied RGBA, the same way the functions
+// of this package read them, and the positions of the pixels are relative to the bounds,
+// so a subimage equals its copy returned by Clone. All the fully transparent pixels
+// are equal regardless of their color channels.
+//
+// Example:
+//
+//	if !imaging.Equal(got, want) {
+//		t.Fatalf("the images differ")
+//	}
+//
+func Equal(a, b image.Image) bool {
+	ok, _ := AlmostEqual(a, b, 0)
+	return ok
+}
+
+// AlmostEqual is like Equal but allows the channel values to differ by up to maxDelta.
+// It also returns a summary of the differences, which is useful in the test failure messages.
+//
+// Example:
+//
+//	if ok, diff := imaging.AlmostEqual(got, want, 1); !ok {
+//		t.Fatalf("%d pixels differ, the first at %v", diff.DiffPixels, diff.FirstDiff)
+//	}
+//
+func AlmostEqual(a, b image.Image, maxDelta int) (bool, DiffSummary) {
+	ba, bb := a.Bounds(), b.Bounds()
+	if ba.Dx() != bb.Dx() || ba.Dy() != bb.Dy() {
+		return false, DiffSummary{SizeMismatch: true}
+	}
+	w, h := ba.Dx(), ba.Dy()
+	if w <= 0 || h <= 0 {
+		return true, DiffSummary{}
+	}
+
+	type rowDiff struct {
+		maxDelta, count, first, sum int
+	}
+	rows := make([]rowDiff, h)
+	sa, sb := newScanner(a), newScanner(b)
+	parallel(0, h, w*costPoint, func(ys <-chan int) {
+		lineA := make([]uint8, w*4)
+		lineB := make([]uint8, w*4)
+		for y := range ys {
+			sa.scan(0, y, w, y+1, lineA)
+			sb.scan(0, y, w, y+1, lineB)
+			r := rowDiff{first: -1}
+			for x := 0; x < w; x++ {
+				pa, pb := lineA[x*4:x*4+4:x*4+4], lineB[x*4:x*4+4:x*4+4]
+				if pa[3] == 0 && pb[3] == 0 {
+					continue
+				}
+				pixelDelta := 0
+				for c := 0; c < 4; c++ {
+					d := absint(int(pa[c]) - int(pb[c]))
+					pixelDelta = max(pixelDelta, d)
+					r.sum += d
+				}
+				r.maxDelta = max(r.maxDelta, pixelDelta)
+				if pixelDelta > maxDelta {
+					if r.count == 0 {
+						r.first = x
+					}
+					r.count++
+				}
+			}
+			rows[y] = r
+		}
+	})
+
+	var summary DiffSummary
+	sum := 0
+	for y, r := range rows {
+		summary.MaxDelta = max(summary.MaxDelta, r.maxDelta)
+		sum += r.sum
+		if r.count > 0 && summary.DiffPixels == 0 {
+			summary.FirstDiff = image.Pt(ba.Min.X+r.first, ba.Min.Y+y)
+		}
+		summary.DiffPixels += r.count
+	}
+	summary.MeanDelta = float64(sum) / float64(w*h*4)
+	return summary.DiffPixels == 0, summary
+}
+
+// CompareMSE returns the mean squared error of the RGB channel values of the images composited
+// over black, in the range from 0 for the identical images to 65025. The images must have
+// the same size, otherwise it returns +Inf.
+//
+// Example:
+//
+//	mse := imaging.CompareMSE(original, compressed)
+//
+func CompareMSE(img1, img2 image.Image) float64 {
+	b1, b2 := img1.Bounds(), img2.Bounds()
+	if b1.Dx() != b2.Dx() || b1.Dy() != b2.Dy() {
+		return math.Inf(1)
+	}
+	w, h := b1.Dx(), b1.Dy()
+	if w <= 0 || h <= 0 {
+		return 0
+	}
+
+	rows := make([]float64, h)
+	s1, s2 := newScanner(img1), newScanner(img2)
+	parallel(0, h, w*costPoint, func(ys <-chan int) {
+		line1 := make([]uint8, w*4)
+		line2 := make([]uint8, w*4)
+		for y := range ys {
+			s1.scan(0, y, w, y+1, line1)
+			s2.scan(0, y, w, y+1, line2)
+			var sum float64
+			for i := 0; i < w*4; i += 4 {
+				a1, a2 := float64(line1[i+3])/255, float64(line2[i+3])/255
+				for c := 0; c < 3; c++ {
+					d := float64(line1[i+c])*a1 - float64(line2[i+c])*a2
+					sum += d * d
+				}
+			}
+			rows[y] = sum
+		}
+	})
+	var sum float64
+	for _, v := range rows {
+		sum += v
+	}
+	return sum / float64(w*h*3)
+}
+
+// ComparePSNR returns the peak signal-to-noise ratio of the images in decibels, computed from
+// CompareMSE. Higher values mean more similar images; the identical images give +Inf, and
+// the lossy compression usually gives 30 to 50 dB. It returns 0 if the sizes of the images differ.
+//
+// Example:
+//
+//	if imaging.ComparePSNR(original, compressed) < 40 {
+//		log.Print("the quality is too low")
+//	}
+//
+func ComparePSNR(img1, img2 image.Image) float64 {
+	mse := CompareMSE(img1, img2)
+	if math.IsInf(mse, 1) {
+		return 0
+	}
+	return 10 * math.Log10(255*255/mse)
+}
+
+// CompareSSIM returns the mean structural similarity index of the luminance of the images
+// composited over black (Wang et al., with an 11x11 Gaussian window of sigma 1.5). Unlike
+// CompareMSE and ComparePSNR it compares the local structure, so it agrees better with
+// the perceived quality: the blur and the blocking artifacts lower it more than the small
+// changes of the brightness. It returns 1 for the identical images and lower values, down to -1,
+// for the less similar ones. It returns 0 if the sizes of the images differ.
+//
+// Example:
+//
+//	if imaging.CompareSSIM(want, got) < 0.98 {
+//		t.Fatalf("the images differ")
+//	}
+//
+func CompareSSIM(img1, img2 image.Image) float64 {
+	b1, b2 := img1.Bounds(), img2.Bounds()
+	if b1.Dx() != b2.Dx() || b1.Dy() != b2.Dy() {
+		return 0
+	}
+	w, h := b1.Dx(), b1.Dy()
+	if w <= 0 || h <= 0 {
+		return 1
+	}
+
+	x, y := ssimLuminance(img1), ssimLuminance(img2)
+	xx := make([]float64, w*h)
+	yy := make([]float64, w*h)
+	xy := make([]float64, w*h)
+	for i := range x {
+		xx[i], yy[i], xy[i] = x[i]*x[i], y[i]*y[i], x[i]*y[i]
+	}
+	const sigma = 1.5
+	muX, muY := ssimFilter(x, w, h, sigma), ssimFilter(y, w, h, sigma)
+	xx, yy, xy = ssimFilter(xx, w, h, sigma), ssimFilter(yy, w, h, sigma), ssimFilter(xy, w, h, sigma)
+
+	const c1, c2 = (0.01 * 255) * (0.01 * 255), (0.03 * 255) * (0.03 * 255)
+	var sum float64
+	for i := range muX {
+		mx, my := muX[i], muY[i]
+		vx, vy, cov := xx[i]-mx*mx, yy[i]-my*my, xy[i]-mx*my
+		sum += (2*mx*my + c1) * (2*cov + c2) / ((mx*mx + my*my + c1) * (vx + vy + c2))
+	}
+	return sum / float64(w*h)
+}
+
+// ssimLuminance returns the luminance of the image composited over black in range [0, 255].
+func ssimLuminance(img image.Image) []float64 {
+	s := newScanner(img)
+	lum := make([]float64, s.w*s.h)
+	parallel(0, s.h, s.w*costPoint, func(ys <-chan int) {
+		line := make([]uint8, s.w*4)
+		for y := range ys {
+			s.scan(0, y, s.w, y+1, line)
+			for x := 0; x < s.w; x++ {
+				p := line[x*4 : x*4+4 : x*4+4]
+				lum[y*s.w+x] = (0.299*float64(p[0]) + 0.587*float64(p[1]) + 0.114*float64(p[2])) * float64(p[3]) / 255
+			}
+		}
+	})
+	return lum
+}
+
+// ssimFilter returns the plane filtered by the Gaussian window of SSIM truncated at 3.5 sigma
+// (the 11x11 window for sigma 1.5). The window is renormalized at the plane edges.
+func ssimFilter(p []float64, w, h int, sigma float64) []float64 {
+	radius := int(3.5 * sigma)
+	kernel := make([]float64, radius+1)
+	for i := range kernel {
+		kernel[i] = gaussianBlurKernel(float64(i), sigma)
+	}
+	filter := func(dst, src []float64, n, step int) {
+		for i := 0; i < n; i++ {
+			var sum, wsum float64
+			for j := max(i-radius, 0); j <= min(i+radius, n-1); j++ {
+				k := kernel[absint(i-j)]
+				sum += src[j*step] * k
+				wsum += k
+			}
+			dst[i*step] = sum / wsum
+		}
+	}
+	tmp := make([]float64, w*h)
+	parallel(0, h, w*(2*radius+1)*costTap, func(ys <-chan int) {
+		for y := range ys {
+			filter(tmp[y*w:], p[y*w:], w, 1)
+		}
+	})
+	dst := make([]float64, w*h)
+	parallel(0, w, h*(2*radius+1)*costTap, func(xs <-chan int) {
+		for x := range xs {
+			filter(dst[x:], tmp[x:], h, w)
+		}
+	})
+	return dst
+}
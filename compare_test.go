@@ -0,0 +1,105 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestEqual(t *testing.T) {
+	src := Clone(testdataFlowersSmallPNG)
+	canvas := Paste(image.NewNRGBA(image.Rect(0, 0, 260, 180)), src, image.Pt(10, 10))
+	testCases := []struct {
+		name string
+		a, b image.Image
+		want bool
+	}{
+		{"same", src, Clone(src), true},
+		{"different type", src, Clone16(src), true},
+		{"subimage", canvas.SubImage(image.Rect(10, 10, 250, 170)), src, true},
+		{"different size", src, Crop(src, image.Rect(0, 0, 100, 100)), false},
+		{"different pixels", src, Blur(src, 1), false},
+		{"transparent", New(2, 2, color.NRGBA{255, 0, 0, 0}), New(2, 2, color.NRGBA{0, 0, 255, 0}), true},
+		{"empty", &image.NRGBA{}, &image.Gray{}, true},
+	}
+	for _, tc := range testCases {
+		if got := Equal(tc.a, tc.b); got != tc.want {
+			t.Fatalf("%s: got %v want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestAlmostEqual(t *testing.T) {
+	a := New(4, 3, color.NRGBA{100, 100, 100, 255})
+	b := Clone(a)
+	b.SetNRGBA(2, 1, color.NRGBA{102, 100, 100, 255})
+	b.SetNRGBA(1, 2, color.NRGBA{100, 95, 100, 255})
+
+	ok, diff := AlmostEqual(a, b, 2)
+	want := DiffSummary{MaxDelta: 5, MeanDelta: 7.0 / 48, DiffPixels: 1, FirstDiff: image.Pt(1, 2)}
+	if ok || diff != want {
+		t.Fatalf("got %v, %+v want %+v", ok, diff, want)
+	}
+	if ok, diff := AlmostEqual(a, b, 5); !ok || diff.DiffPixels != 0 || diff.MaxDelta != 5 {
+		t.Fatalf("got %v, %+v", ok, diff)
+	}
+	ok, diff = AlmostEqual(a.SubImage(image.Rect(1, 1, 4, 3)), b.SubImage(image.Rect(1, 1, 4, 3)), 0)
+	if ok || diff.DiffPixels != 2 || diff.FirstDiff != image.Pt(2, 1) {
+		t.Fatalf("got %v, %+v", ok, diff)
+	}
+	if ok, diff := AlmostEqual(a, New(3, 4, color.Black), 255); ok || !diff.SizeMismatch {
+		t.Fatalf("got %v, %+v", ok, diff)
+	}
+}
+
+func TestCompareMSE(t *testing.T) {
+	a := New(4, 3, color.NRGBA{100, 100, 100, 255})
+	b := New(4, 3, color.NRGBA{110, 90, 100, 255})
+	if got := CompareMSE(a, b); got != 200.0/3 {
+		t.Fatalf("got MSE %v", got)
+	}
+	if got, want := ComparePSNR(a, b), 10*math.Log10(255*255*3/200.0); math.Abs(got-want) > 1e-9 {
+		t.Fatalf("got PSNR %v want %v", got, want)
+	}
+	if got := CompareMSE(a, Clone16(a)); got != 0 {
+		t.Fatalf("got MSE %v for the same pixels", got)
+	}
+	if got := ComparePSNR(a, a); !math.IsInf(got, 1) {
+		t.Fatalf("got PSNR %v for the same image", got)
+	}
+	// The images are composited over black.
+	c, d := New(2, 2, color.NRGBA{255, 0, 0, 0}), New(2, 2, color.NRGBA{0, 0, 255, 0})
+	if got := CompareMSE(c, d); got != 0 {
+		t.Fatalf("got MSE %v for the transparent images", got)
+	}
+	if got := CompareMSE(a, New(3, 4, color.Black)); !math.IsInf(got, 1) {
+		t.Fatalf("got MSE %v for the different sizes", got)
+	}
+	if got := ComparePSNR(a, New(3, 4, color.Black)); got != 0 {
+		t.Fatalf("got PSNR %v for the different sizes", got)
+	}
+}
+
+func TestCompareSSIM(t *testing.T) {
+	src := testdataFlowersSmallPNG
+	if got := CompareSSIM(src, Clone(src)); math.Abs(got-1) > 1e-9 {
+		t.Fatalf("got SSIM %v for the same image", got)
+	}
+
+	blur1, blur3 := CompareSSIM(src, Blur(src, 1)), CompareSSIM(src, Blur(src, 3))
+	if !(blur1 < 1 && blur3 < blur1 && blur3 > 0) {
+		t.Fatalf("got SSIM %v and %v for the blurred images", blur1, blur3)
+	}
+	// A small brightness change affects the structure less than the blur with a similar MSE.
+	brighter := AdjustBrightness(src, 5)
+	if CompareMSE(src, brighter) < CompareMSE(src, Blur(src, 1)) && CompareSSIM(src, brighter) < blur1 {
+		t.Fatalf("got SSIM %v for the brighter image", CompareSSIM(src, brighter))
+	}
+	if got := CompareSSIM(src, Invert(src)); got >= 0 {
+		t.Fatalf("got SSIM %v for the inverted image", got)
+	}
+	if got := CompareSSIM(src, Crop(src, image.Rect(0, 0, 10, 10))); got != 0 {
+		t.Fatalf("got SSIM %v for the different sizes", got)
+	}
+}
@@ -4,6 +4,27 @@ import (
 	"image"
 )
 
+// EdgeMode specifies how the pixels outside the image bounds are sampled during convolution.
+type EdgeMode int
+
+// Edge modes.
+const (
+	// EdgeClamp repeats the nearest edge pixel.
+	EdgeClamp EdgeMode = iota
+
+	// EdgeWrap wraps around to the opposite edge of the image.
+	EdgeWrap
+
+	// EdgeMirror reflects the image at the edge, without repeating the edge pixel.
+	EdgeMirror
+
+	// EdgeTransparent samples transparent pixels outside the image, the same way
+	// the transforms such as Rotate do with a transparent background. In the convolution
+	// the pixels outside are transparent black: use it with the Alpha option to fade
+	// the edges, otherwise the colors near the edges are darkened.
+	EdgeTransparent
+)
+
 // ConvolveOptions are convolution parameters.
 type ConvolveOptions struct {
 	// If Normalize is true the kernel is normalized before convolution.
@@ -13,22 +34,69 @@ type ConvolveOptions struct {
 	Abs bool
 
 	// Bias is added to each color channel value after convolution.
+	// It's not added to the alpha channel.
 	Bias int
+
+	// If Alpha is true the alpha channel is convolved as well and the colors are weighted
+	// by the alpha, the same way Blur does, so the colors of the transparent pixels don't
+	// bleed into the visible ones. Otherwise the alpha is copied from the source image.
+	Alpha bool
+
+	// Edge specifies how the pixels outside the image bounds are sampled.
+	Edge EdgeMode
 }
 
 // Convolve3x3 convolves the image with the specified 3x3 convolution kernel.
 // Default parameters are used if a nil *ConvolveOptions is passed.
 func Convolve3x3(img image.Image, kernel [9]float64, options *ConvolveOptions) *image.NRGBA {
-	return convolve(img, kernel[:], options)
+	return convolve(img, kernel[:], 3, 3, options)
 }
 
 // Convolve5x5 convolves the image with the specified 5x5 convolution kernel.
 // Default parameters are used if a nil *ConvolveOptions is passed.
 func Convolve5x5(img image.Image, kernel [25]float64, options *ConvolveOptions) *image.NRGBA {
-	return convolve(img, kernel[:], options)
+	return convolve(img, kernel[:], 5, 5, options)
+}
+
+// ConvolveKernel convolves the image with the specified convolution kernel given as a slice
+// of rows. All rows must have the same length. The kernel is centered at the element
+// kernel[len(kernel)/2][len(kernel[0])/2], so odd sizes are usually used.
+// If the kernel is empty or its rows have different lengths, a copy of the image is returned.
+// Default parameters are used if a nil *ConvolveOptions is passed.
+//
+// Example:
+//
+//	// Horizontal motion blur with wrapping around the edges.
+//	dstImage := imaging.ConvolveKernel(srcImage, [][]float64{
+//		{1, 1, 1, 1, 1, 1, 1},
+//	}, &imaging.ConvolveOptions{Normalize: true, Edge: imaging.EdgeWrap})
+//
+func ConvolveKernel(img image.Image, kernel [][]float64, options *ConvolveOptions) *image.NRGBA {
+	k, kw, kh, ok := flattenKernel(kernel)
+	if !ok {
+		return Clone(img)
+	}
+	return convolve(img, k, kw, kh, options)
+}
+
+// flattenKernel returns a copy of the kernel in row-major order and its size.
+// It returns false if the kernel is empty or its rows have different lengths.
+func flattenKernel(kernel [][]float64) ([]float64, int, int, bool) {
+	if len(kernel) == 0 || len(kernel[0]) == 0 {
+		return nil, 0, 0, false
+	}
+	kw, kh := len(kernel[0]), len(kernel)
+	k := make([]float64, 0, kw*kh)
+	for _, row := range kernel {
+		if len(row) != kw {
+			return nil, 0, 0, false
+		}
+		k = append(k, row...)
+	}
+	return k, kw, kh, true
 }
 
-func convolve(img image.Image, kernel []float64, options *ConvolveOptions) *image.NRGBA {
+func convolve(img image.Image, kernel []float64, kw, kh int, options *ConvolveOptions) *image.NRGBA {
 	src := toNRGBA(img)
 	w := src.Bounds().Max.X
 	h := src.Bounds().Max.Y
@@ -51,18 +119,10 @@ func convolve(img image.Image, kernel []float64, options *ConvolveOptions) *imag
 		k    float64
 	}
 	var coefs []coef
-	var m int
-
-	switch len(kernel) {
-	case 9:
-		m = 1
-	case 25:
-		m = 2
-	}
 
 	i := 0
-	for y := -m; y <= m; y++ {
-		for x := -m; x <= m; x++ {
+	for y := -kh / 2; y < kh-kh/2; y++ {
+		for x := -kw / 2; x < kw-kw/2; x++ {
 			if kernel[i] != 0 {
 				coefs = append(coefs, coef{x: x, y: y, k: kernel[i]})
 			}
@@ -70,30 +130,42 @@ func convolve(img image.Image, kernel []float64, options *ConvolveOptions) *imag
 		}
 	}
 
-	parallel(0, h, func(ys <-chan int) {
+	parallel(0, h, w*len(coefs)*costTap, func(ys <-chan int) {
 		for y := range ys {
 			for x := 0; x < w; x++ {
-				var r, g, b float64
+				var r, g, b, a float64
 				for _, c := range coefs {
-					ix := x + c.x
-					if ix < 0 {
-						ix = 0
-					} else if ix >= w {
-						ix = w - 1
+					ix, iy := x+c.x, y+c.y
+					if options.Edge == EdgeTransparent && (ix < 0 || ix >= w || iy < 0 || iy >= h) {
+						continue
 					}
+					ix = edgeIndex(ix, w, options.Edge)
+					iy = edgeIndex(iy, h, options.Edge)
 
-					iy := y + c.y
-					if iy < 0 {
-						iy = 0
-					} else if iy >= h {
-						iy = h - 1
+					off := iy*src.Stride + ix*4
+					s := src.Pix[off : off+4 : off+4]
+					k := c.k
+					if options.Alpha {
+						k *= float64(s[3]) / 255
 					}
+					r += float64(s[0]) * k
+					g += float64(s[1]) * k
+					b += float64(s[2]) * k
+					a += float64(s[3]) * c.k
+				}
 
-					off := iy*src.Stride + ix*4
-					s := src.Pix[off : off+3 : off+3]
-					r += float64(s[0]) * c.k
-					g += float64(s[1]) * c.k
-					b += float64(s[2]) * c.k
+				if options.Alpha {
+					if options.Abs && a < 0 {
+						a = -a
+					}
+					if a > 0 {
+						// Divide the alpha-weighted colors by the convolved alpha.
+						r *= 255 / a
+						g *= 255 / a
+						b *= 255 / a
+					} else {
+						r, g, b = 0, 0, 0
+					}
 				}
 
 				if options.Abs {
@@ -120,7 +192,11 @@ func convolve(img image.Image, kernel []float64, options *ConvolveOptions) *imag
 				d[0] = clamp(r)
 				d[1] = clamp(g)
 				d[2] = clamp(b)
-				d[3] = src.Pix[srcOff+3]
+				if options.Alpha {
+					d[3] = clamp(a)
+				} else {
+					d[3] = src.Pix[srcOff+3]
+				}
 			}
 		}
 	})
@@ -128,6 +204,39 @@ func convolve(img image.Image, kernel []float64, options *ConvolveOptions) *imag
 	return dst
 }
 
+// edgeIndex maps the coordinate i to the range [0, n) using the given edge mode.
+func edgeIndex(i, n int, mode EdgeMode) int {
+	if i >= 0 && i < n {
+		return i
+	}
+	switch mode {
+	case EdgeWrap:
+		i %= n
+		if i < 0 {
+			i += n
+		}
+		return i
+	case EdgeMirror:
+		if n == 1 {
+			return 0
+		}
+		period := 2 * (n - 1)
+		i %= period
+		if i < 0 {
+			i += period
+		}
+		if i >= n {
+			i = period - i
+		}
+		return i
+	default:
+		if i < 0 {
+			return 0
+		}
+		return n - 1
+	}
+}
+
 func normalizeKernel(kernel []float64) {
 	var sum, sumpos float64
 	for i := range kernel {
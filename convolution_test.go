@@ -234,6 +234,160 @@ func TestConvolve5x5(t *testing.T) {
 	}
 }
 
+func TestConvolveKernel(t *testing.T) {
+	row := func(pix ...uint8) *image.NRGBA {
+		return &image.NRGBA{Rect: image.Rect(0, 0, len(pix)/4, 1), Stride: len(pix), Pix: pix}
+	}
+	src := row(
+		0x10, 0x11, 0x12, 0xff, 0x20, 0x21, 0x22, 0x80,
+		0x30, 0x31, 0x32, 0x40, 0x40, 0x41, 0x42, 0x20,
+	)
+	testCases := []struct {
+		name    string
+		kernel  [][]float64
+		options *ConvolveOptions
+		want    *image.NRGBA
+	}{
+		{
+			"ConvolveKernel shift right, clamp",
+			[][]float64{{0, 0, 1}},
+			nil,
+			row(
+				0x20, 0x21, 0x22, 0xff, 0x30, 0x31, 0x32, 0x80,
+				0x40, 0x41, 0x42, 0x40, 0x40, 0x41, 0x42, 0x20,
+			),
+		},
+		{
+			"ConvolveKernel shift right, wrap",
+			[][]float64{{0, 0, 1}},
+			&ConvolveOptions{Edge: EdgeWrap},
+			row(
+				0x20, 0x21, 0x22, 0xff, 0x30, 0x31, 0x32, 0x80,
+				0x40, 0x41, 0x42, 0x40, 0x10, 0x11, 0x12, 0x20,
+			),
+		},
+		{
+			"ConvolveKernel shift right, mirror",
+			[][]float64{{0, 0, 1}},
+			&ConvolveOptions{Edge: EdgeMirror},
+			row(
+				0x20, 0x21, 0x22, 0xff, 0x30, 0x31, 0x32, 0x80,
+				0x40, 0x41, 0x42, 0x40, 0x30, 0x31, 0x32, 0x20,
+			),
+		},
+		{
+			"ConvolveKernel shift left by 2, mirror",
+			[][]float64{{1, 0, 0, 0, 0}},
+			&ConvolveOptions{Edge: EdgeMirror},
+			row(
+				0x30, 0x31, 0x32, 0xff, 0x20, 0x21, 0x22, 0x80,
+				0x10, 0x11, 0x12, 0x40, 0x20, 0x21, 0x22, 0x20,
+			),
+		},
+		{
+			"ConvolveKernel shift left by 2, wrap",
+			[][]float64{{1, 0, 0, 0, 0}},
+			&ConvolveOptions{Edge: EdgeWrap},
+			row(
+				0x30, 0x31, 0x32, 0xff, 0x40, 0x41, 0x42, 0x80,
+				0x10, 0x11, 0x12, 0x40, 0x20, 0x21, 0x22, 0x20,
+			),
+		},
+		{
+			"ConvolveKernel shift right, transparent",
+			[][]float64{{0, 0, 1}},
+			&ConvolveOptions{Edge: EdgeTransparent, Alpha: true},
+			row(
+				0x20, 0x21, 0x22, 0x80, 0x30, 0x31, 0x32, 0x40,
+				0x40, 0x41, 0x42, 0x20, 0x00, 0x00, 0x00, 0x00,
+			),
+		},
+		{
+			"ConvolveKernel shift right, alpha",
+			[][]float64{{0, 0, 1}},
+			&ConvolveOptions{Alpha: true},
+			row(
+				0x20, 0x21, 0x22, 0x80, 0x30, 0x31, 0x32, 0x40,
+				0x40, 0x41, 0x42, 0x20, 0x40, 0x41, 0x42, 0x20,
+			),
+		},
+		{
+			"ConvolveKernel normalize, bias",
+			[][]float64{{1}, {1}, {1}},
+			&ConvolveOptions{Normalize: true, Bias: 1},
+			row(
+				0x11, 0x12, 0x13, 0xff, 0x21, 0x22, 0x23, 0x80,
+				0x31, 0x32, 0x33, 0x40, 0x41, 0x42, 0x43, 0x20,
+			),
+		},
+		{
+			"ConvolveKernel empty",
+			[][]float64{},
+			nil,
+			src,
+		},
+		{
+			"ConvolveKernel ragged",
+			[][]float64{{1, 0}, {0}},
+			nil,
+			src,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := ConvolveKernel(src, tc.kernel, tc.options)
+			if !compareNRGBA(got, tc.want, 0) {
+				t.Fatalf("got result %#v want %#v", got, tc.want)
+			}
+		})
+	}
+
+	// The colors of the transparent pixels don't bleed into the visible ones.
+	hidden := row(
+		0xff, 0x00, 0x00, 0x00, 0x00, 0x00, 0xff, 0xff, 0xff, 0x00, 0x00, 0x00,
+	)
+	got := ConvolveKernel(hidden, [][]float64{{1, 1, 1}}, &ConvolveOptions{Normalize: true, Alpha: true})
+	want := row(
+		0x00, 0x00, 0xff, 0x55, 0x00, 0x00, 0xff, 0x55, 0x00, 0x00, 0xff, 0x55,
+	)
+	if !compareNRGBA(got, want, 0) {
+		t.Fatalf("alpha weighting: got result %#v want %#v", got, want)
+	}
+
+	// The bias isn't added to the alpha.
+	got = ConvolveKernel(src, [][]float64{{1}}, &ConvolveOptions{Alpha: true, Bias: 1})
+	want = row(
+		0x11, 0x12, 0x13, 0xff, 0x21, 0x22, 0x23, 0x80,
+		0x31, 0x32, 0x33, 0x40, 0x41, 0x42, 0x43, 0x20,
+	)
+	if !compareNRGBA(got, want, 0) {
+		t.Fatalf("alpha bias: got result %#v want %#v", got, want)
+	}
+}
+
+func TestEdgeIndex(t *testing.T) {
+	testCases := []struct {
+		i, n int
+		mode EdgeMode
+		want int
+	}{
+		{-3, 4, EdgeClamp, 0},
+		{5, 4, EdgeClamp, 3},
+		{-1, 4, EdgeWrap, 3},
+		{9, 4, EdgeWrap, 1},
+		{-1, 4, EdgeMirror, 1},
+		{4, 4, EdgeMirror, 2},
+		{-7, 4, EdgeMirror, 1},
+		{3, 1, EdgeMirror, 0},
+	}
+	for _, tc := range testCases {
+		if got := edgeIndex(tc.i, tc.n, tc.mode); got != tc.want {
+			t.Errorf("edgeIndex(%d, %d, %d): got %d want %d", tc.i, tc.n, tc.mode, got, tc.want)
+		}
+	}
+}
+
 func TestNormalizeKernel(t *testing.T) {
 	testCases := []struct {
 		name   string
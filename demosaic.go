@@ -0,0 +1,168 @@
+package imaging
+
+import (
+	"image"
+)
+
+// BayerPattern is the layout of the color filter array of a camera sensor,
+// given as the colors of the top-left 2x2 pixels row by row.
+type BayerPattern int
+
+// Bayer patterns.
+const (
+	RGGB BayerPattern = iota
+	BGGR
+	GRBG
+	GBRG
+)
+
+// DemosaicMethod is the interpolation method of the missing colors used by Demosaic.
+type DemosaicMethod int
+
+// Demosaicing methods.
+const (
+	// Bilinear averages the nearest pixels of each color. It's fast but blurs the edges
+	// and produces color fringes around them.
+	Bilinear DemosaicMethod = iota
+	// Malvar is the gradient-corrected linear interpolation by Malvar, He and Cutler.
+	// It's noticeably sharper than Bilinear with a few more operations per pixel.
+	Malvar
+)
+
+// bayerColor returns the color channel (0 for red, 1 for green, 2 for blue)
+// of the pixel in the pattern.
+func bayerColor(pattern BayerPattern, x, y int) int {
+	var cfa [4]int
+	switch pattern {
+	case BGGR:
+		cfa = [4]int{2, 1, 1, 0}
+	case GRBG:
+		cfa = [4]int{1, 0, 2, 1}
+	case GBRG:
+		cfa = [4]int{1, 2, 0, 1}
+	default:
+		cfa = [4]int{0, 1, 1, 2}
+	}
+	return cfa[(y&1)*2+x&1]
+}
+
+// Malvar-He-Cutler filters for the 5x5 neighborhood, scaled by 16.
+var (
+	// Green at the red and blue pixels.
+	malvarGreen = [25]int{
+		0, 0, -2, 0, 0,
+		0, 0, 4, 0, 0,
+		-2, 4, 8, 4, -2,
+		0, 0, 4, 0, 0,
+		0, 0, -2, 0, 0,
+	}
+	// Red or blue at the green pixels with the red or blue neighbors in the same row.
+	malvarRow = [25]int{
+		0, 0, 1, 0, 0,
+		0, -2, 0, -2, 0,
+		-2, 8, 10, 8, -2,
+		0, -2, 0, -2, 0,
+		0, 0, 1, 0, 0,
+	}
+	// Red or blue at the green pixels with the red or blue neighbors in the same column.
+	malvarColumn = [25]int{
+		0, 0, -2, 0, 0,
+		0, -2, 8, -2, 0,
+		1, 0, 10, 0, 1,
+		0, -2, 8, -2, 0,
+		0, 0, -2, 0, 0,
+	}
+	// Red at the blue pixels and blue at the red pixels.
+	malvarDiagonal = [25]int{
+		0, 0, -3, 0, 0,
+		0, 4, 0, 4, 0,
+		-3, 0, 12, 0, -3,
+		0, 4, 0, 4, 0,
+		0, 0, -3, 0, 0,
+	}
+)
+
+// Demosaic reconstructs the full color image from the raw data of a camera sensor with
+// a Bayer color filter array, where each pixel holds the value of a single color.
+// The pattern describes the colors of the top-left 2x2 pixels of the raw image.
+// The edges are handled by mirroring the raw data.
+//
+// Example:
+//
+//	raw := &image.Gray{Pix: data, Stride: 1920, Rect: image.Rect(0, 0, 1920, 1080)}
+//	dstImage := imaging.Demosaic(raw, imaging.RGGB, imaging.Malvar)
+//
+func Demosaic(raw *image.Gray, pattern BayerPattern, method DemosaicMethod) *image.NRGBA {
+	w, h := raw.Rect.Dx(), raw.Rect.Dy()
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	if w <= 0 || h <= 0 {
+		return dst
+	}
+
+	// mirror maps the coordinate into [0, n) keeping its parity when possible.
+	mirror := func(v, n int) int {
+		if v < 0 {
+			v = -v
+		}
+		if v >= n {
+			v = 2*(n-1) - v
+		}
+		return min(max(v, 0), n-1)
+	}
+	at := func(x, y int) int {
+		return int(raw.Pix[raw.PixOffset(raw.Rect.Min.X+mirror(x, w), raw.Rect.Min.Y+mirror(y, h))])
+	}
+	filter := func(x, y int, k *[25]int) uint8 {
+		sum := 0
+		for i, v := range k {
+			if v != 0 {
+				sum += v * at(x+i%5-2, y+i/5-2)
+			}
+		}
+		return uint8(min(max((sum+8)>>4, 0), 255))
+	}
+
+	parallel(0, h, w*25*costTap, func(ys <-chan int) {
+		for y := range ys {
+			for x := 0; x < w; x++ {
+				var rgb [3]uint8
+				c := bayerColor(pattern, x, y)
+				rgb[c] = uint8(at(x, y))
+				if method == Malvar {
+					switch c {
+					case 1:
+						// The color of the horizontal neighbors.
+						hc := bayerColor(pattern, x+1, y)
+						rgb[hc] = filter(x, y, &malvarRow)
+						rgb[2-hc] = filter(x, y, &malvarColumn)
+					default:
+						rgb[1] = filter(x, y, &malvarGreen)
+						rgb[2-c] = filter(x, y, &malvarDiagonal)
+					}
+				} else {
+					// Average the pixels of each missing color in the 3x3 neighborhood.
+					var sum, count [3]int
+					for dy := -1; dy <= 1; dy++ {
+						for dx := -1; dx <= 1; dx++ {
+							nx, ny := mirror(x+dx, w), mirror(y+dy, h)
+							nc := bayerColor(pattern, nx, ny)
+							sum[nc] += at(nx, ny)
+							count[nc]++
+						}
+					}
+					for i := range rgb {
+						if i != c && count[i] > 0 {
+							rgb[i] = uint8((sum[i] + count[i]/2) / count[i])
+						}
+					}
+				}
+				i := y*dst.Stride + x*4
+				dst.Pix[i+0] = rgb[0]
+				dst.Pix[i+1] = rgb[1]
+				dst.Pix[i+2] = rgb[2]
+				dst.Pix[i+3] = 0xff
+			}
+		}
+	})
+	return dst
+}
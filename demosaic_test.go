@@ -0,0 +1,84 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// mosaic samples the image through the Bayer color filter array.
+func mosaic(img image.Image, pattern BayerPattern) *image.Gray {
+	src := toNRGBA(img)
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	raw := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			raw.Pix[y*raw.Stride+x] = src.Pix[src.PixOffset(x, y)+bayerColor(pattern, x, y)]
+		}
+	}
+	return raw
+}
+
+func TestDemosaicUniform(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 7, 5))
+	for i := 0; i < len(src.Pix); i += 4 {
+		copy(src.Pix[i:], []uint8{200, 120, 40, 255})
+	}
+	for _, pattern := range []BayerPattern{RGGB, BGGR, GRBG, GBRG} {
+		for _, method := range []DemosaicMethod{Bilinear, Malvar} {
+			got := Demosaic(mosaic(src, pattern), pattern, method)
+			if !compareNRGBA(got, src, 0) {
+				t.Fatalf("pattern %d method %d: got %v", pattern, method, got.Pix[:8])
+			}
+		}
+	}
+}
+
+func TestDemosaicPattern(t *testing.T) {
+	raw := &image.Gray{
+		Rect:   image.Rect(-1, -1, 1, 1),
+		Stride: 2,
+		Pix: []uint8{
+			0x10, 0x80,
+			0x80, 0xf0,
+		},
+	}
+	testCases := []struct {
+		pattern BayerPattern
+		want    color.NRGBA
+	}{
+		{RGGB, color.NRGBA{0x10, 0x80, 0xf0, 0xff}},
+		{BGGR, color.NRGBA{0xf0, 0x80, 0x10, 0xff}},
+	}
+	for _, tc := range testCases {
+		got := Demosaic(raw, tc.pattern, Bilinear)
+		if got.Rect != image.Rect(0, 0, 2, 2) {
+			t.Fatalf("got bounds %v", got.Rect)
+		}
+		if c := got.NRGBAAt(0, 0); c != tc.want {
+			t.Fatalf("pattern %d: got color %v want %v", tc.pattern, c, tc.want)
+		}
+	}
+}
+
+func TestDemosaicQuality(t *testing.T) {
+	src := testdataFlowersSmallPNG
+	raw := mosaic(src, GRBG)
+	bilinear := meanAbsDiff(Demosaic(raw, GRBG, Bilinear), src)
+	malvar := meanAbsDiff(Demosaic(raw, GRBG, Malvar), src)
+	if bilinear > 8 || malvar >= bilinear {
+		t.Fatalf("got mean difference %.2f for Bilinear and %.2f for Malvar", bilinear, malvar)
+	}
+}
+
+func TestDemosaicSmall(t *testing.T) {
+	for _, r := range []image.Rectangle{image.Rect(0, 0, 0, 0), image.Rect(0, 0, 1, 1), image.Rect(0, 0, 1, 3)} {
+		raw := image.NewGray(r)
+		for _, method := range []DemosaicMethod{Bilinear, Malvar} {
+			got := Demosaic(raw, RGGB, method)
+			if got.Rect != r {
+				t.Fatalf("got bounds %v want %v", got.Rect, r)
+			}
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package imaging
+
+import (
+	"image"
+	"math"
+)
+
+// MedianFilter replaces each channel of each pixel with the median of its values in the square
+// window of (2*radius+1) x (2*radius+1) pixels around it. Unlike Blur, it removes the salt and
+// pepper noise and the small specks completely and keeps the edges sharp. The pixels outside
+// the image repeat the edge pixels. If radius is 0 or less, a copy of the image is returned.
+// The time per pixel grows linearly with the radius.
+//
+// Example:
+//
+//	dstImage := imaging.MedianFilter(srcImage, 1)
+//
+func MedianFilter(img image.Image, radius int) *image.NRGBA {
+	if radius <= 0 {
+		return Clone(img)
+	}
+	src := toNRGBA(img)
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	if w <= 0 || h <= 0 {
+		return dst
+	}
+
+	size := 2*radius + 1
+	rank := size * size / 2
+	parallel(0, h, w*size*size*costPoint, func(ys <-chan int) {
+		var hist medianHistogram
+		rows := make([][]uint8, size)
+		for y := range ys {
+			hist = medianHistogram{}
+			for i := range rows {
+				iy := edgeIndex(y+i-radius, h, EdgeClamp)
+				rows[i] = src.Pix[iy*src.Stride : iy*src.Stride+w*4]
+			}
+			for dx := -radius; dx <= radius; dx++ {
+				hist.addColumn(rows, edgeIndex(dx, w, EdgeClamp), 1)
+			}
+			d := dst.Pix[y*dst.Stride : y*dst.Stride+w*4]
+			for x := 0; x < w; x++ {
+				for c := 0; c < 4; c++ {
+					d[x*4+c] = hist.median(c, rank)
+				}
+				if x+1 < w {
+					hist.addColumn(rows, edgeIndex(x-radius, w, EdgeClamp), -1)
+					hist.addColumn(rows, edgeIndex(x+radius+1, w, EdgeClamp), 1)
+				}
+			}
+		}
+	})
+	return dst
+}
+
+// medianHistogram holds the histograms of the channel values of a window, with
+// the coarse histograms of the upper 4 bits to find the median in a few steps.
+type medianHistogram struct {
+	coarse [4][16]int
+	fine   [4][256]int
+}
+
+// addColumn adds the pixels at x of the rows to the histograms n times (n may be negative).
+func (m *medianHistogram) addColumn(rows [][]uint8, x, n int) {
+	for _, row := range rows {
+		p := row[x*4 : x*4+4 : x*4+4]
+		for c, v := range p {
+			m.coarse[c][v>>4] += n
+			m.fine[c][v] += n
+		}
+	}
+}
+
+// median returns the value of the channel with the given rank (0-based) in the window.
+func (m *medianHistogram) median(c, rank int) uint8 {
+	i := 0
+	for ; i < 15 && rank >= m.coarse[c][i]; i++ {
+		rank -= m.coarse[c][i]
+	}
+	v := i << 4
+	for ; v < i<<4+15 && rank >= m.fine[c][v]; v++ {
+		rank -= m.fine[c][v]
+	}
+	return uint8(v)
+}
+
+// Denoise reduces the noise of the image, such as the sensor noise of the photos taken in low
+// light, keeping the edges sharp, using the bilateral filter: each pixel is replaced by the
+// weighted mean of its neighbors within 4 pixels, where the neighbors of a very different
+// color get small weights, so the edges aren't blurred. The strength is the standard deviation
+// of the noise to remove in 8-bit units, e.g. 5 for the light noise and 20 for the heavy one.
+// The larger values flatten the fine textures as well. If strength is 0 or less, a copy
+// of the image is returned. The transparent pixels don't affect the colors.
+//
+// Denoising the photos before downscaling them, e.g. for the thumbnails, avoids
+// the noise turning into the color blotches.
+//
+// Example:
+//
+//	dstImage := imaging.Denoise(srcImage, 10)
+//
+func Denoise(img image.Image, strength float64) *image.NRGBA {
+	if !(strength > 0) {
+		return Clone(img)
+	}
+	src := toNRGBA(img)
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	if w <= 0 || h <= 0 {
+		return dst
+	}
+
+	const radius = 4
+	const sigmaSpatial = 2.0
+	spatial := make([]float64, (2*radius+1)*(2*radius+1))
+	for dy := -radius; dy <= radius; dy++ {
+		for dx := -radius; dx <= radius; dx++ {
+			spatial[(dy+radius)*(2*radius+1)+dx+radius] = math.Exp(-float64(dx*dx+dy*dy) / (2 * sigmaSpatial * sigmaSpatial))
+		}
+	}
+	// The range weights by the squared distance of the colors and alpha divided by 16.
+	// The differences within about 2.5 standard deviations of the noise in each of
+	// the color channels keep high weights.
+	sigmaRange := 2.5 * strength * math.Sqrt(3)
+	rangeWeights := make([]float64, 4*255*255/16+1)
+	for i := range rangeWeights {
+		rangeWeights[i] = math.Exp(-float64(i*16) / (2 * sigmaRange * sigmaRange))
+	}
+
+	parallel(0, h, w*(2*radius+1)*(2*radius+1)*costPoint, func(ys <-chan int) {
+		for y := range ys {
+			for x := 0; x < w; x++ {
+				i := y*src.Stride + x*4
+				p := src.Pix[i : i+4 : i+4]
+				var r, g, b, a, total float64
+				for dy := -radius; dy <= radius; dy++ {
+					sy := y + dy
+					if sy < 0 || sy >= h {
+						continue
+					}
+					for dx := -radius; dx <= radius; dx++ {
+						sx := x + dx
+						if sx < 0 || sx >= w {
+							continue
+						}
+						j := sy*src.Stride + sx*4
+						s := src.Pix[j : j+4 : j+4]
+						dr := int(s[0]) - int(p[0])
+						dg := int(s[1]) - int(p[1])
+						db := int(s[2]) - int(p[2])
+						da := int(s[3]) - int(p[3])
+						wt := spatial[(dy+radius)*(2*radius+1)+dx+radius] * rangeWeights[(dr*dr+dg*dg+db*db+da*da)>>4]
+						total += wt
+						wa := wt * float64(s[3])
+						a += wa
+						r += wa * float64(s[0])
+						g += wa * float64(s[1])
+						b += wa * float64(s[2])
+					}
+				}
+				d := dst.Pix[y*dst.Stride+x*4 : y*dst.Stride+x*4+4 : y*dst.Stride+x*4+4]
+				if a == 0 {
+					continue
+				}
+				d[0] = clamp(r / a)
+				d[1] = clamp(g / a)
+				d[2] = clamp(b / a)
+				d[3] = clamp(a / total)
+			}
+		}
+	})
+	return dst
+}
@@ -0,0 +1,128 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestMedianFilter(t *testing.T) {
+	// Salt and pepper noise on a flat image is removed completely.
+	src := New(20, 20, color.Gray{128})
+	for i := 0; i < 20; i++ {
+		v := uint8(0)
+		if i%2 == 0 {
+			v = 255
+		}
+		src.SetNRGBA((i*7)%20, (i*13)%20, color.NRGBA{v, v, v, 255})
+	}
+	if got := MedianFilter(src, 1); !compareNRGBA(got, New(20, 20, color.Gray{128}), 0) {
+		t.Fatalf("the noise isn't removed")
+	}
+
+	// The edges are kept.
+	step := stepImage(10, 10, 50, 200)
+	if got := MedianFilter(step, 2); !compareNRGBA(got, step, 0) {
+		t.Fatalf("the edge is changed")
+	}
+
+	if got := MedianFilter(src, 0); !compareNRGBA(got, src, 0) {
+		t.Fatalf("radius 0: the image is changed")
+	}
+	if got := MedianFilter(&image.NRGBA{}, 1); !got.Rect.Empty() {
+		t.Fatalf("got bounds %v", got.Rect)
+	}
+}
+
+func TestMedianFilterNaive(t *testing.T) {
+	src := Crop(testdataFlowersSmallPNG, image.Rect(10, 10, 50, 40))
+	for _, radius := range []int{1, 3} {
+		got := MedianFilter(src, radius)
+		w, h := src.Rect.Dx(), src.Rect.Dy()
+		values := make([]uint8, 0, (2*radius+1)*(2*radius+1))
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				for c := 0; c < 4; c++ {
+					values = values[:0]
+					for dy := -radius; dy <= radius; dy++ {
+						for dx := -radius; dx <= radius; dx++ {
+							ix := edgeIndex(x+dx, w, EdgeClamp)
+							iy := edgeIndex(y+dy, h, EdgeClamp)
+							values = append(values, src.Pix[iy*src.Stride+ix*4+c])
+						}
+					}
+					slices.Sort(values)
+					if want := values[len(values)/2]; got.Pix[y*got.Stride+x*4+c] != want {
+						t.Fatalf("radius %d: got %d at (%d, %d) channel %d, want %d", radius, got.Pix[y*got.Stride+x*4+c], x, y, c, want)
+					}
+				}
+			}
+		}
+	}
+}
+
+func stdDev(img *image.NRGBA, r image.Rectangle) float64 {
+	var sum, sum2, n float64
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			v := float64(img.Pix[y*img.Stride+x*4])
+			sum += v
+			sum2 += v * v
+			n++
+		}
+	}
+	mean := sum / n
+	return math.Sqrt(sum2/n - mean*mean)
+}
+
+func TestDenoise(t *testing.T) {
+	src := noisyImage(64, 64, 10)
+	got := Denoise(src, 10)
+	if d := stdDev(got, got.Rect); d > 3 {
+		t.Fatalf("got standard deviation %.2f after denoising, %.2f before", d, stdDev(src, src.Rect))
+	}
+	if m := meanValue(got); math.Abs(m-meanValue(src)) > 1 {
+		t.Fatalf("got mean %.2f, want %.2f", m, meanValue(src))
+	}
+
+	// The edge between the noisy areas stays sharp.
+	edge := stepImage(40, 20, 50, 200)
+	noise := noisyImage(40, 20, 5)
+	for i := 0; i < len(edge.Pix); i += 4 {
+		for c := 0; c < 3; c++ {
+			edge.Pix[i+c] = clamp(float64(edge.Pix[i+c]) + float64(noise.Pix[i]) - 128)
+		}
+	}
+	got = Denoise(edge, 5)
+	for y := 0; y < 20; y++ {
+		if l, r := got.Pix[y*got.Stride+19*4], got.Pix[y*got.Stride+20*4]; math.Abs(float64(l)-50) > 6 || math.Abs(float64(r)-200) > 6 {
+			t.Fatalf("got %d and %d at the edge in row %d", l, r, y)
+		}
+	}
+	if d := stdDev(got, image.Rect(2, 2, 15, 18)); d > 2 {
+		t.Fatalf("got standard deviation %.2f", d)
+	}
+}
+
+func TestDenoiseTransparent(t *testing.T) {
+	// The colors of the transparent pixels don't bleed into the opaque ones.
+	src := New(10, 10, color.NRGBA{100, 100, 100, 255})
+	for y := 0; y < 10; y++ {
+		for x := 5; x < 10; x++ {
+			src.SetNRGBA(x, y, color.NRGBA{100, 100, 100, 0})
+		}
+	}
+	src.SetNRGBA(6, 5, color.NRGBA{255, 0, 0, 0})
+	got := Denoise(src, 20)
+	if c := got.NRGBAAt(4, 5); c.R != 100 || c.G != 100 || c.B != 100 || c.A < 250 {
+		t.Fatalf("got %v", c)
+	}
+	if got := Denoise(src, 0); !compareNRGBA(got, src, 0) {
+		t.Fatalf("strength 0: the image is changed")
+	}
+	if got := Denoise(&image.NRGBA{}, 10); !got.Rect.Empty() {
+		t.Fatalf("got bounds %v", got.Rect)
+	}
+}
@@ -0,0 +1,197 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"slices"
+)
+
+// canonicalImage returns an image with the same pixels in a form that depends only
+// on the pixel values, used by the Deterministic encode option: the colors of the fully
+// transparent pixels are zeroed, the opaque gray images become *image.Gray (or *image.Gray16
+// for the 16-bit sources) and the other images *image.NRGBA (or *image.NRGBA64).
+// The 16-bit images with all the values representable in 8 bits are reduced to 8 bits
+// unless keep16 is true. The paletted images get the canonical palette, see canonicalPaletted.
+func canonicalImage(img image.Image, keep16 bool) image.Image {
+	if p, ok := img.(*image.Paletted); ok && validBuffers(p) {
+		return canonicalPaletted(p)
+	}
+
+	switch img.ColorModel() {
+	case color.Gray16Model, color.RGBA64Model, color.NRGBA64Model:
+		src := Clone16(img)
+		opaque, gray, wide := true, true, false
+		for i := 0; i < len(src.Pix); i += 8 {
+			s := src.Pix[i : i+8 : i+8]
+			if s[6] == 0 && s[7] == 0 {
+				clear(s)
+			}
+			opaque = opaque && s[6] == 0xff && s[7] == 0xff
+			gray = gray && s[0] == s[2] && s[1] == s[3] && s[0] == s[4] && s[1] == s[5]
+			wide = wide || s[0] != s[1] || s[2] != s[3] || s[4] != s[5] || s[6] != s[7]
+		}
+		if !wide && !keep16 {
+			return canonicalImage(to8Bit(src), false)
+		}
+		if !opaque || !gray {
+			return src
+		}
+		dst := image.NewGray16(src.Rect)
+		for i := range len(dst.Pix) / 2 {
+			copy(dst.Pix[i*2:i*2+2], src.Pix[i*8:i*8+2])
+		}
+		return dst
+	}
+
+	src := Clone(img)
+	opaque, gray := true, true
+	for i := 0; i < len(src.Pix); i += 4 {
+		s := src.Pix[i : i+4 : i+4]
+		if s[3] == 0 {
+			clear(s)
+		}
+		opaque = opaque && s[3] == 0xff
+		gray = gray && s[0] == s[1] && s[0] == s[2]
+	}
+	if !opaque || !gray {
+		return src
+	}
+	dst := image.NewGray(src.Rect)
+	for i := range dst.Pix {
+		dst.Pix[i] = src.Pix[i*4]
+	}
+	return dst
+}
+
+// to8Bit converts the 16-bit image to 8 bits by taking the high bytes of the values.
+func to8Bit(src *image.NRGBA64) *image.NRGBA {
+	dst := image.NewNRGBA(src.Rect)
+	for i := range dst.Pix {
+		dst.Pix[i] = src.Pix[i*2]
+	}
+	return dst
+}
+
+// canonicalPaletted returns a copy of the paletted image with the canonical palette:
+// the unused and duplicate colors are removed, all the fully transparent colors are merged
+// into one, which comes first, and the other colors are sorted by their NRGBA values.
+func canonicalPaletted(img *image.Paletted) *image.Paletted {
+	b := img.Rect
+	w, h := b.Dx(), b.Dy()
+
+	// The NRGBA colors of the used indices, the indices out of the palette are transparent.
+	var used [256]bool
+	for y := 0; y < h; y++ {
+		i := img.PixOffset(b.Min.X, b.Min.Y+y)
+		for _, v := range img.Pix[i : i+w] {
+			used[v] = true
+		}
+	}
+	var colors [256]color.NRGBA
+	var pal []color.NRGBA
+	for i := range colors {
+		if !used[i] {
+			continue
+		}
+		if i < len(img.Palette) {
+			colors[i] = color.NRGBAModel.Convert(img.Palette[i]).(color.NRGBA)
+		}
+		if colors[i].A == 0 {
+			colors[i] = color.NRGBA{}
+		}
+		pal = append(pal, colors[i])
+	}
+	pal = canonicalPalette(pal)
+
+	index := make(map[color.NRGBA]uint8, len(pal))
+	for i, c := range pal {
+		index[c] = uint8(i)
+	}
+	var remap [256]uint8
+	for i := range colors {
+		if used[i] {
+			remap[i] = index[colors[i]]
+		}
+	}
+	dst := image.NewPaletted(image.Rect(0, 0, w, h), nrgbaPalette(pal))
+	for y := 0; y < h; y++ {
+		i := img.PixOffset(b.Min.X, b.Min.Y+y)
+		for x, v := range img.Pix[i : i+w] {
+			dst.Pix[y*dst.Stride+x] = remap[v]
+		}
+	}
+	return dst
+}
+
+// canonicalPalette sorts the colors putting the fully transparent color first and the other
+// colors in the order of their NRGBA values, and removes the duplicates. The fully transparent
+// colors must be zeroed.
+func canonicalPalette(pal []color.NRGBA) []color.NRGBA {
+	slices.SortFunc(pal, func(a, b color.NRGBA) int {
+		if (a.A == 0) != (b.A == 0) {
+			if a.A == 0 {
+				return -1
+			}
+			return 1
+		}
+		ka := uint32(a.R)<<24 | uint32(a.G)<<16 | uint32(a.B)<<8 | uint32(a.A)
+		kb := uint32(b.R)<<24 | uint32(b.G)<<16 | uint32(b.B)<<8 | uint32(b.A)
+		if ka < kb {
+			return -1
+		} else if ka > kb {
+			return 1
+		}
+		return 0
+	})
+	return slices.Compact(pal)
+}
+
+// nrgbaPalette converts the colors to color.Palette.
+func nrgbaPalette(colors []color.NRGBA) color.Palette {
+	p := make(color.Palette, len(colors))
+	for i, c := range colors {
+		p[i] = c
+	}
+	return p
+}
+
+// deterministicGIFImage returns the canonical paletted image encoded by the GIF encoder
+// with the Deterministic option. The image is quantized here the same way gif.Encode
+// does it, so that the palette is canonical even if the quantizer returns the colors
+// in an arbitrary order.
+func deterministicGIFImage(img image.Image, cfg *encodeConfig) *image.Paletted {
+	img = canonicalImage(img, false)
+	numColors := min(max(cfg.gifNumColors, 1), 256)
+	if p, ok := img.(*image.Paletted); ok && len(p.Palette) <= numColors {
+		return p
+	}
+
+	quantizer := cfg.gifQuantizer
+	if quantizer == nil {
+		quantizer = medianCutQuantizer{}
+	}
+	pal := quantizer.Quantize(make(color.Palette, 0, numColors), img)
+	if len(pal) > numColors {
+		pal = pal[:numColors]
+	}
+	// The order of the palette changes the choice between the equally close colors,
+	// so the palette is made canonical before drawing too.
+	colors := []color.NRGBA{{A: 0xff}}
+	if len(pal) > 0 {
+		colors = make([]color.NRGBA, len(pal))
+		for i, c := range pal {
+			if colors[i] = color.NRGBAModel.Convert(c).(color.NRGBA); colors[i].A == 0 {
+				colors[i] = color.NRGBA{}
+			}
+		}
+	}
+	drawer := cfg.gifDrawer
+	if drawer == nil {
+		drawer = draw.FloydSteinberg
+	}
+	b := img.Bounds()
+	p := image.NewPaletted(image.Rect(0, 0, b.Dx(), b.Dy()), nrgbaPalette(canonicalPalette(colors)))
+	drawer.Draw(p, p.Rect, img, b.Min)
+	return canonicalPaletted(p)
+}
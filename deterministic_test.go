@@ -0,0 +1,137 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+// reversedQuantizer returns the palette of the wrapped quantizer in the reversed order.
+type reversedQuantizer struct{ draw.Quantizer }
+
+func (q reversedQuantizer) Quantize(p color.Palette, m image.Image) color.Palette {
+	pal := q.Quantizer.Quantize(p, m)
+	for i, j := len(p), len(pal)-1; i < j; i, j = i+1, j-1 {
+		pal[i], pal[j] = pal[j], pal[i]
+	}
+	return pal
+}
+
+func TestDeterministic(t *testing.T) {
+	src := Clone(testdataFlowersSmallPNG)
+	src.Pix[3] = 0 // A transparent pixel.
+
+	// The same pixels in different forms.
+	hidden := Clone(src)
+	hidden.Pix[0], hidden.Pix[1], hidden.Pix[2] = 1, 2, 3
+	canvas := Paste(New(260, 180, color.NRGBA{}), src, image.Pt(10, 10))
+	rgba := image.NewRGBA(src.Rect)
+	draw.Draw(rgba, rgba.Rect, src, image.Point{}, draw.Src)
+	rgba.Pix[0], rgba.Pix[1], rgba.Pix[2], rgba.Pix[3] = 0, 0, 0, 0
+
+	gray := image.NewGray(image.Rect(0, 0, 50, 40))
+	for i := range gray.Pix {
+		gray.Pix[i] = uint8(i * 7)
+	}
+
+	testCases := []struct {
+		name   string
+		images []image.Image
+		opts   []EncodeOption
+	}{
+		{"color", []image.Image{src, hidden, canvas.SubImage(image.Rect(10, 10, 250, 170)), rgba}, nil},
+		{"gray", []image.Image{gray, Clone(gray), Clone16(gray)}, nil},
+		{"16-bit", []image.Image{Clone16(src), Clone16(hidden)}, nil},
+		{"paletted", []image.Image{src, hidden}, []EncodeOption{PNGNumColors(32)}},
+		{"interlaced", []image.Image{src, hidden}, []EncodeOption{PNGInterlace(true)}},
+	}
+	for _, tc := range testCases {
+		var first []byte
+		for i, img := range tc.images {
+			data, err := EncodeBytes(img, PNG, append(tc.opts, Deterministic(true))...)
+			if err != nil {
+				t.Fatalf("%s: EncodeBytes: %v", tc.name, err)
+			}
+			if i == 0 {
+				first = data
+				decoded, err := DecodeBytes(data)
+				if err != nil {
+					t.Fatalf("%s: DecodeBytes: %v", tc.name, err)
+				}
+				if len(tc.opts) == 0 && !Equal(decoded, img) {
+					t.Fatalf("%s: the decoded image differs", tc.name)
+				}
+			} else if !bytes.Equal(data, first) {
+				t.Fatalf("%s: the image %d is encoded differently", tc.name, i)
+			}
+		}
+	}
+
+	// The gray pixels are encoded as a gray image.
+	a, _ := EncodeBytes(gray, PNG, Deterministic(true))
+	b, _ := EncodeBytes(Clone(gray), PNG, Deterministic(true))
+	c, _ := EncodeBytes(Clone(gray), PNG)
+	if !bytes.Equal(a, b) || len(c) <= len(a) {
+		t.Fatalf("got %d, %d and %d bytes", len(a), len(b), len(c))
+	}
+}
+
+func TestDeterministicPaletted(t *testing.T) {
+	pal := color.Palette{color.NRGBA{255, 0, 0, 255}, color.NRGBA{0, 0, 255, 255}, color.NRGBA{9, 9, 9, 0}, color.White}
+	a := image.NewPaletted(image.Rect(0, 0, 8, 8), pal)
+	for i := range a.Pix {
+		a.Pix[i] = uint8(i % 3)
+	}
+
+	// The same pixels with a shuffled palette, an unused color and a duplicate color.
+	shuffled := color.Palette{color.Black, color.Transparent, color.NRGBA{0, 0, 255, 255}, color.NRGBA{255, 0, 0, 255}, color.NRGBA{0, 0, 255, 255}}
+	b := image.NewPaletted(image.Rect(0, 0, 8, 8), shuffled)
+	for i := range b.Pix {
+		b.Pix[i] = [3]uint8{3, 4, 1}[i%3]
+	}
+
+	for _, format := range []Format{PNG, GIF} {
+		da, err := EncodeBytes(a, format, Deterministic(true))
+		if err != nil {
+			t.Fatalf("EncodeBytes: %v", err)
+		}
+		db, err := EncodeBytes(b, format, Deterministic(true))
+		if err != nil {
+			t.Fatalf("EncodeBytes: %v", err)
+		}
+		if !bytes.Equal(da, db) {
+			t.Fatalf("the %v images are encoded differently", format)
+		}
+		decoded, err := DecodeBytes(da)
+		if err != nil {
+			t.Fatalf("DecodeBytes: %v", err)
+		}
+		if !Equal(decoded, a) {
+			t.Fatalf("the decoded %v image differs", format)
+		}
+		if p, ok := decoded.(*image.Paletted); !ok || (format == PNG && len(p.Palette) != 3) {
+			t.Fatalf("got decoded image %T", decoded)
+		}
+	}
+}
+
+func TestDeterministicGIFQuantizer(t *testing.T) {
+	src := testdataFlowersSmallPNG
+	q := medianCutQuantizer{}
+	a, err := EncodeBytes(src, GIF, Deterministic(true), GIFNumColors(64), GIFQuantizer(q))
+	if err != nil {
+		t.Fatalf("EncodeBytes: %v", err)
+	}
+	b, err := EncodeBytes(src, GIF, Deterministic(true), GIFNumColors(64), GIFQuantizer(reversedQuantizer{q}))
+	if err != nil {
+		t.Fatalf("EncodeBytes: %v", err)
+	}
+	if !bytes.Equal(a, b) {
+		t.Fatalf("the palette order of the quantizer changes the data")
+	}
+	if _, err := EncodeBytes(src, GIF, Deterministic(true)); err != nil {
+		t.Fatalf("EncodeBytes: %v", err)
+	}
+}
@@ -0,0 +1,254 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"slices"
+)
+
+// DitherMode is the way Dither and Quantize map the pixels to the colors of the palette.
+type DitherMode int
+
+// Dithering modes.
+const (
+	// DitherNone maps each pixel to the nearest color of the palette. The gradients
+	// show bands, but the flat areas stay clean and the images compress better.
+	DitherNone DitherMode = iota
+	// DitherOrdered mixes the palette colors in the regular patterns picked by the 8x8
+	// Bayer threshold matrix. The patterns are stable, so they don't flicker in the
+	// animations and suit the displays that are refreshed partially.
+	DitherOrdered
+	// DitherFloydSteinberg spreads the error of each pixel to its neighbors. It gives
+	// the most accurate colors but a noisy texture.
+	DitherFloydSteinberg
+	// DitherAtkinson spreads 3/4 of the error of each pixel to a wider neighborhood,
+	// losing the rest. The highlights and the shadows get clean, the midtones keep
+	// a fine texture, which suits the 1-bit printers and e-ink displays.
+	DitherAtkinson
+)
+
+// Dither returns the image converted to a paletted image with the given palette, such as
+// the fixed palette of a device, with the pixels mapped to the palette colors using the mode.
+// Only the first 256 colors of the palette are used. See GrayPalette for the palettes
+// of the grayscale displays and printers.
+//
+// Example:
+//
+//	// 1-bit image for a thermal printer.
+//	dstImage := imaging.Dither(img, imaging.GrayPalette(2), imaging.DitherAtkinson)
+//
+func Dither(img image.Image, palette color.Palette, mode DitherMode) *image.Paletted {
+	if len(palette) > 256 {
+		palette = palette[:256]
+	}
+	if len(palette) == 0 {
+		palette = color.Palette{color.NRGBA{}}
+	}
+	b := img.Bounds()
+	dst := image.NewPaletted(image.Rect(0, 0, b.Dx(), b.Dy()), palette)
+	if dst.Rect.Empty() {
+		return dst
+	}
+
+	switch mode {
+	case DitherFloydSteinberg:
+		draw.FloydSteinberg.Draw(dst, dst.Rect, img, b.Min)
+	case DitherAtkinson:
+		ditherAtkinson(dst, img)
+	case DitherOrdered:
+		ditherOrdered(dst, img)
+	default:
+		mapPaletted(dst, img)
+	}
+	return dst
+}
+
+// GrayPalette returns the palette of the evenly spaced gray levels from black to white,
+// from 2 levels for the 1-bit output to 256. The 4 and 16 levels are the 2-bit and
+// the 4-bit grayscale.
+//
+// Example:
+//
+//	dstImage := imaging.Dither(img, imaging.GrayPalette(16), imaging.DitherFloydSteinberg)
+//
+func GrayPalette(levels int) color.Palette {
+	levels = min(max(levels, 2), 256)
+	p := make(color.Palette, levels)
+	for i := range p {
+		p[i] = color.Gray{uint8((i*255 + (levels-1)/2) / (levels - 1))}
+	}
+	return p
+}
+
+// bayer8 is the 8x8 Bayer threshold matrix.
+var bayer8 = [8][8]uint8{
+	{0, 32, 8, 40, 2, 34, 10, 42},
+	{48, 16, 56, 24, 50, 18, 58, 26},
+	{12, 44, 4, 36, 14, 46, 6, 38},
+	{60, 28, 52, 20, 62, 30, 54, 22},
+	{3, 35, 11, 43, 1, 33, 9, 41},
+	{51, 19, 59, 27, 49, 17, 57, 25},
+	{15, 47, 7, 39, 13, 45, 5, 37},
+	{63, 31, 55, 23, 61, 29, 53, 21},
+}
+
+// ditherOrdered draws the image into dst with the Thomas Knoll's pattern dithering, the ordered
+// dithering for the arbitrary palettes: the candidate colors for each color are chosen so that
+// their mean approaches the color, the same way the error diffusion does, and the Bayer
+// threshold of the pixel picks one of them sorted by luminance. The palette colors are kept
+// as is, and the evenly spaced levels get the usual Bayer patterns.
+func ditherOrdered(dst *image.Paletted, img image.Image) {
+	// Each of the candidates takes 64/n thresholds. The fewer candidates
+	// of the large palettes are enough and faster to find.
+	n := 64
+	if len(dst.Palette) > 16 {
+		n = 16
+	}
+	patternPaletted(dst, img, n)
+}
+
+// mapPaletted sets each pixel of dst to the palette color nearest to the pixel of img.
+func mapPaletted(dst *image.Paletted, img image.Image) {
+	patternPaletted(dst, img, 1)
+}
+
+// patternPaletted sets each pixel of dst to one of the n candidate colors for the pixel
+// of img picked by the Bayer threshold. A single candidate is the nearest color.
+func patternPaletted(dst *image.Paletted, img image.Image, n int) {
+	colors := make([]color.NRGBA, len(dst.Palette))
+	luma := make([]int, len(dst.Palette))
+	// The premultiplied 16-bit colors compared the same way color.Palette.Index compares them.
+	premul := make([][4]int64, len(dst.Palette))
+	// The palette colors are mapped to themselves rather than to a pattern.
+	exact := make(map[color.NRGBA]uint8)
+	for i := len(dst.Palette) - 1; i >= 0; i-- {
+		c := dst.Palette[i]
+		colors[i] = color.NRGBAModel.Convert(c).(color.NRGBA)
+		if colors[i].A == 0 {
+			exact[color.NRGBA{}] = uint8(i)
+		} else {
+			exact[colors[i]] = uint8(i)
+		}
+		luma[i] = 299*int(colors[i].R) + 587*int(colors[i].G) + 114*int(colors[i].B)
+		r, g, b, a := c.RGBA()
+		premul[i] = [4]int64{int64(r), int64(g), int64(b), int64(a)}
+	}
+
+	s := newScanner(img)
+	w := dst.Rect.Dx()
+	parallel(0, dst.Rect.Dy(), w*costHeavy, func(ys <-chan int) {
+		line := make([]uint8, w*4)
+		nearest := make(map[color.NRGBA]uint8)
+		index := func(c color.NRGBA) uint8 {
+			idx, ok := nearest[c]
+			if !ok {
+				r, g, b, a := c.RGBA()
+				v := [4]int64{int64(r), int64(g), int64(b), int64(a)}
+				bestDist := int64(-1)
+				for i, pc := range premul {
+					var d int64
+					for k := range v {
+						d += (v[k] - pc[k]) * (v[k] - pc[k])
+					}
+					if bestDist < 0 || d < bestDist {
+						idx, bestDist = uint8(i), d
+					}
+				}
+				nearest[c] = idx
+			}
+			return idx
+		}
+		patterns := make(map[color.NRGBA][]uint8)
+		for y := range ys {
+			s.scan(0, y, w, y+1, line)
+			for x := 0; x < w; x++ {
+				p := line[x*4 : x*4+4 : x*4+4]
+				c := color.NRGBA{p[0], p[1], p[2], p[3]}
+				if c.A == 0 {
+					c = color.NRGBA{}
+				}
+				if n == 1 {
+					dst.Pix[y*dst.Stride+x] = index(c)
+					continue
+				}
+				if idx, ok := exact[c]; ok {
+					dst.Pix[y*dst.Stride+x] = idx
+					continue
+				}
+				// The patterns are shared by the colors that differ in the low
+				// bits only, finer than the steps of the pattern anyway.
+				c = color.NRGBA{c.R&^3 | 1, c.G&^3 | 1, c.B&^3 | 1, c.A}
+				pattern, ok := patterns[c]
+				if !ok {
+					pattern = make([]uint8, n)
+					var er, eg, eb float64
+					for i := range pattern {
+						idx := index(color.NRGBA{clamp(float64(c.R) + er), clamp(float64(c.G) + eg), clamp(float64(c.B) + eb), c.A})
+						pattern[i] = idx
+						er += float64(c.R) - float64(colors[idx].R)
+						eg += float64(c.G) - float64(colors[idx].G)
+						eb += float64(c.B) - float64(colors[idx].B)
+					}
+					slices.SortStableFunc(pattern, func(a, b uint8) int { return luma[a] - luma[b] })
+					patterns[c] = pattern
+				}
+				dst.Pix[y*dst.Stride+x] = pattern[int(bayer8[y&7][x&7])*n/64]
+			}
+		}
+	})
+}
+
+// ditherAtkinson draws the image into dst with the Atkinson error diffusion. The colors
+// are premultiplied by alpha and compared the same way color.Palette.Index compares them.
+func ditherAtkinson(dst *image.Paletted, img image.Image) {
+	w, h := dst.Rect.Dx(), dst.Rect.Dy()
+	colors := make([][4]float32, len(dst.Palette))
+	for i, c := range dst.Palette {
+		r, g, b, a := c.RGBA()
+		colors[i] = [4]float32{float32(r) / 257, float32(g) / 257, float32(b) / 257, float32(a) / 257}
+	}
+
+	// The errors of the current and the next two rows, with two pixels of padding on each side.
+	var rows [3][][4]float32
+	for i := range rows {
+		rows[i] = make([][4]float32, w+4)
+	}
+	s := newScanner(img)
+	line := make([]uint8, w*4)
+	for y := 0; y < h; y++ {
+		s.scan(0, y, w, y+1, line)
+		cur, next, next2 := rows[0], rows[1], rows[2]
+		for x := 0; x < w; x++ {
+			p := line[x*4 : x*4+4 : x*4+4]
+			a := float32(p[3]) / 255
+			v := [4]float32{float32(p[0]) * a, float32(p[1]) * a, float32(p[2]) * a, float32(p[3])}
+			for c := range v {
+				v[c] = min(max(v[c]+cur[x+2][c], 0), 255)
+			}
+			v[0], v[1], v[2] = min(v[0], v[3]), min(v[1], v[3]), min(v[2], v[3])
+			best, bestDist := 0, float32(-1)
+			for j, pc := range colors {
+				var d float32
+				for c := range v {
+					d += (v[c] - pc[c]) * (v[c] - pc[c])
+				}
+				if bestDist < 0 || d < bestDist {
+					best, bestDist = j, d
+				}
+			}
+			dst.Pix[y*dst.Stride+x] = uint8(best)
+			for c := range v {
+				e := (v[c] - colors[best][c]) / 8
+				cur[x+3][c] += e
+				cur[x+4][c] += e
+				next[x+1][c] += e
+				next[x+2][c] += e
+				next[x+3][c] += e
+				next2[x+2][c] += e
+			}
+		}
+		clear(cur)
+		rows[0], rows[1], rows[2] = next, next2, cur
+	}
+}
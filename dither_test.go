@@ -0,0 +1,135 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestGrayPalette(t *testing.T) {
+	testCases := []struct {
+		levels int
+		want   []uint8
+	}{
+		{0, []uint8{0, 255}},
+		{2, []uint8{0, 255}},
+		{3, []uint8{0, 128, 255}},
+		{4, []uint8{0, 85, 170, 255}},
+	}
+	for _, tc := range testCases {
+		p := GrayPalette(tc.levels)
+		if len(p) != len(tc.want) {
+			t.Fatalf("%d levels: got %d colors", tc.levels, len(p))
+		}
+		for i, c := range p {
+			if c != (color.Gray{tc.want[i]}) {
+				t.Fatalf("%d levels: got palette %v", tc.levels, p)
+			}
+		}
+	}
+	if p := GrayPalette(16); p[1] != (color.Gray{17}) || p[15] != (color.Gray{255}) {
+		t.Fatalf("got palette %v", p)
+	}
+	if p := GrayPalette(1000); len(p) != 256 || p[100] != (color.Gray{100}) {
+		t.Fatalf("got %d colors", len(p))
+	}
+}
+
+func TestDither(t *testing.T) {
+	// The horizontal gradient of gray.
+	src := image.NewNRGBA(image.Rect(10, 10, 266, 42))
+	for y := 10; y < 42; y++ {
+		for x := 10; x < 266; x++ {
+			src.SetNRGBA(x, y, color.NRGBA{uint8(x - 10), uint8(x - 10), uint8(x - 10), 255})
+		}
+	}
+	blurred := Blur(src, 3)
+	testCases := []struct {
+		levels  int
+		mode    DitherMode
+		maxDiff float64
+	}{
+		{2, DitherOrdered, 4},
+		{2, DitherFloydSteinberg, 4},
+		{2, DitherAtkinson, 16},
+		{4, DitherOrdered, 2},
+		{4, DitherFloydSteinberg, 2},
+		{4, DitherAtkinson, 6},
+	}
+	for _, tc := range testCases {
+		palette := GrayPalette(tc.levels)
+		got := Dither(src, palette, tc.mode)
+		if got.Rect != image.Rect(0, 0, 256, 32) {
+			t.Fatalf("%d levels, mode %d: got bounds %v", tc.levels, tc.mode, got.Rect)
+		}
+		if d := meanAbsDiff(Blur(got, 3), blurred); d > tc.maxDiff {
+			t.Fatalf("%d levels, mode %d: got mean difference %.2f of the blurred images", tc.levels, tc.mode, d)
+		}
+		// The black and white ends stay clean.
+		for y := 0; y < 32; y++ {
+			if got.Pix[y*got.Stride] != 0 || got.Pix[y*got.Stride+255] != uint8(tc.levels-1) {
+				t.Fatalf("%d levels, mode %d: got %d and %d at the ends of row %d", tc.levels, tc.mode, got.Pix[y*got.Stride], got.Pix[y*got.Stride+255], y)
+			}
+		}
+	}
+
+	plain := Dither(src, GrayPalette(2), DitherNone)
+	for x := 0; x < 256; x++ {
+		if want := uint8(x / 128); plain.Pix[x] != want {
+			t.Fatalf("got index %d at %d, want %d", plain.Pix[x], x, want)
+		}
+	}
+}
+
+func TestDitherOrderedPattern(t *testing.T) {
+	// The 50% gray is the checkerboard of the 1-bit Bayer pattern.
+	src := New(16, 16, color.Gray{128})
+	got := Dither(src, GrayPalette(2), DitherOrdered)
+	first := got.Pix[0]
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			if want := first ^ uint8((x+y)%2); got.Pix[y*got.Stride+x] != want {
+				t.Fatalf("got index %d at (%d, %d), want %d", got.Pix[y*got.Stride+x], x, y, want)
+			}
+		}
+	}
+}
+
+func TestDitherExactColors(t *testing.T) {
+	palette := color.Palette{
+		color.NRGBA{255, 0, 0, 255},
+		color.NRGBA{0, 128, 0, 255},
+		color.NRGBA{10, 20, 30, 255},
+		color.NRGBA{},
+	}
+	src := image.NewNRGBA(image.Rect(0, 0, 20, 10))
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 20; x++ {
+			src.Set(x, y, palette[(x/4+y)%len(palette)])
+		}
+	}
+	for _, mode := range []DitherMode{DitherNone, DitherOrdered, DitherFloydSteinberg, DitherAtkinson} {
+		got := Dither(src, palette, mode)
+		if !Equal(got, src) {
+			t.Fatalf("mode %d: the colors changed", mode)
+		}
+	}
+}
+
+func TestDitherEmpty(t *testing.T) {
+	got := Dither(&image.NRGBA{}, GrayPalette(2), DitherAtkinson)
+	if !got.Rect.Empty() {
+		t.Fatalf("got bounds %v", got.Rect)
+	}
+	got = Dither(testdataBranchesPNG, nil, DitherOrdered)
+	if len(got.Palette) != 1 || got.Rect.Size() != testdataBranchesPNG.Bounds().Size() {
+		t.Fatalf("got bounds %v and palette %v", got.Rect, got.Palette)
+	}
+	large := make(color.Palette, 300)
+	for i := range large {
+		large[i] = color.Gray{uint8(i)}
+	}
+	if got = Dither(testdataBranchesPNG, large, DitherNone); len(got.Palette) != 256 {
+		t.Fatalf("got %d colors", len(got.Palette))
+	}
+}
@@ -0,0 +1,253 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sort"
+)
+
+// The drawing functions draw directly on the dst image using anti-aliasing and alpha blending.
+// The coordinates are in the dst image coordinate space, an integer point refers to the
+// center of the pixel, so a line of width 1 between two points covers whole pixels.
+
+// DrawLine draws a line segment of the given width between the points p1 and p2.
+//
+// Example:
+//
+//	imaging.DrawLine(img, image.Pt(10, 10), image.Pt(100, 50), 2, color.NRGBA{255, 0, 0, 255})
+//
+func DrawLine(dst *image.NRGBA, p1, p2 image.Point, width float64, c color.Color) {
+	if width <= 0 {
+		return
+	}
+	s := newSegment(p1, p2)
+	hw := width / 2
+	bounds := s.bounds(hw)
+	drawCoverage(dst, bounds, c, func(x, y float64) float64 {
+		return hw + 0.5 - s.dist(x, y)
+	})
+}
+
+// DrawRect draws the outline of the rectangle with lines of the given width.
+// The outline is drawn inside the rectangle, so the pixels outside of it are not changed.
+//
+// Example:
+//
+//	// Draw a bounding box.
+//	imaging.DrawRect(img, image.Rect(40, 30, 120, 90), 2, color.NRGBA{0, 255, 0, 255})
+//
+func DrawRect(dst *image.NRGBA, r image.Rectangle, width float64, c color.Color) {
+	r = r.Canon()
+	if width <= 0 || r.Empty() {
+		return
+	}
+	// The pixels from r.Min to r.Max-1 are covered, the box is given in pixel edge coordinates.
+	outer := [4]float64{float64(r.Min.X) - 0.5, float64(r.Min.Y) - 0.5, float64(r.Max.X) - 0.5, float64(r.Max.Y) - 0.5}
+	inner := [4]float64{outer[0] + width, outer[1] + width, outer[2] - width, outer[3] - width}
+	drawCoverage(dst, r, c, func(x, y float64) float64 {
+		return boxCoverage(outer, x, y) - boxCoverage(inner, x, y)
+	})
+}
+
+// boxCoverage returns the area of the intersection of the box {x1, y1, x2, y2}
+// and the pixel with the center at (x, y).
+func boxCoverage(box [4]float64, x, y float64) float64 {
+	w := math.Min(box[2], x+0.5) - math.Max(box[0], x-0.5)
+	h := math.Min(box[3], y+0.5) - math.Max(box[1], y-0.5)
+	if w <= 0 || h <= 0 {
+		return 0
+	}
+	return w * h
+}
+
+// DrawCircle draws the outline of the circle with the given center and radius
+// with a line of the given width.
+//
+// Example:
+//
+//	imaging.DrawCircle(img, image.Pt(64, 64), 30, 1.5, color.White)
+//
+func DrawCircle(dst *image.NRGBA, center image.Point, radius, width float64, c color.Color) {
+	if width <= 0 || radius < 0 {
+		return
+	}
+	hw := width / 2
+	cx, cy := float64(center.X), float64(center.Y)
+	n := int(math.Ceil(radius + hw + 1))
+	bounds := image.Rect(center.X-n, center.Y-n, center.X+n+1, center.Y+n+1)
+	drawCoverage(dst, bounds, c, func(x, y float64) float64 {
+		return hw + 0.5 - math.Abs(math.Hypot(x-cx, y-cy)-radius)
+	})
+}
+
+// DrawPolygon draws the outline of the closed polygon with the given vertices
+// with lines of the given width.
+//
+// Example:
+//
+//	imaging.DrawPolygon(img, []image.Point{{10, 10}, {50, 10}, {30, 40}}, 1, color.Black)
+//
+func DrawPolygon(dst *image.NRGBA, points []image.Point, width float64, c color.Color) {
+	if width <= 0 || len(points) == 0 {
+		return
+	}
+	hw := width / 2
+	segments := make([]segment, len(points))
+	var bounds image.Rectangle
+	for i, p := range points {
+		segments[i] = newSegment(p, points[(i+1)%len(points)])
+		bounds = bounds.Union(segments[i].bounds(hw))
+	}
+	drawCoverage(dst, bounds, c, func(x, y float64) float64 {
+		// Using the nearest segment avoids blending the joints twice.
+		d := math.Inf(1)
+		for _, s := range segments {
+			d = math.Min(d, s.dist(x, y))
+		}
+		return hw + 0.5 - d
+	})
+}
+
+// FillPolygon fills the closed polygon with the given vertices. The pixels covered
+// by the polygon partially are blended with the color proportionally to the covered area.
+// Self-intersecting polygons are filled using the even-odd rule.
+//
+// Example:
+//
+//	imaging.FillPolygon(img, []image.Point{{10, 10}, {50, 10}, {30, 40}}, color.NRGBA{255, 0, 0, 128})
+//
+func FillPolygon(dst *image.NRGBA, points []image.Point, c color.Color) {
+	if len(points) < 3 {
+		return
+	}
+	var bounds image.Rectangle
+	for _, p := range points {
+		bounds = bounds.Union(image.Rect(p.X, p.Y, p.X+1, p.Y+1))
+	}
+	bounds = bounds.Intersect(dst.Rect)
+	if bounds.Empty() {
+		return
+	}
+	col := color.NRGBAModel.Convert(c).(color.NRGBA)
+
+	// The coverage is computed on a number of sub-scanlines per pixel row.
+	// Along the sub-scanlines the exact covered length is used.
+	const subsamples = 4
+	parallel(bounds.Min.Y, bounds.Max.Y, (bounds.Dx()+len(points))*subsamples*costPoint, func(ys <-chan int) {
+		coverage := make([]float64, bounds.Dx())
+		var xs []float64
+		for y := range ys {
+			for i := range coverage {
+				coverage[i] = 0
+			}
+			for k := 0; k < subsamples; k++ {
+				// Pixel centers are at integer coordinates, so the row spans y-0.5..y+0.5.
+				sy := float64(y) - 0.5 + (float64(k)+0.5)/subsamples
+				xs = polygonIntersections(xs[:0], points, sy)
+				for i := 0; i+1 < len(xs); i += 2 {
+					addSpan(coverage, float64(bounds.Min.X), xs[i], xs[i+1], 1.0/subsamples)
+				}
+			}
+			for i, cov := range coverage {
+				blendPixel(dst, bounds.Min.X+i, y, col, cov)
+			}
+		}
+	})
+}
+
+// polygonIntersections appends the sorted x coordinates of the intersections of the polygon
+// edges with the horizontal line at y to xs. The pixel edge coordinates are used, so the
+// returned values are shifted by 0.5 relative to the pixel centers.
+func polygonIntersections(xs []float64, points []image.Point, y float64) []float64 {
+	for i, p1 := range points {
+		p2 := points[(i+1)%len(points)]
+		y1, y2 := float64(p1.Y), float64(p2.Y)
+		if (y1 <= y) == (y2 <= y) {
+			continue
+		}
+		x1, x2 := float64(p1.X), float64(p2.X)
+		xs = append(xs, x1+(y-y1)*(x2-x1)/(y2-y1)+0.5)
+	}
+	sort.Float64s(xs)
+	return xs
+}
+
+// addSpan adds the weighted coverage of the span [x1, x2) to the pixels of the row
+// starting at x0. The span is given in pixel edge coordinates.
+func addSpan(coverage []float64, x0, x1, x2, weight float64) {
+	x1 = math.Max(x1-x0, 0)
+	x2 = math.Min(x2-x0, float64(len(coverage)))
+	for x1 < x2 {
+		i := int(x1)
+		next := math.Min(float64(i+1), x2)
+		coverage[i] += (next - x1) * weight
+		x1 = next
+	}
+}
+
+// segment is a line segment used for the distance calculations.
+type segment struct {
+	x1, y1, dx, dy, len2 float64
+}
+
+func newSegment(p1, p2 image.Point) segment {
+	dx := float64(p2.X - p1.X)
+	dy := float64(p2.Y - p1.Y)
+	return segment{x1: float64(p1.X), y1: float64(p1.Y), dx: dx, dy: dy, len2: dx*dx + dy*dy}
+}
+
+// dist returns the distance from the point (x, y) to the segment.
+func (s segment) dist(x, y float64) float64 {
+	t := 0.0
+	if s.len2 > 0 {
+		t = math.Max(0, math.Min(1, ((x-s.x1)*s.dx+(y-s.y1)*s.dy)/s.len2))
+	}
+	return math.Hypot(x-(s.x1+t*s.dx), y-(s.y1+t*s.dy))
+}
+
+// bounds returns the pixels that may be covered by the segment drawn with the half-width hw.
+func (s segment) bounds(hw float64) image.Rectangle {
+	n := int(math.Ceil(hw + 1))
+	r := image.Rect(int(s.x1), int(s.y1), int(s.x1+s.dx), int(s.y1+s.dy)).Canon()
+	return image.Rect(r.Min.X-n, r.Min.Y-n, r.Max.X+n+1, r.Max.Y+n+1)
+}
+
+// drawCoverage blends the color c into the pixels of dst within the bounds, using the value
+// of the coverage function at the pixel centers as the opacity.
+func drawCoverage(dst *image.NRGBA, bounds image.Rectangle, c color.Color, coverage func(x, y float64) float64) {
+	bounds = bounds.Intersect(dst.Rect)
+	if bounds.Empty() {
+		return
+	}
+	col := color.NRGBAModel.Convert(c).(color.NRGBA)
+	parallel(bounds.Min.Y, bounds.Max.Y, bounds.Dx()*costHeavy, func(ys <-chan int) {
+		for y := range ys {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				blendPixel(dst, x, y, col, coverage(float64(x), float64(y)))
+			}
+		}
+	})
+}
+
+// blendPixel draws the color c over the pixel (x, y) of dst with the given coverage.
+func blendPixel(dst *image.NRGBA, x, y int, c color.NRGBA, coverage float64) {
+	if coverage <= 0 {
+		return
+	}
+	if coverage > 1 {
+		coverage = 1
+	}
+	i := dst.PixOffset(x, y)
+	d := dst.Pix[i : i+4 : i+4]
+	as := float64(c.A) / 255 * coverage
+	ab := float64(d[3]) / 255
+	ao := as + ab*(1-as)
+	if ao == 0 {
+		return
+	}
+	d[0] = clamp((as*float64(c.R) + (1-as)*ab*float64(d[0])) / ao)
+	d[1] = clamp((as*float64(c.G) + (1-as)*ab*float64(d[1])) / ao)
+	d[2] = clamp((as*float64(c.B) + (1-as)*ab*float64(d[2])) / ao)
+	d[3] = clamp(ao * 255)
+}
@@ -0,0 +1,167 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// alphaMask returns the alpha channel of the image as rows of values.
+func alphaMask(img *image.NRGBA) [][]uint8 {
+	rows := make([][]uint8, img.Rect.Dy())
+	for y := range rows {
+		for x := 0; x < img.Rect.Dx(); x++ {
+			rows[y] = append(rows[y], img.Pix[y*img.Stride+x*4+3])
+		}
+	}
+	return rows
+}
+
+func compareAlpha(img *image.NRGBA, want [][]uint8, delta int) bool {
+	got := alphaMask(img)
+	if len(got) != len(want) {
+		return false
+	}
+	for y := range got {
+		if !compareBytes(got[y], want[y], delta) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDrawLine(t *testing.T) {
+	dst := New(5, 3, color.Transparent)
+	DrawLine(dst, image.Pt(1, 1), image.Pt(3, 1), 1, color.Black)
+	want := [][]uint8{
+		{0x00, 0x00, 0x00, 0x00, 0x00},
+		{0x00, 0xff, 0xff, 0xff, 0x00},
+		{0x00, 0x00, 0x00, 0x00, 0x00},
+	}
+	if !compareAlpha(dst, want, 0) {
+		t.Fatalf("got alpha %v want %v", alphaMask(dst), want)
+	}
+	for _, p := range [][2]int{{1, 1}, {2, 1}, {3, 1}} {
+		if got := dst.NRGBAAt(p[0], p[1]); got != (color.NRGBA{0, 0, 0, 0xff}) {
+			t.Fatalf("pixel %v: got %v want black", p, got)
+		}
+	}
+
+	// Anti-aliased diagonal line.
+	dst = New(5, 5, color.Transparent)
+	DrawLine(dst, image.Pt(0, 0), image.Pt(4, 4), 1, color.Black)
+	for i := 0; i < 5; i++ {
+		if a := dst.NRGBAAt(i, i).A; a != 0xff {
+			t.Fatalf("pixel (%d, %d): got alpha %d want 255", i, i, a)
+		}
+	}
+	if a := dst.NRGBAAt(1, 0).A; a == 0 || a == 0xff {
+		t.Fatalf("pixel (1, 0): got alpha %d want partial coverage", a)
+	}
+	if a := dst.NRGBAAt(4, 0).A; a != 0 {
+		t.Fatalf("pixel (4, 0): got alpha %d want 0", a)
+	}
+
+	// Drawing outside of the image and with zero width doesn't panic or change anything.
+	dst = New(2, 2, color.Transparent)
+	DrawLine(dst, image.Pt(-10, -10), image.Pt(-5, -5), 1, color.Black)
+	DrawLine(dst, image.Pt(0, 0), image.Pt(1, 1), 0, color.Black)
+	if !compareNRGBA(dst, New(2, 2, color.Transparent), 0) {
+		t.Fatalf("image changed: %#v", dst)
+	}
+}
+
+func TestDrawRect(t *testing.T) {
+	dst := New(6, 5, color.Transparent)
+	DrawRect(dst, image.Rect(1, 1, 5, 5), 1, color.NRGBA{0xff, 0, 0, 0xff})
+	want := [][]uint8{
+		{0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		{0x00, 0xff, 0xff, 0xff, 0xff, 0x00},
+		{0x00, 0xff, 0x00, 0x00, 0xff, 0x00},
+		{0x00, 0xff, 0x00, 0x00, 0xff, 0x00},
+		{0x00, 0xff, 0xff, 0xff, 0xff, 0x00},
+	}
+	if !compareAlpha(dst, want, 0) {
+		t.Fatalf("got alpha %v want %v", alphaMask(dst), want)
+	}
+
+	dst = New(4, 4, color.Transparent)
+	DrawRect(dst, image.Rect(0, 0, 4, 4), 0.5, color.Black)
+	want = [][]uint8{
+		{0xbf, 0x80, 0x80, 0xbf},
+		{0x80, 0x00, 0x00, 0x80},
+		{0x80, 0x00, 0x00, 0x80},
+		{0xbf, 0x80, 0x80, 0xbf},
+	}
+	if !compareAlpha(dst, want, 1) {
+		t.Fatalf("got alpha %v want %v", alphaMask(dst), want)
+	}
+}
+
+func TestDrawCircle(t *testing.T) {
+	dst := New(11, 11, color.Transparent)
+	DrawCircle(dst, image.Pt(5, 5), 4, 1, color.Black)
+	for _, p := range []image.Point{{1, 5}, {9, 5}, {5, 1}, {5, 9}} {
+		if a := dst.NRGBAAt(p.X, p.Y).A; a != 0xff {
+			t.Fatalf("pixel %v: got alpha %d want 255", p, a)
+		}
+	}
+	for _, p := range []image.Point{{5, 5}, {0, 0}, {3, 5}} {
+		if a := dst.NRGBAAt(p.X, p.Y).A; a != 0 {
+			t.Fatalf("pixel %v: got alpha %d want 0", p, a)
+		}
+	}
+}
+
+func TestDrawPolygon(t *testing.T) {
+	dst := New(6, 5, color.Transparent)
+	DrawPolygon(dst, []image.Point{{1, 1}, {4, 1}, {4, 4}, {1, 4}}, 1, color.NRGBA{0, 0, 0, 0x80})
+	want := [][]uint8{
+		{0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		{0x00, 0x80, 0x80, 0x80, 0x80, 0x00},
+		{0x00, 0x80, 0x00, 0x00, 0x80, 0x00},
+		{0x00, 0x80, 0x00, 0x00, 0x80, 0x00},
+		{0x00, 0x80, 0x80, 0x80, 0x80, 0x00},
+	}
+	// The joints are not blended twice.
+	if !compareAlpha(dst, want, 1) {
+		t.Fatalf("got alpha %v want %v", alphaMask(dst), want)
+	}
+}
+
+func TestFillPolygon(t *testing.T) {
+	dst := New(6, 6, color.Transparent)
+	FillPolygon(dst, []image.Point{{1, 1}, {4, 1}, {4, 4}, {1, 4}}, color.Black)
+	want := [][]uint8{
+		{0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		{0x00, 0x40, 0x80, 0x80, 0x40, 0x00},
+		{0x00, 0x80, 0xff, 0xff, 0x80, 0x00},
+		{0x00, 0x80, 0xff, 0xff, 0x80, 0x00},
+		{0x00, 0x40, 0x80, 0x80, 0x40, 0x00},
+		{0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	}
+	if !compareAlpha(dst, want, 1) {
+		t.Fatalf("got alpha %v want %v", alphaMask(dst), want)
+	}
+
+	// Triangle: the area is preserved.
+	dst = New(20, 20, color.Transparent)
+	FillPolygon(dst, []image.Point{{2, 2}, {18, 2}, {2, 18}}, color.Black)
+	var sum float64
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 20; x++ {
+			sum += float64(dst.NRGBAAt(x, y).A) / 255
+		}
+	}
+	if sum < 127 || sum > 129 {
+		t.Fatalf("got covered area %v want 128", sum)
+	}
+
+	// Degenerate polygons.
+	dst = New(4, 4, color.Transparent)
+	FillPolygon(dst, []image.Point{{0, 0}, {3, 3}}, color.Black)
+	FillPolygon(dst, []image.Point{{-10, -10}, {-5, -10}, {-5, -5}}, color.Black)
+	if !compareNRGBA(dst, New(4, 4, color.Transparent), 0) {
+		t.Fatalf("image changed: %#v", dst)
+	}
+}
@@ -0,0 +1,245 @@
+package imaging
+
+import (
+	"image"
+	"math"
+)
+
+// GradientOperator is the pair of 3x3 kernels used to compute the image gradient.
+type GradientOperator int
+
+// Gradient operators.
+const (
+	// SobelOperator is the Sobel operator with the [1 2 1] smoothing across the derivative.
+	SobelOperator GradientOperator = iota
+	// ScharrOperator is the Scharr operator with the [3 10 3] smoothing. It's less
+	// sensitive to the direction of the edges than the Sobel operator.
+	ScharrOperator
+)
+
+// Sobel returns the edge map of the image: the magnitude of the luminance gradient computed
+// with the Sobel operator, as an opaque grayscale image. A sharp step of luminance by d
+// gives an edge of brightness d, the flat areas are black. The transparent pixels
+// are treated as white.
+//
+// Example:
+//
+//	edges := imaging.Sobel(srcImage)
+//
+func Sobel(img image.Image) *image.NRGBA {
+	return gradientImage(img, SobelOperator)
+}
+
+// Scharr is like Sobel but uses the Scharr operator, which measures the diagonal edges
+// more accurately.
+//
+// Example:
+//
+//	edges := imaging.Scharr(srcImage)
+//
+func Scharr(img image.Image) *image.NRGBA {
+	return gradientImage(img, ScharrOperator)
+}
+
+// GradientMagnitude returns the magnitudes of the luminance gradient of the image computed
+// with the operator, in the same units as Sobel but not limited to 255, in row-major order:
+// the value of the pixel (x, y) relative to the image bounds is at index y*width+x.
+// It's useful to measure the sharpness or the amount of detail of the image regions.
+//
+// Example:
+//
+//	mag := imaging.GradientMagnitude(srcImage, imaging.SobelOperator)
+//	var sharpness float64
+//	for _, m := range mag {
+//		sharpness += m * m
+//	}
+//
+func GradientMagnitude(img image.Image, op GradientOperator) []float64 {
+	lum, w, h := luminanceFloat(img)
+	gx, gy := gradient(lum, w, h, op)
+	for i := range gx {
+		gx[i] = math.Hypot(gx[i], gy[i])
+	}
+	return gx
+}
+
+// Canny returns the edges of the image found by the Canny edge detector as white lines
+// one pixel wide on black. The luminance is smoothed by a 5x5 Gaussian-like filter, and
+// the local maxima of the Sobel gradient magnitude (in the units of Sobel) are kept if they
+// are at least highThresh, or at least lowThresh and connected to the former. The typical
+// thresholds are 20 and 50, the lower values find the fainter edges. The transparent
+// pixels are treated as white.
+//
+// Example:
+//
+//	edges := imaging.Canny(srcImage, 20, 50)
+//
+func Canny(img image.Image, lowThresh, highThresh float64) *image.NRGBA {
+	lum, w, h := luminanceFloat(img)
+	if w <= 0 || h <= 0 {
+		return &image.NRGBA{}
+	}
+	if lowThresh > highThresh {
+		lowThresh, highThresh = highThresh, lowThresh
+	}
+
+	gx, gy := gradient(smoothPlane(lum, w, h), w, h, SobelOperator)
+	mag := make([]float64, w*h)
+	for i := range mag {
+		mag[i] = math.Hypot(gx[i], gy[i])
+	}
+
+	// Keep the local maxima of the magnitude along the direction of the gradient.
+	tan22 := math.Tan(math.Pi / 8)
+	edges := make([]uint8, w*h) // 0 is no edge, 1 is a weak edge and 2 is a strong one.
+	parallel(0, h, w*costPoint, func(ys <-chan int) {
+		for y := range ys {
+			for x := 0; x < w; x++ {
+				i := y*w + x
+				m := mag[i]
+				if m < lowThresh || m == 0 {
+					continue
+				}
+				ax, ay := math.Abs(gx[i]), math.Abs(gy[i])
+				var dx, dy int
+				switch {
+				case ay <= ax*tan22:
+					dx = 1
+				case ax <= ay*tan22:
+					dy = 1
+				case (gx[i] > 0) == (gy[i] > 0):
+					dx, dy = 1, 1
+				default:
+					dx, dy = 1, -1
+				}
+				// The ties are broken to one side, so the plateaus stay one pixel wide.
+				if m <= magAt(mag, w, h, x-dx, y-dy) || m < magAt(mag, w, h, x+dx, y+dy) {
+					continue
+				}
+				edges[i] = 1
+				if m >= highThresh {
+					edges[i] = 2
+				}
+			}
+		}
+	})
+
+	// Hysteresis: the weak edges connected to the strong ones become strong.
+	var stack []int
+	for i, e := range edges {
+		if e == 2 {
+			stack = append(stack, i)
+		}
+	}
+	for len(stack) > 0 {
+		i := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		x, y := i%w, i/w
+		for ny := max(y-1, 0); ny <= min(y+1, h-1); ny++ {
+			for nx := max(x-1, 0); nx <= min(x+1, w-1); nx++ {
+				if j := ny*w + nx; edges[j] == 1 {
+					edges[j] = 2
+					stack = append(stack, j)
+				}
+			}
+		}
+	}
+	for i, e := range edges {
+		if e == 2 {
+			edges[i] = 255
+		} else {
+			edges[i] = 0
+		}
+	}
+	return grayPlaneToNRGBA(edges, w, h)
+}
+
+// magAt returns the magnitude at (x, y), or 0 outside the plane.
+func magAt(mag []float64, w, h, x, y int) float64 {
+	if x < 0 || x >= w || y < 0 || y >= h {
+		return 0
+	}
+	return mag[y*w+x]
+}
+
+// gradientImage returns the gradient magnitude of the image clamped to [0, 255].
+func gradientImage(img image.Image, op GradientOperator) *image.NRGBA {
+	mag := GradientMagnitude(img, op)
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	if len(mag) == 0 {
+		return &image.NRGBA{}
+	}
+	plane := make([]uint8, len(mag))
+	for i, m := range mag {
+		plane[i] = clamp(m)
+	}
+	return grayPlaneToNRGBA(plane, w, h)
+}
+
+// luminanceFloat returns the luminance plane of the image as float64 values.
+func luminanceFloat(img image.Image) ([]float64, int, int) {
+	lum, w, h := luminancePlane(img)
+	plane := make([]float64, len(lum))
+	for i, v := range lum {
+		plane[i] = float64(v)
+	}
+	return plane, w, h
+}
+
+// gradient returns the horizontal and the vertical derivatives of the plane computed with
+// the operator, normalized so that a step by d gives the derivative d. The pixels outside
+// the plane repeat the edge pixels.
+func gradient(plane []float64, w, h int, op GradientOperator) ([]float64, []float64) {
+	side, center := 1.0, 2.0
+	if op == ScharrOperator {
+		side, center = 3, 10
+	}
+	norm := 1 / (2*side + center)
+	gx := make([]float64, w*h)
+	gy := make([]float64, w*h)
+	parallel(0, h, w*12*costTap, func(ys <-chan int) {
+		for y := range ys {
+			up := plane[edgeIndex(y-1, h, EdgeClamp)*w:][:w]
+			mid := plane[y*w:][:w]
+			down := plane[edgeIndex(y+1, h, EdgeClamp)*w:][:w]
+			for x := 0; x < w; x++ {
+				l, r := edgeIndex(x-1, w, EdgeClamp), edgeIndex(x+1, w, EdgeClamp)
+				gx[y*w+x] = (side*(up[r]-up[l]) + center*(mid[r]-mid[l]) + side*(down[r]-down[l])) * norm
+				gy[y*w+x] = (side*(down[l]-up[l]) + center*(down[x]-up[x]) + side*(down[r]-up[r])) * norm
+			}
+		}
+	})
+	return gx, gy
+}
+
+// smoothPlane returns the plane convolved with the separable 5x5 binomial filter,
+// which approximates the Gaussian blur with sigma 1.
+func smoothPlane(plane []float64, w, h int) []float64 {
+	k := [5]float64{1.0 / 16, 4.0 / 16, 6.0 / 16, 4.0 / 16, 1.0 / 16}
+	tmp := make([]float64, w*h)
+	parallel(0, h, w*5*costTap, func(ys <-chan int) {
+		for y := range ys {
+			row := plane[y*w:][:w]
+			for x := 0; x < w; x++ {
+				var s float64
+				for i, c := range k {
+					s += c * row[edgeIndex(x+i-2, w, EdgeClamp)]
+				}
+				tmp[y*w+x] = s
+			}
+		}
+	})
+	dst := make([]float64, w*h)
+	parallel(0, h, w*5*costTap, func(ys <-chan int) {
+		for y := range ys {
+			for x := 0; x < w; x++ {
+				var s float64
+				for i, c := range k {
+					s += c * tmp[edgeIndex(y+i-2, h, EdgeClamp)*w+x]
+				}
+				dst[y*w+x] = s
+			}
+		}
+	})
+	return dst
+}
@@ -0,0 +1,172 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// stepImage returns an image with the dark left half and the light right half.
+func stepImage(w, h int, dark, light uint8) *image.NRGBA {
+	img := New(w, h, color.Gray{dark})
+	for y := 0; y < h; y++ {
+		for x := w / 2; x < w; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{light, light, light, 255})
+		}
+	}
+	return img
+}
+
+func TestSobel(t *testing.T) {
+	src := stepImage(10, 4, 50, 150)
+	for _, fn := range []func(image.Image) *image.NRGBA{Sobel, Scharr} {
+		got := fn(src)
+		if got.Rect != src.Rect {
+			t.Fatalf("got bounds %v", got.Rect)
+		}
+		for y := 0; y < 4; y++ {
+			for x := 0; x < 10; x++ {
+				want := uint8(0)
+				if x == 4 || x == 5 {
+					want = 100
+				}
+				if p := got.Pix[y*got.Stride+x*4:]; p[0] != want || p[3] != 255 {
+					t.Fatalf("got %v at (%d, %d), want %d", p[:4], x, y, want)
+				}
+			}
+		}
+	}
+	// The magnitude is clamped to 255.
+	if got := Sobel(stepImage(4, 4, 0, 255)); got.Pix[1*4] != 255 {
+		t.Fatalf("got %d", got.Pix[1*4])
+	}
+	if got := Sobel(&image.NRGBA{}); !got.Rect.Empty() {
+		t.Fatalf("got bounds %v", got.Rect)
+	}
+}
+
+func TestGradientMagnitude(t *testing.T) {
+	// The diagonal ramp with the slope of 10 along each axis.
+	src := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			v := uint8(10 * (x + y))
+			src.SetNRGBA(x, y, color.NRGBA{v, v, v, 255})
+		}
+	}
+	for _, op := range []GradientOperator{SobelOperator, ScharrOperator} {
+		mag := GradientMagnitude(src, op)
+		if len(mag) != 64 {
+			t.Fatalf("got %d values", len(mag))
+		}
+		// Inside the image the derivatives are 20, the difference of the neighbors on both sides.
+		if got, want := mag[3*8+3], math.Hypot(20, 20); math.Abs(got-want) > 1e-9 {
+			t.Fatalf("operator %d: got %v, want %v", op, got, want)
+		}
+	}
+	if mag := GradientMagnitude(New(5, 5, color.White), SobelOperator); mag[12] != 0 {
+		t.Fatalf("got %v for a flat image", mag[12])
+	}
+}
+
+func TestCanny(t *testing.T) {
+	// A light rectangle on a dark background.
+	src := New(40, 30, color.Gray{40})
+	for y := 10; y < 20; y++ {
+		for x := 10; x < 30; x++ {
+			src.SetNRGBA(x, y, color.NRGBA{200, 200, 200, 255})
+		}
+	}
+	got := Canny(src, 20, 50)
+	if got.Rect != src.Rect {
+		t.Fatalf("got bounds %v", got.Rect)
+	}
+	at := func(x, y int) bool { return got.Pix[y*got.Stride+x*4] == 255 }
+	count := func(x0, y0, x1, y1 int) int {
+		n := 0
+		for y := y0; y < y1; y++ {
+			for x := x0; x < x1; x++ {
+				if at(x, y) {
+					n++
+				}
+			}
+		}
+		return n
+	}
+	// The vertical sides are one pixel wide.
+	for y := 12; y < 18; y++ {
+		if n := count(0, y, 20, y+1); n != 1 {
+			t.Fatalf("got %d edge pixels at the left side in row %d", n, y)
+		}
+		if n := count(20, y, 40, y+1); n != 1 {
+			t.Fatalf("got %d edge pixels at the right side in row %d", n, y)
+		}
+	}
+	// The horizontal sides are one pixel wide.
+	for x := 12; x < 28; x++ {
+		if n := count(x, 0, x+1, 15); n != 1 {
+			t.Fatalf("got %d edge pixels at the top side in column %d", n, x)
+		}
+	}
+	// No edges far from the rectangle.
+	if n := count(0, 0, 40, 6) + count(13, 13, 27, 17); n != 0 {
+		t.Fatalf("got %d edge pixels in the flat areas", n)
+	}
+
+	// The faint edges are found only with the low thresholds. The smoothed step by 15
+	// has the magnitude of about 9.4.
+	faint := stepImage(20, 10, 100, 115)
+	if n := countWhite(Canny(faint, 20, 50)); n != 0 {
+		t.Fatalf("got %d edge pixels of the faint edge", n)
+	}
+	if n := countWhite(Canny(faint, 5, 8)); n != 10 {
+		t.Fatalf("got %d edge pixels of the faint edge, want 10", n)
+	}
+	if got := Canny(&image.NRGBA{}, 20, 50); !got.Rect.Empty() {
+		t.Fatalf("got bounds %v", got.Rect)
+	}
+}
+
+func TestCannyHysteresis(t *testing.T) {
+	// The edge at x = 10 fades from strong on the top to weak on the bottom, and there is
+	// a separate weak edge at x = 20. The weak part of the first edge is kept as it's
+	// connected to the strong part, the separate weak edge is dropped.
+	src := New(30, 20, color.Gray{50})
+	for y := 0; y < 20; y++ {
+		step := 160 - 8*y
+		for x := 10; x < 30; x++ {
+			v := 50 + step
+			if x >= 20 {
+				v += 16
+			}
+			src.SetNRGBA(x, y, color.NRGBA{uint8(v), uint8(v), uint8(v), 255})
+		}
+	}
+	got := Canny(src, 5, 50)
+	for y := 2; y < 17; y++ {
+		var first, second int
+		for x := 0; x < 30; x++ {
+			if got.Pix[y*got.Stride+x*4] == 255 {
+				if x < 15 {
+					first++
+				} else {
+					second++
+				}
+			}
+		}
+		if first != 1 || second != 0 {
+			t.Fatalf("got %d and %d edge pixels in row %d, want 1 and 0", first, second, y)
+		}
+	}
+}
+
+func countWhite(img *image.NRGBA) int {
+	n := 0
+	for i := 0; i < len(img.Pix); i += 4 {
+		if img.Pix[i] == 255 {
+			n++
+		}
+	}
+	return n
+}
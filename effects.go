@@ -1,6 +1,7 @@
 package imaging
 
 import (
+	"context"
 	"image"
 	"math"
 )
@@ -17,8 +18,35 @@ func gaussianBlurKernel(x, sigma float64) float64 {
 //	dstImage := imaging.Blur(srcImage, 3.5)
 //
 func Blur(img image.Image, sigma float64) *image.NRGBA {
+	return blur(context.Background(), nil, img, sigma)
+}
+
+// BlurInto is like Blur but writes the result into dst, reusing its pixel buffer
+// if it's large enough, and returns dst. Like in ResizeInto, the intermediate image
+// is kept in the spare capacity of the pixel buffer. The dst image must not share
+// pixels with img.
+func BlurInto(dst *image.NRGBA, img image.Image, sigma float64) *image.NRGBA {
+	return blur(context.Background(), dst, img, sigma)
+}
+
+// BlurCtx is like Blur but stops processing and returns the context error
+// if the context is canceled or its deadline is exceeded before the blur is done.
+func BlurCtx(ctx context.Context, img image.Image, sigma float64) (*image.NRGBA, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ctx = beginProgress(ctx, blurWork(img, sigma))
+	dst := blur(ctx, nil, img, sigma)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	endProgress(ctx)
+	return dst, nil
+}
+
+func blur(ctx context.Context, dst *image.NRGBA, img image.Image, sigma float64) *image.NRGBA {
 	if sigma <= 0 {
-		return Clone(img)
+		return cloneInto(dst, img)
 	}
 
 	radius := int(math.Ceil(sigma * 3.0))
@@ -28,103 +56,159 @@ func Blur(img image.Image, sigma float64) *image.NRGBA {
 		kernel[i] = gaussianBlurKernel(float64(i), sigma)
 	}
 
-	return blurVertical(blurHorizontal(img, kernel), kernel)
+	b := img.Bounds()
+	dst, tmp := withScratch(dst, image.Rect(0, 0, b.Dx(), b.Dy()), image.Rect(0, 0, b.Dx(), b.Dy()))
+	return blurVertical(ctx, dst, blurHorizontal(ctx, tmp, img, kernel), kernel)
 }
 
-func blurHorizontal(img image.Image, kernel []float64) *image.NRGBA {
-	src := newScanner(img)
-	dst := image.NewNRGBA(image.Rect(0, 0, src.w, src.h))
+// blurWeights are the weights of the source pixels of a line of pixels blurred with a kernel.
+// The interior pixels, at least radius pixels away from the line ends, use the same weights
+// of the 2*radius+1 consecutive source pixels (see accumulateKernel), while the weights
+// of the edge pixels are clipped to the line.
+type blurWeights struct {
+	n, lo, hi int             // The interior pixels are [lo, hi).
+	full      []float64       // The weights of the source pixels x-radius..x+radius.
+	fullSum   float64         // The sum of the full weights.
+	edges     [][]indexWeight // The weights of the edge pixels [0, lo) and [hi, n).
+	edgeSums  []float64
+}
+
+func newBlurWeights(n int, kernel []float64) *blurWeights {
 	radius := len(kernel) - 1
+	bw := &blurWeights{n: n, lo: radius, hi: n - radius}
+	if bw.lo > n {
+		bw.lo = n
+	}
+	if bw.hi < bw.lo {
+		bw.hi = bw.lo
+	}
+	bw.full = make([]float64, 0, 2*radius+1)
+	for k := -radius; k <= radius; k++ {
+		bw.full = append(bw.full, kernel[absint(k)])
+		bw.fullSum += kernel[absint(k)]
+	}
+	// The weights of all the edge pixels share a single buffer.
+	edges := bw.lo + n - bw.hi
+	bw.edges = make([][]indexWeight, 0, edges)
+	bw.edgeSums = make([]float64, 0, edges)
+	taps := make([]indexWeight, 0, edges*len(bw.full))
+	for x := 0; x < n; x++ {
+		if x == bw.lo {
+			x = bw.hi
+			if x == n {
+				break
+			}
+		}
+		min := x - radius
+		if min < 0 {
+			min = 0
+		}
+		max := x + radius
+		if max > n-1 {
+			max = n - 1
+		}
+		start := len(taps)
+		var wsum float64
+		for i := min; i <= max; i++ {
+			taps = append(taps, indexWeight{index: i, weight: kernel[absint(x-i)]})
+			wsum += kernel[absint(x-i)]
+		}
+		bw.edges = append(bw.edges, taps[start:len(taps):len(taps)])
+		bw.edgeSums = append(bw.edgeSums, wsum)
+	}
+	return bw
+}
 
-	parallel(0, src.h, func(ys <-chan int) {
+// blurLine blurs the line of pixels in src, using acc of len(src) values for the sums,
+// and writes the x-th pixel to dst[x*step:]. The pixels with zero alpha sum are left unchanged.
+func (bw *blurWeights) blurLine(dst []uint8, step int, src []uint8, acc []float64) {
+	lo, hi, n := bw.lo, bw.hi, bw.n
+	accumulateLine(acc[:lo*4], src, bw.edges[:lo])
+	accumulateKernel(acc[lo*4:hi*4], src, bw.full)
+	accumulateLine(acc[hi*4:n*4], src, bw.edges[lo:])
+	for x := 0; x < n; x++ {
+		s := acc[x*4 : x*4+4 : x*4+4]
+		a := s[3]
+		if a == 0 {
+			continue
+		}
+		wsum := bw.fullSum
+		if x < lo {
+			wsum = bw.edgeSums[x]
+		} else if x >= hi {
+			wsum = bw.edgeSums[x-hi+lo]
+		}
+		aInv := 1 / a
+		d := dst[x*step : x*step+4 : x*step+4]
+		d[0] = clamp(s[0] * aInv)
+		d[1] = clamp(s[1] * aInv)
+		d[2] = clamp(s[2] * aInv)
+		d[3] = clamp(a / wsum)
+	}
+}
+
+func blurHorizontal(ctx context.Context, dst *image.NRGBA, img image.Image, kernel []float64) *image.NRGBA {
+	src := newScanner(img)
+	dst = newNRGBA(dst, image.Rect(0, 0, src.w, src.h))
+	weights := newBlurWeights(src.w, kernel)
+
+	parallelCtx(ctx, 0, src.h, src.w*(2*len(kernel)-1)*costTap, func(ys <-chan int) {
 		scanLine := make([]uint8, src.w*4)
-		scanLineF := make([]float64, len(scanLine))
+		acc := make([]float64, len(scanLine))
 		for y := range ys {
 			src.scan(0, y, src.w, y+1, scanLine)
-			for i, v := range scanLine {
-				scanLineF[i] = float64(v)
-			}
-			for x := 0; x < src.w; x++ {
-				min := x - radius
-				if min < 0 {
-					min = 0
-				}
-				max := x + radius
-				if max > src.w-1 {
-					max = src.w - 1
-				}
-				var r, g, b, a, wsum float64
-				for ix := min; ix <= max; ix++ {
-					i := ix * 4
-					weight := kernel[absint(x-ix)]
-					wsum += weight
-					s := scanLineF[i : i+4 : i+4]
-					wa := s[3] * weight
-					r += s[0] * wa
-					g += s[1] * wa
-					b += s[2] * wa
-					a += wa
-				}
-				if a != 0 {
-					aInv := 1 / a
-					j := y*dst.Stride + x*4
-					d := dst.Pix[j : j+4 : j+4]
-					d[0] = clamp(r * aInv)
-					d[1] = clamp(g * aInv)
-					d[2] = clamp(b * aInv)
-					d[3] = clamp(a / wsum)
-				}
-			}
+			weights.blurLine(dst.Pix[y*dst.Stride:], 4, scanLine, acc)
 		}
 	})
 
 	return dst
 }
 
-func blurVertical(img image.Image, kernel []float64) *image.NRGBA {
+// blurStripWidth is the number of columns processed together by blurVertical.
+// Processing strips of columns instead of single columns keeps the memory accesses
+// sequential, which makes a big difference for large images.
+const blurStripWidth = 16
+
+func blurVertical(ctx context.Context, dst *image.NRGBA, img image.Image, kernel []float64) *image.NRGBA {
 	src := newScanner(img)
-	dst := image.NewNRGBA(image.Rect(0, 0, src.w, src.h))
-	radius := len(kernel) - 1
+	dst = newNRGBA(dst, image.Rect(0, 0, src.w, src.h))
+	strips := (src.w + blurStripWidth - 1) / blurStripWidth
+	weights := newBlurWeights(src.h, kernel)
 
-	parallel(0, src.w, func(xs <-chan int) {
-		scanLine := make([]uint8, src.h*4)
-		scanLineF := make([]float64, len(scanLine))
-		for x := range xs {
-			src.scan(x, 0, x+1, src.h, scanLine)
-			for i, v := range scanLine {
-				scanLineF[i] = float64(v)
+	parallelCtx(ctx, 0, strips, blurStripWidth*src.h*(2*len(kernel)-1)*costTap, func(ss <-chan int) {
+		// The strip is read and written row by row, while the columns
+		// are blurred using a transposed copy of the strip.
+		strip := make([]uint8, blurStripWidth*src.h*4)
+		columns := make([]uint8, len(strip))
+		acc := make([]float64, src.h*4)
+		for s := range ss {
+			x1 := s * blurStripWidth
+			x2 := x1 + blurStripWidth
+			if x2 > src.w {
+				x2 = src.w
 			}
+			sw := x2 - x1
+			src.scan(x1, 0, x2, src.h, strip[:sw*src.h*4])
 			for y := 0; y < src.h; y++ {
-				min := y - radius
-				if min < 0 {
-					min = 0
-				}
-				max := y + radius
-				if max > src.h-1 {
-					max = src.h - 1
-				}
-				var r, g, b, a, wsum float64
-				for iy := min; iy <= max; iy++ {
-					i := iy * 4
-					weight := kernel[absint(y-iy)]
-					wsum += weight
-					s := scanLineF[i : i+4 : i+4]
-					wa := s[3] * weight
-					r += s[0] * wa
-					g += s[1] * wa
-					b += s[2] * wa
-					a += wa
-				}
-				if a != 0 {
-					aInv := 1 / a
-					j := y*dst.Stride + x*4
-					d := dst.Pix[j : j+4 : j+4]
-					d[0] = clamp(r * aInv)
-					d[1] = clamp(g * aInv)
-					d[2] = clamp(b * aInv)
-					d[3] = clamp(a / wsum)
+				for x := 0; x < sw; x++ {
+					i := (y*sw + x) * 4
+					j := (x*src.h + y) * 4
+					copy(columns[j:j+4], strip[i:i+4])
 				}
 			}
+			for i := range strip {
+				strip[i] = 0
+			}
+
+			for x := 0; x < sw; x++ {
+				column := columns[x*src.h*4 : (x+1)*src.h*4]
+				weights.blurLine(strip[x*4:], sw*4, column, acc)
+			}
+
+			for y := 0; y < src.h; y++ {
+				j := y*dst.Stride + x1*4
+				copy(dst.Pix[j:j+sw*4], strip[y*sw*4:(y+1)*sw*4])
+			}
 		}
 	})
 
@@ -133,21 +217,45 @@ func blurVertical(img image.Image, kernel []float64) *image.NRGBA {
 
 // Sharpen produces a sharpened version of the image.
 // Sigma parameter must be positive and indicates how much the image will be sharpened.
+// See UnsharpMask for the sharpening with the tunable strength.
 //
 // Example:
 //
 //	dstImage := imaging.Sharpen(srcImage, 3.5)
 //
 func Sharpen(img image.Image, sigma float64) *image.NRGBA {
+	return sharpen(context.Background(), img, sigma)
+}
+
+// SharpenCtx is like Sharpen but stops processing and returns the context error
+// if the context is canceled or its deadline is exceeded before the sharpening is done.
+func SharpenCtx(ctx context.Context, img image.Image, sigma float64) (*image.NRGBA, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	work := blurWork(img, sigma)
+	if work > 0 {
+		work += img.Bounds().Dy()
+	}
+	ctx = beginProgress(ctx, work)
+	dst := sharpen(ctx, img, sigma)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	endProgress(ctx)
+	return dst, nil
+}
+
+func sharpen(ctx context.Context, img image.Image, sigma float64) *image.NRGBA {
 	if sigma <= 0 {
 		return Clone(img)
 	}
 
 	src := newScanner(img)
 	dst := image.NewNRGBA(image.Rect(0, 0, src.w, src.h))
-	blurred := Blur(img, sigma)
+	blurred := blur(ctx, nil, img, sigma)
 
-	parallel(0, src.h, func(ys <-chan int) {
+	parallelCtx(ctx, 0, src.h, src.w*costPoint, func(ys <-chan int) {
 		scanLine := make([]uint8, src.w*4)
 		for y := range ys {
 			src.scan(0, y, src.w, y+1, scanLine)
@@ -167,3 +275,225 @@ func Sharpen(img image.Image, sigma float64) *image.NRGBA {
 
 	return dst
 }
+
+// UnsharpMask sharpens the image with the unsharp mask: the difference between the image
+// and its Gaussian blur with the given sigma is multiplied by amount and added to the image.
+// The sigma is the radius of the details that are sharpened, 0.5-1 for the downscaled
+// thumbnails and 1-3 for the large photos. The amount is the strength, e.g. 0.5 for a subtle
+// sharpening and 1.5 for a strong one (Sharpen uses the amount of 1). The pixels whose
+// luminance differs from the blurred one by less than the threshold, in 8-bit units,
+// aren't sharpened, so the low-contrast noise and the smooth skin aren't amplified,
+// e.g. 3-10 for the photos. The alpha channel isn't changed. If sigma or amount
+// is 0 or less, a copy of the image is returned.
+//
+// Example:
+//
+//	dstImage := imaging.UnsharpMask(imaging.Fit(srcImage, 400, 400, imaging.Lanczos), 0.7, 0.8, 4)
+//
+func UnsharpMask(img image.Image, sigma, amount, threshold float64) *image.NRGBA {
+	dst := Clone(img)
+	if sigma <= 0 || amount <= 0 {
+		return dst
+	}
+	unsharpMask(dst, sigma, amount, threshold)
+	return dst
+}
+
+// unsharpMask sharpens the color channels of the image in place adding the difference
+// between the image and its blurred version multiplied by amount. The pixels whose
+// luminance differs from the blurred one by less than threshold aren't changed.
+// The alpha channel isn't changed, so the edges of the transparent areas don't get halos.
+func unsharpMask(img *image.NRGBA, sigma, amount, threshold float64) {
+	blurred := blur(context.Background(), nil, img, sigma)
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	parallel(0, h, w*costPoint, func(ys <-chan int) {
+		for y := range ys {
+			for x := 0; x < w; x++ {
+				d := img.Pix[y*img.Stride+x*4 : y*img.Stride+x*4+3 : y*img.Stride+x*4+3]
+				b := blurred.Pix[y*blurred.Stride+x*4 : y*blurred.Stride+x*4+3 : y*blurred.Stride+x*4+3]
+				dr := float64(d[0]) - float64(b[0])
+				dg := float64(d[1]) - float64(b[1])
+				db := float64(d[2]) - float64(b[2])
+				if threshold > 0 && math.Abs(0.299*dr+0.587*dg+0.114*db) < threshold {
+					continue
+				}
+				d[0] = clamp(float64(d[0]) + amount*dr)
+				d[1] = clamp(float64(d[1]) + amount*dg)
+				d[2] = clamp(float64(d[2]) + amount*db)
+			}
+		}
+	})
+}
+
+// SharpenText produces a version of the image with sharpened text and other thin
+// high-contrast details, such as the lines of a UI screenshot. Only the areas with a high
+// local contrast are sharpened, so the photos and smooth gradients stay unchanged.
+// The result never overshoots the values of the neighboring pixels, so no halos appear
+// around the edges. Strength parameter must be positive and indicates how much the edges
+// will be sharpened, 1 is a reasonable default for downscaled screenshots.
+//
+// Example:
+//
+//	dstImage := imaging.SharpenText(imaging.Fit(srcImage, 400, 400, imaging.Lanczos), 1)
+//
+func SharpenText(img image.Image, strength float64) *image.NRGBA {
+	if strength <= 0 {
+		return Clone(img)
+	}
+
+	// Local luminance contrast below textEdgeLow is not sharpened at all,
+	// above textEdgeHigh it's sharpened fully.
+	const (
+		textEdgeLow  = 48
+		textEdgeHigh = 96
+	)
+
+	src := Clone(img)
+	dst := image.NewNRGBA(src.Rect)
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	parallel(0, h, w*costHeavy, func(ys <-chan int) {
+		for y := range ys {
+			for x := 0; x < w; x++ {
+				i := y*src.Stride + x*4
+				s := src.Pix[i : i+4 : i+4]
+				d := dst.Pix[i : i+4 : i+4]
+				d[3] = s[3]
+
+				var sum, lo, hi [3]float64
+				lo = [3]float64{255, 255, 255}
+				lumLo, lumHi := 255.0, 0.0
+				var wsum float64
+				for dy := -1; dy <= 1; dy++ {
+					yy := edgeIndex(y+dy, h, EdgeClamp)
+					for dx := -1; dx <= 1; dx++ {
+						xx := edgeIndex(x+dx, w, EdgeClamp)
+						j := yy*src.Stride + xx*4
+						n := src.Pix[j : j+3 : j+3]
+						// Binomial 3x3 weights: 1 2 1, 2 4 2, 1 2 1.
+						k := float64((2 - absint(dx)) * (2 - absint(dy)))
+						wsum += k
+						for c := 0; c < 3; c++ {
+							v := float64(n[c])
+							sum[c] += v * k
+							lo[c] = math.Min(lo[c], v)
+							hi[c] = math.Max(hi[c], v)
+						}
+						lum := 0.299*float64(n[0]) + 0.587*float64(n[1]) + 0.114*float64(n[2])
+						lumLo = math.Min(lumLo, lum)
+						lumHi = math.Max(lumHi, lum)
+					}
+				}
+
+				weight := (lumHi - lumLo - textEdgeLow) / (textEdgeHigh - textEdgeLow)
+				if weight <= 0 {
+					copy(d, s[:3])
+					continue
+				}
+				if weight > 1 {
+					weight = 1
+				}
+				for c := 0; c < 3; c++ {
+					v := float64(s[c])
+					sharp := v + strength*(v-sum[c]/wsum)
+					sharp = math.Min(math.Max(sharp, lo[c]), hi[c])
+					d[c] = clamp(v + weight*(sharp-v))
+				}
+			}
+		}
+	})
+	return dst
+}
+
+// DeinterlaceMethod is a method used by Deinterlace.
+type DeinterlaceMethod int
+
+// Deinterlace methods.
+const (
+	// DeinterlaceBob keeps the even (top field) lines and replaces the odd lines
+	// with the average of their neighbors. It keeps the frame sharp but halves the
+	// vertical resolution of the moving and still parts alike.
+	DeinterlaceBob DeinterlaceMethod = iota
+
+	// DeinterlaceBlend blends every line with its neighbors, mixing the two fields.
+	// It keeps more of the vertical detail of still parts, while the moving parts
+	// get a ghosting instead of the combing.
+	DeinterlaceBlend
+)
+
+// Deinterlace removes the combing artifacts of frames captured from interlaced video,
+// where the even and odd lines come from two different moments in time.
+//
+// Example:
+//
+//	dstImage := imaging.Deinterlace(frame, imaging.DeinterlaceBob)
+//
+func Deinterlace(img image.Image, method DeinterlaceMethod) *image.NRGBA {
+	src := newScanner(img)
+	dst := image.NewNRGBA(image.Rect(0, 0, src.w, src.h))
+	if src.w <= 0 || src.h < 2 {
+		return cloneInto(dst, img)
+	}
+
+	parallel(0, src.h, src.w*costPoint, func(ys <-chan int) {
+		prev := make([]uint8, src.w*4)
+		cur := make([]uint8, src.w*4)
+		next := make([]uint8, src.w*4)
+		for y := range ys {
+			j := y * dst.Stride
+			d := dst.Pix[j : j+src.w*4]
+			if method == DeinterlaceBob && y%2 == 0 {
+				src.scan(0, y, src.w, y+1, d)
+				continue
+			}
+
+			// Mirror the missing neighbors at the top and bottom edges.
+			y0, y1 := y-1, y+1
+			if y0 < 0 {
+				y0 = y1
+			}
+			if y1 >= src.h {
+				y1 = y0
+			}
+			src.scan(0, y0, src.w, y0+1, prev)
+			src.scan(0, y1, src.w, y1+1, next)
+			if method == DeinterlaceBob {
+				for i := 0; i < len(d); i += 4 {
+					blendPixels(d[i:i+4:i+4], prev[i:i+4:i+4], next[i:i+4:i+4], nil)
+				}
+				continue
+			}
+			src.scan(0, y, src.w, y+1, cur)
+			for i := 0; i < len(d); i += 4 {
+				blendPixels(d[i:i+4:i+4], prev[i:i+4:i+4], next[i:i+4:i+4], cur[i:i+4:i+4])
+			}
+		}
+	})
+	return dst
+}
+
+// blendPixels writes the alpha-weighted average of the pixels p and n to d.
+// If c is not nil, it's added to the average with double weight.
+func blendPixels(d, p, n, c []uint8) {
+	wp, wn := float64(p[3]), float64(n[3])
+	r := float64(p[0])*wp + float64(n[0])*wn
+	g := float64(p[1])*wp + float64(n[1])*wn
+	b := float64(p[2])*wp + float64(n[2])*wn
+	a := wp + wn
+	total := 2.0
+	if c != nil {
+		wc := 2 * float64(c[3])
+		r += float64(c[0]) * wc
+		g += float64(c[1]) * wc
+		b += float64(c[2]) * wc
+		a += wc
+		total = 4
+	}
+	if a == 0 {
+		d[0], d[1], d[2], d[3] = 0, 0, 0, 0
+		return
+	}
+	d[0] = clamp(r / a)
+	d[1] = clamp(g / a)
+	d[2] = clamp(b / a)
+	d[3] = clamp(a / total)
+}
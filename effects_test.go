@@ -1,7 +1,9 @@
 package imaging
 
 import (
+	"context"
 	"image"
+	"image/color"
 	"testing"
 )
 
@@ -105,6 +107,74 @@ func TestBlurGolden(t *testing.T) {
 	}
 }
 
+func TestBlurCtx(t *testing.T) {
+	got, err := BlurCtx(context.Background(), testdataFlowersSmallPNG, 1.5)
+	if err != nil {
+		t.Fatalf("BlurCtx: %v", err)
+	}
+	want := Blur(testdataFlowersSmallPNG, 1.5)
+	if !compareNRGBA(got, want, 0) {
+		t.Fatalf("resulting image differs from Blur result")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := BlurCtx(ctx, testdataFlowersSmallPNG, 1.5); err != context.Canceled {
+		t.Fatalf("got error %v want context.Canceled", err)
+	}
+}
+
+func TestBlurInto(t *testing.T) {
+	buf := New(200, 200, color.White)
+	pix := buf.Pix
+	for _, sigma := range []float64{0, 0.5, 1.5} {
+		want := Blur(testdataFlowersSmallPNG, sigma)
+		got := BlurInto(buf, testdataFlowersSmallPNG, sigma)
+		if got != buf || &got.Pix[0] != &pix[0] {
+			t.Fatalf("BlurInto didn't reuse dst")
+		}
+		if !compareNRGBA(got, want, 0) {
+			t.Fatalf("resulting image differs from Blur result")
+		}
+	}
+}
+
+func TestBlurIntoAllocs(t *testing.T) {
+	SetMaxProcs(1)
+	defer SetMaxProcs(0)
+	src := image.NewNRGBA(image.Rect(0, 0, 400, 300))
+	buf := BlurInto(&image.NRGBA{}, src, 3)
+
+	// The intermediate image is kept in the capacity of buf, so neither it nor the result
+	// is allocated (the pixels and the header of each), only the header of the scratch image.
+	into := testing.AllocsPerRun(10, func() {
+		buf = BlurInto(buf, src, 3)
+	})
+	plain := testing.AllocsPerRun(10, func() {
+		Blur(src, 3)
+	})
+	if into > plain-3 {
+		t.Fatalf("got %v allocations per BlurInto call, Blur makes %v", into, plain)
+	}
+	if n := allocatedBytes(10, func() {
+		buf = BlurInto(buf, src, 3)
+	}); n >= 400*300*4 {
+		t.Fatalf("got %d bytes allocated per BlurInto call", n)
+	}
+}
+
+func TestBlurVerticalStrips(t *testing.T) {
+	kernel := []float64{0.4, 0.25, 0.05}
+	for _, w := range []int{1, blurStripWidth, blurStripWidth + 1, 37} {
+		img := Crop(testdataFlowersSmallPNG, image.Rect(3, 5, 3+w, 28))
+		got := blurVertical(context.Background(), nil, img, kernel)
+		want := Transpose(blurHorizontal(context.Background(), nil, Transpose(img), kernel))
+		if !compareNRGBA(got, want, 0) {
+			t.Fatalf("width %d: vertical blur differs from the transposed horizontal blur", w)
+		}
+	}
+}
+
 func BenchmarkBlur(b *testing.B) {
 	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
@@ -230,9 +300,167 @@ func TestSharpenGolden(t *testing.T) {
 	}
 }
 
+func TestSharpenCtx(t *testing.T) {
+	got, err := SharpenCtx(context.Background(), testdataFlowersSmallPNG, 1.5)
+	if err != nil {
+		t.Fatalf("SharpenCtx: %v", err)
+	}
+	want := Sharpen(testdataFlowersSmallPNG, 1.5)
+	if !compareNRGBA(got, want, 0) {
+		t.Fatalf("resulting image differs from Sharpen result")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := SharpenCtx(ctx, testdataFlowersSmallPNG, 1.5); err != context.Canceled {
+		t.Fatalf("got error %v want context.Canceled", err)
+	}
+}
+
 func BenchmarkSharpen(b *testing.B) {
 	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
 		Sharpen(testdataBranchesJPG, 3)
 	}
 }
+
+func TestUnsharpMask(t *testing.T) {
+	// The amount of 1 without the threshold is the same as Sharpen.
+	src := Clone(testdataFlowersSmallPNG)
+	if got, want := UnsharpMask(src, 1.5, 1, 0), Sharpen(src, 1.5); !compareNRGBA(got, want, 0) {
+		t.Fatalf("got a different result than Sharpen")
+	}
+	// The larger amount increases the contrast more.
+	weak := meanAbsDiff(UnsharpMask(src, 1, 0.5, 0), src)
+	strong := meanAbsDiff(UnsharpMask(src, 1, 2, 0), src)
+	if !(weak > 0 && strong > 2*weak) {
+		t.Fatalf("got mean differences %.2f and %.2f", weak, strong)
+	}
+
+	// The low-contrast noise is kept as is, the edge is sharpened.
+	noise := noisyImage(32, 32, 2)
+	if got := UnsharpMask(noise, 1, 1, 10); !compareNRGBA(got, noise, 0) {
+		t.Fatalf("the noise is sharpened")
+	}
+	step := stepImage(20, 4, 50, 200)
+	got := UnsharpMask(step, 1, 1, 10)
+	if l, r := got.Pix[9*4], got.Pix[10*4]; l >= 50 || r <= 200 {
+		t.Fatalf("got %d and %d at the edge", l, r)
+	}
+	if l, r := got.Pix[0], got.Pix[19*4]; l != 50 || r != 200 {
+		t.Fatalf("got %d and %d far from the edge", l, r)
+	}
+
+	// The alpha channel isn't changed.
+	alpha := New(10, 10, color.NRGBA{100, 100, 100, 100})
+	alpha.SetNRGBA(5, 5, color.NRGBA{200, 200, 200, 255})
+	if got := UnsharpMask(alpha, 1, 1, 0); got.Pix[0+3] != 100 || got.NRGBAAt(5, 5).A != 255 || got.NRGBAAt(5, 5).R != 255 {
+		t.Fatalf("got %v and %v", got.NRGBAAt(0, 0), got.NRGBAAt(5, 5))
+	}
+
+	if got := UnsharpMask(src, 0, 1, 0); !compareNRGBA(got, src, 0) {
+		t.Fatalf("sigma 0: the image is changed")
+	}
+	if got := UnsharpMask(src, 1, 0, 0); !compareNRGBA(got, src, 0) {
+		t.Fatalf("amount 0: the image is changed")
+	}
+}
+
+func TestSharpenText(t *testing.T) {
+	// A soft vertical edge, as in downscaled text.
+	edge := []uint8{0xff, 0xff, 0xc8, 0x80, 0x38, 0x00, 0x00}
+	src := image.NewNRGBA(image.Rect(0, 0, len(edge), 3))
+	for y := 0; y < 3; y++ {
+		for x, v := range edge {
+			src.SetNRGBA(x, y, color.NRGBA{v, v, v, 0xff})
+		}
+	}
+	got := SharpenText(src, 1)
+	row := make([]uint8, len(edge))
+	for x := range edge {
+		row[x] = got.NRGBAAt(x, 1).R
+	}
+	if row[2] <= edge[2] || row[4] >= edge[4] {
+		t.Fatalf("edge is not sharpened: got %v from %v", row, edge)
+	}
+	if row[0] != 0xff || row[1] != 0xff || row[5] != 0x00 || row[6] != 0x00 {
+		t.Fatalf("flat areas changed: got %v from %v", row, edge)
+	}
+
+	// Low-contrast areas such as smooth gradients are left as is.
+	gradient := image.NewNRGBA(image.Rect(0, 0, 16, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 16; x++ {
+			v := uint8(x * x)
+			gradient.SetNRGBA(x, y, color.NRGBA{v, v, v, 0x80})
+		}
+	}
+	got = SharpenText(gradient, 2)
+	if !compareNRGBA(got, gradient, 0) {
+		t.Fatalf("gradient changed: got %v", got.Pix)
+	}
+
+	got = SharpenText(src, 0)
+	if !compareNRGBA(got, src, 0) {
+		t.Fatalf("zero strength changed the image")
+	}
+}
+
+func BenchmarkSharpenText(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		SharpenText(testdataBranchesJPG, 1)
+	}
+}
+
+func TestDeinterlace(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 1, 4),
+		Stride: 1 * 4,
+		Pix: []uint8{
+			0xff, 0xff, 0xff, 0xff,
+			0x00, 0x00, 0x00, 0xff,
+			0xff, 0x00, 0x00, 0xff,
+			0x00, 0x00, 0x00, 0x00,
+		},
+	}
+	testCases := []struct {
+		name   string
+		method DeinterlaceMethod
+		want   []uint8
+	}{
+		{
+			"Bob",
+			DeinterlaceBob,
+			[]uint8{
+				0xff, 0xff, 0xff, 0xff,
+				0xff, 0x80, 0x80, 0xff,
+				0xff, 0x00, 0x00, 0xff,
+				0xff, 0x00, 0x00, 0xff,
+			},
+		},
+		{
+			"Blend",
+			DeinterlaceBlend,
+			[]uint8{
+				0x80, 0x80, 0x80, 0xff,
+				0x80, 0x40, 0x40, 0xff,
+				0xaa, 0x00, 0x00, 0xbf,
+				0xff, 0x00, 0x00, 0x80,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Deinterlace(src, tc.method)
+			if !compareBytes(got.Pix, tc.want, 0) {
+				t.Fatalf("got pixels %#v want %#v", got.Pix, tc.want)
+			}
+		})
+	}
+
+	one := New(3, 1, color.White)
+	if got := Deinterlace(one, DeinterlaceBlend); !compareNRGBA(got, one, 0) {
+		t.Fatalf("single line image changed: %#v", got)
+	}
+}
@@ -0,0 +1,68 @@
+package imaging
+
+import (
+	"image"
+)
+
+// FlatField corrects the uneven illumination and the sensor defects of the image using
+// calibration frames: the dark frame (taken with no light, it may be nil) is subtracted
+// from the image and the result is divided by the flat frame (an evenly lit featureless
+// field, with the dark or bias level already subtracted) normalized by its mean, so the
+// overall brightness is preserved. The arithmetic is done in linear light for each color
+// channel. The frames are resized to the image size if their sizes differ.
+//
+// Example:
+//
+//	corrected := imaging.FlatField(frame, flat, dark)
+//
+func FlatField(img, flat, dark image.Image) *image.NRGBA {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	if w <= 0 || h <= 0 || flat.Bounds().Empty() {
+		return Clone(img)
+	}
+
+	frame := func(f image.Image) []uint16 {
+		if f.Bounds().Dx() != w || f.Bounds().Dy() != h {
+			f = ResizeLinear(f, w, h, Linear)
+		}
+		return linearize(f)
+	}
+	src := linearize(img)
+	flatBuf := frame(flat)
+	var darkBuf []uint16
+	if dark != nil && !dark.Bounds().Empty() {
+		darkBuf = frame(dark)
+	}
+
+	var mean [3]float64
+	for i := 0; i < len(flatBuf); i += 4 {
+		for c := 0; c < 3; c++ {
+			mean[c] += float64(flatBuf[i+c])
+		}
+	}
+	for c := range mean {
+		mean[c] /= float64(w * h)
+	}
+
+	lut := linearToSRGBTable()
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	parallel(0, h, w*costPoint, func(ys <-chan int) {
+		for y := range ys {
+			i := y * w * 4
+			d := dst.Pix[y*dst.Stride : y*dst.Stride+w*4]
+			for j := 0; j < len(d); j += 4 {
+				for c := 0; c < 3; c++ {
+					v := float64(src[i+j+c])
+					if darkBuf != nil {
+						v -= float64(darkBuf[i+j+c])
+					}
+					// The flat frame is at least one 16-bit step to avoid the division by zero.
+					v *= mean[c] / max(float64(flatBuf[i+j+c]), 1)
+					d[j+c] = lut[clamp16(v)]
+				}
+				d[j+3] = uint8(src[i+j+3] >> 8)
+			}
+		}
+	})
+	return dst
+}
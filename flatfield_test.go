@@ -0,0 +1,65 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestFlatField(t *testing.T) {
+	src := testdataBranchesPNG
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+
+	// A uniform flat frame of any size doesn't change the image.
+	got := FlatField(src, New(5, 3, color.NRGBA{0x80, 0x90, 0xa0, 0xff}), nil)
+	if !compareNRGBA(got, Clone(src), 1) {
+		t.Fatalf("the uniform flat frame changed the image")
+	}
+
+	// The image equal to the dark frame becomes black.
+	got = FlatField(src, New(w, h, color.White), src)
+	for i := 0; i < len(got.Pix); i += 4 {
+		if got.Pix[i] != 0 || got.Pix[i+1] != 0 || got.Pix[i+2] != 0 {
+			t.Fatalf("got color %v at %d want black", got.Pix[i:i+4], i/4)
+		}
+	}
+}
+
+func TestFlatFieldVignetting(t *testing.T) {
+	// The image of a uniform scene equals the flat frame: the light falls off to the right.
+	flat := image.NewNRGBA(image.Rect(0, 0, 64, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 64; x++ {
+			v := uint8(255 - x*2)
+			flat.SetNRGBA(x, y, color.NRGBA{v, v, v / 2, 0xff})
+		}
+	}
+	dark := New(64, 8, color.NRGBA{0x08, 0x08, 0x08, 0xff})
+	img := image.NewNRGBA(flat.Rect)
+	for i := 0; i < len(img.Pix); i += 4 {
+		for c := 0; c < 3; c++ {
+			// The linear light of the scene plus the dark current.
+			v := srgbToLinear(float64(flat.Pix[i+c])/255) + srgbToLinear(8.0/255)
+			img.Pix[i+c] = clamp(linearToSRGB(v) * 255)
+		}
+		img.Pix[i+3] = 0xff
+	}
+
+	got := FlatField(img, flat, dark)
+	want := got.NRGBAAt(32, 4)
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 64; x++ {
+			c := got.NRGBAAt(x, y)
+			if absint(int(c.R)-int(want.R)) > 2 || absint(int(c.G)-int(want.G)) > 2 || absint(int(c.B)-int(want.B)) > 3 {
+				t.Fatalf("got color %v at (%d, %d) want %v", c, x, y, want)
+			}
+		}
+	}
+}
+
+func TestFlatFieldEmpty(t *testing.T) {
+	got := FlatField(&image.NRGBA{}, New(2, 2, color.White), nil)
+	if !got.Rect.Empty() {
+		t.Fatalf("got bounds %v", got.Rect)
+	}
+}
@@ -0,0 +1,113 @@
+package imaging
+
+import (
+	"image"
+)
+
+// FocusStack merges the images of the same scene taken with different focus distances
+// into one image that is sharp everywhere. For each pixel it selects the source image with
+// the highest local contrast (the smoothed magnitude of the Laplacian) and blends
+// the selection using Laplacian pyramids, hiding the seams between the sources.
+// The images must be aligned. They are resized to the size of the first image
+// if their sizes differ.
+//
+// Example:
+//
+//	stacked := imaging.FocusStack([]image.Image{near, middle, far})
+//
+func FocusStack(images []image.Image) *image.NRGBA {
+	if len(images) == 0 {
+		return &image.NRGBA{}
+	}
+	w, h := images[0].Bounds().Dx(), images[0].Bounds().Dy()
+	if w <= 0 || h <= 0 {
+		return &image.NRGBA{}
+	}
+	if len(images) == 1 {
+		return Clone(images[0])
+	}
+
+	srcs := make([]*floatPlanes, len(images))
+	for i, img := range images {
+		if img.Bounds().Dx() != w || img.Bounds().Dy() != h {
+			img = Resize(img, w, h, Linear)
+		}
+		srcs[i] = floatPlanesFromImage(img)
+	}
+
+	// The index of the sharpest source of each pixel.
+	best := make([]int, w*h)
+	bestScore := make([]float32, w*h)
+	for i, src := range srcs {
+		score := focusMeasure(src)
+		for j, v := range score {
+			if i == 0 || v > bestScore[j] {
+				best[j], bestScore[j] = i, v
+			}
+		}
+	}
+
+	weights := make([]*floatPlanes, len(srcs))
+	for i := range srcs {
+		weights[i] = newFloatPlanes(w, h, 1)
+		for j, b := range best {
+			if b == i {
+				weights[i].pix[j] = 1
+			}
+		}
+	}
+	return blendPyramids(srcs, weights).toImage()
+}
+
+// focusMeasure returns the local contrast of the image: the magnitude of the Laplacian
+// of the luminance averaged over a 5x5 neighborhood.
+func focusMeasure(p *floatPlanes) []float32 {
+	w, h := p.w, p.h
+	lum := make([]float32, w*h)
+	for i := range lum {
+		s := p.pix[i*4:]
+		lum[i] = 0.299*s[0] + 0.587*s[1] + 0.114*s[2]
+	}
+	at := func(x, y int) float32 {
+		return lum[min(max(y, 0), h-1)*w+min(max(x, 0), w-1)]
+	}
+	lap := make([]float32, w*h)
+	parallel(0, h, w*9*costTap, func(ys <-chan int) {
+		for y := range ys {
+			for x := 0; x < w; x++ {
+				v := at(x-1, y) + at(x+1, y) + at(x, y-1) + at(x, y+1) - 4*at(x, y)
+				if v < 0 {
+					v = -v
+				}
+				lap[y*w+x] = v
+			}
+		}
+	})
+
+	// Separable box blur with the radius of 2.
+	const r = 2
+	tmp := make([]float32, w*h)
+	parallel(0, h, w*(2*r+1)*costTap, func(ys <-chan int) {
+		for y := range ys {
+			for x := 0; x < w; x++ {
+				var sum float32
+				for dx := -r; dx <= r; dx++ {
+					sum += lap[y*w+min(max(x+dx, 0), w-1)]
+				}
+				tmp[y*w+x] = sum
+			}
+		}
+	})
+	parallel(0, h, w*(2*r+1)*costTap, func(ys <-chan int) {
+		for y := range ys {
+			for x := 0; x < w; x++ {
+				var sum float32
+				for dy := -r; dy <= r; dy++ {
+					sum += tmp[min(max(y+dy, 0), h-1)*w+x]
+				}
+				lap[y*w+x] = sum
+			}
+		}
+	})
+	return lap
+}
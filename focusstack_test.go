@@ -0,0 +1,43 @@
+package imaging
+
+import (
+	"image"
+	"testing"
+)
+
+func TestFocusStack(t *testing.T) {
+	sharp := Clone(testdataFlowersSmallPNG)
+	blurred := Blur(sharp, 3)
+	w, h := sharp.Rect.Dx(), sharp.Rect.Dy()
+
+	// Each image is in focus on one side.
+	left := Paste(blurred, Crop(sharp, image.Rect(0, 0, w/2, h)), image.Pt(0, 0))
+	right := Paste(blurred, Crop(sharp, image.Rect(w/2, 0, w, h)), image.Pt(w/2, 0))
+
+	got := FocusStack([]image.Image{left, right})
+	if got.Rect != sharp.Rect {
+		t.Fatalf("got bounds %v want %v", got.Rect, sharp.Rect)
+	}
+	d := meanAbsDiff(got, sharp)
+	if d > 1.5 || d*3 > meanAbsDiff(left, sharp) {
+		t.Fatalf("got mean difference %.2f from the sharp image, %.2f for a single source", d, meanAbsDiff(left, sharp))
+	}
+}
+
+func TestFocusStackSame(t *testing.T) {
+	// The pyramid blending of identical images reconstructs the image.
+	src := testdataBranchesPNG
+	got := FocusStack([]image.Image{src, src, src})
+	if !compareNRGBA(got, Clone(src), 1) {
+		t.Fatalf("the image changed")
+	}
+}
+
+func TestFocusStackEmpty(t *testing.T) {
+	if got := FocusStack(nil); !got.Rect.Empty() {
+		t.Fatalf("got bounds %v", got.Rect)
+	}
+	if got := FocusStack([]image.Image{&image.NRGBA{}}); !got.Rect.Empty() {
+		t.Fatalf("got bounds %v", got.Rect)
+	}
+}
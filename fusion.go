@@ -0,0 +1,106 @@
+package imaging
+
+import (
+	"image"
+	"math"
+)
+
+// FuseExposures merges the bracketed shots of the same scene into one image preserving
+// the details of both the shadows and the highlights, without the HDR reconstruction and
+// tone mapping. It implements the exposure fusion of Mertens, Kautz and Van Reeth: each
+// pixel of each image is weighted by its contrast, saturation and well-exposedness,
+// and the images are blended using Laplacian pyramids. The images must be aligned.
+// They are resized to the size of the first image if their sizes differ.
+//
+// Example:
+//
+//	fused := imaging.FuseExposures([]image.Image{under, normal, over})
+//
+func FuseExposures(images []image.Image) *image.NRGBA {
+	if len(images) == 0 {
+		return &image.NRGBA{}
+	}
+	w, h := images[0].Bounds().Dx(), images[0].Bounds().Dy()
+	if w <= 0 || h <= 0 {
+		return &image.NRGBA{}
+	}
+	if len(images) == 1 {
+		return Clone(images[0])
+	}
+
+	srcs := make([]*floatPlanes, len(images))
+	weights := make([]*floatPlanes, len(images))
+	for i, img := range images {
+		if img.Bounds().Dx() != w || img.Bounds().Dy() != h {
+			img = Resize(img, w, h, Linear)
+		}
+		srcs[i] = floatPlanesFromImage(img)
+		weights[i] = exposureWeights(srcs[i])
+	}
+
+	// Normalize the weights to sum to 1 at each pixel.
+	parallel(0, h, w*len(images)*costPoint, func(ys <-chan int) {
+		for y := range ys {
+			for j := y * w; j < (y+1)*w; j++ {
+				var sum float32
+				for _, wp := range weights {
+					sum += wp.pix[j]
+				}
+				for _, wp := range weights {
+					if sum > 0 {
+						wp.pix[j] /= sum
+					} else {
+						wp.pix[j] = 1 / float32(len(weights))
+					}
+				}
+			}
+		}
+	})
+	return blendPyramids(srcs, weights).toImage()
+}
+
+// exposureWeights returns the Mertens weights of the pixels: the product of the contrast
+// (the magnitude of the Laplacian of the luminance), the saturation (the standard deviation
+// of the color channels) and the well-exposedness (the closeness of the channels to 0.5).
+func exposureWeights(p *floatPlanes) *floatPlanes {
+	w, h := p.w, p.h
+	lum := make([]float32, w*h)
+	for i := range lum {
+		s := p.pix[i*4:]
+		lum[i] = 0.299*s[0] + 0.587*s[1] + 0.114*s[2]
+	}
+	at := func(x, y int) float32 {
+		return lum[min(max(y, 0), h-1)*w+min(max(x, 0), w-1)]
+	}
+
+	const sigma = 0.2
+	wp := newFloatPlanes(w, h, 1)
+	parallel(0, h, w*costHeavy, func(ys <-chan int) {
+		for y := range ys {
+			for x := 0; x < w; x++ {
+				i := y*w + x
+				s := p.pix[i*4 : i*4+4]
+
+				contrast := at(x-1, y) + at(x+1, y) + at(x, y-1) + at(x, y+1) - 4*at(x, y)
+				if contrast < 0 {
+					contrast = -contrast
+				}
+
+				mean := (s[0] + s[1] + s[2]) / 3
+				dr, dg, db := s[0]-mean, s[1]-mean, s[2]-mean
+				saturation := float32(math.Sqrt(float64(dr*dr+dg*dg+db*db) / 3))
+
+				exposedness := 1.0
+				for c := 0; c < 3; c++ {
+					d := float64(s[c]) - 0.5
+					exposedness *= math.Exp(-d * d / (2 * sigma * sigma))
+				}
+
+				// A small constant keeps the flat gray areas from getting a zero weight.
+				const eps = 1e-4
+				wp.pix[i] = (contrast + eps) * (saturation + eps) * float32(exposedness) * s[3]
+			}
+		}
+	})
+	return wp
+}
@@ -0,0 +1,70 @@
+package imaging
+
+import (
+	"image"
+	"testing"
+)
+
+// exposed simulates a shot with the exposure changed by the factor: the linear light
+// is scaled and clipped.
+func exposed(img image.Image, factor float64) *image.NRGBA {
+	dst := Clone(img)
+	for i := 0; i < len(dst.Pix); i++ {
+		if i%4 != 3 {
+			dst.Pix[i] = clamp(linearToSRGB(min(srgbToLinear(float64(dst.Pix[i])/255)*factor, 1)) * 255)
+		}
+	}
+	return dst
+}
+
+func TestFuseExposures(t *testing.T) {
+	src := testdataFlowersSmallPNG
+	under, over := exposed(src, 0.25), exposed(src, 4)
+
+	got := FuseExposures([]image.Image{under, over})
+	if got.Rect != Clone(src).Rect {
+		t.Fatalf("got bounds %v", got.Rect)
+	}
+	// The highlights clipped in the over exposed image are taken from the under exposed one
+	// and the shadows crushed in the under exposed image from the over exposed one.
+	count := func(img *image.NRGBA, fn func(v uint8) bool) int {
+		n := 0
+		for i := 0; i < len(img.Pix); i += 4 {
+			if fn(img.Pix[i]) && fn(img.Pix[i+1]) && fn(img.Pix[i+2]) {
+				n++
+			}
+		}
+		return n
+	}
+	clipped := func(v uint8) bool { return v == 0xff }
+	crushed := func(v uint8) bool { return v < 0x10 }
+	if c, co := count(got, clipped), count(over, clipped); c*4 > co {
+		t.Fatalf("got %d clipped pixels, %d in the over exposed image", c, co)
+	}
+	if c, cu := count(got, crushed), count(under, crushed); c*4 > cu {
+		t.Fatalf("got %d crushed pixels, %d in the under exposed image", c, cu)
+	}
+	mean := func(img *image.NRGBA) float64 {
+		var sum float64
+		for i, v := range img.Pix {
+			if i%4 != 3 {
+				sum += float64(v)
+			}
+		}
+		return sum / float64(len(img.Pix)*3/4)
+	}
+	if m := mean(got); m <= mean(under) || m >= mean(over) {
+		t.Fatalf("got mean %.1f, %.1f for the under and %.1f for the over exposed image", m, mean(under), mean(over))
+	}
+}
+
+func TestFuseExposuresSame(t *testing.T) {
+	src := testdataBranchesPNG
+	got := FuseExposures([]image.Image{src, src})
+	if !compareNRGBA(got, Clone(src), 1) {
+		t.Fatalf("the image changed")
+	}
+	if got := FuseExposures(nil); !got.Rect.Empty() {
+		t.Fatalf("got bounds %v", got.Rect)
+	}
+}
@@ -0,0 +1,199 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+)
+
+// ThumbnailGIF returns a thumbnail of the animated GIF image, scaled and cropped to
+// the specified width and height like Thumbnail. The frames are composed according to
+// their disposal methods, so each output frame is a complete picture of the animation
+// at that moment. The frames are quantized back to their original palettes, keeping
+// the transparent color index, and the delays and the loop count are preserved.
+//
+// Example:
+//
+//	g, err := gif.DecodeAll(r)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	err = gif.EncodeAll(w, imaging.ThumbnailGIF(g, 100, 100, imaging.Lanczos))
+//
+func ThumbnailGIF(g *gif.GIF, width, height int, filter ResampleFilter) *gif.GIF {
+	dst := &gif.GIF{
+		LoopCount: g.LoopCount,
+		Config:    image.Config{Width: width, Height: height},
+	}
+	if width <= 0 || height <= 0 || len(g.Image) == 0 {
+		dst.Config = image.Config{}
+		return dst
+	}
+
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	if bounds.Empty() {
+		bounds = g.Image[0].Rect
+		for _, frame := range g.Image[1:] {
+			bounds = bounds.Union(frame.Rect)
+		}
+	}
+	canvas := image.NewNRGBA(bounds)
+	var previous *image.NRGBA
+
+	for i, frame := range g.Image {
+		disposal := byte(gif.DisposalNone)
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		if disposal == gif.DisposalPrevious {
+			previous = Clone(canvas)
+		}
+
+		draw.Draw(canvas, frame.Rect, frame, frame.Rect.Min, draw.Over)
+		thumb := Thumbnail(canvas, width, height, filter)
+		dst.Image = append(dst.Image, quantizeGIFFrame(thumb, frame.Palette, false))
+		if i < len(g.Delay) {
+			dst.Delay = append(dst.Delay, g.Delay[i])
+		} else {
+			dst.Delay = append(dst.Delay, 0)
+		}
+		// Each output frame is complete, so it replaces the previous one entirely.
+		dst.Disposal = append(dst.Disposal, gif.DisposalBackground)
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Rect, image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			draw.Draw(canvas, bounds, previous, image.Point{}, draw.Src)
+		}
+	}
+	return dst
+}
+
+// quantizeGIFFrame converts the image to a paletted image using the given palette.
+// The pixels that are less than half opaque become transparent; the transparent color
+// of the palette is kept or added to the palette if needed. If dither is true, the opaque
+// pixels are dithered with the Floyd-Steinberg error diffusion, otherwise each of them
+// gets the nearest color. The animation frames are not dithered as the dithering
+// patterns flicker between the frames.
+func quantizeGIFFrame(img *image.NRGBA, pal color.Palette, dither bool) *image.Paletted {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+
+	hasTransparent := false
+	for i := 3; i < len(img.Pix); i += 4 {
+		if img.Pix[i] < 0x80 {
+			hasTransparent = true
+			break
+		}
+	}
+
+	transparent := -1
+	opaque := make(color.Palette, 0, len(pal))
+	var opaqueIndex []uint8
+	for i, c := range pal {
+		if _, _, _, a := c.RGBA(); a == 0 {
+			if transparent < 0 {
+				transparent = i
+			}
+			continue
+		}
+		opaque = append(opaque, c)
+		opaqueIndex = append(opaqueIndex, uint8(i))
+	}
+
+	p := make(color.Palette, len(pal), 256)
+	copy(p, pal)
+	if hasTransparent && transparent < 0 {
+		if len(p) < 256 {
+			p = append(p, color.Transparent)
+			transparent = len(p) - 1
+		} else {
+			// The palette is full: give up its last color.
+			transparent = len(p) - 1
+			p[transparent] = color.Transparent
+			opaque = opaque[:len(opaque)-1]
+			opaqueIndex = opaqueIndex[:len(opaqueIndex)-1]
+		}
+	}
+	if len(opaque) == 0 {
+		opaque = color.Palette{color.Black}
+		opaqueIndex = []uint8{uint8(len(p))}
+		p = append(p, color.Black)
+	}
+
+	dst := image.NewPaletted(image.Rect(0, 0, w, h), p)
+	if dither {
+		ditherPaletted(dst, img, opaque, opaqueIndex, uint8(transparent))
+		return dst
+	}
+	parallel(0, h, w*costHeavy, func(ys <-chan int) {
+		local := make(map[color.NRGBA]uint8)
+		for y := range ys {
+			for x := 0; x < w; x++ {
+				i := y*img.Stride + x*4
+				s := img.Pix[i : i+4 : i+4]
+				if s[3] < 0x80 {
+					dst.Pix[y*dst.Stride+x] = uint8(transparent)
+					continue
+				}
+				c := color.NRGBA{s[0], s[1], s[2], 0xff}
+				idx, ok := local[c]
+				if !ok {
+					idx = opaqueIndex[opaque.Index(c)]
+					local[c] = idx
+				}
+				dst.Pix[y*dst.Stride+x] = idx
+			}
+		}
+	})
+	return dst
+}
+
+// ditherPaletted draws the image into dst with the Floyd-Steinberg error diffusion using
+// the opaque colors of the palette, whose indices in the palette of dst are in opaqueIndex.
+// The pixels that are less than half opaque get the transparent index and neither receive
+// nor spread the error.
+func ditherPaletted(dst *image.Paletted, img *image.NRGBA, opaque color.Palette, opaqueIndex []uint8, transparent uint8) {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	colors := make([][3]float32, len(opaque))
+	for i, c := range opaque {
+		n := color.NRGBAModel.Convert(c).(color.NRGBA)
+		colors[i] = [3]float32{float32(n.R), float32(n.G), float32(n.B)}
+	}
+
+	// The errors of the current and the next row, with a pixel of padding on each side.
+	cur := make([][3]float32, w+2)
+	next := make([][3]float32, w+2)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*img.Stride + x*4
+			s := img.Pix[i : i+4 : i+4]
+			if s[3] < 0x80 {
+				dst.Pix[y*dst.Stride+x] = transparent
+				continue
+			}
+			var v [3]float32
+			for c := range v {
+				v[c] = min(max(float32(s[c])+cur[x+1][c], 0), 255)
+			}
+			best, bestDist := 0, float32(-1)
+			for j, pc := range colors {
+				dr, dg, db := v[0]-pc[0], v[1]-pc[1], v[2]-pc[2]
+				if d := dr*dr + dg*dg + db*db; bestDist < 0 || d < bestDist {
+					best, bestDist = j, d
+				}
+			}
+			dst.Pix[y*dst.Stride+x] = opaqueIndex[best]
+			for c := range v {
+				e := v[c] - colors[best][c]
+				cur[x+2][c] += e * 7 / 16
+				next[x][c] += e * 3 / 16
+				next[x+1][c] += e * 5 / 16
+				next[x+2][c] += e * 1 / 16
+			}
+		}
+		cur, next = next, cur
+		clear(next)
+	}
+}
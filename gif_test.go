@@ -0,0 +1,108 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+)
+
+func TestThumbnailGIF(t *testing.T) {
+	pal := color.Palette{
+		color.NRGBA{0xff, 0x00, 0x00, 0xff},
+		color.NRGBA{0x00, 0x00, 0xff, 0xff},
+		color.NRGBA{0x00, 0x00, 0x00, 0x00},
+	}
+	// Frame 0: red background with a transparent right half.
+	f0 := image.NewPaletted(image.Rect(0, 0, 8, 4), pal)
+	for y := 0; y < 4; y++ {
+		for x := 4; x < 8; x++ {
+			f0.SetColorIndex(x, y, 2)
+		}
+	}
+	// Frame 1: blue square over the left half, restored to frame 0 afterwards.
+	f1 := image.NewPaletted(image.Rect(0, 0, 4, 4), pal)
+	for i := range f1.Pix {
+		f1.Pix[i] = 1
+	}
+	// Frame 2: a tiny opaque patch, drawn over the restored canvas.
+	f2 := image.NewPaletted(image.Rect(6, 0, 8, 4), pal)
+
+	g := &gif.GIF{
+		Image:     []*image.Paletted{f0, f1, f2},
+		Delay:     []int{10, 20, 30},
+		Disposal:  []byte{gif.DisposalNone, gif.DisposalPrevious, gif.DisposalNone},
+		LoopCount: 3,
+		Config:    image.Config{Width: 8, Height: 4},
+	}
+	got := ThumbnailGIF(g, 4, 2, NearestNeighbor)
+	if got.Config.Width != 4 || got.Config.Height != 2 {
+		t.Fatalf("got config %+v", got.Config)
+	}
+	if got.LoopCount != 3 || len(got.Image) != 3 {
+		t.Fatalf("got loop count %d and %d frames", got.LoopCount, len(got.Image))
+	}
+	for i, d := range []int{10, 20, 30} {
+		if got.Delay[i] != d {
+			t.Fatalf("frame %d: got delay %d want %d", i, got.Delay[i], d)
+		}
+	}
+
+	want := [][]uint8{
+		{0, 0, 2, 2, 0, 0, 2, 2},
+		{1, 1, 2, 2, 1, 1, 2, 2},
+		{0, 0, 2, 0, 0, 0, 2, 0},
+	}
+	for i, frame := range got.Image {
+		if frame.Rect != image.Rect(0, 0, 4, 2) {
+			t.Fatalf("frame %d: got bounds %v", i, frame.Rect)
+		}
+		if !compareBytes(frame.Pix, want[i], 0) {
+			t.Fatalf("frame %d: got indices %v want %v", i, frame.Pix, want[i])
+		}
+		if len(frame.Palette) != 3 {
+			t.Fatalf("frame %d: got palette %v", i, frame.Palette)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, got); err != nil {
+		t.Fatalf("EncodeAll: %v", err)
+	}
+
+	if got := ThumbnailGIF(g, 0, 10, Box); len(got.Image) != 0 {
+		t.Fatalf("got %d frames for invalid size", len(got.Image))
+	}
+}
+
+func TestQuantizeGIFFrame(t *testing.T) {
+	img := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 3, 1),
+		Stride: 3 * 4,
+		Pix: []uint8{
+			0xf0, 0x10, 0x10, 0xff,
+			0x10, 0x10, 0x10, 0x20,
+			0x20, 0x20, 0xe0, 0xc0,
+		},
+	}
+	// An opaque palette gets a transparent color.
+	pal := color.Palette{color.NRGBA{0xff, 0, 0, 0xff}, color.NRGBA{0, 0, 0xff, 0xff}}
+	got := quantizeGIFFrame(img, pal, false)
+	if len(got.Palette) != 3 || got.Palette[2] != color.Transparent {
+		t.Fatalf("got palette %v", got.Palette)
+	}
+	if want := []uint8{0, 2, 1}; !compareBytes(got.Pix, want, 0) {
+		t.Fatalf("got indices %v want %v", got.Pix, want)
+	}
+
+	// A full palette gives up its last color.
+	full := make(color.Palette, 256)
+	for i := range full {
+		full[i] = color.NRGBA{uint8(i), 0, 0, 0xff}
+	}
+	got = quantizeGIFFrame(img, full, false)
+	if len(got.Palette) != 256 || got.Pix[1] != 255 || got.Pix[0] != 240 {
+		t.Fatalf("got indices %v", got.Pix)
+	}
+}
@@ -1,3 +1,7 @@
 module github.com/disintegration/imaging
 
+go 1.23
+
 require golang.org/x/image v0.0.0-20191009234506-e7c1f5e7dbb8
+
+require golang.org/x/text v0.3.0 // indirect
@@ -0,0 +1,210 @@
+package imaging
+
+import (
+	"image"
+	"math"
+)
+
+// The functions in this file work with a single 8-bit luminance channel and return
+// *image.Gray images, so that grayscale pipelines, such as document or medical scans,
+// don't pay for the conversion to NRGBA with four times the memory and bandwidth.
+// Transparent pixels of the non-gray sources are composited over black, as done by
+// color.GrayModel.
+
+// CloneGray returns a copy of the given image as *image.Gray with the bounds starting
+// at (0, 0). The colors of the non-gray images are converted to the luminance.
+//
+// Example:
+//
+//	img, err := imaging.Open("scan.png")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	gray := imaging.CloneGray(img)
+//
+func CloneGray(img image.Image) *image.Gray {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dst := image.NewGray(image.Rect(0, 0, w, h))
+	if w <= 0 || h <= 0 {
+		return dst
+	}
+
+	if src, ok := img.(*image.Gray); ok {
+		parallel(0, h, w*costCopy, func(ys <-chan int) {
+			for y := range ys {
+				i := src.PixOffset(b.Min.X, b.Min.Y+y)
+				copy(dst.Pix[y*dst.Stride:y*dst.Stride+w], src.Pix[i:i+w])
+			}
+		})
+		return dst
+	}
+
+	s := newScanner(img)
+	parallel(0, h, w*costCopy, func(ys <-chan int) {
+		scanLine := make([]uint8, w*4)
+		for y := range ys {
+			s.scan(0, y, w, y+1, scanLine)
+			d := dst.Pix[y*dst.Stride : y*dst.Stride+w]
+			for x := range d {
+				p := scanLine[x*4 : x*4+4 : x*4+4]
+				// The same coefficients as color.GrayModel.
+				lum := 19595*uint32(p[0]) + 38470*uint32(p[1]) + 7471*uint32(p[2])
+				d[x] = uint8((lum*uint32(p[3])/255 + 1<<15) >> 16)
+			}
+		}
+	})
+	return dst
+}
+
+// ResizeGray is like Resize but works with the luminance only and returns *image.Gray.
+//
+// Example:
+//
+//	dstImage := imaging.ResizeGray(srcImage, 800, 0, imaging.Lanczos)
+//
+func ResizeGray(img image.Image, width, height int, filter ResampleFilter) *image.Gray {
+	srcW := img.Bounds().Dx()
+	srcH := img.Bounds().Dy()
+	if width < 0 || height < 0 || (width == 0 && height == 0) || srcW <= 0 || srcH <= 0 {
+		return &image.Gray{}
+	}
+
+	dstW, dstH := resizeSize(srcW, srcH, width, height)
+	src := CloneGray(img)
+	if srcW == dstW && srcH == dstH {
+		return src
+	}
+	if filter.Support <= 0 {
+		return resizeNearestGray(src, dstW, dstH)
+	}
+	if srcW != dstW {
+		src = resizeHorizontalGray(src, dstW, filter)
+	}
+	if srcH != dstH {
+		src = resizeVerticalGray(src, dstH, filter)
+	}
+	return src
+}
+
+// FitGray is like Fit but works with the luminance only and returns *image.Gray.
+func FitGray(img image.Image, width, height int, filter ResampleFilter) *image.Gray {
+	maxW, maxH := width, height
+	if maxW <= 0 || maxH <= 0 {
+		return &image.Gray{}
+	}
+	srcW := img.Bounds().Dx()
+	srcH := img.Bounds().Dy()
+	if srcW <= 0 || srcH <= 0 {
+		return &image.Gray{}
+	}
+	if srcW <= maxW && srcH <= maxH {
+		return CloneGray(img)
+	}
+	newW, newH := fitSize(srcW, srcH, maxW, maxH)
+	return ResizeGray(img, newW, newH, filter)
+}
+
+// BlurGray is like Blur but works with the luminance only and returns *image.Gray.
+//
+// Example:
+//
+//	dstImage := imaging.BlurGray(srcImage, 3.5)
+//
+func BlurGray(img image.Image, sigma float64) *image.Gray {
+	src := CloneGray(img)
+	if sigma <= 0 {
+		return src
+	}
+
+	radius := int(math.Ceil(sigma * 3.0))
+	kernel := make([]float64, radius+1)
+	for i := 0; i <= radius; i++ {
+		kernel[i] = gaussianBlurKernel(float64(i), sigma)
+	}
+
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	tmp := image.NewGray(src.Rect)
+	parallel(0, h, w*(2*len(kernel)-1)*costTap, func(ys <-chan int) {
+		for y := range ys {
+			blurLineGray(tmp.Pix[y*tmp.Stride:], src.Pix[y*src.Stride:], 1, w, kernel)
+		}
+	})
+	parallel(0, w, h*(2*len(kernel)-1)*costTap, func(xs <-chan int) {
+		for x := range xs {
+			blurLineGray(src.Pix[x:], tmp.Pix[x:], src.Stride, h, kernel)
+		}
+	})
+	return src
+}
+
+// blurLineGray blurs a line of n gray pixels. The i-th source pixel is src[i*step]
+// and the output pixels are written to dst with the same step.
+func blurLineGray(dst, src []uint8, step, n int, kernel []float64) {
+	radius := len(kernel) - 1
+	for x := 0; x < n; x++ {
+		var c, wsum float64
+		for ix := max(x-radius, 0); ix <= min(x+radius, n-1); ix++ {
+			weight := kernel[absint(x-ix)]
+			c += float64(src[ix*step]) * weight
+			wsum += weight
+		}
+		dst[x*step] = clamp(c / wsum)
+	}
+}
+
+// resizeLineGray resamples a line of gray pixels. The i-th source pixel is src[i*step]
+// and the output pixels are written to dst with the same step.
+func resizeLineGray(dst, src []uint8, step int, weights [][]indexWeight) {
+	for x := range weights {
+		var c, wsum float64
+		for _, w := range weights[x] {
+			c += float64(src[w.index*step]) * w.weight
+			wsum += w.weight
+		}
+		if wsum != 0 {
+			dst[x*step] = clamp(c / wsum)
+		}
+	}
+}
+
+func resizeHorizontalGray(src *image.Gray, width int, filter ResampleFilter) *image.Gray {
+	srcW, srcH := src.Rect.Dx(), src.Rect.Dy()
+	dst := image.NewGray(image.Rect(0, 0, width, srcH))
+	weights := cachedWeights(width, srcW, filter)
+	parallel(0, srcH, weightsCost(weights), func(ys <-chan int) {
+		for y := range ys {
+			resizeLineGray(dst.Pix[y*dst.Stride:], src.Pix[y*src.Stride:], 1, weights)
+		}
+	})
+	return dst
+}
+
+func resizeVerticalGray(src *image.Gray, height int, filter ResampleFilter) *image.Gray {
+	srcW, srcH := src.Rect.Dx(), src.Rect.Dy()
+	dst := image.NewGray(image.Rect(0, 0, srcW, height))
+	weights := cachedWeights(height, srcH, filter)
+	parallel(0, srcW, weightsCost(weights), func(xs <-chan int) {
+		for x := range xs {
+			resizeLineGray(dst.Pix[x:], src.Pix[x:], src.Stride, weights)
+		}
+	})
+	return dst
+}
+
+func resizeNearestGray(src *image.Gray, width, height int) *image.Gray {
+	srcW, srcH := src.Rect.Dx(), src.Rect.Dy()
+	dx := float64(srcW) / float64(width)
+	dy := float64(srcH) / float64(height)
+	dst := image.NewGray(image.Rect(0, 0, width, height))
+	parallel(0, height, width*costCopy, func(ys <-chan int) {
+		for y := range ys {
+			srcY := int((float64(y) + 0.5) * dy)
+			for x := 0; x < width; x++ {
+				srcX := int((float64(x) + 0.5) * dx)
+				dst.Pix[y*dst.Stride+x] = src.Pix[srcY*src.Stride+srcX]
+			}
+		}
+	})
+	return dst
+}
@@ -0,0 +1,83 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// grayDiff returns the largest difference of the gray image and the luminance
+// of the NRGBA image.
+func grayDiff(a *image.Gray, b *image.NRGBA) int {
+	d := 0
+	for y := 0; y < a.Rect.Dy(); y++ {
+		for x := 0; x < a.Rect.Dx(); x++ {
+			c := color.GrayModel.Convert(b.At(b.Rect.Min.X+x, b.Rect.Min.Y+y)).(color.Gray)
+			d = max(d, absint(int(a.Pix[y*a.Stride+x])-int(c.Y)))
+		}
+	}
+	return d
+}
+
+func TestCloneGray(t *testing.T) {
+	src := testdataFlowersSmallPNG
+	got := CloneGray(src)
+	if got.Rect != image.Rect(0, 0, 240, 160) {
+		t.Fatalf("got bounds %v", got.Rect)
+	}
+	if d := grayDiff(got, Clone(src)); d > 1 {
+		t.Fatalf("got difference %d from color.GrayModel", d)
+	}
+
+	transparent := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 2, 1),
+		Stride: 2 * 4,
+		Pix:    []uint8{0xff, 0xff, 0xff, 0x00, 0xff, 0xff, 0xff, 0x80},
+	}
+	if got := CloneGray(transparent); got.Pix[0] != 0 || got.Pix[1] != 0x80 {
+		t.Fatalf("got pixels %v", got.Pix)
+	}
+
+	gray := image.NewGray(image.Rect(0, 0, 4, 4))
+	gray.SetGray(2, 3, color.Gray{0x42})
+	got = CloneGray(gray.SubImage(image.Rect(1, 2, 4, 4)))
+	if got.Rect != image.Rect(0, 0, 3, 2) || got.GrayAt(1, 1).Y != 0x42 {
+		t.Fatalf("got result %#v", got)
+	}
+	got.Pix[0] = 0xff
+	if gray.Pix[gray.PixOffset(1, 2)] != 0 {
+		t.Fatalf("the source image changed")
+	}
+}
+
+func TestResizeGray(t *testing.T) {
+	src := CloneGray(testdataFlowersSmallPNG)
+	for _, f := range []ResampleFilter{NearestNeighbor, Box, Linear, Lanczos} {
+		for _, size := range []image.Point{{100, 0}, {300, 300}, {240, 50}} {
+			got := ResizeGray(src, size.X, size.Y, f)
+			want := Resize(src, size.X, size.Y, f)
+			if got.Rect != want.Rect {
+				t.Fatalf("got bounds %v want %v", got.Rect, want.Rect)
+			}
+			if d := grayDiff(got, want); d > 1 {
+				t.Fatalf("got difference %d from Resize", d)
+			}
+		}
+	}
+	if got := ResizeGray(src, 0, 0, Box); !got.Rect.Empty() {
+		t.Fatalf("got bounds %v", got.Rect)
+	}
+	if got := FitGray(src, 120, 120, Box); got.Rect != image.Rect(0, 0, 120, 80) {
+		t.Fatalf("got bounds %v", got.Rect)
+	}
+}
+
+func TestBlurGray(t *testing.T) {
+	src := CloneGray(testdataBranchesPNG)
+	for _, sigma := range []float64{0, 0.5, 3} {
+		got := BlurGray(src, sigma)
+		if d := grayDiff(got, Blur(src, sigma)); d > 1 {
+			t.Fatalf("got difference %d from Blur with sigma %.1f", d, sigma)
+		}
+	}
+}
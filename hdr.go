@@ -0,0 +1,242 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// FloatImage is an in-memory image with float32 red, green, blue and alpha values in linear
+// light. The color values are not premultiplied by alpha and are not limited to [0, 1],
+// so it can hold high dynamic range data. Use ToneMap to convert it to a displayable image.
+type FloatImage struct {
+	// Pix holds the image's pixels, in R, G, B, A order. The pixel at
+	// (x, y) starts at Pix[(y-Rect.Min.Y)*Stride + (x-Rect.Min.X)*4].
+	Pix []float32
+	// Stride is the Pix stride (in float32 values) between vertically adjacent pixels.
+	Stride int
+	// Rect is the image's bounds.
+	Rect image.Rectangle
+}
+
+// NewFloatImage returns a new FloatImage with the given bounds.
+func NewFloatImage(r image.Rectangle) *FloatImage {
+	w, h := r.Dx(), r.Dy()
+	if w <= 0 || h <= 0 {
+		return &FloatImage{Rect: r}
+	}
+	return &FloatImage{Pix: make([]float32, w*h*4), Stride: w * 4, Rect: r}
+}
+
+// ColorModel returns the color model of the image. The colors returned by At are
+// clipped to the displayable range and encoded in sRGB.
+func (p *FloatImage) ColorModel() color.Model { return color.NRGBA64Model }
+
+// Bounds returns the domain for which At can return non-zero color.
+func (p *FloatImage) Bounds() image.Rectangle { return p.Rect }
+
+// At returns the color of the pixel at (x, y) clipped to [0, 1] and encoded in sRGB.
+func (p *FloatImage) At(x, y int) color.Color {
+	r, g, b, a := p.FloatAt(x, y)
+	enc := func(v float32) uint16 {
+		return clamp16(linearToSRGB(math.Min(math.Max(float64(v), 0), 1)) * 65535)
+	}
+	return color.NRGBA64{enc(r), enc(g), enc(b), clamp16(math.Min(math.Max(float64(a), 0), 1) * 65535)}
+}
+
+// FloatAt returns the linear color values of the pixel at (x, y).
+func (p *FloatImage) FloatAt(x, y int) (r, g, b, a float32) {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return 0, 0, 0, 0
+	}
+	i := p.PixOffset(x, y)
+	s := p.Pix[i : i+4 : i+4]
+	return s[0], s[1], s[2], s[3]
+}
+
+// SetFloat sets the linear color values of the pixel at (x, y).
+func (p *FloatImage) SetFloat(x, y int, r, g, b, a float32) {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return
+	}
+	i := p.PixOffset(x, y)
+	s := p.Pix[i : i+4 : i+4]
+	s[0], s[1], s[2], s[3] = r, g, b, a
+}
+
+// PixOffset returns the index of the first element of Pix that corresponds to
+// the pixel at (x, y).
+func (p *FloatImage) PixOffset(x, y int) int {
+	return (y-p.Rect.Min.Y)*p.Stride + (x-p.Rect.Min.X)*4
+}
+
+// FloatFromImage converts the image to a FloatImage with the bounds starting at (0, 0).
+// The sRGB-encoded colors are converted to linear light.
+//
+// Example:
+//
+//	hdr := imaging.FloatFromImage(srcImage)
+//
+func FloatFromImage(img image.Image) *FloatImage {
+	src := newScanner(img)
+	dst := NewFloatImage(image.Rect(0, 0, src.w, src.h))
+	if src.w <= 0 || src.h <= 0 {
+		return dst
+	}
+	parallel(0, src.h, src.w*costPoint, func(ys <-chan int) {
+		scanLine := make([]uint8, src.w*4)
+		for y := range ys {
+			src.scan(0, y, src.w, y+1, scanLine)
+			d := dst.Pix[y*dst.Stride : y*dst.Stride+src.w*4]
+			for i := 0; i < len(d); i += 4 {
+				d[i+0] = float32(srgbToLinearLUT[scanLine[i+0]]) / 65535
+				d[i+1] = float32(srgbToLinearLUT[scanLine[i+1]]) / 65535
+				d[i+2] = float32(srgbToLinearLUT[scanLine[i+2]]) / 65535
+				d[i+3] = float32(scanLine[i+3]) / 255
+			}
+		}
+	})
+	return dst
+}
+
+// ResizeFloat resizes the FloatImage to the specified width and height using the specified
+// resampling filter. The values are not clipped, so the highlights keep their intensity.
+// If one of width or height is 0, the image aspect ratio is preserved.
+//
+// Example:
+//
+//	hdr = imaging.ResizeFloat(hdr, 1024, 0, imaging.Lanczos)
+//
+func ResizeFloat(img *FloatImage, width, height int, filter ResampleFilter) *FloatImage {
+	srcW, srcH := img.Rect.Dx(), img.Rect.Dy()
+	if width < 0 || height < 0 || (width == 0 && height == 0) || srcW <= 0 || srcH <= 0 {
+		return &FloatImage{}
+	}
+	dstW, dstH := resizeSize(srcW, srcH, width, height)
+
+	src := img
+	if filter.Support <= 0 {
+		dst := NewFloatImage(image.Rect(0, 0, dstW, dstH))
+		dx := float64(srcW) / float64(dstW)
+		dy := float64(srcH) / float64(dstH)
+		parallel(0, dstH, dstW*costCopy, func(ys <-chan int) {
+			for y := range ys {
+				srcY := src.Rect.Min.Y + int((float64(y)+0.5)*dy)
+				for x := 0; x < dstW; x++ {
+					srcX := src.Rect.Min.X + int((float64(x)+0.5)*dx)
+					i := src.PixOffset(srcX, srcY)
+					copy(dst.Pix[y*dst.Stride+x*4:y*dst.Stride+x*4+4], src.Pix[i:i+4])
+				}
+			}
+		})
+		return dst
+	}
+
+	// Resize into a new image even if the size is unchanged, so the result
+	// always has the bounds starting at (0, 0) and doesn't share the pixels.
+	tmp := NewFloatImage(image.Rect(0, 0, dstW, srcH))
+	weights := cachedWeights(dstW, srcW, filter)
+	parallel(0, srcH, weightsCost(weights), func(ys <-chan int) {
+		for y := range ys {
+			i := src.PixOffset(src.Rect.Min.X, src.Rect.Min.Y+y)
+			resizeLineFloat(tmp.Pix[y*tmp.Stride:], src.Pix[i:], 4, weights)
+		}
+	})
+
+	dst := NewFloatImage(image.Rect(0, 0, dstW, dstH))
+	weights = cachedWeights(dstH, srcH, filter)
+	parallel(0, dstW, weightsCost(weights), func(xs <-chan int) {
+		for x := range xs {
+			resizeLineFloat(dst.Pix[x*4:], tmp.Pix[x*4:], tmp.Stride, weights)
+		}
+	})
+	return dst
+}
+
+// resizeLineFloat resamples a line of float pixels. The i-th source pixel starts
+// at src[i*step:] and the output pixels are written to dst with the same step.
+func resizeLineFloat(dst, src []float32, step int, weights [][]indexWeight) {
+	for x := range weights {
+		var r, g, b, a float64
+		for _, w := range weights[x] {
+			s := src[w.index*step : w.index*step+4 : w.index*step+4]
+			aw := float64(s[3]) * w.weight
+			r += float64(s[0]) * aw
+			g += float64(s[1]) * aw
+			b += float64(s[2]) * aw
+			a += aw
+		}
+		d := dst[x*step : x*step+4 : x*step+4]
+		if a == 0 {
+			continue
+		}
+		aInv := 1 / a
+		d[0] = float32(r * aInv)
+		d[1] = float32(g * aInv)
+		d[2] = float32(b * aInv)
+		d[3] = float32(math.Min(math.Max(a, 0), 1))
+	}
+}
+
+// ToneMapOperator is a function that maps high dynamic range values to the displayable range.
+type ToneMapOperator int
+
+// Tone mapping operators.
+const (
+	// ToneMapClip clips the values to [0, 1].
+	ToneMapClip ToneMapOperator = iota
+
+	// ToneMapReinhard uses the Reinhard operator x / (1 + x), which compresses
+	// the highlights smoothly and never reaches the full white.
+	ToneMapReinhard
+
+	// ToneMapACES uses the Narkowicz approximation of the ACES filmic curve,
+	// which gives a more contrasty, film-like result.
+	ToneMapACES
+)
+
+func (op ToneMapOperator) apply(x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	switch op {
+	case ToneMapReinhard:
+		return x / (1 + x)
+	case ToneMapACES:
+		const a, b, c, d, e = 2.51, 0.03, 2.43, 0.59, 0.14
+		x = (x * (a*x + b)) / (x*(c*x+d) + e)
+	}
+	return math.Min(x, 1)
+}
+
+// ToneMap converts the high dynamic range image to a displayable sRGB image using
+// the tone mapping operator. The exposure is measured in stops: each stop doubles
+// the brightness of the image before the tone mapping, 0 keeps it unchanged.
+//
+// Example:
+//
+//	dstImage := imaging.ToneMap(hdr, imaging.ToneMapACES, -1)
+//
+func ToneMap(img *FloatImage, op ToneMapOperator, exposure float64) *image.NRGBA {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	if w <= 0 || h <= 0 {
+		return dst
+	}
+	scale := math.Exp2(exposure)
+	lut := linearToSRGBTable()
+	parallel(0, h, w*costHeavy, func(ys <-chan int) {
+		for y := range ys {
+			i := img.PixOffset(img.Rect.Min.X, img.Rect.Min.Y+y)
+			s := img.Pix[i : i+w*4]
+			d := dst.Pix[y*dst.Stride : y*dst.Stride+w*4]
+			for j := 0; j < len(d); j += 4 {
+				d[j+0] = lut[clamp16(op.apply(float64(s[j+0])*scale)*65535)]
+				d[j+1] = lut[clamp16(op.apply(float64(s[j+1])*scale)*65535)]
+				d[j+2] = lut[clamp16(op.apply(float64(s[j+2])*scale)*65535)]
+				d[j+3] = clamp(float64(s[j+3]) * 255)
+			}
+		}
+	})
+	return dst
+}
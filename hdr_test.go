@@ -0,0 +1,109 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestFloatImageRoundTrip(t *testing.T) {
+	hdr := FloatFromImage(testdataFlowersSmallPNG)
+	if hdr.Rect != image.Rect(0, 0, 240, 160) {
+		t.Fatalf("got bounds %v", hdr.Rect)
+	}
+	got := ToneMap(hdr, ToneMapClip, 0)
+	if !compareNRGBA(got, toNRGBA(testdataFlowersSmallPNG), 0) {
+		t.Fatalf("round trip changed the image")
+	}
+	if !compareNRGBA(Clone(hdr), got, 1) {
+		t.Fatalf("colors returned by At differ from ToneMap result")
+	}
+}
+
+func TestFloatImageAccess(t *testing.T) {
+	img := NewFloatImage(image.Rect(-1, -1, 1, 1))
+	img.SetFloat(0, 0, 4, 0.5, 0, 1)
+	img.SetFloat(5, 5, 1, 1, 1, 1)
+	if r, g, b, a := img.FloatAt(0, 0); r != 4 || g != 0.5 || b != 0 || a != 1 {
+		t.Fatalf("got %v %v %v %v", r, g, b, a)
+	}
+	if r, g, b, a := img.FloatAt(5, 5); r != 0 || g != 0 || b != 0 || a != 0 {
+		t.Fatalf("got %v %v %v %v out of bounds", r, g, b, a)
+	}
+	want := color.NRGBA64{0xffff, 0xbc40, 0, 0xffff}
+	if c := img.At(0, 0); c != want {
+		t.Fatalf("got color %v want %v", c, want)
+	}
+}
+
+func TestResizeFloat(t *testing.T) {
+	img := NewFloatImage(image.Rect(0, 0, 4, 4))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = 10, 2, 0.5, 1
+	}
+	for _, f := range []ResampleFilter{NearestNeighbor, Box, Lanczos} {
+		got := ResizeFloat(img, 2, 0, f)
+		if got.Rect != image.Rect(0, 0, 2, 2) {
+			t.Fatalf("got bounds %v", got.Rect)
+		}
+		for i := 0; i < len(got.Pix); i += 4 {
+			p := got.Pix[i : i+4]
+			if p[0] < 9.999 || p[0] > 10.001 || p[1] < 1.999 || p[1] > 2.001 || p[3] != 1 {
+				t.Fatalf("values are not preserved: %v", p)
+			}
+		}
+	}
+
+	hdr := FloatFromImage(testdataBranchesPNG)
+	got := ToneMap(ResizeFloat(hdr, 150, 0, Linear), ToneMapClip, 0)
+	want := ResizeLinear(testdataBranchesPNG, 150, 0, Linear)
+	if !compareNRGBA(got, want, 1) {
+		t.Fatalf("ResizeFloat result differs from ResizeLinear")
+	}
+
+	if got := ResizeFloat(img, 0, 0, Box); !got.Rect.Empty() {
+		t.Fatalf("got bounds %v want empty", got.Rect)
+	}
+}
+
+func TestToneMap(t *testing.T) {
+	img := NewFloatImage(image.Rect(0, 0, 3, 1))
+	img.SetFloat(0, 0, 1, 0.25, 0, 1)
+	img.SetFloat(1, 0, 100, 3, -1, 0.5)
+	img.SetFloat(2, 0, 0.5, 0.5, 0.5, 1)
+	testCases := []struct {
+		name     string
+		op       ToneMapOperator
+		exposure float64
+		want     []uint8
+	}{
+		{
+			"clip",
+			ToneMapClip, 0,
+			[]uint8{0xff, 0x89, 0x00, 0xff, 0xff, 0xff, 0x00, 0x80, 0xbc, 0xbc, 0xbc, 0xff},
+		},
+		{
+			"clip exposure",
+			ToneMapClip, 1,
+			[]uint8{0xff, 0xbc, 0x00, 0xff, 0xff, 0xff, 0x00, 0x80, 0xff, 0xff, 0xff, 0xff},
+		},
+		{
+			"Reinhard",
+			ToneMapReinhard, 0,
+			[]uint8{0xbc, 0x7c, 0x00, 0xff, 0xff, 0xe1, 0x00, 0x80, 0x9b, 0x9b, 0x9b, 0xff},
+		},
+		{
+			"ACES",
+			ToneMapACES, 0,
+			[]uint8{0xe8, 0xa5, 0x00, 0xff, 0xff, 0xfa, 0x00, 0x80, 0xce, 0xce, 0xce, 0xff},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := ToneMap(img, tc.op, tc.exposure)
+			if !compareBytes(got.Pix, tc.want, 1) {
+				t.Fatalf("got pixels %#v want %#v", got.Pix, tc.want)
+			}
+		})
+	}
+}
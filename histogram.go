@@ -19,7 +19,7 @@ func Histogram(img image.Image) [256]float64 {
 		return histogram
 	}
 
-	parallel(0, src.h, func(ys <-chan int) {
+	parallel(0, src.h, src.w*costPoint, func(ys <-chan int) {
 		var tmpHistogram [256]float64
 		var tmpTotal float64
 		scanLine := make([]uint8, src.w*4)
@@ -50,3 +50,50 @@ func Histogram(img image.Image) [256]float64 {
 	}
 	return histogram
 }
+
+// colorHistogram returns the normalized histograms of the red, green and blue channels
+// of an image with the given number of bins each. Each histogram sums up to 1.
+func colorHistogram(img image.Image, bins int) [3][]float64 {
+	var mu sync.Mutex
+	var histogram [3][]float64
+	for c := range histogram {
+		histogram[c] = make([]float64, bins)
+	}
+
+	src := newScanner(img)
+	if src.w == 0 || src.h == 0 {
+		return histogram
+	}
+
+	var total float64
+	parallel(0, src.h, src.w*costPoint, func(ys <-chan int) {
+		tmpHistogram := make([]float64, 3*bins)
+		var tmpTotal float64
+		scanLine := make([]uint8, src.w*4)
+		for y := range ys {
+			src.scan(0, y, src.w, y+1, scanLine)
+			for i := 0; i < len(scanLine); i += 4 {
+				s := scanLine[i : i+3 : i+3]
+				tmpHistogram[int(s[0])*bins/256]++
+				tmpHistogram[bins+int(s[1])*bins/256]++
+				tmpHistogram[2*bins+int(s[2])*bins/256]++
+				tmpTotal++
+			}
+		}
+		mu.Lock()
+		for c := range histogram {
+			for i := range histogram[c] {
+				histogram[c][i] += tmpHistogram[c*bins+i]
+			}
+		}
+		total += tmpTotal
+		mu.Unlock()
+	})
+
+	for c := range histogram {
+		for i := range histogram[c] {
+			histogram[c][i] /= total
+		}
+	}
+	return histogram
+}
@@ -0,0 +1,274 @@
+/*
+Package httpthumb provides an http.Handler that serves resized versions of images.
+
+The handler loads the source image named by the request path, resizes it according to
+the query parameters and encodes the result:
+
+	width, w   - the width of the thumbnail
+	height, h  - the height of the thumbnail
+	fit        - the resizing mode: "resize" (default), "fit" or "fill"
+	format     - the output format: "jpeg", "png", "gif", "tiff", "bmp" or "avif"
+
+For example, the request "/photos/cat.jpg?w=320&h=240&fit=fill&format=png" returns
+the image "photos/cat.jpg" scaled and cropped to 320x240 pixels, encoded as PNG.
+*/
+package httpthumb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"io/fs"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/disintegration/imaging"
+)
+
+// Loader loads the source images.
+type Loader interface {
+	// Load returns the image with the given name. The name is the request path without
+	// the leading slash. Errors wrapping fs.ErrNotExist are reported as 404 Not Found.
+	Load(ctx context.Context, name string) (image.Image, error)
+}
+
+// LoaderFunc is an adapter to allow the use of ordinary functions as Loaders.
+type LoaderFunc func(ctx context.Context, name string) (image.Image, error)
+
+// Load calls f(ctx, name).
+func (f LoaderFunc) Load(ctx context.Context, name string) (image.Image, error) {
+	return f(ctx, name)
+}
+
+// FSLoader returns a Loader that opens the images from the file system fsys.
+// The images are transformed according to the EXIF orientation tag, and the images
+// larger than DefaultMaxSourcePixels or DefaultMaxSourceBytes are rejected before decoding.
+// The options, such as imaging.DecodeLimits, override the defaults.
+func FSLoader(fsys fs.FS, opts ...imaging.DecodeOption) Loader {
+	opts = append([]imaging.DecodeOption{
+		imaging.AutoOrientation(true),
+		imaging.DecodeLimits(imaging.Limits{
+			MaxPixels: DefaultMaxSourcePixels,
+			MaxBytes:  DefaultMaxSourceBytes,
+		}),
+	}, opts...)
+	return LoaderFunc(func(ctx context.Context, name string) (image.Image, error) {
+		return imaging.OpenFS(fsys, name, opts...)
+	})
+}
+
+// Default values of the Handler and FSLoader parameters.
+const (
+	DefaultMaxWidth        = 2048
+	DefaultMaxHeight       = 2048
+	DefaultMaxAge          = 24 * time.Hour
+	DefaultMaxSourcePixels = 100000000
+	DefaultMaxSourceBytes  = 100 << 20
+)
+
+// Handler is an http.Handler that serves thumbnails of the images returned by the Loader.
+// The zero values of the fields other than Loader mean the defaults.
+//
+// Example:
+//
+//	http.Handle("/thumbs/", http.StripPrefix("/thumbs/", &httpthumb.Handler{
+//		Loader: httpthumb.FSLoader(os.DirFS("photos")),
+//		MaxAge: time.Hour,
+//	}))
+//
+type Handler struct {
+	// Loader loads the source images. It must be set.
+	Loader Loader
+
+	// Filter is the resampling filter. The default is imaging.Lanczos.
+	Filter imaging.ResampleFilter
+
+	// MaxWidth and MaxHeight limit the thumbnail size, including the dimensions
+	// derived from the source image, e.g. the height of "?w=100" or the size of the source
+	// served without the width and the height. The defaults are DefaultMaxWidth and DefaultMaxHeight.
+	MaxWidth, MaxHeight int
+
+	// MaxAge is the max-age of the Cache-Control header. The default is DefaultMaxAge,
+	// a negative value disables the caching.
+	MaxAge time.Duration
+
+	// Options are the additional encoding options, e.g. imaging.JPEGQuality.
+	Options []imaging.EncodeOption
+}
+
+// request is a parsed thumbnail request.
+type request struct {
+	width, height int
+	fit           string
+	format        imaging.Format
+}
+
+// ServeHTTP serves the thumbnail of the image named by the request path.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	req, err := h.parse(name, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	img, err := h.Loader.Load(r.Context(), name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, "failed to load the image", http.StatusInternalServerError)
+		return
+	}
+
+	maxWidth, maxHeight := h.maxSize()
+	if width, height := req.outputSize(img.Bounds().Size()); width > maxWidth || height > maxHeight {
+		http.Error(w, fmt.Sprintf("the maximum size is %dx%d", maxWidth, maxHeight), http.StatusBadRequest)
+		return
+	}
+
+	filter := h.Filter
+	if filter.Kernel == nil {
+		filter = imaging.Lanczos
+	}
+	switch req.fit {
+	case "fit":
+		img = imaging.Fit(img, req.width, req.height, filter)
+	case "fill":
+		img = imaging.Fill(img, req.width, req.height, imaging.Center, filter)
+	default:
+		if req.width > 0 || req.height > 0 {
+			img = imaging.Resize(img, req.width, req.height, filter)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := imaging.Encode(&buf, img, req.format, h.Options...); err != nil {
+		http.Error(w, "failed to encode the image", http.StatusInternalServerError)
+		return
+	}
+
+	hash := fnv.New64a()
+	hash.Write(buf.Bytes())
+	header := w.Header()
+	if ct, ok := contentTypes[req.format]; ok {
+		// Otherwise ServeContent detects the type of the formats registered with imaging.RegisterFormat.
+		header.Set("Content-Type", ct)
+	}
+	header.Set("ETag", fmt.Sprintf(`"%016x"`, hash.Sum64()))
+	maxAge := h.MaxAge
+	if maxAge == 0 {
+		maxAge = DefaultMaxAge
+	}
+	if maxAge > 0 {
+		header.Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int64(maxAge/time.Second)))
+	} else {
+		header.Set("Cache-Control", "no-store")
+	}
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(buf.Bytes()))
+}
+
+var contentTypes = map[imaging.Format]string{
+	imaging.JPEG: "image/jpeg",
+	imaging.PNG:  "image/png",
+	imaging.GIF:  "image/gif",
+	imaging.TIFF: "image/tiff",
+	imaging.BMP:  "image/bmp",
+	imaging.AVIF: "image/avif",
+}
+
+// parse parses the query parameters of the request.
+func (h *Handler) parse(name string, r *http.Request) (request, error) {
+	q := r.URL.Query()
+	var req request
+	var err error
+	if req.width, err = dimension(q.Get("width"), q.Get("w")); err != nil {
+		return req, errors.New("invalid width")
+	}
+	if req.height, err = dimension(q.Get("height"), q.Get("h")); err != nil {
+		return req, errors.New("invalid height")
+	}
+	maxWidth, maxHeight := h.maxSize()
+	if req.width > maxWidth || req.height > maxHeight {
+		return req, fmt.Errorf("the maximum size is %dx%d", maxWidth, maxHeight)
+	}
+
+	req.fit = q.Get("fit")
+	switch req.fit {
+	case "", "resize":
+	case "fit", "fill":
+		if req.width == 0 || req.height == 0 {
+			return req, fmt.Errorf("fit=%s requires both width and height", req.fit)
+		}
+	default:
+		return req, errors.New("invalid fit")
+	}
+
+	if format := q.Get("format"); format != "" {
+		if req.format, err = imaging.FormatFromExtension(format); err != nil {
+			return req, errors.New("invalid format")
+		}
+	} else if req.format, err = imaging.FormatFromFilename(name); err != nil {
+		req.format = imaging.JPEG
+	}
+	return req, nil
+}
+
+// maxSize returns the maximum thumbnail size.
+func (h *Handler) maxSize() (int, int) {
+	maxWidth, maxHeight := h.MaxWidth, h.MaxHeight
+	if maxWidth <= 0 {
+		maxWidth = DefaultMaxWidth
+	}
+	if maxHeight <= 0 {
+		maxHeight = DefaultMaxHeight
+	}
+	return maxWidth, maxHeight
+}
+
+// outputSize returns the size of the thumbnail of the source image of the given size.
+// The fit and fill modes never exceed the requested size, the resize mode derives
+// the missing dimensions from the source the same way as imaging.Resize.
+func (req request) outputSize(src image.Point) (int, int) {
+	switch {
+	case req.fit == "fit" || req.fit == "fill" || (req.width > 0 && req.height > 0):
+		return req.width, req.height
+	case src.X <= 0 || src.Y <= 0:
+		return 0, 0
+	case req.width > 0:
+		return req.width, max(1, int(math.Floor(float64(req.width)*float64(src.Y)/float64(src.X)+0.5)))
+	case req.height > 0:
+		return max(1, int(math.Floor(float64(req.height)*float64(src.X)/float64(src.Y)+0.5))), req.height
+	}
+	return src.X, src.Y
+}
+
+// dimension parses a thumbnail dimension given by the long or the short parameter name.
+// A missing dimension is 0.
+func dimension(long, short string) (int, error) {
+	s := long
+	if s == "" {
+		s = short
+	}
+	if s == "" {
+		return 0, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < 0 {
+		return 0, errors.New("invalid dimension")
+	}
+	return v, nil
+}
@@ -0,0 +1,151 @@
+package httpthumb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/disintegration/imaging"
+)
+
+func testHandler(t *testing.T) *Handler {
+	src, err := imaging.EncodeBytes(imaging.New(400, 200, color.NRGBA{200, 100, 50, 255}), imaging.PNG)
+	if err != nil {
+		t.Fatalf("EncodeBytes: %v", err)
+	}
+	return &Handler{
+		Loader: FSLoader(fstest.MapFS{"img/src.png": &fstest.MapFile{Data: src}}),
+		MaxAge: -1,
+	}
+}
+
+func TestHandler(t *testing.T) {
+	testCases := []struct {
+		url         string
+		status      int
+		size        image.Point
+		contentType string
+	}{
+		{"/img/src.png", http.StatusOK, image.Pt(400, 200), "image/png"},
+		{"/img/src.png?w=100", http.StatusOK, image.Pt(100, 50), "image/png"},
+		{"/img/src.png?height=50&width=50", http.StatusOK, image.Pt(50, 50), "image/png"},
+		{"/img/src.png?w=100&h=100&fit=fit", http.StatusOK, image.Pt(100, 50), "image/png"},
+		{"/img/src.png?w=100&h=100&fit=fill&format=jpg", http.StatusOK, image.Pt(100, 100), "image/jpeg"},
+		{"/img/src.png?w=10&format=gif", http.StatusOK, image.Pt(10, 5), "image/gif"},
+		{"/img/src.png?w=abc", http.StatusBadRequest, image.Point{}, ""},
+		{"/img/src.png?h=-1", http.StatusBadRequest, image.Point{}, ""},
+		{"/img/src.png?w=5000", http.StatusBadRequest, image.Point{}, ""},
+		{"/img/src.png?w=100&fit=fill", http.StatusBadRequest, image.Point{}, ""},
+		{"/img/src.png?fit=crop", http.StatusBadRequest, image.Point{}, ""},
+		{"/img/src.png?format=webp", http.StatusBadRequest, image.Point{}, ""},
+		{"/img/missing.png", http.StatusNotFound, image.Point{}, ""},
+	}
+	h := testHandler(t)
+	for _, tc := range testCases {
+		t.Run(tc.url, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.url, nil))
+			if rec.Code != tc.status {
+				t.Fatalf("got status %d want %d", rec.Code, tc.status)
+			}
+			if tc.status != http.StatusOK {
+				return
+			}
+			if got := rec.Header().Get("Content-Type"); got != tc.contentType {
+				t.Fatalf("got Content-Type %q want %q", got, tc.contentType)
+			}
+			img, err := imaging.Decode(bytes.NewReader(rec.Body.Bytes()))
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if got := img.Bounds().Size(); got != tc.size {
+				t.Fatalf("got size %v want %v", got, tc.size)
+			}
+		})
+	}
+}
+
+func TestHandlerCaching(t *testing.T) {
+	h := testHandler(t)
+	h.MaxAge = 0
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/img/src.png?w=40", nil))
+	if got, want := rec.Header().Get("Cache-Control"), "public, max-age=86400"; got != want {
+		t.Fatalf("got Cache-Control %q want %q", got, want)
+	}
+	etag := rec.Header().Get("ETag")
+	if etag == "" {
+		t.Fatalf("no ETag")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/img/src.png?w=40", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Fatalf("got status %d want %d", rec.Code, http.StatusNotModified)
+	}
+
+	h.MaxAge = -1
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/img/src.png?w=40", nil))
+	if got, want := rec.Header().Get("Cache-Control"), "no-store"; got != want {
+		t.Fatalf("got Cache-Control %q want %q", got, want)
+	}
+}
+
+func TestHandlerErrors(t *testing.T) {
+	h := &Handler{Loader: LoaderFunc(func(ctx context.Context, name string) (image.Image, error) {
+		return nil, errors.New("storage is down")
+	})}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/a.jpg", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("got status %d want %d", rec.Code, http.StatusInternalServerError)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/a.jpg", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("got status %d want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestHandlerOutputSize(t *testing.T) {
+	src, err := imaging.EncodeBytes(imaging.New(10, 1000, color.NRGBA{200, 100, 50, 255}), imaging.PNG)
+	if err != nil {
+		t.Fatalf("EncodeBytes: %v", err)
+	}
+	fsys := fstest.MapFS{"tall.png": &fstest.MapFile{Data: src}}
+	h := &Handler{Loader: FSLoader(fsys), MaxWidth: 500, MaxHeight: 500, MaxAge: -1}
+	testCases := []struct {
+		url    string
+		status int
+	}{
+		{"/tall.png?w=100", http.StatusBadRequest},
+		{"/tall.png", http.StatusBadRequest},
+		{"/tall.png?w=5", http.StatusOK},
+		{"/tall.png?h=500", http.StatusOK},
+		{"/tall.png?w=100&h=100&fit=fit", http.StatusOK},
+	}
+	for _, tc := range testCases {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.url, nil))
+		if rec.Code != tc.status {
+			t.Fatalf("%s: got status %d want %d", tc.url, rec.Code, tc.status)
+		}
+	}
+
+	// The source images exceeding the decode limits aren't decoded.
+	h.Loader = FSLoader(fsys, imaging.DecodeLimits(imaging.Limits{MaxPixels: 1000}))
+	if _, err := h.Loader.Load(context.Background(), "tall.png"); err != imaging.ErrLimitExceeded {
+		t.Fatalf("got error %v want imaging.ErrLimitExceeded", err)
+	}
+}
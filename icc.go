@@ -0,0 +1,1000 @@
+package imaging
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"image"
+	"io"
+	"io/ioutil"
+	"math"
+	"sort"
+)
+
+// ErrUnsupportedProfile means the ICC profile can't be used for color conversion.
+var ErrUnsupportedProfile = errors.New("imaging: unsupported ICC profile")
+
+// ReadICCProfile reads the embedded ICC color profile from JPEG, PNG or TIFF image data in r.
+// It returns nil if the image doesn't contain a profile.
+//
+// Example:
+//
+//	data, err := ioutil.ReadFile("photo.jpg")
+//	...
+//	profile, err := imaging.ReadICCProfile(bytes.NewReader(data))
+//	img, err := imaging.Decode(bytes.NewReader(data))
+//	img = imaging.Resize(img, 800, 0, imaging.Lanczos)
+//	err = imaging.Encode(w, img, imaging.JPEG, imaging.ICCProfile(profile))
+//
+func ReadICCProfile(r io.Reader) ([]byte, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(data, []byte("\xff\xd8")):
+		return readJPEGICCProfile(data)
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return readPNGICCProfile(data)
+	case bytes.HasPrefix(data, []byte("II\x2a\x00")), bytes.HasPrefix(data, []byte("MM\x00\x2a")):
+		return readTIFFICCProfile(data)
+	}
+	return nil, ErrUnsupportedFormat
+}
+
+const jpegICCHeader = "ICC_PROFILE\x00"
+
+func readJPEGICCProfile(data []byte) ([]byte, error) {
+	type chunk struct {
+		seq  int
+		data []byte
+	}
+	var chunks []chunk
+
+	i := 2
+	for i+4 <= len(data) {
+		if data[i] != 0xff {
+			return nil, errors.New("imaging: invalid JPEG marker")
+		}
+		marker := data[i+1]
+		if marker == 0xda || marker == 0xd9 { // SOS or EOI: no more metadata.
+			break
+		}
+		size := int(binary.BigEndian.Uint16(data[i+2:]))
+		if size < 2 || i+2+size > len(data) {
+			return nil, errors.New("imaging: invalid JPEG segment size")
+		}
+		seg := data[i+4 : i+2+size]
+		if marker == 0xe2 && len(seg) > len(jpegICCHeader)+2 && string(seg[:len(jpegICCHeader)]) == jpegICCHeader {
+			seg = seg[len(jpegICCHeader):]
+			chunks = append(chunks, chunk{seq: int(seg[0]), data: seg[2:]})
+		}
+		i += 2 + size
+	}
+
+	if len(chunks) == 0 {
+		return nil, nil
+	}
+	sort.SliceStable(chunks, func(i, j int) bool { return chunks[i].seq < chunks[j].seq })
+	var profile []byte
+	for _, c := range chunks {
+		profile = append(profile, c.data...)
+	}
+	return profile, nil
+}
+
+// maxICCProfileSize is the maximum size of the compressed ICC profiles after decompression.
+// It's slightly more than the largest profile that fits in the JPEG APP2 segments.
+const maxICCProfileSize = 16 << 20
+
+func readPNGICCProfile(data []byte) ([]byte, error) {
+	i := 8
+	for i+8 <= len(data) {
+		size := int(binary.BigEndian.Uint32(data[i:]))
+		name := string(data[i+4 : i+8])
+		if size < 0 || i+12+size > len(data) {
+			return nil, errors.New("imaging: invalid PNG chunk size")
+		}
+		if name == "IDAT" {
+			break
+		}
+		if name == "iCCP" {
+			chunk := data[i+8 : i+8+size]
+			// Profile name, null separator, compression method and compressed profile.
+			n := bytes.IndexByte(chunk, 0)
+			if n < 0 || n+2 > len(chunk) || chunk[n+1] != 0 {
+				return nil, errors.New("imaging: invalid PNG iCCP chunk")
+			}
+			zr, err := zlib.NewReader(bytes.NewReader(chunk[n+2:]))
+			if err != nil {
+				return nil, err
+			}
+			profile, err := ioutil.ReadAll(io.LimitReader(zr, maxICCProfileSize+1))
+			if err != nil {
+				return nil, err
+			}
+			if len(profile) > maxICCProfileSize {
+				return nil, errors.New("imaging: PNG iCCP profile is too large")
+			}
+			return profile, nil
+		}
+		i += 12 + size
+	}
+	return nil, nil
+}
+
+func readTIFFICCProfile(data []byte) ([]byte, error) {
+	const tagICCProfile = 34675
+
+	if len(data) < 8 {
+		return nil, errors.New("imaging: invalid TIFF header")
+	}
+	var bo binary.ByteOrder = binary.LittleEndian
+	if data[0] == 'M' {
+		bo = binary.BigEndian
+	}
+	ifd := int(bo.Uint32(data[4:]))
+	if ifd+2 > len(data) {
+		return nil, errors.New("imaging: invalid TIFF IFD offset")
+	}
+	n := int(bo.Uint16(data[ifd:]))
+	for k := 0; k < n; k++ {
+		e := ifd + 2 + k*12
+		if e+12 > len(data) {
+			return nil, errors.New("imaging: invalid TIFF IFD")
+		}
+		if bo.Uint16(data[e:]) != tagICCProfile {
+			continue
+		}
+		count := int(bo.Uint32(data[e+4:]))
+		if count <= 4 {
+			return append([]byte(nil), data[e+8:e+8+count]...), nil
+		}
+		off := int(bo.Uint32(data[e+8:]))
+		if off < 0 || off+count > len(data) {
+			return nil, errors.New("imaging: invalid TIFF ICC profile offset")
+		}
+		return append([]byte(nil), data[off:off+count]...), nil
+	}
+	return nil, nil
+}
+
+// insertWriter passes the data through to w and inserts extra data after the given number of bytes.
+type insertWriter struct {
+	w      io.Writer
+	offset int
+	data   []byte
+}
+
+func (iw *insertWriter) Write(p []byte) (int, error) {
+	if iw.data == nil || iw.offset >= len(p) {
+		iw.offset -= len(p)
+		return iw.w.Write(p)
+	}
+	n, err := iw.w.Write(p[:iw.offset])
+	if err != nil {
+		return n, err
+	}
+	if _, err := iw.w.Write(iw.data); err != nil {
+		return n, err
+	}
+	iw.data = nil
+	m, err := iw.w.Write(p[iw.offset:])
+	return n + m, err
+}
+
+// embedICCProfile returns a writer that inserts the profile into the JPEG or PNG data written to w.
+func embedICCProfile(w io.Writer, format Format, profile []byte) io.Writer {
+	buf := &bytes.Buffer{}
+	switch format {
+	case JPEG:
+		const maxChunk = 65535 - 2 - len(jpegICCHeader) - 2
+		count := (len(profile) + maxChunk - 1) / maxChunk
+		for seq := 1; len(profile) > 0; seq++ {
+			n := len(profile)
+			if n > maxChunk {
+				n = maxChunk
+			}
+			buf.Write([]byte{0xff, 0xe2})
+			binary.Write(buf, binary.BigEndian, uint16(2+len(jpegICCHeader)+2+n))
+			buf.WriteString(jpegICCHeader)
+			buf.Write([]byte{byte(seq), byte(count)})
+			buf.Write(profile[:n])
+			profile = profile[n:]
+		}
+		// Insert after the SOI marker.
+		return &insertWriter{w: w, offset: 2, data: buf.Bytes()}
+
+	case PNG:
+		chunk := &bytes.Buffer{}
+		chunk.WriteString("ICC Profile\x00\x00")
+		zw := zlib.NewWriter(chunk)
+		zw.Write(profile)
+		zw.Close()
+		writePNGChunk(buf, "iCCP", chunk.Bytes())
+		// Insert after the signature and the IHDR chunk.
+		return &insertWriter{w: w, offset: 8 + 8 + 13 + 4, data: buf.Bytes()}
+	}
+	return w
+}
+
+// iccProfile is a parsed matrix/TRC RGB ICC profile.
+type iccProfile struct {
+	matrix [9]float64 // RGB to XYZ (D50), row-major.
+	curves [3][256]float64
+	white  [3]float64 // Media white point (XYZ).
+}
+
+// iccD50 is the ICC profile connection space illuminant (XYZ).
+var iccD50 = [3]float64{0.9642, 1.0, 0.8249}
+
+func parseICCProfile(data []byte) (*iccProfile, error) {
+	if len(data) < 132 || string(data[16:20]) != "RGB " {
+		return nil, ErrUnsupportedProfile
+	}
+	tags, err := parseICCTags(data)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &iccProfile{}
+	for c, sig := range []string{"rXYZ", "gXYZ", "bXYZ"} {
+		t := tags[sig]
+		if len(t) < 20 || string(t[:4]) != "XYZ " {
+			return nil, ErrUnsupportedProfile
+		}
+		for k := 0; k < 3; k++ {
+			p.matrix[k*3+c] = s15Fixed16(t[8+k*4:])
+		}
+	}
+	for c, sig := range []string{"rTRC", "gTRC", "bTRC"} {
+		if err := parseICCCurve(tags[sig], &p.curves[c]); err != nil {
+			return nil, err
+		}
+	}
+	p.white = iccWhitePoint(tags)
+	return p, nil
+}
+
+// parseICCTags returns the data of the tags of the profile by their signatures.
+func parseICCTags(data []byte) (map[string][]byte, error) {
+	if len(data) < 132 {
+		return nil, ErrUnsupportedProfile
+	}
+	tags := make(map[string][]byte)
+	n := int(binary.BigEndian.Uint32(data[128:]))
+	for i := 0; i < n; i++ {
+		e := 132 + i*12
+		if e+12 > len(data) {
+			return nil, ErrUnsupportedProfile
+		}
+		off := int(binary.BigEndian.Uint32(data[e+4:]))
+		size := int(binary.BigEndian.Uint32(data[e+8:]))
+		if off < 0 || size < 0 || off+size > len(data) {
+			return nil, ErrUnsupportedProfile
+		}
+		tags[string(data[e:e+4])] = data[off : off+size]
+	}
+	return tags, nil
+}
+
+// iccWhitePoint returns the media white point of the profile, D50 if it's missing.
+func iccWhitePoint(tags map[string][]byte) [3]float64 {
+	white := iccD50
+	if t := tags["wtpt"]; len(t) >= 20 && string(t[:4]) == "XYZ " {
+		for k := 0; k < 3; k++ {
+			white[k] = s15Fixed16(t[8+k*4:])
+		}
+	}
+	return white
+}
+
+func s15Fixed16(b []byte) float64 {
+	return float64(int32(binary.BigEndian.Uint32(b))) / 65536
+}
+
+// parseICCCurve fills the lookup table mapping 8-bit encoded values to linear values.
+func parseICCCurve(t []byte, lut *[256]float64) error {
+	fn, _, err := iccCurveFunc(t)
+	if err != nil {
+		return err
+	}
+	for i := range lut {
+		lut[i] = math.Min(math.Max(fn(float64(i)/255), 0), 1)
+	}
+	return nil
+}
+
+// iccCurveFunc returns the function of the curv or para curve at the start of t
+// and the size of the curve data padded to 4 bytes.
+func iccCurveFunc(t []byte) (func(x float64) float64, int, error) {
+	if len(t) < 12 {
+		return nil, 0, ErrUnsupportedProfile
+	}
+
+	var fn func(x float64) float64
+	var size int
+	switch string(t[:4]) {
+	case "curv":
+		n := int(binary.BigEndian.Uint32(t[8:]))
+		if n < 0 || n > len(t) || len(t) < 12+n*2 {
+			return nil, 0, ErrUnsupportedProfile
+		}
+		size = 12 + n*2
+		switch n {
+		case 0:
+			fn = func(x float64) float64 { return x }
+		case 1:
+			g := float64(binary.BigEndian.Uint16(t[12:])) / 256
+			fn = func(x float64) float64 { return math.Pow(x, g) }
+		default:
+			fn = func(x float64) float64 {
+				pos := x * float64(n-1)
+				i := int(pos)
+				if i >= n-1 {
+					return float64(binary.BigEndian.Uint16(t[12+(n-1)*2:])) / 65535
+				}
+				v0 := float64(binary.BigEndian.Uint16(t[12+i*2:]))
+				v1 := float64(binary.BigEndian.Uint16(t[12+(i+1)*2:]))
+				return (v0 + (v1-v0)*(pos-float64(i))) / 65535
+			}
+		}
+
+	case "para":
+		numParams := []int{1, 3, 4, 5, 7}
+		typ := int(binary.BigEndian.Uint16(t[8:]))
+		if typ >= len(numParams) || len(t) < 12+numParams[typ]*4 {
+			return nil, 0, ErrUnsupportedProfile
+		}
+		size = 12 + numParams[typ]*4
+		var p [7]float64
+		for i := 0; i < numParams[typ]; i++ {
+			p[i] = s15Fixed16(t[12+i*4:])
+		}
+		g, a, b, c, d, e, f := p[0], p[1], p[2], p[3], p[4], p[5], p[6]
+		fn = func(x float64) float64 {
+			switch typ {
+			case 1:
+				if x >= -b/a {
+					return math.Pow(a*x+b, g)
+				}
+				return 0
+			case 2:
+				if x >= -b/a {
+					return math.Pow(a*x+b, g) + c
+				}
+				return c
+			case 3:
+				if x >= d {
+					return math.Pow(a*x+b, g)
+				}
+				return c * x
+			case 4:
+				if x >= d {
+					return math.Pow(a*x+b, g) + e
+				}
+				return c*x + f
+			}
+			return math.Pow(x, g)
+		}
+
+	default:
+		return nil, 0, ErrUnsupportedProfile
+	}
+	return fn, (size + 3) &^ 3, nil
+}
+
+// xyzD50ToLinearSRGB converts D50-adapted XYZ values to linear sRGB (Bradford adaptation).
+var xyzD50ToLinearSRGB = [9]float64{
+	3.1338561, -1.6168667, -0.4906146,
+	-0.9787684, 1.9161415, 0.0334540,
+	0.0719453, -0.2289914, 1.4052427,
+}
+
+// ConvertToSRGB converts the colors of the image described by the given ICC profile
+// to the sRGB color space and returns the converted image. It's useful for images with
+// a wide-gamut profile (e.g. Display P3 or Adobe RGB), that look desaturated if their
+// pixels are interpreted as sRGB. Only RGB matrix/TRC profiles are supported,
+// ErrUnsupportedProfile is returned for other profiles.
+//
+// Example:
+//
+//	profile, err := imaging.ReadICCProfile(bytes.NewReader(data))
+//	...
+//	if profile != nil {
+//		img, err = imaging.ConvertToSRGB(img, profile)
+//	}
+//
+func ConvertToSRGB(img image.Image, profile []byte) (*image.NRGBA, error) {
+	p, err := parseICCProfile(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	var m [9]float64
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			for k := 0; k < 3; k++ {
+				m[i*3+j] += xyzD50ToLinearSRGB[i*3+k] * p.matrix[k*3+j]
+			}
+		}
+	}
+	lut := linearToSRGBTable()
+
+	src := newScanner(img)
+	dst := image.NewNRGBA(image.Rect(0, 0, src.w, src.h))
+	parallel(0, src.h, src.w*costPoint, func(ys <-chan int) {
+		for y := range ys {
+			i := y * dst.Stride
+			src.scan(0, y, src.w, y+1, dst.Pix[i:i+src.w*4])
+			for x := 0; x < src.w; x++ {
+				d := dst.Pix[i : i+3 : i+3]
+				r := p.curves[0][d[0]]
+				g := p.curves[1][d[1]]
+				b := p.curves[2][d[2]]
+				d[0] = lut[clamp16((m[0]*r+m[1]*g+m[2]*b)*65535)]
+				d[1] = lut[clamp16((m[3]*r+m[4]*g+m[5]*b)*65535)]
+				d[2] = lut[clamp16((m[6]*r+m[7]*g+m[8]*b)*65535)]
+				i += 4
+			}
+		}
+	})
+	return dst, nil
+}
+
+// Intent is an ICC rendering intent. It specifies how the colors that are out of the gamut
+// of the destination color space are handled.
+type Intent int
+
+// Rendering intents. The values match the ICC specification.
+const (
+	Perceptual Intent = iota
+	RelativeColorimetric
+	Saturation
+	AbsoluteColorimetric
+)
+
+// ConvertFromSRGB converts the colors of the image from the sRGB color space to the RGB color
+// space described by the given ICC profile and returns the converted image. It's the inverse
+// of ConvertToSRGB. Both the matrix/TRC profiles, such as Display P3, and the LUT-based
+// output profiles (the BToA tags), such as the RGB printer profiles, are supported.
+// The LUT-based profiles have a separate transform for each rendering intent. Matrix/TRC
+// profiles define a single colorimetric transform, so the Perceptual, RelativeColorimetric
+// and Saturation intents give the same result, while AbsoluteColorimetric also preserves
+// the media white point of the profile. Use ConvertToCMYK for the CMYK profiles,
+// ErrUnsupportedProfile is returned for them and the other unsupported profiles.
+//
+// Example:
+//
+//	// Convert the image to Display P3 and save it with the profile embedded.
+//	err := imaging.Save(img, "out.png", imaging.TargetProfile(displayP3), imaging.RenderingIntent(imaging.Perceptual))
+//
+func ConvertFromSRGB(img image.Image, profile []byte, intent Intent) (*image.NRGBA, error) {
+	if l, err := parseICCOutputLUT(profile, intent); err != errNoICCOutputLUT {
+		if err != nil || l.outputs != 3 {
+			return nil, ErrUnsupportedProfile
+		}
+		return l.convertRGB(img), nil
+	}
+
+	p, err := parseICCProfile(profile)
+	if err != nil {
+		return nil, err
+	}
+	toRGB, ok := invert3x3(p.matrix)
+	if !ok {
+		return nil, ErrUnsupportedProfile
+	}
+	toXYZ, _ := invert3x3(xyzD50ToLinearSRGB)
+	if intent == AbsoluteColorimetric {
+		for i := 0; i < 3; i++ {
+			for j := 0; j < 3; j++ {
+				toXYZ[i*3+j] *= iccD50[i] / p.white[i]
+			}
+		}
+	}
+
+	var m [9]float64
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			for k := 0; k < 3; k++ {
+				m[i*3+j] += toRGB[i*3+k] * toXYZ[k*3+j]
+			}
+		}
+	}
+	var luts [3][]uint8
+	for c := range luts {
+		luts[c] = inverseICCCurve(&p.curves[c])
+	}
+	last := float64(len(luts[0]) - 1)
+
+	src := newScanner(img)
+	dst := image.NewNRGBA(image.Rect(0, 0, src.w, src.h))
+	parallel(0, src.h, src.w*costPoint, func(ys <-chan int) {
+		for y := range ys {
+			i := y * dst.Stride
+			src.scan(0, y, src.w, y+1, dst.Pix[i:i+src.w*4])
+			for x := 0; x < src.w; x++ {
+				d := dst.Pix[i : i+3 : i+3]
+				r := float64(srgbToLinearLUT[d[0]]) / 65535
+				g := float64(srgbToLinearLUT[d[1]]) / 65535
+				b := float64(srgbToLinearLUT[d[2]]) / 65535
+				for c := 0; c < 3; c++ {
+					v := math.Min(math.Max(m[c*3]*r+m[c*3+1]*g+m[c*3+2]*b, 0), 1)
+					d[c] = luts[c][int(v*last+0.5)]
+				}
+				i += 4
+			}
+		}
+	})
+	return dst, nil
+}
+
+// ConvertToCMYK converts the colors of the image from the sRGB color space to the CMYK color
+// space described by the given ICC output profile, such as a printer or press profile,
+// using the BToA transform of the rendering intent. The transparent pixels are composited
+// over white, the color of the paper. ErrUnsupportedProfile is returned for the profiles
+// other than the LUT-based CMYK profiles.
+//
+// Example:
+//
+//	// Convert the image for the printing press and save it as a CMYK JPEG with the profile embedded.
+//	err := imaging.Save(img, "print.jpg", imaging.TargetProfile(fogra39), imaging.RenderingIntent(imaging.RelativeColorimetric))
+//
+func ConvertToCMYK(img image.Image, profile []byte, intent Intent) (*image.CMYK, error) {
+	l, err := parseICCOutputLUT(profile, intent)
+	if err != nil || l.outputs != 4 {
+		return nil, ErrUnsupportedProfile
+	}
+	return l.convertCMYK(img), nil
+}
+
+// convertFromSRGB converts the image to the color space of the profile. The result is
+// an *image.CMYK for the CMYK profiles and an *image.NRGBA for the RGB ones.
+func convertFromSRGB(img image.Image, profile []byte, intent Intent) (image.Image, error) {
+	if len(profile) >= 20 && string(profile[16:20]) == "CMYK" {
+		return ConvertToCMYK(img, profile, intent)
+	}
+	return ConvertFromSRGB(img, profile, intent)
+}
+
+// errNoICCOutputLUT means that the profile has no BToA tags.
+var errNoICCOutputLUT = errors.New("imaging: no BToA tag in ICC profile")
+
+// iccLUT is a parsed BToA transform of an ICC output profile. It converts the colors from
+// the profile connection space (PCS), XYZ or CIELAB, to the device color space in the stages
+// of the lutBToAType: the B curves, the matrix, the M curves, the color lookup table (CLUT)
+// and the A curves. The lut8Type and lut16Type transforms have no B curves, their input tables
+// are the M curves and their output tables are the A curves. The missing stages are skipped.
+type iccLUT struct {
+	outputs  int         // The number of the device channels: 3 for RGB and 4 for CMYK.
+	toXYZ    [9]float64  // Linear sRGB to the XYZ values of the PCS, row-major.
+	pcsLab   bool        // The PCS is CIELAB.
+	labScale [3]float64  // Scales L*, a*+128 and b*+128 to [0, 1].
+	bCurves  [][]float64 // The curves are sampled uniformly over [0, 1].
+	matrix   []float64   // 3x3 row-major matrix followed by the offsets, or nil.
+	mCurves  [][]float64
+	grid     [3]int    // The number of the CLUT grid points of each input channel.
+	clut     []float64 // The device values of the grid points, the first input varies slowest.
+	aCurves  [][]float64
+}
+
+// parseICCOutputLUT parses the BToA transform of the profile for the rendering intent.
+// It returns errNoICCOutputLUT if the profile has no BToA tags.
+func parseICCOutputLUT(data []byte, intent Intent) (*iccLUT, error) {
+	tags, err := parseICCTags(data)
+	if err != nil {
+		return nil, err
+	}
+	sigs := map[Intent]string{
+		Perceptual:           "B2A0",
+		RelativeColorimetric: "B2A1",
+		Saturation:           "B2A2",
+		AbsoluteColorimetric: "B2A1",
+	}
+	t, ok := tags[sigs[intent]]
+	if !ok {
+		if t, ok = tags["B2A0"]; !ok {
+			return nil, errNoICCOutputLUT
+		}
+	}
+
+	l := &iccLUT{}
+	switch string(data[16:20]) {
+	case "RGB ":
+		l.outputs = 3
+	case "CMYK":
+		l.outputs = 4
+	default:
+		return nil, ErrUnsupportedProfile
+	}
+	switch string(data[20:24]) {
+	case "XYZ ":
+	case "Lab ":
+		l.pcsLab = true
+	default:
+		return nil, ErrUnsupportedProfile
+	}
+
+	l.toXYZ, _ = invert3x3(xyzD50ToLinearSRGB)
+	if intent == AbsoluteColorimetric {
+		white := iccWhitePoint(tags)
+		for i := 0; i < 3; i++ {
+			for j := 0; j < 3; j++ {
+				l.toXYZ[i*3+j] *= iccD50[i] / white[i]
+			}
+		}
+	}
+
+	if len(t) < 12 {
+		return nil, ErrUnsupportedProfile
+	}
+	switch string(t[:4]) {
+	case "mft1":
+		l.labScale = [3]float64{1.0 / 100, 1.0 / 255, 1.0 / 255}
+		err = l.parseLegacyLUT(t, 1)
+	case "mft2":
+		// The legacy 16-bit CIELAB encoding maps L* 100 to 0xff00 and a* 127 to 0xff00.
+		l.labScale = [3]float64{0xff00 / 65535.0 / 100, 256 / 65535.0, 256 / 65535.0}
+		err = l.parseLegacyLUT(t, 2)
+	case "mBA ":
+		l.labScale = [3]float64{1.0 / 100, 1.0 / 255, 1.0 / 255}
+		err = l.parseLUTBToA(t)
+	default:
+		err = ErrUnsupportedProfile
+	}
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// parseLegacyLUT parses the lut8Type (the value size is 1) or lut16Type (2) transform.
+func (l *iccLUT) parseLegacyLUT(t []byte, size int) error {
+	if len(t) < 52 || t[8] != 3 || int(t[9]) != l.outputs || t[10] < 2 {
+		return ErrUnsupportedProfile
+	}
+	g := int(t[10])
+	l.grid = [3]int{g, g, g}
+
+	// The matrix is only used with the XYZ PCS.
+	if !l.pcsLab {
+		l.matrix = make([]float64, 12)
+		for i := 0; i < 9; i++ {
+			l.matrix[i] = s15Fixed16(t[12+i*4:])
+		}
+	}
+
+	inEntries, outEntries, pos := 256, 256, 48
+	if size == 2 {
+		inEntries = int(binary.BigEndian.Uint16(t[48:]))
+		outEntries = int(binary.BigEndian.Uint16(t[50:]))
+		pos = 52
+	}
+	if inEntries < 2 || outEntries < 2 {
+		return ErrUnsupportedProfile
+	}
+	clutSize := g * g * g * l.outputs
+	if len(t) < pos+(3*inEntries+clutSize+l.outputs*outEntries)*size {
+		return ErrUnsupportedProfile
+	}
+	values := func(n int) []float64 {
+		v := make([]float64, n)
+		for i := range v {
+			if size == 1 {
+				v[i] = float64(t[pos]) / 255
+			} else {
+				v[i] = float64(binary.BigEndian.Uint16(t[pos:])) / 65535
+			}
+			pos += size
+		}
+		return v
+	}
+
+	for c := 0; c < 3; c++ {
+		l.mCurves = append(l.mCurves, values(inEntries))
+	}
+	l.clut = values(clutSize)
+	for c := 0; c < l.outputs; c++ {
+		l.aCurves = append(l.aCurves, values(outEntries))
+	}
+	return nil
+}
+
+// parseLUTBToA parses the lutBToAType transform.
+func (l *iccLUT) parseLUTBToA(t []byte) error {
+	if len(t) < 32 || t[8] != 3 || int(t[9]) != l.outputs {
+		return ErrUnsupportedProfile
+	}
+	offset := func(i int) int { return int(binary.BigEndian.Uint32(t[12+i*4:])) }
+	bOff, matrixOff, mOff, clutOff, aOff := offset(0), offset(1), offset(2), offset(3), offset(4)
+
+	var err error
+	if bOff == 0 {
+		return ErrUnsupportedProfile
+	}
+	if l.bCurves, err = sampleICCCurves(t, bOff, 3); err != nil {
+		return err
+	}
+	if matrixOff != 0 {
+		if matrixOff < 0 || matrixOff+48 > len(t) {
+			return ErrUnsupportedProfile
+		}
+		l.matrix = make([]float64, 12)
+		for i := range l.matrix {
+			l.matrix[i] = s15Fixed16(t[matrixOff+i*4:])
+		}
+	}
+	if mOff != 0 {
+		if l.mCurves, err = sampleICCCurves(t, mOff, 3); err != nil {
+			return err
+		}
+	}
+
+	if clutOff == 0 {
+		// Without the CLUT the number of the channels can't change.
+		if l.outputs != 3 {
+			return ErrUnsupportedProfile
+		}
+	} else {
+		if clutOff < 0 || clutOff+20 > len(t) {
+			return ErrUnsupportedProfile
+		}
+		n := l.outputs
+		for i := range l.grid {
+			l.grid[i] = int(t[clutOff+i])
+			if l.grid[i] < 2 {
+				return ErrUnsupportedProfile
+			}
+			n *= l.grid[i]
+		}
+		precision := int(t[clutOff+16])
+		pos := clutOff + 20
+		if (precision != 1 && precision != 2) || pos+n*precision > len(t) {
+			return ErrUnsupportedProfile
+		}
+		l.clut = make([]float64, n)
+		for i := range l.clut {
+			if precision == 1 {
+				l.clut[i] = float64(t[pos+i]) / 255
+			} else {
+				l.clut[i] = float64(binary.BigEndian.Uint16(t[pos+i*2:])) / 65535
+			}
+		}
+	}
+
+	if aOff != 0 {
+		if l.aCurves, err = sampleICCCurves(t, aOff, l.outputs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sampleICCCurves samples the n consecutive curves at the offset off of t.
+func sampleICCCurves(t []byte, off, n int) ([][]float64, error) {
+	curves := make([][]float64, n)
+	for c := range curves {
+		if off < 0 || off >= len(t) {
+			return nil, ErrUnsupportedProfile
+		}
+		fn, size, err := iccCurveFunc(t[off:])
+		if err != nil {
+			return nil, err
+		}
+		curves[c] = make([]float64, 1024)
+		for i := range curves[c] {
+			curves[c][i] = math.Min(math.Max(fn(float64(i)/1023), 0), 1)
+		}
+		off += size
+	}
+	return curves, nil
+}
+
+// evalICCCurve returns the value of the sampled curve at x in [0, 1].
+func evalICCCurve(curve []float64, x float64) float64 {
+	pos := math.Min(math.Max(x, 0), 1) * float64(len(curve)-1)
+	i := int(pos)
+	if i >= len(curve)-1 {
+		return curve[len(curve)-1]
+	}
+	return curve[i] + (curve[i+1]-curve[i])*(pos-float64(i))
+}
+
+// transform converts the sRGB color to the device values, scaled to [0, 1].
+func (l *iccLUT) transform(r, g, b uint8, dst []float64) {
+	lr := float64(srgbToLinearLUT[r]) / 65535
+	lg := float64(srgbToLinearLUT[g]) / 65535
+	lb := float64(srgbToLinearLUT[b]) / 65535
+	m := &l.toXYZ
+	xyz := [3]float64{
+		m[0]*lr + m[1]*lg + m[2]*lb,
+		m[3]*lr + m[4]*lg + m[5]*lb,
+		m[6]*lr + m[7]*lg + m[8]*lb,
+	}
+
+	var v [3]float64
+	if l.pcsLab {
+		f := func(t float64) float64 {
+			if t > 216.0/24389 {
+				return math.Cbrt(t)
+			}
+			return t*24389/27/116 + 16.0/116
+		}
+		fx, fy, fz := f(xyz[0]/iccD50[0]), f(xyz[1]/iccD50[1]), f(xyz[2]/iccD50[2])
+		v[0] = (116*fy - 16) * l.labScale[0]
+		v[1] = (500*(fx-fy) + 128) * l.labScale[1]
+		v[2] = (200*(fy-fz) + 128) * l.labScale[2]
+	} else {
+		// The XYZ values are encoded as u1Fixed15 numbers.
+		for c := range v {
+			v[c] = xyz[c] * 32768 / 65535
+		}
+	}
+
+	for c := range v {
+		v[c] = math.Min(math.Max(v[c], 0), 1)
+		if l.bCurves != nil {
+			v[c] = evalICCCurve(l.bCurves[c], v[c])
+		}
+	}
+	if mx := l.matrix; mx != nil {
+		v = [3]float64{
+			mx[0]*v[0] + mx[1]*v[1] + mx[2]*v[2] + mx[9],
+			mx[3]*v[0] + mx[4]*v[1] + mx[5]*v[2] + mx[10],
+			mx[6]*v[0] + mx[7]*v[1] + mx[8]*v[2] + mx[11],
+		}
+		for c := range v {
+			v[c] = math.Min(math.Max(v[c], 0), 1)
+		}
+	}
+	if l.mCurves != nil {
+		for c := range v {
+			v[c] = evalICCCurve(l.mCurves[c], v[c])
+		}
+	}
+	if l.clut != nil {
+		l.interpolate(v, dst)
+	} else {
+		copy(dst, v[:])
+	}
+	if l.aCurves != nil {
+		for c := range dst {
+			dst[c] = evalICCCurve(l.aCurves[c], dst[c])
+		}
+	}
+}
+
+// interpolate looks up the input values in the CLUT using the trilinear interpolation.
+func (l *iccLUT) interpolate(v [3]float64, dst []float64) {
+	var i0 [3]int
+	var frac [3]float64
+	for c := range v {
+		pos := v[c] * float64(l.grid[c]-1)
+		i0[c] = min(int(pos), l.grid[c]-2)
+		frac[c] = pos - float64(i0[c])
+	}
+	for c := range dst {
+		dst[c] = 0
+	}
+	for corner := 0; corner < 8; corner++ {
+		wt := 1.0
+		idx := 0
+		for c := 0; c < 3; c++ {
+			i := i0[c]
+			if corner>>(2-c)&1 == 1 {
+				i++
+				wt *= frac[c]
+			} else {
+				wt *= 1 - frac[c]
+			}
+			idx = idx*l.grid[c] + i
+		}
+		if wt == 0 {
+			continue
+		}
+		e := l.clut[idx*l.outputs : idx*l.outputs+l.outputs]
+		for c := range dst {
+			dst[c] += wt * e[c]
+		}
+	}
+}
+
+// convertRGB converts the image to the RGB color space of the profile, keeping the alpha.
+func (l *iccLUT) convertRGB(img image.Image) *image.NRGBA {
+	src := newScanner(img)
+	dst := image.NewNRGBA(image.Rect(0, 0, src.w, src.h))
+	parallel(0, src.h, src.w*costHeavy, func(ys <-chan int) {
+		var v [3]float64
+		for y := range ys {
+			i := y * dst.Stride
+			src.scan(0, y, src.w, y+1, dst.Pix[i:i+src.w*4])
+			for x := 0; x < src.w; x++ {
+				d := dst.Pix[i : i+3 : i+3]
+				l.transform(d[0], d[1], d[2], v[:])
+				for c := range d {
+					d[c] = clamp(v[c] * 255)
+				}
+				i += 4
+			}
+		}
+	})
+	return dst
+}
+
+// convertCMYK converts the image to the CMYK color space of the profile,
+// compositing the transparent pixels over white.
+func (l *iccLUT) convertCMYK(img image.Image) *image.CMYK {
+	src := newScanner(img)
+	dst := image.NewCMYK(image.Rect(0, 0, src.w, src.h))
+	parallel(0, src.h, src.w*costHeavy, func(ys <-chan int) {
+		var v [4]float64
+		row := make([]uint8, src.w*4)
+		for y := range ys {
+			src.scan(0, y, src.w, y+1, row)
+			d := dst.Pix[y*dst.Stride : y*dst.Stride+src.w*4]
+			for x := 0; x < src.w; x++ {
+				s := row[x*4 : x*4+4 : x*4+4]
+				a := uint16(s[3])
+				for c := 0; c < 3; c++ {
+					s[c] = uint8((uint16(s[c])*a + 255*(255-a) + 127) / 255)
+				}
+				l.transform(s[0], s[1], s[2], v[:])
+				for c := range v {
+					d[x*4+c] = clamp(v[c] * 255)
+				}
+			}
+		}
+	})
+	return dst
+}
+
+// inverseICCCurve returns the lookup table mapping linear values (scaled to the table size)
+// to 8-bit encoded values using the inverse of the given curve.
+func inverseICCCurve(curve *[256]float64) []uint8 {
+	lut := make([]uint8, 4096)
+	last := float64(len(lut) - 1)
+	for i := range lut {
+		v := float64(i) / last
+		// Find the encoded value with the nearest linear value, the curve is monotonic.
+		j := sort.Search(256, func(k int) bool { return curve[k] >= v })
+		if j == 256 {
+			j = 255
+		} else if j > 0 && v-curve[j-1] < curve[j]-v {
+			j--
+		}
+		lut[i] = uint8(j)
+	}
+	return lut
+}
+
+// invert3x3 returns the inverse of the row-major 3x3 matrix.
+// It returns false if the matrix is singular.
+func invert3x3(m [9]float64) ([9]float64, bool) {
+	det := m[0]*(m[4]*m[8]-m[5]*m[7]) - m[1]*(m[3]*m[8]-m[5]*m[6]) + m[2]*(m[3]*m[7]-m[4]*m[6])
+	if det == 0 {
+		return [9]float64{}, false
+	}
+	return [9]float64{
+		(m[4]*m[8] - m[5]*m[7]) / det,
+		(m[2]*m[7] - m[1]*m[8]) / det,
+		(m[1]*m[5] - m[2]*m[4]) / det,
+		(m[5]*m[6] - m[3]*m[8]) / det,
+		(m[0]*m[8] - m[2]*m[6]) / det,
+		(m[2]*m[3] - m[0]*m[5]) / det,
+		(m[3]*m[7] - m[4]*m[6]) / det,
+		(m[1]*m[6] - m[0]*m[7]) / det,
+		(m[0]*m[4] - m[1]*m[3]) / det,
+	}, true
+}
+
+// withRenderingIntent returns a copy of the profile with the rendering intent set in the header.
+func withRenderingIntent(profile []byte, intent Intent) []byte {
+	if len(profile) < 68 {
+		return profile
+	}
+	p := append([]byte(nil), profile...)
+	binary.BigEndian.PutUint32(p[64:], uint32(intent))
+	return p
+}
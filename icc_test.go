@@ -0,0 +1,507 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math"
+	"testing"
+)
+
+// makeICCProfile builds a minimal RGB matrix/TRC ICC profile with the given
+// D50 primaries (columns of the RGB to XYZ matrix) and parametric curve.
+func makeICCProfile(primaries [3][3]float64, curve []float64) []byte {
+	fixed := func(v float64) uint32 { return uint32(int32(math.Round(v * 65536))) }
+
+	var xyz [3][]byte
+	for c := 0; c < 3; c++ {
+		b := make([]byte, 20)
+		copy(b, "XYZ ")
+		for k := 0; k < 3; k++ {
+			binary.BigEndian.PutUint32(b[8+k*4:], fixed(primaries[c][k]))
+		}
+		xyz[c] = b
+	}
+
+	para := make([]byte, 12+len(curve)*4)
+	copy(para, "para")
+	types := map[int]uint16{1: 0, 3: 1, 4: 2, 5: 3, 7: 4}
+	binary.BigEndian.PutUint16(para[8:], types[len(curve)])
+	for i, v := range curve {
+		binary.BigEndian.PutUint32(para[12+i*4:], fixed(v))
+	}
+
+	sigs := []string{"rXYZ", "gXYZ", "bXYZ", "rTRC", "gTRC", "bTRC"}
+	data := [][]byte{xyz[0], xyz[1], xyz[2], para, para, para}
+
+	buf := make([]byte, 132+len(sigs)*12)
+	copy(buf[12:], "mntr")
+	copy(buf[16:], "RGB ")
+	copy(buf[20:], "XYZ ")
+	copy(buf[36:], "acsp")
+	binary.BigEndian.PutUint32(buf[128:], uint32(len(sigs)))
+	for i, sig := range sigs {
+		e := 132 + i*12
+		copy(buf[e:], sig)
+		binary.BigEndian.PutUint32(buf[e+4:], uint32(len(buf)))
+		binary.BigEndian.PutUint32(buf[e+8:], uint32(len(data[i])))
+		buf = append(buf, data[i]...)
+	}
+	binary.BigEndian.PutUint32(buf[0:], uint32(len(buf)))
+	return buf
+}
+
+// makeICCOutputProfile builds a minimal ICC output profile of the color space
+// (RGB or CMYK) and PCS (XYZ or Lab) with the given BToA tags.
+func makeICCOutputProfile(space, pcs string, tags map[string][]byte) []byte {
+	var sigs []string
+	for _, sig := range []string{"B2A0", "B2A1", "B2A2"} {
+		if tags[sig] != nil {
+			sigs = append(sigs, sig)
+		}
+	}
+	buf := make([]byte, 132+len(sigs)*12)
+	copy(buf[12:], "prtr")
+	copy(buf[16:], space)
+	copy(buf[20:], pcs)
+	copy(buf[36:], "acsp")
+	binary.BigEndian.PutUint32(buf[128:], uint32(len(sigs)))
+	for i, sig := range sigs {
+		e := 132 + i*12
+		copy(buf[e:], sig)
+		binary.BigEndian.PutUint32(buf[e+4:], uint32(len(buf)))
+		binary.BigEndian.PutUint32(buf[e+8:], uint32(len(tags[sig])))
+		buf = append(buf, tags[sig]...)
+	}
+	binary.BigEndian.PutUint32(buf[0:], uint32(len(buf)))
+	return buf
+}
+
+// makeICCLUT16 builds a lut16Type transform with identity tables and the CLUT
+// sampled from fn at the grid points.
+func makeICCLUT16(outputs, grid int, fn func(in [3]float64, out []float64)) []byte {
+	b := make([]byte, 52)
+	copy(b, "mft2")
+	b[8], b[9], b[10] = 3, uint8(outputs), uint8(grid)
+	for i := 0; i < 3; i++ {
+		binary.BigEndian.PutUint32(b[12+i*16:], 0x10000)
+	}
+	binary.BigEndian.PutUint16(b[48:], 2)
+	binary.BigEndian.PutUint16(b[50:], 2)
+	u16 := func(v float64) []byte {
+		return binary.BigEndian.AppendUint16(nil, uint16(math.Round(math.Min(math.Max(v, 0), 1)*65535)))
+	}
+	for c := 0; c < 3; c++ {
+		b = append(append(b, u16(0)...), u16(1)...)
+	}
+	out := make([]float64, outputs)
+	for i := 0; i < grid*grid*grid; i++ {
+		in := [3]float64{
+			float64(i/(grid*grid)) / float64(grid-1),
+			float64(i/grid%grid) / float64(grid-1),
+			float64(i%grid) / float64(grid-1),
+		}
+		fn(in, out)
+		for _, v := range out {
+			b = append(b, u16(v)...)
+		}
+	}
+	for c := 0; c < outputs; c++ {
+		b = append(append(b, u16(0)...), u16(1)...)
+	}
+	return b
+}
+
+// makeICCLUTBToA builds a lutBToAType transform of the RGB profile with the XYZ PCS
+// implementing sRGB with the identity B curves, the matrix and the sRGB M curves.
+func makeICCLUTBToA() []byte {
+	fixed := func(v float64) []byte { return binary.BigEndian.AppendUint32(nil, uint32(int32(math.Round(v*65536)))) }
+	identity := []byte("curv\x00\x00\x00\x00\x00\x00\x00\x00")
+	gamma := []byte("para\x00\x00\x00\x00\x00\x00\x00\x00")
+	// Y = 1.055*X^(1/2.4) - 0.055 is written as (1.055^2.4*X)^(1/2.4) - 0.055.
+	for _, v := range []float64{1 / 2.4, math.Pow(1.055, 2.4), 0, 12.92, 0.0031308, -0.055, 0} {
+		gamma = append(gamma, fixed(v)...)
+	}
+	binary.BigEndian.PutUint16(gamma[8:], 4)
+
+	b := make([]byte, 32)
+	copy(b, "mBA ")
+	b[8], b[9] = 3, 3
+	binary.BigEndian.PutUint32(b[12:], uint32(len(b)))
+	for c := 0; c < 3; c++ {
+		b = append(b, identity...)
+	}
+	binary.BigEndian.PutUint32(b[16:], uint32(len(b)))
+	// The inputs are the XYZ values encoded as u1Fixed15 numbers.
+	for _, v := range xyzD50ToLinearSRGB {
+		b = append(b, fixed(v*65535/32768)...)
+	}
+	b = append(b, make([]byte, 12)...)
+	binary.BigEndian.PutUint32(b[20:], uint32(len(b)))
+	for c := 0; c < 3; c++ {
+		b = append(b, gamma...)
+	}
+	return b
+}
+
+var (
+	srgbICCProfile = makeICCProfile(
+		[3][3]float64{
+			{0.4360747, 0.2225045, 0.0139322},
+			{0.3850649, 0.7168786, 0.0971045},
+			{0.1430804, 0.0606169, 0.7141733},
+		},
+		[]float64{2.4, 1 / 1.055, 0.055 / 1.055, 1 / 12.92, 0.04045},
+	)
+	displayP3ICCProfile = makeICCProfile(
+		[3][3]float64{
+			{0.5151, 0.2412, -0.0011},
+			{0.2920, 0.6922, 0.0419},
+			{0.1571, 0.0666, 0.7841},
+		},
+		[]float64{2.4, 1 / 1.055, 0.055 / 1.055, 1 / 12.92, 0.04045},
+	)
+)
+
+func TestReadICCProfile(t *testing.T) {
+	img := New(8, 8, color.NRGBA{0x10, 0x80, 0xf0, 0xff})
+	large := bytes.Repeat(srgbICCProfile, 1000)
+
+	for _, format := range []Format{JPEG, PNG} {
+		for _, profile := range [][]byte{srgbICCProfile, large} {
+			buf := &bytes.Buffer{}
+			if err := Encode(buf, img, format, ICCProfile(profile)); err != nil {
+				t.Fatalf("failed to encode image: %v", err)
+			}
+			got, err := ReadICCProfile(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatalf("ReadICCProfile(%v): %v", format, err)
+			}
+			if !bytes.Equal(got, profile) {
+				t.Fatalf("ReadICCProfile(%v): got profile of %d bytes want %d", format, len(got), len(profile))
+			}
+			if _, err := Decode(bytes.NewReader(buf.Bytes())); err != nil {
+				t.Fatalf("failed to decode image with profile (%v): %v", format, err)
+			}
+		}
+
+		buf := &bytes.Buffer{}
+		if err := Encode(buf, img, format); err != nil {
+			t.Fatalf("failed to encode image: %v", err)
+		}
+		got, err := ReadICCProfile(buf)
+		if err != nil || got != nil {
+			t.Fatalf("ReadICCProfile(%v) without profile: got %v, %v want nil, nil", format, got, err)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	if err := Encode(buf, img, TIFF); err != nil {
+		t.Fatalf("failed to encode image: %v", err)
+	}
+	if got, err := ReadICCProfile(buf); err != nil || got != nil {
+		t.Fatalf("ReadICCProfile(TIFF) without profile: got %v, %v want nil, nil", got, err)
+	}
+
+	if _, err := ReadICCProfile(bytes.NewReader([]byte("bad data"))); err != ErrUnsupportedFormat {
+		t.Fatalf("got error %v want ErrUnsupportedFormat", err)
+	}
+
+	// A small PNG with the profile inflating beyond the maximum size.
+	buf = &bytes.Buffer{}
+	if err := Encode(embedICCProfile(buf, PNG, make([]byte, maxICCProfileSize+1)), img, PNG); err != nil {
+		t.Fatalf("failed to encode image: %v", err)
+	}
+	if buf.Len() > 100000 {
+		t.Fatalf("got PNG of %d bytes", buf.Len())
+	}
+	if _, err := ReadICCProfile(buf); err == nil {
+		t.Fatalf("expected error got nil")
+	}
+}
+
+func TestConvertToSRGB(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 6, 1))
+	copy(src.Pix, []uint8{
+		0xff, 0x00, 0x00, 0xff,
+		0x00, 0xff, 0x00, 0x80,
+		0x00, 0x00, 0xff, 0xff,
+		0x80, 0x80, 0x80, 0xff,
+		0xff, 0xff, 0xff, 0xff,
+		0x12, 0x34, 0x56, 0x00,
+	})
+
+	got, err := ConvertToSRGB(src, srgbICCProfile)
+	if err != nil {
+		t.Fatalf("ConvertToSRGB: %v", err)
+	}
+	if !compareNRGBA(got, src, 1) {
+		t.Fatalf("sRGB conversion: got result %#v want %#v", got, src)
+	}
+
+	got, err = ConvertToSRGB(src, displayP3ICCProfile)
+	if err != nil {
+		t.Fatalf("ConvertToSRGB: %v", err)
+	}
+	// Pure P3 red is out of the sRGB gamut, gray and white stay neutral.
+	want := []uint8{
+		0xff, 0x00, 0x00, 0xff,
+		0x00, 0xff, 0x00, 0x80,
+		0x00, 0x00, 0xff, 0xff,
+		0x80, 0x80, 0x80, 0xff,
+		0xff, 0xff, 0xff, 0xff,
+	}
+	if !compareBytes(got.Pix[12:20], want[12:20], 1) {
+		t.Fatalf("P3 conversion of neutral colors: got %v want %v", got.Pix[12:20], want[12:20])
+	}
+	if got.Pix[7] != 0x80 || got.Pix[23] != 0x00 {
+		t.Fatalf("P3 conversion changed alpha: %v", got.Pix)
+	}
+	// A less saturated P3 color maps to a more saturated sRGB color.
+	mid := New(1, 1, color.NRGBA{0xc0, 0x40, 0x40, 0xff})
+	got, _ = ConvertToSRGB(mid, displayP3ICCProfile)
+	if got.Pix[0] <= 0xc0 || got.Pix[1] >= 0x40 {
+		t.Fatalf("P3 conversion didn't increase saturation: %v", got.Pix)
+	}
+
+	for _, profile := range [][]byte{nil, []byte("bad profile"), srgbICCProfile[:140]} {
+		if _, err := ConvertToSRGB(src, profile); err != ErrUnsupportedProfile {
+			t.Fatalf("got error %v want ErrUnsupportedProfile", err)
+		}
+	}
+}
+
+func TestColorProfileConversion(t *testing.T) {
+	img := New(4, 4, color.NRGBA{0xc0, 0x40, 0x40, 0xff})
+	buf := &bytes.Buffer{}
+	if err := Encode(buf, img, PNG, ICCProfile(displayP3ICCProfile)); err != nil {
+		t.Fatalf("failed to encode image: %v", err)
+	}
+
+	got, err := Decode(bytes.NewReader(buf.Bytes()), ColorProfileConversion(true))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	want, _ := ConvertToSRGB(img, displayP3ICCProfile)
+	if !compareNRGBA(toNRGBA(got), want, 0) {
+		t.Fatalf("got result %#v want %#v", got, want)
+	}
+
+	got, err = Decode(bytes.NewReader(buf.Bytes()), ColorProfileConversion(false))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if !compareNRGBA(Clone(got), img, 0) {
+		t.Fatalf("got result %#v want %#v", got, img)
+	}
+
+	_, err = Decode(bytes.NewReader([]byte("bad data")), ColorProfileConversion(true))
+	if err == nil {
+		t.Fatalf("decoding bad data: expected error got nil")
+	}
+}
+
+func TestConvertFromSRGB(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 4, 1),
+		Stride: 4 * 4,
+		Pix: []uint8{
+			0x00, 0x00, 0x00, 0xff, 0xff, 0xff, 0xff, 0x80,
+			0xff, 0x00, 0x00, 0xff, 0x20, 0x80, 0xc0, 0xff,
+		},
+	}
+
+	got, err := ConvertFromSRGB(src, srgbICCProfile, Perceptual)
+	if err != nil {
+		t.Fatalf("ConvertFromSRGB: %v", err)
+	}
+	if !compareNRGBA(got, src, 1) {
+		t.Fatalf("sRGB to sRGB: got %#v want %#v", got, src)
+	}
+
+	got, err = ConvertFromSRGB(src, displayP3ICCProfile, RelativeColorimetric)
+	if err != nil {
+		t.Fatalf("ConvertFromSRGB: %v", err)
+	}
+	// Pure sRGB red is less saturated in Display P3.
+	if c := got.NRGBAAt(2, 0); c.R > 0xf0 || c.G < 0x20 || c.B < 0x10 {
+		t.Fatalf("got Display P3 red %v", c)
+	}
+	back, err := ConvertToSRGB(got, displayP3ICCProfile)
+	if err != nil {
+		t.Fatalf("ConvertToSRGB: %v", err)
+	}
+	if !compareNRGBA(back, src, 2) {
+		t.Fatalf("round trip: got %#v want %#v", back, src)
+	}
+
+	for _, profile := range [][]byte{nil, []byte("bad profile"), srgbICCProfile[:140]} {
+		if _, err := ConvertFromSRGB(src, profile, Perceptual); err != ErrUnsupportedProfile {
+			t.Fatalf("got error %v want ErrUnsupportedProfile", err)
+		}
+	}
+}
+
+func TestTargetProfile(t *testing.T) {
+	img := New(4, 4, color.NRGBA{0xff, 0x40, 0x20, 0xff})
+	buf := &bytes.Buffer{}
+	err := Encode(buf, img, PNG, TargetProfile(displayP3ICCProfile), RenderingIntent(Saturation))
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	profile, err := ReadICCProfile(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("ReadICCProfile: %v", err)
+	}
+	if len(profile) != len(displayP3ICCProfile) || binary.BigEndian.Uint32(profile[64:]) != uint32(Saturation) {
+		t.Fatalf("embedded profile doesn't match the target profile")
+	}
+	if binary.BigEndian.Uint32(displayP3ICCProfile[64:]) != 0 {
+		t.Fatalf("target profile is modified")
+	}
+
+	decoded, err := Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	want, _ := ConvertFromSRGB(img, displayP3ICCProfile, Saturation)
+	if !compareNRGBA(Clone(decoded), want, 0) {
+		t.Fatalf("got pixels %#v want %#v", decoded, want)
+	}
+
+	if err := Encode(&bytes.Buffer{}, img, PNG, TargetProfile([]byte("bad profile"))); err != ErrUnsupportedProfile {
+		t.Fatalf("got error %v want ErrUnsupportedProfile", err)
+	}
+}
+
+func TestConvertFromSRGBLUT(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 4, 1),
+		Stride: 4 * 4,
+		Pix: []uint8{
+			0x00, 0x00, 0x00, 0xff, 0xff, 0xff, 0xff, 0x80,
+			0xff, 0x00, 0x00, 0xff, 0x20, 0x80, 0xc0, 0xff,
+		},
+	}
+	profile := makeICCOutputProfile("RGB ", "XYZ ", map[string][]byte{"B2A0": makeICCLUTBToA()})
+	for _, intent := range []Intent{Perceptual, RelativeColorimetric, Saturation} {
+		got, err := ConvertFromSRGB(src, profile, intent)
+		if err != nil {
+			t.Fatalf("ConvertFromSRGB: %v", err)
+		}
+		if !compareNRGBA(got, src, 2) {
+			t.Fatalf("sRGB LUT profile (intent %v): got %#v want %#v", intent, got, src)
+		}
+	}
+
+	// The transform of the rendering intent is used.
+	inverted := makeICCLUT16(3, 2, func(in [3]float64, out []float64) {
+		for c := range out {
+			out[c] = 1 - in[0]
+		}
+	})
+	profile = makeICCOutputProfile("RGB ", "Lab ", map[string][]byte{"B2A0": makeICCLUTBToA(), "B2A2": inverted})
+	got, err := ConvertFromSRGB(src, profile, Saturation)
+	if err != nil {
+		t.Fatalf("ConvertFromSRGB: %v", err)
+	}
+	if c := got.NRGBAAt(0, 0); c.R < 0xfe || c.G < 0xfe || c.B < 0xfe {
+		t.Fatalf("got inverted black %v", c)
+	}
+	if c := got.NRGBAAt(1, 0); c.R > 1 || c.A != 0x80 {
+		t.Fatalf("got inverted white %v", c)
+	}
+
+	for _, tag := range [][]byte{[]byte("mft2"), inverted[:100], []byte("mAB \x00\x00\x00\x00\x03\x03\x00\x00")} {
+		profile := makeICCOutputProfile("RGB ", "Lab ", map[string][]byte{"B2A0": tag})
+		if _, err := ConvertFromSRGB(src, profile, Perceptual); err != ErrUnsupportedProfile {
+			t.Fatalf("got error %v want ErrUnsupportedProfile", err)
+		}
+	}
+}
+
+// cmykICCProfile is a CMYK output profile with the Lab PCS mapping L* to K
+// and the positive a* and b* to M and Y.
+var cmykICCProfile = makeICCOutputProfile("CMYK", "Lab ", map[string][]byte{
+	"B2A0": makeICCLUT16(4, 9, func(in [3]float64, out []float64) {
+		out[0] = math.Max(0, (0.5-in[1])*2)
+		out[1] = math.Max(0, (in[1]-0.5)*2)
+		out[2] = math.Max(0, (in[2]-0.5)*2)
+		out[3] = 1 - in[0]
+	}),
+})
+
+func TestConvertToCMYK(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 4, 1),
+		Stride: 4 * 4,
+		Pix: []uint8{
+			0xff, 0xff, 0xff, 0xff, 0x00, 0x00, 0x00, 0xff,
+			0xff, 0x00, 0x00, 0xff, 0x00, 0x00, 0x00, 0x00,
+		},
+	}
+	got, err := ConvertToCMYK(src, cmykICCProfile, RelativeColorimetric)
+	if err != nil {
+		t.Fatalf("ConvertToCMYK: %v", err)
+	}
+	if got.Bounds() != src.Bounds() {
+		t.Fatalf("got bounds %v want %v", got.Bounds(), src.Bounds())
+	}
+	// White, black, red and transparent (white paper).
+	if c := got.CMYKAt(0, 0); c.C > 1 || c.M > 1 || c.Y > 1 || c.K > 1 {
+		t.Fatalf("got white %v", c)
+	}
+	if c := got.CMYKAt(1, 0); c.C > 1 || c.M > 1 || c.Y > 1 || c.K < 0xfd {
+		t.Fatalf("got black %v", c)
+	}
+	if c := got.CMYKAt(2, 0); c.C > 1 || c.M < 0x90 || c.Y < 0x70 || c.K < 0x70 || c.K > 0x80 {
+		t.Fatalf("got red %v", c)
+	}
+	if c := got.CMYKAt(3, 0); c != got.CMYKAt(0, 0) {
+		t.Fatalf("got transparent %v want %v", c, got.CMYKAt(0, 0))
+	}
+
+	if _, err := ConvertFromSRGB(src, cmykICCProfile, Perceptual); err != ErrUnsupportedProfile {
+		t.Fatalf("ConvertFromSRGB: got error %v want ErrUnsupportedProfile", err)
+	}
+	for _, profile := range [][]byte{nil, srgbICCProfile, displayP3ICCProfile} {
+		if _, err := ConvertToCMYK(src, profile, Perceptual); err != ErrUnsupportedProfile {
+			t.Fatalf("got error %v want ErrUnsupportedProfile", err)
+		}
+	}
+}
+
+func TestTargetProfileCMYK(t *testing.T) {
+	img := New(8, 8, color.NRGBA{0xff, 0x00, 0x00, 0xff})
+	buf := &bytes.Buffer{}
+	if err := Encode(buf, img, JPEG, TargetProfile(cmykICCProfile), JPEGQuality(100)); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	profile, err := ReadICCProfile(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("ReadICCProfile: %v", err)
+	}
+	if len(profile) != len(cmykICCProfile) {
+		t.Fatalf("embedded profile doesn't match the target profile")
+	}
+	decoded, err := jpeg.Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("jpeg.Decode: %v", err)
+	}
+	cmyk, ok := decoded.(*image.CMYK)
+	if !ok {
+		t.Fatalf("got image of type %T want *image.CMYK", decoded)
+	}
+	want, _ := ConvertToCMYK(img, cmykICCProfile, Perceptual)
+	if !compareBytes(cmyk.Pix, want.Pix, 4) {
+		t.Fatalf("got pixels %v want %v", cmyk.Pix[:4], want.Pix[:4])
+	}
+
+	if err := Encode(&bytes.Buffer{}, img, PNG, TargetProfile(cmykICCProfile)); err != ErrUnsupportedProfile {
+		t.Fatalf("got error %v want ErrUnsupportedProfile", err)
+	}
+}
@@ -1,6 +1,8 @@
 package imaging
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"image"
@@ -9,10 +11,12 @@ import (
 	"image/jpeg"
 	"image/png"
 	"io"
+	iofs "io/fs"
 	"io/ioutil"
-	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"golang.org/x/image/bmp"
 	"golang.org/x/image/tiff"
@@ -21,21 +25,34 @@ import (
 type fileSystem interface {
 	Create(string) (io.WriteCloser, error)
 	Open(string) (io.ReadCloser, error)
+	Stat(string) (iofs.FileInfo, error)
+	// WriteAtomic writes the file created with the given modification time, so that
+	// the other processes never see a partially written file. It creates the missing
+	// parent directories.
+	WriteAtomic(name string, data []byte, modTime time.Time) error
 }
 
-type localFS struct{}
-
-func (localFS) Create(name string) (io.WriteCloser, error) { return os.Create(name) }
-func (localFS) Open(name string) (io.ReadCloser, error)    { return os.Open(name) }
-
 var fs fileSystem = localFS{}
 
+// WriteFS is a file system that supports creating files. It's used by SaveFS to store
+// images outside of the local file system, e.g. in memory or in a cloud storage.
+// If it also implements fs.FS, the SkipIfUnchanged option of SaveFS compares
+// the encoded image with the existing file.
+type WriteFS interface {
+	// Create creates or truncates the named file. The image is written
+	// to the returned writer, the file is complete when it's closed.
+	Create(name string) (io.WriteCloser, error)
+}
+
 type decodeConfig struct {
-	autoOrientation bool
+	autoOrientation   bool
+	profileConversion bool
+	limits            *Limits
 }
 
 var defaultDecodeConfig = decodeConfig{
-	autoOrientation: false,
+	autoOrientation:   false,
+	profileConversion: false,
 }
 
 // DecodeOption sets an optional parameter for the Decode and Open functions.
@@ -50,6 +67,149 @@ func AutoOrientation(enabled bool) DecodeOption {
 	}
 }
 
+// ColorProfileConversion returns a DecodeOption that sets the color profile conversion mode.
+// If it's enabled and the image has an embedded ICC profile, the image colors will be converted
+// to sRGB after decoding (see ConvertToSRGB). Unsupported profiles are ignored. By default it's disabled.
+func ColorProfileConversion(enabled bool) DecodeOption {
+	return func(c *decodeConfig) {
+		c.profileConversion = enabled
+	}
+}
+
+// Limits are the maximum image size and data size accepted by the decoder.
+// Zero values mean no limit.
+type Limits struct {
+	MaxWidth  int   // Maximum image width in pixels.
+	MaxHeight int   // Maximum image height in pixels.
+	MaxPixels int   // Maximum number of pixels (width * height).
+	MaxBytes  int64 // Maximum size of the encoded image data.
+}
+
+// ErrLimitExceeded means that the image is larger than the decode limits allow.
+var ErrLimitExceeded = errors.New("imaging: image exceeds decode limits")
+
+// DecodeLimits returns a DecodeOption that sets the decode limits. If the limits are set,
+// the image header is checked before decoding and the images exceeding the limits are
+// rejected with ErrLimitExceeded without allocating the memory for the pixels. It protects
+// from the malicious images ("decompression bombs") that declare a huge size in a tiny file.
+// The image data is read into memory, up to MaxBytes. By default there are no limits.
+func DecodeLimits(limits Limits) DecodeOption {
+	return func(c *decodeConfig) {
+		c.limits = &limits
+	}
+}
+
+// DecodeWithLimits reads an image from r, rejecting the images exceeding the limits
+// with ErrLimitExceeded before decoding the pixels.
+//
+// Example:
+//
+//	img, err := imaging.DecodeWithLimits(r.Body, imaging.Limits{
+//		MaxPixels: 50000000,
+//		MaxBytes:  20 << 20,
+//	}, imaging.AutoOrientation(true))
+//	if err == imaging.ErrLimitExceeded {
+//		http.Error(w, "image is too large", http.StatusRequestEntityTooLarge)
+//		return
+//	}
+//
+func DecodeWithLimits(r io.Reader, limits Limits, opts ...DecodeOption) (image.Image, error) {
+	return Decode(r, append(opts, DecodeLimits(limits))...)
+}
+
+// checkLimits reads the image data from r and checks the image header against the limits.
+// It returns a reader of the same data.
+func checkLimits(r io.Reader, limits *Limits) (io.Reader, error) {
+	if limits.MaxBytes > 0 {
+		r = io.LimitReader(r, limits.MaxBytes+1)
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if limits.MaxBytes > 0 && int64(len(data)) > limits.MaxBytes {
+		return nil, ErrLimitExceeded
+	}
+
+	_, cfg, err := findRegisteredCodec(data, true)
+	if err == image.ErrFormat {
+		cfg, _, err = image.DecodeConfig(bytes.NewReader(data))
+	}
+	if err == image.ErrFormat {
+		_, cfg, err = findRegisteredCodec(data, false)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if limits.exceeded(cfg.Width, cfg.Height) {
+		return nil, ErrLimitExceeded
+	}
+	return bytes.NewReader(data), nil
+}
+
+// exceeded reports whether the image of the given size exceeds the limits.
+func (limits *Limits) exceeded(w, h int) bool {
+	return (limits.MaxWidth > 0 && w > limits.MaxWidth) ||
+		(limits.MaxHeight > 0 && h > limits.MaxHeight) ||
+		(limits.MaxPixels > 0 && int64(w)*int64(h) > int64(limits.MaxPixels))
+}
+
+// checkDecodedLimits checks the size of the decoded image against the limits, if any.
+func checkDecodedLimits(img image.Image, limits *Limits) error {
+	if limits == nil {
+		return nil
+	}
+	if limits.exceeded(img.Bounds().Dx(), img.Bounds().Dy()) {
+		return ErrLimitExceeded
+	}
+	return nil
+}
+
+// decodeImage decodes the image using the decoders registered in the image package.
+// The decoders registered with RegisterFormat for the built-in formats are tried first.
+// If the format is not recognized, the image is decoded by the first other format registered
+// with RegisterFormat that recognizes its header. The CMYK JPEG images rejected
+// by image/jpeg for the missing Adobe segment are decoded by decodeCMYKJPEG.
+func decodeImage(r io.Reader) (image.Image, error) {
+	if !hasRegisteredDecoders() {
+		br := bufio.NewReader(r)
+		if sig, _ := br.Peek(2); !bytes.Equal(sig, []byte{0xff, 0xd8}) {
+			img, _, err := image.Decode(br)
+			return img, err
+		}
+		// Keep the JPEG data to retry the CMYK images without the Adobe segment.
+		var buf bytes.Buffer
+		img, _, err := image.Decode(io.TeeReader(br, &buf))
+		if !isCMYKJPEGWithoutAdobe(err) {
+			return img, err
+		}
+		if _, err := io.Copy(&buf, br); err != nil {
+			return nil, err
+		}
+		return decodeCMYKJPEG(buf.Bytes())
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if codec, _, err := findRegisteredCodec(data, true); err == nil {
+		return codec.decode(bytes.NewReader(data))
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if isCMYKJPEGWithoutAdobe(err) {
+		return decodeCMYKJPEG(data)
+	}
+	if err != image.ErrFormat {
+		return img, err
+	}
+	codec, _, err := findRegisteredCodec(data, false)
+	if err != nil {
+		return nil, err
+	}
+	return codec.decode(bytes.NewReader(data))
+}
+
 // Decode reads an image from r.
 func Decode(r io.Reader, opts ...DecodeOption) (image.Image, error) {
 	cfg := defaultDecodeConfig
@@ -57,9 +217,23 @@ func Decode(r io.Reader, opts ...DecodeOption) (image.Image, error) {
 		option(&cfg)
 	}
 
+	if cfg.limits != nil {
+		var err error
+		if r, err = checkLimits(r, cfg.limits); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.profileConversion {
+		return decodeConvertProfile(r, cfg)
+	}
+
 	if !cfg.autoOrientation {
-		img, _, err := image.Decode(r)
-		return img, err
+		img, err := decodeImage(r)
+		if err != nil {
+			return nil, err
+		}
+		return img, checkDecodedLimits(img, cfg.limits)
 	}
 
 	var orient orientation
@@ -72,16 +246,55 @@ func Decode(r io.Reader, opts ...DecodeOption) (image.Image, error) {
 		io.Copy(ioutil.Discard, pr)
 	}()
 
-	img, _, err := image.Decode(r)
+	img, err := decodeImage(r)
 	pw.Close()
 	<-done
 	if err != nil {
 		return nil, err
 	}
+	if err := checkDecodedLimits(img, cfg.limits); err != nil {
+		return nil, err
+	}
 
 	return fixOrientation(img, orient), nil
 }
 
+// decodeConvertProfile reads the whole image data into memory, decodes the image
+// and converts it to sRGB using the embedded ICC profile.
+func decodeConvertProfile(r io.Reader, cfg decodeConfig) (image.Image, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := decodeImage(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDecodedLimits(img, cfg.limits); err != nil {
+		return nil, err
+	}
+
+	profile, err := ReadICCProfile(bytes.NewReader(data))
+	if err == nil && profile != nil {
+		if converted, err := ConvertToSRGB(img, profile); err == nil {
+			img = converted
+		}
+	}
+
+	if cfg.autoOrientation {
+		img = fixOrientation(img, readOrientation(bytes.NewReader(data)))
+	}
+	return img, nil
+}
+
+// DecodeBytes decodes an image from the given data.
+// It's a convenience wrapper around Decode, useful in environments without
+// a file system, such as WebAssembly modules running in a browser.
+func DecodeBytes(data []byte, opts ...DecodeOption) (image.Image, error) {
+	return Decode(bytes.NewReader(data), opts...)
+}
+
 // Open loads an image from file.
 //
 // Examples:
@@ -101,6 +314,25 @@ func Open(filename string, opts ...DecodeOption) (image.Image, error) {
 	return Decode(file, opts...)
 }
 
+// OpenFS loads an image from the named file of the file system fsys,
+// such as embed.FS, zip.Reader or os.DirFS.
+//
+// Example:
+//
+//	//go:embed assets
+//	var assets embed.FS
+//
+//	img, err := imaging.OpenFS(assets, "assets/logo.png")
+//
+func OpenFS(fsys iofs.FS, name string, opts ...DecodeOption) (image.Image, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Decode(file, opts...)
+}
+
 // Format is an image file format.
 type Format int
 
@@ -111,6 +343,7 @@ const (
 	GIF
 	TIFF
 	BMP
+	AVIF
 )
 
 var formatExts = map[string]Format{
@@ -121,6 +354,7 @@ var formatExts = map[string]Format{
 	"tif":  TIFF,
 	"tiff": TIFF,
 	"bmp":  BMP,
+	"avif": AVIF,
 }
 
 var formatNames = map[Format]string{
@@ -129,41 +363,221 @@ var formatNames = map[Format]string{
 	GIF:  "GIF",
 	TIFF: "TIFF",
 	BMP:  "BMP",
+	AVIF: "AVIF",
 }
 
 func (f Format) String() string {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
 	return formatNames[f]
 }
 
+// formatCodec is the decoder and the encoder of a format registered with RegisterFormat
+// or RegisterFormatEncoder.
+type formatCodec struct {
+	decode       func(io.Reader) (image.Image, error)
+	decodeConfig func(io.Reader) (image.Config, error)
+	encode       func(io.Writer, image.Image, ...EncodeOption) error
+}
+
+var (
+	// formatsMu guards formatExts, formatNames, formatCodecs, decodeOrder and formatDefaults.
+	formatsMu      sync.RWMutex
+	formatCodecs   = map[Format]formatCodec{}
+	decodeOrder    []Format
+	formatDefaults = map[Format][]EncodeOption{}
+)
+
+// RegisterFormat registers an image format, so Open, Decode, Save, Encode and
+// FormatFromExtension support it, and returns its Format value. The name is returned
+// by Format.String and the extensions (with or without the leading dot) are matched
+// case-insensitively. If a format with the same name exists (e.g. "AVIF" or "JPEG"),
+// it's updated instead: the extensions are added and the codec replaces the built-in one.
+// The decoders of the built-in formats registered this way are tried before the decoders
+// of the image package. The decoders of the new formats are tried when the image package
+// doesn't recognize the data. In both cases the decodeConfig functions are tried in the order
+// of registration, and the image is decoded by the first format that recognizes it. The decodeConfig function returns the image size
+// from the header, it's required with decode, so the DecodeLimits are checked before
+// decoding; RegisterFormat panics if it's nil. All the functions may be nil if the format
+// can't be decoded or encoded. RegisterFormat is typically called from init.
+//
+// Example:
+//
+//	var HEIF = imaging.RegisterFormat("HEIF", []string{"heic", "heif"}, heif.Decode, heif.DecodeConfig,
+//		func(w io.Writer, img image.Image, opts ...imaging.EncodeOption) error {
+//			return heif.Encode(w, img, nil)
+//		},
+//	)
+//
+func RegisterFormat(name string, ext []string, decode func(io.Reader) (image.Image, error), decodeConfig func(io.Reader) (image.Config, error), encode func(io.Writer, image.Image, ...EncodeOption) error) Format {
+	if decode != nil && decodeConfig == nil {
+		panic("imaging: RegisterFormat: decodeConfig is required with decode")
+	}
+
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+
+	format := Format(-1)
+	for f, n := range formatNames {
+		if strings.EqualFold(n, name) {
+			format = f
+			break
+		}
+	}
+	if format < 0 {
+		format = Format(len(formatNames))
+		formatNames[format] = name
+	}
+	for _, e := range ext {
+		formatExts[strings.ToLower(strings.TrimPrefix(e, "."))] = format
+	}
+
+	formatCodecs[format] = formatCodec{decode: decode, decodeConfig: decodeConfig, encode: encode}
+	for i, f := range decodeOrder {
+		if f == format {
+			decodeOrder = append(decodeOrder[:i], decodeOrder[i+1:]...)
+			break
+		}
+	}
+	if decode != nil {
+		decodeOrder = append(decodeOrder, format)
+	}
+	return format
+}
+
+// hasRegisteredDecoders reports whether any format is registered with a decoder.
+func hasRegisteredDecoders() bool {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+	return len(decodeOrder) > 0
+}
+
+// findRegisteredCodec returns the codec of the first format registered with RegisterFormat
+// that recognizes the image data, and the image config read by it. If builtin is true only
+// the built-in formats are tried, otherwise only the new ones. It returns image.ErrFormat
+// if no format recognizes the data.
+func findRegisteredCodec(data []byte, builtin bool) (formatCodec, image.Config, error) {
+	formatsMu.RLock()
+	codecs := make([]formatCodec, 0, len(decodeOrder))
+	for _, f := range decodeOrder {
+		if (f <= AVIF) == builtin {
+			codecs = append(codecs, formatCodecs[f])
+		}
+	}
+	formatsMu.RUnlock()
+
+	for _, codec := range codecs {
+		if cfg, err := codec.decodeConfig(bytes.NewReader(data)); err == nil {
+			return codec, cfg, nil
+		}
+	}
+	return formatCodec{}, image.Config{}, image.ErrFormat
+}
+
 // ErrUnsupportedFormat means the given image format is not supported.
 var ErrUnsupportedFormat = errors.New("imaging: unsupported image format")
 
 // FormatFromExtension parses image format from filename extension:
-// "jpg" (or "jpeg"), "png", "gif", "tif" (or "tiff") and "bmp" are supported.
+// "jpg" (or "jpeg"), "png", "gif", "tif" (or "tiff"), "bmp" and "avif" are supported,
+// as well as the extensions of the formats registered with RegisterFormat.
 func FormatFromExtension(ext string) (Format, error) {
-	if f, ok := formatExts[strings.ToLower(strings.TrimPrefix(ext, "."))]; ok {
+	formatsMu.RLock()
+	f, ok := formatExts[strings.ToLower(strings.TrimPrefix(ext, "."))]
+	formatsMu.RUnlock()
+	if ok {
 		return f, nil
 	}
 	return -1, ErrUnsupportedFormat
 }
 
 // FormatFromFilename parses image format from filename:
-// "jpg" (or "jpeg"), "png", "gif", "tif" (or "tiff") and "bmp" are supported.
+// "jpg" (or "jpeg"), "png", "gif", "tif" (or "tiff"), "bmp" and "avif" are supported,
+// as well as the extensions of the formats registered with RegisterFormat.
 func FormatFromFilename(filename string) (Format, error) {
 	ext := filepath.Ext(filename)
 	return FormatFromExtension(ext)
 }
 
+// FormatEncoder encodes images in a file format. It plugs an external encoder into
+// Encode and Save, e.g. for AVIF that has no built-in encoder.
+type FormatEncoder interface {
+	// Encode writes the image to w. The quality ranges from 1 to 100, it's set by
+	// the JPEGQuality option for JPEG and by the AVIFQuality option for AVIF,
+	// and it's 0 for other formats.
+	Encode(w io.Writer, img image.Image, quality int) error
+}
+
+// FormatEncoderFunc is an adapter to use an ordinary function as a FormatEncoder.
+type FormatEncoderFunc func(w io.Writer, img image.Image, quality int) error
+
+// Encode calls f(w, img, quality).
+func (f FormatEncoderFunc) Encode(w io.Writer, img image.Image, quality int) error {
+	return f(w, img, quality)
+}
+
+// RegisterFormatEncoder registers the encoder of the format, replacing the built-in one
+// and the encoder registered with RegisterFormat, if any. A nil encoder removes the registered
+// encoder. Decoding works for every format whose decoder is registered in the image package,
+// so an AVIF decoder package only needs to be imported.
+//
+// Example:
+//
+//	imaging.RegisterFormatEncoder(imaging.AVIF, imaging.FormatEncoderFunc(
+//		func(w io.Writer, img image.Image, quality int) error {
+//			return avif.Encode(w, img, avif.Options{Quality: quality})
+//		},
+//	))
+//
+func RegisterFormatEncoder(format Format, enc FormatEncoder) {
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+	codec := formatCodecs[format]
+	codec.encode = nil
+	if enc != nil {
+		codec.encode = func(w io.Writer, img image.Image, opts ...EncodeOption) error {
+			cfg := defaultEncodeConfig
+			for _, option := range opts {
+				option(&cfg)
+			}
+			quality := 0
+			switch format {
+			case JPEG:
+				quality = cfg.jpegQuality
+			case AVIF:
+				quality = cfg.avifQuality
+			}
+			return enc.Encode(w, img, quality)
+		}
+	}
+	formatCodecs[format] = codec
+}
+
 type encodeConfig struct {
 	jpegQuality         int
+	jpegSubsampling     ChromaSubsampling
+	jpegProgressive     bool
+	jpegKeepCMYK        bool
+	avifQuality         int
 	gifNumColors        int
 	gifQuantizer        draw.Quantizer
 	gifDrawer           draw.Drawer
 	pngCompressionLevel png.CompressionLevel
+	pngBitDepth         int
+	pngNumColors        int
+	pngQuantizer        draw.Quantizer
+	pngDrawer           draw.Drawer
+	pngInterlace        bool
+	iccProfile          []byte
+	targetProfile       []byte
+	renderingIntent     Intent
+	skipIfUnchanged     bool
+	allowDownscale      bool
+	deterministic       bool
 }
 
 var defaultEncodeConfig = encodeConfig{
 	jpegQuality:         95,
+	avifQuality:         60,
 	gifNumColors:        256,
 	gifQuantizer:        nil,
 	gifDrawer:           nil,
@@ -181,6 +595,42 @@ func JPEGQuality(quality int) EncodeOption {
 	}
 }
 
+// JPEGSubsampling returns an EncodeOption that sets the chroma subsampling of the
+// JPEG-encoded image. Default is Subsampling420.
+func JPEGSubsampling(subsampling ChromaSubsampling) EncodeOption {
+	return func(c *encodeConfig) {
+		c.jpegSubsampling = subsampling
+	}
+}
+
+// JPEGProgressive returns an EncodeOption that enables the progressive JPEG encoding.
+// Progressive images are rendered coarse to fine while they are loading and are often
+// slightly smaller. Default is false (baseline).
+func JPEGProgressive(enabled bool) EncodeOption {
+	return func(c *encodeConfig) {
+		c.jpegProgressive = enabled
+	}
+}
+
+// JPEGKeepCMYK returns an EncodeOption that makes the encoder write *image.CMYK images,
+// such as the CMYK JPEG and TIFF images returned by Open and Decode, as CMYK JPEGs
+// instead of converting them to RGB. It keeps the colors intended for the print.
+// By default it's disabled.
+func JPEGKeepCMYK(enabled bool) EncodeOption {
+	return func(c *encodeConfig) {
+		c.jpegKeepCMYK = enabled
+	}
+}
+
+// AVIFQuality returns an EncodeOption that sets the output AVIF quality passed
+// to the registered AVIF encoder. Quality ranges from 1 to 100 inclusive, higher is better.
+// Default is 60.
+func AVIFQuality(quality int) EncodeOption {
+	return func(c *encodeConfig) {
+		c.avifQuality = quality
+	}
+}
+
 // GIFNumColors returns an EncodeOption that sets the maximum number of colors
 // used in the GIF-encoded image. It ranges from 1 to 256.  Default is 256.
 func GIFNumColors(numColors int) EncodeOption {
@@ -190,7 +640,8 @@ func GIFNumColors(numColors int) EncodeOption {
 }
 
 // GIFQuantizer returns an EncodeOption that sets the quantizer that is used to produce
-// a palette of the GIF-encoded image.
+// a palette of the GIF-encoded image. By default it's the median cut quantizer,
+// see NewQuantizer for the others.
 func GIFQuantizer(quantizer draw.Quantizer) EncodeOption {
 	return func(c *encodeConfig) {
 		c.gifQuantizer = quantizer
@@ -213,15 +664,200 @@ func PNGCompressionLevel(level png.CompressionLevel) EncodeOption {
 	}
 }
 
-// Encode writes the image img to w in the specified format (JPEG, PNG, GIF, TIFF or BMP).
-func Encode(w io.Writer, img image.Image, format Format, opts ...EncodeOption) error {
+// PNGBitDepth returns an EncodeOption that sets the bit depth of the samples of the
+// PNG-encoded image: 8 or 16. By default the depth follows the image type, e.g.
+// *image.NRGBA64 images are encoded with 16 bits per sample and *image.NRGBA with 8.
+func PNGBitDepth(depth int) EncodeOption {
+	return func(c *encodeConfig) {
+		c.pngBitDepth = depth
+	}
+}
+
+// PNGNumColors returns an EncodeOption that makes the encoder reduce the image
+// to a palette of at most numColors colors (1 to 256) and write a paletted PNG.
+// Paletted images are usually several times smaller than full color ones.
+// Default is 0 (no palette).
+func PNGNumColors(numColors int) EncodeOption {
+	return func(c *encodeConfig) {
+		c.pngNumColors = numColors
+	}
+}
+
+// PNGQuantizer returns an EncodeOption that sets the quantizer that is used to produce
+// the palette of the PNG-encoded image when PNGNumColors is set. By default the palette
+// is built by the median cut algorithm.
+func PNGQuantizer(quantizer draw.Quantizer) EncodeOption {
+	return func(c *encodeConfig) {
+		c.pngQuantizer = quantizer
+	}
+}
+
+// PNGDrawer returns an EncodeOption that sets the drawer that is used to convert the image
+// to the palette of the PNG-encoded image when PNGNumColors is set. Default is
+// draw.FloydSteinberg, use draw.Src to disable the dithering.
+func PNGDrawer(drawer draw.Drawer) EncodeOption {
+	return func(c *encodeConfig) {
+		c.pngDrawer = drawer
+	}
+}
+
+// PNGInterlace returns an EncodeOption that enables the Adam7 interlacing of the PNG-encoded
+// image, so it's displayed coarse to fine while loading. Interlaced images are slightly
+// larger. By default it's disabled.
+func PNGInterlace(enabled bool) EncodeOption {
+	return func(c *encodeConfig) {
+		c.pngInterlace = enabled
+	}
+}
+
+// SkipIfUnchanged returns an EncodeOption that makes Save compare the encoded image with
+// the content of the existing destination file and skip the write if they are identical.
+// It keeps the modification time of the file, avoiding needless updates in sync-based
+// deployments, at the cost of reading the existing file. It's ignored by Encode.
+// By default it's disabled.
+func SkipIfUnchanged(enabled bool) EncodeOption {
+	return func(c *encodeConfig) {
+		c.skipIfUnchanged = enabled
+	}
+}
+
+// Deterministic returns an EncodeOption that makes the PNG and GIF-encoded data depend only
+// on the pixel values and the other options, so that content-addressed storage and
+// reproducible builds get the same bytes for the same pixels. The image type is chosen
+// from the pixels (gray or color, 8 or 16 bits), the hidden colors of the fully transparent
+// pixels are zeroed, and the palette of the paletted images is reduced to the used colors
+// in a fixed order. No timestamps are written in any case. The data is compressed by
+// the compress/flate package of the Go standard library, so it's only guaranteed to be
+// stable for the same Go version. It's ignored for the other formats. By default it's disabled.
+func Deterministic(enabled bool) EncodeOption {
+	return func(c *encodeConfig) {
+		c.deterministic = enabled
+	}
+}
+
+// AllowDownscale returns an EncodeOption that allows EncodeTargetSize to reduce the image
+// size when lowering the JPEG quality is not enough to fit the byte budget, or when the
+// format has no quality setting. It's ignored by Encode and Save. By default it's disabled.
+func AllowDownscale(enabled bool) EncodeOption {
+	return func(c *encodeConfig) {
+		c.allowDownscale = enabled
+	}
+}
+
+// ICCProfile returns an EncodeOption that embeds the given ICC color profile
+// into the JPEG or PNG-encoded image. It's ignored for other formats.
+// The profile can be read from the source image using ReadICCProfile.
+func ICCProfile(profile []byte) EncodeOption {
+	return func(c *encodeConfig) {
+		c.iccProfile = profile
+	}
+}
+
+// TargetProfile returns an EncodeOption that converts the colors of the image from sRGB
+// to the color space described by the given ICC profile before encoding (see ConvertFromSRGB)
+// and embeds the profile into the JPEG or PNG-encoded image. The images converted to
+// the CMYK profiles, such as the printer profiles, are written as CMYK JPEGs (see ConvertToCMYK),
+// the other formats can't store them. Encoding fails with ErrUnsupportedProfile if the profile
+// can't be used for the conversion or the format.
+func TargetProfile(profile []byte) EncodeOption {
+	return func(c *encodeConfig) {
+		c.targetProfile = profile
+	}
+}
+
+// RenderingIntent returns an EncodeOption that sets the rendering intent used with
+// the TargetProfile option. Default is Perceptual.
+func RenderingIntent(intent Intent) EncodeOption {
+	return func(c *encodeConfig) {
+		c.renderingIntent = intent
+	}
+}
+
+// SetDefaultOptions sets the options that Encode, Save and the other encoding functions
+// apply to the images of the format before the options given to them, so an application
+// can set its defaults once instead of at every call. The options given to the function
+// override the defaults. Calling SetDefaultOptions again replaces the defaults of the format,
+// without options it removes them. It's safe to call concurrently with the encoding,
+// but it's typically called from init or main.
+//
+// Example:
+//
+//	imaging.SetDefaultOptions(imaging.JPEG,
+//		imaging.JPEGQuality(82),
+//		imaging.JPEGProgressive(true),
+//		imaging.JPEGSubsampling(imaging.Subsampling420),
+//	)
+//	imaging.SetDefaultOptions(imaging.PNG, imaging.PNGCompressionLevel(png.BestCompression))
+//
+func SetDefaultOptions(format Format, opts ...EncodeOption) {
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+	if len(opts) == 0 {
+		delete(formatDefaults, format)
+		return
+	}
+	formatDefaults[format] = append([]EncodeOption(nil), opts...)
+}
+
+// withDefaultOptions returns the options with the default options of the format prepended.
+func withDefaultOptions(format Format, opts []EncodeOption) []EncodeOption {
+	formatsMu.RLock()
+	defaults := formatDefaults[format]
+	formatsMu.RUnlock()
+	if len(defaults) == 0 {
+		return opts
+	}
+	return append(defaults[:len(defaults):len(defaults)], opts...)
+}
+
+// newEncodeConfig returns the encoding parameters set by the default options
+// of the format and the options.
+func newEncodeConfig(format Format, opts []EncodeOption) encodeConfig {
 	cfg := defaultEncodeConfig
-	for _, option := range opts {
+	for _, option := range withDefaultOptions(format, opts) {
 		option(&cfg)
 	}
+	return cfg
+}
+
+// Encode writes the image img to w in the specified format (JPEG, PNG, GIF, TIFF or BMP).
+// AVIF and other formats require an encoder registered with RegisterFormatEncoder or RegisterFormat.
+// The default options of the format set by SetDefaultOptions are applied first.
+func Encode(w io.Writer, img image.Image, format Format, opts ...EncodeOption) error {
+	cfg := newEncodeConfig(format, opts)
+
+	if len(cfg.targetProfile) > 0 {
+		converted, err := convertFromSRGB(img, cfg.targetProfile, cfg.renderingIntent)
+		if err != nil {
+			return err
+		}
+		if _, cmyk := converted.(*image.CMYK); cmyk {
+			if format != JPEG {
+				return ErrUnsupportedProfile
+			}
+			cfg.jpegKeepCMYK = true
+		}
+		img = converted
+		cfg.iccProfile = withRenderingIntent(cfg.targetProfile, cfg.renderingIntent)
+	}
+
+	if len(cfg.iccProfile) > 0 {
+		w = embedICCProfile(w, format, cfg.iccProfile)
+	}
+
+	formatsMu.RLock()
+	codec := formatCodecs[format]
+	formatsMu.RUnlock()
+	if codec.encode != nil {
+		return codec.encode(w, img, withDefaultOptions(format, opts)...)
+	}
 
 	switch format {
 	case JPEG:
+		_, cmyk := img.(*image.CMYK)
+		if cfg.jpegSubsampling != Subsampling420 || cfg.jpegProgressive || (cmyk && cfg.jpegKeepCMYK) {
+			return encodeJPEG(w, img, cfg.jpegQuality, cfg.jpegSubsampling, cfg.jpegProgressive, cfg.jpegKeepCMYK)
+		}
 		if nrgba, ok := img.(*image.NRGBA); ok && nrgba.Opaque() {
 			rgba := &image.RGBA{
 				Pix:    nrgba.Pix,
@@ -233,13 +869,27 @@ func Encode(w io.Writer, img image.Image, format Format, opts ...EncodeOption) e
 		return jpeg.Encode(w, img, &jpeg.Options{Quality: cfg.jpegQuality})
 
 	case PNG:
+		img = pngImage(img, &cfg)
+		if cfg.deterministic {
+			img = canonicalImage(img, cfg.pngBitDepth == 16)
+		}
+		if cfg.pngInterlace {
+			return encodePNGInterlaced(w, img, cfg.pngCompressionLevel)
+		}
 		encoder := png.Encoder{CompressionLevel: cfg.pngCompressionLevel}
 		return encoder.Encode(w, img)
 
 	case GIF:
+		if cfg.deterministic {
+			img = deterministicGIFImage(img, &cfg)
+		}
+		quantizer := cfg.gifQuantizer
+		if quantizer == nil {
+			quantizer = medianCutQuantizer{}
+		}
 		return gif.Encode(w, img, &gif.Options{
 			NumColors: cfg.gifNumColors,
-			Quantizer: cfg.gifQuantizer,
+			Quantizer: quantizer,
 			Drawer:    cfg.gifDrawer,
 		})
 
@@ -253,6 +903,17 @@ func Encode(w io.Writer, img image.Image, format Format, opts ...EncodeOption) e
 	return ErrUnsupportedFormat
 }
 
+// EncodeBytes encodes the image in the specified format and returns the encoded data.
+// It's a convenience wrapper around Encode, useful in environments without
+// a file system, such as WebAssembly modules running in a browser.
+func EncodeBytes(img image.Image, format Format, opts ...EncodeOption) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := Encode(buf, img, format, opts...); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
 // Save saves the image to file with the specified filename.
 // The format is determined from the filename extension:
 // "jpg" (or "jpeg"), "png", "gif", "tif" (or "tiff") and "bmp" are supported.
@@ -266,11 +927,58 @@ func Encode(w io.Writer, img image.Image, format Format, opts ...EncodeOption) e
 //	err := imaging.Save(img, "out.jpg", imaging.JPEGQuality(80))
 //
 func Save(img image.Image, filename string, opts ...EncodeOption) (err error) {
+	return save(fs.Create, fs.Open, img, filename, opts...)
+}
+
+// SaveFS saves the image to the named file of the file system fsys.
+// The format is determined from the file name extension, as in Save.
+//
+// Example:
+//
+//	err := imaging.SaveFS(bucketFS, img, "thumbs/photo.jpg", imaging.JPEGQuality(80))
+//
+func SaveFS(fsys WriteFS, img image.Image, name string, opts ...EncodeOption) error {
+	open := func(name string) (io.ReadCloser, error) {
+		return nil, iofs.ErrNotExist
+	}
+	if rfs, ok := fsys.(iofs.FS); ok {
+		open = func(name string) (io.ReadCloser, error) {
+			return rfs.Open(name)
+		}
+	}
+	return save(fsys.Create, open, img, name, opts...)
+}
+
+// save encodes the image into the file created by the create function.
+// The open function is used to read the existing file for the SkipIfUnchanged option.
+func save(create func(string) (io.WriteCloser, error), open func(string) (io.ReadCloser, error), img image.Image, filename string, opts ...EncodeOption) error {
 	f, err := FormatFromFilename(filename)
 	if err != nil {
 		return err
 	}
-	file, err := fs.Create(filename)
+
+	cfg := newEncodeConfig(f, opts)
+	if cfg.skipIfUnchanged {
+		data, err := EncodeBytes(img, f, opts...)
+		if err != nil {
+			return err
+		}
+		if fileContentEquals(open, filename, data) {
+			return nil
+		}
+		file, err := create(filename)
+		if err != nil {
+			return err
+		}
+		_, err = file.Write(data)
+		errc := file.Close()
+		if err == nil {
+			err = errc
+		}
+		return err
+	}
+
+	file, err := create(filename)
 	if err != nil {
 		return err
 	}
@@ -282,6 +990,30 @@ func Save(img image.Image, filename string, opts ...EncodeOption) (err error) {
 	return err
 }
 
+// fileContentEquals reports whether the file exists and its content is equal to data.
+func fileContentEquals(open func(string) (io.ReadCloser, error), filename string, data []byte) bool {
+	file, err := open(filename)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := io.ReadFull(file, buf)
+		if n > len(data) || !bytes.Equal(buf[:n], data[:n]) {
+			return false
+		}
+		data = data[n:]
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return len(data) == 0
+		}
+		if err != nil {
+			return false
+		}
+	}
+}
+
 // orientation is an EXIF flag that specifies the transformation
 // that should be applied to image to display it correctly.
 type orientation int
@@ -0,0 +1,25 @@
+//go:build js
+
+package imaging
+
+import (
+	"errors"
+	"io"
+	iofs "io/fs"
+	"time"
+)
+
+// ErrNoFileSystem means that the file system is not available in the js/wasm build.
+// Use Decode, Encode, DecodeBytes and EncodeBytes instead of Open and Save.
+var ErrNoFileSystem = errors.New("imaging: file system is not available")
+
+// localFS is a stub file system used in the browser, where there is no file system access.
+type localFS struct{}
+
+func (localFS) Create(name string) (io.WriteCloser, error) { return nil, ErrNoFileSystem }
+func (localFS) Open(name string) (io.ReadCloser, error)    { return nil, ErrNoFileSystem }
+func (localFS) Stat(name string) (iofs.FileInfo, error)    { return nil, ErrNoFileSystem }
+
+func (localFS) WriteAtomic(name string, data []byte, modTime time.Time) error {
+	return ErrNoFileSystem
+}
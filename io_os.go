@@ -0,0 +1,45 @@
+//go:build !js
+
+package imaging
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type localFS struct{}
+
+func (localFS) Create(name string) (io.WriteCloser, error) { return os.Create(name) }
+func (localFS) Open(name string) (io.ReadCloser, error)    { return os.Open(name) }
+func (localFS) Stat(name string) (os.FileInfo, error)      { return os.Stat(name) }
+
+func (localFS) WriteAtomic(name string, data []byte, modTime time.Time) error {
+	dir := filepath.Dir(name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	// The data is written to a temporary file and renamed.
+	file, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if errc := file.Close(); err == nil {
+		err = errc
+	}
+	if err == nil {
+		err = os.Chmod(file.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Chtimes(file.Name(), modTime, modTime)
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), name)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+	}
+	return err
+}
@@ -2,18 +2,23 @@ package imaging
 
 import (
 	"bytes"
+	"encoding/binary"
 	"errors"
+	"hash/crc32"
 	"image"
 	"image/color"
 	"image/color/palette"
 	"image/draw"
 	"image/png"
 	"io"
+	iofs "io/fs"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 )
 
 var (
@@ -35,6 +40,14 @@ func (badFS) Open(name string) (io.ReadCloser, error) {
 	return nil, errOpen
 }
 
+func (badFS) Stat(name string) (iofs.FileInfo, error) {
+	return nil, errOpen
+}
+
+func (badFS) WriteAtomic(name string, data []byte, modTime time.Time) error {
+	return errCreate
+}
+
 type badFile struct {
 	io.Writer
 }
@@ -173,6 +186,494 @@ func TestOpenSave(t *testing.T) {
 	}
 }
 
+func TestSaveSkipIfUnchanged(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imaging")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "out.png")
+	img := New(4, 3, color.NRGBA{0x10, 0x20, 0x30, 0xff})
+	if err := Save(img, filename, SkipIfUnchanged(true)); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(filename, past, past); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+
+	modTime := func() time.Time {
+		info, err := os.Stat(filename)
+		if err != nil {
+			t.Fatalf("Stat: %v", err)
+		}
+		return info.ModTime()
+	}
+
+	if err := Save(img, filename, SkipIfUnchanged(true)); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if !modTime().Equal(past) {
+		t.Fatalf("unchanged file was written")
+	}
+
+	img2 := New(4, 3, color.NRGBA{0x10, 0x20, 0x31, 0xff})
+	if err := Save(img2, filename, SkipIfUnchanged(true)); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if modTime().Equal(past) {
+		t.Fatalf("changed file was not written")
+	}
+	got, err := Open(filename)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if !compareNRGBA(Clone(got), img2, 0) {
+		t.Fatalf("got image %#v want %#v", got, img2)
+	}
+
+	// A longer file with the same prefix is not equal.
+	data, err := EncodeBytes(img2, PNG)
+	if err != nil {
+		t.Fatalf("EncodeBytes: %v", err)
+	}
+	if err := ioutil.WriteFile(filename, append(data, 0), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if fileContentEquals(fs.Open, filename, data) {
+		t.Fatalf("files with different lengths are equal")
+	}
+	if fileContentEquals(fs.Open, filepath.Join(dir, "missing.png"), data) {
+		t.Fatalf("missing file is equal")
+	}
+}
+
+// memFS is an in-memory writable file system.
+type memFS struct {
+	fstest.MapFS
+	creates int
+}
+
+func (m *memFS) Create(name string) (io.WriteCloser, error) {
+	m.creates++
+	return &memFile{fsys: m, name: name}, nil
+}
+
+type memFile struct {
+	bytes.Buffer
+	fsys *memFS
+	name string
+}
+
+func (f *memFile) Close() error {
+	f.fsys.MapFS[f.name] = &fstest.MapFile{Data: f.Bytes()}
+	return nil
+}
+
+// createOnlyFS is a writable file system that doesn't implement fs.FS.
+type createOnlyFS struct {
+	fsys *memFS
+}
+
+func (c createOnlyFS) Create(name string) (io.WriteCloser, error) {
+	return c.fsys.Create(name)
+}
+
+func TestOpenSaveFS(t *testing.T) {
+	img := New(4, 3, color.NRGBA{0x10, 0x20, 0x30, 0xff})
+	fsys := &memFS{MapFS: fstest.MapFS{}}
+
+	if err := SaveFS(fsys, img, "dir/out.png"); err != nil {
+		t.Fatalf("SaveFS: %v", err)
+	}
+	if _, ok := fsys.MapFS["dir/out.png"]; !ok {
+		t.Fatalf("SaveFS didn't create the file")
+	}
+	got, err := OpenFS(fsys, "dir/out.png")
+	if err != nil {
+		t.Fatalf("OpenFS: %v", err)
+	}
+	if !compareNRGBA(Clone(got), img, 0) {
+		t.Fatalf("OpenFS: got %#v want %#v", got, img)
+	}
+
+	if err := SaveFS(fsys, img, "dir/out.png", SkipIfUnchanged(true)); err != nil {
+		t.Fatalf("SaveFS: %v", err)
+	}
+	if fsys.creates != 1 {
+		t.Fatalf("SaveFS rewrote an unchanged file")
+	}
+	if err := SaveFS(fsys, Invert(img), "dir/out.png", SkipIfUnchanged(true)); err != nil {
+		t.Fatalf("SaveFS: %v", err)
+	}
+	if fsys.creates != 2 {
+		t.Fatalf("SaveFS didn't rewrite a changed file")
+	}
+
+	// Without fs.FS the file can't be compared, so it's always written.
+	cfs := createOnlyFS{&memFS{MapFS: fstest.MapFS{}}}
+	for i := 0; i < 2; i++ {
+		if err := SaveFS(cfs, img, "out.png", SkipIfUnchanged(true)); err != nil {
+			t.Fatalf("SaveFS: %v", err)
+		}
+	}
+	if cfs.fsys.creates != 2 {
+		t.Fatalf("got %d creates want 2", cfs.fsys.creates)
+	}
+
+	if _, err := OpenFS(fsys, "missing.png"); !errors.Is(err, iofs.ErrNotExist) {
+		t.Fatalf("OpenFS: got error %v want fs.ErrNotExist", err)
+	}
+	if err := SaveFS(fsys, img, "out.unknown"); err != ErrUnsupportedFormat {
+		t.Fatalf("SaveFS: got error %v want ErrUnsupportedFormat", err)
+	}
+}
+
+func TestEncodeDecodeBytes(t *testing.T) {
+	img := New(4, 3, color.NRGBA{0x10, 0x20, 0x30, 0xff})
+	for _, format := range []Format{PNG, TIFF, BMP} {
+		data, err := EncodeBytes(img, format)
+		if err != nil {
+			t.Fatalf("EncodeBytes(%v): %v", format, err)
+		}
+		got, err := DecodeBytes(data)
+		if err != nil {
+			t.Fatalf("DecodeBytes(%v): %v", format, err)
+		}
+		if !compareNRGBA(Clone(got), img, 0) {
+			t.Fatalf("bad encode-decode result (%v): got %#v want %#v", format, got, img)
+		}
+	}
+
+	if _, err := EncodeBytes(img, Format(100)); err != ErrUnsupportedFormat {
+		t.Fatalf("got error %v want ErrUnsupportedFormat", err)
+	}
+	if _, err := DecodeBytes([]byte("bad data")); err == nil {
+		t.Fatalf("decoding bad data: expected error got nil")
+	}
+}
+
+// pngWithSize returns a valid PNG image header declaring the given size,
+// as found in the "decompression bomb" images.
+func pngWithSize(t *testing.T, width, height uint32) []byte {
+	data, err := EncodeBytes(New(1, 1, color.Black), PNG)
+	if err != nil {
+		t.Fatalf("EncodeBytes: %v", err)
+	}
+	// The IHDR chunk follows the 8-byte signature: length, type, data and CRC.
+	binary.BigEndian.PutUint32(data[16:], width)
+	binary.BigEndian.PutUint32(data[20:], height)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+	return data
+}
+
+func TestDecodeLimits(t *testing.T) {
+	img := New(40, 30, color.NRGBA{0x10, 0x20, 0x30, 0xff})
+	data, err := EncodeBytes(img, PNG)
+	if err != nil {
+		t.Fatalf("EncodeBytes: %v", err)
+	}
+
+	testCases := []struct {
+		name   string
+		data   []byte
+		limits Limits
+		err    error
+	}{
+		{"no limits", data, Limits{}, nil},
+		{"within limits", data, Limits{MaxWidth: 40, MaxHeight: 30, MaxPixels: 1200, MaxBytes: int64(len(data))}, nil},
+		{"width", data, Limits{MaxWidth: 39}, ErrLimitExceeded},
+		{"height", data, Limits{MaxHeight: 29}, ErrLimitExceeded},
+		{"pixels", data, Limits{MaxPixels: 1199}, ErrLimitExceeded},
+		{"bytes", data, Limits{MaxBytes: int64(len(data)) - 1}, ErrLimitExceeded},
+		{"bomb", pngWithSize(t, 100000, 100000), Limits{MaxPixels: 1 << 24}, ErrLimitExceeded},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := DecodeWithLimits(bytes.NewReader(tc.data), tc.limits)
+			if err != tc.err {
+				t.Fatalf("got error %v want %v", err, tc.err)
+			}
+			if err == nil && !compareNRGBA(Clone(got), img, 0) {
+				t.Fatalf("got image %#v want %#v", got, img)
+			}
+		})
+	}
+
+	_, err = DecodeBytes([]byte("bad data"), DecodeLimits(Limits{MaxPixels: 100}))
+	if err == nil {
+		t.Fatalf("decoding bad data: expected error got nil")
+	}
+
+	got, err := DecodeBytes(data, DecodeLimits(Limits{MaxPixels: 1200}), AutoOrientation(true))
+	if err != nil {
+		t.Fatalf("DecodeBytes: %v", err)
+	}
+	if !compareNRGBA(Clone(got), img, 0) {
+		t.Fatalf("got image %#v want %#v", got, img)
+	}
+}
+
+func TestRegisterFormatEncoder(t *testing.T) {
+	img := New(4, 3, color.NRGBA{0x10, 0x20, 0x30, 0xff})
+	if err := Encode(&bytes.Buffer{}, img, AVIF); err != ErrUnsupportedFormat {
+		t.Fatalf("got error %v want ErrUnsupportedFormat", err)
+	}
+
+	// A fake AVIF encoder storing the quality and a PNG in an ftyp box.
+	var gotQuality int
+	RegisterFormatEncoder(AVIF, FormatEncoderFunc(func(w io.Writer, img image.Image, quality int) error {
+		gotQuality = quality
+		if _, err := io.WriteString(w, "\x00\x00\x00\x0cftypavif"); err != nil {
+			return err
+		}
+		return png.Encode(w, img)
+	}))
+	defer RegisterFormatEncoder(AVIF, nil)
+	image.RegisterFormat("avif", "\x00\x00\x00\x0cftypavif", func(r io.Reader) (image.Image, error) {
+		if _, err := io.CopyN(io.Discard, r, 12); err != nil {
+			return nil, err
+		}
+		return png.Decode(r)
+	}, nil)
+
+	fsys := &memFS{MapFS: fstest.MapFS{}}
+	if err := SaveFS(fsys, img, "out.avif", AVIFQuality(45)); err != nil {
+		t.Fatalf("SaveFS: %v", err)
+	}
+	if gotQuality != 45 {
+		t.Fatalf("got quality %d want 45", gotQuality)
+	}
+	got, err := OpenFS(fsys, "out.avif")
+	if err != nil {
+		t.Fatalf("OpenFS: %v", err)
+	}
+	if !compareNRGBA(Clone(got), img, 0) {
+		t.Fatalf("got image %#v want %#v", got, img)
+	}
+
+	if _, err := EncodeBytes(img, AVIF); err != nil || gotQuality != 60 {
+		t.Fatalf("got error %v and quality %d want the default quality 60", err, gotQuality)
+	}
+
+	// The registered encoder replaces the built-in one.
+	RegisterFormatEncoder(BMP, FormatEncoderFunc(func(w io.Writer, img image.Image, quality int) error {
+		_, err := io.WriteString(w, "custom")
+		return err
+	}))
+	defer RegisterFormatEncoder(BMP, nil)
+	if data, _ := EncodeBytes(img, BMP); string(data) != "custom" {
+		t.Fatalf("got %q from the registered encoder", data)
+	}
+}
+
+func TestSetDefaultOptions(t *testing.T) {
+	img := Clone(testdataFlowersSmallPNG)
+	encode := func(format Format, opts ...EncodeOption) []byte {
+		t.Helper()
+		data, err := EncodeBytes(img, format, opts...)
+		if err != nil {
+			t.Fatalf("EncodeBytes: %v", err)
+		}
+		return data
+	}
+	q10, q95 := encode(JPEG, JPEGQuality(10)), encode(JPEG, JPEGQuality(95))
+	progressive := encode(JPEG, JPEGQuality(10), JPEGProgressive(true))
+
+	opts := []EncodeOption{JPEGQuality(10), JPEGProgressive(true)}
+	SetDefaultOptions(JPEG, opts...)
+	defer SetDefaultOptions(JPEG)
+	opts[1] = JPEGProgressive(false)
+	if !bytes.Equal(encode(JPEG), progressive) {
+		t.Fatalf("the default options aren't applied")
+	}
+	// The explicit options override the defaults.
+	if !bytes.Equal(encode(JPEG, JPEGQuality(95), JPEGProgressive(false)), q95) {
+		t.Fatalf("the explicit options don't override the defaults")
+	}
+	// The defaults of the other formats are separate.
+	if !bytes.Equal(encode(PNG), encode(PNG, JPEGQuality(10))) {
+		t.Fatalf("the JPEG defaults change PNG")
+	}
+
+	SetDefaultOptions(PNG, PNGCompressionLevel(png.NoCompression), SkipIfUnchanged(true))
+	defer SetDefaultOptions(PNG)
+	fsys := &memFS{MapFS: fstest.MapFS{}}
+	for i := 0; i < 2; i++ {
+		if err := SaveFS(fsys, img, "out.png"); err != nil {
+			t.Fatalf("SaveFS: %v", err)
+		}
+	}
+	if fsys.creates != 1 {
+		t.Fatalf("got %d creates, the default SkipIfUnchanged isn't applied", fsys.creates)
+	}
+	if n := len(fsys.MapFS["out.png"].Data); n < len(img.Pix)/4*3 {
+		t.Fatalf("got %d bytes of the uncompressed PNG", n)
+	}
+
+	SetDefaultOptions(JPEG)
+	if !bytes.Equal(encode(JPEG, JPEGQuality(10)), q10) {
+		t.Fatalf("the default options aren't removed")
+	}
+}
+
+func TestRegisterFormat(t *testing.T) {
+	// A raw format: the magic, the width and the height, followed by the NRGBA pixels.
+	decodeConfig := func(r io.Reader) (image.Config, error) {
+		var header [12]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil || string(header[:4]) != "TRAW" {
+			return image.Config{}, errors.New("not a raw image")
+		}
+		w, h := binary.BigEndian.Uint32(header[4:]), binary.BigEndian.Uint32(header[8:])
+		return image.Config{ColorModel: color.NRGBAModel, Width: int(w), Height: int(h)}, nil
+	}
+	decodes := 0
+	decode := func(r io.Reader) (image.Image, error) {
+		decodes++
+		var header [12]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil || string(header[:4]) != "TRAW" {
+			return nil, errors.New("not a raw image")
+		}
+		w, h := binary.BigEndian.Uint32(header[4:]), binary.BigEndian.Uint32(header[8:])
+		img := image.NewNRGBA(image.Rect(0, 0, int(w), int(h)))
+		if _, err := io.ReadFull(r, img.Pix); err != nil {
+			return nil, err
+		}
+		return img, nil
+	}
+	encode := func(w io.Writer, img image.Image, opts ...EncodeOption) error {
+		src := Clone(img)
+		var header [12]byte
+		copy(header[:], "TRAW")
+		binary.BigEndian.PutUint32(header[4:], uint32(src.Rect.Dx()))
+		binary.BigEndian.PutUint32(header[8:], uint32(src.Rect.Dy()))
+		if _, err := w.Write(header[:]); err != nil {
+			return err
+		}
+		_, err := w.Write(src.Pix)
+		return err
+	}
+	format := RegisterFormat("TESTRAW", []string{".traw", "tr"}, decode, decodeConfig, encode)
+	defer RegisterFormat("TESTRAW", nil, nil, nil, nil)
+
+	if format.String() != "TESTRAW" {
+		t.Fatalf("got format name %q", format.String())
+	}
+	if again := RegisterFormat("testraw", nil, decode, decodeConfig, encode); again != format {
+		t.Fatalf("got format %d for the same name want %d", again, format)
+	}
+	for _, ext := range []string{"traw", ".TR"} {
+		if f, err := FormatFromExtension(ext); err != nil || f != format {
+			t.Fatalf("FormatFromExtension(%q): got %v, %v", ext, f, err)
+		}
+	}
+
+	img := New(5, 4, color.NRGBA{0x10, 0x20, 0x30, 0x40})
+	fsys := &memFS{MapFS: fstest.MapFS{}}
+	if err := SaveFS(fsys, img, "out.traw"); err != nil {
+		t.Fatalf("SaveFS: %v", err)
+	}
+	if data := fsys.MapFS["out.traw"].Data; string(data[:4]) != "TRAW" {
+		t.Fatalf("got data %q", data[:4])
+	}
+	got, err := OpenFS(fsys, "out.traw", AutoOrientation(true))
+	if err != nil {
+		t.Fatalf("OpenFS: %v", err)
+	}
+	if !compareNRGBA(Clone(got), img, 0) {
+		t.Fatalf("got image %#v want %#v", got, img)
+	}
+
+	// The limits are checked before decoding, even if the data declares a huge size.
+	valid, _ := EncodeBytes(img, format)
+	data := append([]byte(nil), valid...)
+	binary.BigEndian.PutUint32(data[4:], 1<<30)
+	decodes = 0
+	if _, err := DecodeWithLimits(bytes.NewReader(data), Limits{MaxPixels: 1000}); err != ErrLimitExceeded {
+		t.Fatalf("got error %v want ErrLimitExceeded", err)
+	}
+	if decodes != 0 {
+		t.Fatalf("the image exceeding the limits is decoded")
+	}
+	if _, err := DecodeWithLimits(bytes.NewReader([]byte("garbage")), Limits{MaxPixels: 1000}); err != image.ErrFormat {
+		t.Fatalf("got error %v want image.ErrFormat", err)
+	}
+	if _, err := DecodeBytes([]byte("garbage")); err != image.ErrFormat {
+		t.Fatalf("got error %v want image.ErrFormat", err)
+	}
+	png, _ := EncodeBytes(img, PNG)
+	if _, err := DecodeBytes(png); err != nil {
+		t.Fatalf("DecodeBytes: %v", err)
+	}
+
+	// The encoder registered with RegisterFormatEncoder replaces the one of the format.
+	RegisterFormatEncoder(format, FormatEncoderFunc(func(w io.Writer, img image.Image, quality int) error {
+		_, err := io.WriteString(w, "custom")
+		return err
+	}))
+	if data, _ := EncodeBytes(img, format); string(data) != "custom" {
+		t.Fatalf("got %q from the registered encoder", data)
+	}
+	RegisterFormatEncoder(format, nil)
+	if _, err := EncodeBytes(img, format); err != ErrUnsupportedFormat {
+		t.Fatalf("got error %v want ErrUnsupportedFormat", err)
+	}
+	if _, err := DecodeBytes(valid); err != nil {
+		t.Fatalf("the decoder is removed with the encoder: %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("RegisterFormat without decodeConfig doesn't panic")
+		}
+	}()
+	RegisterFormat("TESTRAW2", nil, decode, nil, nil)
+}
+
+func TestRegisterFormatBuiltin(t *testing.T) {
+	// The decoder registered for PNG replaces the one of the image package.
+	marker := color.NRGBA{1, 2, 3, 4}
+	decode := func(r io.Reader) (image.Image, error) {
+		cfg, err := png.DecodeConfig(r)
+		if err != nil {
+			return nil, err
+		}
+		return New(cfg.Width, cfg.Height, marker), nil
+	}
+	RegisterFormat("PNG", nil, decode, png.DecodeConfig, nil)
+	defer RegisterFormat("PNG", nil, nil, nil, nil)
+
+	img := New(3, 2, color.NRGBA{0x10, 0x20, 0x30, 0xff})
+	data, err := EncodeBytes(img, PNG)
+	if err != nil {
+		t.Fatalf("EncodeBytes: %v", err)
+	}
+	for _, opts := range [][]DecodeOption{nil, {DecodeLimits(Limits{MaxPixels: 100})}} {
+		got, err := DecodeBytes(data, opts...)
+		if err != nil {
+			t.Fatalf("DecodeBytes: %v", err)
+		}
+		if want := New(3, 2, marker); !compareNRGBA(Clone(got), want, 0) {
+			t.Fatalf("the image isn't decoded by the registered decoder: got %#v", got)
+		}
+	}
+	if _, err := DecodeBytes(data, DecodeLimits(Limits{MaxPixels: 5})); err != ErrLimitExceeded {
+		t.Fatalf("got error %v want ErrLimitExceeded", err)
+	}
+
+	// The other formats are still decoded by the image package.
+	gif, _ := EncodeBytes(img, GIF)
+	if got, err := DecodeBytes(gif); err != nil || !compareNRGBA(Clone(got), img, 0) {
+		t.Fatalf("DecodeBytes(GIF): got %v, %v", got, err)
+	}
+
+	RegisterFormat("PNG", nil, nil, nil, nil)
+	if got, err := DecodeBytes(data); err != nil || !compareNRGBA(Clone(got), img, 0) {
+		t.Fatalf("the built-in decoder isn't restored: got %v, %v", got, err)
+	}
+}
+
 func TestFormats(t *testing.T) {
 	formatNames := map[Format]string{
 		JPEG:       "JPEG",
@@ -180,6 +681,7 @@ func TestFormats(t *testing.T) {
 		GIF:        "GIF",
 		BMP:        "BMP",
 		TIFF:       "TIFF",
+		AVIF:       "AVIF",
 		Format(-1): "",
 	}
 	for format, name := range formatNames {
@@ -212,6 +714,11 @@ func TestFormatFromExtension(t *testing.T) {
 			ext:  ".JPG",
 			want: JPEG,
 		},
+		{
+			name: "avif",
+			ext:  ".avif",
+			want: AVIF,
+		},
 		{
 			name: "unsupported",
 			ext:  ".unsupportedextension",
@@ -0,0 +1,516 @@
+package imaging
+
+import (
+	"bufio"
+	"errors"
+	"image"
+	"io"
+	"math"
+)
+
+// ChromaSubsampling is the ratio of the chroma subsampling of the JPEG-encoded image.
+type ChromaSubsampling int
+
+// Chroma subsampling ratios.
+const (
+	// Subsampling420 halves the chroma resolution horizontally and vertically (the default).
+	Subsampling420 ChromaSubsampling = iota
+	// Subsampling422 halves the chroma resolution horizontally.
+	Subsampling422
+	// Subsampling444 keeps the full chroma resolution. It's the best choice for graphics
+	// and text with saturated colors, where the subsampling smears the edges.
+	Subsampling444
+)
+
+// jpegZigzag maps the zig-zag order of the coefficients to the natural order.
+var jpegZigzag = [64]int{
+	0, 1, 8, 16, 9, 2, 3, 10,
+	17, 24, 32, 25, 18, 11, 4, 5,
+	12, 19, 26, 33, 40, 48, 41, 34,
+	27, 20, 13, 6, 7, 14, 21, 28,
+	35, 42, 49, 56, 57, 50, 43, 36,
+	29, 22, 15, 23, 30, 37, 44, 51,
+	58, 59, 52, 45, 38, 31, 39, 46,
+	53, 60, 61, 54, 47, 55, 62, 63,
+}
+
+// jpegQuant are the luminance and chrominance quantization tables of the section K.1
+// of the JPEG specification in the natural order.
+var jpegQuant = [2][64]int{
+	{
+		16, 11, 10, 16, 24, 40, 51, 61,
+		12, 12, 14, 19, 26, 58, 60, 55,
+		14, 13, 16, 24, 40, 57, 69, 56,
+		14, 17, 22, 29, 51, 87, 80, 62,
+		18, 22, 37, 56, 68, 109, 103, 77,
+		24, 35, 55, 64, 81, 104, 113, 92,
+		49, 64, 78, 87, 103, 121, 120, 101,
+		72, 92, 95, 98, 112, 100, 103, 99,
+	},
+	{
+		17, 18, 24, 47, 99, 99, 99, 99,
+		18, 21, 26, 66, 99, 99, 99, 99,
+		24, 26, 56, 99, 99, 99, 99, 99,
+		47, 66, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+	},
+}
+
+// jpegHuffmanSpec is a Huffman table: the number of codes of each length
+// from 1 to 16 bits and the coded values.
+type jpegHuffmanSpec struct {
+	count [16]uint8
+	value []uint8
+}
+
+// jpegHuffman are the luminance DC, luminance AC, chrominance DC and chrominance AC
+// Huffman tables of the section K.3 of the JPEG specification.
+var jpegHuffman = [4]jpegHuffmanSpec{
+	{
+		[16]uint8{0, 1, 5, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0, 0, 0, 0},
+		[]uint8{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11},
+	},
+	{
+		[16]uint8{0, 2, 1, 3, 3, 2, 4, 3, 5, 5, 4, 4, 0, 0, 1, 125},
+		[]uint8{
+			0x01, 0x02, 0x03, 0x00, 0x04, 0x11, 0x05, 0x12,
+			0x21, 0x31, 0x41, 0x06, 0x13, 0x51, 0x61, 0x07,
+			0x22, 0x71, 0x14, 0x32, 0x81, 0x91, 0xa1, 0x08,
+			0x23, 0x42, 0xb1, 0xc1, 0x15, 0x52, 0xd1, 0xf0,
+			0x24, 0x33, 0x62, 0x72, 0x82, 0x09, 0x0a, 0x16,
+			0x17, 0x18, 0x19, 0x1a, 0x25, 0x26, 0x27, 0x28,
+			0x29, 0x2a, 0x34, 0x35, 0x36, 0x37, 0x38, 0x39,
+			0x3a, 0x43, 0x44, 0x45, 0x46, 0x47, 0x48, 0x49,
+			0x4a, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58, 0x59,
+			0x5a, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68, 0x69,
+			0x6a, 0x73, 0x74, 0x75, 0x76, 0x77, 0x78, 0x79,
+			0x7a, 0x83, 0x84, 0x85, 0x86, 0x87, 0x88, 0x89,
+			0x8a, 0x92, 0x93, 0x94, 0x95, 0x96, 0x97, 0x98,
+			0x99, 0x9a, 0xa2, 0xa3, 0xa4, 0xa5, 0xa6, 0xa7,
+			0xa8, 0xa9, 0xaa, 0xb2, 0xb3, 0xb4, 0xb5, 0xb6,
+			0xb7, 0xb8, 0xb9, 0xba, 0xc2, 0xc3, 0xc4, 0xc5,
+			0xc6, 0xc7, 0xc8, 0xc9, 0xca, 0xd2, 0xd3, 0xd4,
+			0xd5, 0xd6, 0xd7, 0xd8, 0xd9, 0xda, 0xe1, 0xe2,
+			0xe3, 0xe4, 0xe5, 0xe6, 0xe7, 0xe8, 0xe9, 0xea,
+			0xf1, 0xf2, 0xf3, 0xf4, 0xf5, 0xf6, 0xf7, 0xf8,
+			0xf9, 0xfa,
+		},
+	},
+	{
+		[16]uint8{0, 3, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0, 0},
+		[]uint8{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11},
+	},
+	{
+		[16]uint8{0, 2, 1, 2, 4, 4, 3, 4, 7, 5, 4, 4, 0, 1, 2, 119},
+		[]uint8{
+			0x00, 0x01, 0x02, 0x03, 0x11, 0x04, 0x05, 0x21,
+			0x31, 0x06, 0x12, 0x41, 0x51, 0x07, 0x61, 0x71,
+			0x13, 0x22, 0x32, 0x81, 0x08, 0x14, 0x42, 0x91,
+			0xa1, 0xb1, 0xc1, 0x09, 0x23, 0x33, 0x52, 0xf0,
+			0x15, 0x62, 0x72, 0xd1, 0x0a, 0x16, 0x24, 0x34,
+			0xe1, 0x25, 0xf1, 0x17, 0x18, 0x19, 0x1a, 0x26,
+			0x27, 0x28, 0x29, 0x2a, 0x35, 0x36, 0x37, 0x38,
+			0x39, 0x3a, 0x43, 0x44, 0x45, 0x46, 0x47, 0x48,
+			0x49, 0x4a, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58,
+			0x59, 0x5a, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68,
+			0x69, 0x6a, 0x73, 0x74, 0x75, 0x76, 0x77, 0x78,
+			0x79, 0x7a, 0x82, 0x83, 0x84, 0x85, 0x86, 0x87,
+			0x88, 0x89, 0x8a, 0x92, 0x93, 0x94, 0x95, 0x96,
+			0x97, 0x98, 0x99, 0x9a, 0xa2, 0xa3, 0xa4, 0xa5,
+			0xa6, 0xa7, 0xa8, 0xa9, 0xaa, 0xb2, 0xb3, 0xb4,
+			0xb5, 0xb6, 0xb7, 0xb8, 0xb9, 0xba, 0xc2, 0xc3,
+			0xc4, 0xc5, 0xc6, 0xc7, 0xc8, 0xc9, 0xca, 0xd2,
+			0xd3, 0xd4, 0xd5, 0xd6, 0xd7, 0xd8, 0xd9, 0xda,
+			0xe2, 0xe3, 0xe4, 0xe5, 0xe6, 0xe7, 0xe8, 0xe9,
+			0xea, 0xf2, 0xf3, 0xf4, 0xf5, 0xf6, 0xf7, 0xf8,
+			0xf9, 0xfa,
+		},
+	},
+}
+
+// jpegHuffmanCodes returns the code of each value of the table: the code length
+// in the high 8 bits and the code in the low 24 bits.
+func jpegHuffmanCodes(s jpegHuffmanSpec) [256]uint32 {
+	var codes [256]uint32
+	code, k := uint32(0), 0
+	for i, n := range s.count {
+		for j := uint8(0); j < n; j++ {
+			codes[s.value[k]] = uint32(i+1)<<24 | code
+			code++
+			k++
+		}
+		code <<= 1
+	}
+	return codes
+}
+
+// jpegDCTCos holds the DCT basis functions: C(u)/2 * cos((2x+1)uπ/16).
+var jpegDCTCos = func() (c [8][8]float64) {
+	for u := 0; u < 8; u++ {
+		cu := 0.5
+		if u == 0 {
+			cu = 0.5 / math.Sqrt2
+		}
+		for x := 0; x < 8; x++ {
+			c[u][x] = cu * math.Cos(float64(2*x+1)*float64(u)*math.Pi/16)
+		}
+	}
+	return c
+}()
+
+// jpegComponent is a color component of the encoded image.
+type jpegComponent struct {
+	id     byte
+	h, v   int // Sampling factors.
+	table  int // Index of the quantization table and of the pair of Huffman tables.
+	bw, bh int // Number of blocks per row and column, including the MCU padding.
+	cw, ch int // Number of blocks covering the component samples without the padding.
+	blocks [][64]int32
+}
+
+// errJPEGSize means the image is too large for JPEG.
+var errJPEGSize = errors.New("imaging: image is too large to encode as JPEG")
+
+// encodeJPEG encodes the image as JPEG with the given chroma subsampling,
+// as a baseline or a progressive image. If keepCMYK is set, *image.CMYK images
+// are encoded as Adobe CMYK JPEGs.
+func encodeJPEG(w io.Writer, img image.Image, quality int, subsampling ChromaSubsampling, progressive, keepCMYK bool) error {
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+	if width <= 0 || height <= 0 || width >= 1<<16 || height >= 1<<16 {
+		return errJPEGSize
+	}
+	if quality < 1 {
+		quality = 1
+	} else if quality > 100 {
+		quality = 100
+	}
+	scale := 200 - quality*2
+	if quality < 50 {
+		scale = 5000 / quality
+	}
+	var quant [2][64]int32
+	for t := range quant {
+		for i, q := range jpegQuant[t] {
+			quant[t][i] = int32(min(max((q*scale+50)/100, 1), 255))
+		}
+	}
+
+	_, gray := img.(*image.Gray)
+	cmykImg, cmyk := img.(*image.CMYK)
+	cmyk = cmyk && keepCMYK && validBuffers(img)
+	var comps []*jpegComponent
+	if gray {
+		comps = []*jpegComponent{{id: 1, h: 1, v: 1}}
+	} else if cmyk {
+		comps = []*jpegComponent{{id: 1, h: 1, v: 1}, {id: 2, h: 1, v: 1}, {id: 3, h: 1, v: 1}, {id: 4, h: 1, v: 1}}
+	} else {
+		y := &jpegComponent{id: 1, h: 1, v: 1}
+		switch subsampling {
+		case Subsampling420:
+			y.h, y.v = 2, 2
+		case Subsampling422:
+			y.h = 2
+		}
+		comps = []*jpegComponent{y, {id: 2, h: 1, v: 1, table: 1}, {id: 3, h: 1, v: 1, table: 1}}
+	}
+	hmax, vmax := comps[0].h, comps[0].v
+	mcusX := (width + 8*hmax - 1) / (8 * hmax)
+	mcusY := (height + 8*vmax - 1) / (8 * vmax)
+	for _, c := range comps {
+		c.bw, c.bh = mcusX*c.h, mcusY*c.v
+		c.cw = ((width*c.h+hmax-1)/hmax + 7) / 8
+		c.ch = ((height*c.v+vmax-1)/vmax + 7) / 8
+		c.blocks = make([][64]int32, c.bw*c.bh)
+	}
+
+	// The full resolution planes of the components, padded to whole MCUs
+	// by repeating the edge pixels.
+	pw, ph := mcusX*8*hmax, mcusY*8*vmax
+	planes := make([][]float32, len(comps))
+	for i := range planes {
+		planes[i] = make([]float32, pw*ph)
+	}
+	src := newScanner(img)
+	parallel(0, ph, pw*costPoint, func(ys <-chan int) {
+		line := make([]uint8, width*4)
+		for y := range ys {
+			if cmyk {
+				row := cmykImg.Pix[min(y, height-1)*cmykImg.Stride:]
+				for x := 0; x < pw; x++ {
+					s := row[min(x, width-1)*4:]
+					for c := range planes {
+						// Adobe CMYK JPEGs store the inverted values.
+						planes[c][y*pw+x] = float32(255 - s[c])
+					}
+				}
+				continue
+			}
+			src.scan(0, min(y, height-1), width, min(y, height-1)+1, line)
+			for x := 0; x < pw; x++ {
+				s := line[min(x, width-1)*4:]
+				// Transparent pixels are composited over black.
+				a := float32(s[3]) / 255
+				r, g, bl := float32(s[0])*a, float32(s[1])*a, float32(s[2])*a
+				i := y*pw + x
+				planes[0][i] = 0.299*r + 0.587*g + 0.114*bl
+				if !gray {
+					planes[1][i] = -0.168736*r - 0.331264*g + 0.5*bl + 128
+					planes[2][i] = 0.5*r - 0.418688*g - 0.081312*bl + 128
+				}
+			}
+		}
+	})
+
+	for ci, c := range comps {
+		fx, fy := hmax/c.h, vmax/c.v
+		plane := planes[ci]
+		quant := &quant[c.table]
+		parallel(0, c.bh, c.bw*64*(fx*fy+16)*costTap, func(bys <-chan int) {
+			var block [64]float64
+			for by := range bys {
+				for bx := 0; bx < c.bw; bx++ {
+					// Average the samples of the subsampled components.
+					for y := 0; y < 8; y++ {
+						for x := 0; x < 8; x++ {
+							var sum float32
+							for dy := 0; dy < fy; dy++ {
+								row := ((by*8+y)*fy + dy) * pw
+								for dx := 0; dx < fx; dx++ {
+									sum += plane[row+(bx*8+x)*fx+dx]
+								}
+							}
+							block[y*8+x] = float64(sum)/float64(fx*fy) - 128
+						}
+					}
+					jpegFDCT(&block)
+					dst := &c.blocks[by*c.bw+bx]
+					for k, n := range jpegZigzag {
+						dst[k] = int32(math.Round(block[n] / float64(quant[n])))
+					}
+				}
+			}
+		})
+	}
+
+	e := &jpegWriter{w: bufio.NewWriter(w)}
+	for t := range e.codes {
+		e.codes[t] = jpegHuffmanCodes(jpegHuffman[t])
+	}
+	e.marker(0xd8, nil)
+	if cmyk {
+		// The Adobe APP14 segment with the transform 0 (no color conversion).
+		e.marker(0xee, []byte{'A', 'd', 'o', 'b', 'e', 0, 0x64, 0, 0, 0, 0, 0})
+	}
+
+	tables := 2
+	if gray || cmyk {
+		tables = 1
+	}
+	dqt := make([]byte, 0, tables*65)
+	for t := 0; t < tables; t++ {
+		dqt = append(dqt, byte(t))
+		for _, n := range jpegZigzag {
+			dqt = append(dqt, byte(quant[t][n]))
+		}
+	}
+	e.marker(0xdb, dqt)
+
+	sof := []byte{8, byte(height >> 8), byte(height), byte(width >> 8), byte(width), byte(len(comps))}
+	for _, c := range comps {
+		sof = append(sof, c.id, byte(c.h<<4|c.v), byte(c.table))
+	}
+	if progressive {
+		e.marker(0xc2, sof)
+	} else {
+		e.marker(0xc0, sof)
+	}
+
+	var dht []byte
+	for t := 0; t < 2*tables; t++ {
+		s := jpegHuffman[t]
+		// The DC tables are class 0, the AC tables are class 1.
+		dht = append(dht, byte((t%2)<<4|t/2))
+		dht = append(dht, s.count[:]...)
+		dht = append(dht, s.value...)
+	}
+	e.marker(0xc4, dht)
+
+	if progressive {
+		e.scan(comps, mcusX, mcusY, 0, 0)
+		for _, c := range comps {
+			if c.table == 0 {
+				e.scan([]*jpegComponent{c}, mcusX, mcusY, 1, 5)
+				e.scan([]*jpegComponent{c}, mcusX, mcusY, 6, 63)
+			} else {
+				e.scan([]*jpegComponent{c}, mcusX, mcusY, 1, 63)
+			}
+		}
+	} else {
+		e.scan(comps, mcusX, mcusY, 0, 63)
+	}
+	e.marker(0xd9, nil)
+	if e.err != nil {
+		return e.err
+	}
+	return e.w.Flush()
+}
+
+// jpegFDCT computes the forward DCT of the 8x8 block in place.
+func jpegFDCT(block *[64]float64) {
+	var tmp [64]float64
+	for y := 0; y < 8; y++ {
+		row := block[y*8 : y*8+8 : y*8+8]
+		for u := 0; u < 8; u++ {
+			c := &jpegDCTCos[u]
+			tmp[y*8+u] = c[0]*row[0] + c[1]*row[1] + c[2]*row[2] + c[3]*row[3] +
+				c[4]*row[4] + c[5]*row[5] + c[6]*row[6] + c[7]*row[7]
+		}
+	}
+	for x := 0; x < 8; x++ {
+		for v := 0; v < 8; v++ {
+			c := &jpegDCTCos[v]
+			var sum float64
+			for y := 0; y < 8; y++ {
+				sum += c[y] * tmp[y*8+x]
+			}
+			block[v*8+x] = sum
+		}
+	}
+}
+
+// jpegWriter writes the JPEG markers and the entropy-coded data.
+type jpegWriter struct {
+	w     *bufio.Writer
+	codes [4][256]uint32
+	bits  uint32
+	nBits uint
+	err   error
+}
+
+func (e *jpegWriter) writeByte(b byte) {
+	if e.err == nil {
+		e.err = e.w.WriteByte(b)
+	}
+}
+
+// marker writes the marker and its payload, if any.
+func (e *jpegWriter) marker(m byte, payload []byte) {
+	e.writeByte(0xff)
+	e.writeByte(m)
+	if m == 0xd8 || m == 0xd9 {
+		return
+	}
+	n := len(payload) + 2
+	e.writeByte(byte(n >> 8))
+	e.writeByte(byte(n))
+	if e.err == nil {
+		_, e.err = e.w.Write(payload)
+	}
+}
+
+// emit writes the n low bits of bits, escaping the 0xff bytes.
+func (e *jpegWriter) emit(bits uint32, n uint) {
+	e.bits = e.bits<<n | bits&(1<<n-1)
+	e.nBits += n
+	for e.nBits >= 8 {
+		b := byte(e.bits >> (e.nBits - 8))
+		e.writeByte(b)
+		if b == 0xff {
+			e.writeByte(0)
+		}
+		e.nBits -= 8
+	}
+}
+
+// emitHuffman writes the Huffman code of the value.
+func (e *jpegWriter) emitHuffman(table int, value uint8) {
+	c := e.codes[table][value]
+	e.emit(c&(1<<24-1), uint(c>>24))
+}
+
+// emitValue writes the Huffman code of the run length and the size of the value
+// followed by the value bits.
+func (e *jpegWriter) emitValue(table int, run int, value int32) {
+	a, b := value, value
+	if a < 0 {
+		a, b = -value, value-1
+	}
+	size := uint(0)
+	for a > 0 {
+		size++
+		a >>= 1
+	}
+	e.emitHuffman(table, uint8(run<<4)|uint8(size))
+	if size > 0 {
+		e.emit(uint32(b), size)
+	}
+}
+
+// scan writes a scan of the coefficients from ss to se of the components.
+// Scans of several components are interleaved by MCUs, a scan of a single
+// component only covers the blocks of its samples.
+func (e *jpegWriter) scan(comps []*jpegComponent, mcusX, mcusY int, ss, se int) {
+	sos := []byte{byte(len(comps))}
+	for _, c := range comps {
+		sos = append(sos, c.id, byte(c.table<<4|c.table))
+	}
+	sos = append(sos, byte(ss), byte(se), 0)
+	e.marker(0xda, sos)
+
+	var pred [4]int32
+	block := func(i int, c *jpegComponent, b *[64]int32) {
+		dc, ac := 2*c.table, 2*c.table+1
+		if ss == 0 {
+			e.emitValue(dc, 0, b[0]-pred[i])
+			pred[i] = b[0]
+		}
+		if se == 0 {
+			return
+		}
+		run := 0
+		for k := max(ss, 1); k <= se; k++ {
+			if b[k] == 0 {
+				run++
+				continue
+			}
+			for ; run > 15; run -= 16 {
+				e.emitHuffman(ac, 0xf0)
+			}
+			e.emitValue(ac, run, b[k])
+			run = 0
+		}
+		if run > 0 {
+			e.emitHuffman(ac, 0x00)
+		}
+	}
+
+	if len(comps) == 1 {
+		c := comps[0]
+		for by := 0; by < c.ch; by++ {
+			for bx := 0; bx < c.cw; bx++ {
+				block(0, c, &c.blocks[by*c.bw+bx])
+			}
+		}
+	} else {
+		for my := 0; my < mcusY; my++ {
+			for mx := 0; mx < mcusX; mx++ {
+				for i, c := range comps {
+					for v := 0; v < c.v; v++ {
+						for h := 0; h < c.h; h++ {
+							block(i, c, &c.blocks[(my*c.v+v)*c.bw+mx*c.h+h])
+						}
+					}
+				}
+			}
+		}
+	}
+	// Pad the last byte with 1 bits.
+	if e.nBits > 0 {
+		e.emit(0x7f, 8-e.nBits)
+	}
+	e.bits, e.nBits = 0, 0
+}
@@ -0,0 +1,139 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// meanAbsDiff returns the mean absolute difference of the RGB channels of the images.
+func meanAbsDiff(a, b image.Image) float64 {
+	na, nb := toNRGBA(a), toNRGBA(b)
+	var sum, n float64
+	for i := 0; i < len(na.Pix); i += 4 {
+		for c := 0; c < 3; c++ {
+			sum += float64(absint(int(na.Pix[i+c]) - int(nb.Pix[i+c])))
+			n++
+		}
+	}
+	return sum / n
+}
+
+func TestEncodeJPEGOptions(t *testing.T) {
+	// The odd size doesn't match the MCUs of any subsampling.
+	src := Resize(testdataFlowersSmallPNG, 237, 155, Box)
+	testCases := []struct {
+		name        string
+		subsampling ChromaSubsampling
+		progressive bool
+		sof         byte
+		ratio       image.YCbCrSubsampleRatio
+	}{
+		{"444", Subsampling444, false, 0xc0, image.YCbCrSubsampleRatio444},
+		{"422", Subsampling422, false, 0xc0, image.YCbCrSubsampleRatio422},
+		{"420 progressive", Subsampling420, true, 0xc2, image.YCbCrSubsampleRatio420},
+		{"444 progressive", Subsampling444, true, 0xc2, image.YCbCrSubsampleRatio444},
+		{"422 progressive", Subsampling422, true, 0xc2, image.YCbCrSubsampleRatio422},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := EncodeBytes(src, JPEG, JPEGSubsampling(tc.subsampling), JPEGProgressive(tc.progressive))
+			if err != nil {
+				t.Fatalf("EncodeBytes: %v", err)
+			}
+			if !bytes.Contains(data, []byte{0xff, tc.sof}) {
+				t.Fatalf("SOF marker 0xff%02x not found", tc.sof)
+			}
+			got, err := jpeg.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("jpeg.Decode: %v", err)
+			}
+			ycbcr, ok := got.(*image.YCbCr)
+			if !ok {
+				t.Fatalf("got decoded image %T want *image.YCbCr", got)
+			}
+			if ycbcr.SubsampleRatio != tc.ratio {
+				t.Fatalf("got subsample ratio %v want %v", ycbcr.SubsampleRatio, tc.ratio)
+			}
+			if got.Bounds() != src.Bounds() {
+				t.Fatalf("got bounds %v want %v", got.Bounds(), src.Bounds())
+			}
+			if d := meanAbsDiff(got, src); d > 3 {
+				t.Fatalf("got mean difference %.2f", d)
+			}
+		})
+	}
+}
+
+func TestEncodeJPEGDefault(t *testing.T) {
+	// The default options keep the standard library encoder.
+	var want bytes.Buffer
+	if err := jpeg.Encode(&want, testdataBranchesPNG, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatalf("jpeg.Encode: %v", err)
+	}
+	got, err := EncodeBytes(testdataBranchesPNG, JPEG, JPEGSubsampling(Subsampling420), JPEGProgressive(false))
+	if err != nil {
+		t.Fatalf("EncodeBytes: %v", err)
+	}
+	if !bytes.Equal(got, want.Bytes()) {
+		t.Fatalf("the output differs from the standard library encoder")
+	}
+}
+
+func TestEncodeJPEGChromaDetail(t *testing.T) {
+	// Thin red lines on white are smeared by the chroma subsampling.
+	src := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			c := color.NRGBA{255, 255, 255, 255}
+			if x%4 == 0 {
+				c = color.NRGBA{255, 0, 0, 255}
+			}
+			src.SetNRGBA(x, y, c)
+		}
+	}
+	diff := func(s ChromaSubsampling) float64 {
+		data, err := EncodeBytes(src, JPEG, JPEGSubsampling(s), JPEGQuality(90))
+		if err != nil {
+			t.Fatalf("EncodeBytes: %v", err)
+		}
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("jpeg.Decode: %v", err)
+		}
+		return meanAbsDiff(img, src)
+	}
+	if d444, d420 := diff(Subsampling444), diff(Subsampling420); d444*2 > d420 {
+		t.Fatalf("got difference %.2f for 4:4:4 and %.2f for 4:2:0", d444, d420)
+	}
+}
+
+func TestEncodeJPEGGray(t *testing.T) {
+	src := image.NewGray(image.Rect(0, 0, 30, 20))
+	for i := range src.Pix {
+		src.Pix[i] = uint8(i * 7)
+	}
+	data, err := EncodeBytes(src, JPEG, JPEGProgressive(true), JPEGQuality(100))
+	if err != nil {
+		t.Fatalf("EncodeBytes: %v", err)
+	}
+	got, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("jpeg.Decode: %v", err)
+	}
+	if _, ok := got.(*image.Gray); !ok {
+		t.Fatalf("got decoded image %T want *image.Gray", got)
+	}
+	if d := meanAbsDiff(got, src); d > 2 {
+		t.Fatalf("got mean difference %.2f", d)
+	}
+}
+
+func TestEncodeJPEGEmpty(t *testing.T) {
+	_, err := EncodeBytes(image.NewNRGBA(image.Rect(0, 0, 0, 0)), JPEG, JPEGProgressive(true))
+	if err == nil {
+		t.Fatalf("expected error for the empty image")
+	}
+}
@@ -0,0 +1,117 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// JustifiedLayout arranges the images in rows of equal height, as photo galleries do.
+// Each row is scaled to fill the maximum width exactly, keeping the aspect ratios of
+// the images, and the row heights stay as close to the target row height as possible.
+// The last row is not stretched if it's too short to fill the width. The images are
+// separated by gutter pixels horizontally and vertically.
+//
+// It returns the rectangle of each image in the layout, in the same order. The empty images
+// get empty rectangles. Use RenderLayout to draw the images into the computed rectangles.
+// It returns nil if the target row height or the maximum width is not positive or
+// the gutter is negative.
+//
+// Example:
+//
+//	rects := imaging.JustifiedLayout(photos, 200, 1200, 8)
+//	page := imaging.RenderLayout(photos, rects, color.White, imaging.Lanczos)
+//
+func JustifiedLayout(images []image.Image, targetRowHeight, maxWidth, gutter int) []image.Rectangle {
+	if targetRowHeight <= 0 || maxWidth <= 0 || gutter < 0 {
+		return nil
+	}
+	rects := make([]image.Rectangle, len(images))
+	target := float64(targetRowHeight)
+
+	var row []int
+	var aspectSum float64
+	y := 0
+	// rowHeight returns the height of the row that fills the maximum width.
+	rowHeight := func(n int, aspects float64) float64 {
+		return float64(maxWidth-gutter*(n-1)) / aspects
+	}
+	place := func(height float64) {
+		h := int(math.Floor(height + 0.5))
+		if h < 1 {
+			h = 1
+		}
+		pos := 0.0
+		for _, i := range row {
+			b := images[i].Bounds()
+			w := height * float64(b.Dx()) / float64(b.Dy())
+			x0 := int(math.Floor(pos + 0.5))
+			x1 := int(math.Floor(pos + w + 0.5))
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			rects[i] = image.Rect(x0, y, x1, y+h)
+			pos += w + float64(gutter)
+		}
+		y += h + gutter
+		row = row[:0]
+		aspectSum = 0
+	}
+
+	for i, img := range images {
+		b := img.Bounds()
+		if b.Dx() <= 0 || b.Dy() <= 0 {
+			continue
+		}
+		aspect := float64(b.Dx()) / float64(b.Dy())
+		n := len(row) + 1
+		if (aspectSum+aspect)*target+float64(gutter*(n-1)) < float64(maxWidth) {
+			// The row with the image is still narrower than the maximum width.
+			row = append(row, i)
+			aspectSum += aspect
+			continue
+		}
+		// The row is full: close it with or without the image,
+		// whichever gives the height closer to the target.
+		with := rowHeight(n, aspectSum+aspect)
+		if len(row) == 0 || math.Abs(with-target) <= math.Abs(rowHeight(len(row), aspectSum)-target) {
+			row = append(row, i)
+			aspectSum += aspect
+			place(with)
+			continue
+		}
+		place(rowHeight(len(row), aspectSum))
+		row = append(row, i)
+		aspectSum = aspect
+	}
+	if len(row) > 0 {
+		place(math.Min(target, rowHeight(len(row), aspectSum)))
+	}
+	return rects
+}
+
+// RenderLayout draws the images into the rectangles computed by JustifiedLayout on
+// a background of the specified color. Each image is scaled and cropped to fill its
+// rectangle using the specified resampling filter. The size of the result is the size
+// of the bounding box of the rectangles.
+func RenderLayout(images []image.Image, rects []image.Rectangle, background color.Color, filter ResampleFilter) *image.NRGBA {
+	var size image.Point
+	for _, r := range rects {
+		if r.Max.X > size.X {
+			size.X = r.Max.X
+		}
+		if r.Max.Y > size.Y {
+			size.Y = r.Max.Y
+		}
+	}
+	dst := New(size.X, size.Y, background)
+	for i, r := range rects {
+		if i >= len(images) || r.Empty() {
+			continue
+		}
+		tile := Fill(images[i], r.Dx(), r.Dy(), Center, filter)
+		draw.Draw(dst, r, tile, image.Point{}, draw.Over)
+	}
+	return dst
+}
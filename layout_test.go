@@ -0,0 +1,91 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestJustifiedLayout(t *testing.T) {
+	red := color.NRGBA{0xff, 0, 0, 0xff}
+	images := []image.Image{
+		New(300, 200, red),
+		New(200, 200, red),
+		&image.NRGBA{},
+		New(400, 200, red),
+		New(200, 100, red),
+	}
+	testCases := []struct {
+		name   string
+		images []image.Image
+		want   []image.Rectangle
+	}{
+		{
+			"last row is not stretched",
+			images[:4],
+			[]image.Rectangle{
+				image.Rect(0, 0, 150, 100),
+				image.Rect(160, 0, 260, 100),
+				{},
+				image.Rect(270, 0, 470, 100),
+			},
+		},
+		{
+			"full row is justified",
+			images,
+			[]image.Rectangle{
+				image.Rect(0, 0, 160, 107),
+				image.Rect(170, 0, 277, 107),
+				{},
+				image.Rect(287, 0, 500, 107),
+				image.Rect(0, 117, 200, 217),
+			},
+		},
+		{
+			"wide image",
+			[]image.Image{New(2000, 100, red)},
+			[]image.Rectangle{image.Rect(0, 0, 500, 25)},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := JustifiedLayout(tc.images, 100, 500, 10)
+			if len(got) != len(tc.want) {
+				t.Fatalf("got %d rectangles want %d", len(got), len(tc.want))
+			}
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Fatalf("image %d: got %v want %v", i, got[i], tc.want[i])
+				}
+			}
+		})
+	}
+
+	if got := JustifiedLayout(images, 0, 500, 10); got != nil {
+		t.Fatalf("got %v for invalid parameters", got)
+	}
+}
+
+func TestRenderLayout(t *testing.T) {
+	images := []image.Image{
+		New(40, 20, color.NRGBA{0xff, 0, 0, 0xff}),
+		New(20, 20, color.NRGBA{0, 0xff, 0, 0xff}),
+	}
+	rects := JustifiedLayout(images, 10, 34, 4)
+	got := RenderLayout(images, rects, color.White, Box)
+	if got.Rect != image.Rect(0, 0, 34, 10) {
+		t.Fatalf("got bounds %v", got.Rect)
+	}
+	for _, tc := range []struct {
+		x    int
+		want color.NRGBA
+	}{
+		{0, color.NRGBA{0xff, 0, 0, 0xff}},
+		{21, color.NRGBA{0xff, 0xff, 0xff, 0xff}},
+		{33, color.NRGBA{0, 0xff, 0, 0xff}},
+	} {
+		if c := got.NRGBAAt(tc.x, 5); c != tc.want {
+			t.Fatalf("pixel at x=%d: got %v want %v", tc.x, c, tc.want)
+		}
+	}
+}
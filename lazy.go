@@ -0,0 +1,241 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"io"
+	"io/ioutil"
+	"math"
+	"reflect"
+	"sync"
+)
+
+type lazyOpKind int
+
+const (
+	lazyCrop lazyOpKind = iota
+	lazyResize
+	lazyFit
+)
+
+type lazyOp struct {
+	kind          lazyOpKind
+	rect          image.Rectangle
+	width, height int
+	filter        ResampleFilter
+}
+
+// LazyImage is a handle to an image that is not decoded yet. The operations called on
+// it are only recorded and executed when the image is rendered. Knowing the whole chain
+// of operations in advance allows to skip the work that doesn't affect the result:
+// a leading crop reads only the needed region of the source (see DecodeRegion),
+// consecutive crops are merged into one and consecutive resizes with the same filter
+// are done in a single pass.
+//
+// LazyImage values are immutable, each operation returns a new handle.
+type LazyImage struct {
+	open func() (io.Reader, func() error, error)
+	opts []DecodeOption
+	ops  []lazyOp
+}
+
+// Lazy returns a handle to the image in the given file. The file is not read
+// until the image is rendered.
+//
+// Example:
+//
+//	err := imaging.Lazy("scan.tif").
+//		Crop(image.Rect(4000, 4000, 8000, 8000)).
+//		Resize(800, 0, imaging.Lanczos).
+//		Save("out.jpg")
+//
+func Lazy(filename string, opts ...DecodeOption) *LazyImage {
+	return &LazyImage{
+		open: func() (io.Reader, func() error, error) {
+			file, err := fs.Open(filename)
+			if err != nil {
+				return nil, nil, err
+			}
+			return file, file.Close, nil
+		},
+		opts: opts,
+	}
+}
+
+// LazyReader returns a handle to the image read from r. If r implements io.ReaderAt and
+// its size is known, as for *os.File and *bytes.Reader, the image is read from the beginning
+// at the needed offsets on each render, so only the needed region of the image may be read.
+// Otherwise the data is read into memory on the first render, up to the MaxBytes limit
+// of the DecodeLimits option, and reused by the later renders.
+func LazyReader(r io.Reader, opts ...DecodeOption) *LazyImage {
+	noClose := func() error { return nil }
+	if ra, ok := r.(io.ReaderAt); ok {
+		if size := readerAtSize(ra); size >= 0 {
+			return &LazyImage{
+				open: func() (io.Reader, func() error, error) {
+					return io.NewSectionReader(ra, 0, size), noClose, nil
+				},
+				opts: opts,
+			}
+		}
+	}
+
+	var (
+		once sync.Once
+		data []byte
+		err  error
+	)
+	return &LazyImage{
+		open: func() (io.Reader, func() error, error) {
+			once.Do(func() {
+				data, err = readLazySource(r, opts)
+			})
+			if err != nil {
+				return nil, nil, err
+			}
+			return bytes.NewReader(data), noClose, nil
+		},
+		opts: opts,
+	}
+}
+
+// readLazySource reads all the data of r, up to the MaxBytes limit of the options.
+func readLazySource(r io.Reader, opts []DecodeOption) ([]byte, error) {
+	cfg := defaultDecodeConfig
+	for _, option := range opts {
+		option(&cfg)
+	}
+	if cfg.limits != nil && cfg.limits.MaxBytes > 0 {
+		r = io.LimitReader(r, cfg.limits.MaxBytes+1)
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.limits != nil && cfg.limits.MaxBytes > 0 && int64(len(data)) > cfg.limits.MaxBytes {
+		return nil, ErrLimitExceeded
+	}
+	return data, nil
+}
+
+func (l *LazyImage) with(op lazyOp) *LazyImage {
+	ops := make([]lazyOp, len(l.ops), len(l.ops)+1)
+	copy(ops, l.ops)
+	return &LazyImage{open: l.open, opts: l.opts, ops: append(ops, op)}
+}
+
+// Crop records a Crop operation.
+func (l *LazyImage) Crop(rect image.Rectangle) *LazyImage {
+	return l.with(lazyOp{kind: lazyCrop, rect: rect})
+}
+
+// Resize records a Resize operation.
+func (l *LazyImage) Resize(width, height int, filter ResampleFilter) *LazyImage {
+	return l.with(lazyOp{kind: lazyResize, width: width, height: height, filter: filter})
+}
+
+// Fit records a Fit operation.
+func (l *LazyImage) Fit(width, height int, filter ResampleFilter) *LazyImage {
+	return l.with(lazyOp{kind: lazyFit, width: width, height: height, filter: filter})
+}
+
+// Render decodes the image and executes the recorded operations.
+func (l *LazyImage) Render() (*image.NRGBA, error) {
+	r, closeFn, err := l.open()
+	if err != nil {
+		return nil, err
+	}
+	defer closeFn()
+
+	cfg := defaultDecodeConfig
+	for _, option := range l.opts {
+		option(&cfg)
+	}
+
+	ops := l.ops
+	var img image.Image
+	ra, ok := r.(io.ReaderAt)
+	if ok && !cfg.autoOrientation && len(ops) > 0 && ops[0].kind == lazyCrop {
+		// Without auto-orientation the crop is done in the source coordinates,
+		// so only the needed region has to be decoded.
+		var rect image.Rectangle
+		rect, ops = mergeCrops(ops)
+		img, err = DecodeRegion(ra, rect, l.opts...)
+	} else {
+		img, err = Decode(r, l.opts...)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for len(ops) > 0 {
+		switch ops[0].kind {
+		case lazyCrop:
+			var rect image.Rectangle
+			rect, ops = mergeCrops(ops)
+			img = Crop(img, rect)
+		default:
+			var w, h int
+			var filter ResampleFilter
+			w, h, filter, ops = mergeResizes(img.Bounds().Dx(), img.Bounds().Dy(), ops)
+			img = Resize(img, w, h, filter)
+		}
+	}
+
+	return toNRGBA(img), nil
+}
+
+// Save renders the image and saves it to the file with the specified filename.
+func (l *LazyImage) Save(filename string, opts ...EncodeOption) error {
+	img, err := l.Render()
+	if err != nil {
+		return err
+	}
+	return Save(img, filename, opts...)
+}
+
+// mergeCrops merges the leading crop operations into a single rectangle relative
+// to the image they are applied to. It returns the rectangle and the remaining operations.
+func mergeCrops(ops []lazyOp) (image.Rectangle, []lazyOp) {
+	// Decoded and cropped images have the origin at (0, 0), so the parts of
+	// the rectangles with negative coordinates are always cut off.
+	bounds := image.Rect(0, 0, math.MaxInt32, math.MaxInt32)
+	rect := ops[0].rect.Intersect(bounds)
+	ops = ops[1:]
+	for len(ops) > 0 && ops[0].kind == lazyCrop {
+		rect = ops[0].rect.Intersect(bounds).Add(rect.Min).Intersect(rect)
+		ops = ops[1:]
+	}
+	return rect, ops
+}
+
+// mergeResizes merges the leading resize and fit operations with the same filter into
+// a single resize of an image with the given size. It returns the final size, the filter
+// and the remaining operations.
+func mergeResizes(w, h int, ops []lazyOp) (int, int, ResampleFilter, []lazyOp) {
+	filter := ops[0].filter
+	for len(ops) > 0 && ops[0].kind != lazyCrop && sameFilter(ops[0].filter, filter) {
+		op := ops[0]
+		ops = ops[1:]
+		if w <= 0 || h <= 0 {
+			continue
+		}
+
+		switch op.kind {
+		case lazyResize:
+			if op.width < 0 || op.height < 0 || (op.width == 0 && op.height == 0) {
+				w, h = 0, 0
+				continue
+			}
+			w, h = resizeSize(w, h, op.width, op.height)
+		case lazyFit:
+			w, h = fitSize(w, h, op.width, op.height)
+		}
+	}
+	return w, h, filter, ops
+}
+
+// sameFilter reports whether the filters have the same support and kernel function.
+func sameFilter(a, b ResampleFilter) bool {
+	return a.Support == b.Support && reflect.ValueOf(a.Kernel).Pointer() == reflect.ValueOf(b.Kernel).Pointer()
+}
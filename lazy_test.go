@@ -0,0 +1,224 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLazy(t *testing.T) {
+	src := Clone(testdataFlowersSmallPNG)
+	buf := &bytes.Buffer{}
+	if err := Encode(buf, src, TIFF); err != nil {
+		t.Fatalf("failed to encode image: %v", err)
+	}
+
+	testCases := []struct {
+		name string
+		lazy func(l *LazyImage) *LazyImage
+		want *image.NRGBA
+	}{
+		{
+			"no operations",
+			func(l *LazyImage) *LazyImage { return l },
+			src,
+		},
+		{
+			"crop crop",
+			func(l *LazyImage) *LazyImage {
+				return l.Crop(image.Rect(-10, 10, 60, 70)).Crop(image.Rect(5, 5, 40, 100))
+			},
+			Crop(Crop(src, image.Rect(-10, 10, 60, 70)), image.Rect(5, 5, 40, 100)),
+		},
+		{
+			"crop resize crop",
+			func(l *LazyImage) *LazyImage {
+				return l.Crop(image.Rect(10, 10, 60, 70)).Resize(25, 0, Box).Crop(image.Rect(1, 1, 20, 20))
+			},
+			Crop(Resize(Crop(src, image.Rect(10, 10, 60, 70)), 25, 0, Box), image.Rect(1, 1, 20, 20)),
+		},
+		{
+			"resize fit",
+			func(l *LazyImage) *LazyImage {
+				return l.Resize(1000, 0, Linear).Fit(40, 40, Linear)
+			},
+			Resize(src, 40, 26, Linear),
+		},
+		{
+			"resize fit different filters",
+			func(l *LazyImage) *LazyImage {
+				return l.Resize(50, 0, Box).Fit(40, 40, Linear)
+			},
+			Fit(Resize(src, 50, 0, Box), 40, 40, Linear),
+		},
+		{
+			"resize empty",
+			func(l *LazyImage) *LazyImage {
+				return l.Resize(0, 0, Box).Resize(10, 10, Box)
+			},
+			&image.NRGBA{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.lazy(LazyReader(bytes.NewReader(buf.Bytes()))).Render()
+			if err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+			if !compareNRGBA(got, tc.want, 0) {
+				t.Fatalf("got result %#v want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+// addTIFFICCProfile returns the little-endian TIFF data with the ICC profile tag added.
+// The new IFD and the profile are appended to the data.
+func addTIFFICCProfile(data, profile []byte) []byte {
+	bo := binary.LittleEndian
+	ifd := int(bo.Uint32(data[4:]))
+	n := int(bo.Uint16(data[ifd:]))
+	newIFD := len(data)
+	out := make([]byte, newIFD+2+(n+1)*12+4+len(profile))
+	copy(out, data)
+	bo.PutUint32(out[4:], uint32(newIFD))
+	bo.PutUint16(out[newIFD:], uint16(n+1))
+	copy(out[newIFD+2:], data[ifd+2:ifd+2+n*12])
+	e := out[newIFD+2+n*12:]
+	bo.PutUint16(e[0:], tiffTagICCProfile)
+	bo.PutUint16(e[2:], 7) // UNDEFINED
+	bo.PutUint32(e[4:], uint32(len(profile)))
+	bo.PutUint32(e[8:], uint32(len(out)-len(profile)))
+	// The next IFD offset stays 0.
+	copy(out[len(out)-len(profile):], profile)
+	return out
+}
+
+func TestLazyCropColorProfile(t *testing.T) {
+	src := Clone(testdataFlowersSmallPNG)
+	rect := image.Rect(10, 5, 50, 40)
+
+	png := &bytes.Buffer{}
+	if err := Encode(png, src, PNG, ICCProfile(displayP3ICCProfile)); err != nil {
+		t.Fatalf("failed to encode image: %v", err)
+	}
+	tiff := &bytes.Buffer{}
+	if err := Encode(tiff, src, TIFF); err != nil {
+		t.Fatalf("failed to encode image: %v", err)
+	}
+
+	for name, data := range map[string][]byte{
+		"png":  png.Bytes(),
+		"tiff": addTIFFICCProfile(tiff.Bytes(), displayP3ICCProfile),
+	} {
+		full, err := Decode(bytes.NewReader(data), ColorProfileConversion(true))
+		if err != nil {
+			t.Fatalf("%s: Decode: %v", name, err)
+		}
+		want := Crop(full, rect)
+		if compareNRGBA(want, Crop(src, rect), 0) {
+			t.Fatalf("%s: the profile isn't applied by Decode", name)
+		}
+		got, err := LazyReader(bytes.NewReader(data), ColorProfileConversion(true)).Crop(rect).Render()
+		if err != nil {
+			t.Fatalf("%s: Render: %v", name, err)
+		}
+		if !compareNRGBA(got, want, 0) {
+			t.Fatalf("%s: lazy crop differs from the converted image", name)
+		}
+		region, err := DecodeRegion(bytes.NewReader(data), rect, ColorProfileConversion(true))
+		if err != nil {
+			t.Fatalf("%s: DecodeRegion: %v", name, err)
+		}
+		if !compareNRGBA(region, want, 0) {
+			t.Fatalf("%s: region differs from the converted image", name)
+		}
+	}
+}
+
+func TestLazyImmutable(t *testing.T) {
+	base := Lazy("testdata/flowers_small.png")
+	small := base.Resize(10, 0, Box)
+	large := base.Resize(20, 0, Box)
+
+	for _, tc := range []struct {
+		l    *LazyImage
+		want int
+	}{{small, 10}, {large, 20}} {
+		img, err := tc.l.Render()
+		if err != nil {
+			t.Fatalf("Render: %v", err)
+		}
+		if img.Rect.Dx() != tc.want {
+			t.Fatalf("got width %d want %d", img.Rect.Dx(), tc.want)
+		}
+	}
+}
+
+func TestLazyReaderRenderTwice(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := Encode(buf, testdataFlowersSmallPNG, PNG); err != nil {
+		t.Fatalf("failed to encode image: %v", err)
+	}
+	want := Resize(testdataFlowersSmallPNG, 20, 0, Box)
+
+	// A plain io.Reader is read once and buffered.
+	l := LazyReader(struct{ io.Reader }{bytes.NewReader(buf.Bytes())}).Resize(20, 0, Box)
+	for i := 0; i < 2; i++ {
+		got, err := l.Render()
+		if err != nil {
+			t.Fatalf("Render %d: %v", i, err)
+		}
+		if !compareNRGBA(got, want, 0) {
+			t.Fatalf("Render %d: got a different result", i)
+		}
+	}
+
+	// An io.ReaderAt is read from the beginning each time.
+	r := bytes.NewReader(buf.Bytes())
+	l = LazyReader(r).Resize(20, 0, Box)
+	for i := 0; i < 2; i++ {
+		if got, err := l.Render(); err != nil || !compareNRGBA(got, want, 0) {
+			t.Fatalf("Render %d: got a different result, error %v", i, err)
+		}
+	}
+
+	limited := LazyReader(struct{ io.Reader }{bytes.NewReader(buf.Bytes())}, DecodeLimits(Limits{MaxBytes: 100}))
+	if _, err := limited.Render(); err != ErrLimitExceeded {
+		t.Fatalf("got error %v want ErrLimitExceeded", err)
+	}
+}
+
+func TestLazySave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imaging")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "out.png")
+	err = Lazy("testdata/flowers_small.png").Crop(image.Rect(0, 0, 10, 10)).Save(filename)
+	if err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	img, err := Open(filename)
+	if err != nil {
+		t.Fatalf("failed to open image: %v", err)
+	}
+	want := Crop(testdataFlowersSmallPNG, image.Rect(0, 0, 10, 10))
+	if !compareNRGBA(Clone(img), want, 0) {
+		t.Fatalf("saved image differs from the expected result")
+	}
+
+	if err := Lazy(filepath.Join(dir, "missing.png")).Save(filename); err == nil {
+		t.Fatalf("expected error got nil")
+	}
+	if _, err := LazyReader(bytes.NewReader([]byte("bad data"))).Render(); err == nil {
+		t.Fatalf("expected error got nil")
+	}
+}
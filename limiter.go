@@ -0,0 +1,114 @@
+package imaging
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Limiter limits the rate of image processing, measured in megapixels per second,
+// using a token bucket. A Limiter shared by several Processors makes them share
+// the processing capacity, e.g. between the tenants of a service.
+// A Limiter is safe for concurrent use.
+type Limiter struct {
+	mu     sync.Mutex
+	rate   float64 // Pixels per second.
+	burst  float64 // Bucket size in pixels.
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+// NewLimiter returns a Limiter that allows processing of megapixelsPerSecond megapixels
+// per second on average, with bursts of up to burstMegapixels megapixels. Operations larger
+// than the burst are allowed, but the following operations wait until the debt is repaid.
+//
+// Example:
+//
+//	// Each tenant gets 50 MP/s.
+//	limiter := imaging.NewLimiter(50, 100)
+//	p := imaging.NewProcessor(nil).WithLimiter(limiter)
+//	dstImage := p.Resize(srcImage, 800, 0, imaging.Lanczos)
+//
+func NewLimiter(megapixelsPerSecond, burstMegapixels float64) *Limiter {
+	l := &Limiter{
+		rate:  megapixelsPerSecond * 1e6,
+		burst: burstMegapixels * 1e6,
+		now:   time.Now,
+	}
+	l.tokens = l.burst
+	l.last = l.now()
+	return l
+}
+
+// Wait blocks until processing of the given number of pixels is allowed or ctx is done.
+// It returns the context error in the latter case.
+func (l *Limiter) Wait(ctx context.Context, pixels int) error {
+	d := l.reserve(pixels)
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		l.tokens += float64(pixels)
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// reserve takes the tokens for the given number of pixels and returns
+// how long the caller has to wait before the processing.
+func (l *Limiter) reserve(pixels int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rate <= 0 {
+		return 0
+	}
+	now := l.now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	l.tokens -= float64(pixels)
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// ProcessorStats holds the processing statistics of a Processor.
+type ProcessorStats struct {
+	Operations int64         // Number of operations run.
+	Pixels     int64         // Number of pixels processed (the larger of the source and result sizes).
+	Busy       time.Duration // Total time spent in the operations, excluding the waiting.
+	Throttled  time.Duration // Total time spent waiting for the Limiter.
+	Panics     int64         // Number of panics recovered (see Untrusted).
+}
+
+// processorStats is the concurrent-safe counterpart of ProcessorStats.
+type processorStats struct {
+	operations int64
+	pixels     int64
+	busy       int64
+	throttled  int64
+	panics     int64
+}
+
+func (s *processorStats) snapshot() ProcessorStats {
+	return ProcessorStats{
+		Operations: atomic.LoadInt64(&s.operations),
+		Pixels:     atomic.LoadInt64(&s.pixels),
+		Busy:       time.Duration(atomic.LoadInt64(&s.busy)),
+		Throttled:  time.Duration(atomic.LoadInt64(&s.throttled)),
+		Panics:     atomic.LoadInt64(&s.panics),
+	}
+}
@@ -0,0 +1,83 @@
+package imaging
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLimiterReserve(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := NewLimiter(1, 2) // 1 MP/s, 2 MP burst.
+	l.now = func() time.Time { return now }
+	l.last = now
+
+	steps := []struct {
+		advance time.Duration
+		pixels  int
+		want    time.Duration
+	}{
+		{0, 1500000, 0},
+		{0, 500000, 0},
+		{0, 250000, 250 * time.Millisecond},
+		{time.Second, 500000, 0},
+		{10 * time.Second, 5000000, 3 * time.Second},
+		{0, 0, 3 * time.Second},
+	}
+	for i, s := range steps {
+		now = now.Add(s.advance)
+		if got := l.reserve(s.pixels); got != s.want {
+			t.Fatalf("step %d: got wait %v want %v", i, got, s.want)
+		}
+	}
+
+	unlimited := NewLimiter(0, 0)
+	if got := unlimited.reserve(1e9); got != 0 {
+		t.Fatalf("got wait %v for unlimited limiter", got)
+	}
+}
+
+func TestLimiterWait(t *testing.T) {
+	l := NewLimiter(0.001, 0) // 1000 pixels per second.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.Wait(ctx, 1000000); err != context.Canceled {
+		t.Fatalf("got error %v want context.Canceled", err)
+	}
+	// The tokens of the canceled wait are returned.
+	if l.tokens < -1 {
+		t.Fatalf("got %v tokens after canceled wait", l.tokens)
+	}
+	if err := l.Wait(context.Background(), 5); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+}
+
+func TestProcessorStats(t *testing.T) {
+	p := NewProcessor(nil)
+	p.Resize(testdataBranchesPNG, 100, 0, Box)
+	p.ColorMatrix(testdataFlowersSmallPNG, [20]float64{})
+	p.Resize(testdataBranchesPNG, 600, 400, Box) // A copy, counted as well.
+
+	got := p.Stats()
+	if got.Operations != 3 {
+		t.Fatalf("got %d operations want 3", got.Operations)
+	}
+	if want := int64(2*600*400 + 240*160); got.Pixels != want {
+		t.Fatalf("got %d pixels want %d", got.Pixels, want)
+	}
+	if got.Busy <= 0 || got.Throttled != 0 {
+		t.Fatalf("got busy %v throttled %v", got.Busy, got.Throttled)
+	}
+
+	// 600x400 pixels at 10 MP/s with no burst take 24ms.
+	limited := p.WithLimiter(NewLimiter(10, 0))
+	if limited.Stats() != (ProcessorStats{}) {
+		t.Fatalf("new processor has non-empty stats")
+	}
+	limited.Resize(testdataBranchesPNG, 100, 0, Box)
+	limited.Resize(testdataBranchesPNG, 100, 0, Box)
+	if got := limited.Stats(); got.Throttled < 20*time.Millisecond {
+		t.Fatalf("got throttled time %v want at least 20ms", got.Throttled)
+	}
+}
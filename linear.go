@@ -0,0 +1,174 @@
+package imaging
+
+import (
+	"image"
+	"math"
+	"sync"
+)
+
+var (
+	srgbToLinearLUT [256]uint16
+
+	linearToSRGBOnce sync.Once
+	linearToSRGBLUT  []uint8
+)
+
+func init() {
+	for i := range srgbToLinearLUT {
+		srgbToLinearLUT[i] = uint16(srgbToLinear(float64(i)/255)*65535 + 0.5)
+	}
+}
+
+// srgbToLinear converts an sRGB-encoded value in range [0, 1] to linear light.
+func srgbToLinear(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+// linearToSRGB converts a linear light value in range [0, 1] to sRGB encoding.
+func linearToSRGB(v float64) float64 {
+	if v <= 0.0031308 {
+		return v * 12.92
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
+
+// linearToSRGBTable returns the lookup table that maps 16-bit linear values to 8-bit sRGB values.
+func linearToSRGBTable() []uint8 {
+	linearToSRGBOnce.Do(func() {
+		linearToSRGBLUT = make([]uint8, 65536)
+		for i := range linearToSRGBLUT {
+			linearToSRGBLUT[i] = clamp(linearToSRGB(float64(i)/65535) * 255)
+		}
+	})
+	return linearToSRGBLUT
+}
+
+// clamp16 rounds and clamps float64 value to fit into uint16.
+func clamp16(x float64) uint16 {
+	v := int64(x + 0.5)
+	if v > 65535 {
+		return 65535
+	}
+	if v > 0 {
+		return uint16(v)
+	}
+	return 0
+}
+
+// ResizeLinear resizes the image like Resize, but the resampling is done in linear light
+// instead of the gamma-encoded sRGB color space. This avoids darkening of fine
+// high-contrast details and color shifts on edges when downscaling, at the cost of speed.
+// The image is converted to a 16-bit linear representation using a lookup table, resampled
+// and converted back to sRGB.
+//
+// Example:
+//
+//	dstImage := imaging.ResizeLinear(srcImage, 800, 0, imaging.Lanczos)
+//
+func ResizeLinear(img image.Image, width, height int, filter ResampleFilter) *image.NRGBA {
+	srcW := img.Bounds().Dx()
+	srcH := img.Bounds().Dy()
+	if width < 0 || height < 0 || (width == 0 && height == 0) || srcW <= 0 || srcH <= 0 {
+		return &image.NRGBA{}
+	}
+
+	dstW, dstH := resizeSize(srcW, srcH, width, height)
+	if (srcW == dstW && srcH == dstH) || filter.Support <= 0 {
+		// Nearest-neighbor resampling and copying are not affected by the gamma.
+		return Resize(img, dstW, dstH, filter)
+	}
+
+	buf := linearize(img)
+	if srcW != dstW {
+		buf = resizeLinearHorizontal(buf, srcW, srcH, dstW, filter)
+	}
+	return resizeLinearVertical(buf, dstW, srcH, dstH, filter)
+}
+
+// linearize converts the image into a slice of 16-bit linear light RGBA values.
+func linearize(img image.Image) []uint16 {
+	src := newScanner(img)
+	buf := make([]uint16, src.w*src.h*4)
+	parallel(0, src.h, src.w*costPoint, func(ys <-chan int) {
+		scanLine := make([]uint8, src.w*4)
+		for y := range ys {
+			src.scan(0, y, src.w, y+1, scanLine)
+			d := buf[y*src.w*4 : (y+1)*src.w*4]
+			for i := 0; i < len(scanLine); i += 4 {
+				d[i+0] = srgbToLinearLUT[scanLine[i+0]]
+				d[i+1] = srgbToLinearLUT[scanLine[i+1]]
+				d[i+2] = srgbToLinearLUT[scanLine[i+2]]
+				d[i+3] = uint16(scanLine[i+3]) * 0x101
+			}
+		}
+	})
+	return buf
+}
+
+func resizeLinearHorizontal(src []uint16, srcW, srcH, width int, filter ResampleFilter) []uint16 {
+	dst := make([]uint16, width*srcH*4)
+	weights := cachedWeights(width, srcW, filter)
+	parallel(0, srcH, weightsCost(weights), func(ys <-chan int) {
+		for y := range ys {
+			s0 := y * srcW * 4
+			d0 := y * width * 4
+			for x := range weights {
+				var r, g, b, a float64
+				for _, w := range weights[x] {
+					i := s0 + w.index*4
+					s := src[i : i+4 : i+4]
+					aw := float64(s[3]) * w.weight
+					r += float64(s[0]) * aw
+					g += float64(s[1]) * aw
+					b += float64(s[2]) * aw
+					a += aw
+				}
+				if a != 0 {
+					aInv := 1 / a
+					j := d0 + x*4
+					d := dst[j : j+4 : j+4]
+					d[0] = clamp16(r * aInv)
+					d[1] = clamp16(g * aInv)
+					d[2] = clamp16(b * aInv)
+					d[3] = clamp16(a)
+				}
+			}
+		}
+	})
+	return dst
+}
+
+func resizeLinearVertical(src []uint16, srcW, srcH, height int, filter ResampleFilter) *image.NRGBA {
+	dst := image.NewNRGBA(image.Rect(0, 0, srcW, height))
+	weights := cachedWeights(height, srcH, filter)
+	lut := linearToSRGBTable()
+	parallel(0, height, weightsCost(weights), func(ys <-chan int) {
+		for y := range ys {
+			for x := 0; x < srcW; x++ {
+				var r, g, b, a float64
+				for _, w := range weights[y] {
+					i := (w.index*srcW + x) * 4
+					s := src[i : i+4 : i+4]
+					aw := float64(s[3]) * w.weight
+					r += float64(s[0]) * aw
+					g += float64(s[1]) * aw
+					b += float64(s[2]) * aw
+					a += aw
+				}
+				if a != 0 {
+					aInv := 1 / a
+					j := y*dst.Stride + x*4
+					d := dst.Pix[j : j+4 : j+4]
+					d[0] = lut[clamp16(r*aInv)]
+					d[1] = lut[clamp16(g*aInv)]
+					d[2] = lut[clamp16(b*aInv)]
+					d[3] = clamp(a / 0x101)
+				}
+			}
+		}
+	})
+	return dst
+}
@@ -0,0 +1,114 @@
+package imaging
+
+import (
+	"image"
+	"testing"
+)
+
+func TestSRGBLinearRoundTrip(t *testing.T) {
+	lut := linearToSRGBTable()
+	for i := 0; i < 256; i++ {
+		got := lut[srgbToLinearLUT[i]]
+		if got != uint8(i) {
+			t.Fatalf("round trip of %d: got %d", i, got)
+		}
+	}
+}
+
+func TestResizeLinear(t *testing.T) {
+	testCases := []struct {
+		name string
+		src  image.Image
+		w, h int
+		f    ResampleFilter
+		want *image.NRGBA
+	}{
+		{
+			"ResizeLinear 2x2 1x1 box",
+			&image.NRGBA{
+				Rect:   image.Rect(-1, -1, 1, 1),
+				Stride: 2 * 4,
+				Pix: []uint8{
+					0x00, 0x00, 0x00, 0xff, 0xff, 0xff, 0xff, 0xff,
+					0xff, 0xff, 0xff, 0xff, 0x00, 0x00, 0x00, 0xff,
+				},
+			},
+			1, 1,
+			Box,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 1, 1),
+				Stride: 1 * 4,
+				Pix:    []uint8{0xbc, 0xbc, 0xbc, 0xff},
+			},
+		},
+		{
+			"ResizeLinear 2x1 1x1 transparent box",
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 1),
+				Stride: 2 * 4,
+				Pix: []uint8{
+					0x00, 0x00, 0x00, 0x00, 0xff, 0x00, 0x00, 0xff,
+				},
+			},
+			1, 0,
+			Box,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 1, 1),
+				Stride: 1 * 4,
+				Pix:    []uint8{0xff, 0x00, 0x00, 0x80},
+			},
+		},
+		{
+			"ResizeLinear 1x2 1x4 nearest",
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 1, 2),
+				Stride: 1 * 4,
+				Pix: []uint8{
+					0x00, 0x00, 0x00, 0xff,
+					0xff, 0xff, 0xff, 0xff,
+				},
+			},
+			1, 4,
+			NearestNeighbor,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 1, 4),
+				Stride: 1 * 4,
+				Pix: []uint8{
+					0x00, 0x00, 0x00, 0xff,
+					0x00, 0x00, 0x00, 0xff,
+					0xff, 0xff, 0xff, 0xff,
+					0xff, 0xff, 0xff, 0xff,
+				},
+			},
+		},
+		{
+			"ResizeLinear 2x2 0x0",
+			image.NewNRGBA(image.Rect(0, 0, 2, 2)),
+			0, 0,
+			Box,
+			&image.NRGBA{},
+		},
+		{
+			"ResizeLinear 2x2 -1x2",
+			image.NewNRGBA(image.Rect(0, 0, 2, 2)),
+			-1, 2,
+			Box,
+			&image.NRGBA{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := ResizeLinear(tc.src, tc.w, tc.h, tc.f)
+			if !compareNRGBA(got, tc.want, 0) {
+				t.Fatalf("got result %#v want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func BenchmarkResizeLinear(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ResizeLinear(testdataBranchesJPG, 100, 0, Lanczos)
+	}
+}
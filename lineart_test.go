@@ -0,0 +1,82 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestResizeLineArt(t *testing.T) {
+	// A one pixel wide black line on a gray background.
+	src := New(64, 64, color.NRGBA{0x80, 0x80, 0x80, 0xff})
+	for y := 0; y < 64; y++ {
+		src.SetNRGBA(30, y, color.NRGBA{0, 0, 0, 0xff})
+	}
+
+	got := ResizeLineArt(src, 8, 8)
+	if got.Bounds() != image.Rect(0, 0, 8, 8) {
+		t.Fatalf("got bounds %v want 8x8", got.Bounds())
+	}
+	lanczos := ResizeLinear(src, 8, 8, Lanczos)
+
+	darkest := func(img *image.NRGBA) uint8 {
+		v := uint8(0xff)
+		for x := 0; x < 8; x++ {
+			if c := img.NRGBAAt(x, 4).R; c < v {
+				v = c
+			}
+		}
+		return v
+	}
+	if darkest(got) >= darkest(lanczos) {
+		t.Fatalf("line is not preserved: darkest %#x, Lanczos %#x", darkest(got), darkest(lanczos))
+	}
+
+	// No ringing: the background must not get lighter than the source.
+	for i := 0; i < len(got.Pix); i += 4 {
+		if got.Pix[i] > 0x80 || got.Pix[i+3] != 0xff {
+			t.Fatalf("ringing at pixel %d: %v", i/4, got.Pix[i:i+4])
+		}
+	}
+}
+
+func TestResizeLineArtSizes(t *testing.T) {
+	testCases := []struct {
+		w, h int
+		want image.Rectangle
+	}{
+		{100, 0, image.Rect(0, 0, 100, 67)},
+		{1200, 0, image.Rect(0, 0, 1200, 800)},
+		{0, 0, image.Rectangle{}},
+		{-1, 10, image.Rectangle{}},
+	}
+	for _, tc := range testCases {
+		got := ResizeLineArt(testdataBranchesPNG, tc.w, tc.h)
+		if got.Bounds() != tc.want {
+			t.Fatalf("ResizeLineArt(%d, %d): got bounds %v want %v", tc.w, tc.h, got.Bounds(), tc.want)
+		}
+	}
+}
+
+func TestDilateDark(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 4, 1),
+		Stride: 4 * 4,
+		Pix: []uint8{
+			0xff, 0xff, 0xff, 0xff,
+			0x10, 0x20, 0x30, 0xff,
+			0xff, 0xff, 0xff, 0xff,
+			0x00, 0x00, 0x00, 0x00,
+		},
+	}
+	want := []uint8{
+		0x10, 0x20, 0x30, 0xff,
+		0x10, 0x20, 0x30, 0xff,
+		0x10, 0x20, 0x30, 0xff,
+		0xff, 0xff, 0xff, 0xff,
+	}
+	got := dilateDark(src, 1, 0)
+	if !compareBytes(got.Pix, want, 0) {
+		t.Fatalf("got pixels %#v want %#v", got.Pix, want)
+	}
+}
@@ -0,0 +1,244 @@
+package imaging
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidLUT means that the 3D LUT data is malformed or not supported.
+var ErrInvalidLUT = errors.New("imaging: invalid 3D LUT")
+
+// maxLUTSize is the maximum supported number of entries per LUT axis.
+const maxLUTSize = 256
+
+// LUT3D is a three-dimensional color lookup table, as used by color grading presets.
+// It maps each input RGB color to an output RGB color, the colors between the table
+// entries are interpolated.
+type LUT3D struct {
+	size      int
+	data      []float64 // RGB triples, red changes fastest, then green, then blue.
+	domainMin [3]float64
+	domainMax [3]float64
+}
+
+// Size returns the number of entries along each axis of the table.
+func (l *LUT3D) Size() int {
+	return l.size
+}
+
+// LoadCubeLUT reads a 3D LUT in the .cube format (Adobe / Resolve) from r.
+// One-dimensional .cube tables are not supported.
+//
+// Example:
+//
+//	f, err := os.Open("preset.cube")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer f.Close()
+//	lut, err := imaging.LoadCubeLUT(f)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	dstImage := imaging.ApplyLUT3D(srcImage, lut)
+//
+func LoadCubeLUT(r io.Reader) (*LUT3D, error) {
+	lut := &LUT3D{domainMax: [3]float64{1, 1, 1}}
+	s := bufio.NewScanner(r)
+	line := 0
+	for s.Scan() {
+		line++
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		switch fields[0] {
+		case "TITLE":
+			continue
+		case "LUT_1D_SIZE":
+			return nil, fmt.Errorf("%w: 1D tables are not supported", ErrInvalidLUT)
+		case "LUT_3D_SIZE":
+			if len(fields) != 2 {
+				return nil, cubeLineError(line)
+			}
+			n, err := strconv.Atoi(fields[1])
+			if err != nil || n < 2 || n > maxLUTSize {
+				return nil, cubeLineError(line)
+			}
+			lut.size = n
+			lut.data = make([]float64, 0, n*n*n*3)
+			continue
+		case "DOMAIN_MIN", "DOMAIN_MAX":
+			v, err := parseCubeTriple(fields[1:])
+			if err != nil {
+				return nil, cubeLineError(line)
+			}
+			if fields[0] == "DOMAIN_MIN" {
+				lut.domainMin = v
+			} else {
+				lut.domainMax = v
+			}
+			continue
+		}
+
+		v, err := parseCubeTriple(fields)
+		if err != nil || lut.size == 0 || len(lut.data) == cap(lut.data) {
+			return nil, cubeLineError(line)
+		}
+		lut.data = append(lut.data, v[:]...)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	if lut.size == 0 || len(lut.data) != cap(lut.data) {
+		return nil, fmt.Errorf("%w: wrong number of entries", ErrInvalidLUT)
+	}
+	for c := 0; c < 3; c++ {
+		if lut.domainMax[c] <= lut.domainMin[c] {
+			return nil, fmt.Errorf("%w: invalid domain", ErrInvalidLUT)
+		}
+	}
+	return lut, nil
+}
+
+func cubeLineError(line int) error {
+	return fmt.Errorf("%w: malformed line %d", ErrInvalidLUT, line)
+}
+
+func parseCubeTriple(fields []string) ([3]float64, error) {
+	var v [3]float64
+	if len(fields) != 3 {
+		return v, ErrInvalidLUT
+	}
+	for i, f := range fields {
+		x, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return v, err
+		}
+		v[i] = x
+	}
+	return v, nil
+}
+
+// HaldCLUT returns the 3D LUT stored in the HALD CLUT image. A HALD image of level L
+// is a square image with the side of L*L*L pixels, containing a table with L*L entries
+// per axis. Applying the color grading to the identity HALD image produces the HALD CLUT
+// of that grading.
+//
+// Example:
+//
+//	hald, err := imaging.Open("preset.png")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	lut, err := imaging.HaldCLUT(hald)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	dstImage := imaging.ApplyLUT3D(srcImage, lut)
+//
+func HaldCLUT(img image.Image) (*LUT3D, error) {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	level := 2
+	for level*level*level < w {
+		level++
+	}
+	if w != h || level*level*level != w || level*level > maxLUTSize {
+		return nil, fmt.Errorf("%w: wrong HALD image size %dx%d", ErrInvalidLUT, w, h)
+	}
+
+	size := level * level
+	lut := &LUT3D{
+		size:      size,
+		data:      make([]float64, size*size*size*3),
+		domainMax: [3]float64{1, 1, 1},
+	}
+	src := newScanner(img)
+	scanLine := make([]uint8, w*4)
+	for y := 0; y < h; y++ {
+		src.scan(0, y, w, y+1, scanLine)
+		d := lut.data[y*w*3 : (y+1)*w*3]
+		for x := 0; x < w; x++ {
+			d[x*3+0] = float64(scanLine[x*4+0]) / 255
+			d[x*3+1] = float64(scanLine[x*4+1]) / 255
+			d[x*3+2] = float64(scanLine[x*4+2]) / 255
+		}
+	}
+	return lut, nil
+}
+
+// ApplyLUT3D maps the colors of the image through the 3D LUT using trilinear interpolation
+// and returns the adjusted image. The alpha channel is not changed.
+//
+// Example:
+//
+//	dstImage := imaging.ApplyLUT3D(srcImage, lut)
+//
+func ApplyLUT3D(img image.Image, lut *LUT3D) *image.NRGBA {
+	if lut == nil || lut.size < 2 {
+		return Clone(img)
+	}
+
+	// Map 8-bit input values to the table coordinates once per channel.
+	n := lut.size
+	last := float64(n - 1)
+	var index [3][256]int
+	var frac [3][256]float64
+	for c := 0; c < 3; c++ {
+		for i := 0; i < 256; i++ {
+			v := (float64(i)/255 - lut.domainMin[c]) / (lut.domainMax[c] - lut.domainMin[c]) * last
+			if v < 0 {
+				v = 0
+			} else if v > last {
+				v = last
+			}
+			k := int(v)
+			if k == n-1 {
+				k = n - 2
+			}
+			index[c][i] = k
+			frac[c][i] = v - float64(k)
+		}
+	}
+
+	src := newScanner(img)
+	dst := image.NewNRGBA(image.Rect(0, 0, src.w, src.h))
+	parallel(0, src.h, src.w*costHeavy, func(ys <-chan int) {
+		for y := range ys {
+			i := y * dst.Stride
+			src.scan(0, y, src.w, y+1, dst.Pix[i:i+src.w*4])
+			for x := 0; x < src.w; x++ {
+				d := dst.Pix[i : i+3 : i+3]
+				r0, g0, b0 := index[0][d[0]], index[1][d[1]], index[2][d[2]]
+				fr, fg, fb := frac[0][d[0]], frac[1][d[1]], frac[2][d[2]]
+				p000 := ((b0*n+g0)*n + r0) * 3
+				p100 := p000 + 3
+				p010 := p000 + n*3
+				p110 := p010 + 3
+				p001 := p000 + n*n*3
+				p101 := p001 + 3
+				p011 := p001 + n*3
+				p111 := p011 + 3
+				t := lut.data
+				for c := 0; c < 3; c++ {
+					c00 := t[p000+c] + (t[p100+c]-t[p000+c])*fr
+					c10 := t[p010+c] + (t[p110+c]-t[p010+c])*fr
+					c01 := t[p001+c] + (t[p101+c]-t[p001+c])*fr
+					c11 := t[p011+c] + (t[p111+c]-t[p011+c])*fr
+					c0 := c00 + (c10-c00)*fg
+					c1 := c01 + (c11-c01)*fg
+					d[c] = clamp((c0 + (c1-c0)*fb) * 255)
+				}
+				i += 4
+			}
+		}
+	})
+	return dst
+}
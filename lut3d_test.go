@@ -0,0 +1,156 @@
+package imaging
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+const identityCube = `# Identity
+TITLE "identity"
+LUT_3D_SIZE 2
+
+0 0 0
+1 0 0
+0 1 0
+1 1 0
+0 0 1
+1 0 1
+0 1 1
+1 1 1
+`
+
+func TestLoadCubeLUT(t *testing.T) {
+	lut, err := LoadCubeLUT(strings.NewReader(identityCube))
+	if err != nil {
+		t.Fatalf("LoadCubeLUT: %v", err)
+	}
+	if lut.Size() != 2 {
+		t.Fatalf("got size %d want 2", lut.Size())
+	}
+
+	for _, data := range []string{
+		"",
+		"LUT_1D_SIZE 2\n0 0 0\n1 1 1\n",
+		"LUT_3D_SIZE 1\n0 0 0\n",
+		"LUT_3D_SIZE 2\n0 0 0\n",
+		"0 0 0\n",
+		strings.Replace(identityCube, "1 1 1", "1 1", 1),
+		strings.Replace(identityCube, "1 1 1", "1 1 x", 1),
+		identityCube + "0 0 0\n",
+		"DOMAIN_MIN 1 1 1\n" + identityCube,
+	} {
+		_, err := LoadCubeLUT(strings.NewReader(data))
+		if !errors.Is(err, ErrInvalidLUT) {
+			t.Fatalf("LoadCubeLUT(%q): got error %v want ErrInvalidLUT", data, err)
+		}
+	}
+}
+
+func TestApplyLUT3D(t *testing.T) {
+	identity, err := LoadCubeLUT(strings.NewReader(identityCube))
+	if err != nil {
+		t.Fatalf("LoadCubeLUT: %v", err)
+	}
+	got := ApplyLUT3D(testdataFlowersSmallPNG, identity)
+	if !compareNRGBA(got, toNRGBA(testdataFlowersSmallPNG), 1) {
+		t.Fatalf("identity LUT changed the image")
+	}
+
+	invert := "LUT_3D_SIZE 2\n" +
+		"1 1 1\n0 1 1\n1 0 1\n0 0 1\n1 1 0\n0 1 0\n1 0 0\n0 0 0\n"
+	lut, err := LoadCubeLUT(strings.NewReader(invert))
+	if err != nil {
+		t.Fatalf("LoadCubeLUT: %v", err)
+	}
+	got = ApplyLUT3D(testdataFlowersSmallPNG, lut)
+	if !compareNRGBA(got, Invert(testdataFlowersSmallPNG), 1) {
+		t.Fatalf("inverting LUT result differs from Invert")
+	}
+
+	// The domain is [0, 0.5], the brighter input values are clipped.
+	domain := "DOMAIN_MAX 0.5 0.5 0.5\n" + identityCube
+	lut, err = LoadCubeLUT(strings.NewReader(domain))
+	if err != nil {
+		t.Fatalf("LoadCubeLUT: %v", err)
+	}
+	src := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 2, 1),
+		Stride: 2 * 4,
+		Pix:    []uint8{0x40, 0x00, 0xff, 0x80, 0x20, 0x80, 0x00, 0xff},
+	}
+	want := []uint8{0x80, 0x00, 0xff, 0x80, 0x40, 0xff, 0x00, 0xff}
+	got = ApplyLUT3D(src, lut)
+	if !compareBytes(got.Pix, want, 1) {
+		t.Fatalf("got pixels %#v want %#v", got.Pix, want)
+	}
+
+	got = ApplyLUT3D(src, nil)
+	if !compareNRGBA(got, src, 0) {
+		t.Fatalf("nil LUT changed the image")
+	}
+}
+
+func TestHaldCLUT(t *testing.T) {
+	// Identity HALD image of level 2: 8x8 pixels, 4 entries per axis.
+	hald := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	for i := 0; i < 64; i++ {
+		hald.SetNRGBA(i%8, i/8, color.NRGBA{
+			R: uint8(i % 4 * 85),
+			G: uint8(i / 4 % 4 * 85),
+			B: uint8(i / 16 * 85),
+			A: 0xff,
+		})
+	}
+	lut, err := HaldCLUT(hald)
+	if err != nil {
+		t.Fatalf("HaldCLUT: %v", err)
+	}
+	if lut.Size() != 4 {
+		t.Fatalf("got size %d want 4", lut.Size())
+	}
+	got := ApplyLUT3D(testdataFlowersSmallPNG, lut)
+	if !compareNRGBA(got, toNRGBA(testdataFlowersSmallPNG), 1) {
+		t.Fatalf("identity HALD CLUT changed the image")
+	}
+
+	// Swap the color channels: linear gradings are reproduced exactly.
+	swap := [20]float64{
+		0, 1, 0, 0, 0,
+		0, 0, 1, 0, 0,
+		1, 0, 0, 0, 0,
+		0, 0, 0, 1, 0,
+	}
+	lut, err = HaldCLUT(ColorMatrix(hald, swap))
+	if err != nil {
+		t.Fatalf("HaldCLUT: %v", err)
+	}
+	got = ApplyLUT3D(testdataFlowersSmallPNG, lut)
+	if !compareNRGBA(got, ColorMatrix(testdataFlowersSmallPNG, swap), 1) {
+		t.Fatalf("HALD CLUT result differs from the grading")
+	}
+
+	for _, r := range []image.Rectangle{
+		image.Rect(0, 0, 8, 7),
+		image.Rect(0, 0, 9, 9),
+		image.Rect(0, 0, 1, 1),
+		image.Rect(0, 0, 0, 0),
+	} {
+		if _, err := HaldCLUT(image.NewNRGBA(r)); !errors.Is(err, ErrInvalidLUT) {
+			t.Fatalf("HaldCLUT(%v): got error %v want ErrInvalidLUT", r, err)
+		}
+	}
+}
+
+func BenchmarkApplyLUT3D(b *testing.B) {
+	lut, err := LoadCubeLUT(strings.NewReader(identityCube))
+	if err != nil {
+		b.Fatalf("LoadCubeLUT: %v", err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ApplyLUT3D(testdataBranchesJPG, lut)
+	}
+}
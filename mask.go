@@ -0,0 +1,165 @@
+package imaging
+
+import (
+	"image"
+	"math"
+)
+
+// maskScanner reads the values of a grayscale mask image. Pixels outside the mask
+// bounds have the value of 0.
+type maskScanner struct {
+	src  *scanner
+	line []uint8
+}
+
+func newMaskScanner(mask image.Image) *maskScanner {
+	src := newScanner(mask)
+	return &maskScanner{src: src, line: make([]uint8, src.w*4)}
+}
+
+// scan reads the mask values in range [0, 255] for the pixels x1..x2 of the row y into dst.
+// The value of a mask pixel is its luminance multiplied by its alpha.
+func (m *maskScanner) scan(x1, x2, y int, dst []uint8) {
+	for i := range dst {
+		dst[i] = 0
+	}
+	if y < 0 || y >= m.src.h {
+		return
+	}
+	lo, hi := x1, x2
+	if lo < 0 {
+		lo = 0
+	}
+	if hi > m.src.w {
+		hi = m.src.w
+	}
+	if lo >= hi {
+		return
+	}
+	line := m.line[:(hi-lo)*4]
+	m.src.scan(lo, y, hi, y+1, line)
+	for x := lo; x < hi; x++ {
+		s := line[(x-lo)*4 : (x-lo)*4+4 : (x-lo)*4+4]
+		lum := (19595*uint32(s[0]) + 38470*uint32(s[1]) + 7471*uint32(s[2]) + 1<<15) >> 16
+		dst[x-x1] = uint8((lum*uint32(s[3]) + 127) / 255)
+	}
+}
+
+// ApplyMask uses the grayscale mask image as the alpha channel of the img image and returns
+// the result: the alpha of each pixel is multiplied by the luminance of the corresponding mask
+// pixel. Black mask pixels make the image transparent and white ones keep it unchanged.
+// The mask is aligned with the top-left corner of the image, the pixels not covered by
+// the mask become transparent.
+//
+// Example:
+//
+//	// Make a circular avatar with soft edges.
+//	mask := imaging.Blur(imaging.RoundedRectMask(256, 256, 128), 1.5)
+//	avatar := imaging.ApplyMask(imaging.Fill(photo, 256, 256, imaging.Center, imaging.Lanczos), mask)
+//
+func ApplyMask(img, mask image.Image) *image.NRGBA {
+	dst := Clone(img)
+	w, h := dst.Bounds().Dx(), dst.Bounds().Dy()
+	parallel(0, h, w*costPoint, func(ys <-chan int) {
+		m := newMaskScanner(mask)
+		values := make([]uint8, w)
+		for y := range ys {
+			m.scan(0, w, y, values)
+			i := y*dst.Stride + 3
+			for _, v := range values {
+				dst.Pix[i] = uint8((uint32(dst.Pix[i])*uint32(v) + 127) / 255)
+				i += 4
+			}
+		}
+	})
+	return dst
+}
+
+// PasteWithMask draws the img image over the background image at the specified position
+// using the grayscale mask image as a per-pixel opacity and returns the combined image.
+// The mask is aligned with the top-left corner of the img image, the pixels not covered
+// by the mask are not drawn.
+//
+// Example:
+//
+//	// Paste the logo with rounded corners.
+//	mask := imaging.RoundedRectMask(logo.Bounds().Dx(), logo.Bounds().Dy(), 12)
+//	dstImage := imaging.PasteWithMask(backgroundImage, logo, mask, image.Pt(20, 20))
+//
+func PasteWithMask(background, img, mask image.Image, pos image.Point) *image.NRGBA {
+	dst := Clone(background)
+	pos = pos.Sub(background.Bounds().Min)
+	pasteRect := image.Rectangle{Min: pos, Max: pos.Add(img.Bounds().Size())}
+	interRect := pasteRect.Intersect(dst.Bounds())
+	if interRect.Empty() {
+		return dst
+	}
+	src := newScanner(img)
+	parallel(interRect.Min.Y, interRect.Max.Y, interRect.Dx()*costPoint, func(ys <-chan int) {
+		m := newMaskScanner(mask)
+		scanLine := make([]uint8, interRect.Dx()*4)
+		values := make([]uint8, interRect.Dx())
+		for y := range ys {
+			x1 := interRect.Min.X - pasteRect.Min.X
+			x2 := interRect.Max.X - pasteRect.Min.X
+			y1 := y - pasteRect.Min.Y
+			src.scan(x1, y1, x2, y1+1, scanLine)
+			m.scan(x1, x2, y1, values)
+			i := y*dst.Stride + interRect.Min.X*4
+			for j, v := range values {
+				d := dst.Pix[i : i+4 : i+4]
+				s := scanLine[j*4 : j*4+4 : j*4+4]
+				i += 4
+
+				ab := float64(d[3]) / 255
+				as := float64(s[3]) / 255 * float64(v) / 255
+				ao := as + ab*(1-as)
+				if ao == 0 {
+					continue
+				}
+				for k := 0; k < 3; k++ {
+					d[k] = clamp((as*float64(s[k]) + (1-as)*ab*float64(d[k])) / ao)
+				}
+				d[3] = clamp(ao * 255)
+			}
+		}
+	})
+	return dst
+}
+
+// RoundedRectMask returns an anti-aliased mask of the given size with a white rectangle
+// with rounded corners of the given radius on a black background. The radius is limited
+// to half of the smaller side, so a square mask with a large radius contains a circle.
+//
+// Example:
+//
+//	// A circle mask for avatars.
+//	mask := imaging.RoundedRectMask(128, 128, 64)
+//
+func RoundedRectMask(width, height int, radius float64) *image.NRGBA {
+	if width <= 0 || height <= 0 {
+		return &image.NRGBA{}
+	}
+	radius = math.Max(0, math.Min(radius, math.Min(float64(width), float64(height))/2))
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	hw, hh := float64(width)/2, float64(height)/2
+	parallel(0, height, width*costPoint, func(ys <-chan int) {
+		for y := range ys {
+			i := y * dst.Stride
+			for x := 0; x < width; x++ {
+				// Signed distance from the pixel center to the rounded rectangle edge.
+				qx := math.Abs(float64(x)+0.5-hw) - (hw - radius)
+				qy := math.Abs(float64(y)+0.5-hh) - (hh - radius)
+				dist := math.Hypot(math.Max(qx, 0), math.Max(qy, 0)) + math.Min(math.Max(qx, qy), 0) - radius
+				v := clamp((0.5 - dist) * 255)
+				d := dst.Pix[i : i+4 : i+4]
+				d[0] = v
+				d[1] = v
+				d[2] = v
+				d[3] = 0xff
+				i += 4
+			}
+		}
+	})
+	return dst
+}
@@ -0,0 +1,119 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestApplyMask(t *testing.T) {
+	testCases := []struct {
+		name string
+		img  image.Image
+		mask image.Image
+		want *image.NRGBA
+	}{
+		{
+			"ApplyMask 3x1 gray mask",
+			&image.NRGBA{
+				Rect:   image.Rect(-1, -1, 2, 0),
+				Stride: 3 * 4,
+				Pix:    []uint8{0x10, 0x20, 0x30, 0xff, 0x40, 0x50, 0x60, 0xff, 0x70, 0x80, 0x90, 0x80},
+			},
+			&image.Gray{
+				Rect:   image.Rect(5, 5, 8, 6),
+				Stride: 3,
+				Pix:    []uint8{0x00, 0x80, 0xff},
+			},
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 1),
+				Stride: 3 * 4,
+				Pix:    []uint8{0x10, 0x20, 0x30, 0x00, 0x40, 0x50, 0x60, 0x80, 0x70, 0x80, 0x90, 0x80},
+			},
+		},
+		{
+			"ApplyMask 2x2 small transparent mask",
+			New(2, 2, color.NRGBA{0x10, 0x20, 0x30, 0xff}),
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 1, 2),
+				Stride: 4,
+				Pix:    []uint8{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x80},
+			},
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 2),
+				Stride: 2 * 4,
+				Pix: []uint8{
+					0x10, 0x20, 0x30, 0xff, 0x10, 0x20, 0x30, 0x00,
+					0x10, 0x20, 0x30, 0x80, 0x10, 0x20, 0x30, 0x00,
+				},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := ApplyMask(tc.img, tc.mask)
+			if !compareNRGBA(got, tc.want, 0) {
+				t.Fatalf("got result %#v want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPasteWithMask(t *testing.T) {
+	bg := New(3, 1, color.NRGBA{0x00, 0x00, 0x00, 0xff})
+	img := New(2, 1, color.NRGBA{0xff, 0x80, 0x00, 0xff})
+	mask := &image.Gray{Rect: image.Rect(0, 0, 2, 1), Stride: 2, Pix: []uint8{0xff, 0x80}}
+
+	got := PasteWithMask(bg, img, mask, image.Pt(1, 0))
+	want := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 3, 1),
+		Stride: 3 * 4,
+		Pix:    []uint8{0x00, 0x00, 0x00, 0xff, 0xff, 0x80, 0x00, 0xff, 0x80, 0x40, 0x00, 0xff},
+	}
+	if !compareNRGBA(got, want, 1) {
+		t.Fatalf("got result %#v want %#v", got, want)
+	}
+
+	// A white mask gives the same result as Overlay.
+	white := New(testdataFlowersSmallPNG.Bounds().Dx(), testdataFlowersSmallPNG.Bounds().Dy(), color.White)
+	got = PasteWithMask(testdataBranchesPNG, testdataFlowersSmallPNG, white, image.Pt(-10, 30))
+	want = Overlay(testdataBranchesPNG, testdataFlowersSmallPNG, image.Pt(-10, 30), 1)
+	if !compareNRGBA(got, want, 1) {
+		t.Fatalf("result with a white mask differs from Overlay")
+	}
+
+	// Transparent pixels over a transparent background stay transparent.
+	got = PasteWithMask(New(1, 1, color.Transparent), New(1, 1, color.Transparent), white, image.Pt(0, 0))
+	if !compareNRGBA(got, New(1, 1, color.Transparent), 0) {
+		t.Fatalf("got result %#v want transparent", got)
+	}
+}
+
+func TestRoundedRectMask(t *testing.T) {
+	mask := RoundedRectMask(20, 10, 100)
+	if mask.Bounds() != image.Rect(0, 0, 20, 10) {
+		t.Fatalf("got bounds %v want 20x10", mask.Bounds())
+	}
+	testCases := []struct {
+		x, y int
+		want uint8
+	}{
+		{0, 0, 0x00},
+		{19, 9, 0x00},
+		{10, 5, 0xff},
+		{10, 0, 0xff},
+		{0, 5, 0xf8},
+	}
+	for _, tc := range testCases {
+		if got := mask.NRGBAAt(tc.x, tc.y); got.R != tc.want || got.A != 0xff {
+			t.Errorf("pixel (%d, %d): got %v want %#x", tc.x, tc.y, got, tc.want)
+		}
+	}
+
+	if got := RoundedRectMask(10, 10, 0); !compareNRGBA(got, New(10, 10, color.White), 0) {
+		t.Fatalf("zero radius mask is not a white rectangle")
+	}
+	if got := RoundedRectMask(0, 10, 2); got.Bounds() != (image.Rectangle{}) {
+		t.Fatalf("got bounds %v want empty", got.Bounds())
+	}
+}
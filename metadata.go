@@ -0,0 +1,331 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+)
+
+// MetaKind is a kind of image metadata.
+type MetaKind int
+
+// Metadata kinds.
+const (
+	// MetaOrientation is the EXIF orientation tag.
+	MetaOrientation MetaKind = iota
+
+	// MetaICC is the embedded ICC color profile.
+	MetaICC
+
+	// MetaEXIF is the EXIF data except the orientation tag and the GPS location,
+	// e.g. the camera model, the capture time and the embedded thumbnail.
+	MetaEXIF
+
+	// MetaGPS is the GPS location stored in the EXIF data.
+	MetaGPS
+
+	// MetaXMP is the XMP data.
+	MetaXMP
+
+	// MetaComment is the comments, the PNG text chunks and modification time
+	// and the Photoshop (IPTC) data.
+	MetaComment
+)
+
+var errInvalidMetadata = errors.New("imaging: invalid image metadata")
+
+// StripMetadata copies a JPEG or PNG image from r to w removing the metadata except
+// the kinds listed in keep. The pixel data is copied as is, without decoding and
+// re-encoding, so there is no quality loss. It returns ErrUnsupportedFormat for other
+// formats. The application-specific JPEG segments that are not recognized are removed,
+// except the JFIF and Adobe ones, which are needed to decode the image.
+//
+// Example:
+//
+//	// Remove everything that may identify the author, keeping the image appearance.
+//	err := imaging.StripMetadata(r, w, imaging.MetaOrientation, imaging.MetaICC)
+//
+func StripMetadata(r io.Reader, w io.Writer, keep ...MetaKind) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	var kinds [MetaComment + 1]bool
+	for _, k := range keep {
+		if k >= 0 && int(k) < len(kinds) {
+			kinds[k] = true
+		}
+	}
+
+	var out []byte
+	switch {
+	case bytes.HasPrefix(data, []byte("\xff\xd8")):
+		out, err = stripJPEGMetadata(data, kinds)
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		out, err = stripPNGMetadata(data, kinds)
+	default:
+		return ErrUnsupportedFormat
+	}
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+const (
+	jpegEXIFHeader    = "Exif\x00\x00"
+	jpegXMPHeader     = "http://ns.adobe.com/xap/1.0/\x00"
+	jpegXMPExtHeader  = "http://ns.adobe.com/xmp/extension/\x00"
+	pngXMPKeyword     = "XML:com.adobe.xmp\x00"
+	exifTagOrient     = 0x0112
+	exifTagGPSPointer = 0x8825
+)
+
+func stripJPEGMetadata(data []byte, kinds [MetaComment + 1]bool) ([]byte, error) {
+	out := append([]byte(nil), data[:2]...)
+	i := 2
+	for {
+		if i+4 > len(data) || data[i] != 0xff {
+			return nil, errInvalidMetadata
+		}
+		marker := data[i+1]
+		if marker == 0xda { // SOS: the rest is the image data.
+			break
+		}
+		size := int(binary.BigEndian.Uint16(data[i+2:]))
+		if size < 2 || i+2+size > len(data) {
+			return nil, errInvalidMetadata
+		}
+		seg := data[i : i+2+size]
+		payload := seg[4:]
+		i += 2 + size
+
+		switch {
+		case marker == 0xe1 && bytes.HasPrefix(payload, []byte(jpegEXIFHeader)):
+			tiff := stripEXIF(payload[len(jpegEXIFHeader):], kinds)
+			if tiff == nil {
+				continue
+			}
+			n := 2 + len(jpegEXIFHeader) + len(tiff)
+			if n > 0xffff {
+				return nil, errInvalidMetadata
+			}
+			out = append(out, 0xff, 0xe1, byte(n>>8), byte(n))
+			out = append(out, jpegEXIFHeader...)
+			out = append(out, tiff...)
+			continue
+		case marker == 0xe1 && (bytes.HasPrefix(payload, []byte(jpegXMPHeader)) || bytes.HasPrefix(payload, []byte(jpegXMPExtHeader))):
+			if !kinds[MetaXMP] {
+				continue
+			}
+		case marker == 0xe2 && bytes.HasPrefix(payload, []byte(jpegICCHeader)):
+			if !kinds[MetaICC] {
+				continue
+			}
+		case marker == 0xed || marker == 0xfe: // APP13 (Photoshop) or COM.
+			if !kinds[MetaComment] {
+				continue
+			}
+		case marker == 0xe0 || marker == 0xee: // APP0 (JFIF) or APP14 (Adobe).
+		case marker >= 0xe0 && marker <= 0xef:
+			continue
+		}
+		out = append(out, seg...)
+	}
+	return append(out, data[i:]...), nil
+}
+
+func stripPNGMetadata(data []byte, kinds [MetaComment + 1]bool) ([]byte, error) {
+	out := append([]byte(nil), data[:8]...)
+	i := 8
+	for i < len(data) {
+		if i+12 > len(data) {
+			return nil, errInvalidMetadata
+		}
+		size := int(binary.BigEndian.Uint32(data[i:]))
+		if size < 0 || size > len(data)-i-12 {
+			return nil, errInvalidMetadata
+		}
+		name := string(data[i+4 : i+8])
+		chunk := data[i : i+12+size]
+		payload := chunk[8 : 8+size]
+		i += 12 + size
+
+		switch name {
+		case "eXIf":
+			tiff := stripEXIF(payload, kinds)
+			if tiff == nil {
+				continue
+			}
+			out = appendPNGChunk(out, name, tiff)
+			continue
+		case "iCCP":
+			if !kinds[MetaICC] {
+				continue
+			}
+		case "iTXt":
+			if bytes.HasPrefix(payload, []byte(pngXMPKeyword)) {
+				if !kinds[MetaXMP] {
+					continue
+				}
+			} else if !kinds[MetaComment] {
+				continue
+			}
+		case "tEXt", "zTXt", "tIME":
+			if !kinds[MetaComment] {
+				continue
+			}
+		}
+		out = append(out, chunk...)
+	}
+	return out, nil
+}
+
+func appendPNGChunk(out []byte, name string, payload []byte) []byte {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(len(payload)))
+	out = append(out, b[:]...)
+	start := len(out)
+	out = append(out, name...)
+	out = append(out, payload...)
+	binary.BigEndian.PutUint32(b[:], crc32.ChecksumIEEE(out[start:]))
+	return append(out, b[:]...)
+}
+
+// stripEXIF returns the EXIF (TIFF) data with the metadata kinds that are not
+// kept removed, or nil if nothing is left. The removed GPS data is overwritten
+// with zeros, so the other offsets in the data stay valid. If the data is malformed
+// and has to be changed, it's removed entirely.
+func stripEXIF(tiff []byte, kinds [MetaComment + 1]bool) []byte {
+	if kinds[MetaEXIF] && kinds[MetaGPS] && kinds[MetaOrientation] {
+		return tiff
+	}
+	order, ifd, ok := exifIFD0(tiff)
+	if !ok {
+		return nil
+	}
+
+	if !kinds[MetaEXIF] {
+		if !kinds[MetaOrientation] {
+			return nil
+		}
+		e, ok := findIFDEntry(tiff, order, ifd, exifTagOrient)
+		if !ok {
+			return nil
+		}
+		// A minimal TIFF structure with the orientation tag only.
+		out := make([]byte, 8+2+12+4)
+		copy(out, tiff[:4])
+		order.PutUint32(out[4:], 8)
+		order.PutUint16(out[8:], 1)
+		copy(out[10:22], tiff[e:e+12])
+		return out
+	}
+
+	out := append([]byte(nil), tiff...)
+	if !kinds[MetaGPS] {
+		if e, ok := findIFDEntry(out, order, ifd, exifTagGPSPointer); ok {
+			if !zeroIFD(out, order, int(order.Uint32(out[e+8:]))) {
+				return nil
+			}
+			removeIFDEntry(out, order, ifd, e)
+		}
+	}
+	if !kinds[MetaOrientation] {
+		if e, ok := findIFDEntry(out, order, ifd, exifTagOrient); ok {
+			removeIFDEntry(out, order, ifd, e)
+		}
+	}
+	return out
+}
+
+// exifIFD0 parses the TIFF header and returns the byte order and the offset of
+// the first IFD, checking that the IFD entries and the next IFD offset fit the data.
+func exifIFD0(tiff []byte) (binary.ByteOrder, int, bool) {
+	if len(tiff) < 8 {
+		return nil, 0, false
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:4]) {
+	case "II\x2a\x00":
+		order = binary.LittleEndian
+	case "MM\x00\x2a":
+		order = binary.BigEndian
+	default:
+		return nil, 0, false
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if !validIFD(tiff, order, ifd) {
+		return nil, 0, false
+	}
+	return order, ifd, true
+}
+
+func validIFD(tiff []byte, order binary.ByteOrder, ifd int) bool {
+	if ifd < 8 || ifd > len(tiff)-2 {
+		return false
+	}
+	n := int(order.Uint16(tiff[ifd:]))
+	return ifd+2+n*12+4 <= len(tiff)
+}
+
+// findIFDEntry returns the offset of the IFD entry with the given tag.
+func findIFDEntry(tiff []byte, order binary.ByteOrder, ifd int, tag uint16) (int, bool) {
+	n := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < n; i++ {
+		e := ifd + 2 + i*12
+		if order.Uint16(tiff[e:]) == tag {
+			return e, true
+		}
+	}
+	return 0, false
+}
+
+// removeIFDEntry removes the entry at offset e from the IFD, moving the following
+// entries and the next IFD offset up and zeroing the freed bytes.
+func removeIFDEntry(tiff []byte, order binary.ByteOrder, ifd, e int) {
+	n := int(order.Uint16(tiff[ifd:]))
+	end := ifd + 2 + n*12 + 4
+	copy(tiff[e:], tiff[e+12:end])
+	for i := end - 12; i < end; i++ {
+		tiff[i] = 0
+	}
+	order.PutUint16(tiff[ifd:], uint16(n-1))
+}
+
+// zeroIFD overwrites the IFD and the values it refers to with zeros.
+// It returns false if the IFD is malformed.
+func zeroIFD(tiff []byte, order binary.ByteOrder, ifd int) bool {
+	typeSizes := [...]int{0, 1, 1, 2, 4, 8, 1, 1, 2, 4, 8, 4, 8}
+	if !validIFD(tiff, order, ifd) {
+		return false
+	}
+	n := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < n; i++ {
+		e := ifd + 2 + i*12
+		typ := int(order.Uint16(tiff[e+2:]))
+		count := int64(order.Uint32(tiff[e+4:]))
+		if typ <= 0 || typ >= len(typeSizes) {
+			continue
+		}
+		size := count * int64(typeSizes[typ])
+		if size <= 4 {
+			continue
+		}
+		off := int64(order.Uint32(tiff[e+8:]))
+		if off+size > int64(len(tiff)) {
+			return false
+		}
+		for j := off; j < off+size; j++ {
+			tiff[j] = 0
+		}
+	}
+	for i := ifd; i < ifd+2+n*12+4; i++ {
+		tiff[i] = 0
+	}
+	return true
+}
@@ -0,0 +1,165 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"testing"
+)
+
+// makeEXIF returns little-endian EXIF data with the orientation, camera make
+// and GPS latitude tags.
+func makeEXIF() []byte {
+	le := binary.LittleEndian
+	tiff := make([]byte, 8+2+3*12+4+2+12+4+24)
+	copy(tiff, "II\x2a\x00")
+	le.PutUint32(tiff[4:], 8)
+
+	ifd := 8
+	le.PutUint16(tiff[ifd:], 3)
+	entry := func(e int, tag, typ uint16, count, value uint32) {
+		le.PutUint16(tiff[e:], tag)
+		le.PutUint16(tiff[e+2:], typ)
+		le.PutUint32(tiff[e+4:], count)
+		le.PutUint32(tiff[e+8:], value)
+	}
+	gps := ifd + 2 + 3*12 + 4
+	entry(ifd+2, exifTagOrient, 3, 1, 6)
+	entry(ifd+14, 0x010f, 2, 4, le.Uint32([]byte("Cam\x00")))
+	entry(ifd+26, exifTagGPSPointer, 4, 1, uint32(gps))
+
+	values := gps + 2 + 12 + 4
+	le.PutUint16(tiff[gps:], 1)
+	entry(gps+2, 0x0002, 5, 3, uint32(values))
+	for i := values; i < len(tiff); i++ {
+		tiff[i] = 0x77
+	}
+	return tiff
+}
+
+func insertJPEGSegment(jpeg []byte, marker byte, payload []byte) []byte {
+	n := len(payload) + 2
+	out := append([]byte(nil), jpeg[:2]...)
+	out = append(out, 0xff, marker, byte(n>>8), byte(n))
+	out = append(out, payload...)
+	return append(out, jpeg[2:]...)
+}
+
+func TestStripMetadataJPEG(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	data, err := EncodeBytes(img, JPEG, ICCProfile(srgbICCProfile))
+	if err != nil {
+		t.Fatalf("EncodeBytes: %v", err)
+	}
+	orig, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	data = insertJPEGSegment(data, 0xfe, []byte("a comment"))
+	data = insertJPEGSegment(data, 0xe1, []byte(jpegXMPHeader+"<x:xmpmeta/>"))
+	data = insertJPEGSegment(data, 0xe1, append([]byte(jpegEXIFHeader), makeEXIF()...))
+
+	testCases := []struct {
+		name        string
+		keep        []MetaKind
+		orientation orientation
+		make        bool
+		gps         bool
+		icc         bool
+		xmp         bool
+		comment     bool
+	}{
+		{"all", []MetaKind{MetaOrientation, MetaICC, MetaEXIF, MetaGPS, MetaXMP, MetaComment}, 6, true, true, true, true, true},
+		{"none", nil, 0, false, false, false, false, false},
+		{"orientation and ICC", []MetaKind{MetaOrientation, MetaICC}, 6, false, false, true, false, false},
+		{"no GPS", []MetaKind{MetaOrientation, MetaEXIF, MetaXMP, MetaComment}, 6, true, false, false, true, true},
+		{"EXIF only", []MetaKind{MetaEXIF}, 0, true, false, false, false, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := StripMetadata(bytes.NewReader(data), &buf, tc.keep...); err != nil {
+				t.Fatalf("StripMetadata: %v", err)
+			}
+			got := buf.Bytes()
+			if o := readOrientation(bytes.NewReader(got)); o != tc.orientation {
+				t.Fatalf("got orientation %d want %d", o, tc.orientation)
+			}
+			if bytes.Contains(got, []byte("Cam\x00")) != tc.make {
+				t.Fatalf("camera make: got %v want %v", !tc.make, tc.make)
+			}
+			if bytes.Contains(got, bytes.Repeat([]byte{0x77}, 24)) != tc.gps {
+				t.Fatalf("GPS data: got %v want %v", !tc.gps, tc.gps)
+			}
+			profile, err := ReadICCProfile(bytes.NewReader(got))
+			if err != nil || (profile != nil) != tc.icc {
+				t.Fatalf("got ICC profile %d bytes, %v", len(profile), err)
+			}
+			if bytes.Contains(got, []byte("xmpmeta")) != tc.xmp {
+				t.Fatalf("XMP: got %v want %v", !tc.xmp, tc.xmp)
+			}
+			if bytes.Contains(got, []byte("a comment")) != tc.comment {
+				t.Fatalf("comment: got %v want %v", !tc.comment, tc.comment)
+			}
+			dec, err := Decode(bytes.NewReader(got))
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if !compareNRGBA(Clone(dec), Clone(orig), 0) {
+				t.Fatalf("the pixels have changed")
+			}
+		})
+	}
+}
+
+func TestStripMetadataPNG(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	data, err := EncodeBytes(img, PNG, ICCProfile(srgbICCProfile))
+	if err != nil {
+		t.Fatalf("EncodeBytes: %v", err)
+	}
+	// Insert the text and EXIF chunks after IHDR.
+	ihdr := 8 + 12 + 13
+	var extra []byte
+	extra = appendPNGChunk(extra, "tEXt", []byte("Author\x00Someone"))
+	extra = appendPNGChunk(extra, "eXIf", makeEXIF())
+	data = append(append(append([]byte(nil), data[:ihdr]...), extra...), data[ihdr:]...)
+
+	var buf bytes.Buffer
+	if err := StripMetadata(bytes.NewReader(data), &buf, MetaICC, MetaEXIF); err != nil {
+		t.Fatalf("StripMetadata: %v", err)
+	}
+	got := buf.Bytes()
+	if bytes.Contains(got, []byte("Someone")) {
+		t.Fatalf("the text chunk is not removed")
+	}
+	if !bytes.Contains(got, []byte("Cam\x00")) || bytes.Contains(got, bytes.Repeat([]byte{0x77}, 24)) {
+		t.Fatalf("the EXIF data is not stripped correctly")
+	}
+	if profile, err := ReadICCProfile(bytes.NewReader(got)); err != nil || profile == nil {
+		t.Fatalf("the ICC profile is not kept: %v", err)
+	}
+	dec, err := Decode(bytes.NewReader(got))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if !compareNRGBA(Clone(dec), img, 0) {
+		t.Fatalf("the pixels have changed")
+	}
+}
+
+func TestStripMetadataErrors(t *testing.T) {
+	var buf bytes.Buffer
+	if err := StripMetadata(bytes.NewReader([]byte("GIF89a")), &buf); err != ErrUnsupportedFormat {
+		t.Fatalf("got error %v want %v", err, ErrUnsupportedFormat)
+	}
+	if err := StripMetadata(bytes.NewReader([]byte("\xff\xd8\xff\xe1\x00")), &buf); err != errInvalidMetadata {
+		t.Fatalf("got error %v want %v", err, errInvalidMetadata)
+	}
+	if err := StripMetadata(bytes.NewReader([]byte("\x89PNG\r\n\x1a\n\x00\x00\x10\x00IHDR")), &buf); err != errInvalidMetadata {
+		t.Fatalf("got error %v want %v", err, errInvalidMetadata)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("got %d bytes written on error", buf.Len())
+	}
+}
@@ -0,0 +1,183 @@
+package imaging
+
+import (
+	"image"
+)
+
+// StructuringElement is the shape of the neighborhood used by the morphological operations,
+// given as the offsets of the neighbor pixels from the center pixel. The center pixel
+// (0, 0) is usually included.
+type StructuringElement []image.Point
+
+// RectElement returns the structuring element of the width x height rectangle
+// centered at the pixel. Even sizes extend one pixel further to the left and up.
+//
+// Example:
+//
+//	// Close the horizontal gaps in the text lines.
+//	dstImage := imaging.Closing(srcImage, imaging.RectElement(9, 1))
+//
+func RectElement(width, height int) StructuringElement {
+	var se StructuringElement
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			se = append(se, image.Pt(x-width/2, y-height/2))
+		}
+	}
+	return se
+}
+
+// CrossElement returns the structuring element of the cross with the arms of the given
+// length: the pixel and its neighbors up to radius pixels away horizontally or vertically.
+//
+// Example:
+//
+//	dstImage := imaging.Erode(srcImage, imaging.CrossElement(1))
+//
+func CrossElement(radius int) StructuringElement {
+	if radius < 0 {
+		return nil
+	}
+	se := StructuringElement{{}}
+	for i := 1; i <= radius; i++ {
+		se = append(se, image.Pt(-i, 0), image.Pt(i, 0), image.Pt(0, -i), image.Pt(0, i))
+	}
+	return se
+}
+
+// DiskElement returns the structuring element of the disk of the given radius centered
+// at the pixel. The disks are the most uniform in all directions.
+//
+// Example:
+//
+//	dstImage := imaging.Opening(srcImage, imaging.DiskElement(2))
+//
+func DiskElement(radius int) StructuringElement {
+	var se StructuringElement
+	for y := -radius; y <= radius; y++ {
+		for x := -radius; x <= radius; x++ {
+			// The margin of about half a pixel makes the small disks rounder.
+			if x*x+y*y <= radius*radius+radius {
+				se = append(se, image.Pt(x, y))
+			}
+		}
+	}
+	return se
+}
+
+// Erode shrinks the light areas of the image and grows the dark ones: each channel
+// of each pixel, including alpha, is replaced by its minimum over the neighborhood given
+// by the structuring element. The pixels outside the image are ignored. On black and white
+// images it removes the white specks smaller than the element and thickens the black strokes,
+// and it shrinks the masks given by the alpha channel. If the element is empty,
+// a copy of the image is returned. The time is proportional to the size of the element.
+//
+// Example:
+//
+//	dstImage := imaging.Erode(srcImage, imaging.DiskElement(1))
+//
+func Erode(img image.Image, se StructuringElement) *image.NRGBA {
+	return morphology(img, se, false)
+}
+
+// Dilate grows the light areas of the image and shrinks the dark ones: each channel
+// of each pixel, including alpha, is replaced by its maximum over the neighborhood given
+// by the structuring element reflected about the center. It's the opposite of Erode:
+// it removes the black specks and thins the black strokes, and it grows the masks.
+//
+// Example:
+//
+//	dstImage := imaging.Dilate(srcImage, imaging.DiskElement(1))
+//
+func Dilate(img image.Image, se StructuringElement) *image.NRGBA {
+	return morphology(img, se, true)
+}
+
+// Opening erodes and then dilates the image with the structuring element. It removes
+// the light details smaller than the element, such as the white noise or thin white lines,
+// keeping the shape of the larger light areas.
+//
+// Example:
+//
+//	// Remove the salt noise of a black and white scan.
+//	dstImage := imaging.Opening(imaging.OtsuThreshold(scan), imaging.RectElement(3, 3))
+//
+func Opening(img image.Image, se StructuringElement) *image.NRGBA {
+	if len(se) == 0 {
+		return Clone(img)
+	}
+	return Dilate(Erode(img, se), se)
+}
+
+// Closing dilates and then erodes the image with the structuring element. It removes
+// the dark details smaller than the element, such as the pepper noise or the small gaps
+// and holes in the light areas, keeping the shape of the larger dark areas.
+//
+// Example:
+//
+//	// Fill the small holes of a mask.
+//	dstImage := imaging.Closing(mask, imaging.DiskElement(3))
+//
+func Closing(img image.Image, se StructuringElement) *image.NRGBA {
+	if len(se) == 0 {
+		return Clone(img)
+	}
+	return Erode(Dilate(img, se), se)
+}
+
+// morphology computes the minimum (erosion) or the maximum of the reflected element
+// (dilation) of each channel over the neighborhood of each pixel.
+func morphology(img image.Image, se StructuringElement, dilate bool) *image.NRGBA {
+	if len(se) == 0 {
+		return Clone(img)
+	}
+	src := toNRGBA(img)
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	if w <= 0 || h <= 0 {
+		return dst
+	}
+
+	offsets := make([]image.Point, len(se))
+	for i, p := range se {
+		if dilate {
+			p = image.Pt(-p.X, -p.Y)
+		}
+		offsets[i] = p
+	}
+	parallel(0, h, w*len(offsets)*costTap, func(ys <-chan int) {
+		for y := range ys {
+			for x := 0; x < w; x++ {
+				v := [4]uint8{255, 255, 255, 255}
+				if dilate {
+					v = [4]uint8{}
+				}
+				found := false
+				for _, p := range offsets {
+					sx, sy := x+p.X, y+p.Y
+					if sx < 0 || sx >= w || sy < 0 || sy >= h {
+						continue
+					}
+					found = true
+					i := sy*src.Stride + sx*4
+					s := src.Pix[i : i+4 : i+4]
+					for c := range v {
+						if dilate {
+							v[c] = max(v[c], s[c])
+						} else {
+							v[c] = min(v[c], s[c])
+						}
+					}
+				}
+				if !found {
+					// The element doesn't reach into the image, keep the pixel.
+					i := y*src.Stride + x*4
+					copy(v[:], src.Pix[i:i+4])
+				}
+				i := y*dst.Stride + x*4
+				copy(dst.Pix[i:i+4], v[:])
+			}
+		}
+	})
+	return dst
+}
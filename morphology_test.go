@@ -0,0 +1,175 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// binaryImage returns a black and white image from the rows of '#' (white) and '.' (black).
+func binaryImage(rows ...string) *image.NRGBA {
+	img := New(len(rows[0]), len(rows), color.Black)
+	for y, row := range rows {
+		for x, c := range row {
+			if c == '#' {
+				img.SetNRGBA(x, y, color.NRGBA{255, 255, 255, 255})
+			}
+		}
+	}
+	return img
+}
+
+func TestStructuringElements(t *testing.T) {
+	testCases := []struct {
+		name string
+		se   StructuringElement
+		want int
+	}{
+		{"RectElement(3, 2)", RectElement(3, 2), 6},
+		{"RectElement(0, 5)", RectElement(0, 5), 0},
+		{"CrossElement(0)", CrossElement(0), 1},
+		{"CrossElement(2)", CrossElement(2), 9},
+		{"CrossElement(-1)", CrossElement(-1), 0},
+		{"DiskElement(0)", DiskElement(0), 1},
+		{"DiskElement(1)", DiskElement(1), 9},
+		{"DiskElement(2)", DiskElement(2), 21},
+	}
+	for _, tc := range testCases {
+		if len(tc.se) != tc.want {
+			t.Fatalf("%s: got %d points %v, want %d", tc.name, len(tc.se), tc.se, tc.want)
+		}
+	}
+	if se := RectElement(2, 2); se[0] != image.Pt(-1, -1) || se[3] != image.Pt(0, 0) {
+		t.Fatalf("got %v", se)
+	}
+}
+
+func TestErodeDilate(t *testing.T) {
+	src := binaryImage(
+		"......",
+		".###..",
+		".###..",
+		".###.#",
+		"......",
+	)
+	testCases := []struct {
+		name string
+		got  *image.NRGBA
+		want *image.NRGBA
+	}{
+		{
+			"Erode",
+			Erode(src, CrossElement(1)),
+			binaryImage(
+				"......",
+				"......",
+				"..#...",
+				"......",
+				"......",
+			),
+		},
+		{
+			"Dilate",
+			Dilate(src, CrossElement(1)),
+			binaryImage(
+				".###..",
+				"#####.",
+				"######",
+				"######",
+				".###.#",
+			),
+		},
+		{
+			// The element covers the pixel and its right neighbor, the reflected one
+			// the pixel and its left neighbor.
+			"Dilate asymmetric",
+			Dilate(src, StructuringElement{{0, 0}, {1, 0}}),
+			binaryImage(
+				"......",
+				".####.",
+				".####.",
+				".#####",
+				"......",
+			),
+		},
+		{
+			"Opening",
+			Opening(src, RectElement(3, 3)),
+			binaryImage(
+				"......",
+				".###..",
+				".###..",
+				".###..",
+				"......",
+			),
+		},
+		{
+			"Empty element",
+			Erode(src, nil),
+			src,
+		},
+	}
+	for _, tc := range testCases {
+		if !compareNRGBA(tc.got, tc.want, 0) {
+			t.Fatalf("%s: got %v", tc.name, tc.got.Pix)
+		}
+	}
+}
+
+func TestClosing(t *testing.T) {
+	src := binaryImage(
+		"#######",
+		"##.####",
+		"#######",
+		"####..#",
+		"####..#",
+		"#######",
+	)
+	// The hole of one pixel is filled, the larger one is kept.
+	got := Closing(src, RectElement(2, 2))
+	want := binaryImage(
+		"#######",
+		"#######",
+		"#######",
+		"####..#",
+		"####..#",
+		"#######",
+	)
+	if !compareNRGBA(got, want, 0) {
+		t.Fatalf("got %v", got.Pix)
+	}
+	if got := Closing(src, RectElement(3, 3)); !compareNRGBA(got, New(7, 6, color.White), 0) {
+		t.Fatalf("got %v", got.Pix)
+	}
+}
+
+func TestMorphologyGrayscale(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(2, 2, 5, 3))
+	copy(src.Pix, []uint8{
+		10, 200, 30, 255,
+		40, 50, 60, 100,
+		70, 80, 90, 0,
+	})
+	se := RectElement(3, 1)
+	got := Erode(src, se)
+	want := []uint8{
+		10, 50, 30, 100,
+		10, 50, 30, 0,
+		40, 50, 60, 0,
+	}
+	if !compareNRGBA(got, &image.NRGBA{Rect: image.Rect(0, 0, 3, 1), Stride: 12, Pix: want}, 0) {
+		t.Fatalf("Erode: got %v", got.Pix)
+	}
+	got = Dilate(src, se)
+	want = []uint8{
+		40, 200, 60, 255,
+		70, 200, 90, 255,
+		70, 80, 90, 100,
+	}
+	if !compareNRGBA(got, &image.NRGBA{Rect: image.Rect(0, 0, 3, 1), Stride: 12, Pix: want}, 0) {
+		t.Fatalf("Dilate: got %v", got.Pix)
+	}
+	if got := Erode(&image.NRGBA{}, se); !got.Rect.Empty() {
+		t.Fatalf("got bounds %v", got.Rect)
+	}
+}
@@ -0,0 +1,77 @@
+package imaging
+
+import (
+	"context"
+	"image"
+	"math"
+)
+
+// Noise levels (standard deviation in 8-bit units) between which the noise-aware
+// downscaling switches from the requested filter to the Box filter.
+const (
+	noiseLow  = 2.0
+	noiseHigh = 8.0
+)
+
+// noiseMap returns a grayscale image with the estimated noise standard deviation of each
+// pixel. It uses the Immerkaer operator, the difference of two Laplacians, which responds
+// to the noise but mostly ignores the edges.
+func noiseMap(img image.Image) *image.NRGBA {
+	src := Clone(img)
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	dst := image.NewNRGBA(src.Rect)
+	lum := func(x, y int) float64 {
+		i := edgeIndex(y, h, EdgeClamp)*src.Stride + edgeIndex(x, w, EdgeClamp)*4
+		s := src.Pix[i : i+3 : i+3]
+		return 0.299*float64(s[0]) + 0.587*float64(s[1]) + 0.114*float64(s[2])
+	}
+	scale := math.Sqrt(math.Pi/2) / 6
+	parallel(0, h, w*costHeavy, func(ys <-chan int) {
+		for y := range ys {
+			for x := 0; x < w; x++ {
+				v := lum(x-1, y-1) - 2*lum(x, y-1) + lum(x+1, y-1) -
+					2*lum(x-1, y) + 4*lum(x, y) - 2*lum(x+1, y) +
+					lum(x-1, y+1) - 2*lum(x, y+1) + lum(x+1, y+1)
+				i := y*dst.Stride + x*4
+				n := clamp(math.Abs(v) * scale)
+				dst.Pix[i+0] = n
+				dst.Pix[i+1] = n
+				dst.Pix[i+2] = n
+				dst.Pix[i+3] = 0xff
+			}
+		}
+	})
+	return dst
+}
+
+// blendNoisyRegions blends the image img resized to the size of dst using the Box filter
+// into dst, which is img resized using the filter. The blending weight is derived from
+// the estimated noise level of the source region.
+func blendNoisyRegions(dst *image.NRGBA, img image.Image, filter ResampleFilter) {
+	w, h := dst.Rect.Dx(), dst.Rect.Dy()
+	if w <= 0 || h <= 0 || w >= img.Bounds().Dx() && h >= img.Bounds().Dy() {
+		return
+	}
+	ctx := context.Background()
+	noise := resize(ctx, nil, noiseMap(img), w, h, Box)
+	box := resize(ctx, nil, img, w, h, Box)
+	parallel(0, h, w*costPoint, func(ys <-chan int) {
+		for y := range ys {
+			for x := 0; x < w; x++ {
+				i := y*dst.Stride + x*4
+				t := (float64(noise.Pix[i]) - noiseLow) / (noiseHigh - noiseLow)
+				if t <= 0 {
+					continue
+				}
+				if t > 1 {
+					t = 1
+				}
+				d := dst.Pix[i : i+4 : i+4]
+				b := box.Pix[i : i+4 : i+4]
+				for c := 0; c < 4; c++ {
+					d[c] = clamp(float64(d[c]) + t*(float64(b[c])-float64(d[c])))
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,87 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+)
+
+// noisyImage returns a gray image with Gaussian noise of the given standard deviation.
+func noisyImage(w, h int, sigma float64) *image.NRGBA {
+	rnd := rand.New(rand.NewSource(1))
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := clamp(128 + rnd.NormFloat64()*sigma)
+			img.SetNRGBA(x, y, color.NRGBA{v, v, v, 0xff})
+		}
+	}
+	return img
+}
+
+func meanValue(img *image.NRGBA) float64 {
+	var sum float64
+	for i := 0; i < len(img.Pix); i += 4 {
+		sum += float64(img.Pix[i])
+	}
+	return sum / float64(len(img.Pix)/4)
+}
+
+func TestNoiseMap(t *testing.T) {
+	got := meanValue(noiseMap(noisyImage(64, 64, 20)))
+	if got < 15 || got > 25 {
+		t.Fatalf("got noise estimate %v want about 20", got)
+	}
+
+	// Flat areas and straight edges have no noise.
+	edges := New(16, 16, color.White)
+	for y := 0; y < 16; y++ {
+		for x := 8; x < 16; x++ {
+			edges.SetNRGBA(x, y, color.NRGBA{0, 0, 0, 0xff})
+		}
+	}
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 16; x++ {
+			edges.SetNRGBA(x, y, color.NRGBA{0x40, 0x40, 0x40, 0xff})
+		}
+	}
+	if got := meanValue(noiseMap(edges)); got > 1 {
+		t.Fatalf("got noise estimate %v for a clean image want about 0", got)
+	}
+}
+
+func TestResizeNoiseAware(t *testing.T) {
+	noisy := noisyImage(128, 128, 30)
+	plain := Resize(noisy, 32, 32, Lanczos)
+	box := Resize(noisy, 32, 32, Box)
+	got := Resize(noisy, 32, 32, Lanczos, NoiseAware(true))
+	if !compareNRGBA(got, box, 0) {
+		t.Fatalf("noisy image is not downscaled with Box filter")
+	}
+	if compareNRGBA(plain, box, 0) {
+		t.Fatalf("Lanczos and Box results are the same")
+	}
+
+	// Clean images are not affected.
+	clean := image.NewNRGBA(image.Rect(0, 0, 128, 128))
+	for y := 0; y < 128; y++ {
+		for x := 0; x < 128; x++ {
+			v := uint8(x + y)
+			if x > 64 {
+				v = 0
+			}
+			clean.SetNRGBA(x, y, color.NRGBA{v, v / 2, 0xff - v, 0xff})
+		}
+	}
+	got = Resize(clean, 32, 0, Lanczos, NoiseAware(true))
+	want := Resize(clean, 32, 0, Lanczos)
+	if !compareNRGBA(got, want, 0) {
+		t.Fatalf("clean image is affected")
+	}
+
+	got = Resize(noisy, 256, 0, Lanczos, NoiseAware(true))
+	if !compareNRGBA(got, Resize(noisy, 256, 0, Lanczos), 0) {
+		t.Fatalf("upscaled image is affected")
+	}
+}
@@ -0,0 +1,231 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// The functions in this file work with 16 bits per channel and return *image.NRGBA64 images,
+// so that high bit depth images, such as 16-bit TIFF or PNG scans, can be processed
+// without the banding caused by the conversion to 8 bits. The results can be saved
+// in the PNG and TIFF formats without the loss of precision.
+
+// get16 returns the 16-bit value stored in big-endian order at the start of p.
+func get16(p []uint8) uint16 {
+	return uint16(p[0])<<8 | uint16(p[1])
+}
+
+// put16 stores the 16-bit value in big-endian order at the start of p.
+func put16(p []uint8, v uint16) {
+	p[0] = uint8(v >> 8)
+	p[1] = uint8(v)
+}
+
+// Clone16 returns a copy of the given image as *image.NRGBA64 with the bounds
+// starting at (0, 0). The 16-bit precision of the source is preserved.
+//
+// Example:
+//
+//	img, err := imaging.Open("scan.tif")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	img16 := imaging.Clone16(img)
+//
+func Clone16(img image.Image) *image.NRGBA64 {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dst := image.NewNRGBA64(image.Rect(0, 0, w, h))
+	if w <= 0 || h <= 0 {
+		return dst
+	}
+
+	switch src := img.(type) {
+	case *image.NRGBA64:
+		parallel(0, h, w*costCopy, func(ys <-chan int) {
+			for y := range ys {
+				i := src.PixOffset(b.Min.X, b.Min.Y+y)
+				copy(dst.Pix[y*dst.Stride:y*dst.Stride+w*8], src.Pix[i:i+w*8])
+			}
+		})
+		return dst
+	case *image.NRGBA, *image.RGBA, *image.Gray, *image.YCbCr, *image.Paletted:
+		// 8-bit images: scan them as usual and extend the values.
+		s := newScanner(img)
+		parallel(0, h, w*costCopy, func(ys <-chan int) {
+			scanLine := make([]uint8, w*4)
+			for y := range ys {
+				s.scan(0, y, w, y+1, scanLine)
+				d := dst.Pix[y*dst.Stride : y*dst.Stride+w*8]
+				for i, v := range scanLine {
+					d[i*2] = v
+					d[i*2+1] = v
+				}
+			}
+		})
+		return dst
+	}
+
+	parallel(0, h, w*costPoint, func(ys <-chan int) {
+		for y := range ys {
+			for x := 0; x < w; x++ {
+				c := color.NRGBA64Model.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA64)
+				d := dst.Pix[y*dst.Stride+x*8 : y*dst.Stride+x*8+8 : y*dst.Stride+x*8+8]
+				put16(d[0:], c.R)
+				put16(d[2:], c.G)
+				put16(d[4:], c.B)
+				put16(d[6:], c.A)
+			}
+		}
+	})
+	return dst
+}
+
+// Resize16 is like Resize but keeps 16 bits per channel and returns *image.NRGBA64.
+//
+// Example:
+//
+//	dstImage := imaging.Resize16(srcImage, 2000, 0, imaging.Lanczos)
+//
+func Resize16(img image.Image, width, height int, filter ResampleFilter) *image.NRGBA64 {
+	srcW := img.Bounds().Dx()
+	srcH := img.Bounds().Dy()
+	if width < 0 || height < 0 || (width == 0 && height == 0) || srcW <= 0 || srcH <= 0 {
+		return &image.NRGBA64{}
+	}
+
+	dstW, dstH := resizeSize(srcW, srcH, width, height)
+	src := Clone16(img)
+	if srcW == dstW && srcH == dstH {
+		return src
+	}
+	if filter.Support <= 0 {
+		return resizeNearest16(src, dstW, dstH)
+	}
+	if srcW != dstW {
+		src = resizeHorizontal16(src, dstW, filter)
+	}
+	if srcH != dstH {
+		src = resizeVertical16(src, dstH, filter)
+	}
+	return src
+}
+
+// Fit16 is like Fit but keeps 16 bits per channel and returns *image.NRGBA64.
+func Fit16(img image.Image, width, height int, filter ResampleFilter) *image.NRGBA64 {
+	maxW, maxH := width, height
+	if maxW <= 0 || maxH <= 0 {
+		return &image.NRGBA64{}
+	}
+	srcW := img.Bounds().Dx()
+	srcH := img.Bounds().Dy()
+	if srcW <= 0 || srcH <= 0 {
+		return &image.NRGBA64{}
+	}
+	if srcW <= maxW && srcH <= maxH {
+		return Clone16(img)
+	}
+	newW, newH := fitSize(srcW, srcH, maxW, maxH)
+	return Resize16(img, newW, newH, filter)
+}
+
+// resizeLine16 resamples a line of 16-bit pixels. The i-th source pixel starts
+// at src[i*step:] and the output pixels are written to dst with the same step.
+func resizeLine16(dst, src []uint8, step int, weights [][]indexWeight) {
+	for x := range weights {
+		var r, g, b, a float64
+		for _, w := range weights[x] {
+			s := src[w.index*step : w.index*step+8 : w.index*step+8]
+			aw := float64(get16(s[6:])) * w.weight
+			r += float64(get16(s[0:])) * aw
+			g += float64(get16(s[2:])) * aw
+			b += float64(get16(s[4:])) * aw
+			a += aw
+		}
+		d := dst[x*step : x*step+8 : x*step+8]
+		if a == 0 {
+			continue
+		}
+		aInv := 1 / a
+		put16(d[0:], clamp16(r*aInv))
+		put16(d[2:], clamp16(g*aInv))
+		put16(d[4:], clamp16(b*aInv))
+		put16(d[6:], clamp16(a))
+	}
+}
+
+func resizeHorizontal16(src *image.NRGBA64, width int, filter ResampleFilter) *image.NRGBA64 {
+	srcW, srcH := src.Rect.Dx(), src.Rect.Dy()
+	dst := image.NewNRGBA64(image.Rect(0, 0, width, srcH))
+	weights := cachedWeights(width, srcW, filter)
+	parallel(0, srcH, weightsCost(weights), func(ys <-chan int) {
+		for y := range ys {
+			resizeLine16(dst.Pix[y*dst.Stride:], src.Pix[y*src.Stride:], 8, weights)
+		}
+	})
+	return dst
+}
+
+func resizeVertical16(src *image.NRGBA64, height int, filter ResampleFilter) *image.NRGBA64 {
+	srcW, srcH := src.Rect.Dx(), src.Rect.Dy()
+	dst := image.NewNRGBA64(image.Rect(0, 0, srcW, height))
+	weights := cachedWeights(height, srcH, filter)
+	parallel(0, srcW, weightsCost(weights), func(xs <-chan int) {
+		for x := range xs {
+			resizeLine16(dst.Pix[x*8:], src.Pix[x*8:], src.Stride, weights)
+		}
+	})
+	return dst
+}
+
+func resizeNearest16(src *image.NRGBA64, width, height int) *image.NRGBA64 {
+	srcW, srcH := src.Rect.Dx(), src.Rect.Dy()
+	dx := float64(srcW) / float64(width)
+	dy := float64(srcH) / float64(height)
+	dst := image.NewNRGBA64(image.Rect(0, 0, width, height))
+	parallel(0, height, width*costCopy, func(ys <-chan int) {
+		for y := range ys {
+			srcY := int((float64(y) + 0.5) * dy)
+			for x := 0; x < width; x++ {
+				srcX := int((float64(x) + 0.5) * dx)
+				i := src.PixOffset(srcX, srcY)
+				j := dst.PixOffset(x, y)
+				copy(dst.Pix[j:j+8], src.Pix[i:i+8])
+			}
+		}
+	})
+	return dst
+}
+
+// AdjustGamma16 is like AdjustGamma but keeps 16 bits per channel and returns *image.NRGBA64.
+//
+// Example:
+//
+//	dstImage := imaging.AdjustGamma16(srcImage, 0.7)
+//
+func AdjustGamma16(img image.Image, gamma float64) *image.NRGBA64 {
+	dst := Clone16(img)
+	if gamma == 1 {
+		return dst
+	}
+
+	e := 1.0 / math.Max(gamma, 0.0001)
+	lut := make([]uint16, 65536)
+	for i := range lut {
+		lut[i] = clamp16(math.Pow(float64(i)/65535, e) * 65535)
+	}
+
+	w, h := dst.Rect.Dx(), dst.Rect.Dy()
+	parallel(0, h, w*costPoint, func(ys <-chan int) {
+		for y := range ys {
+			row := dst.Pix[y*dst.Stride : y*dst.Stride+w*8]
+			for i := 0; i < len(row); i += 8 {
+				put16(row[i+0:], lut[get16(row[i+0:])])
+				put16(row[i+2:], lut[get16(row[i+2:])])
+				put16(row[i+4:], lut[get16(row[i+4:])])
+			}
+		}
+	})
+	return dst
+}
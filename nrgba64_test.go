@@ -0,0 +1,104 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// to8 converts a 16-bit image to 8 bits for comparison with the 8-bit functions.
+func to8(img *image.NRGBA64) *image.NRGBA {
+	dst := image.NewNRGBA(img.Rect)
+	for i := 0; i < len(dst.Pix); i++ {
+		dst.Pix[i] = uint8((uint32(get16(img.Pix[i*2:])) + 0x80) / 0x101)
+	}
+	return dst
+}
+
+func TestClone16(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 1, 0),
+		Stride: 2 * 4,
+		Pix:    []uint8{0x00, 0x11, 0x22, 0xff, 0xff, 0x80, 0x40, 0x80},
+	}
+	got := Clone16(src)
+	want := &image.NRGBA64{
+		Rect:   image.Rect(0, 0, 2, 1),
+		Stride: 2 * 8,
+		Pix: []uint8{
+			0x00, 0x00, 0x11, 0x11, 0x22, 0x22, 0xff, 0xff,
+			0xff, 0xff, 0x80, 0x80, 0x40, 0x40, 0x80, 0x80,
+		},
+	}
+	if got.Rect != want.Rect || !compareBytes(got.Pix, want.Pix, 0) {
+		t.Fatalf("got result %#v want %#v", got, want)
+	}
+
+	src16 := image.NewNRGBA64(image.Rect(0, 0, 4, 4))
+	src16.SetNRGBA64(2, 3, color.NRGBA64{0x1234, 0x5678, 0x9abc, 0xdef0})
+	got = Clone16(src16.SubImage(image.Rect(1, 2, 4, 4)))
+	if c := got.NRGBA64At(1, 1); c != (color.NRGBA64{0x1234, 0x5678, 0x9abc, 0xdef0}) {
+		t.Fatalf("got color %v", c)
+	}
+	if got.Rect != image.Rect(0, 0, 3, 2) {
+		t.Fatalf("got bounds %v", got.Rect)
+	}
+}
+
+func TestResize16(t *testing.T) {
+	for _, f := range []ResampleFilter{NearestNeighbor, Box, Linear, Lanczos} {
+		got := to8(Resize16(testdataFlowersSmallPNG, 100, 0, f))
+		want := Resize(testdataFlowersSmallPNG, 100, 0, f)
+		if !compareNRGBA(got, want, 1) {
+			t.Fatalf("Resize16 result differs from Resize")
+		}
+		got = to8(Resize16(testdataFlowersSmallPNG, 300, 300, f))
+		want = Resize(testdataFlowersSmallPNG, 300, 300, f)
+		if !compareNRGBA(got, want, 1) {
+			t.Fatalf("Resize16 result differs from Resize")
+		}
+	}
+
+	// A subtle 16-bit gradient keeps its distinct values.
+	src := image.NewNRGBA64(image.Rect(0, 0, 64, 1))
+	for x := 0; x < 64; x++ {
+		src.SetNRGBA64(x, 0, color.NRGBA64{uint16(0x8000 + x*4), 0, 0, 0xffff})
+	}
+	got := Resize16(src, 32, 1, Linear)
+	for x := 1; x < 32; x++ {
+		if got.NRGBA64At(x, 0).R <= got.NRGBA64At(x-1, 0).R {
+			t.Fatalf("gradient is not preserved at %d: %v", x, got.Pix)
+		}
+	}
+
+	if got := Resize16(src, 0, 0, Linear); !got.Rect.Empty() {
+		t.Fatalf("got bounds %v want empty", got.Rect)
+	}
+}
+
+func TestFit16(t *testing.T) {
+	testCases := []struct {
+		w, h int
+		want image.Rectangle
+	}{
+		{150, 150, image.Rect(0, 0, 150, 100)},
+		{1000, 1000, image.Rect(0, 0, 600, 400)},
+		{0, 100, image.Rectangle{}},
+	}
+	for _, tc := range testCases {
+		got := Fit16(testdataBranchesPNG, tc.w, tc.h, Box)
+		if got.Rect != tc.want {
+			t.Fatalf("Fit16(%d, %d): got bounds %v want %v", tc.w, tc.h, got.Rect, tc.want)
+		}
+	}
+}
+
+func TestAdjustGamma16(t *testing.T) {
+	for _, g := range []float64{0.5, 1, 1.5} {
+		got := to8(AdjustGamma16(testdataFlowersSmallPNG, g))
+		want := AdjustGamma(testdataFlowersSmallPNG, g)
+		if !compareNRGBA(got, want, 1) {
+			t.Fatalf("AdjustGamma16(%v) result differs from AdjustGamma", g)
+		}
+	}
+}
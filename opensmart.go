@@ -0,0 +1,283 @@
+package imaging
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"image"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// OpenReport describes what OpenSmart and DecodeSmart found in the image data
+// and what they did to decode it.
+type OpenReport struct {
+	// Format is the name of the format detected from the content of the data, such as "PNG"
+	// or "WebP", or an empty string if the content isn't recognized. For the supported formats
+	// it's the same as Format.String. The format may be detected but not supported, in this
+	// case the error is returned along with the report.
+	Format string
+
+	// ExtensionMismatch is true if the extension of the filename doesn't match
+	// the detected format, e.g. a PNG image saved as "photo.jpg".
+	ExtensionMismatch bool
+
+	// Filename is the filename with the extension replaced by the one of the detected
+	// format if the extension doesn't match, otherwise it's the original filename.
+	Filename string
+
+	// Repaired is true if the data is truncated or damaged and the image was decoded
+	// in the tolerant mode. The missing part of the image is transparent (black
+	// if the image has no alpha channel) or filled with artifacts.
+	Repaired bool
+
+	// DecodeErr is the error of decoding the original data if the image was Repaired.
+	DecodeErr error
+}
+
+// sniffedFormat is a format recognized by its magic bytes.
+type sniffedFormat struct {
+	name  string
+	ext   string // The canonical extension.
+	match func(data []byte) bool
+}
+
+var sniffedFormats = []sniffedFormat{
+	{"JPEG", "jpg", func(data []byte) bool { return bytes.HasPrefix(data, []byte("\xff\xd8\xff")) }},
+	{"PNG", "png", func(data []byte) bool { return bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")) }},
+	{"GIF", "gif", func(data []byte) bool {
+		return bytes.HasPrefix(data, []byte("GIF87a")) || bytes.HasPrefix(data, []byte("GIF89a"))
+	}},
+	{"TIFF", "tif", func(data []byte) bool {
+		return bytes.HasPrefix(data, []byte("II\x2a\x00")) || bytes.HasPrefix(data, []byte("MM\x00\x2a"))
+	}},
+	{"BMP", "bmp", func(data []byte) bool { return bytes.HasPrefix(data, []byte("BM")) }},
+	{"WebP", "webp", func(data []byte) bool {
+		return len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP"
+	}},
+	{"AVIF", "avif", func(data []byte) bool { return isftypBrand(data, "avif", "avis") }},
+	{"HEIF", "heic", func(data []byte) bool { return isftypBrand(data, "heic", "heix", "mif1", "msf1") }},
+}
+
+// isftypBrand reports whether the data is an ISO base media file with one of the major brands.
+func isftypBrand(data []byte, brands ...string) bool {
+	if len(data) < 12 || string(data[4:8]) != "ftyp" {
+		return false
+	}
+	for _, b := range brands {
+		if string(data[8:12]) == b {
+			return true
+		}
+	}
+	return false
+}
+
+// OpenSmart loads an image from file like Open, but it doesn't trust the file:
+// the format is detected from the content regardless of the extension and reported
+// along with a fixed filename if the extension is wrong, and if the image fails to decode,
+// it's decoded again in the tolerant mode that recovers the truncated baseline JPEG and
+// non-interlaced PNG files, such as the interrupted uploads. The report is returned
+// whenever the file could be read, even if the image couldn't be decoded.
+//
+// Example:
+//
+//	img, report, err := imaging.OpenSmart("uploads/photo.jpg")
+//	if err != nil {
+//		log.Printf("failed to open the %s image: %v", report.Format, err)
+//	}
+//	if report.ExtensionMismatch {
+//		os.Rename("uploads/photo.jpg", report.Filename)
+//	}
+//
+func OpenSmart(filename string, opts ...DecodeOption) (image.Image, *OpenReport, error) {
+	file, err := fs.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+	return DecodeSmart(file, filename, opts...)
+}
+
+// DecodeSmart is like OpenSmart but reads the image from r. The filename is only used
+// to check the extension, it may be empty.
+//
+// Example:
+//
+//	file, header, err := r.FormFile("image")
+//	...
+//	img, report, err := imaging.DecodeSmart(file, header.Filename)
+//
+func DecodeSmart(r io.Reader, filename string, opts ...DecodeOption) (image.Image, *OpenReport, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	report := &OpenReport{Filename: filename}
+	var sniffed *sniffedFormat
+	for i := range sniffedFormats {
+		if sniffedFormats[i].match(data) {
+			sniffed = &sniffedFormats[i]
+			report.Format = sniffed.name
+			break
+		}
+	}
+	if sniffed != nil && filename != "" && !extensionMatches(filename, sniffed) {
+		report.ExtensionMismatch = true
+		report.Filename = strings.TrimSuffix(filename, filepath.Ext(filename)) + "." + sniffed.ext
+	}
+
+	img, err := Decode(bytes.NewReader(data), opts...)
+	if err == nil || err == ErrLimitExceeded || sniffed == nil {
+		return img, report, err
+	}
+
+	var repaired []byte
+	switch sniffed.name {
+	case "JPEG":
+		repaired = repairJPEG(data)
+	case "PNG":
+		repaired = repairPNG(data)
+	}
+	if repaired == nil {
+		return nil, report, err
+	}
+	img, rerr := Decode(bytes.NewReader(repaired), opts...)
+	if rerr != nil {
+		return nil, report, err
+	}
+	report.Repaired = true
+	report.DecodeErr = err
+	return img, report, nil
+}
+
+// extensionMatches reports whether the extension of the filename is one of the extensions
+// of the format.
+func extensionMatches(filename string, sniffed *sniffedFormat) bool {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(filename), "."))
+	if ext == sniffed.ext {
+		return true
+	}
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+	f, ok := formatExts[ext]
+	return ok && strings.EqualFold(formatNames[f], sniffed.name)
+}
+
+// repairJPEG returns the JPEG data padded with enough zero bytes to complete
+// the entropy-coded data of the truncated baseline image, followed by the EOI marker.
+// The zero bits decode to artifacts, but the data before the truncation is kept.
+// It returns nil if the data isn't repairable.
+func repairJPEG(data []byte) []byte {
+	w, h, ok := jpegFrameSize(data)
+	if !ok || bytes.HasSuffix(data, []byte{0xff, 0xd9}) {
+		return nil
+	}
+	// All-zero bits take at most 3 bits per coefficient, 24 bytes per 8x8 block
+	// of each of the up to 3 components at the full resolution.
+	padding := ((w + 7) / 8) * ((h + 7) / 8) * 3 * 25
+	repaired := make([]byte, len(data), len(data)+padding+2)
+	copy(repaired, data)
+	repaired = append(repaired, make([]byte, padding)...)
+	return append(repaired, 0xff, 0xd9)
+}
+
+// jpegFrameSize returns the image size from the baseline SOF segment of the JPEG data.
+func jpegFrameSize(data []byte) (int, int, bool) {
+	i := 2
+	for i+4 <= len(data) {
+		if data[i] != 0xff {
+			return 0, 0, false
+		}
+		marker := data[i+1]
+		if marker == 0xda || marker == 0xd9 {
+			return 0, 0, false
+		}
+		size := int(binary.BigEndian.Uint16(data[i+2:]))
+		if size < 2 || i+2+size > len(data) {
+			return 0, 0, false
+		}
+		if marker == 0xc0 || marker == 0xc1 { // Baseline and extended sequential DCT.
+			if size < 8 {
+				return 0, 0, false
+			}
+			seg := data[i+4:]
+			return int(binary.BigEndian.Uint16(seg[3:])), int(binary.BigEndian.Uint16(seg[1:])), true
+		}
+		if marker >= 0xc2 && marker <= 0xcf && marker != 0xc4 && marker != 0xc8 && marker != 0xcc {
+			return 0, 0, false // Progressive, lossless and arithmetic coding aren't repairable.
+		}
+		i += 2 + size
+	}
+	return 0, 0, false
+}
+
+// repairPNG returns the non-interlaced PNG data rebuilt from the image data that
+// survived the truncation or the damage of the IDAT chunks, with the missing rows
+// filled with zeros (transparent or black), or nil if the data isn't repairable.
+func repairPNG(data []byte) []byte {
+	out := append([]byte(nil), data[:8]...)
+	var idat []byte
+	var rowSize, height int
+	i := 8
+	for i+8 <= len(data) {
+		size := int(binary.BigEndian.Uint32(data[i:]))
+		name := string(data[i+4 : i+8])
+		if size < 0 || size > len(data)-i-8 {
+			size = len(data) - i - 8 // The truncated chunk.
+		}
+		payload := data[i+8 : i+8+size]
+		i += 12 + size
+
+		switch name {
+		case "IHDR":
+			if size < 13 || payload[12] != 0 { // Interlaced images aren't repairable.
+				return nil
+			}
+			channels := map[byte]int{0: 1, 2: 3, 3: 1, 4: 2, 6: 4}[payload[9]]
+			width := int(binary.BigEndian.Uint32(payload))
+			height = int(binary.BigEndian.Uint32(payload[4:]))
+			rowSize = 1 + (width*channels*int(payload[8])+7)/8
+		case "IDAT":
+			idat = append(idat, payload...)
+			continue
+		case "IEND":
+			continue
+		default:
+			if idat != nil {
+				continue // The chunks after the image data aren't needed.
+			}
+		}
+		if i > len(data) {
+			return nil
+		}
+		out = append(out, data[i-12-size:i]...)
+	}
+	if rowSize <= 1 || height <= 0 || idat == nil {
+		return nil
+	}
+
+	// Keep the rows that were decompressed before the error.
+	zr, err := zlib.NewReader(bytes.NewReader(idat))
+	if err != nil {
+		return nil
+	}
+	raw, _ := ioutil.ReadAll(io.LimitReader(zr, int64(rowSize)*int64(height)))
+	raw = raw[:len(raw)/rowSize*rowSize]
+	if len(raw) == rowSize*height {
+		return nil // The image data is intact, the failure is elsewhere.
+	}
+
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write(raw)
+	zeros := make([]byte, rowSize)
+	for y := len(raw) / rowSize; y < height; y++ {
+		zw.Write(zeros)
+	}
+	zw.Close()
+	out = appendPNGChunk(out, "IDAT", buf.Bytes())
+	return appendPNGChunk(out, "IEND", nil)
+}
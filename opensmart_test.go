@@ -0,0 +1,127 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenSmart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imaging")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := Clone(testdataFlowersSmallPNG)
+	data, err := EncodeBytes(src, PNG)
+	if err != nil {
+		t.Fatalf("EncodeBytes: %v", err)
+	}
+	filename := filepath.Join(dir, "photo.JPG")
+	if err := ioutil.WriteFile(filename, data, 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	img, report, err := OpenSmart(filename)
+	if err != nil {
+		t.Fatalf("OpenSmart: %v", err)
+	}
+	want := OpenReport{Format: "PNG", ExtensionMismatch: true, Filename: filepath.Join(dir, "photo.png")}
+	if *report != want {
+		t.Fatalf("got report %+v want %+v", *report, want)
+	}
+	if !Equal(img, src) {
+		t.Fatalf("the decoded image differs")
+	}
+
+	if _, report, err := OpenSmart(filepath.Join(dir, "missing.png")); err == nil || report != nil {
+		t.Fatalf("got report %v and error %v for the missing file", report, err)
+	}
+}
+
+func TestDecodeSmart(t *testing.T) {
+	jpegData, err := ioutil.ReadFile("testdata/branches.jpg")
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	testCases := []struct {
+		name     string
+		data     []byte
+		filename string
+		want     OpenReport
+		wantErr  bool
+	}{
+		{"jpeg", jpegData, "a.jpeg", OpenReport{Format: "JPEG", Filename: "a.jpeg"}, false},
+		{"no filename", jpegData, "", OpenReport{Format: "JPEG"}, false},
+		{"no extension", jpegData, "upload", OpenReport{Format: "JPEG", ExtensionMismatch: true, Filename: "upload.jpg"}, false},
+		{"webp", []byte("RIFF\x24\x00\x00\x00WEBPVP8 "), "a.jpg", OpenReport{Format: "WebP", ExtensionMismatch: true, Filename: "a.webp"}, true},
+		{"unknown", []byte("not an image"), "a.png", OpenReport{Filename: "a.png"}, true},
+		{"broken header", jpegData[:100], "a.jpg", OpenReport{Format: "JPEG", Filename: "a.jpg"}, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, report, err := DecodeSmart(bytes.NewReader(tc.data), tc.filename)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got error %v", err)
+			}
+			if report == nil || *report != tc.want {
+				t.Fatalf("got report %+v want %+v", report, tc.want)
+			}
+		})
+	}
+}
+
+func TestDecodeSmartRepair(t *testing.T) {
+	jpegData, err := ioutil.ReadFile("testdata/branches.jpg")
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	orig, err := Decode(bytes.NewReader(jpegData))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	img, report, err := DecodeSmart(bytes.NewReader(jpegData[:len(jpegData)/2]), "a.jpg")
+	if err != nil {
+		t.Fatalf("DecodeSmart: %v", err)
+	}
+	if !report.Repaired || report.DecodeErr == nil {
+		t.Fatalf("got report %+v", report)
+	}
+	top := image.Rect(0, 0, 600, 100)
+	if d := meanAbsDiff(Crop(img, top), Crop(orig, top)); d != 0 {
+		t.Fatalf("got mean difference %.2f of the intact part", d)
+	}
+
+	src := Clone(testdataFlowersSmallPNG)
+	pngData, err := EncodeBytes(src, PNG)
+	if err != nil {
+		t.Fatalf("EncodeBytes: %v", err)
+	}
+	img, report, err = DecodeSmart(bytes.NewReader(pngData[:len(pngData)/2]), "a.png")
+	if err != nil {
+		t.Fatalf("DecodeSmart: %v", err)
+	}
+	if !report.Repaired || img.Bounds() != src.Rect {
+		t.Fatalf("got report %+v, bounds %v", report, img.Bounds())
+	}
+	top = image.Rect(0, 0, 240, 40)
+	if !Equal(Crop(img, top), Crop(src, top)) {
+		t.Fatalf("the intact part differs")
+	}
+	// The image has no alpha channel, the missing rows are black.
+	if c := Clone(img).NRGBAAt(100, 159); c != (color.NRGBA{0, 0, 0, 255}) {
+		t.Fatalf("got color %v of the missing part", c)
+	}
+
+	// The complete files and the progressive JPEG files aren't repaired.
+	if repairJPEG(jpegData) != nil || repairPNG(pngData) != nil {
+		t.Fatalf("the complete files are repaired")
+	}
+	if _, _, ok := jpegFrameSize([]byte("\xff\xd8\xff\xc2\x00\x0b\x08\x00\x10\x00\x10\x01\x01\x11\x00")); ok {
+		t.Fatalf("got the size of the progressive JPEG")
+	}
+}
@@ -0,0 +1,156 @@
+package imaging
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"reflect"
+)
+
+// OpVersion is the version of the operations created by ResizeOp, FitOp, BlurOp and SharpenOp.
+// It is a part of the serialized operations and of their fingerprints, and it changes whenever
+// a change of the package changes the results of any of these operations, so that the images
+// derived and cached by an older version of the package are not mistaken for the current ones.
+const OpVersion = "1"
+
+var (
+	// ErrOpNotSerializable means that the operation is not created by one of the Op
+	// constructors of this package (for example, it is a FuncOp), or that it uses a custom
+	// resampling filter.
+	ErrOpNotSerializable = errors.New("imaging: operation can't be serialized")
+
+	// ErrOpVersion means that the serialized operation has a version other than OpVersion.
+	ErrOpVersion = errors.New("imaging: unsupported operation version")
+)
+
+// opSpec is the serialized form of an operation.
+type opSpec struct {
+	Version string  `json:"version"`
+	Op      string  `json:"op"`
+	Width   int     `json:"width,omitempty"`
+	Height  int     `json:"height,omitempty"`
+	Filter  string  `json:"filter,omitempty"`
+	Sigma   float64 `json:"sigma,omitempty"`
+}
+
+// namedFilters are the predefined resampling filters with their names used in the serialized
+// operations. The names must not change.
+var namedFilters = []struct {
+	name   string
+	filter *ResampleFilter
+}{
+	{"nearest", &NearestNeighbor},
+	{"box", &Box},
+	{"linear", &Linear},
+	{"hermite", &Hermite},
+	{"mitchellnetravali", &MitchellNetravali},
+	{"catmullrom", &CatmullRom},
+	{"bspline", &BSpline},
+	{"gaussian", &Gaussian},
+	{"bartlett", &Bartlett},
+	{"lanczos", &Lanczos},
+	{"hann", &Hann},
+	{"hamming", &Hamming},
+	{"blackman", &Blackman},
+	{"welch", &Welch},
+	{"cosine", &Cosine},
+}
+
+// filterName returns the name of the predefined filter, or "" for a custom one.
+func filterName(filter ResampleFilter) string {
+	kernel := reflect.ValueOf(filter.Kernel).Pointer()
+	for _, f := range namedFilters {
+		if f.filter.Support == filter.Support && reflect.ValueOf(f.filter.Kernel).Pointer() == kernel {
+			return f.name
+		}
+	}
+	return ""
+}
+
+// MarshalJSON encodes the parameters of the operation together with OpVersion.
+// Only the operations created by ResizeOp, FitOp, BlurOp and SharpenOp with
+// the predefined resampling filters can be serialized, otherwise ErrOpNotSerializable
+// is returned.
+//
+// Example:
+//
+//	data, err := json.Marshal([]imaging.Op{
+//		imaging.FitOp(800, 800, imaging.Lanczos),
+//		imaging.SharpenOp(0.5),
+//	})
+//
+func (op Op) MarshalJSON() ([]byte, error) {
+	spec := op.spec
+	if spec.Op == "" || ((spec.Op == "resize" || spec.Op == "fit") && spec.Filter == "") {
+		return nil, ErrOpNotSerializable
+	}
+	spec.Version = OpVersion
+	return json.Marshal(spec)
+}
+
+// UnmarshalJSON decodes an operation encoded by MarshalJSON. It returns ErrOpVersion
+// if the operation was encoded with a different OpVersion, as it may give different
+// results with this version of the package.
+func (op *Op) UnmarshalJSON(data []byte) error {
+	var spec opSpec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return err
+	}
+	if spec.Version != OpVersion {
+		return ErrOpVersion
+	}
+
+	var filter *ResampleFilter
+	if spec.Op == "resize" || spec.Op == "fit" {
+		for _, f := range namedFilters {
+			if f.name == spec.Filter {
+				filter = f.filter
+			}
+		}
+		if filter == nil {
+			return errors.New("imaging: unknown resampling filter")
+		}
+	}
+	switch spec.Op {
+	case "resize":
+		*op = ResizeOp(spec.Width, spec.Height, *filter)
+	case "fit":
+		*op = FitOp(spec.Width, spec.Height, *filter)
+	case "blur":
+		*op = BlurOp(spec.Sigma)
+	case "sharpen":
+		*op = SharpenOp(spec.Sigma)
+	default:
+		return errors.New("imaging: unknown operation")
+	}
+	return nil
+}
+
+// Fingerprint returns a stable key of the sequence of the operations that can be used to cache
+// the images derived by them: the hex-encoded SHA-256 hash of their serialized parameters
+// and OpVersion. The key changes when the parameters or OpVersion change. It returns
+// ErrOpNotSerializable if any of the operations can't be serialized.
+//
+// Example:
+//
+//	key, err := imaging.Fingerprint(imaging.FitOp(800, 800, imaging.Lanczos), imaging.SharpenOp(0.5))
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	cacheKey := sourceKey + "/" + key
+//
+func Fingerprint(ops ...Op) (string, error) {
+	if ops == nil {
+		ops = []Op{}
+	}
+	data, err := json.Marshal(ops)
+	if err != nil {
+		if errors.Is(err, ErrOpNotSerializable) {
+			return "", ErrOpNotSerializable
+		}
+		return "", err
+	}
+	sum := sha256.Sum256(append([]byte(OpVersion+"\n"), data...))
+	return hex.EncodeToString(sum[:]), nil
+}
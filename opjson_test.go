@@ -0,0 +1,99 @@
+package imaging
+
+import (
+	"encoding/json"
+	"errors"
+	"image"
+	"testing"
+)
+
+func TestOpJSON(t *testing.T) {
+	ops := []Op{
+		ResizeOp(100, 0, Lanczos),
+		FitOp(50, 40, NearestNeighbor),
+		BlurOp(1.5),
+		SharpenOp(0.5),
+	}
+	data, err := json.Marshal(ops)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	want := `[{"version":"1","op":"resize","width":100,"filter":"lanczos"},` +
+		`{"version":"1","op":"fit","width":50,"height":40,"filter":"nearest"},` +
+		`{"version":"1","op":"blur","sigma":1.5},{"version":"1","op":"sharpen","sigma":0.5}]`
+	if string(data) != want {
+		t.Fatalf("got %s want %s", data, want)
+	}
+
+	var got []Op
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if len(got) != len(ops) {
+		t.Fatalf("got %d operations want %d", len(got), len(ops))
+	}
+	for i, op := range got {
+		if op.Name != ops[i].Name {
+			t.Fatalf("got operation %q want %q", op.Name, ops[i].Name)
+		}
+		if !compareNRGBA(op.Apply(testdataBranchesPNG), ops[i].Apply(testdataBranchesPNG), 0) {
+			t.Fatalf("the operation %q gives a different result", op.Name)
+		}
+	}
+}
+
+func TestOpJSONErrors(t *testing.T) {
+	custom := ResampleFilter{Support: 1, Kernel: func(x float64) float64 { return 1 - x }}
+	for _, op := range []Op{
+		FuncOp("invert", func(img image.Image) *image.NRGBA { return Invert(img) }),
+		ResizeOp(10, 10, custom),
+	} {
+		if _, err := json.Marshal(op); !errors.Is(err, ErrOpNotSerializable) {
+			t.Fatalf("got error %v for %q", err, op.Name)
+		}
+	}
+
+	var op Op
+	testCases := []struct {
+		data string
+		want error
+	}{
+		{`{"version":"0","op":"blur","sigma":1}`, ErrOpVersion},
+		{`{"version":"1","op":"rotate"}`, nil},
+		{`{"version":"1","op":"resize","width":10,"filter":"custom"}`, nil},
+		{`{"version":"1","op":"fit","width":10}`, nil},
+	}
+	for _, tc := range testCases {
+		err := json.Unmarshal([]byte(tc.data), &op)
+		if err == nil || (tc.want != nil && !errors.Is(err, tc.want)) {
+			t.Fatalf("got error %v for %s", err, tc.data)
+		}
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	a, err := Fingerprint(FitOp(800, 800, Lanczos), SharpenOp(0.5))
+	if err != nil {
+		t.Fatalf("Fingerprint: %v", err)
+	}
+	if len(a) != 64 {
+		t.Fatalf("got fingerprint %q", a)
+	}
+	if b, _ := Fingerprint(FitOp(800, 800, Lanczos), SharpenOp(0.5)); b != a {
+		t.Fatalf("got different fingerprints %q and %q for the same operations", a, b)
+	}
+	for _, ops := range [][]Op{
+		{FitOp(800, 800, Lanczos)},
+		{FitOp(800, 800, Linear), SharpenOp(0.5)},
+		{SharpenOp(0.5), FitOp(800, 800, Lanczos)},
+		{ResizeOp(800, 800, Lanczos), SharpenOp(0.5)},
+		nil,
+	} {
+		if b, err := Fingerprint(ops...); err != nil || b == a {
+			t.Fatalf("got fingerprint %q, %v for %v", b, err, ops)
+		}
+	}
+	if _, err := Fingerprint(FuncOp("nop", Clone)); !errors.Is(err, ErrOpNotSerializable) {
+		t.Fatalf("got error %v", err)
+	}
+}
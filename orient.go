@@ -0,0 +1,157 @@
+package imaging
+
+import (
+	"image"
+	"math"
+)
+
+// OrientationSuggestion is the result of SuggestOrientation.
+type OrientationSuggestion struct {
+	// Angle is the counter-clockwise rotation in degrees (0, 90, 180 or 270) that
+	// probably makes the image upright, e.g. 90 means that Rotate90 should be applied.
+	Angle int
+
+	// Confidence is the confidence in Angle, from 0 (a guess) to 1.
+	Confidence float64
+
+	// Sideways is the confidence, from 0 to 1, that the image is rotated by
+	// 90 degrees in either direction, which is the most common error of cameras
+	// that don't record the orientation.
+	Sideways float64
+}
+
+// SuggestOrientation guesses the orientation of a photo that has no EXIF orientation
+// using content heuristics. It combines two cues: the sky or the ceiling is usually
+// brighter and bluer than the ground, and the lines of text and other horizontal
+// structures give a stronger row profile than column profile. The heuristics are not
+// reliable enough to rotate the images automatically, but are good at flagging
+// probably sideways images for human review.
+//
+// Example:
+//
+//	s := imaging.SuggestOrientation(img)
+//	if s.Sideways > 0.5 {
+//		flagForReview(img, s.Angle)
+//	}
+//
+func SuggestOrientation(img image.Image) OrientationSuggestion {
+	small := Fit(img, 128, 128, Box)
+	w, h := small.Rect.Dx(), small.Rect.Dy()
+	if w < 4 || h < 4 {
+		return OrientationSuggestion{}
+	}
+
+	lum := make([]float64, w*h)
+	blue := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*small.Stride + x*4
+			s := small.Pix[i : i+4 : i+4]
+			r, g, b := float64(s[0])/255, float64(s[1])/255, float64(s[2])/255
+			lum[y*w+x] = 0.299*r + 0.587*g + 0.114*b
+			blue[y*w+x] = math.Max(b-r, 0)
+		}
+	}
+
+	// The sky cue: the score of each side (top, right, bottom and left) being up.
+	// The side order matches the rotation angles 0, 90, 180 and 270.
+	var sides [4]float64
+	var counts [4]int
+	bw, bh := w/4, h/4
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := lum[y*w+x] + 0.5*blue[y*w+x]
+			if y < bh {
+				sides[0] += v
+				counts[0]++
+			}
+			if x >= w-bw {
+				sides[1] += v
+				counts[1]++
+			}
+			if y >= h-bh {
+				sides[2] += v
+				counts[2]++
+			}
+			if x < bw {
+				sides[3] += v
+				counts[3]++
+			}
+		}
+	}
+	for i := range sides {
+		sides[i] /= float64(counts[i])
+	}
+
+	// The text cue: the edge density varies between the lines of text and the gaps,
+	// so the profile along the lines is more uneven than the profile across them.
+	rows := make([]float64, h)
+	cols := make([]float64, w)
+	var edges float64
+	for y := 0; y < h-1; y++ {
+		for x := 0; x < w-1; x++ {
+			l := lum[y*w+x]
+			e := math.Abs(lum[y*w+x+1]-l) + math.Abs(lum[(y+1)*w+x]-l)
+			rows[y] += e
+			cols[x] += e
+			edges += e
+		}
+	}
+	rowCV, colCV := variation(rows[:h-1]), variation(cols[:w-1])
+	var textUpright float64
+	if m := math.Max(rowCV, colCV); m > 0 {
+		strength := math.Min(edges/float64((w-1)*(h-1))/0.05, 1)
+		textUpright = (rowCV - colCV) / m * strength
+	}
+
+	const textWeight = 0.2
+	var scores [4]float64
+	for i := range scores {
+		scores[i] = sides[i]
+		if i%2 == 0 {
+			scores[i] += textWeight * textUpright
+		} else {
+			scores[i] -= textWeight * textUpright
+		}
+	}
+
+	best := 0
+	for i := range scores {
+		if scores[i] > scores[best] {
+			best = i
+		}
+	}
+	second := math.Inf(-1)
+	for i := range scores {
+		if i != best && scores[i] > second {
+			second = scores[i]
+		}
+	}
+
+	const margin = 0.2
+	confidence := func(d float64) float64 {
+		return math.Min(math.Max(d/margin, 0), 1)
+	}
+	sideways := math.Max(scores[1], scores[3]) - math.Max(scores[0], scores[2])
+	return OrientationSuggestion{
+		Angle:      best * 90,
+		Confidence: confidence(scores[best] - second),
+		Sideways:   confidence(sideways),
+	}
+}
+
+// variation returns the coefficient of variation (the standard deviation divided
+// by the mean) of the values.
+func variation(values []float64) float64 {
+	var sum, sum2 float64
+	for _, v := range values {
+		sum += v
+		sum2 += v * v
+	}
+	n := float64(len(values))
+	mean := sum / n
+	if mean <= 0 {
+		return 0
+	}
+	return math.Sqrt(math.Max(sum2/n-mean*mean, 0)) / mean
+}
@@ -0,0 +1,78 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// makeLandscape returns a synthetic photo with a blue sky above a dark ground.
+func makeLandscape() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 160, 120))
+	for y := 0; y < 120; y++ {
+		c := color.NRGBA{0x40, 0x50, 0x30, 0xff}
+		if y < 50 {
+			c = color.NRGBA{0x80, 0xb0, 0xf0, 0xff}
+		}
+		for x := 0; x < 160; x++ {
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// makeTextPage returns a synthetic page with horizontal lines of "words".
+func makeTextPage() *image.NRGBA {
+	img := New(160, 200, color.White)
+	for y := 10; y < 190; y += 12 {
+		for x := 10; x < 150; x += 2 {
+			if (x/2)%9 == 8 {
+				continue
+			}
+			for dy := 0; dy < 6; dy += 2 {
+				img.SetNRGBA(x, y+dy, color.NRGBA{0, 0, 0, 0xff})
+			}
+		}
+	}
+	return img
+}
+
+func TestSuggestOrientation(t *testing.T) {
+	landscape := makeLandscape()
+	page := makeTextPage()
+	testCases := []struct {
+		name     string
+		img      image.Image
+		angles   []int
+		sideways bool
+	}{
+		{"landscape", landscape, []int{0}, false},
+		{"landscape rotated 90", Rotate90(landscape), []int{270}, true},
+		{"landscape rotated 180", Rotate180(landscape), []int{180}, false},
+		{"landscape rotated 270", Rotate270(landscape), []int{90}, true},
+		{"page", page, []int{0, 180}, false},
+		{"page rotated 90", Rotate90(page), []int{90, 270}, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := SuggestOrientation(tc.img)
+			ok := false
+			for _, a := range tc.angles {
+				ok = ok || got.Angle == a
+			}
+			if !ok {
+				t.Fatalf("got angle %d want one of %v", got.Angle, tc.angles)
+			}
+			if (got.Sideways > 0.5) != tc.sideways {
+				t.Fatalf("got sideways confidence %v", got.Sideways)
+			}
+		})
+	}
+
+	if got := SuggestOrientation(New(100, 100, color.Gray{0x80})); got.Confidence != 0 || got.Sideways != 0 {
+		t.Fatalf("got %+v for a uniform image", got)
+	}
+	if got := SuggestOrientation(&image.NRGBA{}); got != (OrientationSuggestion{}) {
+		t.Fatalf("got %+v for an empty image", got)
+	}
+}
@@ -0,0 +1,77 @@
+package imaging
+
+import (
+	"image"
+)
+
+// ResizePaletted is like Resize but returns a paletted image using the palette of the source,
+// so that the resized GIF and paletted PNG images keep their colors and file size.
+// The resampled image is mapped back to the palette with the Floyd-Steinberg dithering.
+// The pixels that become less than half opaque are transparent, and the transparent color
+// is added to the palette if it has none. With the NearestNeighbor filter the palette
+// indices are copied and the colors don't change at all.
+//
+// Example:
+//
+//	dstImage := imaging.ResizePaletted(srcImage, 100, 0, imaging.Lanczos)
+//
+func ResizePaletted(img *image.Paletted, width, height int, filter ResampleFilter) *image.Paletted {
+	srcW, srcH := img.Rect.Dx(), img.Rect.Dy()
+	if width < 0 || height < 0 || (width == 0 && height == 0) || srcW <= 0 || srcH <= 0 {
+		return &image.Paletted{Palette: img.Palette}
+	}
+
+	dstW, dstH := resizeSize(srcW, srcH, width, height)
+	if filter.Support <= 0 || (srcW == dstW && srcH == dstH) {
+		return resizeNearestPaletted(img, dstW, dstH)
+	}
+	return quantizeGIFFrame(Resize(img, dstW, dstH, filter), img.Palette, true)
+}
+
+// FitPaletted is like Fit but returns a paletted image using the palette of the source.
+// See ResizePaletted for the details.
+func FitPaletted(img *image.Paletted, width, height int, filter ResampleFilter) *image.Paletted {
+	srcW, srcH := img.Rect.Dx(), img.Rect.Dy()
+	if width <= 0 || height <= 0 || srcW <= 0 || srcH <= 0 {
+		return &image.Paletted{Palette: img.Palette}
+	}
+	if srcW <= width && srcH <= height {
+		return resizeNearestPaletted(img, srcW, srcH)
+	}
+	newW, newH := fitSize(srcW, srcH, width, height)
+	return ResizePaletted(img, newW, newH, filter)
+}
+
+// ThumbnailPaletted is like Thumbnail but returns a paletted image using the palette
+// of the source. See ResizePaletted for the details.
+//
+// Example:
+//
+//	dstImage := imaging.ThumbnailPaletted(srcImage, 100, 100, imaging.Lanczos)
+//
+func ThumbnailPaletted(img *image.Paletted, width, height int, filter ResampleFilter) *image.Paletted {
+	if width <= 0 || height <= 0 || img.Rect.Empty() {
+		return &image.Paletted{Palette: img.Palette}
+	}
+	return quantizeGIFFrame(Thumbnail(img, width, height, filter), img.Palette, true)
+}
+
+// resizeNearestPaletted resizes the image using the nearest neighbor sampling of the palette
+// indices. The result shares the palette of the source.
+func resizeNearestPaletted(src *image.Paletted, width, height int) *image.Paletted {
+	srcW, srcH := src.Rect.Dx(), src.Rect.Dy()
+	dx := float64(srcW) / float64(width)
+	dy := float64(srcH) / float64(height)
+	dst := image.NewPaletted(image.Rect(0, 0, width, height), src.Palette)
+	parallel(0, height, width*costCopy, func(ys <-chan int) {
+		for y := range ys {
+			srcY := int((float64(y) + 0.5) * dy)
+			i := src.PixOffset(src.Rect.Min.X, src.Rect.Min.Y+srcY)
+			for x := 0; x < width; x++ {
+				srcX := int((float64(x) + 0.5) * dx)
+				dst.Pix[y*dst.Stride+x] = src.Pix[i+srcX]
+			}
+		}
+	})
+	return dst
+}
@@ -0,0 +1,79 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestResizePaletted(t *testing.T) {
+	src := palettedImage(testdataFlowersSmallPNG, 64, nil, nil)
+
+	got := ResizePaletted(src, 120, 0, Lanczos)
+	if got.Rect != image.Rect(0, 0, 120, 80) {
+		t.Fatalf("got bounds %v", got.Rect)
+	}
+	if len(got.Palette) != len(src.Palette) {
+		t.Fatalf("got %d colors want %d", len(got.Palette), len(src.Palette))
+	}
+	// The dithering preserves the local average colors.
+	want := Resize(src, 120, 0, Lanczos)
+	if d := meanAbsDiff(Blur(got, 1.5), Blur(want, 1.5)); d > 3 {
+		t.Fatalf("got mean difference %.2f", d)
+	}
+
+	// The nearest neighbor resizing copies the indices.
+	got = ResizePaletted(src, 100, 70, NearestNeighbor)
+	if !compareNRGBA(Clone(got), Resize(src, 100, 70, NearestNeighbor), 0) {
+		t.Fatalf("the nearest neighbor result differs from Resize")
+	}
+	sub := src.SubImage(image.Rect(10, 20, 50, 60)).(*image.Paletted)
+	if !compareNRGBA(Clone(ResizePaletted(sub, 20, 20, NearestNeighbor)), Resize(sub, 20, 20, NearestNeighbor), 0) {
+		t.Fatalf("the nearest neighbor result differs from Resize for the subimage")
+	}
+
+	if got := ResizePaletted(src, 0, 0, Lanczos); !got.Rect.Empty() {
+		t.Fatalf("got bounds %v", got.Rect)
+	}
+}
+
+func TestResizePalettedTransparent(t *testing.T) {
+	pal := color.Palette{color.Transparent, color.NRGBA{255, 0, 0, 255}, color.NRGBA{0, 0, 255, 255}}
+	src := image.NewPaletted(image.Rect(0, 0, 40, 40), pal)
+	for y := 0; y < 40; y++ {
+		for x := 0; x < 40; x++ {
+			if x >= 20 {
+				src.SetColorIndex(x, y, uint8(1+y/20))
+			}
+		}
+	}
+
+	got := ResizePaletted(src, 20, 20, Linear)
+	if len(got.Palette) != 3 {
+		t.Fatalf("got palette %v", got.Palette)
+	}
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 20; x++ {
+			want := uint8(0)
+			if x >= 10 {
+				want = uint8(1 + y/10)
+			}
+			if x != 9 && x != 10 && y != 9 && y != 10 && got.ColorIndexAt(x, y) != want {
+				t.Fatalf("got index %d at (%d, %d) want %d", got.ColorIndexAt(x, y), x, y, want)
+			}
+		}
+	}
+}
+
+func TestFitThumbnailPaletted(t *testing.T) {
+	src := palettedImage(testdataFlowersSmallPNG, 16, nil, nil)
+	if got := FitPaletted(src, 300, 300, Lanczos); !compareBytes(got.Pix, src.Pix, 0) {
+		t.Fatalf("the image changed")
+	}
+	if got := FitPaletted(src, 60, 60, Lanczos); got.Rect != image.Rect(0, 0, 60, 40) {
+		t.Fatalf("got bounds %v", got.Rect)
+	}
+	if got := ThumbnailPaletted(src, 50, 50, Lanczos); got.Rect != image.Rect(0, 0, 50, 50) || len(got.Palette) != 16 {
+		t.Fatalf("got bounds %v with %d colors", got.Rect, len(got.Palette))
+	}
+}
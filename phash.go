@@ -0,0 +1,136 @@
+package imaging
+
+import (
+	"image"
+	"math"
+	"math/bits"
+	"slices"
+)
+
+// The perceptual hashes summarize the appearance of an image in 64 bits, so that similar
+// images, such as the resized, recompressed or slightly edited copies, get hashes with
+// a small HammingDistance, usually less than 10, while the distance of the unrelated images
+// is about 32. The hashes are computed from the luminance of the image composited over black.
+// The bits of a hash are set in the row order of the hash grid starting from the most
+// significant bit.
+
+// AverageHash returns the average hash (aHash) of the image: the image is reduced
+// to 8x8 pixels and each bit tells whether the pixel is brighter than the mean.
+// It's the fastest of the hashes and the least robust to the changes of the brightness
+// and the contrast.
+//
+// Example:
+//
+//	if imaging.HammingDistance(imaging.AverageHash(img1), imaging.AverageHash(img2)) < 10 {
+//		fmt.Println("the images are similar")
+//	}
+//
+func AverageHash(img image.Image) uint64 {
+	small := ResizeGray(img, 8, 8, Box)
+	if len(small.Pix) < 64 {
+		return 0
+	}
+	sum := 0
+	for _, v := range small.Pix {
+		sum += int(v)
+	}
+	var hash uint64
+	for _, v := range small.Pix {
+		hash <<= 1
+		if int(v)*64 > sum {
+			hash |= 1
+		}
+	}
+	return hash
+}
+
+// DifferenceHash returns the difference hash (dHash) of the image: the image is reduced
+// to 9x8 pixels and each bit tells whether a pixel is brighter than its right neighbor.
+// It's as fast as AverageHash and tracks the gradients, so it isn't affected
+// by the changes of the brightness.
+//
+// Example:
+//
+//	hash := imaging.DifferenceHash(img)
+//
+func DifferenceHash(img image.Image) uint64 {
+	small := ResizeGray(img, 9, 8, Box)
+	if len(small.Pix) < 72 {
+		return 0
+	}
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		row := small.Pix[y*small.Stride : y*small.Stride+9]
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if row[x] > row[x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// PerceptualHash returns the DCT-based perceptual hash (pHash) of the image: the image
+// is reduced to 32x32 pixels, and each bit tells whether one of the 8x8 lowest frequency
+// coefficients of its discrete cosine transform is greater than their median. It's slower
+// than the other hashes and the most robust to the compression, the noise and the changes
+// of the colors.
+//
+// Example:
+//
+//	hash := imaging.PerceptualHash(img)
+//
+func PerceptualHash(img image.Image) uint64 {
+	const n = 32
+	small := ResizeGray(img, n, n, Box)
+	if len(small.Pix) < n*n {
+		return 0
+	}
+
+	// The separable DCT-II, only the 8 lowest frequencies are needed in each direction.
+	var cos [8][n]float64
+	for k := range cos {
+		for i := range cos[k] {
+			cos[k][i] = math.Cos(math.Pi * float64(k) * (float64(i) + 0.5) / n)
+		}
+	}
+	var rows [n][8]float64
+	for y := 0; y < n; y++ {
+		for k := 0; k < 8; k++ {
+			var sum float64
+			for x := 0; x < n; x++ {
+				sum += float64(small.Pix[y*small.Stride+x]) * cos[k][x]
+			}
+			rows[y][k] = sum
+		}
+	}
+	var coeffs [64]float64
+	for ky := 0; ky < 8; ky++ {
+		for kx := 0; kx < 8; kx++ {
+			var sum float64
+			for y := 0; y < n; y++ {
+				sum += rows[y][kx] * cos[ky][y]
+			}
+			coeffs[ky*8+kx] = sum
+		}
+	}
+
+	// The DC coefficient is the mean brightness, it's excluded from the median.
+	sorted := slices.Clone(coeffs[1:])
+	slices.Sort(sorted)
+	median := sorted[len(sorted)/2]
+	var hash uint64
+	for _, c := range coeffs {
+		hash <<= 1
+		if c > median {
+			hash |= 1
+		}
+	}
+	return hash
+}
+
+// HammingDistance returns the number of the bits that differ in the hashes.
+func HammingDistance(hash1, hash2 uint64) int {
+	return bits.OnesCount64(hash1 ^ hash2)
+}
@@ -0,0 +1,74 @@
+package imaging
+
+import (
+	"image"
+	"testing"
+)
+
+func TestPerceptualHashes(t *testing.T) {
+	src := testdataFlowersSmallPNG
+	jpegData, err := EncodeBytes(src, JPEG, JPEGQuality(50))
+	if err != nil {
+		t.Fatalf("EncodeBytes: %v", err)
+	}
+	compressed, err := DecodeBytes(jpegData)
+	if err != nil {
+		t.Fatalf("DecodeBytes: %v", err)
+	}
+	similar := []image.Image{
+		Resize(src, 120, 80, Lanczos),
+		Blur(src, 1),
+		compressed,
+		AdjustBrightness(src, 10),
+	}
+	different := []image.Image{
+		testdataBranchesPNG,
+		FlipH(src),
+		Invert(src),
+	}
+
+	hashes := []struct {
+		name string
+		fn   func(image.Image) uint64
+	}{
+		{"AverageHash", AverageHash},
+		{"DifferenceHash", DifferenceHash},
+		{"PerceptualHash", PerceptualHash},
+	}
+	for _, h := range hashes {
+		hash := h.fn(src)
+		if h.fn(Clone(src)) != hash {
+			t.Fatalf("%s: got different hashes for the same image", h.name)
+		}
+		for i, img := range similar {
+			if d := HammingDistance(hash, h.fn(img)); d > 8 {
+				t.Fatalf("%s: got distance %d for the similar image %d", h.name, d, i)
+			}
+		}
+		for i, img := range different {
+			if d := HammingDistance(hash, h.fn(img)); d < 12 {
+				t.Fatalf("%s: got distance %d for the different image %d", h.name, d, i)
+			}
+		}
+		if got := h.fn(&image.NRGBA{}); got != 0 {
+			t.Fatalf("%s: got hash %x for the empty image", h.name, got)
+		}
+	}
+}
+
+func TestHammingDistance(t *testing.T) {
+	testCases := []struct {
+		a, b uint64
+		want int
+	}{
+		{0, 0, 0},
+		{0, 1, 1},
+		{0xff00, 0x0ff0, 8},
+		{0, ^uint64(0), 64},
+	}
+	for _, tc := range testCases {
+		if got := HammingDistance(tc.a, tc.b); got != tc.want {
+			t.Fatalf("got distance %d for %x and %x want %d", got, tc.a, tc.b, tc.want)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+)
+
+// pipelineOp is a recorded Pipeline operation. Exactly one of the fields is set.
+type pipelineOp struct {
+	apply func(img image.Image) *image.NRGBA // An operation on the whole image.
+	lut   *[3][256]uint8                     // Per-channel lookup tables.
+	line  func(pix []uint8)                  // Another per-pixel operation.
+}
+
+// Pipeline is a chain of image operations that are executed together by Run.
+// The consecutive per-pixel operations (the color adjustments, ColorMatrix, AdjustFunc etc.)
+// are fused: they are applied in a single pass over the image, the lookup table based
+// adjustments are collapsed into a single table, and they are done in place on the result
+// of the previous operation instead of allocating a new image. The result is the same
+// as if the operations were called one by one.
+//
+// Pipeline values are immutable, each operation returns a new Pipeline, so a common
+// prefix can be shared by several pipelines. A Pipeline is safe for concurrent use.
+type Pipeline struct {
+	ops []pipelineOp
+}
+
+// NewPipeline returns an empty pipeline.
+//
+// Example:
+//
+//	p := imaging.NewPipeline().
+//		Resize(800, 0, imaging.Lanczos).
+//		Sharpen(0.5).
+//		AdjustGamma(1.1).
+//		AdjustContrast(10)
+//	dstImage := p.Run(srcImage)
+//
+func NewPipeline() *Pipeline {
+	return &Pipeline{}
+}
+
+func (p *Pipeline) with(op pipelineOp) *Pipeline {
+	ops := make([]pipelineOp, len(p.ops), len(p.ops)+1)
+	copy(ops, p.ops)
+	return &Pipeline{ops: append(ops, op)}
+}
+
+func (p *Pipeline) withLUT(lut []uint8) *Pipeline {
+	var luts [3][256]uint8
+	for c := range luts {
+		copy(luts[c][:], lut)
+	}
+	return p.with(pipelineOp{lut: &luts})
+}
+
+// Resize adds a Resize operation.
+func (p *Pipeline) Resize(width, height int, filter ResampleFilter, opts ...ResizeOption) *Pipeline {
+	return p.with(pipelineOp{apply: func(img image.Image) *image.NRGBA {
+		return Resize(img, width, height, filter, opts...)
+	}})
+}
+
+// Fit adds a Fit operation.
+func (p *Pipeline) Fit(width, height int, filter ResampleFilter) *Pipeline {
+	return p.with(pipelineOp{apply: func(img image.Image) *image.NRGBA {
+		return Fit(img, width, height, filter)
+	}})
+}
+
+// Fill adds a Fill operation.
+func (p *Pipeline) Fill(width, height int, anchor Anchor, filter ResampleFilter) *Pipeline {
+	return p.with(pipelineOp{apply: func(img image.Image) *image.NRGBA {
+		return Fill(img, width, height, anchor, filter)
+	}})
+}
+
+// Crop adds a Crop operation.
+func (p *Pipeline) Crop(rect image.Rectangle) *Pipeline {
+	return p.with(pipelineOp{apply: func(img image.Image) *image.NRGBA {
+		return Crop(img, rect)
+	}})
+}
+
+// Blur adds a Blur operation.
+func (p *Pipeline) Blur(sigma float64) *Pipeline {
+	return p.with(pipelineOp{apply: func(img image.Image) *image.NRGBA {
+		return Blur(img, sigma)
+	}})
+}
+
+// Sharpen adds a Sharpen operation.
+func (p *Pipeline) Sharpen(sigma float64) *Pipeline {
+	return p.with(pipelineOp{apply: func(img image.Image) *image.NRGBA {
+		return Sharpen(img, sigma)
+	}})
+}
+
+// AdjustGamma adds an AdjustGamma operation.
+func (p *Pipeline) AdjustGamma(gamma float64) *Pipeline {
+	if gamma == 1 {
+		return p
+	}
+	return p.withLUT(gammaLUT(gamma))
+}
+
+// AdjustBrightness adds an AdjustBrightness operation.
+func (p *Pipeline) AdjustBrightness(percentage float64) *Pipeline {
+	if percentage == 0 {
+		return p
+	}
+	return p.withLUT(brightnessLUT(percentage))
+}
+
+// AdjustContrast adds an AdjustContrast operation.
+func (p *Pipeline) AdjustContrast(percentage float64) *Pipeline {
+	if percentage == 0 {
+		return p
+	}
+	return p.withLUT(contrastLUT(percentage))
+}
+
+// AdjustSigmoid adds an AdjustSigmoid operation.
+func (p *Pipeline) AdjustSigmoid(midpoint, factor float64) *Pipeline {
+	if factor == 0 {
+		return p
+	}
+	return p.withLUT(sigmoidLUT(midpoint, factor))
+}
+
+// Invert adds an Invert operation.
+func (p *Pipeline) Invert() *Pipeline {
+	return p.with(pipelineOp{line: invertLine})
+}
+
+// Grayscale adds a Grayscale operation.
+func (p *Pipeline) Grayscale() *Pipeline {
+	wr, wg, wb := Rec601.weights()
+	return p.with(pipelineOp{line: func(pix []uint8) {
+		grayscaleLine(pix, wr, wg, wb, nil)
+	}})
+}
+
+// ColorMatrix adds a ColorMatrix operation.
+func (p *Pipeline) ColorMatrix(matrix [20]float64) *Pipeline {
+	return p.with(pipelineOp{line: func(pix []uint8) {
+		colorMatrixLine(pix, &matrix)
+	}})
+}
+
+// AdjustFunc adds an AdjustFunc operation. The fn function may be called concurrently.
+func (p *Pipeline) AdjustFunc(fn func(c color.NRGBA) color.NRGBA) *Pipeline {
+	return p.with(pipelineOp{line: func(pix []uint8) {
+		funcLine(pix, fn)
+	}})
+}
+
+// Run executes the pipeline on the image and returns the result.
+// The source image is never modified.
+func (p *Pipeline) Run(img image.Image) *image.NRGBA {
+	var dst *image.NRGBA // The result of the last operation, owned by the pipeline.
+	ops := p.ops
+	for len(ops) > 0 {
+		if ops[0].apply != nil {
+			src := img
+			if dst != nil {
+				src = dst
+			}
+			dst = ops[0].apply(src)
+			ops = ops[1:]
+			continue
+		}
+
+		n := 0
+		for n < len(ops) && ops[n].apply == nil {
+			n++
+		}
+		stages := fusePixelOps(ops[:n])
+		ops = ops[n:]
+
+		if dst == nil {
+			// The first operations read the source image while converting it.
+			src := newScanner(img)
+			dst = image.NewNRGBA(image.Rect(0, 0, src.w, src.h))
+			parallel(0, src.h, src.w*len(stages)*costPoint, func(ys <-chan int) {
+				for y := range ys {
+					line := dst.Pix[y*dst.Stride : y*dst.Stride+src.w*4]
+					src.scan(0, y, src.w, y+1, line)
+					for _, stage := range stages {
+						stage(line)
+					}
+				}
+			})
+			continue
+		}
+		w := dst.Rect.Dx()
+		parallel(0, dst.Rect.Dy(), w*len(stages)*costPoint, func(ys <-chan int) {
+			for y := range ys {
+				line := dst.Pix[y*dst.Stride : y*dst.Stride+w*4]
+				for _, stage := range stages {
+					stage(line)
+				}
+			}
+		})
+	}
+	if dst == nil {
+		return Clone(img)
+	}
+	return dst
+}
+
+// fusePixelOps returns the functions applying the per-pixel operations to a line
+// of pixels. The consecutive lookup tables are composed into one.
+func fusePixelOps(ops []pipelineOp) []func(pix []uint8) {
+	var stages []func(pix []uint8)
+	for i := 0; i < len(ops); {
+		if ops[i].line != nil {
+			stages = append(stages, ops[i].line)
+			i++
+			continue
+		}
+		luts := *ops[i].lut
+		for i++; i < len(ops) && ops[i].lut != nil; i++ {
+			next := ops[i].lut
+			for c := range luts {
+				for v := range luts[c] {
+					luts[c][v] = next[c][luts[c][v]]
+				}
+			}
+		}
+		stages = append(stages, func(pix []uint8) {
+			lutR, lutG, lutB := &luts[0], &luts[1], &luts[2]
+			for i := 0; i < len(pix); i += 4 {
+				d := pix[i : i+3 : i+3]
+				d[0] = lutR[d[0]]
+				d[1] = lutG[d[1]]
+				d[2] = lutB[d[2]]
+			}
+		})
+	}
+	return stages
+}
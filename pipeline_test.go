@@ -0,0 +1,106 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestPipeline(t *testing.T) {
+	swap := [20]float64{
+		0, 0, 1, 0, 0,
+		0, 1, 0, 0, 0,
+		1, 0, 0, 0, 0,
+		0, 0, 0, 1, 0,
+	}
+	halfAlpha := func(c color.NRGBA) color.NRGBA {
+		c.A /= 2
+		return c
+	}
+	src := testdataBranchesPNG
+	testCases := []struct {
+		name string
+		p    *Pipeline
+		want *image.NRGBA
+	}{
+		{
+			"empty",
+			NewPipeline(),
+			Clone(src),
+		},
+		{
+			"pixel operations only",
+			NewPipeline().AdjustGamma(1.2).AdjustContrast(20).Invert().AdjustBrightness(-10),
+			AdjustBrightness(Invert(AdjustContrast(AdjustGamma(src, 1.2), 20)), -10),
+		},
+		{
+			"mixed",
+			NewPipeline().
+				Crop(image.Rect(100, 50, 500, 350)).
+				AdjustSigmoid(0.5, 3).
+				Resize(200, 0, Lanczos).
+				Sharpen(0.5).
+				AdjustGamma(0.8).
+				ColorMatrix(swap).
+				AdjustFunc(halfAlpha).
+				Grayscale().
+				Fit(50, 50, Linear).
+				Fill(30, 30, Center, Box).
+				Blur(1),
+			Blur(Fill(Fit(Grayscale(AdjustFunc(ColorMatrix(AdjustGamma(Sharpen(Resize(AdjustSigmoid(
+				Crop(src, image.Rect(100, 50, 500, 350)), 0.5, 3), 200, 0, Lanczos), 0.5), 0.8), swap),
+				halfAlpha)), 50, 50, Linear), 30, 30, Center, Box), 1),
+		},
+		{
+			"identity adjustments",
+			NewPipeline().AdjustGamma(1).AdjustBrightness(0).AdjustContrast(0).AdjustSigmoid(0.5, 0),
+			Clone(src),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := tc.p.Run(src)
+			if !compareNRGBA(got, tc.want, 0) {
+				t.Fatalf("the result differs from the chained calls")
+			}
+		})
+	}
+}
+
+func TestPipelineImmutable(t *testing.T) {
+	base := NewPipeline().Resize(100, 0, Box)
+	inverted := base.Invert()
+	darker := base.AdjustBrightness(-20)
+	if got := base.Run(testdataFlowersSmallPNG); !compareNRGBA(got, Resize(testdataFlowersSmallPNG, 100, 0, Box), 0) {
+		t.Fatalf("the base pipeline was modified")
+	}
+	if got := inverted.Run(testdataFlowersSmallPNG); !compareNRGBA(got, Invert(Resize(testdataFlowersSmallPNG, 100, 0, Box)), 0) {
+		t.Fatalf("unexpected inverted pipeline result")
+	}
+	if got := darker.Run(testdataFlowersSmallPNG); !compareNRGBA(got, AdjustBrightness(Resize(testdataFlowersSmallPNG, 100, 0, Box), -20), 0) {
+		t.Fatalf("unexpected darker pipeline result")
+	}
+
+	// The source image is not modified by the in-place operations.
+	src := Clone(testdataFlowersSmallPNG)
+	NewPipeline().Invert().Run(src)
+	if !compareNRGBA(src, Clone(testdataFlowersSmallPNG), 0) {
+		t.Fatalf("the source image was modified")
+	}
+}
+
+func BenchmarkPipeline(b *testing.B) {
+	b.Run("chained", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			AdjustContrast(AdjustBrightness(AdjustGamma(testdataBranchesJPG, 1.2), 10), 20)
+		}
+	})
+	b.Run("pipeline", func(b *testing.B) {
+		p := NewPipeline().AdjustGamma(1.2).AdjustBrightness(10).AdjustContrast(20)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			p.Run(testdataBranchesJPG)
+		}
+	})
+}
@@ -0,0 +1,75 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"iter"
+)
+
+// Pixels returns an iterator over the pixels of the image in the row order, yielding
+// the position of each pixel in the coordinates of the image and its color. The pixels
+// of any image type are read row by row the same way the functions of this package read
+// them, so it's much faster than calling the At method for each pixel and doesn't need
+// the index computations of the Pix slices.
+//
+// Example:
+//
+//	var sum int
+//	for _, c := range imaging.Pixels(img) {
+//		sum += int(c.R)
+//	}
+//
+func Pixels(img image.Image) iter.Seq2[image.Point, color.NRGBA] {
+	return func(yield func(image.Point, color.NRGBA) bool) {
+		b := img.Bounds()
+		s := newScanner(img)
+		if s.w <= 0 || s.h <= 0 {
+			return
+		}
+		line := make([]uint8, s.w*4)
+		for y := 0; y < s.h; y++ {
+			s.scan(0, y, s.w, y+1, line)
+			for x := 0; x < s.w; x++ {
+				p := line[x*4 : x*4+4 : x*4+4]
+				if !yield(image.Pt(b.Min.X+x, b.Min.Y+y), color.NRGBA{p[0], p[1], p[2], p[3]}) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// MapPixels returns a new image with the colors of the pixels replaced by the results
+// of fn, which gets the position of each pixel in the coordinates of the source image and
+// its color. The rows of the image are processed in parallel, so fn may be called concurrently.
+// The result has the bounds of the image moved to start at (0, 0).
+//
+// Example:
+//
+//	// A horizontal fade to transparent.
+//	w := float64(img.Bounds().Dx())
+//	dstImage := imaging.MapPixels(img, func(p image.Point, c color.NRGBA) color.NRGBA {
+//		c.A = uint8(float64(c.A) * (1 - float64(p.X)/w))
+//		return c
+//	})
+//
+func MapPixels(img image.Image, fn func(p image.Point, c color.NRGBA) color.NRGBA) *image.NRGBA {
+	b := img.Bounds()
+	s := newScanner(img)
+	dst := image.NewNRGBA(image.Rect(0, 0, s.w, s.h))
+	if s.w <= 0 || s.h <= 0 {
+		return dst
+	}
+	parallel(0, s.h, s.w*costHeavy, func(ys <-chan int) {
+		for y := range ys {
+			d := dst.Pix[y*dst.Stride : y*dst.Stride+s.w*4]
+			s.scan(0, y, s.w, y+1, d)
+			for x := 0; x < s.w; x++ {
+				p := d[x*4 : x*4+4 : x*4+4]
+				c := fn(image.Pt(b.Min.X+x, b.Min.Y+y), color.NRGBA{p[0], p[1], p[2], p[3]})
+				p[0], p[1], p[2], p[3] = c.R, c.G, c.B, c.A
+			}
+		}
+	})
+	return dst
+}
@@ -0,0 +1,72 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"image/color/palette"
+	"testing"
+)
+
+func TestPixels(t *testing.T) {
+	rect := image.Rect(-1, 2, 13, 9)
+	for _, img := range []image.Image{
+		makeNRGBAImage(rect, palette.Plan9),
+		makeYCbCrImage(rect, palette.Plan9, image.YCbCrSubsampleRatio422),
+		makeGrayImage(rect, palette.Plan9),
+		makeGenericImage(rect, palette.Plan9),
+	} {
+		want := Clone(img)
+		n := 0
+		for p, c := range Pixels(img) {
+			if wantP := image.Pt(rect.Min.X+n%rect.Dx(), rect.Min.Y+n/rect.Dx()); p != wantP {
+				t.Fatalf("%T: got position %v want %v", img, p, wantP)
+			}
+			if wantC := want.NRGBAAt(p.X-rect.Min.X, p.Y-rect.Min.Y); c != wantC {
+				t.Fatalf("%T: got color %v at %v want %v", img, c, p, wantC)
+			}
+			n++
+		}
+		if n != rect.Dx()*rect.Dy() {
+			t.Fatalf("%T: got %d pixels want %d", img, n, rect.Dx()*rect.Dy())
+		}
+	}
+
+	n := 0
+	for range Pixels(testdataBranchesPNG) {
+		if n++; n == 10 {
+			break
+		}
+	}
+	for range Pixels(&image.NRGBA{}) {
+		t.Fatalf("got a pixel of the empty image")
+	}
+}
+
+func TestMapPixels(t *testing.T) {
+	src := Clone(testdataFlowersSmallPNG).SubImage(image.Rect(10, 20, 110, 90))
+	got := MapPixels(src, func(p image.Point, c color.NRGBA) color.NRGBA {
+		if p.X < 60 {
+			return color.NRGBA{c.G, c.B, c.R, c.A}
+		}
+		return color.NRGBA{}
+	})
+	if got.Rect != image.Rect(0, 0, 100, 70) {
+		t.Fatalf("got bounds %v", got.Rect)
+	}
+	want := Clone(src)
+	for y := 0; y < 70; y++ {
+		for x := 0; x < 100; x++ {
+			c := want.NRGBAAt(x, y)
+			w := color.NRGBA{c.G, c.B, c.R, c.A}
+			if x >= 50 {
+				w = color.NRGBA{}
+			}
+			if got.NRGBAAt(x, y) != w {
+				t.Fatalf("got color %v at (%d, %d) want %v", got.NRGBAAt(x, y), x, y, w)
+			}
+		}
+	}
+	if got := MapPixels(&image.NRGBA{}, nil); !got.Rect.Empty() {
+		t.Fatalf("got bounds %v", got.Rect)
+	}
+}
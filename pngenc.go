@@ -0,0 +1,397 @@
+package imaging
+
+import (
+	"bufio"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"sort"
+)
+
+// pngImage converts the image to the bit depth or the palette set by the PNG encode options.
+func pngImage(img image.Image, cfg *encodeConfig) image.Image {
+	if cfg.pngNumColors > 0 {
+		return palettedImage(img, cfg.pngNumColors, cfg.pngQuantizer, cfg.pngDrawer)
+	}
+	switch cfg.pngBitDepth {
+	case 8:
+		switch img.ColorModel() {
+		case color.GrayModel, color.RGBAModel, color.NRGBAModel, color.AlphaModel:
+			return img
+		case color.Gray16Model:
+			return convertImage(image.NewGray(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy())), img)
+		}
+		if _, ok := img.(image.PalettedImage); ok {
+			return img
+		}
+		return toNRGBA(img)
+	case 16:
+		switch img.ColorModel() {
+		case color.Gray16Model, color.RGBA64Model, color.NRGBA64Model:
+			return img
+		case color.GrayModel:
+			return convertImage(image.NewGray16(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy())), img)
+		}
+		return Clone16(img)
+	}
+	return img
+}
+
+// convertImage draws the image into dst, converting the colors to the model of dst.
+func convertImage(dst draw.Image, img image.Image) image.Image {
+	draw.Draw(dst, dst.Bounds(), img, img.Bounds().Min, draw.Src)
+	return dst
+}
+
+// palettedImage converts the image to a paletted image of at most numColors colors.
+// The palette is built by the quantizer (median cut by default) and the image is drawn
+// by the drawer (Floyd-Steinberg dithering by default).
+func palettedImage(img image.Image, numColors int, quantizer draw.Quantizer, drawer draw.Drawer) *image.Paletted {
+	numColors = min(max(numColors, 1), 256)
+	if quantizer == nil {
+		quantizer = medianCutQuantizer{}
+	}
+	if drawer == nil {
+		drawer = draw.FloydSteinberg
+	}
+	b := img.Bounds()
+	palette := quantizer.Quantize(make(color.Palette, 0, numColors), img)
+	if len(palette) > numColors {
+		palette = palette[:numColors]
+	}
+	if len(palette) == 0 {
+		palette = color.Palette{color.NRGBA{}}
+	}
+	dst := image.NewPaletted(image.Rect(0, 0, b.Dx(), b.Dy()), palette)
+	drawer.Draw(dst, dst.Rect, img, b.Min)
+	return dst
+}
+
+// medianCutQuantizer builds a palette by splitting the color space box of the image colors
+// along its longest side at the median until the number of boxes reaches the palette capacity.
+type medianCutQuantizer struct{}
+
+// Quantize implements draw.Quantizer.
+func (medianCutQuantizer) Quantize(p color.Palette, m image.Image) color.Palette {
+	colors := sampleColors(m)
+	if len(colors) == 0 {
+		return p
+	}
+
+	type box struct {
+		colors  [][4]uint8
+		channel int
+		size    int
+	}
+	newBox := func(colors [][4]uint8) box {
+		bx := box{colors: colors}
+		for ch := 0; ch < 4; ch++ {
+			lo, hi := uint8(255), uint8(0)
+			for _, c := range colors {
+				lo = min(lo, c[ch])
+				hi = max(hi, c[ch])
+			}
+			if int(hi)-int(lo) > bx.size {
+				bx.channel, bx.size = ch, int(hi)-int(lo)
+			}
+		}
+		return bx
+	}
+
+	boxes := []box{newBox(colors)}
+	for len(boxes) < cap(p)-len(p) {
+		// Split the box with the longest side.
+		best := -1
+		for i, bx := range boxes {
+			if len(bx.colors) > 1 && bx.size > 0 && (best < 0 || bx.size > boxes[best].size) {
+				best = i
+			}
+		}
+		if best < 0 {
+			break
+		}
+		bx := boxes[best]
+		ch := bx.channel
+		sort.Slice(bx.colors, func(i, j int) bool { return bx.colors[i][ch] < bx.colors[j][ch] })
+		// Split at the value change nearest to the median, so equal colors stay in one box.
+		mid := len(bx.colors) / 2
+		lo, hi := mid, mid
+		for lo > 0 && bx.colors[lo-1][ch] == bx.colors[lo][ch] {
+			lo--
+		}
+		for hi < len(bx.colors) && bx.colors[hi-1][ch] == bx.colors[hi][ch] {
+			hi++
+		}
+		if lo > 0 && (hi == len(bx.colors) || mid-lo <= hi-mid) {
+			mid = lo
+		} else {
+			mid = hi
+		}
+		boxes[best] = newBox(bx.colors[:mid])
+		boxes = append(boxes, newBox(bx.colors[mid:]))
+	}
+
+	for _, bx := range boxes {
+		var sum [4]int
+		for _, c := range bx.colors {
+			for ch := range sum {
+				sum[ch] += int(c[ch])
+			}
+		}
+		k := len(bx.colors)
+		p = append(p, color.NRGBA{
+			uint8((sum[0] + k/2) / k),
+			uint8((sum[1] + k/2) / k),
+			uint8((sum[2] + k/2) / k),
+			uint8((sum[3] + k/2) / k),
+		})
+	}
+	return p
+}
+
+// quantizeMaxSamples limits the number of the pixels used to build the palette.
+const quantizeMaxSamples = 1 << 18
+
+// sampleColors returns the colors of the image pixels, or of the evenly spaced pixels
+// if there are more than quantizeMaxSamples of them.
+func sampleColors(m image.Image) [][4]uint8 {
+	src := toNRGBA(m)
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	n := w * h
+	step := 1
+	if n > quantizeMaxSamples {
+		step = (n + quantizeMaxSamples - 1) / quantizeMaxSamples
+	}
+	colors := make([][4]uint8, 0, n/step+1)
+	for i := 0; i < n; i += step {
+		var c [4]uint8
+		copy(c[:], src.Pix[src.PixOffset(i%w, i/w):][:4])
+		if c[3] == 0 {
+			// All the transparent pixels are the same color.
+			c = [4]uint8{}
+		}
+		colors = append(colors, c)
+	}
+	return colors
+}
+
+// adam7 are the passes of the Adam7 interlacing: the offsets and the steps of the pixels.
+var adam7 = [7]struct{ x, y, dx, dy int }{
+	{0, 0, 8, 8},
+	{4, 0, 8, 8},
+	{0, 4, 4, 8},
+	{2, 0, 4, 4},
+	{0, 2, 2, 4},
+	{1, 0, 2, 2},
+	{0, 1, 1, 2},
+}
+
+// encodePNGInterlaced encodes the image as an Adam7-interlaced PNG. The color type and
+// the bit depth are chosen from the image type the same way as png.Encode does.
+func encodePNGInterlaced(w io.Writer, img image.Image, level png.CompressionLevel) error {
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+	if width <= 0 || height <= 0 || int64(width) >= 1<<31 || int64(height) >= 1<<31 {
+		return errors.New("imaging: invalid image size")
+	}
+
+	// The samples of the image in the PNG layout, one byte per pixel for the paletted images.
+	var (
+		pix       []uint8
+		bpp       int // Bytes per pixel.
+		depth     uint8
+		colorType uint8
+		palette   color.Palette
+	)
+	switch src := img.(type) {
+	case *image.Paletted:
+		palette = src.Palette
+		pix, bpp, colorType = make([]uint8, width*height), 1, 3
+		for y := 0; y < height; y++ {
+			copy(pix[y*width:], src.Pix[src.PixOffset(b.Min.X, b.Min.Y+y):][:width])
+		}
+		switch {
+		case len(palette) <= 2:
+			depth = 1
+		case len(palette) <= 4:
+			depth = 2
+		case len(palette) <= 16:
+			depth = 4
+		default:
+			depth = 8
+		}
+	case *image.Gray:
+		pix, bpp, depth, colorType = make([]uint8, width*height), 1, 8, 0
+		for y := 0; y < height; y++ {
+			copy(pix[y*width:], src.Pix[src.PixOffset(b.Min.X, b.Min.Y+y):][:width])
+		}
+	case *image.Gray16:
+		pix, bpp, depth, colorType = make([]uint8, width*height*2), 2, 16, 0
+		for y := 0; y < height; y++ {
+			copy(pix[y*width*2:], src.Pix[src.PixOffset(b.Min.X, b.Min.Y+y):][:width*2])
+		}
+	default:
+		switch img.ColorModel() {
+		case color.RGBAModel, color.NRGBAModel, color.AlphaModel, color.GrayModel:
+			pix, bpp, depth = Clone(img).Pix, 4, 8
+		default:
+			pix, bpp, depth = Clone16(img).Pix, 8, 16
+		}
+		colorType = 6
+		// Drop the alpha channel of the opaque images.
+		sample := bpp / 4
+		opaque := true
+		for i := 3 * sample; i < len(pix); i += bpp {
+			if pix[i] != 0xff || (sample == 2 && pix[i+1] != 0xff) {
+				opaque = false
+				break
+			}
+		}
+		if opaque {
+			n := 0
+			for i := 0; i < len(pix); i += bpp {
+				n += copy(pix[n:], pix[i:i+3*sample])
+			}
+			pix, bpp, colorType = pix[:n], 3*sample, 2
+		}
+	}
+
+	if _, err := io.WriteString(w, "\x89PNG\r\n\x1a\n"); err != nil {
+		return err
+	}
+	var ihdr [13]byte
+	binary.BigEndian.PutUint32(ihdr[0:4], uint32(width))
+	binary.BigEndian.PutUint32(ihdr[4:8], uint32(height))
+	ihdr[8] = depth
+	ihdr[9] = colorType
+	ihdr[12] = 1 // Interlace method: Adam7.
+	if err := writePNGChunk(w, "IHDR", ihdr[:]); err != nil {
+		return err
+	}
+
+	if palette != nil {
+		plte := make([]uint8, 0, len(palette)*3)
+		trns := make([]uint8, 0, len(palette))
+		last := -1
+		for i, c := range palette {
+			nc := color.NRGBAModel.Convert(c).(color.NRGBA)
+			plte = append(plte, nc.R, nc.G, nc.B)
+			trns = append(trns, nc.A)
+			if nc.A != 0xff {
+				last = i
+			}
+		}
+		if err := writePNGChunk(w, "PLTE", plte); err != nil {
+			return err
+		}
+		if last >= 0 {
+			if err := writePNGChunk(w, "tRNS", trns[:last+1]); err != nil {
+				return err
+			}
+		}
+	}
+
+	bw := bufio.NewWriterSize(pngChunkWriter{w}, 1<<15)
+	zw, err := zlib.NewWriterLevel(bw, pngZlibLevel(level))
+	if err != nil {
+		return err
+	}
+	// The paletted images with the packed pixels and the uncompressed images aren't filtered.
+	filter := colorType != 3 && level != png.NoCompression
+	for _, pass := range adam7 {
+		pw := (width - pass.x + pass.dx - 1) / pass.dx
+		ph := (height - pass.y + pass.dy - 1) / pass.dy
+		if pw <= 0 || ph <= 0 {
+			continue
+		}
+		rowSize := (pw*bpp*int(depth) + 7) / 8
+		if colorType != 3 {
+			rowSize = pw * bpp
+		}
+		prev := make([]uint8, rowSize)
+		cur := make([]uint8, rowSize)
+		var out [5][]uint8
+		for i := range out {
+			out[i] = make([]uint8, 1+rowSize)
+			out[i][0] = uint8(i)
+		}
+		for py := 0; py < ph; py++ {
+			row := pix[(pass.y+py*pass.dy)*width*bpp:]
+			if colorType == 3 {
+				for i := range cur {
+					cur[i] = 0
+				}
+				for px := 0; px < pw; px++ {
+					bit := px * int(depth)
+					cur[bit/8] |= row[pass.x+px*pass.dx] << (8 - int(depth) - bit%8)
+				}
+			} else {
+				for px := 0; px < pw; px++ {
+					copy(cur[px*bpp:], row[(pass.x+px*pass.dx)*bpp:][:bpp])
+				}
+			}
+			line := out[0]
+			copy(line[1:], cur)
+			if filter {
+				line = pngFilter(&out, cur, prev, bpp)
+			}
+			if _, err := zw.Write(line); err != nil {
+				return err
+			}
+			prev, cur = cur, prev
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	return writePNGChunk(w, "IEND", nil)
+}
+
+// pngFilter applies all the PNG filters to the row and returns the filtered row with
+// the smallest sum of absolute values, prefixed with the filter type.
+func pngFilter(out *[5][]uint8, cur, prev []uint8, bpp int) []uint8 {
+	copy(out[0][1:], cur)
+	for i := range cur {
+		var left, upLeft uint8
+		if i >= bpp {
+			left, upLeft = cur[i-bpp], prev[i-bpp]
+		}
+		up := prev[i]
+		out[1][i+1] = cur[i] - left
+		out[2][i+1] = cur[i] - up
+		out[3][i+1] = cur[i] - uint8((int(left)+int(up))/2)
+		out[4][i+1] = cur[i] - paeth(left, up, upLeft)
+	}
+	best, bestSum := 0, -1
+	for f := range out {
+		sum := 0
+		for _, v := range out[f][1:] {
+			sum += absint(int(int8(v)))
+		}
+		if bestSum < 0 || sum < bestSum {
+			best, bestSum = f, sum
+		}
+	}
+	return out[best]
+}
+
+// paeth returns the Paeth predictor of the PNG filter type 4.
+func paeth(a, b, c uint8) uint8 {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := absint(p-int(a)), absint(p-int(b)), absint(p-int(c))
+	if pa <= pb && pa <= pc {
+		return a
+	}
+	if pb <= pc {
+		return b
+	}
+	return c
+}
@@ -0,0 +1,170 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"testing"
+)
+
+func TestEncodePNGPalette(t *testing.T) {
+	src := testdataFlowersSmallPNG
+	full, err := EncodeBytes(src, PNG)
+	if err != nil {
+		t.Fatalf("EncodeBytes: %v", err)
+	}
+	data, err := EncodeBytes(src, PNG, PNGNumColors(64))
+	if err != nil {
+		t.Fatalf("EncodeBytes: %v", err)
+	}
+	if len(data)*2 > len(full) {
+		t.Fatalf("got %d bytes for the paletted image and %d for the full color one", len(data), len(full))
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("png.Decode: %v", err)
+	}
+	p, ok := img.(*image.Paletted)
+	if !ok {
+		t.Fatalf("got decoded image %T want *image.Paletted", img)
+	}
+	if len(p.Palette) > 64 {
+		t.Fatalf("got %d palette colors", len(p.Palette))
+	}
+	if d := meanAbsDiff(img, src); d > 8 {
+		t.Fatalf("got mean difference %.2f", d)
+	}
+}
+
+func TestEncodePNGPaletteExact(t *testing.T) {
+	colors := []color.NRGBA{
+		{255, 0, 0, 255},
+		{0, 255, 0, 255},
+		{0, 0, 255, 128},
+		{0, 0, 0, 0},
+	}
+	src := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			src.SetNRGBA(x, y, colors[(x/4+y/8)%4])
+		}
+	}
+	data, err := EncodeBytes(src, PNG, PNGNumColors(4), PNGDrawer(draw.Src))
+	if err != nil {
+		t.Fatalf("EncodeBytes: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("png.Decode: %v", err)
+	}
+	if _, ok := img.(*image.Paletted); !ok {
+		t.Fatalf("got decoded image %T want *image.Paletted", img)
+	}
+	if !compareNRGBA(toNRGBA(img), src, 0) {
+		t.Fatalf("the colors are not preserved")
+	}
+}
+
+func TestEncodePNGBitDepth(t *testing.T) {
+	src16 := image.NewNRGBA64(image.Rect(0, 0, 8, 8))
+	for i := range src16.Pix {
+		src16.Pix[i] = uint8(i * 31)
+	}
+	testCases := []struct {
+		name  string
+		src   image.Image
+		depth int
+		want  color.Model
+	}{
+		{"16-bit source", src16, 0, color.NRGBA64Model},
+		{"16-bit source to 8", src16, 8, color.NRGBAModel},
+		{"8-bit source to 16", testdataBranchesPNG, 16, color.RGBA64Model},
+		{"8-bit source", testdataBranchesPNG, 8, color.RGBAModel},
+		{"gray to 16", image.NewGray(image.Rect(0, 0, 4, 4)), 16, color.Gray16Model},
+		{"gray16 to 8", image.NewGray16(image.Rect(0, 0, 4, 4)), 8, color.GrayModel},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := EncodeBytes(tc.src, PNG, PNGBitDepth(tc.depth))
+			if err != nil {
+				t.Fatalf("EncodeBytes: %v", err)
+			}
+			img, err := png.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("png.Decode: %v", err)
+			}
+			if img.ColorModel() != tc.want {
+				t.Fatalf("got decoded image %T", img)
+			}
+		})
+	}
+}
+
+func TestEncodePNGInterlace(t *testing.T) {
+	transparent := Clone(testdataBranchesPNG)
+	for i := 3; i < len(transparent.Pix); i += 40 {
+		transparent.Pix[i] = 0x80
+	}
+	gray16 := image.NewGray16(image.Rect(0, 0, 13, 7))
+	for i := range gray16.Pix {
+		gray16.Pix[i] = uint8(i * 13)
+	}
+	gray := image.NewGray(image.Rect(2, 3, 11, 12))
+	for i := range gray.Pix {
+		gray.Pix[i] = uint8(i * 5)
+	}
+	testCases := []struct {
+		name string
+		src  image.Image
+		opts []EncodeOption
+	}{
+		{"opaque", Resize(testdataFlowersSmallPNG, 237, 155, Box), nil},
+		{"transparent", transparent, nil},
+		{"16-bit", testdataFlowersSmallPNG, []EncodeOption{PNGBitDepth(16)}},
+		{"gray", gray, nil},
+		{"gray16", gray16, nil},
+		{"1x1", image.NewNRGBA(image.Rect(0, 0, 1, 1)), nil},
+		{"2 colors", testdataBranchesPNG, []EncodeOption{PNGNumColors(2)}},
+		{"16 colors", testdataBranchesPNG, []EncodeOption{PNGNumColors(16)}},
+		{"200 colors", testdataFlowersSmallPNG, []EncodeOption{PNGNumColors(200)}},
+		{"no compression", testdataBranchesPNG, []EncodeOption{PNGCompressionLevel(png.NoCompression)}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			plain, err := EncodeBytes(tc.src, PNG, tc.opts...)
+			if err != nil {
+				t.Fatalf("EncodeBytes: %v", err)
+			}
+			data, err := EncodeBytes(tc.src, PNG, append(tc.opts, PNGInterlace(true))...)
+			if err != nil {
+				t.Fatalf("EncodeBytes: %v", err)
+			}
+			if data[28] != 1 {
+				t.Fatalf("got interlace method %d want 1", data[28])
+			}
+			want, err := png.Decode(bytes.NewReader(plain))
+			if err != nil {
+				t.Fatalf("png.Decode: %v", err)
+			}
+			got, err := png.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("png.Decode: %v", err)
+			}
+			if !isPalettedPair(got, want) && got.ColorModel() != want.ColorModel() {
+				t.Fatalf("got decoded image %T want %T", got, want)
+			}
+			if !bytes.Equal(Clone16(got).Pix, Clone16(want).Pix) {
+				t.Fatalf("the interlaced image differs")
+			}
+		})
+	}
+}
+
+// isPalettedPair reports whether both images are paletted.
+func isPalettedPair(a, b image.Image) bool {
+	_, ok1 := a.(*image.Paletted)
+	_, ok2 := b.(*image.Paletted)
+	return ok1 && ok2
+}
@@ -0,0 +1,179 @@
+package imaging
+
+import (
+	"fmt"
+	"image"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Op is a named image processing operation that can be used with Profile.
+type Op struct {
+	// Name is the operation name used in reports.
+	Name string
+
+	// Apply applies the operation to the image and returns the result.
+	Apply func(img image.Image) *image.NRGBA
+
+	// suggest returns a hint on how to make the operation faster for the given source image.
+	suggest func(src image.Image) string
+
+	// spec holds the parameters of the operations created by the constructors of this package,
+	// which are needed for the serialization. Its Op field is empty for the other operations.
+	spec opSpec
+}
+
+// FuncOp returns an Op with the given name that calls fn.
+func FuncOp(name string, fn func(img image.Image) *image.NRGBA) Op {
+	return Op{Name: name, Apply: fn}
+}
+
+// ResizeOp returns an Op that calls Resize with the given parameters.
+func ResizeOp(width, height int, filter ResampleFilter) Op {
+	return Op{
+		Name: fmt.Sprintf("Resize(%d, %d)", width, height),
+		spec: opSpec{Op: "resize", Width: width, Height: height, Filter: filterName(filter)},
+		Apply: func(img image.Image) *image.NRGBA {
+			return Resize(img, width, height, filter)
+		},
+		suggest: func(src image.Image) string {
+			return suggestResize(src, width, height, filter)
+		},
+	}
+}
+
+// FitOp returns an Op that calls Fit with the given parameters.
+func FitOp(width, height int, filter ResampleFilter) Op {
+	return Op{
+		Name: fmt.Sprintf("Fit(%d, %d)", width, height),
+		spec: opSpec{Op: "fit", Width: width, Height: height, Filter: filterName(filter)},
+		Apply: func(img image.Image) *image.NRGBA {
+			return Fit(img, width, height, filter)
+		},
+		suggest: func(src image.Image) string {
+			w, h := fitSize(src.Bounds().Dx(), src.Bounds().Dy(), width, height)
+			return suggestResize(src, w, h, filter)
+		},
+	}
+}
+
+// BlurOp returns an Op that calls Blur with the given parameters.
+func BlurOp(sigma float64) Op {
+	return Op{
+		Name: fmt.Sprintf("Blur(%g)", sigma),
+		spec: opSpec{Op: "blur", Sigma: sigma},
+		Apply: func(img image.Image) *image.NRGBA {
+			return Blur(img, sigma)
+		},
+		suggest: func(src image.Image) string {
+			if sigma > 10 {
+				return "for a large sigma, downscale the image, blur it with a proportionally smaller sigma and upscale it back"
+			}
+			return ""
+		},
+	}
+}
+
+// SharpenOp returns an Op that calls Sharpen with the given parameters.
+func SharpenOp(sigma float64) Op {
+	return Op{
+		Name: fmt.Sprintf("Sharpen(%g)", sigma),
+		spec: opSpec{Op: "sharpen", Sigma: sigma},
+		Apply: func(img image.Image) *image.NRGBA {
+			return Sharpen(img, sigma)
+		},
+	}
+}
+
+func suggestResize(src image.Image, width, height int, filter ResampleFilter) string {
+	srcW, srcH := src.Bounds().Dx(), src.Bounds().Dy()
+	if srcW <= 0 || srcH <= 0 || width < 0 || height < 0 || (width == 0 && height == 0) {
+		return ""
+	}
+	width, height = resizeSize(srcW, srcH, width, height)
+	if filter.Support > 1 && srcW >= width*4 && srcH >= height*4 {
+		return fmt.Sprintf("shrinking %dx, prefilter with Box to %dx%d first, then apply the filter",
+			srcW/width, width*2, height*2)
+	}
+	return ""
+}
+
+// StepReport holds the measurements of a single operation.
+type StepReport struct {
+	Name        string
+	Duration    time.Duration
+	Allocs      uint64 // Number of heap allocations.
+	AllocBytes  uint64 // Bytes allocated on the heap.
+	Width       int    // Output image width.
+	Height      int    // Output image height.
+	Suggestion  string // A hint on how to make the operation faster, if any.
+	InputFormat string // Type of the input image, e.g. "*image.YCbCr".
+}
+
+// Report is the result of Profile.
+type Report struct {
+	Steps []StepReport
+	Total time.Duration
+}
+
+// String formats the report as a human-readable table.
+func (r Report) String() string {
+	b := &strings.Builder{}
+	for _, s := range r.Steps {
+		fmt.Fprintf(b, "%-24s %12v %8d allocs %12d B  %dx%d  (input %s)\n",
+			s.Name, s.Duration, s.Allocs, s.AllocBytes, s.Width, s.Height, s.InputFormat)
+		if s.Suggestion != "" {
+			fmt.Fprintf(b, "    hint: %s\n", s.Suggestion)
+		}
+	}
+	fmt.Fprintf(b, "total %v\n", r.Total)
+	return b.String()
+}
+
+// Profile runs the operations one after another, each on the result of the previous one,
+// and measures the wall time, heap allocations and output size of each step.
+// The report also contains hints on faster equivalents where they are known.
+// Allocation counts are process-wide, so other goroutines running at the same time
+// affect the results.
+//
+// Example:
+//
+//	report := imaging.Profile(img,
+//		imaging.ResizeOp(200, 0, imaging.Lanczos),
+//		imaging.SharpenOp(0.5),
+//	)
+//	fmt.Print(report)
+//
+func Profile(img image.Image, ops ...Op) Report {
+	var report Report
+	var before, after runtime.MemStats
+	for _, op := range ops {
+		step := StepReport{
+			Name:        op.Name,
+			InputFormat: fmt.Sprintf("%T", img),
+		}
+		if op.suggest != nil {
+			step.Suggestion = op.suggest(img)
+		}
+		if _, ok := img.(*image.NRGBA); !ok && step.Suggestion == "" && len(ops) > 1 {
+			step.Suggestion = "convert the input to *image.NRGBA once (see Clone) to speed up pixel access"
+		}
+
+		runtime.ReadMemStats(&before)
+		start := time.Now()
+		dst := op.Apply(img)
+		step.Duration = time.Since(start)
+		runtime.ReadMemStats(&after)
+
+		step.Allocs = after.Mallocs - before.Mallocs
+		step.AllocBytes = after.TotalAlloc - before.TotalAlloc
+		step.Width = dst.Bounds().Dx()
+		step.Height = dst.Bounds().Dy()
+
+		report.Steps = append(report.Steps, step)
+		report.Total += step.Duration
+		img = dst
+	}
+	return report
+}
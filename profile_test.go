@@ -0,0 +1,69 @@
+package imaging
+
+import (
+	"image"
+	"strings"
+	"testing"
+)
+
+func TestProfile(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 400, 300))
+	report := Profile(src,
+		ResizeOp(50, 0, Lanczos),
+		FitOp(40, 40, Linear),
+		BlurOp(20),
+		SharpenOp(1),
+		FuncOp("Invert", Invert),
+	)
+
+	if len(report.Steps) != 5 {
+		t.Fatalf("got %d steps want 5", len(report.Steps))
+	}
+
+	want := []struct {
+		name       string
+		w, h       int
+		suggestion bool
+	}{
+		{"Resize(50, 0)", 50, 38, true},
+		{"Fit(40, 40)", 40, 30, false},
+		{"Blur(20)", 40, 30, true},
+		{"Sharpen(1)", 40, 30, false},
+		{"Invert", 40, 30, false},
+	}
+	var total int64
+	for i, w := range want {
+		s := report.Steps[i]
+		if s.Name != w.name || s.Width != w.w || s.Height != w.h {
+			t.Fatalf("step %d: got %q %dx%d want %q %dx%d", i, s.Name, s.Width, s.Height, w.name, w.w, w.h)
+		}
+		if (s.Suggestion != "") != w.suggestion {
+			t.Fatalf("step %d: got suggestion %q", i, s.Suggestion)
+		}
+		total += int64(s.Duration)
+	}
+	if int64(report.Total) != total {
+		t.Fatalf("got total %v want %v", report.Total, total)
+	}
+	if report.Steps[0].InputFormat != "*image.RGBA" || report.Steps[1].InputFormat != "*image.NRGBA" {
+		t.Fatalf("got input formats %q %q", report.Steps[0].InputFormat, report.Steps[1].InputFormat)
+	}
+	if report.Steps[0].AllocBytes == 0 {
+		t.Fatalf("got zero allocated bytes for Resize")
+	}
+
+	str := report.String()
+	for _, w := range want {
+		if !strings.Contains(str, w.name) {
+			t.Fatalf("report doesn't contain %q:\n%s", w.name, str)
+		}
+	}
+	if !strings.Contains(str, "hint: ") {
+		t.Fatalf("report doesn't contain hints:\n%s", str)
+	}
+
+	report = Profile(src, FuncOp("Clone", Clone), FuncOp("Invert", Invert))
+	if report.Steps[0].Suggestion == "" || report.Steps[1].Suggestion != "" {
+		t.Fatalf("got suggestions %q %q", report.Steps[0].Suggestion, report.Steps[1].Suggestion)
+	}
+}
@@ -0,0 +1,140 @@
+package imaging
+
+import (
+	"context"
+	"image"
+	"math"
+	"sync"
+)
+
+type progressKey struct{}
+
+// WithProgress returns a copy of ctx that carries a progress callback. The long running
+// functions that accept a context (ResizeCtx, BlurCtx, SharpenCtx and RotateCtx) call fn
+// as the processing goes on, with the amount of work done and the total amount of work
+// of the operation, measured in rows or columns of pixels. The calls are serialized,
+// done never decreases, and the last call of a successful operation has done equal
+// to total. The fn function is called for each processed row, so it must be fast:
+// e.g. it can store the values for a GUI to display.
+//
+// Example:
+//
+//	ctx := imaging.WithProgress(context.Background(), func(done, total int) {
+//		bar.Set(float64(done) / float64(total))
+//	})
+//	dstImage, err := imaging.ResizeCtx(ctx, srcImage, 800, 0, imaging.Lanczos)
+//
+func WithProgress(ctx context.Context, fn func(done, total int)) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// operationProgress tracks the progress of a single operation.
+type operationProgress struct {
+	mu    sync.Mutex
+	fn    func(done, total int)
+	done  int
+	total int
+}
+
+type operationProgressKey struct{}
+
+// beginProgress starts tracking the progress of an operation with the given total amount
+// of work. It returns ctx unchanged if there is no progress callback.
+func beginProgress(ctx context.Context, total int) context.Context {
+	fn, ok := ctx.Value(progressKey{}).(func(done, total int))
+	if !ok || fn == nil {
+		return ctx
+	}
+	if total < 1 {
+		// The operation is done in a single step.
+		total = 1
+	}
+	return context.WithValue(ctx, operationProgressKey{}, &operationProgress{fn: fn, total: total})
+}
+
+// endProgress reports the completion of the operation if it's not reported yet.
+func endProgress(ctx context.Context) {
+	if p, ok := ctx.Value(operationProgressKey{}).(*operationProgress); ok {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.done < p.total {
+			p.done = p.total
+			p.fn(p.done, p.total)
+		}
+	}
+}
+
+func (p *operationProgress) add(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.done+n > p.total {
+		n = p.total - p.done
+	}
+	if n <= 0 {
+		return
+	}
+	p.done += n
+	p.fn(p.done, p.total)
+}
+
+// trackProgress wraps the function processing the indices handed out by parallelCtx,
+// so that each processed index is reported as a unit of work. An index is known to be
+// processed when the worker asks for the next one or returns.
+func trackProgress(ctx context.Context, fn func(<-chan int)) func(<-chan int) {
+	p, ok := ctx.Value(operationProgressKey{}).(*operationProgress)
+	if !ok {
+		return fn
+	}
+	return func(in <-chan int) {
+		out := make(chan int)
+		received := false
+		go func() {
+			defer close(out)
+			for i := range in {
+				out <- i
+				if received {
+					p.add(1)
+				}
+				received = true
+			}
+		}()
+		fn(out)
+		if received {
+			p.add(1)
+		}
+	}
+}
+
+// resizeWork returns the amount of work done by resize, as used for the progress reporting.
+func resizeWork(img image.Image, width, height int, filter ResampleFilter) int {
+	srcW, srcH := img.Bounds().Dx(), img.Bounds().Dy()
+	if width < 0 || height < 0 || (width == 0 && height == 0) || srcW <= 0 || srcH <= 0 {
+		return 0
+	}
+	dstW, dstH := resizeSize(srcW, srcH, width, height)
+	work := 0
+	switch {
+	case srcW == dstW && srcH == dstH:
+	case filter.Support <= 0:
+		work = dstH
+	case srcW != dstW && srcH != dstH:
+		work = srcH + dstW
+	case srcW != dstW:
+		work = srcH
+	default:
+		work = srcW
+	}
+	return work
+}
+
+// blurWork returns the amount of work done by blur, as used for the progress reporting.
+func blurWork(img image.Image, sigma float64) int {
+	if sigma <= 0 {
+		return 0
+	}
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	if w <= 0 || h <= 0 {
+		return 0
+	}
+	return h + int(math.Ceil(float64(w)/blurStripWidth))
+}
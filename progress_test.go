@@ -0,0 +1,123 @@
+package imaging
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"testing"
+)
+
+type progressRecorder struct {
+	calls [][2]int
+}
+
+func (r *progressRecorder) context() context.Context {
+	return WithProgress(context.Background(), func(done, total int) {
+		r.calls = append(r.calls, [2]int{done, total})
+	})
+}
+
+func TestProgress(t *testing.T) {
+	src := testdataBranchesPNG // 600x400
+	testCases := []struct {
+		name  string
+		fn    func(ctx context.Context) (*image.NRGBA, error)
+		total int
+	}{
+		{
+			"ResizeCtx",
+			func(ctx context.Context) (*image.NRGBA, error) { return ResizeCtx(ctx, src, 300, 0, Lanczos) },
+			400 + 300,
+		},
+		{
+			"ResizeCtx width only",
+			func(ctx context.Context) (*image.NRGBA, error) { return ResizeCtx(ctx, src, 300, 400, Linear) },
+			400,
+		},
+		{
+			"ResizeCtx nearest",
+			func(ctx context.Context) (*image.NRGBA, error) { return ResizeCtx(ctx, src, 300, 0, NearestNeighbor) },
+			200,
+		},
+		{
+			"ResizeCtx same size",
+			func(ctx context.Context) (*image.NRGBA, error) { return ResizeCtx(ctx, src, 600, 400, Lanczos) },
+			1,
+		},
+		{
+			"BlurCtx",
+			func(ctx context.Context) (*image.NRGBA, error) { return BlurCtx(ctx, src, 2) },
+			400 + (600+blurStripWidth-1)/blurStripWidth,
+		},
+		{
+			"SharpenCtx",
+			func(ctx context.Context) (*image.NRGBA, error) { return SharpenCtx(ctx, src, 2) },
+			400 + (600+blurStripWidth-1)/blurStripWidth + 400,
+		},
+		{
+			"RotateCtx",
+			func(ctx context.Context) (*image.NRGBA, error) { return RotateCtx(ctx, src, 30, color.Black) },
+			rotateWork(src, 30),
+		},
+		{
+			"RotateCtx right angle",
+			func(ctx context.Context) (*image.NRGBA, error) { return RotateCtx(ctx, src, -90, color.Black) },
+			1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var r progressRecorder
+			if _, err := tc.fn(r.context()); err != nil {
+				t.Fatalf("got error %v", err)
+			}
+			if len(r.calls) == 0 {
+				t.Fatalf("the progress is not reported")
+			}
+			prev := 0
+			for _, c := range r.calls {
+				if c[1] != tc.total {
+					t.Fatalf("got total %d want %d", c[1], tc.total)
+				}
+				if c[0] < prev || c[0] > c[1] {
+					t.Fatalf("got invalid progress sequence %v", r.calls)
+				}
+				prev = c[0]
+			}
+			if last := r.calls[len(r.calls)-1]; last[0] != last[1] {
+				t.Fatalf("the last call is %v", last)
+			}
+			if tc.total > 1 && len(r.calls) != tc.total {
+				t.Fatalf("got %d calls want one per unit of work", len(r.calls))
+			}
+		})
+	}
+
+	// The results are not affected.
+	var r progressRecorder
+	got, _ := ResizeCtx(r.context(), src, 100, 0, Lanczos)
+	if !compareNRGBA(got, Resize(src, 100, 0, Lanczos), 0) {
+		t.Fatalf("the result differs from Resize")
+	}
+	got, _ = RotateCtx(r.context(), src, 30, color.Black)
+	if !compareNRGBA(got, Rotate(src, 30, color.Black), 0) {
+		t.Fatalf("the result differs from Rotate")
+	}
+}
+
+func TestProgressCanceled(t *testing.T) {
+	var last [2]int
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx = WithProgress(ctx, func(done, total int) {
+		last = [2]int{done, total}
+		if done*2 >= total {
+			cancel()
+		}
+	})
+	if _, err := BlurCtx(ctx, testdataBranchesPNG, 3); err != context.Canceled {
+		t.Fatalf("got error %v want %v", err, context.Canceled)
+	}
+	if last[0] >= last[1] {
+		t.Fatalf("the canceled operation reported completion: %v", last)
+	}
+}
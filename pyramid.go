@@ -0,0 +1,206 @@
+package imaging
+
+import (
+	"image"
+)
+
+// floatPlanes is an image with interleaved float32 channels used by the pyramid blending.
+type floatPlanes struct {
+	w, h, ch int
+	pix      []float32
+}
+
+func newFloatPlanes(w, h, ch int) *floatPlanes {
+	return &floatPlanes{w: w, h: h, ch: ch, pix: make([]float32, w*h*ch)}
+}
+
+// floatPlanesFromImage converts the image to the RGBA channels in range [0, 1].
+func floatPlanesFromImage(img image.Image) *floatPlanes {
+	src := newScanner(img)
+	p := newFloatPlanes(src.w, src.h, 4)
+	parallel(0, src.h, src.w*costPoint, func(ys <-chan int) {
+		scanLine := make([]uint8, src.w*4)
+		for y := range ys {
+			src.scan(0, y, src.w, y+1, scanLine)
+			d := p.pix[y*src.w*4 : (y+1)*src.w*4]
+			for i, v := range scanLine {
+				d[i] = float32(v) / 255
+			}
+		}
+	})
+	return p
+}
+
+// toImage converts the RGBA channels in range [0, 1] to an image.
+func (p *floatPlanes) toImage() *image.NRGBA {
+	dst := image.NewNRGBA(image.Rect(0, 0, p.w, p.h))
+	parallel(0, p.h, p.w*costPoint, func(ys <-chan int) {
+		for y := range ys {
+			s := p.pix[y*p.w*4 : (y+1)*p.w*4]
+			d := dst.Pix[y*dst.Stride : y*dst.Stride+p.w*4]
+			for i, v := range s {
+				d[i] = clamp(float64(v) * 255)
+			}
+		}
+	})
+	return dst
+}
+
+// pyramidKernel is the 5-tap binomial kernel of the Burt-Adelson pyramids.
+var pyramidKernel = [5]float32{1.0 / 16, 4.0 / 16, 6.0 / 16, 4.0 / 16, 1.0 / 16}
+
+// pyrDown blurs the image with the pyramid kernel and halves its size.
+func pyrDown(p *floatPlanes) *floatPlanes {
+	w, h := (p.w+1)/2, (p.h+1)/2
+	tmp := newFloatPlanes(w, p.h, p.ch)
+	parallel(0, p.h, w*5*p.ch*costTap, func(ys <-chan int) {
+		for y := range ys {
+			for x := 0; x < w; x++ {
+				d := tmp.pix[(y*w+x)*p.ch:][:p.ch]
+				for k, kv := range pyramidKernel {
+					sx := min(max(2*x+k-2, 0), p.w-1)
+					s := p.pix[(y*p.w+sx)*p.ch:][:p.ch]
+					for c := range d {
+						d[c] += kv * s[c]
+					}
+				}
+			}
+		}
+	})
+	dst := newFloatPlanes(w, h, p.ch)
+	parallel(0, h, w*5*p.ch*costTap, func(ys <-chan int) {
+		for y := range ys {
+			for k, kv := range pyramidKernel {
+				sy := min(max(2*y+k-2, 0), p.h-1)
+				s := tmp.pix[sy*w*p.ch : (sy+1)*w*p.ch]
+				d := dst.pix[y*w*p.ch : (y+1)*w*p.ch]
+				for i := range d {
+					d[i] += kv * s[i]
+				}
+			}
+		}
+	})
+	return dst
+}
+
+// pyrUp doubles the size of the image interpolating with the pyramid kernel
+// and crops it to w x h.
+func pyrUp(p *floatPlanes, w, h int) *floatPlanes {
+	// The even pixels are 1/8, 6/8, 1/8 of the source neighbors, the odd ones are their averages.
+	sample := func(i, n int, fn func(j int, weight float32)) {
+		j := i / 2
+		if i%2 == 0 {
+			fn(max(j-1, 0), 1.0/8)
+			fn(j, 6.0/8)
+			fn(min(j+1, n-1), 1.0/8)
+		} else {
+			fn(j, 0.5)
+			fn(min(j+1, n-1), 0.5)
+		}
+	}
+	tmp := newFloatPlanes(w, p.h, p.ch)
+	parallel(0, p.h, w*2*p.ch*costTap, func(ys <-chan int) {
+		for y := range ys {
+			for x := 0; x < w; x++ {
+				d := tmp.pix[(y*w+x)*p.ch:][:p.ch]
+				sample(x, p.w, func(sx int, weight float32) {
+					s := p.pix[(y*p.w+sx)*p.ch:][:p.ch]
+					for c := range d {
+						d[c] += weight * s[c]
+					}
+				})
+			}
+		}
+	})
+	dst := newFloatPlanes(w, h, p.ch)
+	parallel(0, h, w*2*p.ch*costTap, func(ys <-chan int) {
+		for y := range ys {
+			d := dst.pix[y*w*p.ch : (y+1)*w*p.ch]
+			sample(y, p.h, func(sy int, weight float32) {
+				s := tmp.pix[sy*w*p.ch : (sy+1)*w*p.ch]
+				for i := range d {
+					d[i] += weight * s[i]
+				}
+			})
+		}
+	})
+	return dst
+}
+
+// pyramidLevels returns the number of the pyramid levels for the image size,
+// so that the smallest level is at least 8 pixels on the shorter side.
+func pyramidLevels(w, h int) int {
+	n := 1
+	for s := min(w, h); s >= 16; s = (s + 1) / 2 {
+		n++
+	}
+	return n
+}
+
+// gaussianPyramid returns the successively blurred and halved copies of the image.
+func gaussianPyramid(p *floatPlanes, levels int) []*floatPlanes {
+	pyr := []*floatPlanes{p}
+	for len(pyr) < levels {
+		pyr = append(pyr, pyrDown(pyr[len(pyr)-1]))
+	}
+	return pyr
+}
+
+// laplacianPyramid returns the band-pass levels of the image and the low-pass residual
+// as the last level.
+func laplacianPyramid(p *floatPlanes, levels int) []*floatPlanes {
+	pyr := gaussianPyramid(p, levels)
+	for i := 0; i < len(pyr)-1; i++ {
+		up := pyrUp(pyr[i+1], pyr[i].w, pyr[i].h)
+		band := newFloatPlanes(pyr[i].w, pyr[i].h, p.ch)
+		for j := range band.pix {
+			band.pix[j] = pyr[i].pix[j] - up.pix[j]
+		}
+		pyr[i] = band
+	}
+	return pyr
+}
+
+// collapsePyramid reconstructs the image from its Laplacian pyramid.
+func collapsePyramid(pyr []*floatPlanes) *floatPlanes {
+	img := pyr[len(pyr)-1]
+	for i := len(pyr) - 2; i >= 0; i-- {
+		up := pyrUp(img, pyr[i].w, pyr[i].h)
+		for j := range up.pix {
+			up.pix[j] += pyr[i].pix[j]
+		}
+		img = up
+	}
+	return img
+}
+
+// blendPyramids blends the images with the per-pixel weights (single channel planes
+// summing to 1 at each pixel) by combining their Laplacian pyramids weighted by the
+// Gaussian pyramids of the weights, which hides the seams between the images.
+func blendPyramids(srcs, weights []*floatPlanes) *floatPlanes {
+	levels := pyramidLevels(srcs[0].w, srcs[0].h)
+	var blended []*floatPlanes
+	for i, src := range srcs {
+		wp := gaussianPyramid(weights[i], levels)
+		bands := laplacianPyramid(src, levels)
+		if blended == nil {
+			blended = make([]*floatPlanes, levels)
+			for l, band := range bands {
+				blended[l] = newFloatPlanes(band.w, band.h, band.ch)
+			}
+		}
+		for l, band := range bands {
+			wt, dst := wp[l].pix, blended[l].pix
+			parallel(0, band.h, band.w*band.ch*costPoint, func(ys <-chan int) {
+				for y := range ys {
+					for j := y * band.w; j < (y+1)*band.w; j++ {
+						for c := 0; c < band.ch; c++ {
+							dst[j*band.ch+c] += wt[j] * band.pix[j*band.ch+c]
+						}
+					}
+				}
+			})
+		}
+	}
+	return collapsePyramid(blended)
+}
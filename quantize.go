@@ -0,0 +1,417 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"slices"
+)
+
+// QuantizeMethod is the algorithm that builds the palette of Quantize.
+type QuantizeMethod int
+
+// Color quantization methods.
+const (
+	// MedianCut splits the box of the image colors at the median of its longest side
+	// until there are enough boxes. It's the default palette of the PNGNumColors option.
+	MedianCut QuantizeMethod = iota
+	// Octree merges the least used branches of the tree of the color bits, from the finest
+	// bits up, so the rare colors give their palette entries to the frequent ones first.
+	Octree
+	// Wu is the Xiaolin Wu's greedy orthogonal bipartition that minimizes the variance
+	// of the colors in the boxes. It usually gives the lowest error of the three.
+	Wu
+)
+
+// Quantize returns the image converted to a paletted image of at most numColors colors,
+// from 1 to 256, with the palette built by the method and the pixels mapped to it using
+// the dither mode like Dither does. The fully transparent pixels share one palette color.
+// The result can be encoded as GIF or PNG as is.
+//
+// Example:
+//
+//	dstImage := imaging.Quantize(img, 64, imaging.Wu, imaging.DitherFloydSteinberg)
+//	err := gif.Encode(w, dstImage, nil)
+//
+func Quantize(img image.Image, numColors int, method QuantizeMethod, dither DitherMode) *image.Paletted {
+	numColors = min(max(numColors, 1), 256)
+	palette := NewQuantizer(method).Quantize(make(color.Palette, 0, numColors), img)
+	if len(palette) > numColors {
+		palette = palette[:numColors]
+	}
+	if len(palette) == 0 {
+		palette = color.Palette{color.NRGBA{}}
+	}
+	return Dither(img, palette, dither)
+}
+
+// NewQuantizer returns the quantizer of the method, to be used with the GIFQuantizer and
+// PNGQuantizer options or with the image/gif package.
+//
+// Example:
+//
+//	err := imaging.Save(img, "out.gif", imaging.GIFQuantizer(imaging.NewQuantizer(imaging.Wu)))
+//
+func NewQuantizer(method QuantizeMethod) draw.Quantizer {
+	switch method {
+	case Octree:
+		return octreeQuantizer{}
+	case Wu:
+		return wuQuantizer{}
+	}
+	return medianCutQuantizer{}
+}
+
+// quantizeOpaque splits off the fully transparent color of the sampled colors. The octree
+// and the Wu quantizers work in the RGB space and average the alpha in their boxes, so
+// the fully transparent pixels get a palette color of their own.
+func quantizeOpaque(p color.Palette, colors [][4]uint8) (color.Palette, [][4]uint8) {
+	opaque := slices.DeleteFunc(colors, func(c [4]uint8) bool { return c[3] == 0 })
+	if len(opaque) < len(colors) || len(opaque) == 0 {
+		p = append(p, color.NRGBA{})
+	}
+	return p, opaque
+}
+
+// colorSum accumulates the colors of a box of the quantizers.
+type colorSum struct {
+	n          int
+	r, g, b, a int
+}
+
+func (s *colorSum) add(c [4]uint8) {
+	s.n++
+	s.r += int(c[0])
+	s.g += int(c[1])
+	s.b += int(c[2])
+	s.a += int(c[3])
+}
+
+func (s colorSum) color() color.NRGBA {
+	n := max(s.n, 1)
+	return color.NRGBA{
+		uint8((s.r + n/2) / n),
+		uint8((s.g + n/2) / n),
+		uint8((s.b + n/2) / n),
+		uint8((s.a + n/2) / n),
+	}
+}
+
+// octreeQuantizer builds a palette by merging the leaves of the octree of the colors,
+// starting from the deepest level and the least used nodes, until there are few enough.
+// The alpha doesn't take part in the tree, it's averaged in the leaves.
+type octreeQuantizer struct{}
+
+type octreeNode struct {
+	sum      colorSum
+	children [8]*octreeNode
+	leaf     bool
+}
+
+// Quantize implements draw.Quantizer.
+func (octreeQuantizer) Quantize(p color.Palette, m image.Image) color.Palette {
+	p, colors := quantizeOpaque(p, sampleColors(m))
+	numColors := cap(p) - len(p)
+	if len(colors) == 0 || numColors <= 0 {
+		return p
+	}
+
+	root := &octreeNode{}
+	// The internal nodes of each level, the candidates for the merging.
+	var levels [8][]*octreeNode
+	levels[0] = []*octreeNode{root}
+	leaves := 0
+	for _, c := range colors {
+		node := root
+		for level := 0; level < 8; level++ {
+			shift := 7 - level
+			i := (c[0]>>shift&1)<<2 | (c[1]>>shift&1)<<1 | c[2]>>shift&1
+			child := node.children[i]
+			if child == nil {
+				child = &octreeNode{leaf: level == 7}
+				node.children[i] = child
+				if child.leaf {
+					leaves++
+				} else {
+					levels[level+1] = append(levels[level+1], child)
+				}
+			}
+			node = child
+		}
+		node.sum.add(c)
+	}
+	// The sums of the internal nodes are the sums of their subtrees.
+	for level := 7; level >= 0; level-- {
+		for _, node := range levels[level] {
+			for _, child := range node.children {
+				if child != nil {
+					node.sum.n += child.sum.n
+					node.sum.r += child.sum.r
+					node.sum.g += child.sum.g
+					node.sum.b += child.sum.b
+					node.sum.a += child.sum.a
+				}
+			}
+		}
+	}
+
+	// Merge the least used nodes of the deepest level first, the ties are broken
+	// by the tree order. The nodes whose merging leaves too few colors are skipped.
+	var countLeaves func(node *octreeNode) int
+	countLeaves = func(node *octreeNode) int {
+		if node.leaf {
+			return 1
+		}
+		n := 0
+		for _, child := range node.children {
+			if child != nil {
+				n += countLeaves(child)
+			}
+		}
+		return n
+	}
+	for level := 7; level >= 0 && leaves > numColors; level-- {
+		nodes := levels[level]
+		slices.SortStableFunc(nodes, func(a, b *octreeNode) int { return a.sum.n - b.sum.n })
+		for _, node := range nodes {
+			if leaves <= numColors {
+				break
+			}
+			n := countLeaves(node)
+			if leaves-n+1 < numColors {
+				continue
+			}
+			node.children = [8]*octreeNode{}
+			node.leaf = true
+			leaves -= n - 1
+		}
+	}
+
+	var sums []colorSum
+	var walk func(node *octreeNode)
+	walk = func(node *octreeNode) {
+		if node.leaf {
+			sums = append(sums, node.sum)
+			return
+		}
+		for _, child := range node.children {
+			if child != nil {
+				walk(child)
+			}
+		}
+	}
+	walk(root)
+
+	// Merge the remaining excess colors pairwise, the pair that adds the least
+	// squared error first.
+	for len(sums) > numColors {
+		bi, bj, best := 0, 1, math.Inf(1)
+		for i := range sums {
+			for j := i + 1; j < len(sums); j++ {
+				if d := mergeCost(sums[i], sums[j]); d < best {
+					bi, bj, best = i, j, d
+				}
+			}
+		}
+		a, b := &sums[bi], sums[bj]
+		a.n, a.r, a.g, a.b, a.a = a.n+b.n, a.r+b.r, a.g+b.g, a.b+b.b, a.a+b.a
+		sums = slices.Delete(sums, bj, bj+1)
+	}
+	for _, sum := range sums {
+		p = append(p, sum.color())
+	}
+	return p
+}
+
+// mergeCost returns the increase of the squared error of merging the colors (Ward's criterion).
+func mergeCost(a, b colorSum) float64 {
+	na, nb := float64(a.n), float64(b.n)
+	dr := float64(a.r)/na - float64(b.r)/nb
+	dg := float64(a.g)/na - float64(b.g)/nb
+	db := float64(a.b)/na - float64(b.b)/nb
+	return na * nb / (na + nb) * (dr*dr + dg*dg + db*db)
+}
+
+// wuQuantizer builds a palette with the Xiaolin Wu's color quantization algorithm: the RGB
+// cube of the 5-bit colors is split by the planes that maximize the variance reduction.
+type wuQuantizer struct{}
+
+const wuSide = 33 // 32 values of each 5-bit channel and a zero border for the cumulative moments.
+
+// wuMoments are the cumulative moments of the color histogram.
+type wuMoments struct {
+	w, r, g, b, a, m2 []float64
+}
+
+// wuBox is a box of the histogram cells: the lower bounds are exclusive, the upper inclusive.
+type wuBox struct {
+	r0, r1, g0, g1, b0, b1 int
+}
+
+func wuIndex(r, g, b int) int {
+	return (r*wuSide+g)*wuSide + b
+}
+
+// volume returns the sum of the moment over the box.
+func (box wuBox) volume(m []float64) float64 {
+	return m[wuIndex(box.r1, box.g1, box.b1)] - m[wuIndex(box.r1, box.g1, box.b0)] -
+		m[wuIndex(box.r1, box.g0, box.b1)] + m[wuIndex(box.r1, box.g0, box.b0)] -
+		m[wuIndex(box.r0, box.g1, box.b1)] + m[wuIndex(box.r0, box.g1, box.b0)] +
+		m[wuIndex(box.r0, box.g0, box.b1)] - m[wuIndex(box.r0, box.g0, box.b0)]
+}
+
+// bounds returns the pointers to the lower and the upper bound along the channel.
+func (box *wuBox) bounds(channel int) (*int, *int) {
+	switch channel {
+	case 0:
+		return &box.r0, &box.r1
+	case 1:
+		return &box.g0, &box.g1
+	}
+	return &box.b0, &box.b1
+}
+
+func (box wuBox) cells() int {
+	return (box.r1 - box.r0) * (box.g1 - box.g0) * (box.b1 - box.b0)
+}
+
+func (m *wuMoments) variance(box wuBox) float64 {
+	w := box.volume(m.w)
+	if w == 0 {
+		return 0
+	}
+	r, g, b := box.volume(m.r), box.volume(m.g), box.volume(m.b)
+	return box.volume(m.m2) - (r*r+g*g+b*b)/w
+}
+
+// maximize returns the cut of the box along the channel with the largest sum
+// of the squared means weighted by the counts of the two parts, or -1 if the box
+// can't be cut.
+func (m *wuMoments) maximize(box wuBox, channel int) (int, float64) {
+	w, r, g, b := box.volume(m.w), box.volume(m.r), box.volume(m.g), box.volume(m.b)
+	lo, hi := box.bounds(channel)
+	first, last := *lo+1, *hi
+	bestCut, best := -1, 0.0
+	for cut := first; cut < last; cut++ {
+		half := box
+		_, halfHi := half.bounds(channel)
+		*halfHi = cut
+		hw := half.volume(m.w)
+		if hw == 0 || hw == w {
+			continue
+		}
+		hr, hg, hb := half.volume(m.r), half.volume(m.g), half.volume(m.b)
+		v := (hr*hr + hg*hg + hb*hb) / hw
+		ur, ug, ub := r-hr, g-hg, b-hb
+		v += (ur*ur + ug*ug + ub*ub) / (w - hw)
+		if v > best {
+			bestCut, best = cut, v
+		}
+	}
+	return bestCut, best
+}
+
+// Quantize implements draw.Quantizer.
+func (wuQuantizer) Quantize(p color.Palette, m image.Image) color.Palette {
+	p, colors := quantizeOpaque(p, sampleColors(m))
+	numColors := cap(p) - len(p)
+	if len(colors) == 0 || numColors <= 0 {
+		return p
+	}
+
+	const size = wuSide * wuSide * wuSide
+	moments := &wuMoments{
+		w: make([]float64, size), r: make([]float64, size), g: make([]float64, size),
+		b: make([]float64, size), a: make([]float64, size), m2: make([]float64, size),
+	}
+	for _, c := range colors {
+		i := wuIndex(int(c[0]>>3)+1, int(c[1]>>3)+1, int(c[2]>>3)+1)
+		r, g, b := float64(c[0]), float64(c[1]), float64(c[2])
+		moments.w[i]++
+		moments.r[i] += r
+		moments.g[i] += g
+		moments.b[i] += b
+		moments.a[i] += float64(c[3])
+		moments.m2[i] += r*r + g*g + b*b
+	}
+	// The cumulative sums along each axis.
+	for _, mm := range [][]float64{moments.w, moments.r, moments.g, moments.b, moments.a, moments.m2} {
+		for r := 1; r < wuSide; r++ {
+			for g := 1; g < wuSide; g++ {
+				for b := 1; b < wuSide; b++ {
+					i := wuIndex(r, g, b)
+					mm[i] += mm[i-1]
+				}
+			}
+		}
+		for r := 1; r < wuSide; r++ {
+			for g := 2; g < wuSide; g++ {
+				for b := 1; b < wuSide; b++ {
+					mm[wuIndex(r, g, b)] += mm[wuIndex(r, g-1, b)]
+				}
+			}
+		}
+		for r := 2; r < wuSide; r++ {
+			for g := 1; g < wuSide; g++ {
+				for b := 1; b < wuSide; b++ {
+					mm[wuIndex(r, g, b)] += mm[wuIndex(r-1, g, b)]
+				}
+			}
+		}
+	}
+
+	boxes := []wuBox{{0, wuSide - 1, 0, wuSide - 1, 0, wuSide - 1}}
+	variances := []float64{moments.variance(boxes[0])}
+	for len(boxes) < numColors {
+		// Split the box with the largest variance.
+		next := 0
+		for i, v := range variances {
+			if v > variances[next] {
+				next = i
+			}
+		}
+		if variances[next] <= 0 {
+			break
+		}
+		box := boxes[next]
+		bestChannel, bestCut, best := -1, -1, 0.0
+		for channel := 0; channel < 3; channel++ {
+			if cut, v := moments.maximize(box, channel); cut >= 0 && v > best {
+				bestChannel, bestCut, best = channel, cut, v
+			}
+		}
+		if bestChannel < 0 {
+			variances[next] = 0
+			continue
+		}
+		upper := box
+		lo, _ := upper.bounds(bestChannel)
+		*lo = bestCut
+		_, hi := box.bounds(bestChannel)
+		*hi = bestCut
+		boxes[next] = box
+		boxes = append(boxes, upper)
+		variances[next] = 0
+		variances = append(variances, 0)
+		for _, i := range []int{next, len(boxes) - 1} {
+			if boxes[i].cells() > 1 {
+				variances[i] = moments.variance(boxes[i])
+			}
+		}
+	}
+
+	for _, box := range boxes {
+		w := box.volume(moments.w)
+		if w == 0 {
+			continue
+		}
+		p = append(p, color.NRGBA{
+			uint8(box.volume(moments.r)/w + 0.5),
+			uint8(box.volume(moments.g)/w + 0.5),
+			uint8(box.volume(moments.b)/w + 0.5),
+			uint8(box.volume(moments.a)/w + 0.5),
+		})
+	}
+	return p
+}
@@ -0,0 +1,114 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+)
+
+func TestQuantize(t *testing.T) {
+	src := Clone(testdataFlowersSmallPNG)
+	testCases := []struct {
+		numColors int
+		maxDiff   float64
+	}{
+		{4, 13},
+		{16, 8},
+		{256, 3},
+	}
+	for _, method := range []QuantizeMethod{MedianCut, Octree, Wu} {
+		for _, tc := range testCases {
+			got := Quantize(src, tc.numColors, method, DitherNone)
+			if got.Rect != src.Rect || len(got.Palette) != tc.numColors {
+				t.Fatalf("method %d, %d colors: got bounds %v and %d colors", method, tc.numColors, got.Rect, len(got.Palette))
+			}
+			// Octree is coarser with the few colors.
+			maxDiff := tc.maxDiff
+			if method == Octree {
+				maxDiff *= 1.3
+			}
+			if d := meanAbsDiff(Clone(got), src); d > maxDiff {
+				t.Fatalf("method %d, %d colors: got mean difference %.2f", method, tc.numColors, d)
+			}
+		}
+	}
+}
+
+func TestQuantizeExactColors(t *testing.T) {
+	colors := []color.NRGBA{
+		{255, 0, 0, 255},
+		{0, 128, 0, 255},
+		{10, 20, 30, 255},
+		{200, 200, 50, 128},
+		{},
+	}
+	src := image.NewNRGBA(image.Rect(0, 0, 20, 10))
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 20; x++ {
+			src.SetNRGBA(x, y, colors[(x/4+y)%len(colors)])
+		}
+	}
+	for _, method := range []QuantizeMethod{MedianCut, Octree, Wu} {
+		for _, dither := range []DitherMode{DitherNone, DitherOrdered, DitherFloydSteinberg} {
+			got := Quantize(src, 8, method, dither)
+			if !Equal(got, src) {
+				t.Fatalf("method %d, dither %d: the colors changed", method, dither)
+			}
+		}
+	}
+	// A transparent color is added for the transparent pixels.
+	if got := Quantize(src, 2, Wu, DitherNone); got.Palette[got.Pix[4*4]] != (color.NRGBA{}) {
+		t.Fatalf("got palette %v", got.Palette)
+	}
+}
+
+func TestQuantizeDither(t *testing.T) {
+	// The horizontal gradient of gray.
+	src := image.NewNRGBA(image.Rect(0, 0, 256, 32))
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 256; x++ {
+			src.SetNRGBA(x, y, color.NRGBA{uint8(x), uint8(x), uint8(x), 255})
+		}
+	}
+	blurred := Blur(src, 3)
+	plain := meanAbsDiff(Blur(Quantize(src, 4, Wu, DitherNone), 3), blurred)
+	for _, dither := range []DitherMode{DitherOrdered, DitherFloydSteinberg} {
+		got := Quantize(src, 4, Wu, dither)
+		if d := meanAbsDiff(Blur(got, 3), blurred); d > plain/2 {
+			t.Fatalf("dither %d: got mean difference %.2f of the blurred images, %.2f without dithering", dither, d, plain)
+		}
+	}
+}
+
+func TestQuantizeEmpty(t *testing.T) {
+	for _, method := range []QuantizeMethod{MedianCut, Octree, Wu} {
+		got := Quantize(&image.NRGBA{}, 16, method, DitherOrdered)
+		if !got.Rect.Empty() || len(got.Palette) == 0 {
+			t.Fatalf("method %d: got bounds %v and palette %v", method, got.Rect, got.Palette)
+		}
+	}
+	got := Quantize(testdataBranchesPNG, 0, Octree, DitherNone)
+	if len(got.Palette) != 1 {
+		t.Fatalf("got %d colors", len(got.Palette))
+	}
+}
+
+func TestNewQuantizerGIF(t *testing.T) {
+	src := Clone(testdataFlowersSmallPNG)
+	var buf bytes.Buffer
+	if err := Encode(&buf, src, GIF, GIFNumColors(32), GIFQuantizer(NewQuantizer(Wu))); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	img, err := gif.Decode(&buf)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if n := len(img.(*image.Paletted).Palette); n > 32 {
+		t.Fatalf("got %d colors", n)
+	}
+	if d := meanAbsDiff(Blur(img, 2), Blur(src, 2)); d > 4 {
+		t.Fatalf("got mean difference %.2f", d)
+	}
+}
@@ -0,0 +1,126 @@
+package imaging
+
+import (
+	"image"
+	"math"
+)
+
+// maxQuietRegions is the maximum number of regions returned by FindQuietRegions.
+const maxQuietRegions = 10
+
+// FindQuietRegions finds the areas of the image of the given size that have little detail
+// and don't stand out from the rest of the image, so a watermark or a caption placed there
+// doesn't cover the subject of the photo. Each area is scored by the amount of edges
+// (the luminance gradient) and by the saliency (the difference of the colors from the
+// average color of the image). It returns up to 10 non-overlapping rectangles inside
+// the image bounds, the quietest first, or nil if the size doesn't fit the image.
+//
+// Example:
+//
+//	regions := imaging.FindQuietRegions(photo, logo.Bounds().Size())
+//	if len(regions) > 0 {
+//		photo = imaging.Overlay(photo, logo, regions[0].Min, 0.5)
+//	}
+//
+func FindQuietRegions(img image.Image, size image.Point) []image.Rectangle {
+	bounds := img.Bounds()
+	if size.X <= 0 || size.Y <= 0 || size.X > bounds.Dx() || size.Y > bounds.Dy() {
+		return nil
+	}
+
+	small := Fit(img, 256, 256, Box)
+	w, h := small.Rect.Dx(), small.Rect.Dy()
+	// The windows are rounded up, so the rectangles of the given size inside
+	// non-overlapping windows don't overlap.
+	scaleX := float64(bounds.Dx()) / float64(w)
+	scaleY := float64(bounds.Dy()) / float64(h)
+	ww := int(math.Min(math.Ceil(float64(size.X)/scaleX), float64(w)))
+	wh := int(math.Min(math.Ceil(float64(size.Y)/scaleY), float64(h)))
+
+	// Luminance and the average color.
+	lum := make([]float64, w*h)
+	var mean [3]float64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*small.Stride + x*4
+			s := small.Pix[i : i+3 : i+3]
+			lum[y*w+x] = 0.299*float64(s[0]) + 0.587*float64(s[1]) + 0.114*float64(s[2])
+			mean[0] += float64(s[0])
+			mean[1] += float64(s[1])
+			mean[2] += float64(s[2])
+		}
+	}
+	for c := range mean {
+		mean[c] /= float64(w * h)
+	}
+
+	// The integral image of the cost of each pixel.
+	sum := make([]float64, (w+1)*(h+1))
+	for y := 0; y < h; y++ {
+		var row float64
+		for x := 0; x < w; x++ {
+			l := lum[y*w+x]
+			var grad float64
+			if x+1 < w {
+				grad += math.Abs(lum[y*w+x+1] - l)
+			}
+			if y+1 < h {
+				grad += math.Abs(lum[(y+1)*w+x] - l)
+			}
+			i := y*small.Stride + x*4
+			s := small.Pix[i : i+3 : i+3]
+			dr, dg, db := float64(s[0])-mean[0], float64(s[1])-mean[1], float64(s[2])-mean[2]
+			saliency := math.Sqrt(dr*dr+dg*dg+db*db) / math.Sqrt(3)
+			row += grad + 0.25*saliency
+			sum[(y+1)*(w+1)+x+1] = sum[y*(w+1)+x+1] + row
+		}
+	}
+	cost := func(x, y int) float64 {
+		return sum[(y+wh)*(w+1)+x+ww] - sum[y*(w+1)+x+ww] - sum[(y+wh)*(w+1)+x] + sum[y*(w+1)+x]
+	}
+
+	type candidate struct {
+		x, y int
+		cost float64
+	}
+	var picked []candidate
+	for len(picked) < maxQuietRegions {
+		best := candidate{x: -1, cost: math.Inf(1)}
+		for y := 0; y+wh <= h; y++ {
+			for x := 0; x+ww <= w; x++ {
+				overlaps := false
+				for _, p := range picked {
+					if x < p.x+ww && p.x < x+ww && y < p.y+wh && p.y < y+wh {
+						overlaps = true
+						break
+					}
+				}
+				if overlaps {
+					continue
+				}
+				if c := cost(x, y); c < best.cost {
+					best = candidate{x, y, c}
+				}
+			}
+		}
+		if best.x < 0 {
+			break
+		}
+		picked = append(picked, best)
+	}
+
+	rects := make([]image.Rectangle, 0, len(picked))
+	for _, p := range picked {
+		x := int(float64(p.x) * scaleX)
+		y := int(float64(p.y) * scaleY)
+		if x+size.X > bounds.Dx() {
+			x = bounds.Dx() - size.X
+		}
+		if y+size.Y > bounds.Dy() {
+			y = bounds.Dy() - size.Y
+		}
+		min := bounds.Min.Add(image.Pt(x, y))
+		rects = append(rects, image.Rectangle{Min: min, Max: min.Add(size)})
+	}
+	return rects
+}
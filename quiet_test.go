@@ -0,0 +1,44 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestFindQuietRegions(t *testing.T) {
+	// A plain background with a busy, colorful subject.
+	img := New(600, 400, color.NRGBA{0x80, 0x90, 0xa0, 0xff})
+	subject := image.Rect(0, 100, 240, 260)
+	img = Paste(img, testdataFlowersSmallPNG, subject.Min)
+	sub := img.SubImage(image.Rect(0, 50, 600, 400))
+
+	size := image.Pt(120, 60)
+	got := FindQuietRegions(sub, size)
+	if len(got) != maxQuietRegions {
+		t.Fatalf("got %d regions want %d", len(got), maxQuietRegions)
+	}
+	for i, r := range got {
+		if r.Size() != size || !r.In(sub.Bounds()) {
+			t.Fatalf("region %v: invalid size or position", r)
+		}
+		if i < 3 && r.Overlaps(subject) {
+			t.Fatalf("region %d %v overlaps the subject", i, r)
+		}
+		for _, r2 := range got[:i] {
+			if r.Overlaps(r2) {
+				t.Fatalf("regions %v and %v overlap", r, r2)
+			}
+		}
+	}
+
+	// The whole image is the only region of its size.
+	if got := FindQuietRegions(sub, sub.Bounds().Size()); len(got) != 1 || got[0] != sub.Bounds() {
+		t.Fatalf("got %v for the full size", got)
+	}
+	for _, size := range []image.Point{{0, 10}, {601, 10}, {10, 351}} {
+		if got := FindQuietRegions(sub, size); got != nil {
+			t.Fatalf("got %v for size %v", got, size)
+		}
+	}
+}
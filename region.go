@@ -0,0 +1,437 @@
+package imaging
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"image"
+	"io"
+	iofs "io/fs"
+	"io/ioutil"
+	"math"
+)
+
+// DecodeRegion reads the given rectangular region of an image from r and returns it
+// as a new image. The rectangle is specified in the source image coordinates and is
+// clipped to the image bounds.
+//
+// Striped and tiled TIFF images with 8-bit gray, RGB or RGBA samples that are either
+// uncompressed or deflate-compressed are read partially: only the strips or tiles
+// intersecting the region are loaded. All other images are decoded entirely and
+// then cropped. The options used are DecodeLimits and ColorProfileConversion: the region
+// and each strip or tile read must fit in the limits, as well as the images decoded entirely,
+// and the colors of the region are converted to sRGB using the embedded ICC profile. The layout
+// of the TIFF images is checked against the data size if r has a Size or Stat method,
+// like *bytes.Reader and *os.File.
+//
+// Example:
+//
+//	f, err := os.Open("scan.tif")
+//	if err != nil {
+//		return err
+//	}
+//	defer f.Close()
+//	window, err := imaging.DecodeRegion(f, image.Rect(10000, 20000, 10512, 20512))
+//
+func DecodeRegion(r io.ReaderAt, rect image.Rectangle, opts ...DecodeOption) (*image.NRGBA, error) {
+	cfg := defaultDecodeConfig
+	for _, option := range opts {
+		option(&cfg)
+	}
+
+	t, err := readTIFFLayout(r)
+	if err == nil {
+		img, err := t.decodeRegion(r, rect, cfg.limits)
+		if err != nil || !cfg.profileConversion || t.iccSize == 0 {
+			return img, err
+		}
+		// Like in Decode, the unreadable and unsupported profiles are ignored.
+		if profile, err := readSection(r, t.size, t.iccOffset, t.iccSize); err == nil {
+			if converted, err := ConvertToSRGB(img, profile); err == nil {
+				img = converted
+			}
+		}
+		return img, nil
+	}
+	if err != errTIFFFallback {
+		return nil, err
+	}
+
+	var decodeOpts []DecodeOption
+	if cfg.limits != nil {
+		decodeOpts = append(decodeOpts, DecodeLimits(*cfg.limits))
+	}
+	if cfg.profileConversion {
+		decodeOpts = append(decodeOpts, ColorProfileConversion(true))
+	}
+	img, err := Decode(io.NewSectionReader(r, 0, math.MaxInt64), decodeOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return Crop(img, rect), nil
+}
+
+// errTIFFFallback means that the image must be decoded entirely.
+var errTIFFFallback = errors.New("imaging: unsupported TIFF layout")
+
+// errTIFFMalformed means that the TIFF structures point beyond the data. Such images aren't
+// decoded entirely either, as the decoder would allocate the memory for the declared sizes.
+var errTIFFMalformed = errors.New("imaging: malformed TIFF data")
+
+const (
+	tiffTagImageWidth      = 256
+	tiffTagImageLength     = 257
+	tiffTagBitsPerSample   = 258
+	tiffTagCompression     = 259
+	tiffTagPhotometric     = 262
+	tiffTagStripOffsets    = 273
+	tiffTagSamplesPerPixel = 277
+	tiffTagRowsPerStrip    = 278
+	tiffTagStripByteCounts = 279
+	tiffTagPlanarConfig    = 284
+	tiffTagPredictor       = 317
+	tiffTagTileWidth       = 322
+	tiffTagTileLength      = 323
+	tiffTagTileOffsets     = 324
+	tiffTagTileByteCounts  = 325
+	tiffTagExtraSamples    = 338
+	tiffTagICCProfile      = 34675
+)
+
+// tiffLayout describes the chunk grid of a TIFF image. Strips are represented
+// as chunks with the width equal to the image width.
+type tiffLayout struct {
+	width, height  int
+	chunkW, chunkH int
+	samples        int
+	associated     bool
+	compressed     bool
+	predictor      bool
+	offsets        []uint32
+	counts         []uint32
+	size           int64 // The data size, or -1 if unknown.
+	iccOffset      int64 // The position of the embedded ICC profile.
+	iccSize        int64 // The size of the embedded ICC profile, or 0 if there is none.
+}
+
+func readTIFFLayout(r io.ReaderAt) (*tiffLayout, error) {
+	size := readerAtSize(r)
+	var header [8]byte
+	if _, err := r.ReadAt(header[:], 0); err != nil {
+		return nil, errTIFFFallback
+	}
+
+	var bo binary.ByteOrder
+	switch string(header[0:4]) {
+	case "II\x2a\x00":
+		bo = binary.LittleEndian
+	case "MM\x00\x2a":
+		bo = binary.BigEndian
+	default:
+		return nil, errTIFFFallback
+	}
+
+	ifd := int64(bo.Uint32(header[4:8]))
+	var n [2]byte
+	if _, err := r.ReadAt(n[:], ifd); err != nil {
+		return nil, errTIFFMalformed
+	}
+	entries, err := readSection(r, size, ifd+2, 12*int64(bo.Uint16(n[:])))
+	if err != nil {
+		return nil, errTIFFMalformed
+	}
+
+	fields := make(map[uint16][]uint32)
+	var iccOffset, iccSize int64
+	for i := 0; i < len(entries); i += 12 {
+		e := entries[i : i+12]
+		tag := bo.Uint16(e[0:2])
+		typ := bo.Uint16(e[2:4])
+		count := bo.Uint32(e[4:8])
+
+		if tag == tiffTagICCProfile {
+			// The profile is read only if it's needed. The profiles are never
+			// small enough to be stored in the entry itself.
+			if count > 4 && count <= 1<<24 {
+				iccOffset, iccSize = int64(bo.Uint32(e[8:12])), int64(count)
+			}
+			continue
+		}
+
+		var valueSize uint32
+		switch typ {
+		case 3: // SHORT
+			valueSize = 2
+		case 4: // LONG
+			valueSize = 4
+		default:
+			continue
+		}
+		if count == 0 || count > 1<<24 {
+			continue
+		}
+
+		data := e[8:12]
+		if count*valueSize > 4 {
+			var err error
+			data, err = readSection(r, size, int64(bo.Uint32(e[8:12])), int64(count*valueSize))
+			if err != nil {
+				return nil, errTIFFMalformed
+			}
+		}
+		vals := make([]uint32, count)
+		for j := range vals {
+			if valueSize == 2 {
+				vals[j] = uint32(bo.Uint16(data[j*2:]))
+			} else {
+				vals[j] = bo.Uint32(data[j*4:])
+			}
+		}
+		fields[tag] = vals
+	}
+
+	field := func(tag uint16, def uint32) uint32 {
+		if v, ok := fields[tag]; ok {
+			return v[0]
+		}
+		return def
+	}
+
+	t := &tiffLayout{
+		width:     int(field(tiffTagImageWidth, 0)),
+		height:    int(field(tiffTagImageLength, 0)),
+		samples:   int(field(tiffTagSamplesPerPixel, 1)),
+		size:      size,
+		iccOffset: iccOffset,
+		iccSize:   iccSize,
+	}
+	if t.width <= 0 || t.height <= 0 {
+		return nil, errTIFFFallback
+	}
+
+	// The default of BitsPerSample is 1, the bilevel images are decoded entirely.
+	bps, ok := fields[tiffTagBitsPerSample]
+	if !ok {
+		return nil, errTIFFFallback
+	}
+	for _, v := range bps {
+		if v != 8 {
+			return nil, errTIFFFallback
+		}
+	}
+	if field(tiffTagPlanarConfig, 1) != 1 {
+		return nil, errTIFFFallback
+	}
+
+	switch field(tiffTagCompression, 1) {
+	case 1:
+	case 8, 32946:
+		t.compressed = true
+	default:
+		return nil, errTIFFFallback
+	}
+
+	switch field(tiffTagPredictor, 1) {
+	case 1:
+	case 2:
+		t.predictor = true
+	default:
+		return nil, errTIFFFallback
+	}
+
+	switch field(tiffTagPhotometric, 0) {
+	case 1: // BlackIsZero
+		if t.samples != 1 {
+			return nil, errTIFFFallback
+		}
+	case 2: // RGB
+		switch t.samples {
+		case 3:
+		case 4:
+			switch field(tiffTagExtraSamples, 0) {
+			case 1:
+				t.associated = true
+			case 2:
+			default:
+				return nil, errTIFFFallback
+			}
+		default:
+			return nil, errTIFFFallback
+		}
+	default:
+		return nil, errTIFFFallback
+	}
+
+	if _, ok := fields[tiffTagTileWidth]; ok {
+		t.chunkW = int(field(tiffTagTileWidth, 0))
+		t.chunkH = int(field(tiffTagTileLength, 0))
+		t.offsets = fields[tiffTagTileOffsets]
+		t.counts = fields[tiffTagTileByteCounts]
+	} else {
+		t.chunkW = t.width
+		t.chunkH = int(field(tiffTagRowsPerStrip, uint32(t.height)))
+		if t.chunkH > t.height {
+			t.chunkH = t.height
+		}
+		t.offsets = fields[tiffTagStripOffsets]
+		t.counts = fields[tiffTagStripByteCounts]
+	}
+	if t.chunkW <= 0 || t.chunkH <= 0 {
+		return nil, errTIFFFallback
+	}
+
+	numChunks := (t.width + t.chunkW - 1) / t.chunkW * ((t.height + t.chunkH - 1) / t.chunkH)
+	if len(t.offsets) != numChunks || len(t.counts) != numChunks {
+		return nil, errTIFFFallback
+	}
+	if size >= 0 {
+		for i, off := range t.offsets {
+			if int64(off)+int64(t.counts[i]) > size {
+				return nil, errTIFFMalformed
+			}
+		}
+	}
+
+	return t, nil
+}
+
+// readerAtSize returns the size of the data of r, or -1 if it's unknown.
+func readerAtSize(r io.ReaderAt) int64 {
+	switch r := r.(type) {
+	case interface{ Size() int64 }:
+		return r.Size()
+	case interface{ Stat() (iofs.FileInfo, error) }:
+		if fi, err := r.Stat(); err == nil && fi.Mode().IsRegular() {
+			return fi.Size()
+		}
+	}
+	return -1
+}
+
+// readSection reads n bytes at the offset off of r with the data size, or -1 if unknown.
+// The sections beyond the data size are rejected without allocating the memory for them.
+// If the size is unknown, the memory is allocated as the data is read.
+func readSection(r io.ReaderAt, size, off, n int64) ([]byte, error) {
+	if off < 0 || n < 0 || (size >= 0 && off+n > size) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if size < 0 {
+		data, err := ioutil.ReadAll(io.NewSectionReader(r, off, n))
+		if err == nil && int64(len(data)) < n {
+			err = io.ErrUnexpectedEOF
+		}
+		return data, err
+	}
+	data := make([]byte, n)
+	if m, err := r.ReadAt(data, off); m < len(data) {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (t *tiffLayout) decodeRegion(r io.ReaderAt, rect image.Rectangle, limits *Limits) (*image.NRGBA, error) {
+	rect = rect.Intersect(image.Rect(0, 0, t.width, t.height))
+	if rect.Empty() {
+		return &image.NRGBA{}, nil
+	}
+	if limits != nil && (limits.exceeded(rect.Dx(), rect.Dy()) || limits.exceeded(t.chunkW, t.chunkH)) {
+		return nil, ErrLimitExceeded
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	across := (t.width + t.chunkW - 1) / t.chunkW
+
+	for cy := rect.Min.Y / t.chunkH; cy*t.chunkH < rect.Max.Y; cy++ {
+		for cx := rect.Min.X / t.chunkW; cx*t.chunkW < rect.Max.X; cx++ {
+			i := cy*across + cx
+			if limits != nil && limits.MaxBytes > 0 && int64(t.counts[i]) > limits.MaxBytes {
+				return nil, ErrLimitExceeded
+			}
+			buf, err := t.readChunk(r, i, cy)
+			if err != nil {
+				return nil, err
+			}
+			chunk := image.Rect(cx*t.chunkW, cy*t.chunkH, (cx+1)*t.chunkW, (cy+1)*t.chunkH)
+			t.copyChunk(dst, rect, buf, chunk)
+		}
+	}
+
+	return dst, nil
+}
+
+// readChunk reads and decompresses the i-th strip or tile.
+func (t *tiffLayout) readChunk(r io.ReaderAt, i, cy int) ([]byte, error) {
+	rows := t.chunkH
+	if t.chunkW == t.width && (cy+1)*t.chunkH > t.height {
+		// The last strip may be shorter than the others.
+		rows = t.height - cy*t.chunkH
+	}
+	rowSize := t.chunkW * t.samples
+	size := rowSize * rows
+
+	raw, err := readSection(r, t.size, int64(t.offsets[i]), int64(t.counts[i]))
+	if err != nil {
+		return nil, err
+	}
+
+	buf := raw
+	if t.compressed {
+		zr, err := zlib.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return nil, err
+		}
+		buf, err = ioutil.ReadAll(io.LimitReader(zr, int64(size)))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(buf) < size {
+		return nil, errors.New("imaging: TIFF chunk is too short")
+	}
+	buf = buf[:size]
+
+	if t.predictor {
+		for y := 0; y < rows; y++ {
+			row := buf[y*rowSize : (y+1)*rowSize]
+			for x := t.samples; x < rowSize; x++ {
+				row[x] += row[x-t.samples]
+			}
+		}
+	}
+	return buf, nil
+}
+
+// copyChunk copies the part of the chunk that intersects the region into dst.
+func (t *tiffLayout) copyChunk(dst *image.NRGBA, rect image.Rectangle, buf []byte, chunk image.Rectangle) {
+	inter := chunk.Intersect(rect)
+	rowSize := t.chunkW * t.samples
+	for y := inter.Min.Y; y < inter.Max.Y; y++ {
+		i := (y-chunk.Min.Y)*rowSize + (inter.Min.X-chunk.Min.X)*t.samples
+		j := (y-rect.Min.Y)*dst.Stride + (inter.Min.X-rect.Min.X)*4
+		for x := inter.Min.X; x < inter.Max.X; x++ {
+			s := buf[i : i+t.samples : i+t.samples]
+			d := dst.Pix[j : j+4 : j+4]
+			switch t.samples {
+			case 1:
+				d[0], d[1], d[2], d[3] = s[0], s[0], s[0], 0xff
+			case 3:
+				d[0], d[1], d[2], d[3] = s[0], s[1], s[2], 0xff
+			case 4:
+				a := s[3]
+				if t.associated && a != 0 && a != 0xff {
+					// The invalid samples exceeding the alpha are clamped.
+					a16 := uint16(a)
+					d[0] = uint8(min(uint16(s[0])*0xff/a16, 0xff))
+					d[1] = uint8(min(uint16(s[1])*0xff/a16, 0xff))
+					d[2] = uint8(min(uint16(s[2])*0xff/a16, 0xff))
+				} else {
+					d[0], d[1], d[2] = s[0], s[1], s[2]
+				}
+				d[3] = a
+			}
+			i += t.samples
+			j += 4
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"io"
+	"testing"
+)
+
+func TestDecodeRegion(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 5, 7))
+	for i := range src.Pix {
+		src.Pix[i] = uint8(i * 3)
+	}
+	for i := 3; i < len(src.Pix); i += 4 {
+		src.Pix[i] = 0xff
+	}
+
+	encode := func(format Format) []byte {
+		buf := &bytes.Buffer{}
+		if err := Encode(buf, src, format); err != nil {
+			t.Fatalf("failed to encode image: %v", err)
+		}
+		return buf.Bytes()
+	}
+	streamed := &bytes.Buffer{}
+	enc, err := NewRowEncoder(streamed, TIFF, 5, 7)
+	if err != nil {
+		t.Fatalf("NewRowEncoder: %v", err)
+	}
+	enc.AppendRows(src.Pix)
+	enc.Close()
+
+	sources := map[string][]byte{
+		"TIFF deflate": encode(TIFF),
+		"TIFF raw":     streamed.Bytes(),
+		"TIFF tiled":   makeTiledTIFF(src, 2, 3),
+		"PNG":          encode(PNG),
+	}
+
+	rects := []image.Rectangle{
+		image.Rect(0, 0, 5, 7),
+		image.Rect(1, 2, 4, 6),
+		image.Rect(3, 5, 10, 10),
+		image.Rect(-2, -2, 1, 1),
+	}
+
+	for name, data := range sources {
+		for _, rect := range rects {
+			t.Run(name+" "+rect.String(), func(t *testing.T) {
+				got, err := DecodeRegion(bytes.NewReader(data), rect)
+				if err != nil {
+					t.Fatalf("DecodeRegion: %v", err)
+				}
+				want := Crop(src, rect)
+				if !compareNRGBA(got, want, 0) {
+					t.Fatalf("got result %#v want %#v", got, want)
+				}
+			})
+		}
+	}
+
+	got, err := DecodeRegion(bytes.NewReader(sources["TIFF tiled"]), image.Rect(10, 10, 20, 20))
+	if err != nil {
+		t.Fatalf("DecodeRegion: %v", err)
+	}
+	if !compareNRGBA(got, &image.NRGBA{}, 0) {
+		t.Fatalf("got result %#v want empty image", got)
+	}
+
+	_, err = DecodeRegion(bytes.NewReader([]byte("bad data")), image.Rect(0, 0, 1, 1))
+	if err == nil {
+		t.Fatalf("decoding bad data: expected error got nil")
+	}
+}
+
+func TestDecodeRegionMalformed(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 5, 7))
+	for i := range src.Pix {
+		src.Pix[i] = 0xff
+	}
+	valid := makeTiledTIFF(src, 2, 3)
+	rect := image.Rect(0, 0, 5, 7)
+
+	// The byte count of the first tile is 4 GB.
+	data := append([]byte(nil), valid...)
+	countsOffset := binary.LittleEndian.Uint32(data[10+9*12+8:])
+	binary.LittleEndian.PutUint32(data[countsOffset:], 0xfffffff0)
+	if _, err := DecodeRegion(bytes.NewReader(data), rect); err != errTIFFMalformed {
+		t.Fatalf("got error %v want errTIFFMalformed", err)
+	}
+	// The data size is unknown.
+	if _, err := DecodeRegion(struct{ io.ReaderAt }{bytes.NewReader(data)}, rect); err == nil {
+		t.Fatalf("expected error got nil")
+	}
+
+	// The tile offsets are beyond the data.
+	data = append([]byte(nil), valid...)
+	binary.LittleEndian.PutUint32(data[10+8*12+4:], 1<<24)
+	if _, err := DecodeRegion(bytes.NewReader(data), rect); err != errTIFFMalformed {
+		t.Fatalf("got error %v want errTIFFMalformed", err)
+	}
+
+	// The default BitsPerSample is 1.
+	data = append([]byte(nil), valid...)
+	binary.LittleEndian.PutUint16(data[10+2*12:], 0xfffe)
+	if _, err := readTIFFLayout(bytes.NewReader(data)); err != errTIFFFallback {
+		t.Fatalf("got error %v want errTIFFFallback", err)
+	}
+}
+
+func TestDecodeRegionLimits(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 5, 7))
+	sources := map[string][]byte{
+		"TIFF tiled": makeTiledTIFF(src, 2, 3),
+		"PNG":        func() []byte { data, _ := EncodeBytes(src, PNG); return data }(),
+	}
+	for name, data := range sources {
+		if _, err := DecodeRegion(bytes.NewReader(data), image.Rect(0, 0, 2, 2), DecodeLimits(Limits{MaxPixels: 12})); err != nil && name != "PNG" {
+			t.Fatalf("%s: got error %v", name, err)
+		}
+		if _, err := DecodeRegion(bytes.NewReader(data), image.Rect(0, 0, 5, 5), DecodeLimits(Limits{MaxPixels: 12})); err != ErrLimitExceeded {
+			t.Fatalf("%s: got error %v want ErrLimitExceeded", name, err)
+		}
+	}
+	// The tiles larger than the limits aren't read.
+	if _, err := DecodeRegion(bytes.NewReader(sources["TIFF tiled"]), image.Rect(0, 0, 1, 1), DecodeLimits(Limits{MaxPixels: 4})); err != ErrLimitExceeded {
+		t.Fatalf("got error %v want ErrLimitExceeded", err)
+	}
+}
+
+// makeTiledTIFF encodes img as an uncompressed little-endian RGBA TIFF
+// split into tiles of the given size.
+func TestTIFFCopyChunkAssociatedAlpha(t *testing.T) {
+	// The samples exceeding the alpha are invalid, they are clamped instead of wrapping around.
+	layout := &tiffLayout{chunkW: 2, samples: 4, associated: true}
+	buf := []byte{200, 10, 0, 100, 0x40, 0x20, 0x10, 0x80}
+	dst := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	layout.copyChunk(dst, dst.Rect, buf, image.Rect(0, 0, 2, 1))
+	want := []byte{0xff, 25, 0, 100, 0x7f, 0x3f, 0x1f, 0x80}
+	if !bytes.Equal(dst.Pix, want) {
+		t.Fatalf("got pixels %v want %v", dst.Pix, want)
+	}
+}
+
+func makeTiledTIFF(img *image.NRGBA, tw, th int) []byte {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	across := (w + tw - 1) / tw
+	down := (h + th - 1) / th
+	numTiles := across * down
+	tileSize := tw * th * 4
+
+	const numEntries = 11
+	ifdSize := 2 + numEntries*12 + 4
+	bpsOffset := 8 + ifdSize
+	offsetsOffset := bpsOffset + 8
+	countsOffset := offsetsOffset + numTiles*4
+	dataOffset := countsOffset + numTiles*4
+
+	buf := make([]byte, dataOffset+numTiles*tileSize)
+	le := binary.LittleEndian
+	copy(buf, "II\x2a\x00")
+	le.PutUint32(buf[4:], 8)
+	le.PutUint16(buf[8:], numEntries)
+
+	entries := [][4]uint32{
+		{256, 4, 1, uint32(w)},
+		{257, 4, 1, uint32(h)},
+		{258, 3, 4, uint32(bpsOffset)},
+		{259, 3, 1, 1},
+		{262, 3, 1, 2},
+		{277, 3, 1, 4},
+		{322, 4, 1, uint32(tw)},
+		{323, 4, 1, uint32(th)},
+		{324, 4, uint32(numTiles), uint32(offsetsOffset)},
+		{325, 4, uint32(numTiles), uint32(countsOffset)},
+		{338, 3, 1, 2},
+	}
+	for i, e := range entries {
+		p := buf[10+i*12:]
+		le.PutUint16(p[0:], uint16(e[0]))
+		le.PutUint16(p[2:], uint16(e[1]))
+		le.PutUint32(p[4:], e[2])
+		if e[1] == 3 && e[2] == 1 {
+			le.PutUint16(p[8:], uint16(e[3]))
+		} else {
+			le.PutUint32(p[8:], e[3])
+		}
+	}
+	for i := 0; i < 4; i++ {
+		le.PutUint16(buf[bpsOffset+i*2:], 8)
+	}
+
+	for ty := 0; ty < down; ty++ {
+		for tx := 0; tx < across; tx++ {
+			i := ty*across + tx
+			off := dataOffset + i*tileSize
+			le.PutUint32(buf[offsetsOffset+i*4:], uint32(off))
+			le.PutUint32(buf[countsOffset+i*4:], uint32(tileSize))
+			for y := 0; y < th; y++ {
+				for x := 0; x < tw; x++ {
+					sx, sy := tx*tw+x, ty*th+y
+					if sx >= w || sy >= h {
+						continue
+					}
+					j := off + (y*tw+x)*4
+					copy(buf[j:j+4], img.Pix[sy*img.Stride+sx*4:])
+				}
+			}
+		}
+	}
+	return buf
+}
@@ -0,0 +1,87 @@
+package imaging
+
+// The resampling and blur loops spend most of the time in accumulateLine and
+// accumulateKernel, and the scanner in the row conversions of the YCbCr (JPEG)
+// and gray images. They have the AVX2 implementations on amd64, the other
+// architectures and the purego build use the Go versions. There is no arm64
+// NEON version yet. The assembly gives exactly the same results as the Go code:
+// it doesn't fuse the multiplications and additions, and the Go code converts
+// the products to float64 explicitly, which prevents the compiler from fusing
+// them into the FMA instructions (e.g. with GOAMD64=v3). The rows of the NRGBA
+// sources are copied with the built-in copy, and the conversions of the other
+// image types are dominated by the per-pixel branches and integer divisions.
+
+// accumulateLine computes the weighted sums of the source pixels for each destination
+// pixel of a resampled line. The i-th source pixel starts at src[i*4:], the sums of
+// the x-th destination pixel are written to acc[x*4:x*4+4] in the order
+// r*a*w, g*a*w, b*a*w, a*w, where w is the weight of the source pixel.
+func accumulateLine(acc []float64, src []uint8, weights [][]indexWeight) {
+	if len(acc) < len(weights)*4 {
+		panic("imaging: accumulateLine: acc is too short")
+	}
+	accumulateLineImpl(acc, src, weights)
+}
+
+func accumulateLineGeneric(acc []float64, src []uint8, weights [][]indexWeight) {
+	for x := range weights {
+		var r, g, b, a float64
+		for _, w := range weights[x] {
+			i := w.index * 4
+			s := src[i : i+4 : i+4]
+			aw := float64(float64(s[3]) * w.weight)
+			r += float64(float64(s[0]) * aw)
+			g += float64(float64(s[1]) * aw)
+			b += float64(float64(s[2]) * aw)
+			a += aw
+		}
+		d := acc[x*4 : x*4+4 : x*4+4]
+		d[0], d[1], d[2], d[3] = r, g, b, a
+	}
+}
+
+// accumulateKernel computes the weighted sums of the source pixels like accumulateLine
+// for the destination pixels that have the same weights of the consecutive source pixels:
+// the sums of the x-th destination pixel are computed from the pixels src[(x+k)*4:]
+// with the weights[k]. The number of the destination pixels is len(acc)/4.
+func accumulateKernel(acc []float64, src []uint8, weights []float64) {
+	n := len(acc) / 4
+	if n > 0 && len(src) < (n+len(weights)-1)*4 {
+		panic("imaging: accumulateKernel: src is too short")
+	}
+	accumulateKernelImpl(acc[:n*4], src, weights)
+}
+
+func accumulateKernelGeneric(acc []float64, src []uint8, weights []float64) {
+	for x := 0; x < len(acc)/4; x++ {
+		var r, g, b, a float64
+		for k, w := range weights {
+			i := (x + k) * 4
+			s := src[i : i+4 : i+4]
+			aw := float64(float64(s[3]) * w)
+			r += float64(float64(s[0]) * aw)
+			g += float64(float64(s[1]) * aw)
+			b += float64(float64(s[2]) * aw)
+			a += aw
+		}
+		d := acc[x*4 : x*4+4 : x*4+4]
+		d[0], d[1], d[2], d[3] = r, g, b, a
+	}
+}
+
+// storeLine converts the sums computed by accumulateLine to pixels. The x-th pixel
+// is written to dst[x*step:]. The pixels with zero alpha sum are left unchanged.
+func storeLine(dst []uint8, step int, acc []float64) {
+	for x := 0; x < len(acc)/4; x++ {
+		s := acc[x*4 : x*4+4 : x*4+4]
+		a := s[3]
+		if a == 0 {
+			continue
+		}
+		aInv := 1 / a
+		d := dst[x*step : x*step+4 : x*step+4]
+		d[0] = clamp(s[0] * aInv)
+		d[1] = clamp(s[1] * aInv)
+		d[2] = clamp(s[2] * aInv)
+		d[3] = clamp(a)
+	}
+}
@@ -0,0 +1,52 @@
+//go:build amd64 && !purego
+
+package imaging
+
+var (
+	accumulateLineImpl   = accumulateLineGeneric
+	accumulateKernelImpl = accumulateKernelGeneric
+)
+
+func init() {
+	if hasAVX2() {
+		accumulateLineImpl = accumulateLineAVX2
+		accumulateKernelImpl = accumulateKernelAVX2
+	}
+}
+
+// accumulateLineAVX2 is accumulateLineGeneric using the AVX2 instructions. All the
+// source indices in weights must be valid, they are not checked. The multiplications
+// and additions are not fused, so the results are identical to the generic version.
+//
+//go:noescape
+func accumulateLineAVX2(acc []float64, src []uint8, weights [][]indexWeight)
+
+// accumulateKernelAVX2 is accumulateKernelGeneric using the AVX2 instructions.
+// The length of src is not checked.
+//
+//go:noescape
+func accumulateKernelAVX2(acc []float64, src []uint8, weights []float64)
+
+func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+
+func xgetbv() (eax, edx uint32)
+
+// hasAVX2 reports whether the CPU and the operating system support the AVX2 instructions.
+func hasAVX2() bool {
+	maxLeaf, _, _, _ := cpuid(0, 0)
+	if maxLeaf < 7 {
+		return false
+	}
+	_, _, ecx1, _ := cpuid(1, 0)
+	const osxsave, avx = 1 << 27, 1 << 28
+	if ecx1&osxsave == 0 || ecx1&avx == 0 {
+		return false
+	}
+	// The OS must save the XMM and YMM registers.
+	if xcr0, _ := xgetbv(); xcr0&6 != 6 {
+		return false
+	}
+	_, ebx7, _, _ := cpuid(7, 0)
+	const avx2 = 1 << 5
+	return ebx7&avx2 != 0
+}
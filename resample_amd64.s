@@ -0,0 +1,119 @@
+//go:build amd64 && !purego
+
+#include "textflag.h"
+
+// func accumulateLineAVX2(acc []float64, src []uint8, weights [][]indexWeight)
+TEXT ·accumulateLineAVX2(SB), NOSPLIT, $0-72
+	MOVQ acc_base+0(FP), DI
+	MOVQ src_base+24(FP), SI
+	MOVQ weights_base+48(FP), R8
+	MOVQ weights_len+56(FP), R9
+
+	// Y15 = (1.0, 1.0, 1.0, 1.0), used to replace the alpha of the source pixel.
+	MOVQ         $0x3ff0000000000000, AX
+	VMOVQ        AX, X15
+	VBROADCASTSD X15, Y15
+
+	TESTQ R9, R9
+	JZ    done
+
+loopx:
+	MOVQ   0(R8), R10 // Weights of the destination pixel.
+	MOVQ   8(R8), R11
+	VXORPD Y0, Y0, Y0
+	TESTQ  R11, R11
+	JZ     store
+
+loopw:
+	MOVQ         0(R10), AX            // Source index.
+	VPMOVZXBD    (SI)(AX*4), X1        // r, g, b, a as int32.
+	VCVTDQ2PD    X1, Y1                // r, g, b, a as float64.
+	VBROADCASTSD 8(R10), Y2            // w
+	VPERMPD      $0xff, Y1, Y3         // a, a, a, a
+	VMULPD       Y2, Y3, Y3            // aw = a*w
+	VBLENDPD     $8, Y15, Y1, Y1       // r, g, b, 1
+	VMULPD       Y3, Y1, Y1            // r*aw, g*aw, b*aw, aw
+	VADDPD       Y1, Y0, Y0
+	ADDQ         $16, R10
+	DECQ         R11
+	JNZ          loopw
+
+store:
+	VMOVUPD Y0, (DI)
+	ADDQ    $32, DI
+	ADDQ    $24, R8
+	DECQ    R9
+	JNZ     loopx
+
+done:
+	VZEROUPPER
+	RET
+
+// func accumulateKernelAVX2(acc []float64, src []uint8, weights []float64)
+TEXT ·accumulateKernelAVX2(SB), NOSPLIT, $0-72
+	MOVQ acc_base+0(FP), DI
+	MOVQ acc_len+8(FP), R9
+	SHRQ $2, R9 // Number of the destination pixels.
+	MOVQ src_base+24(FP), SI
+	MOVQ weights_base+48(FP), R8
+	MOVQ weights_len+56(FP), R12
+
+	// Y15 = (1.0, 1.0, 1.0, 1.0), used to replace the alpha of the source pixel.
+	MOVQ         $0x3ff0000000000000, AX
+	VMOVQ        AX, X15
+	VBROADCASTSD X15, Y15
+
+	TESTQ R9, R9
+	JZ    kdone
+
+kloopx:
+	MOVQ   SI, R10 // First source pixel of the destination pixel.
+	MOVQ   R8, R11
+	MOVQ   R12, CX
+	VXORPD Y0, Y0, Y0
+	TESTQ  CX, CX
+	JZ     kstore
+
+kloopw:
+	VPMOVZXBD    (R10), X1       // r, g, b, a as int32.
+	VCVTDQ2PD    X1, Y1          // r, g, b, a as float64.
+	VBROADCASTSD (R11), Y2       // w
+	VPERMPD      $0xff, Y1, Y3   // a, a, a, a
+	VMULPD       Y2, Y3, Y3      // aw = a*w
+	VBLENDPD     $8, Y15, Y1, Y1 // r, g, b, 1
+	VMULPD       Y3, Y1, Y1      // r*aw, g*aw, b*aw, aw
+	VADDPD       Y1, Y0, Y0
+	ADDQ         $4, R10
+	ADDQ         $8, R11
+	DECQ         CX
+	JNZ          kloopw
+
+kstore:
+	VMOVUPD Y0, (DI)
+	ADDQ    $32, DI
+	ADDQ    $4, SI
+	DECQ    R9
+	JNZ     kloopx
+
+kdone:
+	VZEROUPPER
+	RET
+
+// func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+TEXT ·cpuid(SB), NOSPLIT, $0-24
+	MOVL eaxArg+0(FP), AX
+	MOVL ecxArg+4(FP), CX
+	CPUID
+	MOVL AX, eax+8(FP)
+	MOVL BX, ebx+12(FP)
+	MOVL CX, ecx+16(FP)
+	MOVL DX, edx+20(FP)
+	RET
+
+// func xgetbv() (eax, edx uint32)
+TEXT ·xgetbv(SB), NOSPLIT, $0-8
+	MOVL $0, CX
+	XGETBV
+	MOVL AX, eax+0(FP)
+	MOVL DX, edx+4(FP)
+	RET
@@ -0,0 +1,8 @@
+//go:build !amd64 || purego
+
+package imaging
+
+var (
+	accumulateLineImpl   = accumulateLineGeneric
+	accumulateKernelImpl = accumulateKernelGeneric
+)
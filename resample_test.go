@@ -0,0 +1,126 @@
+package imaging
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestAccumulateLine(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	src := make([]uint8, 97*4)
+	for i := range src {
+		src[i] = uint8(rnd.Intn(256))
+	}
+	for _, filter := range []ResampleFilter{Box, Linear, CatmullRom, Lanczos} {
+		for _, size := range []int{1, 10, 33, 97, 250} {
+			weights := precomputeWeights(size, 97, filter)
+			got := make([]float64, size*4)
+			want := make([]float64, size*4)
+			accumulateLine(got, src, weights)
+			accumulateLineGeneric(want, src, weights)
+			for i := range want {
+				if got[i] != want[i] {
+					t.Fatalf("size %d: got %v want %v at %d", size, got[i], want[i], i)
+				}
+			}
+		}
+	}
+}
+
+// TestAccumulateRandom compares the architecture-specific kernels with the Go versions
+// on the random pixels and weight tables. With the purego tag both are the Go versions.
+func TestAccumulateRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(2))
+	for iter := 0; iter < 200; iter++ {
+		src := make([]uint8, (1+rnd.Intn(300))*4)
+		for i := range src {
+			src[i] = uint8(rnd.Intn(256))
+		}
+		// Fully transparent and opaque pixels are common in the real images.
+		for i := 3; i < len(src); i += 4 {
+			switch rnd.Intn(4) {
+			case 0:
+				src[i] = 0
+			case 1:
+				src[i] = 0xff
+			}
+		}
+		n := len(src) / 4
+
+		weights := make([][]indexWeight, rnd.Intn(200))
+		for x := range weights {
+			weights[x] = make([]indexWeight, rnd.Intn(40))
+			for k := range weights[x] {
+				weights[x][k] = indexWeight{index: rnd.Intn(n), weight: rnd.NormFloat64()}
+			}
+		}
+		got := make([]float64, len(weights)*4)
+		want := make([]float64, len(weights)*4)
+		accumulateLineImpl(got, src, weights)
+		accumulateLineGeneric(want, src, weights)
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("accumulateLine (iteration %d): got %v want %v at %d", iter, got[i], want[i], i)
+			}
+		}
+
+		kernel := make([]float64, rnd.Intn(n+1))
+		for k := range kernel {
+			kernel[k] = rnd.NormFloat64()
+		}
+		size := n - len(kernel) + 1
+		if len(kernel) == 0 {
+			size = n
+		}
+		got = make([]float64, size*4)
+		want = make([]float64, size*4)
+		accumulateKernelImpl(got, src, kernel)
+		accumulateKernelGeneric(want, src, kernel)
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("accumulateKernel (iteration %d): got %v want %v at %d", iter, got[i], want[i], i)
+			}
+		}
+	}
+}
+
+func BenchmarkAccumulateLine(b *testing.B) {
+	src := make([]uint8, 1024*4)
+	for i := range src {
+		src[i] = uint8(i)
+	}
+	weights := precomputeWeights(300, 1024, Lanczos)
+	acc := make([]float64, 300*4)
+	b.Run("generic", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			accumulateLineGeneric(acc, src, weights)
+		}
+	})
+	b.Run("default", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			accumulateLine(acc, src, weights)
+		}
+	})
+}
+
+func BenchmarkAccumulateKernel(b *testing.B) {
+	src := make([]uint8, 1024*4)
+	for i := range src {
+		src[i] = uint8(i)
+	}
+	kernel := make([]float64, 19)
+	for i := range kernel {
+		kernel[i] = 1 / float64(len(kernel))
+	}
+	acc := make([]float64, (1024-len(kernel)+1)*4)
+	b.Run("generic", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			accumulateKernelGeneric(acc, src, kernel)
+		}
+	})
+	b.Run("default", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			accumulateKernel(acc, src, kernel)
+		}
+	})
+}
@@ -1,8 +1,12 @@
 package imaging
 
 import (
+	"container/list"
+	"context"
 	"image"
 	"math"
+	"reflect"
+	"sync"
 )
 
 type indexWeight struct {
@@ -54,6 +58,156 @@ func precomputeWeights(dstSize, srcSize int, filter ResampleFilter) [][]indexWei
 	return out
 }
 
+// weightsCacheSize is the default number of weight tables kept in the cache.
+const weightsCacheSize = 64
+
+// weightsCache is an LRU cache of the weight tables computed by precomputeWeights.
+// Only the tables for the predefined filters are cached, as the kernels
+// of other filters can't be compared reliably.
+var weightsCache = struct {
+	sync.Mutex
+	capacity int
+	order    *list.List // Most recently used first.
+	entries  map[weightsKey]*list.Element
+}{
+	capacity: weightsCacheSize,
+	order:    list.New(),
+	entries:  make(map[weightsKey]*list.Element),
+}
+
+type weightsKey struct {
+	dstSize, srcSize int
+	support          float64
+	kernel           uintptr
+}
+
+type weightsEntry struct {
+	key     weightsKey
+	weights [][]indexWeight
+}
+
+var (
+	predefinedKernelsOnce sync.Once
+	predefinedKernels     map[uintptr]bool
+)
+
+// isPredefinedKernel reports whether the kernel function belongs to one of the predefined filters.
+func isPredefinedKernel(kernel uintptr) bool {
+	predefinedKernelsOnce.Do(func() {
+		predefinedKernels = make(map[uintptr]bool)
+		for _, f := range []ResampleFilter{
+			Box, Linear, Hermite, MitchellNetravali, CatmullRom, BSpline, Gaussian,
+			Bartlett, Lanczos, Hann, Hamming, Blackman, Welch, Cosine,
+		} {
+			predefinedKernels[reflect.ValueOf(f.Kernel).Pointer()] = true
+		}
+	})
+	return predefinedKernels[kernel]
+}
+
+// SetWeightsCacheSize sets the maximum number of resampling weight tables kept in the cache.
+// The tables depend on the source size, the destination size and the filter, so services that
+// resize many images to the same sizes avoid computing them for every image. Only the tables
+// for the predefined filters are cached. A value <= 0 disables the cache. The default value is 64.
+func SetWeightsCacheSize(size int) {
+	c := &weightsCache
+	c.Lock()
+	defer c.Unlock()
+	if size < 0 {
+		size = 0
+	}
+	c.capacity = size
+	for c.order.Len() > c.capacity {
+		delete(c.entries, c.order.Remove(c.order.Back()).(*weightsEntry).key)
+	}
+}
+
+// weightsCost returns the estimated cost of resampling a line of pixels with the weights.
+func weightsCost(weights [][]indexWeight) int {
+	taps := 0
+	for _, w := range weights {
+		taps += len(w)
+	}
+	return taps * costTap
+}
+
+// cachedWeights is like precomputeWeights but returns the cached table if possible.
+// The returned table must not be modified.
+func cachedWeights(dstSize, srcSize int, filter ResampleFilter) [][]indexWeight {
+	kernel := reflect.ValueOf(filter.Kernel).Pointer()
+	if !isPredefinedKernel(kernel) {
+		return precomputeWeights(dstSize, srcSize, filter)
+	}
+	key := weightsKey{dstSize: dstSize, srcSize: srcSize, support: filter.Support, kernel: kernel}
+
+	c := &weightsCache
+	c.Lock()
+	if e, ok := c.entries[key]; ok {
+		c.order.MoveToFront(e)
+		c.Unlock()
+		return e.Value.(*weightsEntry).weights
+	}
+	capacity := c.capacity
+	c.Unlock()
+
+	weights := precomputeWeights(dstSize, srcSize, filter)
+	if capacity == 0 {
+		return weights
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	if _, ok := c.entries[key]; !ok && c.capacity > 0 {
+		c.entries[key] = c.order.PushFront(&weightsEntry{key: key, weights: weights})
+		for c.order.Len() > c.capacity {
+			delete(c.entries, c.order.Remove(c.order.Back()).(*weightsEntry).key)
+		}
+	}
+	return weights
+}
+
+type resizeConfig struct {
+	autoSharpen bool
+	noiseAware  bool
+	preshrink   bool
+}
+
+// ResizeOption sets an optional parameter for the Resize function.
+type ResizeOption func(*resizeConfig)
+
+// AutoSharpen returns a ResizeOption that sets the auto-sharpening mode.
+// If it's enabled, the downscaled image is sharpened with an unsharp mask whose amount
+// grows with the scale factor, restoring the crispness lost during downscaling.
+// Upscaled images are not sharpened. By default it's disabled.
+func AutoSharpen(enabled bool) ResizeOption {
+	return func(c *resizeConfig) {
+		c.autoSharpen = enabled
+	}
+}
+
+// NoiseAware returns a ResizeOption that sets the noise-aware downscaling mode.
+// If it's enabled, the noise level of the image is estimated for each region, and in the
+// noisy regions the result of the given filter is blended with the result of the Box filter,
+// which averages the noise out instead of sharpening it. Clean regions keep the sharpness
+// of the given filter. It's useful for thumbnails of high-ISO photos. By default it's disabled.
+func NoiseAware(enabled bool) ResizeOption {
+	return func(c *resizeConfig) {
+		c.noiseAware = enabled
+	}
+}
+
+// Preshrink returns a ResizeOption that sets the two-stage downscaling mode.
+// If it's enabled and the image is downscaled more than 2 times, it's first shrunk
+// by an integer factor averaging the blocks of pixels to about twice the target size,
+// and then resized with the given filter. The result is slightly softer than the
+// single-stage resize, but large shrink ratios are several times faster, as the kernels
+// of the final pass are small. By default it's disabled.
+func Preshrink(enabled bool) ResizeOption {
+	return func(c *resizeConfig) {
+		c.preshrink = enabled
+	}
+}
+
 // Resize resizes the image to the specified width and height using the specified resampling
 // filter and returns the transformed image. If one of width or height is 0, the image aspect
 // ratio is preserved.
@@ -62,128 +216,269 @@ func precomputeWeights(dstSize, srcSize int, filter ResampleFilter) [][]indexWei
 //
 //	dstImage := imaging.Resize(srcImage, 800, 600, imaging.Lanczos)
 //
-func Resize(img image.Image, width, height int, filter ResampleFilter) *image.NRGBA {
+//	// Sharpen the result like photo export tools do.
+//	dstImage = imaging.Resize(srcImage, 800, 0, imaging.Lanczos, imaging.AutoSharpen(true))
+//
+func Resize(img image.Image, width, height int, filter ResampleFilter, opts ...ResizeOption) *image.NRGBA {
+	var cfg resizeConfig
+	for _, option := range opts {
+		option(&cfg)
+	}
+
+	src := img
+	if cfg.preshrink && filter.Support > 0 {
+		src, width, height = preshrink(img, width, height)
+	}
+	dst := resize(context.Background(), nil, src, width, height, filter)
+	if cfg.noiseAware && filter.Support > Box.Support {
+		blendNoisyRegions(dst, img, filter)
+	}
+	if cfg.autoSharpen {
+		if amount := autoSharpenAmount(img.Bounds(), dst.Bounds()); amount > 0 {
+			unsharpMask(dst, autoSharpenSigma, amount, 0)
+		}
+	}
+	return dst
+}
+
+// preshrink shrinks the image by the largest integer factors that keep it at least
+// twice as large as the resize result. It returns the image unchanged if both factors are 1.
+// The returned size is the resize result size calculated from the original image size,
+// so it doesn't depend on the rounding of the shrunk image size.
+func preshrink(img image.Image, width, height int) (image.Image, int, int) {
+	srcW, srcH := img.Bounds().Dx(), img.Bounds().Dy()
+	if width < 0 || height < 0 || (width == 0 && height == 0) || srcW <= 0 || srcH <= 0 {
+		return img, width, height
+	}
+	dstW, dstH := resizeSize(srcW, srcH, width, height)
+	fx, fy := srcW/(2*dstW), srcH/(2*dstH)
+	if fx < 1 {
+		fx = 1
+	}
+	if fy < 1 {
+		fy = 1
+	}
+	if fx == 1 && fy == 1 {
+		return img, dstW, dstH
+	}
+	return shrinkBox(img, fx, fy), dstW, dstH
+}
+
+// shrinkBox shrinks the image by the integer factors, averaging each block of fx*fy pixels.
+// The blocks at the right and bottom edges may be smaller.
+func shrinkBox(img image.Image, fx, fy int) *image.NRGBA {
+	src := newScanner(img)
+	w, h := (src.w+fx-1)/fx, (src.h+fy-1)/fy
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	parallel(0, h, w*fx*fy*costTap, func(ys <-chan int) {
+		scanLine := make([]uint8, src.w*4)
+		sums := make([]uint64, w*4)
+		for y := range ys {
+			for i := range sums {
+				sums[i] = 0
+			}
+			y0, y1 := y*fy, y*fy+fy
+			if y1 > src.h {
+				y1 = src.h
+			}
+			for sy := y0; sy < y1; sy++ {
+				src.scan(0, sy, src.w, sy+1, scanLine)
+				for x := 0; x < w; x++ {
+					x0, x1 := x*fx, x*fx+fx
+					if x1 > src.w {
+						x1 = src.w
+					}
+					var r, g, b, a uint64
+					for i := x0 * 4; i < x1*4; i += 4 {
+						s := scanLine[i : i+4 : i+4]
+						sa := uint64(s[3])
+						r += uint64(s[0]) * sa
+						g += uint64(s[1]) * sa
+						b += uint64(s[2]) * sa
+						a += sa
+					}
+					d := sums[x*4 : x*4+4 : x*4+4]
+					d[0] += r
+					d[1] += g
+					d[2] += b
+					d[3] += a
+				}
+			}
+
+			rows := uint64(y1 - y0)
+			for x := 0; x < w; x++ {
+				s := sums[x*4 : x*4+4 : x*4+4]
+				a := s[3]
+				if a == 0 {
+					continue
+				}
+				cols := uint64(fx)
+				if x*fx+fx > src.w {
+					cols = uint64(src.w - x*fx)
+				}
+				n := rows * cols
+				j := y*dst.Stride + x*4
+				d := dst.Pix[j : j+4 : j+4]
+				d[0] = uint8((s[0] + a/2) / a)
+				d[1] = uint8((s[1] + a/2) / a)
+				d[2] = uint8((s[2] + a/2) / a)
+				d[3] = uint8((a + n/2) / n)
+			}
+		}
+	})
+	return dst
+}
+
+// autoSharpenSigma is the radius of the unsharp mask used by AutoSharpen.
+const autoSharpenSigma = 0.6
+
+// autoSharpenAmount returns the unsharp mask amount for the resize from src to dst size.
+// The amount grows logarithmically with the downscale factor.
+func autoSharpenAmount(src, dst image.Rectangle) float64 {
+	if dst.Dx() <= 0 || dst.Dy() <= 0 {
+		return 0
+	}
+	scale := math.Max(
+		float64(src.Dx())/float64(dst.Dx()),
+		float64(src.Dy())/float64(dst.Dy()),
+	)
+	if scale <= 1 {
+		return 0
+	}
+	return math.Min(0.3*math.Log2(scale), 1)
+}
+
+// ResizeInto is like Resize but writes the result into dst, reusing its pixel buffer
+// if it's large enough, and returns dst. It is useful for avoiding allocations when
+// processing many images of similar size. The intermediate image of the two passes is
+// kept in the spare capacity of the pixel buffer, which is reserved when the buffer has
+// to be reallocated, so reusing the result doesn't allocate any of the images.
+// The dst image must not share pixels with img.
+//
+// Example:
+//
+//	buf := &image.NRGBA{}
+//	for _, img := range images {
+//		buf = imaging.ResizeInto(buf, img, 128, 128, imaging.Lanczos)
+//		// Use buf before the next iteration.
+//	}
+//
+func ResizeInto(dst *image.NRGBA, img image.Image, width, height int, filter ResampleFilter) *image.NRGBA {
+	return resize(context.Background(), dst, img, width, height, filter)
+}
+
+// ResizeCtx is like Resize but stops processing and returns the context error
+// if the context is canceled or its deadline is exceeded before the resize is done.
+//
+// Example:
+//
+//	dstImage, err := imaging.ResizeCtx(r.Context(), srcImage, 800, 600, imaging.Lanczos)
+//
+func ResizeCtx(ctx context.Context, img image.Image, width, height int, filter ResampleFilter) (*image.NRGBA, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ctx = beginProgress(ctx, resizeWork(img, width, height, filter))
+	dst := resize(ctx, nil, img, width, height, filter)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	endProgress(ctx)
+	return dst, nil
+}
+
+func resize(ctx context.Context, dst *image.NRGBA, img image.Image, width, height int, filter ResampleFilter) *image.NRGBA {
 	dstW, dstH := width, height
 	if dstW < 0 || dstH < 0 {
-		return &image.NRGBA{}
+		return emptyNRGBA(dst)
 	}
 	if dstW == 0 && dstH == 0 {
-		return &image.NRGBA{}
+		return emptyNRGBA(dst)
 	}
 
 	srcW := img.Bounds().Dx()
 	srcH := img.Bounds().Dy()
 	if srcW <= 0 || srcH <= 0 {
-		return &image.NRGBA{}
+		return emptyNRGBA(dst)
 	}
 
-	// If new width or height is 0 then preserve aspect ratio, minimum 1px.
-	if dstW == 0 {
-		tmpW := float64(dstH) * float64(srcW) / float64(srcH)
-		dstW = int(math.Max(1.0, math.Floor(tmpW+0.5)))
-	}
-	if dstH == 0 {
-		tmpH := float64(dstW) * float64(srcH) / float64(srcW)
-		dstH = int(math.Max(1.0, math.Floor(tmpH+0.5)))
-	}
+	dstW, dstH = resizeSize(srcW, srcH, dstW, dstH)
 
 	if srcW == dstW && srcH == dstH {
-		return Clone(img)
+		return cloneInto(dst, img)
 	}
 
 	if filter.Support <= 0 {
 		// Nearest-neighbor special case.
-		return resizeNearest(img, dstW, dstH)
+		return resizeNearest(ctx, dst, img, dstW, dstH)
 	}
 
 	if srcW != dstW && srcH != dstH {
-		return resizeVertical(resizeHorizontal(img, dstW, filter), dstH, filter)
+		dst, tmp := withScratch(dst, image.Rect(0, 0, dstW, dstH), image.Rect(0, 0, dstW, srcH))
+		return resizeVertical(ctx, dst, resizeHorizontal(ctx, tmp, img, dstW, filter), dstH, filter)
 	}
 	if srcW != dstW {
-		return resizeHorizontal(img, dstW, filter)
+		return resizeHorizontal(ctx, dst, img, dstW, filter)
 	}
-	return resizeVertical(img, dstH, filter)
+	return resizeVertical(ctx, dst, img, dstH, filter)
+
+}
 
+// resizeSize returns the destination size of the resize. If the width or height
+// is 0 then it's calculated preserving the aspect ratio, minimum 1px.
+func resizeSize(srcW, srcH, dstW, dstH int) (int, int) {
+	if dstW == 0 {
+		tmpW := float64(dstH) * float64(srcW) / float64(srcH)
+		dstW = int(math.Max(1.0, math.Floor(tmpW+0.5)))
+	}
+	if dstH == 0 {
+		tmpH := float64(dstW) * float64(srcH) / float64(srcW)
+		dstH = int(math.Max(1.0, math.Floor(tmpH+0.5)))
+	}
+	return dstW, dstH
 }
 
-func resizeHorizontal(img image.Image, width int, filter ResampleFilter) *image.NRGBA {
+func resizeHorizontal(ctx context.Context, dst *image.NRGBA, img image.Image, width int, filter ResampleFilter) *image.NRGBA {
 	src := newScanner(img)
-	dst := image.NewNRGBA(image.Rect(0, 0, width, src.h))
-	weights := precomputeWeights(width, src.w, filter)
-	parallel(0, src.h, func(ys <-chan int) {
+	dst = newNRGBA(dst, image.Rect(0, 0, width, src.h))
+	weights := cachedWeights(width, src.w, filter)
+	parallelCtx(ctx, 0, src.h, weightsCost(weights), func(ys <-chan int) {
 		scanLine := make([]uint8, src.w*4)
+		acc := make([]float64, width*4)
 		for y := range ys {
 			src.scan(0, y, src.w, y+1, scanLine)
-			j0 := y * dst.Stride
-			for x := range weights {
-				var r, g, b, a float64
-				for _, w := range weights[x] {
-					i := w.index * 4
-					s := scanLine[i : i+4 : i+4]
-					aw := float64(s[3]) * w.weight
-					r += float64(s[0]) * aw
-					g += float64(s[1]) * aw
-					b += float64(s[2]) * aw
-					a += aw
-				}
-				if a != 0 {
-					aInv := 1 / a
-					j := j0 + x*4
-					d := dst.Pix[j : j+4 : j+4]
-					d[0] = clamp(r * aInv)
-					d[1] = clamp(g * aInv)
-					d[2] = clamp(b * aInv)
-					d[3] = clamp(a)
-				}
-			}
+			accumulateLine(acc, scanLine, weights)
+			storeLine(dst.Pix[y*dst.Stride:], 4, acc)
 		}
 	})
 	return dst
 }
 
-func resizeVertical(img image.Image, height int, filter ResampleFilter) *image.NRGBA {
+func resizeVertical(ctx context.Context, dst *image.NRGBA, img image.Image, height int, filter ResampleFilter) *image.NRGBA {
 	src := newScanner(img)
-	dst := image.NewNRGBA(image.Rect(0, 0, src.w, height))
-	weights := precomputeWeights(height, src.h, filter)
-	parallel(0, src.w, func(xs <-chan int) {
+	dst = newNRGBA(dst, image.Rect(0, 0, src.w, height))
+	weights := cachedWeights(height, src.h, filter)
+	parallelCtx(ctx, 0, src.w, weightsCost(weights), func(xs <-chan int) {
 		scanLine := make([]uint8, src.h*4)
+		acc := make([]float64, height*4)
 		for x := range xs {
 			src.scan(x, 0, x+1, src.h, scanLine)
-			for y := range weights {
-				var r, g, b, a float64
-				for _, w := range weights[y] {
-					i := w.index * 4
-					s := scanLine[i : i+4 : i+4]
-					aw := float64(s[3]) * w.weight
-					r += float64(s[0]) * aw
-					g += float64(s[1]) * aw
-					b += float64(s[2]) * aw
-					a += aw
-				}
-				if a != 0 {
-					aInv := 1 / a
-					j := y*dst.Stride + x*4
-					d := dst.Pix[j : j+4 : j+4]
-					d[0] = clamp(r * aInv)
-					d[1] = clamp(g * aInv)
-					d[2] = clamp(b * aInv)
-					d[3] = clamp(a)
-				}
-			}
+			accumulateLine(acc, scanLine, weights)
+			storeLine(dst.Pix[x*4:], dst.Stride, acc)
 		}
 	})
 	return dst
 }
 
 // resizeNearest is a fast nearest-neighbor resize, no filtering.
-func resizeNearest(img image.Image, width, height int) *image.NRGBA {
-	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+func resizeNearest(ctx context.Context, dst *image.NRGBA, img image.Image, width, height int) *image.NRGBA {
+	dst = newNRGBA(dst, image.Rect(0, 0, width, height))
 	dx := float64(img.Bounds().Dx()) / float64(width)
 	dy := float64(img.Bounds().Dy()) / float64(height)
 
 	if dx > 1 && dy > 1 {
 		src := newScanner(img)
-		parallel(0, height, func(ys <-chan int) {
+		parallelCtx(ctx, 0, height, width*costCopy, func(ys <-chan int) {
 			for y := range ys {
 				srcY := int((float64(y) + 0.5) * dy)
 				dstOff := y * dst.Stride
@@ -196,7 +491,7 @@ func resizeNearest(img image.Image, width, height int) *image.NRGBA {
 		})
 	} else {
 		src := toNRGBA(img)
-		parallel(0, height, func(ys <-chan int) {
+		parallelCtx(ctx, 0, height, width*costCopy, func(ys <-chan int) {
 			for y := range ys {
 				srcY := int((float64(y) + 0.5) * dy)
 				srcOff0 := srcY * src.Stride
@@ -240,6 +535,20 @@ func Fit(img image.Image, width, height int, filter ResampleFilter) *image.NRGBA
 		return Clone(img)
 	}
 
+	newW, newH := fitSize(srcW, srcH, maxW, maxH)
+	return Resize(img, newW, newH, filter)
+}
+
+// fitSize returns the size of the image scaled down to fit the maximum width and height.
+// It returns zero size if the maximum width or height is not positive.
+func fitSize(srcW, srcH, maxW, maxH int) (int, int) {
+	if maxW <= 0 || maxH <= 0 {
+		return 0, 0
+	}
+	if srcW <= maxW && srcH <= maxH {
+		return srcW, srcH
+	}
+
 	srcAspectRatio := float64(srcW) / float64(srcH)
 	maxAspectRatio := float64(maxW) / float64(maxH)
 
@@ -251,12 +560,12 @@ func Fit(img image.Image, width, height int, filter ResampleFilter) *image.NRGBA
 		newH = maxH
 		newW = int(float64(newH) * srcAspectRatio)
 	}
-
-	return Resize(img, newW, newH, filter)
+	return newW, newH
 }
 
 // Fill creates an image with the specified dimensions and fills it with the scaled source image.
 // To achieve the correct aspect ratio without stretching, the source image will be cropped.
+// The anchor parameter specifies which part of the scaled image is kept (e.g. Center, Top, BottomRight).
 //
 // Example:
 //
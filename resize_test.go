@@ -1,8 +1,11 @@
 package imaging
 
 import (
+	"context"
 	"fmt"
 	"image"
+	"image/color"
+	"math"
 	"path/filepath"
 	"testing"
 )
@@ -242,6 +245,47 @@ func TestResampleFilters(t *testing.T) {
 	}
 }
 
+func TestCachedWeights(t *testing.T) {
+	defer SetWeightsCacheSize(weightsCacheSize)
+	SetWeightsCacheSize(2)
+
+	same := func(a, b [][]indexWeight) bool { return &a[0][0] == &b[0][0] }
+
+	w1 := cachedWeights(10, 20, Lanczos)
+	if !same(w1, cachedWeights(10, 20, Lanczos)) {
+		t.Fatalf("expected the cached table to be reused")
+	}
+	want := precomputeWeights(10, 20, Lanczos)
+	for i := range want {
+		for j := range want[i] {
+			if w1[i][j] != want[i][j] {
+				t.Fatalf("got weights %v want %v", w1, want)
+			}
+		}
+	}
+	if same(w1, cachedWeights(10, 20, CatmullRom)) || same(w1, cachedWeights(10, 21, Lanczos)) {
+		t.Fatalf("expected different tables for different keys")
+	}
+	// Both tables above were added, so the first one is evicted.
+	if same(w1, cachedWeights(10, 20, Lanczos)) {
+		t.Fatalf("expected the least recently used table to be evicted")
+	}
+
+	custom := ResampleFilter{Support: 1, Kernel: func(x float64) float64 { return 1 - math.Abs(x) }}
+	if same(cachedWeights(10, 20, custom), cachedWeights(10, 20, custom)) {
+		t.Fatalf("expected tables for custom filters not to be cached")
+	}
+	wide := ResampleFilter{Support: 2, Kernel: Lanczos.Kernel}
+	if same(cachedWeights(10, 20, Lanczos), cachedWeights(10, 20, wide)) {
+		t.Fatalf("expected filters with different support to have different tables")
+	}
+
+	SetWeightsCacheSize(0)
+	if same(cachedWeights(10, 20, Lanczos), cachedWeights(10, 20, Lanczos)) {
+		t.Fatalf("expected the cache to be disabled")
+	}
+}
+
 func TestResizeGolden(t *testing.T) {
 	for name, filter := range map[string]ResampleFilter{
 		"out_resize_nearest.png": NearestNeighbor,
@@ -447,6 +491,28 @@ func TestFill(t *testing.T) {
 			Box,
 			&image.NRGBA{},
 		},
+		{
+			"Fill 4x2 2x2 BottomRight Box",
+			&image.NRGBA{
+				Rect:   image.Rect(-1, -1, 3, 1),
+				Stride: 4 * 4,
+				Pix: []uint8{
+					0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f,
+					0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1a, 0x1b, 0x1c, 0x1d, 0x1e, 0x1f,
+				},
+			},
+			2, 2,
+			BottomRight,
+			Box,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 2),
+				Stride: 2 * 4,
+				Pix: []uint8{
+					0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f,
+					0x18, 0x19, 0x1a, 0x1b, 0x1c, 0x1d, 0x1e, 0x1f,
+				},
+			},
+		},
 		{
 			"Fill 100x200 20x10 Center Linear",
 			image.NewRGBA(image.Rect(0, 0, 100, 200)),
@@ -874,6 +940,196 @@ func TestThumbnail(t *testing.T) {
 	}
 }
 
+func TestResizeCtx(t *testing.T) {
+	got, err := ResizeCtx(context.Background(), testdataBranchesPNG, 150, 0, Linear)
+	if err != nil {
+		t.Fatalf("ResizeCtx: %v", err)
+	}
+	want := Resize(testdataBranchesPNG, 150, 0, Linear)
+	if !compareNRGBA(got, want, 0) {
+		t.Fatalf("resulting image differs from Resize result")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	got, err = ResizeCtx(ctx, testdataBranchesPNG, 150, 0, Linear)
+	if err != context.Canceled {
+		t.Fatalf("got error %v want context.Canceled", err)
+	}
+	if got != nil {
+		t.Fatalf("got result %#v want nil", got)
+	}
+}
+
+func TestResizeInto(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 2, 2),
+		Stride: 2 * 4,
+		Pix: []uint8{
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0xff, 0x00, 0xff, 0x00, 0x00, 0xff, 0xff,
+		},
+	}
+	buf := New(10, 10, color.NRGBA{0xff, 0xff, 0xff, 0xff})
+	pix := buf.Pix
+
+	for _, size := range [][2]int{{4, 4}, {1, 4}, {2, 2}, {4, 0}} {
+		for _, f := range []ResampleFilter{NearestNeighbor, Box, Linear} {
+			want := Resize(src, size[0], size[1], f)
+			got := ResizeInto(buf, src, size[0], size[1], f)
+			if got != buf {
+				t.Fatalf("ResizeInto didn't return dst")
+			}
+			if &got.Pix[:1][0] != &pix[0] {
+				t.Fatalf("ResizeInto didn't reuse the pixel buffer")
+			}
+			if !compareNRGBA(got, want, 0) {
+				t.Fatalf("got result %#v want %#v", got, want)
+			}
+		}
+	}
+
+	got := ResizeInto(buf, src, 0, 0, Box)
+	if !got.Rect.Empty() || len(got.Pix) != 0 {
+		t.Fatalf("got result %#v want empty image", got)
+	}
+
+	got = ResizeInto(New(1, 1, color.Black), src, 20, 20, Linear)
+	want := Resize(src, 20, 20, Linear)
+	if !compareNRGBA(got, want, 0) {
+		t.Fatalf("got result %#v want %#v", got, want)
+	}
+}
+
+func TestResizeIntoAllocs(t *testing.T) {
+	SetMaxProcs(1)
+	defer SetMaxProcs(0)
+	src := image.NewNRGBA(image.Rect(0, 0, 400, 300))
+	buf := ResizeInto(&image.NRGBA{}, src, 200, 100, Lanczos)
+
+	// The intermediate image is kept in the capacity of buf, so neither it nor the result
+	// is allocated (the pixels and the header of each), only the header of the scratch image.
+	into := testing.AllocsPerRun(10, func() {
+		buf = ResizeInto(buf, src, 200, 100, Lanczos)
+	})
+	plain := testing.AllocsPerRun(10, func() {
+		Resize(src, 200, 100, Lanczos)
+	})
+	if into > plain-3 {
+		t.Fatalf("got %v allocations per ResizeInto call, Resize makes %v", into, plain)
+	}
+	if n := allocatedBytes(10, func() {
+		buf = ResizeInto(buf, src, 200, 100, Lanczos)
+	}); n >= 200*300*4 {
+		t.Fatalf("got %d bytes allocated per ResizeInto call", n)
+	}
+}
+
+func TestResizeAutoSharpen(t *testing.T) {
+	plain := Resize(testdataBranchesPNG, 150, 0, Linear)
+	got := Resize(testdataBranchesPNG, 150, 0, Linear, AutoSharpen(false))
+	if !compareNRGBA(got, plain, 0) {
+		t.Fatalf("disabled AutoSharpen changed the result")
+	}
+
+	// The amount for a 4x downscale is 0.6.
+	got = Resize(testdataBranchesPNG, 150, 0, Linear, AutoSharpen(true))
+	want := UnsharpMask(plain, autoSharpenSigma, 0.6, 0)
+	if !compareNRGBA(got, want, 0) {
+		t.Fatalf("resulting image differs from the expected unsharp mask")
+	}
+	if compareNRGBA(got, plain, 0) {
+		t.Fatalf("AutoSharpen didn't sharpen the downscaled image")
+	}
+
+	got = Resize(testdataFlowersSmallPNG, 480, 0, Linear, AutoSharpen(true))
+	if !compareNRGBA(got, Resize(testdataFlowersSmallPNG, 480, 0, Linear), 0) {
+		t.Fatalf("AutoSharpen changed the upscaled image")
+	}
+	// The alpha channel isn't sharpened.
+	src := New(400, 400, color.NRGBA{0, 0, 0, 0})
+	src = Paste(src, New(200, 200, color.NRGBA{200, 100, 50, 255}), image.Pt(100, 100))
+	plain = Resize(src, 100, 0, Linear)
+	got = Resize(src, 100, 0, Linear, AutoSharpen(true))
+	for i := 3; i < len(got.Pix); i += 4 {
+		if got.Pix[i] != plain.Pix[i] {
+			t.Fatalf("AutoSharpen changed the alpha at %d: got %d want %d", i/4, got.Pix[i], plain.Pix[i])
+		}
+	}
+}
+
+func TestAutoSharpenAmount(t *testing.T) {
+	testCases := []struct {
+		src, dst image.Rectangle
+		want     float64
+	}{
+		{image.Rect(0, 0, 100, 100), image.Rect(0, 0, 100, 100), 0},
+		{image.Rect(0, 0, 100, 100), image.Rect(0, 0, 200, 200), 0},
+		{image.Rect(0, 0, 100, 100), image.Rect(0, 0, 50, 50), 0.3},
+		{image.Rect(0, 0, 100, 100), image.Rect(0, 0, 100, 25), 0.6},
+		{image.Rect(0, 0, 4096, 4096), image.Rect(0, 0, 16, 16), 1},
+		{image.Rect(0, 0, 100, 100), image.Rectangle{}, 0},
+	}
+	for _, tc := range testCases {
+		got := autoSharpenAmount(tc.src, tc.dst)
+		if math.Abs(got-tc.want) > 1e-9 {
+			t.Fatalf("autoSharpenAmount(%v, %v): got %v want %v", tc.src, tc.dst, got, tc.want)
+		}
+	}
+}
+
+func TestResizePreshrink(t *testing.T) {
+	// The image is shrunk 3 times before the final pass.
+	got := Resize(testdataBranchesPNG, 100, 0, Lanczos, Preshrink(true))
+	want := Resize(testdataBranchesPNG, 100, 0, Lanczos)
+	if !compareNRGBA(got, want, 16) {
+		t.Fatalf("the result differs too much from the single-stage resize")
+	}
+	if compareNRGBA(got, want, 0) {
+		t.Fatalf("Preshrink didn't change the result")
+	}
+
+	// Small shrink ratios and upscaling are not affected.
+	for _, size := range []int{400, 1000} {
+		got := Resize(testdataBranchesPNG, size, 0, Lanczos, Preshrink(true))
+		if !compareNRGBA(got, Resize(testdataBranchesPNG, size, 0, Lanczos), 0) {
+			t.Fatalf("Preshrink changed the %dpx result", size)
+		}
+	}
+}
+
+func TestShrinkBox(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 3, 2),
+		Stride: 3 * 4,
+		Pix: []uint8{
+			0x00, 0x10, 0x20, 0xff, 0x40, 0x50, 0x60, 0xff, 0x11, 0x22, 0x33, 0x80,
+			0xff, 0x00, 0x00, 0x00, 0x80, 0x90, 0xa0, 0xff, 0x11, 0x22, 0x33, 0x00,
+		},
+	}
+	got := shrinkBox(src, 2, 2)
+	want := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 2, 1),
+		Stride: 2 * 4,
+		Pix:    []uint8{0x40, 0x50, 0x60, 0xbf, 0x11, 0x22, 0x33, 0x40},
+	}
+	if !compareNRGBA(got, want, 0) {
+		t.Fatalf("got %#v want %#v", got.Pix, want.Pix)
+	}
+}
+
+func BenchmarkResizePreshrink(b *testing.B) {
+	src := Resize(testdataBranchesJPG, 4000, 0, Linear)
+	for _, enabled := range []bool{false, true} {
+		b.Run(fmt.Sprintf("Preshrink=%v", enabled), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				Resize(src, 200, 0, Lanczos, Preshrink(enabled))
+			}
+		})
+	}
+}
+
 func BenchmarkResize(b *testing.B) {
 	for _, dir := range []string{"Down", "Up"} {
 		for _, filter := range []string{"NearestNeighbor", "Linear", "CatmullRom", "Lanczos"} {
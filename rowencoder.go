@@ -0,0 +1,287 @@
+package imaging
+
+import (
+	"bufio"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image/png"
+	"io"
+	"math"
+)
+
+var (
+	// ErrRowCount means that the number of rows written to a RowEncoder doesn't match the image height.
+	ErrRowCount = errors.New("imaging: row count doesn't match image height")
+
+	// ErrRowSize means that the pixel data passed to a RowEncoder is not a whole number of rows.
+	ErrRowSize = errors.New("imaging: pixel data is not a whole number of rows")
+)
+
+// RowEncoder encodes an image row by row as the rows become available,
+// without buffering the whole image in memory. PNG and TIFF formats are supported.
+type RowEncoder struct {
+	w      io.Writer
+	format Format
+	width  int
+	height int
+	rows   int
+	bw     *bufio.Writer
+	zw     *zlib.Writer
+	err    error
+}
+
+// NewRowEncoder creates a RowEncoder that writes an image of the given size to w
+// in the specified format (PNG or TIFF). It writes the image header immediately.
+// The rows must be passed to AppendRows in top to bottom order and the encoder
+// must be closed after the last row is written.
+//
+// Example:
+//
+//	enc, err := imaging.NewRowEncoder(w, imaging.PNG, 800, 600)
+//	if err != nil {
+//		return err
+//	}
+//	for y := 0; y < 600; y++ {
+//		if err := enc.AppendRows(row(y)); err != nil {
+//			return err
+//		}
+//	}
+//	return enc.Close()
+//
+func NewRowEncoder(w io.Writer, format Format, width, height int, opts ...EncodeOption) (*RowEncoder, error) {
+	if width <= 0 || height <= 0 {
+		return nil, errors.New("imaging: invalid image size")
+	}
+
+	cfg := newEncodeConfig(format, opts)
+
+	e := &RowEncoder{
+		w:      w,
+		format: format,
+		width:  width,
+		height: height,
+	}
+
+	switch format {
+	case PNG:
+		if err := e.writePNGHeader(cfg.pngCompressionLevel); err != nil {
+			return nil, err
+		}
+	case TIFF:
+		if err := e.writeTIFFHeader(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+
+	return e, nil
+}
+
+// AppendRows encodes one or more rows of pixels. The pix slice holds the rows
+// in the *image.NRGBA Pix layout (4 bytes per pixel, no padding between rows).
+func (e *RowEncoder) AppendRows(pix []byte) error {
+	if e.err != nil {
+		return e.err
+	}
+
+	rowSize := e.width * 4
+	if len(pix)%rowSize != 0 {
+		return ErrRowSize
+	}
+	n := len(pix) / rowSize
+	if e.rows+n > e.height {
+		return ErrRowCount
+	}
+
+	switch e.format {
+	case PNG:
+		for i := 0; i < n; i++ {
+			// Each PNG scanline is preceded by the filter type byte (0 = no filtering).
+			if _, e.err = e.zw.Write([]byte{0}); e.err != nil {
+				return e.err
+			}
+			if _, e.err = e.zw.Write(pix[i*rowSize : (i+1)*rowSize]); e.err != nil {
+				return e.err
+			}
+		}
+	case TIFF:
+		if _, e.err = e.w.Write(pix); e.err != nil {
+			return e.err
+		}
+	}
+
+	e.rows += n
+	return nil
+}
+
+// Close finishes the encoding. It returns ErrRowCount if fewer rows than
+// the image height were written. Close doesn't close the underlying writer.
+func (e *RowEncoder) Close() error {
+	if e.err != nil {
+		return e.err
+	}
+	if e.rows != e.height {
+		return ErrRowCount
+	}
+
+	if e.format == PNG {
+		if e.err = e.zw.Close(); e.err != nil {
+			return e.err
+		}
+		if e.err = e.bw.Flush(); e.err != nil {
+			return e.err
+		}
+		if e.err = writePNGChunk(e.w, "IEND", nil); e.err != nil {
+			return e.err
+		}
+	}
+
+	e.err = errors.New("imaging: RowEncoder is closed")
+	return nil
+}
+
+func (e *RowEncoder) writePNGHeader(level png.CompressionLevel) error {
+	if _, err := io.WriteString(e.w, "\x89PNG\r\n\x1a\n"); err != nil {
+		return err
+	}
+
+	var ihdr [13]byte
+	binary.BigEndian.PutUint32(ihdr[0:4], uint32(e.width))
+	binary.BigEndian.PutUint32(ihdr[4:8], uint32(e.height))
+	ihdr[8] = 8  // Bit depth.
+	ihdr[9] = 6  // Color type: truecolor with alpha.
+	ihdr[10] = 0 // Compression method.
+	ihdr[11] = 0 // Filter method.
+	ihdr[12] = 0 // Interlace method: no interlace.
+	if err := writePNGChunk(e.w, "IHDR", ihdr[:]); err != nil {
+		return err
+	}
+
+	e.bw = bufio.NewWriterSize(pngChunkWriter{e.w}, 1<<15)
+	zw, err := zlib.NewWriterLevel(e.bw, pngZlibLevel(level))
+	if err != nil {
+		return err
+	}
+	e.zw = zw
+	return nil
+}
+
+func (e *RowEncoder) writeTIFFHeader() error {
+	const (
+		tagImageWidth                = 256
+		tagImageLength               = 257
+		tagBitsPerSample             = 258
+		tagCompression               = 259
+		tagPhotometricInterpretation = 262
+		tagStripOffsets              = 273
+		tagSamplesPerPixel           = 277
+		tagRowsPerStrip              = 278
+		tagStripByteCounts           = 279
+		tagPlanarConfiguration       = 284
+		tagExtraSamples              = 338
+
+		typeShort = 3
+		typeLong  = 4
+
+		numEntries = 11
+		ifdOffset  = 8
+		bpsOffset  = ifdOffset + 2 + numEntries*12 + 4
+		dataOffset = bpsOffset + 8
+	)
+
+	dataSize := uint64(e.width) * uint64(e.height) * 4
+	if dataSize+dataOffset > math.MaxUint32 {
+		return errors.New("imaging: image is too large for TIFF")
+	}
+
+	type entry struct {
+		tag, typ uint16
+		count    uint32
+		value    uint32
+	}
+	entries := [numEntries]entry{
+		{tagImageWidth, typeLong, 1, uint32(e.width)},
+		{tagImageLength, typeLong, 1, uint32(e.height)},
+		{tagBitsPerSample, typeShort, 4, bpsOffset},
+		{tagCompression, typeShort, 1, 1},               // No compression.
+		{tagPhotometricInterpretation, typeShort, 1, 2}, // RGB.
+		{tagStripOffsets, typeLong, 1, dataOffset},
+		{tagSamplesPerPixel, typeShort, 1, 4},
+		{tagRowsPerStrip, typeLong, 1, uint32(e.height)},
+		{tagStripByteCounts, typeLong, 1, uint32(dataSize)},
+		{tagPlanarConfiguration, typeShort, 1, 1}, // Chunky.
+		{tagExtraSamples, typeShort, 1, 2},        // Unassociated alpha.
+	}
+
+	buf := make([]byte, dataOffset)
+	le := binary.LittleEndian
+	copy(buf[0:4], "II\x2a\x00")
+	le.PutUint32(buf[4:8], ifdOffset)
+	le.PutUint16(buf[ifdOffset:], numEntries)
+	for i, en := range entries {
+		p := buf[ifdOffset+2+i*12:]
+		le.PutUint16(p[0:2], en.tag)
+		le.PutUint16(p[2:4], en.typ)
+		le.PutUint32(p[4:8], en.count)
+		if en.typ == typeShort && en.count == 1 {
+			le.PutUint16(p[8:10], uint16(en.value))
+		} else {
+			le.PutUint32(p[8:12], en.value)
+		}
+	}
+	// The next IFD offset is left zero. Bits per sample follow the IFD.
+	for i := 0; i < 4; i++ {
+		le.PutUint16(buf[bpsOffset+i*2:], 8)
+	}
+
+	_, err := e.w.Write(buf)
+	return err
+}
+
+// pngZlibLevel returns the zlib compression level matching the PNG compression level.
+func pngZlibLevel(level png.CompressionLevel) int {
+	switch level {
+	case png.NoCompression:
+		return zlib.NoCompression
+	case png.BestSpeed:
+		return zlib.BestSpeed
+	case png.BestCompression:
+		return zlib.BestCompression
+	}
+	return zlib.DefaultCompression
+}
+
+type pngChunkWriter struct {
+	w io.Writer
+}
+
+func (cw pngChunkWriter) Write(p []byte) (int, error) {
+	if err := writePNGChunk(cw.w, "IDAT", p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func writePNGChunk(w io.Writer, name string, data []byte) error {
+	var header [8]byte
+	binary.BigEndian.PutUint32(header[0:4], uint32(len(data)))
+	copy(header[4:8], name)
+
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:8])
+	crc.Write(data)
+	var footer [4]byte
+	binary.BigEndian.PutUint32(footer[:], crc.Sum32())
+
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	_, err := w.Write(footer[:])
+	return err
+}
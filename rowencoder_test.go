@@ -0,0 +1,91 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"testing"
+)
+
+func TestRowEncoder(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 3, 4))
+	for i := range src.Pix {
+		src.Pix[i] = uint8(i * 5)
+	}
+	for i := 3; i < len(src.Pix); i += 4 {
+		if src.Pix[i] == 0 {
+			src.Pix[i] = 0xff
+		}
+	}
+
+	for _, format := range []Format{PNG, TIFF} {
+		for _, opts := range [][]EncodeOption{nil, {PNGCompressionLevel(png.NoCompression)}} {
+			t.Run(format.String(), func(t *testing.T) {
+				buf := &bytes.Buffer{}
+				enc, err := NewRowEncoder(buf, format, 3, 4, opts...)
+				if err != nil {
+					t.Fatalf("NewRowEncoder: %v", err)
+				}
+				if err := enc.AppendRows(src.Pix[:src.Stride]); err != nil {
+					t.Fatalf("AppendRows: %v", err)
+				}
+				if err := enc.AppendRows(src.Pix[src.Stride:]); err != nil {
+					t.Fatalf("AppendRows: %v", err)
+				}
+				if err := enc.Close(); err != nil {
+					t.Fatalf("Close: %v", err)
+				}
+
+				img, err := Decode(buf)
+				if err != nil {
+					t.Fatalf("Decode: %v", err)
+				}
+				got := Clone(img)
+				if !compareNRGBA(got, src, 0) {
+					t.Fatalf("got result %#v want %#v", got, src)
+				}
+			})
+		}
+	}
+}
+
+func TestRowEncoderErrors(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	if _, err := NewRowEncoder(buf, JPEG, 2, 2); err != ErrUnsupportedFormat {
+		t.Fatalf("got error %v want ErrUnsupportedFormat", err)
+	}
+	if _, err := NewRowEncoder(buf, PNG, 0, 2); err == nil {
+		t.Fatalf("expected error got nil")
+	}
+
+	enc, err := NewRowEncoder(buf, PNG, 2, 2)
+	if err != nil {
+		t.Fatalf("NewRowEncoder: %v", err)
+	}
+	if err := enc.AppendRows(make([]byte, 7)); err != ErrRowSize {
+		t.Fatalf("got error %v want ErrRowSize", err)
+	}
+	if err := enc.AppendRows(make([]byte, 24)); err != ErrRowCount {
+		t.Fatalf("got error %v want ErrRowCount", err)
+	}
+	if err := enc.AppendRows(make([]byte, 8)); err != nil {
+		t.Fatalf("AppendRows: %v", err)
+	}
+	if err := enc.Close(); err != ErrRowCount {
+		t.Fatalf("got error %v want ErrRowCount", err)
+	}
+
+	if _, err := NewRowEncoder(failWriter{}, TIFF, 2, 2); err != errWrite {
+		t.Fatalf("got error %v want errWrite", err)
+	}
+}
+
+var errWrite = errors.New("failed to write")
+
+type failWriter struct{}
+
+func (failWriter) Write(p []byte) (int, error) {
+	return 0, errWrite
+}
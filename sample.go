@@ -0,0 +1,118 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// SampleBilinear returns the color of the image at the fractional position (x, y),
+// in the coordinates of the image, bilinearly interpolated from the 4 nearest pixels.
+// The pixel centers are at the integer coordinates, so the pixels are returned unchanged
+// at those. The interpolation is the one used by Rotate and the other transforms: the colors
+// are weighted by their alpha, so the colors of the transparent pixels don't bleed into
+// the result. The edge mode sets how the pixels outside the image are sampled; with
+// EdgeTransparent the result matches Rotate with a transparent background.
+//
+// Example:
+//
+//	// Sample a pixel of the image rotated by 30 degrees around its center.
+//	c := imaging.SampleBilinear(img, cx+dx*cos-dy*sin, cy+dx*sin+dy*cos, imaging.EdgeTransparent)
+//
+func SampleBilinear(img image.Image, x, y float64, edge EdgeMode) color.NRGBA {
+	return sample(img, x, y, edge, 1, func(t float64) float64 {
+		return 1 - math.Abs(t)
+	})
+}
+
+// SampleBicubic is like SampleBilinear but interpolates from the 16 nearest pixels using
+// the Catmull-Rom cubic, the kernel of the CatmullRom resampling filter. It gives sharper
+// results than SampleBilinear when the image is magnified.
+//
+// Example:
+//
+//	c := imaging.SampleBicubic(img, 10.25, 20.5, imaging.EdgeClamp)
+//
+func SampleBicubic(img image.Image, x, y float64, edge EdgeMode) color.NRGBA {
+	return sample(img, x, y, edge, 2, func(t float64) float64 {
+		return bcspline(t, 0, 0.5)
+	})
+}
+
+// sample interpolates the color at (x, y) from the pixels within the given support
+// using the separable kernel.
+func sample(img image.Image, x, y float64, edge EdgeMode, support int, kernel func(float64) float64) color.NRGBA {
+	if math.IsNaN(x) || math.IsNaN(y) || math.IsInf(x, 0) || math.IsInf(y, 0) {
+		return color.NRGBA{}
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= 0 || h <= 0 {
+		return color.NRGBA{}
+	}
+	x, y = x-float64(b.Min.X), y-float64(b.Min.Y)
+	if edge == EdgeWrap || edge == EdgeMirror {
+		x, y = reduceSamplePos(x, w, edge), reduceSamplePos(y, h, edge)
+	} else {
+		// The positions far outside the image only sample the edge pixels or nothing.
+		x = max(-float64(w)-4, min(2*float64(w)+4, x))
+		y = max(-float64(h)-4, min(2*float64(h)+4, y))
+	}
+
+	x0, y0 := int(math.Floor(x)), int(math.Floor(y))
+	var wx, wy [4]float64
+	for i := 0; i < 2*support; i++ {
+		wx[i] = kernel(x - float64(x0-support+1+i))
+		wy[i] = kernel(y - float64(y0-support+1+i))
+	}
+
+	src, _ := img.(*image.NRGBA)
+	var s *scanner
+	if src == nil {
+		s = newScanner(img)
+	}
+	var buf [4]uint8
+	var r, g, bl, a float64
+	for j := 0; j < 2*support; j++ {
+		py := y0 - support + 1 + j
+		if edge == EdgeTransparent && (py < 0 || py >= h) {
+			continue
+		}
+		py = edgeIndex(py, h, edge)
+		for i := 0; i < 2*support; i++ {
+			px := x0 - support + 1 + i
+			if edge == EdgeTransparent && (px < 0 || px >= w) {
+				continue
+			}
+			px = edgeIndex(px, w, edge)
+			var p []uint8
+			if src != nil {
+				k := (py+b.Min.Y-src.Rect.Min.Y)*src.Stride + (px+b.Min.X-src.Rect.Min.X)*4
+				p = src.Pix[k : k+4 : k+4]
+			} else {
+				s.scan(px, py, px+1, py+1, buf[:])
+				p = buf[:]
+			}
+			wa := float64(p[3]) * wx[i] * wy[j]
+			r += float64(p[0]) * wa
+			g += float64(p[1]) * wa
+			bl += float64(p[2]) * wa
+			a += wa
+		}
+	}
+	if a <= 0 {
+		return color.NRGBA{}
+	}
+	aInv := 1 / a
+	return color.NRGBA{clamp(r * aInv), clamp(g * aInv), clamp(bl * aInv), clamp(a)}
+}
+
+// reduceSamplePos moves the position of a periodic edge mode into its first period
+// keeping the fraction, so that the sampled pixels don't change.
+func reduceSamplePos(v float64, n int, edge EdgeMode) float64 {
+	period := n
+	if edge == EdgeMirror {
+		period = max(2*(n-1), 1)
+	}
+	return v - float64(period)*math.Floor(v/float64(period))
+}
@@ -0,0 +1,96 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"image/color/palette"
+	"math"
+	"testing"
+)
+
+func TestSamplePixelCenters(t *testing.T) {
+	rect := image.Rect(-2, 3, 9, 10)
+	for _, img := range []image.Image{
+		makeNRGBAImage(rect, palette.Plan9),
+		makeGenericImage(rect, palette.Plan9),
+		Clone(testdataBranchesPNG).SubImage(image.Rect(5, 7, 16, 14)),
+	} {
+		b := img.Bounds()
+		want := Clone(img)
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				w := want.NRGBAAt(x-b.Min.X, y-b.Min.Y)
+				if w.A == 0 {
+					continue
+				}
+				if c := SampleBilinear(img, float64(x), float64(y), EdgeClamp); c != w {
+					t.Fatalf("%T: SampleBilinear got %v at (%d, %d) want %v", img, c, x, y, w)
+				}
+				if c := SampleBicubic(img, float64(x), float64(y), EdgeClamp); c != w {
+					t.Fatalf("%T: SampleBicubic got %v at (%d, %d) want %v", img, c, x, y, w)
+				}
+			}
+		}
+	}
+}
+
+func TestSampleBilinearRotate(t *testing.T) {
+	src := Clone(testdataFlowersSmallPNG).SubImage(image.Rect(30, 20, 90, 61))
+	const angle = 30
+	got := Rotate(src, angle, color.Transparent)
+	b := src.Bounds()
+	w, h := got.Rect.Dx(), got.Rect.Dy()
+	sin, cos := math.Sincos(math.Pi * angle / 180)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			xf, yf := rotatePoint(float64(x)-float64(w)/2+0.5, float64(y)-float64(h)/2+0.5, sin, cos)
+			xf += float64(b.Min.X) + float64(b.Dx())/2 - 0.5
+			yf += float64(b.Min.Y) + float64(b.Dy())/2 - 0.5
+			if c := SampleBilinear(src, xf, yf, EdgeTransparent); c != got.NRGBAAt(x, y) {
+				t.Fatalf("got %v at (%d, %d) want %v", c, x, y, got.NRGBAAt(x, y))
+			}
+		}
+	}
+}
+
+func TestSampleEdges(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 1))
+	copy(img.Pix, []uint8{
+		0, 0, 0, 255,
+		40, 40, 40, 255,
+		80, 80, 80, 255,
+		200, 200, 200, 255,
+	})
+	gray := func(v, a uint8) color.NRGBA { return color.NRGBA{v, v, v, a} }
+	testCases := []struct {
+		x    float64
+		edge EdgeMode
+		want color.NRGBA
+	}{
+		{-10, EdgeClamp, gray(0, 255)},
+		{13.7, EdgeClamp, gray(200, 255)},
+		{-1, EdgeWrap, gray(200, 255)},
+		{4*1000 + 1.5, EdgeWrap, gray(60, 255)},
+		{-1, EdgeMirror, gray(40, 255)},
+		{4.5, EdgeMirror, gray(60, 255)},
+		{-1, EdgeTransparent, color.NRGBA{}},
+		{-0.5, EdgeTransparent, gray(0, 128)},
+		{3.5, EdgeTransparent, gray(200, 128)},
+		{1e300, EdgeTransparent, color.NRGBA{}},
+		{math.NaN(), EdgeClamp, color.NRGBA{}},
+		{math.Inf(-1), EdgeClamp, color.NRGBA{}},
+	}
+	for _, tc := range testCases {
+		if got := SampleBilinear(img, tc.x, 0, tc.edge); got != tc.want {
+			t.Fatalf("x=%v edge=%d: got %v want %v", tc.x, tc.edge, got, tc.want)
+		}
+	}
+
+	// The Catmull-Rom cubic overshoots at the steep edge, the result is clamped.
+	if got := SampleBicubic(img, 2.5, 0, EdgeClamp); got.R <= 140 || got.A != 255 {
+		t.Fatalf("got %v", got)
+	}
+	if got := SampleBicubic(&image.NRGBA{}, 0, 0, EdgeClamp); got != (color.NRGBA{}) {
+		t.Fatalf("got %v for the empty image", got)
+	}
+}
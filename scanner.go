@@ -6,28 +6,111 @@ import (
 )
 
 type scanner struct {
-	image   image.Image
-	w, h    int
-	palette []color.NRGBA
+	image      image.Image
+	w, h       int
+	palette    []color.NRGBA
+	collectors []Collector
+	invalid    bool // The image buffers are inconsistent, it's scanned as transparent.
 }
 
 func newScanner(img image.Image) *scanner {
+	var collectors []Collector
+	for {
+		c, ok := img.(*collectingImage)
+		if !ok {
+			break
+		}
+		collectors = append(collectors, c.collectors...)
+		img = c.Image
+	}
 	s := &scanner{
-		image: img,
-		w:     img.Bounds().Dx(),
-		h:     img.Bounds().Dy(),
+		image:      img,
+		w:          img.Bounds().Dx(),
+		h:          img.Bounds().Dy(),
+		collectors: collectors,
+		invalid:    !validBuffers(img),
 	}
 	if img, ok := img.(*image.Paletted); ok {
-		s.palette = make([]color.NRGBA, len(img.Palette))
-		for i := 0; i < len(img.Palette); i++ {
+		// Indices missing from the palette map to transparent color.
+		s.palette = make([]color.NRGBA, 256)
+		for i := 0; i < len(img.Palette) && i < len(s.palette); i++ {
 			s.palette[i] = color.NRGBAModel.Convert(img.Palette[i]).(color.NRGBA)
 		}
 	}
 	return s
 }
 
+// Scanner reads the pixels of an image of any type as 8-bit non-premultiplied RGBA,
+// the same way the functions of this package do: the common image types are converted
+// without the per-pixel At calls, and the collectors attached by WithCollector observe
+// the read pixels. It is safe to scan different regions concurrently.
+//
+// Example:
+//
+//	s := imaging.NewScanner(img)
+//	b := s.Bounds()
+//	row := make([]uint8, b.Dx()*4)
+//	for y := b.Min.Y; y < b.Max.Y; y++ {
+//		s.ScanRect(image.Rect(b.Min.X, y, b.Max.X, y+1), row)
+//		// Process the row: row[x*4:x*4+4] is the R, G, B, A of the pixel b.Min.X+x.
+//	}
+//
+type Scanner struct {
+	s      *scanner
+	bounds image.Rectangle
+}
+
+// NewScanner returns a Scanner reading the given image.
+func NewScanner(img image.Image) *Scanner {
+	return &Scanner{s: newScanner(img), bounds: img.Bounds()}
+}
+
+// Bounds returns the bounds of the scanned image.
+func (s *Scanner) Bounds() image.Rectangle {
+	return s.bounds
+}
+
+// ScanRect reads the pixels of the rectangle r, given in the coordinates of the image,
+// into dst as consecutive rows of r.Dx() pixels of 4 bytes. The pixels of r outside
+// the image bounds are transparent. It panics if dst is shorter than r.Dx()*r.Dy()*4 bytes.
+func (s *Scanner) ScanRect(r image.Rectangle, dst []uint8) {
+	if r.Empty() {
+		return
+	}
+	w, h := r.Dx(), r.Dy()
+	dst = dst[:w*h*4]
+	in := r.Intersect(s.bounds)
+	if in == r {
+		s.s.scan(r.Min.X-s.bounds.Min.X, r.Min.Y-s.bounds.Min.Y, r.Max.X-s.bounds.Min.X, r.Max.Y-s.bounds.Min.Y, dst)
+		return
+	}
+	clear(dst)
+	if in.Empty() {
+		return
+	}
+	for y := in.Min.Y; y < in.Max.Y; y++ {
+		i := ((y-r.Min.Y)*w + in.Min.X - r.Min.X) * 4
+		s.s.scan(in.Min.X-s.bounds.Min.X, y-s.bounds.Min.Y, in.Max.X-s.bounds.Min.X, y+1-s.bounds.Min.Y, dst[i:i+in.Dx()*4])
+	}
+}
+
 // scan scans the given rectangular region of the image into dst.
 func (s *scanner) scan(x1, y1, x2, y2 int, dst []uint8) {
+	s.scanImage(x1, y1, x2, y2, dst)
+	for _, c := range s.collectors {
+		c.Collect(dst[:(x2-x1)*(y2-y1)*4])
+	}
+}
+
+func (s *scanner) scanImage(x1, y1, x2, y2 int, dst []uint8) {
+	if s.invalid {
+		size := (x2 - x1) * (y2 - y1) * 4
+		for i := range dst[:size] {
+			dst[i] = 0
+		}
+		return
+	}
+
 	switch img := s.image.(type) {
 	case *image.NRGBA:
 		size := (x2 - x1) * 4
@@ -136,19 +219,12 @@ func (s *scanner) scan(x1, y1, x2, y2 int, dst []uint8) {
 		}
 
 	case *image.Gray:
+		size := (x2 - x1) * 4
 		j := 0
 		for y := y1; y < y2; y++ {
 			i := y*img.Stride + x1
-			for x := x1; x < x2; x++ {
-				c := img.Pix[i]
-				d := dst[j : j+4 : j+4]
-				d[0] = c
-				d[1] = c
-				d[2] = c
-				d[3] = 0xff
-				j += 4
-				i++
-			}
+			convertGrayLineImpl(dst[j:j+size], img.Pix[i:i+x2-x1])
+			j += size
 		}
 
 	case *image.Gray16:
@@ -187,50 +263,67 @@ func (s *scanner) scan(x1, y1, x2, y2 int, dst []uint8) {
 				yBase = (y/2 - hy) * img.CStride
 			}
 
+			// The rows of the common subsample ratios are converted at once. With the
+			// negative x the chroma pairs are shifted (x/2 rounds toward zero), so those
+			// rows are converted pixel by pixel like the other ratios.
+			size := (x2 - x1) * 4
+			switch img.SubsampleRatio {
+			case image.YCbCrSubsampleRatio444, image.YCbCrSubsampleRatio440:
+				ic := yBase + (x1 - img.Rect.Min.X)
+				n := x2 - x1
+				convertYCbCrLineImpl(dst[j:j+size], img.Y[iy:iy+n], img.Cb[ic:ic+n], img.Cr[ic:ic+n], false)
+				j += size
+				continue
+			case image.YCbCrSubsampleRatio422, image.YCbCrSubsampleRatio420:
+				if x1 < 0 {
+					break
+				}
+				x := x1
+				if x%2 == 1 {
+					// The first pixel shares the chroma with the pixel on the left.
+					ic := yBase + (x/2 - hx)
+					convertYCbCrLineGeneric(dst[j:j+4], img.Y[iy:iy+1], img.Cb[ic:ic+1], img.Cr[ic:ic+1], false)
+					iy++
+					j += 4
+					x++
+				}
+				ic := yBase + (x/2 - hx)
+				n := x2 - x
+				nc := (n + 1) / 2
+				convertYCbCrLineImpl(dst[j:j+n*4], img.Y[iy:iy+n], img.Cb[ic:ic+nc], img.Cr[ic:ic+nc], true)
+				j += n * 4
+				continue
+			}
+
 			for x := x1; x < x2; x++ {
 				var ic int
 				switch img.SubsampleRatio {
-				case image.YCbCrSubsampleRatio444, image.YCbCrSubsampleRatio440:
-					ic = yBase + (x - img.Rect.Min.X)
 				case image.YCbCrSubsampleRatio422, image.YCbCrSubsampleRatio420:
 					ic = yBase + (x/2 - hx)
 				default:
 					ic = img.COffset(x, y)
 				}
+				convertYCbCrLineGeneric(dst[j:j+4], img.Y[iy:iy+1], img.Cb[ic:ic+1], img.Cr[ic:ic+1], false)
+				iy++
+				j += 4
+			}
+		}
 
-				yy1 := int32(img.Y[iy]) * 0x10101
-				cb1 := int32(img.Cb[ic]) - 128
-				cr1 := int32(img.Cr[ic]) - 128
-
-				r := yy1 + 91881*cr1
-				if uint32(r)&0xff000000 == 0 {
-					r >>= 16
-				} else {
-					r = ^(r >> 31)
-				}
-
-				g := yy1 - 22554*cb1 - 46802*cr1
-				if uint32(g)&0xff000000 == 0 {
-					g >>= 16
-				} else {
-					g = ^(g >> 31)
-				}
-
-				b := yy1 + 116130*cb1
-				if uint32(b)&0xff000000 == 0 {
-					b >>= 16
-				} else {
-					b = ^(b >> 31)
-				}
-
+	case *image.CMYK:
+		j := 0
+		for y := y1; y < y2; y++ {
+			i := y*img.Stride + x1*4
+			for x := x1; x < x2; x++ {
+				s := img.Pix[i : i+4 : i+4]
+				// The same conversion as color.CMYKToRGB.
+				w := 0xffff - uint32(s[3])*0x101
 				d := dst[j : j+4 : j+4]
-				d[0] = uint8(r)
-				d[1] = uint8(g)
-				d[2] = uint8(b)
+				d[0] = uint8((0xffff - uint32(s[0])*0x101) * w / 0xffff >> 8)
+				d[1] = uint8((0xffff - uint32(s[1])*0x101) * w / 0xffff >> 8)
+				d[2] = uint8((0xffff - uint32(s[2])*0x101) * w / 0xffff >> 8)
 				d[3] = 0xff
-
-				iy++
 				j += 4
+				i += 4
 			}
 		}
 
@@ -283,3 +376,57 @@ func (s *scanner) scan(x1, y1, x2, y2 int, dst []uint8) {
 		}
 	}
 }
+
+// convertGrayLineGeneric converts the gray pixels of src to the NRGBA pixels of dst.
+func convertGrayLineGeneric(dst, src []uint8) {
+	for i, c := range src {
+		d := dst[i*4 : i*4+4 : i*4+4]
+		d[0] = c
+		d[1] = c
+		d[2] = c
+		d[3] = 0xff
+	}
+}
+
+// convertYCbCrLineGeneric converts the YCbCr pixels to the NRGBA pixels of dst the same
+// way color.YCbCrToRGB does. The i-th pixel is yy[i], cb[i], cr[i], or yy[i], cb[i/2],
+// cr[i/2] if half is true.
+func convertYCbCrLineGeneric(dst, yy, cb, cr []uint8, half bool) {
+	for i := range yy {
+		ic := i
+		if half {
+			ic = i / 2
+		}
+
+		yy1 := int32(yy[i]) * 0x10101
+		cb1 := int32(cb[ic]) - 128
+		cr1 := int32(cr[ic]) - 128
+
+		r := yy1 + 91881*cr1
+		if uint32(r)&0xff000000 == 0 {
+			r >>= 16
+		} else {
+			r = ^(r >> 31)
+		}
+
+		g := yy1 - 22554*cb1 - 46802*cr1
+		if uint32(g)&0xff000000 == 0 {
+			g >>= 16
+		} else {
+			g = ^(g >> 31)
+		}
+
+		b := yy1 + 116130*cb1
+		if uint32(b)&0xff000000 == 0 {
+			b >>= 16
+		} else {
+			b = ^(b >> 31)
+		}
+
+		d := dst[i*4 : i*4+4 : i*4+4]
+		d[0] = uint8(r)
+		d[1] = uint8(g)
+		d[2] = uint8(b)
+		d[3] = 0xff
+	}
+}
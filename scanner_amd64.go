@@ -0,0 +1,51 @@
+//go:build amd64 && !purego
+
+package imaging
+
+var (
+	convertGrayLineImpl  = convertGrayLineGeneric
+	convertYCbCrLineImpl = convertYCbCrLineGeneric
+)
+
+func init() {
+	if hasAVX2() {
+		convertGrayLineImpl = convertGrayLineAVX2
+		convertYCbCrLineImpl = convertYCbCrLineAVX2
+	}
+}
+
+// convertGrayLineAVX2 converts the pixels in the blocks of 8 with the AVX2 instructions
+// and the rest with the Go code.
+func convertGrayLineAVX2(dst, src []uint8) {
+	n := len(src) &^ 7
+	if n > 0 {
+		grayLineAVX2(dst[:n*4], src[:n])
+	}
+	convertGrayLineGeneric(dst[n*4:], src[n:])
+}
+
+// convertYCbCrLineAVX2 converts the pixels in the blocks of 8 with the AVX2 instructions
+// and the rest with the Go code.
+func convertYCbCrLineAVX2(dst, yy, cb, cr []uint8, half bool) {
+	n := len(yy) &^ 7
+	nc := n
+	if half {
+		nc = n / 2
+	}
+	if n > 0 {
+		ycbcrLineAVX2(dst[:n*4], yy[:n], cb[:nc], cr[:nc], half)
+	}
+	convertYCbCrLineGeneric(dst[n*4:], yy[n:], cb[nc:], cr[nc:], half)
+}
+
+// grayLineAVX2 is convertGrayLineGeneric using the AVX2 instructions. The length of src
+// must be a multiple of 8, the length of dst is not checked.
+//
+//go:noescape
+func grayLineAVX2(dst, src []uint8)
+
+// ycbcrLineAVX2 is convertYCbCrLineGeneric using the AVX2 instructions. The length of yy
+// must be a multiple of 8, the lengths of dst, cb and cr are not checked.
+//
+//go:noescape
+func ycbcrLineAVX2(dst, yy, cb, cr []uint8, half bool)
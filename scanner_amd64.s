@@ -0,0 +1,120 @@
+//go:build amd64 && !purego
+
+#include "textflag.h"
+
+// BROADCASTD fills all the 32-bit lanes of the register with the constant.
+#define BROADCASTD(c, x, y) \
+	MOVL         c, AX \
+	VMOVD        AX, x \
+	VPBROADCASTD x, y
+
+// func grayLineAVX2(dst, src []uint8)
+TEXT ·grayLineAVX2(SB), NOSPLIT, $0-48
+	MOVQ dst_base+0(FP), DI
+	MOVQ src_base+24(FP), SI
+	MOVQ src_len+32(FP), CX
+	SHRQ $3, CX
+	JZ   gdone
+
+	BROADCASTD($0x10101, X6, Y6)
+	BROADCASTD($0xff000000, X14, Y14)
+
+gloop:
+	VPMOVZXBD (SI), Y0     // 8 gray values as int32.
+	VPMULLD   Y6, Y0, Y0   // c, c, c, 0
+	VPOR      Y14, Y0, Y0  // c, c, c, 0xff
+	VMOVDQU   Y0, (DI)
+	ADDQ      $8, SI
+	ADDQ      $32, DI
+	DECQ      CX
+	JNZ       gloop
+
+gdone:
+	VZEROUPPER
+	RET
+
+// func ycbcrLineAVX2(dst, yy, cb, cr []uint8, half bool)
+TEXT ·ycbcrLineAVX2(SB), NOSPLIT, $0-97
+	MOVQ dst_base+0(FP), DI
+	MOVQ yy_base+24(FP), SI
+	MOVQ yy_len+32(FP), CX
+	MOVQ cb_base+48(FP), R8
+	MOVQ cr_base+72(FP), R9
+	MOVB half+96(FP), DX
+	SHRQ $3, CX
+	JZ   ydone
+
+	BROADCASTD($0x10101, X6, Y6)
+	BROADCASTD($128, X7, Y7)
+	BROADCASTD($91881, X8, Y8)
+	BROADCASTD($22554, X9, Y9)
+	BROADCASTD($46802, X10, Y10)
+	BROADCASTD($116130, X11, Y11)
+	VPXOR Y12, Y12, Y12
+	BROADCASTD($0xffffff, X13, Y13)
+	BROADCASTD($0xff000000, X14, Y14)
+
+yloop:
+	TESTB DL, DL
+	JNZ   yhalf
+	VPMOVZXBD (R8), Y1 // 8 cb values as int32.
+	VPMOVZXBD (R9), Y2 // 8 cr values as int32.
+	ADDQ      $8, R8
+	ADDQ      $8, R9
+	JMP       yconv
+
+yhalf:
+	// Each of the 4 chroma values is used by 2 pixels.
+	VMOVD      (R8), X1
+	VPUNPCKLBW X1, X1, X1
+	VPMOVZXBD  X1, Y1
+	VMOVD      (R9), X2
+	VPUNPCKLBW X2, X2, X2
+	VPMOVZXBD  X2, Y2
+	ADDQ       $4, R8
+	ADDQ       $4, R9
+
+yconv:
+	VPMOVZXBD (SI), Y0     // 8 luma values as int32.
+	VPMULLD   Y6, Y0, Y0   // yy1 = y * 0x10101
+	VPSUBD    Y7, Y1, Y1   // cb1 = cb - 128
+	VPSUBD    Y7, Y2, Y2   // cr1 = cr - 128
+
+	VPMULLD Y8, Y2, Y3 // r = yy1 + 91881*cr1
+	VPADDD  Y0, Y3, Y3
+
+	VPMULLD Y9, Y1, Y4  // g = yy1 - 22554*cb1 - 46802*cr1
+	VPSUBD  Y4, Y0, Y4
+	VPMULLD Y10, Y2, Y5
+	VPSUBD  Y5, Y4, Y4
+
+	VPMULLD Y11, Y1, Y5 // b = yy1 + 116130*cb1
+	VPADDD  Y0, Y5, Y5
+
+	// Clamp to [0, 0xffffff] and take the high byte, the same as the
+	// branches of the Go code.
+	VPMAXSD Y12, Y3, Y3
+	VPMINSD Y13, Y3, Y3
+	VPSRLD  $16, Y3, Y3
+	VPMAXSD Y12, Y4, Y4
+	VPMINSD Y13, Y4, Y4
+	VPSRLD  $16, Y4, Y4
+	VPMAXSD Y12, Y5, Y5
+	VPMINSD Y13, Y5, Y5
+	VPSRLD  $16, Y5, Y5
+
+	VPSLLD  $8, Y4, Y4
+	VPSLLD  $16, Y5, Y5
+	VPOR    Y4, Y3, Y3
+	VPOR    Y5, Y3, Y3
+	VPOR    Y14, Y3, Y3 // r, g, b, 0xff
+	VMOVDQU Y3, (DI)
+
+	ADDQ $8, SI
+	ADDQ $32, DI
+	DECQ CX
+	JNZ  yloop
+
+ydone:
+	VZEROUPPER
+	RET
@@ -6,6 +6,7 @@ import (
 	"image/color"
 	"image/color/palette"
 	"image/draw"
+	"math/rand"
 	"testing"
 )
 
@@ -227,3 +228,147 @@ func readColumn(img image.Image, x int) []uint8 {
 	}
 	return column
 }
+
+func TestNewScanner(t *testing.T) {
+	rect := image.Rect(-1, -1, 15, 15)
+	colors := palette.Plan9
+	images := []image.Image{
+		makeNRGBAImage(rect, colors),
+		makeYCbCrImage(rect, colors, image.YCbCrSubsampleRatio420),
+		makePalettedImage(rect, colors),
+		makeGenericImage(rect, colors),
+	}
+	rects := []image.Rectangle{
+		rect,
+		image.Rect(2, 3, 9, 4),
+		image.Rect(-5, 10, 3, 20),
+		image.Rect(20, 20, 22, 22),
+	}
+	for _, img := range images {
+		s := NewScanner(img)
+		if s.Bounds() != rect {
+			t.Fatalf("got bounds %v want %v", s.Bounds(), rect)
+		}
+		for _, r := range rects {
+			dst := make([]uint8, r.Dx()*r.Dy()*4)
+			for i := range dst {
+				dst[i] = 0xaa
+			}
+			s.ScanRect(r, dst)
+			i := 0
+			for y := r.Min.Y; y < r.Max.Y; y++ {
+				for x := r.Min.X; x < r.Max.X; x++ {
+					want := color.NRGBA{}
+					if image.Pt(x, y).In(rect) {
+						want = color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+					}
+					got := color.NRGBA{dst[i], dst[i+1], dst[i+2], dst[i+3]}
+					if absint(int(got.R)-int(want.R)) > 1 || absint(int(got.G)-int(want.G)) > 1 ||
+						absint(int(got.B)-int(want.B)) > 1 || got.A != want.A {
+						t.Fatalf("%T %v: got color %v at (%d, %d) want %v", img, r, got, x, y, want)
+					}
+					i += 4
+				}
+			}
+		}
+	}
+}
+
+func TestScannerYCbCrRows(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, sr := range []image.YCbCrSubsampleRatio{
+		image.YCbCrSubsampleRatio444,
+		image.YCbCrSubsampleRatio422,
+		image.YCbCrSubsampleRatio420,
+		image.YCbCrSubsampleRatio440,
+	} {
+		for _, rect := range []image.Rectangle{
+			image.Rect(0, 0, 37, 5),
+			image.Rect(3, 1, 44, 6),
+			image.Rect(-5, -3, 30, 2),
+		} {
+			img := image.NewYCbCr(rect, sr)
+			rnd.Read(img.Y)
+			rnd.Read(img.Cb)
+			rnd.Read(img.Cr)
+			s := newScanner(img)
+			w, h := rect.Dx(), rect.Dy()
+			for y := 0; y < h; y++ {
+				for x1 := 0; x1 < 4; x1++ {
+					buf := make([]uint8, (w-x1)*4)
+					s.scan(x1, y, w, y+1, buf)
+					for x := x1; x < w; x++ {
+						c := img.YCbCrAt(rect.Min.X+x, rect.Min.Y+y)
+						r, g, b := color.YCbCrToRGB(c.Y, c.Cb, c.Cr)
+						want := []uint8{r, g, b, 0xff}
+						i := (x - x1) * 4
+						if got := buf[i : i+4]; string(got) != string(want) {
+							t.Fatalf("%v %v: got %v at (%d, %d) want %v", sr, rect, got, x, y, want)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// TestConvertLinesRandom compares the architecture-specific row conversions of the scanner
+// with the Go versions. With the purego tag both are the Go versions.
+func TestConvertLinesRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(2))
+	for iter := 0; iter < 200; iter++ {
+		n := rnd.Intn(100)
+		src := make([]uint8, n)
+		rnd.Read(src)
+		got := make([]uint8, n*4)
+		want := make([]uint8, n*4)
+		convertGrayLineImpl(got, src)
+		convertGrayLineGeneric(want, src)
+		if string(got) != string(want) {
+			t.Fatalf("convertGrayLine (iteration %d): got %v want %v", iter, got, want)
+		}
+
+		for _, half := range []bool{false, true} {
+			nc := n
+			if half {
+				nc = (n + 1) / 2
+			}
+			cb := make([]uint8, nc)
+			cr := make([]uint8, nc)
+			rnd.Read(cb)
+			rnd.Read(cr)
+			convertYCbCrLineImpl(got, src, cb, cr, half)
+			convertYCbCrLineGeneric(want, src, cb, cr, half)
+			if string(got) != string(want) {
+				t.Fatalf("convertYCbCrLine (iteration %d, half %v): got %v want %v", iter, half, got, want)
+			}
+		}
+	}
+}
+
+func BenchmarkScanYCbCr(b *testing.B) {
+	img := image.NewYCbCr(image.Rect(0, 0, 1024, 1024), image.YCbCrSubsampleRatio420)
+	for i := range img.Y {
+		img.Y[i] = uint8(i)
+	}
+	for i := range img.Cb {
+		img.Cb[i] = uint8(i * 3)
+		img.Cr[i] = uint8(i * 7)
+	}
+	s := newScanner(img)
+	buf := make([]uint8, 1024*4)
+	b.Run("generic", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for y := 0; y < 1024; y++ {
+				convertYCbCrLineGeneric(buf, img.Y[y*img.YStride:y*img.YStride+1024], img.Cb[y/2*img.CStride:], img.Cr[y/2*img.CStride:], true)
+			}
+		}
+	})
+	b.Run("default", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for y := 0; y < 1024; y++ {
+				s.scan(0, y, 1024, y+1, buf)
+			}
+		}
+	})
+}
@@ -0,0 +1,68 @@
+package imaging
+
+import (
+	"image"
+	"math"
+)
+
+// FrameSource provides the frames of a video or an animation one by one.
+type FrameSource interface {
+	// NextFrame returns the next frame. It returns a nil image or a non-nil error
+	// when there are no more frames.
+	NextFrame() (image.Image, error)
+}
+
+// FrameSourceFunc is an adapter to allow the use of ordinary functions as frame sources.
+type FrameSourceFunc func() (image.Image, error)
+
+// NextFrame calls f().
+func (f FrameSourceFunc) NextFrame() (image.Image, error) {
+	return f()
+}
+
+// sceneHistogramBins is the number of bins of each color channel histogram used by DetectScenes.
+const sceneHistogramBins = 16
+
+// DetectScenes reads all the frames from the source and returns the indices of the frames
+// that start new scenes, beginning with 0 for the first frame. A new scene starts when
+// the color histograms of consecutive frames differ by more than the threshold, which is
+// in the range (0, 1): 0 means identical histograms and 1 means that the frames have no
+// colors in common. Values around 0.3 work well for cuts in typical videos. The frames are
+// downscaled before the comparison, so the detection is fast and insensitive to noise.
+// The reading stops at the first error returned by the source.
+//
+// Example:
+//
+//	scenes := imaging.DetectScenes(frames, 0.3)
+//	for _, i := range scenes {
+//		// Use a frame from each scene as a thumbnail candidate.
+//	}
+//
+func DetectScenes(frames FrameSource, threshold float64) []int {
+	var scenes []int
+	var prev [3][]float64
+	for i := 0; ; i++ {
+		frame, err := frames.NextFrame()
+		if err != nil || frame == nil {
+			break
+		}
+		hist := colorHistogram(Fit(frame, 64, 64, Box), sceneHistogramBins)
+		if i == 0 || histogramDistance(prev, hist) > threshold {
+			scenes = append(scenes, i)
+		}
+		prev = hist
+	}
+	return scenes
+}
+
+// histogramDistance returns the average total variation distance between
+// the color histograms, in the range [0, 1].
+func histogramDistance(h1, h2 [3][]float64) float64 {
+	var d float64
+	for c := range h1 {
+		for i := range h1[c] {
+			d += math.Abs(h1[c][i] - h2[c][i])
+		}
+	}
+	return d / 6
+}
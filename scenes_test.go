@@ -0,0 +1,65 @@
+package imaging
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"testing"
+)
+
+func sliceFrames(frames []image.Image, err error) FrameSource {
+	return FrameSourceFunc(func() (image.Image, error) {
+		if len(frames) == 0 {
+			return nil, err
+		}
+		f := frames[0]
+		frames = frames[1:]
+		return f, nil
+	})
+}
+
+func TestDetectScenes(t *testing.T) {
+	frames := []image.Image{
+		testdataBranchesPNG,
+		AdjustBrightness(testdataBranchesPNG, 2),
+		Crop(testdataBranchesPNG, image.Rect(10, 10, 590, 390)),
+		testdataFlowersSmallPNG,
+		AdjustBrightness(testdataFlowersSmallPNG, -2),
+		New(100, 100, color.NRGBA{0x20, 0x40, 0xff, 0xff}),
+		testdataBranchesPNG,
+	}
+	if got, want := DetectScenes(sliceFrames(frames, io.EOF), 0.3), []int{0, 3, 5, 6}; !equalInts(got, want) {
+		t.Fatalf("got scenes %v want %v", got, want)
+	}
+	// The reading stops at the first error.
+	if got, want := DetectScenes(sliceFrames(frames[:4], errors.New("read error")), 0.3), []int{0, 3}; !equalInts(got, want) {
+		t.Fatalf("got scenes %v want %v", got, want)
+	}
+	if got := DetectScenes(sliceFrames(nil, io.EOF), 0.3); len(got) != 0 {
+		t.Fatalf("got scenes %v for no frames", got)
+	}
+}
+
+func TestHistogramDistance(t *testing.T) {
+	black := colorHistogram(New(10, 10, color.Black), sceneHistogramBins)
+	white := colorHistogram(New(10, 10, color.White), sceneHistogramBins)
+	if d := histogramDistance(black, black); d != 0 {
+		t.Fatalf("got distance %v for identical histograms", d)
+	}
+	if d := histogramDistance(black, white); d != 1 {
+		t.Fatalf("got distance %v for disjoint histograms", d)
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
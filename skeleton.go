@@ -0,0 +1,94 @@
+package imaging
+
+import (
+	"image"
+)
+
+// Skeletonize thins the shapes of the binary image down to lines one pixel wide running
+// along their middle, keeping the shapes connected, using the Zhang-Suen thinning algorithm.
+// It's useful for vectorization of line drawings and for measuring the length of lines
+// in diagrams. The pixels with values of 128 and higher are the foreground. The result
+// contains the skeleton pixels with the value of 255 on a background of 0.
+//
+// Example:
+//
+//	// Dark lines on a light background become the foreground.
+//	gray := image.NewGray(src.Bounds())
+//	draw.Draw(gray, gray.Bounds(), imaging.Invert(src), src.Bounds().Min, draw.Src)
+//	skeleton := imaging.Skeletonize(gray)
+//
+func Skeletonize(binary *image.Gray) *image.Gray {
+	w, h := binary.Rect.Dx(), binary.Rect.Dy()
+	dst := image.NewGray(image.Rect(0, 0, w, h))
+	if w <= 0 || h <= 0 {
+		return dst
+	}
+
+	// The image with a border of background pixels, so the neighbors always exist.
+	pw := w + 2
+	pix := make([]uint8, pw*(h+2))
+	for y := 0; y < h; y++ {
+		src := binary.Pix[binary.PixOffset(binary.Rect.Min.X, binary.Rect.Min.Y+y):]
+		for x := 0; x < w; x++ {
+			if src[x] >= 128 {
+				pix[(y+1)*pw+x+1] = 1
+			}
+		}
+	}
+
+	remove := make([]bool, len(pix))
+	for changed := true; changed; {
+		changed = false
+		for pass := 0; pass < 2; pass++ {
+			parallel(1, h+1, w*costPoint, func(ys <-chan int) {
+				for y := range ys {
+					for x := 1; x <= w; x++ {
+						i := y*pw + x
+						remove[i] = pix[i] == 1 && thinningRemovable(pix, i, pw, pass)
+					}
+				}
+			})
+			for i, r := range remove {
+				if r {
+					pix[i] = 0
+					changed = true
+				}
+			}
+		}
+	}
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if pix[(y+1)*pw+x+1] == 1 {
+				dst.Pix[y*dst.Stride+x] = 0xff
+			}
+		}
+	}
+	return dst
+}
+
+// thinningRemovable reports whether the foreground pixel i can be removed in the given
+// pass of the Zhang-Suen algorithm.
+func thinningRemovable(pix []uint8, i, stride, pass int) bool {
+	// The neighbors clockwise starting from the north one.
+	p := [8]uint8{
+		pix[i-stride], pix[i-stride+1], pix[i+1], pix[i+stride+1],
+		pix[i+stride], pix[i+stride-1], pix[i-1], pix[i-stride-1],
+	}
+	n := 0
+	transitions := 0
+	for k := 0; k < 8; k++ {
+		n += int(p[k])
+		if p[k] == 0 && p[(k+1)%8] == 1 {
+			transitions++
+		}
+	}
+	if n < 2 || n > 6 || transitions != 1 {
+		return false
+	}
+	north, east, south, west := p[0], p[2], p[4], p[6]
+	if pass == 0 {
+		return north*east*south == 0 && east*south*west == 0
+	}
+	return north*east*west == 0 && north*south*west == 0
+}
@@ -0,0 +1,130 @@
+package imaging
+
+import (
+	"image"
+	"testing"
+)
+
+func TestSkeletonize(t *testing.T) {
+	testCases := []struct {
+		name string
+		src  *image.Gray
+		want *image.Gray
+	}{
+		{
+			"thick line ends are shortened",
+			&image.Gray{
+				Rect:   image.Rect(-1, -1, 6, 4),
+				Stride: 7,
+				Pix: []uint8{
+					0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+					0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00,
+					0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00,
+					0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00,
+					0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+				},
+			},
+			&image.Gray{
+				Rect:   image.Rect(0, 0, 7, 5),
+				Stride: 7,
+				Pix: []uint8{
+					0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+					0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+					0x00, 0x00, 0xff, 0xff, 0x00, 0x00, 0x00,
+					0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+					0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+				},
+			},
+		},
+		{
+			"single line is kept",
+			&image.Gray{
+				Rect:   image.Rect(0, 0, 3, 3),
+				Stride: 3,
+				Pix: []uint8{
+					0xff, 0x00, 0x00,
+					0x00, 0x80, 0x00,
+					0x00, 0x00, 0xc0,
+				},
+			},
+			&image.Gray{
+				Rect:   image.Rect(0, 0, 3, 3),
+				Stride: 3,
+				Pix: []uint8{
+					0xff, 0x00, 0x00,
+					0x00, 0xff, 0x00,
+					0x00, 0x00, 0xff,
+				},
+			},
+		},
+		{
+			"empty",
+			&image.Gray{},
+			&image.Gray{Rect: image.Rect(0, 0, 0, 0), Stride: 0, Pix: []uint8{}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Skeletonize(tc.src)
+			if got.Rect != tc.want.Rect || string(got.Pix) != string(tc.want.Pix) {
+				t.Fatalf("got result %#v want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSkeletonizeConnected(t *testing.T) {
+	// A thick ring must stay a closed loop one pixel wide.
+	src := image.NewGray(image.Rect(0, 0, 40, 40))
+	for y := 0; y < 40; y++ {
+		for x := 0; x < 40; x++ {
+			dx, dy := x-20, y-20
+			if d := dx*dx + dy*dy; d >= 10*10 && d <= 16*16 {
+				src.Pix[y*src.Stride+x] = 0xff
+			}
+		}
+	}
+	got := Skeletonize(src)
+
+	count := 0
+	var start image.Point
+	for y := 0; y < 40; y++ {
+		for x := 0; x < 40; x++ {
+			if got.GrayAt(x, y).Y == 0xff {
+				if src.GrayAt(x, y).Y != 0xff {
+					t.Fatalf("skeleton pixel (%d, %d) is outside of the shape", x, y)
+				}
+				count++
+				start = image.Pt(x, y)
+			}
+		}
+	}
+	// The circumference of the middle circle is about 2*pi*13 = 82 pixels.
+	if count < 60 || count > 110 {
+		t.Fatalf("got %d skeleton pixels", count)
+	}
+
+	// All the skeleton pixels are 8-connected.
+	seen := map[image.Point]bool{start: true}
+	stack := []image.Point{start}
+	for len(stack) > 0 {
+		p := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				q := p.Add(image.Pt(dx, dy))
+				if q.In(got.Rect) && got.GrayAt(q.X, q.Y).Y == 0xff && !seen[q] {
+					seen[q] = true
+					stack = append(stack, q)
+				}
+			}
+		}
+	}
+	if len(seen) != count {
+		t.Fatalf("got %d connected pixels of %d", len(seen), count)
+	}
+	// The center of the ring is not filled.
+	if got.GrayAt(20, 20).Y != 0 {
+		t.Fatalf("the hole of the ring is filled")
+	}
+}
@@ -0,0 +1,104 @@
+package imaging
+
+import (
+	"image"
+)
+
+// JPEGMaxSize is the maximum width and height of an image that can be encoded as JPEG.
+const JPEGMaxSize = 65535
+
+// SplitMax splits the image into a grid of overlapping segments, each no larger than
+// maxW x maxH pixels, and returns them in row-major order (left to right, top to bottom).
+// Neighboring segments share overlap pixels. It returns nil if maxW or maxH is not greater
+// than overlap. The original image can be restored from the segments using JoinStrips.
+//
+// Example:
+//
+//	// Split a huge panorama into parts that can be saved as JPEG.
+//	parts := imaging.SplitMax(pano, imaging.JPEGMaxSize, imaging.JPEGMaxSize, 0)
+//
+func SplitMax(img image.Image, maxW, maxH int, overlap int) []*image.NRGBA {
+	if overlap < 0 || maxW <= overlap || maxH <= overlap {
+		return nil
+	}
+
+	b := img.Bounds()
+	xs := splitSpans(b.Dx(), maxW, overlap)
+	ys := splitSpans(b.Dy(), maxH, overlap)
+
+	parts := make([]*image.NRGBA, 0, len(xs)*len(ys))
+	for _, y := range ys {
+		for _, x := range xs {
+			r := image.Rect(x[0], y[0], x[1], y[1]).Add(b.Min)
+			parts = append(parts, Crop(img, r))
+		}
+	}
+	return parts
+}
+
+// SplitColumns returns the number of columns in the grid of segments produced
+// by SplitMax for an image of the given width.
+func SplitColumns(width, maxW, overlap int) int {
+	if overlap < 0 || maxW <= overlap {
+		return 0
+	}
+	return len(splitSpans(width, maxW, overlap))
+}
+
+// splitSpans divides the size into spans no longer than max that overlap by the given amount.
+func splitSpans(size, max, overlap int) [][2]int {
+	if size <= 0 {
+		return nil
+	}
+	step := max - overlap
+	var spans [][2]int
+	for start := 0; ; start += step {
+		end := start + max
+		if end >= size {
+			spans = append(spans, [2]int{start, size})
+			return spans
+		}
+		spans = append(spans, [2]int{start, end})
+	}
+}
+
+// JoinStrips joins the segments produced by SplitMax back into a single image.
+// The parts must be given in row-major order, columns is the number of segments
+// in each row and overlap is the number of pixels shared by neighboring segments.
+//
+// Example:
+//
+//	cols := imaging.SplitColumns(pano.Bounds().Dx(), 8000, 16)
+//	parts := imaging.SplitMax(pano, 8000, 8000, 16)
+//	...
+//	pano = imaging.JoinStrips(parts, cols, 16)
+//
+func JoinStrips(parts []*image.NRGBA, columns, overlap int) *image.NRGBA {
+	if len(parts) == 0 || columns <= 0 || len(parts)%columns != 0 || overlap < 0 {
+		return &image.NRGBA{}
+	}
+	rows := len(parts) / columns
+
+	xs := make([]int, columns+1)
+	for c := 0; c < columns; c++ {
+		xs[c+1] = xs[c] + parts[c].Bounds().Dx() - overlap
+	}
+	ys := make([]int, rows+1)
+	for r := 0; r < rows; r++ {
+		ys[r+1] = ys[r] + parts[r*columns].Bounds().Dy() - overlap
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, xs[columns]+overlap, ys[rows]+overlap))
+	for i, part := range parts {
+		pos := image.Pt(xs[i%columns], ys[i/columns])
+		src := newScanner(part)
+		r := image.Rectangle{Min: pos, Max: pos.Add(image.Pt(src.w, src.h))}.Intersect(dst.Rect)
+		parallel(r.Min.Y, r.Max.Y, src.w*costCopy, func(ys <-chan int) {
+			for y := range ys {
+				j := y*dst.Stride + r.Min.X*4
+				src.scan(0, y-pos.Y, r.Dx(), y-pos.Y+1, dst.Pix[j:j+r.Dx()*4])
+			}
+		})
+	}
+	return dst
+}
@@ -0,0 +1,61 @@
+package imaging
+
+import (
+	"image"
+	"testing"
+)
+
+func TestSplitMax(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(-2, -2, 8, 3))
+	for i := range src.Pix {
+		src.Pix[i] = uint8(i)
+	}
+
+	testCases := []struct {
+		name        string
+		maxW, maxH  int
+		overlap     int
+		wantSizes   []image.Point
+		wantColumns int
+	}{
+		{"fits", 10, 5, 0, []image.Point{{10, 5}}, 1},
+		{"columns", 4, 5, 0, []image.Point{{4, 5}, {4, 5}, {2, 5}}, 3},
+		{"grid overlap", 6, 4, 2, []image.Point{{6, 4}, {6, 4}, {6, 3}, {6, 3}}, 2},
+		{"invalid", 2, 4, 2, nil, 0},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			parts := SplitMax(src, tc.maxW, tc.maxH, tc.overlap)
+			if len(parts) != len(tc.wantSizes) {
+				t.Fatalf("got %d parts want %d", len(parts), len(tc.wantSizes))
+			}
+			for i, p := range parts {
+				if p.Rect.Size() != tc.wantSizes[i] {
+					t.Fatalf("part %d: got size %v want %v", i, p.Rect.Size(), tc.wantSizes[i])
+				}
+			}
+			cols := SplitColumns(src.Rect.Dx(), tc.maxW, tc.overlap)
+			if cols != tc.wantColumns {
+				t.Fatalf("got %d columns want %d", cols, tc.wantColumns)
+			}
+			if len(parts) == 0 {
+				return
+			}
+			got := JoinStrips(parts, cols, tc.overlap)
+			want := Clone(src)
+			if !compareNRGBA(got, want, 0) {
+				t.Fatalf("got joined result %#v want %#v", got, want)
+			}
+		})
+	}
+}
+
+func TestJoinStripsInvalid(t *testing.T) {
+	parts := []*image.NRGBA{New(2, 2, image.Black), New(2, 2, image.Black), New(2, 2, image.Black)}
+	for _, cols := range []int{0, 2} {
+		got := JoinStrips(parts, cols, 0)
+		if !compareNRGBA(got, &image.NRGBA{}, 0) {
+			t.Fatalf("got result %#v want empty image", got)
+		}
+	}
+}
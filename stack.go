@@ -0,0 +1,115 @@
+package imaging
+
+import (
+	"image"
+	"slices"
+)
+
+// StackMode is the way Stack combines the values of a pixel across the images.
+type StackMode int
+
+const (
+	// StackMedian takes the median, removing the noise and the moving objects.
+	StackMedian StackMode = iota
+	// StackMean takes the mean in linear light, simulating a long exposure.
+	StackMean
+	// StackMax takes the brightest value, as used for the star and light trails.
+	StackMax
+	// StackMin takes the darkest value.
+	StackMin
+)
+
+// Stack merges a series of shots of the same scene by combining each channel of each pixel
+// across the images according to the mode. The images must be aligned. They are resized
+// to the size of the first image if their sizes differ. The images are read row by row,
+// so the memory used besides the result doesn't depend on their height.
+//
+// Example:
+//
+//	trails := imaging.Stack(frames, imaging.StackMax)
+//
+func Stack(images []image.Image, mode StackMode) *image.NRGBA {
+	if len(images) == 0 {
+		return &image.NRGBA{}
+	}
+	w, h := images[0].Bounds().Dx(), images[0].Bounds().Dy()
+	if w <= 0 || h <= 0 {
+		return &image.NRGBA{}
+	}
+	if len(images) == 1 {
+		return Clone(images[0])
+	}
+
+	scanners := make([]*scanner, len(images))
+	for i, img := range images {
+		if img.Bounds().Dx() != w || img.Bounds().Dy() != h {
+			img = Resize(img, w, h, Linear)
+		}
+		scanners[i] = newScanner(img)
+	}
+
+	var toSRGB []uint8
+	if mode == StackMean {
+		toSRGB = linearToSRGBTable()
+	}
+	n := len(images)
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	parallel(0, h, w*n*costPoint, func(ys <-chan int) {
+		scanLines := make([][]uint8, n)
+		for i := range scanLines {
+			scanLines[i] = make([]uint8, w*4)
+		}
+		values := make([]uint8, n)
+		for y := range ys {
+			for i, s := range scanners {
+				s.scan(0, y, w, y+1, scanLines[i])
+			}
+			d := dst.Pix[y*dst.Stride : y*dst.Stride+w*4]
+			for j := range d {
+				for i, line := range scanLines {
+					values[i] = line[j]
+				}
+				switch mode {
+				case StackMean:
+					sum := 0
+					if j%4 == 3 {
+						for _, v := range values {
+							sum += int(v)
+						}
+						d[j] = uint8((sum + n/2) / n)
+					} else {
+						for _, v := range values {
+							sum += int(srgbToLinearLUT[v])
+						}
+						d[j] = toSRGB[(sum+n/2)/n]
+					}
+				case StackMax:
+					d[j] = slices.Max(values)
+				case StackMin:
+					d[j] = slices.Min(values)
+				default:
+					slices.Sort(values)
+					if n%2 == 1 {
+						d[j] = values[n/2]
+					} else {
+						d[j] = uint8((int(values[n/2-1]) + int(values[n/2]) + 1) / 2)
+					}
+				}
+			}
+		}
+	})
+	return dst
+}
+
+// MedianStack merges a burst of shots of the same scene by taking the median of each
+// channel of each pixel across the images. It reduces the noise and removes the moving
+// objects, such as people passing through a scene, that are present in fewer than half
+// of the images. It is the same as Stack with StackMedian.
+//
+// Example:
+//
+//	clean := imaging.MedianStack([]image.Image{shot1, shot2, shot3, shot4, shot5})
+//
+func MedianStack(images []image.Image) *image.NRGBA {
+	return Stack(images, StackMedian)
+}
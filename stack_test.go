@@ -0,0 +1,96 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+)
+
+func TestMedianStack(t *testing.T) {
+	src := Clone(testdataFlowersSmallPNG)
+	rnd := rand.New(rand.NewSource(1))
+
+	// Each shot has noise and an object at a different position.
+	var shots []image.Image
+	for i := 0; i < 5; i++ {
+		shot := Clone(src)
+		for j := range shot.Pix {
+			if j%4 != 3 {
+				shot.Pix[j] = clamp(float64(shot.Pix[j]) + rnd.NormFloat64()*8)
+			}
+		}
+		object := image.Rect(i*40, 60, i*40+30, 90)
+		shot = Paste(shot, New(30, 30, color.NRGBA{255, 0, 255, 255}), object.Min)
+		shots = append(shots, shot)
+	}
+
+	got := MedianStack(shots)
+	if got.Rect != src.Rect {
+		t.Fatalf("got bounds %v want %v", got.Rect, src.Rect)
+	}
+	if d, ds := meanAbsDiff(got, src), meanAbsDiff(shots[0], src); d*2 > ds {
+		t.Fatalf("got mean difference %.2f, %.2f for a single shot", d, ds)
+	}
+	for i := 0; i < 5; i++ {
+		object := image.Rect(i*40, 60, i*40+30, 90)
+		if d := meanAbsDiff(Crop(got, object), Crop(src, object)); d > 10 {
+			t.Fatalf("got mean difference %.2f at the object %d", d, i)
+		}
+	}
+}
+
+func TestMedianStackEven(t *testing.T) {
+	a := New(2, 1, color.NRGBA{10, 20, 30, 255})
+	b := New(4, 2, color.NRGBA{20, 20, 35, 255})
+	got := MedianStack([]image.Image{a, b})
+	want := New(2, 1, color.NRGBA{15, 20, 33, 255})
+	if !compareNRGBA(got, want, 0) {
+		t.Fatalf("got result %v want %v", got.Pix, want.Pix)
+	}
+	if got := MedianStack(nil); !got.Rect.Empty() {
+		t.Fatalf("got bounds %v", got.Rect)
+	}
+}
+
+func TestStack(t *testing.T) {
+	a := New(2, 1, color.NRGBA{0, 200, 30, 255})
+	b := New(2, 1, color.NRGBA{255, 100, 30, 255})
+	c := New(2, 1, color.NRGBA{10, 50, 30, 255})
+	images := []image.Image{a, b, c}
+
+	testCases := []struct {
+		mode StackMode
+		want color.NRGBA
+	}{
+		{StackMedian, color.NRGBA{10, 100, 30, 255}},
+		{StackMax, color.NRGBA{255, 200, 30, 255}},
+		{StackMin, color.NRGBA{0, 50, 30, 255}},
+		// The mean is taken in linear light.
+		{StackMean, color.NRGBA{156, 136, 30, 255}},
+	}
+	for _, tc := range testCases {
+		got := Stack(images, tc.mode)
+		if !compareNRGBA(got, New(2, 1, tc.want), 1) {
+			t.Fatalf("got result %v want %v for mode %d", got.Pix[:4], tc.want, tc.mode)
+		}
+	}
+}
+
+func TestStackMeanNoise(t *testing.T) {
+	src := Clone(testdataBranchesPNG)
+	rnd := rand.New(rand.NewSource(2))
+	var shots []image.Image
+	for i := 0; i < 8; i++ {
+		shot := Clone(src)
+		for j := range shot.Pix {
+			if j%4 != 3 {
+				shot.Pix[j] = clamp(float64(shot.Pix[j]) + rnd.NormFloat64()*8)
+			}
+		}
+		shots = append(shots, shot)
+	}
+	if d, ds := meanAbsDiff(Stack(shots, StackMean), src), meanAbsDiff(shots[0], src); d*2 > ds {
+		t.Fatalf("got mean difference %.2f, %.2f for a single shot", d, ds)
+	}
+}
@@ -0,0 +1,101 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"io"
+	"math"
+)
+
+// ErrTargetSizeTooSmall means the image can't be encoded within the given number of bytes.
+var ErrTargetSizeTooSmall = errors.New("imaging: target size is too small")
+
+// minDownscaleQuality is the lowest JPEG quality tried before the image is downscaled.
+const minDownscaleQuality = 50
+
+// EncodeTargetSize writes the image img to w in the specified format so that the output
+// is not larger than maxBytes. For JPEG it finds the highest quality (up to the one set by
+// the JPEGQuality option, 95 by default) that fits the budget using a binary search.
+// If the AllowDownscale option is enabled, the image is also downscaled when the quality
+// would fall below 50, or when the size of another format exceeds the budget. It returns
+// ErrTargetSizeTooSmall if the image doesn't fit, nothing is written in this case.
+//
+// Example:
+//
+//	// Avatar under 200 KB.
+//	err := imaging.EncodeTargetSize(w, avatar, imaging.JPEG, 200<<10, imaging.AllowDownscale(true))
+//
+func EncodeTargetSize(w io.Writer, img image.Image, format Format, maxBytes int, opts ...EncodeOption) error {
+	cfg := newEncodeConfig(format, opts)
+	if maxBytes <= 0 {
+		return ErrTargetSizeTooSmall
+	}
+	maxQuality := cfg.jpegQuality
+	if maxQuality < 1 {
+		maxQuality = 1
+	}
+	if maxQuality > 100 {
+		maxQuality = 100
+	}
+	minQuality := 1
+	if cfg.allowDownscale {
+		minQuality = minDownscaleQuality
+	}
+	if minQuality > maxQuality {
+		minQuality = maxQuality
+	}
+
+	var buf bytes.Buffer
+	encode := func(img image.Image, quality int) ([]byte, error) {
+		buf.Reset()
+		o := opts[:len(opts):len(opts)]
+		if format == JPEG {
+			o = append(o, JPEGQuality(quality))
+		}
+		if err := Encode(&buf, img, format, o...); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	src := img
+	for {
+		data, err := encode(img, minQuality)
+		if err != nil {
+			return err
+		}
+		if len(data) <= maxBytes {
+			best := append([]byte(nil), data...)
+			if format == JPEG {
+				// The highest quality that fits in (minQuality, maxQuality].
+				lo, hi := minQuality+1, maxQuality
+				for lo <= hi {
+					q := (lo + hi) / 2
+					data, err := encode(img, q)
+					if err != nil {
+						return err
+					}
+					if len(data) <= maxBytes {
+						best = append(best[:0], data...)
+						lo = q + 1
+					} else {
+						hi = q - 1
+					}
+				}
+			}
+			_, err := w.Write(best)
+			return err
+		}
+
+		b := img.Bounds()
+		if !cfg.allowDownscale || (b.Dx() <= 1 && b.Dy() <= 1) {
+			return ErrTargetSizeTooSmall
+		}
+		// The encoded size is roughly proportional to the number of pixels.
+		scale := math.Min(math.Sqrt(float64(maxBytes)/float64(len(data)))*0.95, 0.9)
+		width := int(math.Max(1, float64(b.Dx())*scale))
+		height := int(math.Max(1, float64(b.Dy())*scale))
+		img = Resize(src, width, height, Lanczos)
+	}
+}
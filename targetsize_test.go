@@ -0,0 +1,76 @@
+package imaging
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncodeTargetSizeJPEG(t *testing.T) {
+	img := testdataFlowersSmallPNG
+	for _, maxBytes := range []int{4000, 8000, 1 << 20} {
+		var buf bytes.Buffer
+		if err := EncodeTargetSize(&buf, img, JPEG, maxBytes); err != nil {
+			t.Fatalf("EncodeTargetSize(%d): %v", maxBytes, err)
+		}
+		if buf.Len() > maxBytes {
+			t.Fatalf("EncodeTargetSize(%d): got %d bytes", maxBytes, buf.Len())
+		}
+
+		// The result is the highest quality that fits.
+		want := -1
+		for q := 1; q <= 95; q++ {
+			data, err := EncodeBytes(img, JPEG, JPEGQuality(q))
+			if err != nil {
+				t.Fatalf("EncodeBytes: %v", err)
+			}
+			if len(data) <= maxBytes && bytes.Equal(data, buf.Bytes()) {
+				want = q
+			}
+			if want >= 0 && len(data) > maxBytes {
+				break
+			}
+		}
+		if want < 0 {
+			t.Fatalf("EncodeTargetSize(%d): the result is not a JPEG of any quality", maxBytes)
+		}
+		if want < 95 {
+			data, _ := EncodeBytes(img, JPEG, JPEGQuality(want+1))
+			if len(data) <= maxBytes {
+				t.Fatalf("EncodeTargetSize(%d): quality %d fits too", maxBytes, want+1)
+			}
+		}
+	}
+}
+
+func TestEncodeTargetSizeDownscale(t *testing.T) {
+	img := testdataFlowersSmallPNG
+	for _, format := range []Format{JPEG, PNG} {
+		var buf bytes.Buffer
+		err := EncodeTargetSize(&buf, img, format, 800)
+		if err != ErrTargetSizeTooSmall {
+			t.Fatalf("%v without downscaling: got error %v want ErrTargetSizeTooSmall", format, err)
+		}
+		if buf.Len() != 0 {
+			t.Fatalf("%v without downscaling: got %d bytes written", format, buf.Len())
+		}
+
+		if err := EncodeTargetSize(&buf, img, format, 800, AllowDownscale(true)); err != nil {
+			t.Fatalf("%v: EncodeTargetSize: %v", format, err)
+		}
+		if buf.Len() > 800 {
+			t.Fatalf("%v: got %d bytes", format, buf.Len())
+		}
+		got, err := Decode(&buf)
+		if err != nil {
+			t.Fatalf("%v: Decode: %v", format, err)
+		}
+		w, h := got.Bounds().Dx(), got.Bounds().Dy()
+		if w >= 240 || h >= 160 || absint(w*2-h*3) > 3 {
+			t.Fatalf("%v: got size %dx%d want a smaller 3:2 image", format, w, h)
+		}
+	}
+
+	if err := EncodeTargetSize(&bytes.Buffer{}, img, PNG, 0, AllowDownscale(true)); err != ErrTargetSizeTooSmall {
+		t.Fatalf("got error %v want ErrTargetSizeTooSmall", err)
+	}
+}
@@ -0,0 +1,93 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// DrawText draws the text on the dst image using the given font face and color.
+// The point pt is the left end of the baseline of the text. The text is drawn
+// as a single line, newline characters are not interpreted.
+//
+// Example:
+//
+//	imaging.DrawText(img, "Hello", image.Pt(10, 20), basicfont.Face7x13, color.White)
+//
+func DrawText(dst *image.NRGBA, text string, pt image.Point, face font.Face, c color.Color) {
+	d := &font.Drawer{
+		Dst:  dst,
+		Src:  image.NewUniform(c),
+		Face: face,
+		Dot:  fixed.P(pt.X, pt.Y),
+	}
+	d.DrawString(text)
+}
+
+// DrawTextBox draws the text on the dst image inside the rectangle r. The text is wrapped
+// at spaces to fit the width of the rectangle, newline characters start new lines. Each line
+// and the whole block of lines are aligned within the rectangle according to the anchor.
+// Words wider than the rectangle and the lines that don't fit its height are not cut off.
+//
+// Example:
+//
+//	// Draw a caption at the bottom of the image.
+//	r := image.Rect(10, 10, img.Bounds().Dx()-10, img.Bounds().Dy()-10)
+//	imaging.DrawTextBox(img, caption, r, face, color.White, imaging.Bottom)
+//
+func DrawTextBox(dst *image.NRGBA, text string, r image.Rectangle, face font.Face, c color.Color, anchor Anchor) {
+	lines := wrapText(text, face, r.Dx())
+	metrics := face.Metrics()
+	lineHeight := metrics.Height.Ceil()
+	ascent := metrics.Ascent.Ceil()
+
+	top := anchorPt(r, 0, len(lines)*lineHeight, anchor).Y
+	for i, line := range lines {
+		width := font.MeasureString(face, line).Ceil()
+		x := anchorPt(r, width, 0, anchor).X
+		DrawText(dst, line, image.Pt(x, top+i*lineHeight+ascent), face, c)
+	}
+}
+
+// Label draws the text over the image inside the margin from the image edges and returns
+// the result. It's a convenience wrapper of DrawTextBox for simple labels and watermarks.
+//
+// Example:
+//
+//	// Semi-transparent watermark in the bottom right corner.
+//	dstImage := imaging.Label(srcImage, "© Example", face, color.NRGBA{255, 255, 255, 128}, imaging.BottomRight, 8)
+//
+func Label(img image.Image, text string, face font.Face, c color.Color, anchor Anchor, margin int) *image.NRGBA {
+	dst := Clone(img)
+	r := dst.Bounds().Inset(margin)
+	DrawTextBox(dst, text, r, face, c, anchor)
+	return dst
+}
+
+// wrapText splits the text into lines not wider than maxWidth pixels when possible.
+// If maxWidth <= 0, the text is only split at newline characters.
+func wrapText(text string, face font.Face, maxWidth int) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		words := strings.Fields(paragraph)
+		if len(words) == 0 {
+			lines = append(lines, "")
+			continue
+		}
+		line := words[0]
+		for _, word := range words[1:] {
+			candidate := line + " " + word
+			if maxWidth > 0 && font.MeasureString(face, candidate).Ceil() > maxWidth {
+				lines = append(lines, line)
+				line = word
+				continue
+			}
+			line = candidate
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,100 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+
+	"golang.org/x/image/font/basicfont"
+)
+
+// opaqueBounds returns the bounds of the non-transparent pixels of the image.
+func opaqueBounds(img *image.NRGBA) image.Rectangle {
+	var r image.Rectangle
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+			if img.NRGBAAt(x, y).A != 0 {
+				r = r.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return r
+}
+
+func TestWrapText(t *testing.T) {
+	face := basicfont.Face7x13
+	testCases := []struct {
+		text     string
+		maxWidth int
+		want     []string
+	}{
+		{"aaa bbb ccc", 50, []string{"aaa bbb", "ccc"}},
+		{"aaa bbb ccc", 49, []string{"aaa bbb", "ccc"}},
+		{"aaa bbb ccc", 48, []string{"aaa", "bbb", "ccc"}},
+		{"aaa  bbb\n\nccc", 0, []string{"aaa bbb", "", "ccc"}},
+		{"aaaaaaaaaa b", 20, []string{"aaaaaaaaaa", "b"}},
+		{"", 20, []string{""}},
+	}
+	for _, tc := range testCases {
+		got := wrapText(tc.text, face, tc.maxWidth)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("wrapText(%q, %d): got %q want %q", tc.text, tc.maxWidth, got, tc.want)
+		}
+	}
+}
+
+func TestDrawText(t *testing.T) {
+	dst := New(40, 20, color.Transparent)
+	DrawText(dst, "AB", image.Pt(5, 15), basicfont.Face7x13, color.NRGBA{0xff, 0, 0, 0xff})
+	b := opaqueBounds(dst)
+	if b.Empty() || !b.In(image.Rect(5, 4, 19, 17)) {
+		t.Fatalf("got text bounds %v want inside %v", b, image.Rect(5, 4, 19, 17))
+	}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if c := dst.NRGBAAt(x, y); c.A != 0 && c != (color.NRGBA{0xff, 0, 0, 0xff}) {
+				t.Fatalf("pixel (%d, %d): got color %v want red", x, y, c)
+			}
+		}
+	}
+}
+
+func TestDrawTextBox(t *testing.T) {
+	testCases := []struct {
+		anchor Anchor
+		want   image.Rectangle
+	}{
+		{TopLeft, image.Rect(10, 10, 24, 36)},
+		{BottomRight, image.Rect(26, 54, 40, 80)},
+		{Center, image.Rect(18, 32, 32, 58)},
+	}
+	for _, tc := range testCases {
+		dst := New(100, 90, color.Transparent)
+		// "ab cd" doesn't fit the width of 30px, so it's wrapped into two lines.
+		DrawTextBox(dst, "ab cd", image.Rect(10, 10, 40, 80), basicfont.Face7x13, color.Black, tc.anchor)
+		b := opaqueBounds(dst)
+		if b.Empty() || !b.In(tc.want) {
+			t.Errorf("anchor %d: got text bounds %v want inside %v", tc.anchor, b, tc.want)
+		}
+	}
+}
+
+func TestLabel(t *testing.T) {
+	src := New(60, 30, color.White)
+	got := Label(src, "Hi", basicfont.Face7x13, color.Black, BottomRight, 5)
+	if !compareNRGBA(src, New(60, 30, color.White), 0) {
+		t.Fatalf("source image is modified")
+	}
+	var dark image.Rectangle
+	for y := 0; y < 30; y++ {
+		for x := 0; x < 60; x++ {
+			if got.NRGBAAt(x, y).R == 0 {
+				dark = dark.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	if dark.Empty() || !dark.In(image.Rect(41, 12, 55, 25)) {
+		t.Fatalf("got text bounds %v want inside %v", dark, image.Rect(41, 12, 55, 25))
+	}
+}
@@ -0,0 +1,121 @@
+package imaging
+
+import (
+	"image"
+)
+
+// Threshold converts the image to black and white: the pixels with the luminance of at least
+// the level become white, the darker ones black. The transparent pixels are treated as white.
+// The result contains only opaque black and white pixels.
+//
+// Example:
+//
+//	dstImage := imaging.Threshold(srcImage, 128)
+//
+func Threshold(img image.Image, level uint8) *image.NRGBA {
+	lum, w, h := luminancePlane(img)
+	if w <= 0 || h <= 0 {
+		return &image.NRGBA{}
+	}
+	return grayPlaneToNRGBA(thresholdPlane(lum, level), w, h)
+}
+
+// OtsuThreshold converts the image to black and white like Threshold, with the level
+// chosen by OtsuLevel. It suits the images with evenly lit dark and light areas,
+// such as the clean scans. See AdaptiveThreshold for the uneven lighting.
+//
+// Example:
+//
+//	dstImage := imaging.OtsuThreshold(srcImage)
+//
+func OtsuThreshold(img image.Image) *image.NRGBA {
+	lum, w, h := luminancePlane(img)
+	if w <= 0 || h <= 0 {
+		return &image.NRGBA{}
+	}
+	return grayPlaneToNRGBA(thresholdPlane(lum, otsuLevel(lum)), w, h)
+}
+
+// OtsuLevel returns the level of Threshold that best separates the dark and the light pixels
+// of the image by the Otsu's method: the level that minimizes the variance of the luminance
+// within the dark and the light pixels. It returns 128 for the empty and uniform images.
+//
+// Example:
+//
+//	level := imaging.OtsuLevel(srcImage)
+//	dstImage := imaging.Threshold(srcImage, level+10) // A bit darker than the automatic level.
+//
+func OtsuLevel(img image.Image) uint8 {
+	lum, _, _ := luminancePlane(img)
+	return otsuLevel(lum)
+}
+
+// AdaptiveThreshold converts the image to black and white comparing each pixel to the mean
+// luminance of the blockSize x blockSize neighborhood around it: the pixels darker than the mean
+// by at least the offset become black, the others white. Unlike Threshold, it copes with the
+// uneven lighting and the shadows of the photographed documents. The block should be larger
+// than the strokes of the text, e.g. 15-50 pixels. The offset should be positive, such as 10,
+// to keep the flat areas white despite their noise. The transparent pixels are treated
+// as white, and the result contains only opaque black and white pixels.
+//
+// Example:
+//
+//	dstImage := imaging.AdaptiveThreshold(srcImage, 25, 10)
+//
+func AdaptiveThreshold(img image.Image, blockSize int, offset float64) *image.NRGBA {
+	lum, w, h := luminancePlane(img)
+	if w <= 0 || h <= 0 {
+		return &image.NRGBA{}
+	}
+	return grayPlaneToNRGBA(binarize(lum, w, h, max(blockSize, 1), 0, offset), w, h)
+}
+
+// thresholdPlane returns the plane with the luminance values of at least the level set to white
+// and the others to black.
+func thresholdPlane(lum []uint8, level uint8) []uint8 {
+	bin := make([]uint8, len(lum))
+	for i, v := range lum {
+		if v >= level {
+			bin[i] = 255
+		}
+	}
+	return bin
+}
+
+// otsuLevel returns the Otsu threshold level of the luminance values. If several levels
+// separate the values equally well, the one in the middle of them is returned.
+func otsuLevel(lum []uint8) uint8 {
+	var hist [256]float64
+	for _, v := range lum {
+		hist[v]++
+	}
+	var total, sum float64
+	for v, n := range hist {
+		total += n
+		sum += float64(v) * n
+	}
+
+	// The level t splits the values into the dark ones (< t) and the light ones (>= t).
+	var darkCount, darkSum, best float64
+	first, last := -1, -1
+	for t := 1; t < 256; t++ {
+		darkCount += hist[t-1]
+		darkSum += float64(t-1) * hist[t-1]
+		lightCount := total - darkCount
+		if darkCount == 0 || lightCount == 0 {
+			continue
+		}
+		d := darkSum/darkCount - (sum-darkSum)/lightCount
+		between := darkCount * lightCount * d * d
+		switch {
+		case between > best*(1+1e-12):
+			best, first, last = between, t, t
+		case between >= best*(1-1e-12):
+			last = t
+		}
+	}
+	if first < 0 {
+		return 128
+	}
+	return uint8((first + last) / 2)
+}
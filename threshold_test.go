@@ -0,0 +1,106 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestThreshold(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(5, 5, 9, 6))
+	copy(src.Pix, []uint8{
+		0x7f, 0x7f, 0x7f, 0xff,
+		0x80, 0x80, 0x80, 0xff,
+		0xff, 0x00, 0x00, 0xff, // Luminance 76.
+		0x00, 0x00, 0x00, 0x00, // Transparent is white.
+	})
+	got := Threshold(src, 128)
+	want := []uint8{0, 255, 0, 255}
+	if got.Rect != image.Rect(0, 0, 4, 1) {
+		t.Fatalf("got bounds %v", got.Rect)
+	}
+	for i, v := range want {
+		if p := got.Pix[i*4 : i*4+4]; p[0] != v || p[1] != v || p[2] != v || p[3] != 255 {
+			t.Fatalf("got pixel %d %v, want %d", i, p, v)
+		}
+	}
+	if got := Threshold(src, 0); got.Pix[0] != 255 || got.Pix[8] != 255 {
+		t.Fatalf("level 0: got pixels %v", got.Pix)
+	}
+	if got := Threshold(&image.NRGBA{}, 128); !got.Rect.Empty() {
+		t.Fatalf("got bounds %v", got.Rect)
+	}
+}
+
+func TestOtsuThreshold(t *testing.T) {
+	// Noisy dark text on a light background.
+	src := New(40, 40, color.Gray{200})
+	for y := 0; y < 40; y++ {
+		for x := 0; x < 40; x++ {
+			v := 200 + (x*7+y*13)%21 - 10
+			if x >= 10 && x < 20 {
+				v = 60 + (x*11+y*5)%21 - 10
+			}
+			src.SetNRGBA(x, y, color.NRGBA{uint8(v), uint8(v), uint8(v), 255})
+		}
+	}
+	level := OtsuLevel(src)
+	if level <= 70 || level > 190 {
+		t.Fatalf("got level %d", level)
+	}
+	got := OtsuThreshold(src)
+	for y := 0; y < 40; y++ {
+		for x := 0; x < 40; x++ {
+			want := uint8(255)
+			if x >= 10 && x < 20 {
+				want = 0
+			}
+			if got.Pix[y*got.Stride+x*4] != want {
+				t.Fatalf("got %d at (%d, %d), want %d", got.Pix[y*got.Stride+x*4], x, y, want)
+			}
+		}
+	}
+
+	// The level in the middle between two values.
+	two := New(4, 1, color.Gray{50})
+	two.SetNRGBA(0, 0, color.NRGBA{200, 200, 200, 255})
+	if level := OtsuLevel(two); level != 125 {
+		t.Fatalf("got level %d, want 125", level)
+	}
+	if level := OtsuLevel(New(4, 4, color.Gray{30})); level != 128 {
+		t.Fatalf("uniform image: got level %d, want 128", level)
+	}
+	if level := OtsuLevel(&image.NRGBA{}); level != 128 {
+		t.Fatalf("empty image: got level %d, want 128", level)
+	}
+}
+
+func TestAdaptiveThreshold(t *testing.T) {
+	// A dark line on the background with a strong horizontal gradient,
+	// which no single level separates.
+	src := image.NewNRGBA(image.Rect(0, 0, 100, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 100; x++ {
+			v := 40 + x*2
+			if y == 10 {
+				v -= 35
+			}
+			src.SetNRGBA(x, y, color.NRGBA{uint8(v), uint8(v), uint8(v), 255})
+		}
+	}
+	got := AdaptiveThreshold(src, 15, 10)
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 100; x++ {
+			want := uint8(255)
+			if y == 10 {
+				want = 0
+			}
+			if got.Pix[y*got.Stride+x*4] != want {
+				t.Fatalf("got %d at (%d, %d), want %d", got.Pix[y*got.Stride+x*4], x, y, want)
+			}
+		}
+	}
+	if got := AdaptiveThreshold(&image.NRGBA{}, 15, 10); !got.Rect.Empty() {
+		t.Fatalf("got bounds %v", got.Rect)
+	}
+}
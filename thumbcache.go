@@ -0,0 +1,133 @@
+package imaging
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrCustomFilter means that the thumbnail uses a custom resampling filter, which
+// can't be a part of the cache key. Only the predefined filters can be cached.
+var ErrCustomFilter = errors.New("imaging: custom resampling filter can't be cached")
+
+// thumbnailCall is a thumbnail being generated, waited for by the concurrent callers.
+type thumbnailCall struct {
+	done chan struct{}
+	img  image.Image
+	err  error
+}
+
+var (
+	thumbnailMu    sync.Mutex
+	thumbnailCalls = make(map[string]*thumbnailCall) // By the cache path.
+)
+
+// CachedThumbnail returns the thumbnail of the image file made by Thumbnail, keeping it
+// in the cacheDir directory, so that it's generated again only when the source file changes.
+// The cached thumbnail is valid while its modification time equals the modification time
+// of the source file, and it's replaced when the source is modified. The concurrent calls
+// for the same thumbnail wait for a single generation and get the same image, which must
+// not be modified. The cache files are written atomically, so the cache directory can
+// be shared by several processes.
+//
+// The thumbnails of the JPEG files are cached as JPEG, the others as PNG. The returned
+// thumbnail is always decoded from the cached data, so it's the same whether it's just
+// generated or read from the cache. The source is decoded with AutoOrientation. Only
+// the predefined resampling filters are supported, ErrCustomFilter is returned for
+// the others. The stale thumbnails of the deleted sources are not removed.
+//
+// Example:
+//
+//	thumb, err := imaging.CachedThumbnail("photos/photo.jpg", 200, 200, imaging.Lanczos, "cache/thumbs")
+//
+func CachedThumbnail(srcPath string, width, height int, filter ResampleFilter, cacheDir string) (image.Image, error) {
+	if width <= 0 || height <= 0 {
+		return &image.NRGBA{}, nil
+	}
+	name := filterName(filter)
+	if name == "" {
+		return nil, ErrCustomFilter
+	}
+	info, err := fs.Stat(srcPath)
+	if err != nil {
+		return nil, err
+	}
+	absPath, err := filepath.Abs(srcPath)
+	if err != nil {
+		return nil, err
+	}
+
+	format := PNG
+	if f, err := FormatFromFilename(srcPath); err == nil && f == JPEG {
+		format = JPEG
+	}
+	key := fmt.Sprintf("%s\n%s\n%dx%d\n%s\n%s", OpVersion, absPath, width, height, name, format)
+	sum := sha256.Sum256([]byte(key))
+	cachePath := filepath.Join(cacheDir, hex.EncodeToString(sum[:16])+"."+formatExtension(format))
+
+	if img, ok := openCachedThumbnail(cachePath, info.ModTime()); ok {
+		return img, nil
+	}
+
+	thumbnailMu.Lock()
+	if call, ok := thumbnailCalls[cachePath]; ok {
+		thumbnailMu.Unlock()
+		<-call.done
+		return call.img, call.err
+	}
+	call := &thumbnailCall{done: make(chan struct{})}
+	thumbnailCalls[cachePath] = call
+	thumbnailMu.Unlock()
+
+	call.img, call.err = generateThumbnail(srcPath, cachePath, format, width, height, filter, info.ModTime())
+	thumbnailMu.Lock()
+	delete(thumbnailCalls, cachePath)
+	thumbnailMu.Unlock()
+	close(call.done)
+	return call.img, call.err
+}
+
+// formatExtension returns the canonical file name extension of the format.
+func formatExtension(f Format) string {
+	if f == JPEG {
+		return "jpg"
+	}
+	return "png"
+}
+
+// openCachedThumbnail returns the cached thumbnail if it's up to date with the source
+// modified at modTime.
+func openCachedThumbnail(cachePath string, modTime time.Time) (image.Image, bool) {
+	info, err := fs.Stat(cachePath)
+	if err != nil || !info.ModTime().Equal(modTime) {
+		return nil, false
+	}
+	img, err := Open(cachePath)
+	if err != nil {
+		return nil, false
+	}
+	return img, true
+}
+
+// generateThumbnail makes the thumbnail of the source, writes it to the cache
+// with the modification time of the source and returns the cached image.
+func generateThumbnail(srcPath, cachePath string, format Format, width, height int, filter ResampleFilter, modTime time.Time) (image.Image, error) {
+	src, err := Open(srcPath, AutoOrientation(true))
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	if err := Encode(buf, Thumbnail(src, width, height, filter), format); err != nil {
+		return nil, err
+	}
+	if err := fs.WriteAtomic(cachePath, buf.Bytes(), modTime); err != nil {
+		return nil, err
+	}
+	return Decode(bytes.NewReader(buf.Bytes()))
+}
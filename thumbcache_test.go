@@ -0,0 +1,136 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCachedThumbnail(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imaging")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	srcPath := filepath.Join(dir, "flowers.png")
+	cacheDir := filepath.Join(dir, "cache")
+	if err := Save(testdataFlowersSmallPNG, srcPath); err != nil {
+		t.Fatalf("failed to save image: %v", err)
+	}
+	want := Thumbnail(testdataFlowersSmallPNG, 50, 40, Lanczos)
+
+	got, err := CachedThumbnail(srcPath, 50, 40, Lanczos, cacheDir)
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	if !Equal(got, want) {
+		t.Fatalf("got a different thumbnail")
+	}
+	files, err := ioutil.ReadDir(cacheDir)
+	if err != nil || len(files) != 1 || filepath.Ext(files[0].Name()) != ".png" {
+		t.Fatalf("got cache files %v, error %v", files, err)
+	}
+	cachePath := filepath.Join(cacheDir, files[0].Name())
+
+	// The cached thumbnail is used while the source isn't modified.
+	marker := New(50, 40, color.NRGBA{255, 0, 0, 255})
+	if err := Save(marker, cachePath); err != nil {
+		t.Fatalf("failed to save image: %v", err)
+	}
+	info, _ := os.Stat(srcPath)
+	os.Chtimes(cachePath, info.ModTime(), info.ModTime())
+	if got, err = CachedThumbnail(srcPath, 50, 40, Lanczos, cacheDir); err != nil || !Equal(got, marker) {
+		t.Fatalf("the cached thumbnail isn't used, error %v", err)
+	}
+
+	// The other sizes and filters are cached separately.
+	if got, err = CachedThumbnail(srcPath, 40, 50, Box, cacheDir); err != nil || !Equal(got, Thumbnail(testdataFlowersSmallPNG, 40, 50, Box)) {
+		t.Fatalf("got a different thumbnail, error %v", err)
+	}
+
+	// The thumbnail is generated again when the source is modified.
+	modTime := info.ModTime().Add(time.Hour)
+	os.Chtimes(srcPath, modTime, modTime)
+	if got, err = CachedThumbnail(srcPath, 50, 40, Lanczos, cacheDir); err != nil || !Equal(got, want) {
+		t.Fatalf("the stale thumbnail is used, error %v", err)
+	}
+	if info, err := os.Stat(cachePath); err != nil || !info.ModTime().Equal(modTime) {
+		t.Fatalf("the cached thumbnail isn't replaced, error %v", err)
+	}
+}
+
+func TestCachedThumbnailConcurrent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imaging")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	srcPath := filepath.Join(dir, "flowers.jpg")
+	if err := Save(testdataFlowersSmallPNG, srcPath); err != nil {
+		t.Fatalf("failed to save image: %v", err)
+	}
+	var wg sync.WaitGroup
+	results := make([]image.Image, 8)
+	errs := make([]error, len(results))
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = CachedThumbnail(srcPath, 30, 30, Lanczos, dir)
+		}(i)
+	}
+	wg.Wait()
+	for i, img := range results {
+		if errs[i] != nil || img.Bounds() != image.Rect(0, 0, 30, 30) {
+			t.Fatalf("got bounds %v, error %v", img.Bounds(), errs[i])
+		}
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	if len(files) != 2 {
+		t.Fatalf("got files %v", files)
+	}
+
+	// The cache hit returns the same lossy JPEG thumbnail as the generation.
+	got, err := CachedThumbnail(srcPath, 30, 30, Lanczos, dir)
+	if err != nil || !Equal(got, results[0]) {
+		t.Fatalf("got a different cached thumbnail, error %v", err)
+	}
+}
+
+func TestCachedThumbnailErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imaging")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	srcPath := filepath.Join(dir, "flowers.png")
+	if err := Save(testdataFlowersSmallPNG, srcPath); err != nil {
+		t.Fatalf("failed to save image: %v", err)
+	}
+	custom := ResampleFilter{Support: 1, Kernel: func(x float64) float64 { return 1 - x }}
+	if _, err := CachedThumbnail(srcPath, 10, 10, custom, dir); err != ErrCustomFilter {
+		t.Fatalf("got error %v, want ErrCustomFilter", err)
+	}
+	if _, err := CachedThumbnail(filepath.Join(dir, "missing.png"), 10, 10, Lanczos, dir); !os.IsNotExist(err) {
+		t.Fatalf("got error %v", err)
+	}
+	if img, err := CachedThumbnail(srcPath, 0, 10, Lanczos, dir); err != nil || !img.Bounds().Empty() {
+		t.Fatalf("got bounds %v, error %v", img.Bounds(), err)
+	}
+
+	// The file system is accessed through fs.
+	prevFS := fs
+	fs = badFS{}
+	defer func() { fs = prevFS }()
+	if _, err := CachedThumbnail(srcPath, 10, 10, Lanczos, dir); err != errOpen {
+		t.Fatalf("got error %v, want errOpen", err)
+	}
+}
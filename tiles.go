@@ -0,0 +1,269 @@
+package imaging
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"path"
+)
+
+// TileSink receives the tiles produced by GenerateTiles.
+type TileSink interface {
+	// WriteTile is called for each tile of the pyramid. The level, col and row
+	// parameters follow the Deep Zoom (DZI) layout: level 0 is a single pixel
+	// image and the highest level has the size of the source image.
+	WriteTile(level, col, row int, tile *image.NRGBA) error
+}
+
+// TileSinkFunc is an adapter to allow the use of ordinary functions as tile sinks.
+type TileSinkFunc func(level, col, row int, tile *image.NRGBA) error
+
+// WriteTile calls f(level, col, row, tile).
+func (f TileSinkFunc) WriteTile(level, col, row int, tile *image.NRGBA) error {
+	return f(level, col, row, tile)
+}
+
+// TileLevels returns the number of levels of the tile pyramid for an image
+// of the given size, as used by OpenSeadragon and other Deep Zoom viewers.
+func TileLevels(width, height int) int {
+	size := width
+	if height > size {
+		size = height
+	}
+	levels := 1
+	for size > 1 {
+		size = (size + 1) / 2
+		levels++
+	}
+	return levels
+}
+
+// GenerateTiles splits the image into a Deep Zoom tile pyramid and passes the tiles
+// to the sink. Each level is half the size of the next one (rounded up) and is divided
+// into square tiles of the given size, each extended by overlap pixels on the sides
+// shared with the neighboring tiles. Every level is downscaled from the next one by
+// averaging the blocks of 2x2 pixels. The tiles of each level are produced row by row,
+// the rows of the different levels are interleaved.
+//
+// Example:
+//
+//	err := imaging.GenerateTiles(img, 254, 1, imaging.TileSinkFunc(
+//		func(level, col, row int, tile *image.NRGBA) error {
+//			name := fmt.Sprintf("out_files/%d/%d_%d.jpg", level, col, row)
+//			return imaging.Save(tile, name)
+//		},
+//	))
+//
+func GenerateTiles(img image.Image, tileSize, overlap int, sink TileSink) error {
+	if tileSize <= 0 || overlap < 0 {
+		return errors.New("imaging: invalid tile size or overlap")
+	}
+	src := toNRGBA(img)
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	if w <= 0 || h <= 0 {
+		return nil
+	}
+	return newTileLevel(TileLevels(w, h)-1, w, h, tileSize, overlap, sink).add(src)
+}
+
+// GenerateTilesFromReader is like GenerateTiles, but it reads the image from r by the bands
+// of tile rows using DecodeRegion, so that only a few rows of tiles of each level are kept
+// in memory, and the images much larger than the available memory can be tiled. The result
+// is the same as of GenerateTiles. The images that can't be read partially, see DecodeRegion,
+// are decoded entirely. The only option used is DecodeLimits, applied to each band.
+//
+// Example:
+//
+//	f, err := os.Open("scan.tif")
+//	if err != nil {
+//		return err
+//	}
+//	defer f.Close()
+//	err = imaging.GenerateTilesFromReader(f, 254, 1, imaging.DZISink(bucketFS, "scan_files", imaging.JPEG))
+//
+func GenerateTilesFromReader(r io.ReaderAt, tileSize, overlap int, sink TileSink, opts ...DecodeOption) error {
+	if tileSize <= 0 || overlap < 0 {
+		return errors.New("imaging: invalid tile size or overlap")
+	}
+	cfg := defaultDecodeConfig
+	for _, option := range opts {
+		option(&cfg)
+	}
+
+	t, err := readTIFFLayout(r)
+	if err == errTIFFFallback {
+		img, err := DecodeRegion(r, image.Rect(0, 0, math.MaxInt32, math.MaxInt32), opts...)
+		if err != nil {
+			return err
+		}
+		return GenerateTiles(img, tileSize, overlap, sink)
+	}
+	if err != nil {
+		return err
+	}
+	if t.width <= 0 || t.height <= 0 {
+		return nil
+	}
+
+	level := newTileLevel(TileLevels(t.width, t.height)-1, t.width, t.height, tileSize, overlap, sink)
+	// The bands have an even height, so that they are downscaled to the next level separately.
+	band := tileSize + tileSize%2
+	for y := 0; y < t.height; y += band {
+		img, err := t.decodeRegion(r, image.Rect(0, y, t.width, y+band), cfg.limits)
+		if err != nil {
+			return err
+		}
+		if err := level.add(img); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tileLevel is a level of the tile pyramid that receives its rows from the top to the bottom.
+// It writes the tiles as soon as their rows are available and passes the rows, downscaled,
+// to the next smaller level. Only the rows needed for the next row of tiles and the rows
+// not yet downscaled are kept.
+type tileLevel struct {
+	level             int
+	width, height     int
+	tileSize, overlap int
+	sink              TileSink
+	rows              []uint8 // The buffered rows, starting at the row top of the level.
+	top               int
+	row               int // The next row of tiles.
+	passed            int // The number of rows passed to the next level.
+	next              *tileLevel
+}
+
+func newTileLevel(level, width, height, tileSize, overlap int, sink TileSink) *tileLevel {
+	l := &tileLevel{
+		level:    level,
+		width:    width,
+		height:   height,
+		tileSize: tileSize,
+		overlap:  overlap,
+		sink:     sink,
+	}
+	if level > 0 {
+		l.next = newTileLevel(level-1, (width+1)/2, (height+1)/2, tileSize, overlap, sink)
+	}
+	return l
+}
+
+// add appends the next rows of the level, writes the complete tiles
+// and passes the rows to the next level.
+func (l *tileLevel) add(img *image.NRGBA) error {
+	stride := l.width * 4
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		i := img.PixOffset(img.Rect.Min.X, y)
+		l.rows = append(l.rows, img.Pix[i:i+stride]...)
+	}
+	buf := &image.NRGBA{
+		Pix:    l.rows,
+		Stride: stride,
+		Rect:   image.Rect(0, l.top, l.width, l.top+len(l.rows)/stride),
+	}
+	end := buf.Rect.Max.Y
+
+	ts, ov := l.tileSize, l.overlap
+	for l.row*ts < l.height {
+		bottom := (l.row+1)*ts + ov
+		if bottom > l.height {
+			bottom = l.height
+		}
+		if end < bottom {
+			break
+		}
+		for col := 0; col*ts < l.width; col++ {
+			r := image.Rect(col*ts-ov, l.row*ts-ov, (col+1)*ts+ov, (l.row+1)*ts+ov)
+			if err := l.sink.WriteTile(l.level, col, l.row, Crop(buf, r)); err != nil {
+				return err
+			}
+		}
+		l.row++
+	}
+
+	// The rows are downscaled in pairs, except for the last odd row.
+	keep := end
+	if l.next != nil {
+		n := (end - l.passed) &^ 1
+		if end == l.height {
+			n = end - l.passed
+		}
+		if n > 0 {
+			rows := buf.SubImage(image.Rect(0, l.passed, l.width, l.passed+n))
+			if err := l.next.add(shrinkBox(rows, 2, 2)); err != nil {
+				return err
+			}
+			l.passed += n
+		}
+		keep = l.passed
+	}
+	if first := l.row*ts - ov; first < keep {
+		keep = first
+	}
+	if keep > l.top {
+		n := copy(l.rows, l.rows[(keep-l.top)*stride:])
+		l.rows = l.rows[:n]
+		l.top = keep
+	}
+	return nil
+}
+
+// WriteDZI writes the Deep Zoom descriptor (the .dzi file) of the tile pyramid
+// of an image of the given size, generated by GenerateTiles with the given tile size
+// and overlap and saved in the given format (JPEG or PNG).
+//
+// Example:
+//
+//	// The tiles are saved to out_files/, next to out.dzi.
+//	f, err := os.Create("out.dzi")
+//	...
+//	err = imaging.WriteDZI(f, img.Bounds().Dx(), img.Bounds().Dy(), 254, 1, imaging.JPEG)
+//
+func WriteDZI(w io.Writer, width, height, tileSize, overlap int, format Format) error {
+	ext, err := dziExtension(format)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<Image xmlns="http://schemas.microsoft.com/deepzoom/2008" Format="%s" Overlap="%d" TileSize="%d">
+  <Size Width="%d" Height="%d"/>
+</Image>
+`, ext, overlap, tileSize, width, height)
+	return err
+}
+
+// DZISink returns a TileSink that saves the tiles to the file system fsys in the Deep Zoom
+// layout: the tile of the given level, column and row is saved to dir/level/col_row.jpg
+// (or .png). The dir is usually named as the .dzi descriptor with the "_files" suffix.
+// The encoding options are passed to SaveFS.
+//
+// Example:
+//
+//	err := imaging.GenerateTiles(img, 254, 1, imaging.DZISink(bucketFS, "out_files", imaging.JPEG, imaging.JPEGQuality(85)))
+//
+func DZISink(fsys WriteFS, dir string, format Format, opts ...EncodeOption) TileSink {
+	return TileSinkFunc(func(level, col, row int, tile *image.NRGBA) error {
+		ext, err := dziExtension(format)
+		if err != nil {
+			return err
+		}
+		name := path.Join(dir, fmt.Sprint(level), fmt.Sprintf("%d_%d.%s", col, row, ext))
+		return SaveFS(fsys, tile, name, opts...)
+	})
+}
+
+// dziExtension returns the tile file name extension of the format supported by Deep Zoom.
+func dziExtension(format Format) (string, error) {
+	switch format {
+	case JPEG:
+		return "jpg", nil
+	case PNG:
+		return "png", nil
+	}
+	return "", ErrUnsupportedFormat
+}
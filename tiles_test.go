@@ -0,0 +1,207 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"math/rand"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestTileLevels(t *testing.T) {
+	testCases := []struct {
+		w, h int
+		want int
+	}{
+		{1, 1, 1},
+		{2, 1, 2},
+		{3, 3, 3},
+		{600, 400, 11},
+		{1024, 1024, 11},
+		{1025, 10, 12},
+	}
+	for _, tc := range testCases {
+		got := TileLevels(tc.w, tc.h)
+		if got != tc.want {
+			t.Fatalf("TileLevels(%d, %d): got %d want %d", tc.w, tc.h, got, tc.want)
+		}
+	}
+}
+
+func TestGenerateTiles(t *testing.T) {
+	src := New(10, 5, image.White.C)
+	tiles := make(map[string]image.Rectangle)
+	err := GenerateTiles(src, 4, 1, TileSinkFunc(func(level, col, row int, tile *image.NRGBA) error {
+		tiles[fmt.Sprintf("%d/%d_%d", level, col, row)] = tile.Rect
+		return nil
+	}))
+	if err != nil {
+		t.Fatalf("GenerateTiles: %v", err)
+	}
+
+	want := map[string]image.Rectangle{
+		"4/0_0": image.Rect(0, 0, 5, 5),
+		"4/1_0": image.Rect(0, 0, 6, 5),
+		"4/2_0": image.Rect(0, 0, 3, 5),
+		"4/0_1": image.Rect(0, 0, 5, 2),
+		"4/1_1": image.Rect(0, 0, 6, 2),
+		"4/2_1": image.Rect(0, 0, 3, 2),
+		"3/0_0": image.Rect(0, 0, 5, 3),
+		"3/1_0": image.Rect(0, 0, 2, 3),
+		"2/0_0": image.Rect(0, 0, 3, 2),
+		"1/0_0": image.Rect(0, 0, 2, 1),
+		"0/0_0": image.Rect(0, 0, 1, 1),
+	}
+	if len(tiles) != len(want) {
+		t.Fatalf("got %d tiles want %d: %v", len(tiles), len(want), tiles)
+	}
+	for name, r := range want {
+		if !tiles[name].Eq(r) {
+			t.Fatalf("tile %s: got bounds %v want %v", name, tiles[name], r)
+		}
+	}
+
+	errSink := errors.New("sink error")
+	err = GenerateTiles(src, 4, 1, TileSinkFunc(func(level, col, row int, tile *image.NRGBA) error {
+		return errSink
+	}))
+	if err != errSink {
+		t.Fatalf("got error %v want errSink", err)
+	}
+
+	if err := GenerateTiles(src, 0, 1, nil); err == nil {
+		t.Fatalf("expected error got nil")
+	}
+	if err := GenerateTiles(&image.NRGBA{}, 4, 0, nil); err != nil {
+		t.Fatalf("got error %v want nil", err)
+	}
+}
+
+// collectTiles returns a sink storing the tiles by their DZI names.
+func collectTiles(tiles map[string]*image.NRGBA) TileSink {
+	return TileSinkFunc(func(level, col, row int, tile *image.NRGBA) error {
+		name := fmt.Sprintf("%d/%d_%d", level, col, row)
+		if _, ok := tiles[name]; ok {
+			return fmt.Errorf("duplicate tile %s", name)
+		}
+		tiles[name] = tile
+		return nil
+	})
+}
+
+func TestGenerateTilesPixels(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	src := image.NewNRGBA(image.Rect(0, 0, 37, 23))
+	for i := range src.Pix {
+		src.Pix[i] = uint8(rnd.Intn(256))
+	}
+
+	for _, tc := range []struct{ tileSize, overlap int }{{4, 1}, {5, 2}, {16, 0}, {64, 1}} {
+		tiles := make(map[string]*image.NRGBA)
+		if err := GenerateTiles(src, tc.tileSize, tc.overlap, collectTiles(tiles)); err != nil {
+			t.Fatalf("GenerateTiles: %v", err)
+		}
+
+		// Each level is the 2x2 box downscale of the next one, cut into tiles.
+		level := src
+		count := 0
+		for l := TileLevels(37, 23) - 1; l >= 0; l-- {
+			ts, ov := tc.tileSize, tc.overlap
+			for row := 0; row*ts < level.Rect.Dy(); row++ {
+				for col := 0; col*ts < level.Rect.Dx(); col++ {
+					want := Crop(level, image.Rect(col*ts-ov, row*ts-ov, (col+1)*ts+ov, (row+1)*ts+ov))
+					name := fmt.Sprintf("%d/%d_%d", l, col, row)
+					if got, ok := tiles[name]; !ok || !compareNRGBA(got, want, 0) {
+						t.Fatalf("tile size %d: tile %s differs from the expected one", ts, name)
+					}
+					count++
+				}
+			}
+			level = shrinkBox(level, 2, 2)
+		}
+		if len(tiles) != count {
+			t.Fatalf("tile size %d: got %d tiles want %d", tc.tileSize, len(tiles), count)
+		}
+	}
+}
+
+func TestGenerateTilesFromReader(t *testing.T) {
+	rnd := rand.New(rand.NewSource(2))
+	src := image.NewNRGBA(image.Rect(0, 0, 41, 30))
+	for i := range src.Pix {
+		src.Pix[i] = uint8(rnd.Intn(256))
+	}
+	want := make(map[string]*image.NRGBA)
+	if err := GenerateTiles(src, 5, 1, collectTiles(want)); err != nil {
+		t.Fatalf("GenerateTiles: %v", err)
+	}
+
+	// The TIFF images are read by bands, the others entirely.
+	for _, format := range []Format{TIFF, PNG} {
+		buf := &bytes.Buffer{}
+		if err := Encode(buf, src, format); err != nil {
+			t.Fatalf("failed to encode image: %v", err)
+		}
+		got := make(map[string]*image.NRGBA)
+		if err := GenerateTilesFromReader(bytes.NewReader(buf.Bytes()), 5, 1, collectTiles(got)); err != nil {
+			t.Fatalf("GenerateTilesFromReader(%v): %v", format, err)
+		}
+		if len(got) != len(want) {
+			t.Fatalf("%v: got %d tiles want %d", format, len(got), len(want))
+		}
+		for name, tile := range want {
+			if !compareNRGBA(got[name], tile, 0) {
+				t.Fatalf("%v: tile %s differs", format, name)
+			}
+		}
+
+		err := GenerateTilesFromReader(bytes.NewReader(buf.Bytes()), 5, 1, collectTiles(got), DecodeLimits(Limits{MaxPixels: 100}))
+		if err != ErrLimitExceeded {
+			t.Fatalf("%v: got error %v want ErrLimitExceeded", format, err)
+		}
+	}
+
+	if err := GenerateTilesFromReader(bytes.NewReader(nil), 0, 1, nil); err == nil {
+		t.Fatalf("expected error got nil")
+	}
+	if err := GenerateTilesFromReader(strings.NewReader("bad data"), 4, 1, collectTiles(nil)); err == nil {
+		t.Fatalf("expected error got nil")
+	}
+}
+
+func TestDZI(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := WriteDZI(buf, 600, 400, 254, 1, JPEG); err != nil {
+		t.Fatalf("WriteDZI: %v", err)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<Image xmlns="http://schemas.microsoft.com/deepzoom/2008" Format="jpg" Overlap="1" TileSize="254">
+  <Size Width="600" Height="400"/>
+</Image>
+`
+	if buf.String() != want {
+		t.Fatalf("got descriptor %q want %q", buf.String(), want)
+	}
+	if err := WriteDZI(buf, 600, 400, 254, 1, GIF); err != ErrUnsupportedFormat {
+		t.Fatalf("got error %v want ErrUnsupportedFormat", err)
+	}
+
+	fsys := &memFS{MapFS: fstest.MapFS{}}
+	if err := GenerateTiles(New(10, 5, image.White.C), 8, 1, DZISink(fsys, "out_files", PNG)); err != nil {
+		t.Fatalf("GenerateTiles: %v", err)
+	}
+	for _, name := range []string{"out_files/0/0_0.png", "out_files/4/0_0.png", "out_files/4/1_0.png"} {
+		if _, err := OpenFS(fsys, name); err != nil {
+			t.Fatalf("OpenFS(%s): %v", name, err)
+		}
+	}
+	if len(fsys.MapFS) != 6 {
+		t.Fatalf("got %d files want 6", len(fsys.MapFS))
+	}
+	if err := GenerateTiles(New(10, 5, image.White.C), 8, 1, DZISink(fsys, "out_files", BMP)); err != ErrUnsupportedFormat {
+		t.Fatalf("got error %v want ErrUnsupportedFormat", err)
+	}
+}
@@ -27,10 +27,20 @@ func New(width, height int, fillColor color.Color) *image.NRGBA {
 
 // Clone returns a copy of the given image.
 func Clone(img image.Image) *image.NRGBA {
+	return cloneInto(nil, img)
+}
+
+// CloneInto is like Clone but copies the image into dst, reusing its pixel buffer
+// if it's large enough, and returns dst. The dst image must not share pixels with img.
+func CloneInto(dst *image.NRGBA, img image.Image) *image.NRGBA {
+	return cloneInto(dst, img)
+}
+
+func cloneInto(dst *image.NRGBA, img image.Image) *image.NRGBA {
 	src := newScanner(img)
-	dst := image.NewNRGBA(image.Rect(0, 0, src.w, src.h))
+	dst = newNRGBA(dst, image.Rect(0, 0, src.w, src.h))
 	size := src.w * 4
-	parallel(0, src.h, func(ys <-chan int) {
+	parallel(0, src.h, src.w*costCopy, func(ys <-chan int) {
 		for y := range ys {
 			i := y * dst.Stride
 			src.scan(0, y, src.w, y+1, dst.Pix[i:i+size])
@@ -103,7 +113,7 @@ func Crop(img image.Image, rect image.Rectangle) *image.NRGBA {
 	src := newScanner(img)
 	dst := image.NewNRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
 	rowSize := r.Dx() * 4
-	parallel(r.Min.Y, r.Max.Y, func(ys <-chan int) {
+	parallel(r.Min.Y, r.Max.Y, r.Dx()*costCopy, func(ys <-chan int) {
 		for y := range ys {
 			i := (y - r.Min.Y) * dst.Stride
 			src.scan(r.Min.X, y, r.Max.X, y+1, dst.Pix[i:i+rowSize])
@@ -142,7 +152,7 @@ func Paste(background, img image.Image, pos image.Point) *image.NRGBA {
 	}
 
 	src := newScanner(img)
-	parallel(interRect.Min.Y, interRect.Max.Y, func(ys <-chan int) {
+	parallel(interRect.Min.Y, interRect.Max.Y, interRect.Dx()*costCopy, func(ys <-chan int) {
 		for y := range ys {
 			x1 := interRect.Min.X - pasteRect.Min.X
 			x2 := interRect.Max.X - pasteRect.Min.X
@@ -195,7 +205,7 @@ func Overlay(background, img image.Image, pos image.Point, opacity float64) *ima
 		return dst
 	}
 	src := newScanner(img)
-	parallel(interRect.Min.Y, interRect.Max.Y, func(ys <-chan int) {
+	parallel(interRect.Min.Y, interRect.Max.Y, interRect.Dx()*costPoint, func(ys <-chan int) {
 		scanLine := make([]uint8, interRect.Dx()*4)
 		for y := range ys {
 			x1 := interRect.Min.X - pasteRect.Min.X
@@ -255,3 +265,164 @@ func OverlayCenter(background, img image.Image, opacity float64) *image.NRGBA {
 
 	return Overlay(background, img, image.Point{x0, y0}, opacity)
 }
+
+// BlendMode is a blend mode used to combine the colors of two image layers.
+type BlendMode int
+
+// Blend modes.
+const (
+	// BlendNormal uses the color of the top layer.
+	BlendNormal BlendMode = iota
+	// BlendMultiply multiplies the colors, the result is always darker.
+	BlendMultiply
+	// BlendScreen multiplies the inverted colors, the result is always lighter.
+	BlendScreen
+	// BlendOverlay multiplies or screens the colors depending on the bottom layer color.
+	BlendOverlay
+	// BlendDarken uses the darker of the colors.
+	BlendDarken
+	// BlendLighten uses the lighter of the colors.
+	BlendLighten
+	// BlendColorDodge brightens the bottom layer color to reflect the top layer color.
+	BlendColorDodge
+	// BlendColorBurn darkens the bottom layer color to reflect the top layer color.
+	BlendColorBurn
+	// BlendHardLight multiplies or screens the colors depending on the top layer color.
+	BlendHardLight
+	// BlendSoftLight darkens or lightens the colors depending on the top layer color.
+	BlendSoftLight
+	// BlendDifference subtracts the darker of the colors from the lighter one.
+	BlendDifference
+	// BlendExclusion is similar to BlendDifference but has lower contrast.
+	BlendExclusion
+	// BlendAdd adds the colors (also known as linear dodge).
+	BlendAdd
+)
+
+// blendFunc returns the function that blends the bottom layer color b with the top layer
+// color s. Both colors are in range [0, 1].
+func (mode BlendMode) blendFunc() func(b, s float64) float64 {
+	switch mode {
+	case BlendMultiply:
+		return func(b, s float64) float64 { return b * s }
+	case BlendScreen:
+		return func(b, s float64) float64 { return b + s - b*s }
+	case BlendOverlay:
+		return func(b, s float64) float64 { return blendHardLight(s, b) }
+	case BlendDarken:
+		return math.Min
+	case BlendLighten:
+		return math.Max
+	case BlendColorDodge:
+		return func(b, s float64) float64 {
+			if b == 0 {
+				return 0
+			}
+			if s == 1 {
+				return 1
+			}
+			return math.Min(1, b/(1-s))
+		}
+	case BlendColorBurn:
+		return func(b, s float64) float64 {
+			if b == 1 {
+				return 1
+			}
+			if s == 0 {
+				return 0
+			}
+			return 1 - math.Min(1, (1-b)/s)
+		}
+	case BlendHardLight:
+		return blendHardLight
+	case BlendSoftLight:
+		return func(b, s float64) float64 {
+			if s <= 0.5 {
+				return b - (1-2*s)*b*(1-b)
+			}
+			var d float64
+			if b <= 0.25 {
+				d = ((16*b-12)*b + 4) * b
+			} else {
+				d = math.Sqrt(b)
+			}
+			return b + (2*s-1)*(d-b)
+		}
+	case BlendDifference:
+		return func(b, s float64) float64 { return math.Abs(b - s) }
+	case BlendExclusion:
+		return func(b, s float64) float64 { return b + s - 2*b*s }
+	case BlendAdd:
+		return func(b, s float64) float64 { return math.Min(1, b+s) }
+	default:
+		return func(b, s float64) float64 { return s }
+	}
+}
+
+func blendHardLight(b, s float64) float64 {
+	if s <= 0.5 {
+		return b * 2 * s
+	}
+	return b + (2*s - 1) - b*(2*s-1)
+}
+
+// OverlayWithMode is like Overlay but combines the colors of the images using the given
+// blend mode. The blended colors are composed with the background image according to the
+// alpha channels of the images and the opacity, which must be from 0.0 to 1.0.
+// The BlendNormal mode gives the same result as Overlay.
+//
+// Example:
+//
+//	// Darken the photo using the texture image.
+//	dstImage := imaging.OverlayWithMode(photo, texture, image.Pt(0, 0), 0.8, imaging.BlendMultiply)
+//
+func OverlayWithMode(background, img image.Image, pos image.Point, opacity float64, mode BlendMode) *image.NRGBA {
+	if mode == BlendNormal {
+		return Overlay(background, img, pos, opacity)
+	}
+	blend := mode.blendFunc()
+	opacity = math.Min(math.Max(opacity, 0.0), 1.0) // Ensure 0.0 <= opacity <= 1.0.
+	dst := Clone(background)
+	pos = pos.Sub(background.Bounds().Min)
+	pasteRect := image.Rectangle{Min: pos, Max: pos.Add(img.Bounds().Size())}
+	interRect := pasteRect.Intersect(dst.Bounds())
+	if interRect.Empty() {
+		return dst
+	}
+	src := newScanner(img)
+	parallel(interRect.Min.Y, interRect.Max.Y, interRect.Dx()*costHeavy, func(ys <-chan int) {
+		scanLine := make([]uint8, interRect.Dx()*4)
+		for y := range ys {
+			x1 := interRect.Min.X - pasteRect.Min.X
+			x2 := interRect.Max.X - pasteRect.Min.X
+			y1 := y - pasteRect.Min.Y
+			y2 := y1 + 1
+			src.scan(x1, y1, x2, y2, scanLine)
+			i := y*dst.Stride + interRect.Min.X*4
+			j := 0
+			for x := interRect.Min.X; x < interRect.Max.X; x++ {
+				d := dst.Pix[i : i+4 : i+4]
+				s := scanLine[j : j+4 : j+4]
+				i += 4
+				j += 4
+
+				ab := float64(d[3]) / 255
+				as := opacity * float64(s[3]) / 255
+				ao := as + ab*(1-as)
+				if ao == 0 {
+					continue
+				}
+				for k := 0; k < 3; k++ {
+					cb := float64(d[k]) / 255
+					cs := float64(s[k]) / 255
+					// The top layer color is mixed with the blended color
+					// according to the background alpha.
+					cs = (1-ab)*cs + ab*blend(cb, cs)
+					d[k] = clamp((as*cs + (1-as)*ab*cb) / ao * 255)
+				}
+				d[3] = clamp(ao * 255)
+			}
+		}
+	})
+	return dst
+}
@@ -321,6 +321,29 @@ func TestClone(t *testing.T) {
 	}
 }
 
+func TestCloneInto(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 1, 0),
+		Stride: 2 * 4,
+		Pix:    []uint8{0x00, 0x11, 0x22, 0x33, 0xcc, 0xdd, 0xee, 0xff},
+	}
+	buf := New(3, 3, color.White)
+	pix := buf.Pix
+	got := CloneInto(buf, src)
+	if got != buf || &got.Pix[0] != &pix[0] {
+		t.Fatalf("CloneInto didn't reuse dst")
+	}
+	want := Clone(src)
+	if !compareNRGBA(got, want, 0) {
+		t.Fatalf("got result %#v want %#v", got, want)
+	}
+
+	got = CloneInto(&image.NRGBA{}, src)
+	if !compareNRGBA(got, want, 0) {
+		t.Fatalf("got result %#v want %#v", got, want)
+	}
+}
+
 func TestCrop(t *testing.T) {
 	testCases := []struct {
 		name string
@@ -1128,6 +1151,67 @@ func BenchmarkOverlay(b *testing.B) {
 	}
 }
 
+func TestOverlayWithMode(t *testing.T) {
+	pixel := func(r, g, b, a uint8) *image.NRGBA {
+		return &image.NRGBA{Rect: image.Rect(0, 0, 1, 1), Stride: 4, Pix: []uint8{r, g, b, a}}
+	}
+	bg := pixel(0x80, 0x40, 0xff, 0xff)
+	fg := pixel(0x40, 0x80, 0x00, 0xff)
+
+	testCases := []struct {
+		name    string
+		bg      image.Image
+		mode    BlendMode
+		opacity float64
+		want    *image.NRGBA
+	}{
+		{"Multiply", bg, BlendMultiply, 1, pixel(0x20, 0x20, 0x00, 0xff)},
+		{"Screen", bg, BlendScreen, 1, pixel(0xa0, 0xa0, 0xff, 0xff)},
+		{"Darken", bg, BlendDarken, 1, pixel(0x40, 0x40, 0x00, 0xff)},
+		{"Lighten", bg, BlendLighten, 1, pixel(0x80, 0x80, 0xff, 0xff)},
+		{"Difference", bg, BlendDifference, 1, pixel(0x40, 0x40, 0xff, 0xff)},
+		{"Add", bg, BlendAdd, 1, pixel(0xc0, 0xc0, 0xff, 0xff)},
+		{"Multiply 0.5", bg, BlendMultiply, 0.5, pixel(0x50, 0x30, 0x80, 0xff)},
+		{"Multiply opacity 0", bg, BlendMultiply, 0, bg},
+		{"Multiply transparent background", pixel(0x80, 0x40, 0xff, 0x00), BlendMultiply, 1, fg},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := OverlayWithMode(tc.bg, fg, image.Pt(0, 0), tc.opacity, tc.mode)
+			if !compareNRGBA(got, tc.want, 1) {
+				t.Fatalf("got result %#v want %#v", got, tc.want)
+			}
+		})
+	}
+
+	// Blending with white (black for additive modes) keeps the background unchanged.
+	white := New(8, 8, color.White)
+	black := New(8, 8, color.Black)
+	for _, mode := range []BlendMode{BlendMultiply, BlendDarken, BlendColorBurn} {
+		got := OverlayWithMode(testdataFlowersSmallPNG, white, image.Pt(0, 0), 1, mode)
+		if !compareNRGBA(got, Clone(testdataFlowersSmallPNG), 1) {
+			t.Fatalf("mode %d: background changed after blending with white", mode)
+		}
+	}
+	for _, mode := range []BlendMode{BlendScreen, BlendLighten, BlendColorDodge, BlendDifference, BlendExclusion, BlendAdd} {
+		got := OverlayWithMode(testdataFlowersSmallPNG, black, image.Pt(0, 0), 1, mode)
+		if !compareNRGBA(got, Clone(testdataFlowersSmallPNG), 1) {
+			t.Fatalf("mode %d: background changed after blending with black", mode)
+		}
+	}
+
+	got := OverlayWithMode(testdataBranchesPNG, testdataFlowersSmallPNG, image.Pt(10, 20), 0.7, BlendNormal)
+	want := Overlay(testdataBranchesPNG, testdataFlowersSmallPNG, image.Pt(10, 20), 0.7)
+	if !compareNRGBA(got, want, 0) {
+		t.Fatalf("BlendNormal result differs from Overlay")
+	}
+
+	got = OverlayWithMode(bg, fg, image.Pt(5, 5), 1, BlendMultiply)
+	if !compareNRGBA(got, bg, 0) {
+		t.Fatalf("non-overlapping images: got %#v want %#v", got, bg)
+	}
+}
+
 func TestOverlayCenter(t *testing.T) {
 	testCases := []struct {
 		name string
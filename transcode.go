@@ -0,0 +1,148 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+)
+
+// TranscodeOptions are the parameters of Transcode.
+type TranscodeOptions struct {
+	// Format is the output format.
+	Format Format
+
+	// If AutoOrientation is true, the EXIF orientation is applied to the pixels,
+	// as with the AutoOrientation decode option.
+	AutoOrientation bool
+
+	// Quality is the JPEG quality of the output. If it's 0, the original JPEG data
+	// is kept when possible, and the default quality is used otherwise.
+	Quality int
+
+	// If StripMetadata is true, the EXIF and XMP metadata and the comments are removed.
+	// The ICC profile is always kept, and so is the EXIF orientation of the images that
+	// are not rotated by AutoOrientation.
+	StripMetadata bool
+
+	// Limits are the decode limits checked when the image has to be decoded, as with
+	// the DecodeLimits option. The zero value means no limits.
+	Limits Limits
+}
+
+// Transcode reads an image from r and writes it to w in the format given by the options.
+// It is intended for normalizing uploaded images. When the input is a JPEG image, the output
+// format is JPEG and the quality is not set, the compressed image data is copied as is and
+// only the metadata segments are rewritten, so there is no decoding, no generation loss and
+// the work is proportional to the file size. This is possible as long as the image doesn't
+// need to be rotated: when the orientation has to be applied, the image is decoded, transformed
+// and encoded again. Stripping the metadata of a rotated image keeps the orientation tag. A re-encoded
+// image keeps only the ICC profile, so the orientation is always applied to its pixels.
+// If options is nil, the image is converted to JPEG keeping the metadata.
+//
+// Example:
+//
+//	err := imaging.Transcode(r.Body, w, &imaging.TranscodeOptions{
+//		Format:          imaging.JPEG,
+//		AutoOrientation: true,
+//		StripMetadata:   true,
+//	})
+//
+func Transcode(r io.Reader, w io.Writer, options *TranscodeOptions) error {
+	if options == nil {
+		options = &TranscodeOptions{Format: JPEG}
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	if options.Format == JPEG && options.Quality == 0 && bytes.HasPrefix(data, []byte("\xff\xd8")) {
+		o := readOrientation(bytes.NewReader(data))
+		rotated := o != orientationUnspecified && o != orientationNormal
+		if !rotated || !options.AutoOrientation {
+			err := copyJPEG(w, data, options.StripMetadata, rotated)
+			if err != errJPEGPassthrough {
+				return err
+			}
+		}
+	}
+
+	// The metadata isn't written by the encoders, so the orientation has to be applied.
+	decodeOpts := []DecodeOption{AutoOrientation(true)}
+	if options.Limits != (Limits{}) {
+		decodeOpts = append(decodeOpts, DecodeLimits(options.Limits))
+	}
+	img, err := Decode(bytes.NewReader(data), decodeOpts...)
+	if err != nil {
+		return err
+	}
+
+	var opts []EncodeOption
+	if options.Quality > 0 {
+		opts = append(opts, JPEGQuality(options.Quality))
+	}
+	if profile, err := ReadICCProfile(bytes.NewReader(data)); err == nil && profile != nil {
+		opts = append(opts, ICCProfile(profile))
+	}
+	return Encode(w, img, options.Format, opts...)
+}
+
+// errJPEGPassthrough means that the JPEG data can't be copied without decoding.
+var errJPEGPassthrough = errors.New("imaging: JPEG data can't be copied")
+
+// copyJPEG writes the JPEG data to w, optionally removing the EXIF and XMP (APP1)
+// segments and the comments. If keepOrientation is true, the removed EXIF segment is
+// replaced with one holding only the orientation tag. The data is validated up to the start
+// of the image scan before anything is written, and errJPEGPassthrough is returned if it's
+// malformed.
+func copyJPEG(w io.Writer, data []byte, strip, keepOrientation bool) error {
+	const (
+		markerAPP1 = 0xe1
+		markerCOM  = 0xfe
+		markerSOS  = 0xda
+	)
+
+	var segments [][]byte
+	i := 2
+	for {
+		if i+4 > len(data) || data[i] != 0xff {
+			return errJPEGPassthrough
+		}
+		marker := data[i+1]
+		if marker == markerSOS {
+			break
+		}
+		size := int(binary.BigEndian.Uint16(data[i+2:]))
+		if size < 2 || i+2+size > len(data) {
+			return errJPEGPassthrough
+		}
+		seg := data[i : i+2+size]
+		i += 2 + size
+		if strip && keepOrientation && marker == markerAPP1 && bytes.HasPrefix(seg[4:], []byte(jpegEXIFHeader)) {
+			tiff := stripEXIF(seg[4+len(jpegEXIFHeader):], [MetaComment + 1]bool{MetaOrientation: true})
+			if tiff == nil {
+				return errJPEGPassthrough
+			}
+			n := 2 + len(jpegEXIFHeader) + len(tiff)
+			exif := append([]byte{0xff, markerAPP1, byte(n >> 8), byte(n)}, jpegEXIFHeader...)
+			segments = append(segments, append(exif, tiff...))
+			continue
+		}
+		if !strip || (marker != markerAPP1 && marker != markerCOM) {
+			segments = append(segments, seg)
+		}
+	}
+
+	if _, err := w.Write(data[:2]); err != nil {
+		return err
+	}
+	for _, s := range segments {
+		if _, err := w.Write(s); err != nil {
+			return err
+		}
+	}
+	_, err := w.Write(data[i:])
+	return err
+}
@@ -0,0 +1,101 @@
+package imaging
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestTranscode(t *testing.T) {
+	normal, err := ioutil.ReadFile("testdata/orientation_1.jpg")
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	rotated, err := ioutil.ReadFile("testdata/orientation_6.jpg")
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+
+	transcode := func(data []byte, options *TranscodeOptions) []byte {
+		t.Helper()
+		var buf bytes.Buffer
+		if err := Transcode(bytes.NewReader(data), &buf, options); err != nil {
+			t.Fatalf("Transcode: %v", err)
+		}
+		return buf.Bytes()
+	}
+
+	// Passthrough: nothing to change.
+	if got := transcode(normal, nil); !bytes.Equal(got, normal) {
+		t.Fatalf("JPEG data is not copied as is")
+	}
+	if got := transcode(rotated, &TranscodeOptions{Format: JPEG}); !bytes.Equal(got, rotated) {
+		t.Fatalf("JPEG data is not copied as is")
+	}
+
+	// Passthrough with the metadata removed: the pixels are the same.
+	got := transcode(normal, &TranscodeOptions{Format: JPEG, StripMetadata: true})
+	if len(got) >= len(normal) || bytes.Contains(got, []byte("Exif\x00\x00")) {
+		t.Fatalf("metadata is not removed")
+	}
+	img, err := Decode(bytes.NewReader(got))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	want, err := Decode(bytes.NewReader(normal))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if !compareNRGBA(toNRGBA(img), toNRGBA(want), 0) {
+		t.Fatalf("pixels changed after removing metadata")
+	}
+
+	// Passthrough of a rotated image: only the orientation tag is kept.
+	got = transcode(rotated, &TranscodeOptions{Format: JPEG, StripMetadata: true})
+	if len(got) >= len(rotated) || !bytes.HasSuffix(rotated, got[bytes.Index(got, []byte{0xff, 0xda}):]) {
+		t.Fatalf("JPEG data is not copied")
+	}
+	if o := readOrientation(bytes.NewReader(got)); o != orientationRotate270 {
+		t.Fatalf("got orientation %v want %v", o, orientationRotate270)
+	}
+	// The APP1 segment holds the EXIF header and a TIFF structure with one IFD entry.
+	if !bytes.Contains(got, []byte("\xff\xe1\x00\x22Exif\x00\x00")) || bytes.Count(got, []byte("\xff\xe1")) != 1 {
+		t.Fatalf("the EXIF data is not reduced to the orientation")
+	}
+
+	// Rotation requires re-encoding.
+	want, err = Decode(bytes.NewReader(rotated), AutoOrientation(true))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	for _, options := range []*TranscodeOptions{
+		{Format: JPEG, AutoOrientation: true},
+		{Format: JPEG, AutoOrientation: true, StripMetadata: true},
+		{Format: JPEG, Quality: 100},
+		{Format: PNG},
+	} {
+		got := transcode(rotated, options)
+		img, err := Decode(bytes.NewReader(got), AutoOrientation(true))
+		if err != nil {
+			t.Fatalf("Decode: %v", err)
+		}
+		if img.Bounds() != want.Bounds() {
+			t.Fatalf("%+v: got bounds %v want %v", options, img.Bounds(), want.Bounds())
+		}
+		if !compareNRGBA(toNRGBA(img), toNRGBA(want), 16) {
+			t.Fatalf("%+v: resulting image differs from the rotated image", options)
+		}
+	}
+
+	// The limits are checked when the image is decoded.
+	var buf bytes.Buffer
+	options := &TranscodeOptions{Format: JPEG, AutoOrientation: true, Limits: Limits{MaxPixels: 100}}
+	if err := Transcode(bytes.NewReader(rotated), &buf, options); err != ErrLimitExceeded {
+		t.Fatalf("got error %v want ErrLimitExceeded", err)
+	}
+
+	buf.Reset()
+	if err := Transcode(bytes.NewReader([]byte("\xff\xd8garbage")), &buf, nil); err == nil {
+		t.Fatalf("expected error for invalid data")
+	}
+}
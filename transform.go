@@ -1,6 +1,7 @@
 package imaging
 
 import (
+	"context"
 	"image"
 	"image/color"
 	"math"
@@ -13,7 +14,7 @@ func FlipH(img image.Image) *image.NRGBA {
 	dstH := src.h
 	rowSize := dstW * 4
 	dst := image.NewNRGBA(image.Rect(0, 0, dstW, dstH))
-	parallel(0, dstH, func(ys <-chan int) {
+	parallel(0, dstH, dstW*costCopy, func(ys <-chan int) {
 		for dstY := range ys {
 			i := dstY * dst.Stride
 			srcY := dstY
@@ -31,7 +32,7 @@ func FlipV(img image.Image) *image.NRGBA {
 	dstH := src.h
 	rowSize := dstW * 4
 	dst := image.NewNRGBA(image.Rect(0, 0, dstW, dstH))
-	parallel(0, dstH, func(ys <-chan int) {
+	parallel(0, dstH, dstW*costCopy, func(ys <-chan int) {
 		for dstY := range ys {
 			i := dstY * dst.Stride
 			srcY := dstH - dstY - 1
@@ -48,7 +49,7 @@ func Transpose(img image.Image) *image.NRGBA {
 	dstH := src.w
 	rowSize := dstW * 4
 	dst := image.NewNRGBA(image.Rect(0, 0, dstW, dstH))
-	parallel(0, dstH, func(ys <-chan int) {
+	parallel(0, dstH, dstW*costCopy, func(ys <-chan int) {
 		for dstY := range ys {
 			i := dstY * dst.Stride
 			srcX := dstY
@@ -65,7 +66,7 @@ func Transverse(img image.Image) *image.NRGBA {
 	dstH := src.w
 	rowSize := dstW * 4
 	dst := image.NewNRGBA(image.Rect(0, 0, dstW, dstH))
-	parallel(0, dstH, func(ys <-chan int) {
+	parallel(0, dstH, dstW*costCopy, func(ys <-chan int) {
 		for dstY := range ys {
 			i := dstY * dst.Stride
 			srcX := dstH - dstY - 1
@@ -83,7 +84,7 @@ func Rotate90(img image.Image) *image.NRGBA {
 	dstH := src.w
 	rowSize := dstW * 4
 	dst := image.NewNRGBA(image.Rect(0, 0, dstW, dstH))
-	parallel(0, dstH, func(ys <-chan int) {
+	parallel(0, dstH, dstW*costCopy, func(ys <-chan int) {
 		for dstY := range ys {
 			i := dstY * dst.Stride
 			srcX := dstH - dstY - 1
@@ -100,7 +101,7 @@ func Rotate180(img image.Image) *image.NRGBA {
 	dstH := src.h
 	rowSize := dstW * 4
 	dst := image.NewNRGBA(image.Rect(0, 0, dstW, dstH))
-	parallel(0, dstH, func(ys <-chan int) {
+	parallel(0, dstH, dstW*costCopy, func(ys <-chan int) {
 		for dstY := range ys {
 			i := dstY * dst.Stride
 			srcY := dstH - dstY - 1
@@ -118,7 +119,7 @@ func Rotate270(img image.Image) *image.NRGBA {
 	dstH := src.w
 	rowSize := dstW * 4
 	dst := image.NewNRGBA(image.Rect(0, 0, dstW, dstH))
-	parallel(0, dstH, func(ys <-chan int) {
+	parallel(0, dstH, dstW*costCopy, func(ys <-chan int) {
 		for dstY := range ys {
 			i := dstY * dst.Stride
 			srcX := dstY
@@ -133,6 +134,36 @@ func Rotate270(img image.Image) *image.NRGBA {
 // The angle parameter is the rotation angle in degrees.
 // The bgColor parameter specifies the color of the uncovered zone after the rotation.
 func Rotate(img image.Image, angle float64, bgColor color.Color) *image.NRGBA {
+	return rotate(context.Background(), img, angle, bgColor)
+}
+
+// RotateCtx is like Rotate but stops processing and returns the context error
+// if the context is canceled or its deadline is exceeded before the rotation is done.
+func RotateCtx(ctx context.Context, img image.Image, angle float64, bgColor color.Color) (*image.NRGBA, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ctx = beginProgress(ctx, rotateWork(img, angle))
+	dst := rotate(ctx, img, angle, bgColor)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	endProgress(ctx)
+	return dst, nil
+}
+
+// rotateWork returns the amount of work done by rotate, as used for the progress reporting.
+// The rotations by right angles are fast and are counted as a single step.
+func rotateWork(img image.Image, angle float64) int {
+	angle = angle - math.Floor(angle/360)*360
+	if angle == 0 || angle == 90 || angle == 180 || angle == 270 {
+		return 0
+	}
+	_, h := rotatedSize(img.Bounds().Dx(), img.Bounds().Dy(), angle)
+	return h
+}
+
+func rotate(ctx context.Context, img image.Image, angle float64, bgColor color.Color) *image.NRGBA {
 	angle = angle - math.Floor(angle/360)*360
 
 	switch angle {
@@ -164,7 +195,7 @@ func Rotate(img image.Image, angle float64, bgColor color.Color) *image.NRGBA {
 	bgColorNRGBA := color.NRGBAModel.Convert(bgColor).(color.NRGBA)
 	sin, cos := math.Sincos(math.Pi * angle / 180)
 
-	parallel(0, dstH, func(ys <-chan int) {
+	parallelCtx(ctx, 0, dstH, dstW*4*costTap, func(ys <-chan int) {
 		for dstY := range ys {
 			for dstX := 0; dstX < dstW; dstX++ {
 				xf, yf := rotatePoint(float64(dstX)-dstXOff, float64(dstY)-dstYOff, sin, cos)
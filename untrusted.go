@@ -0,0 +1,140 @@
+package imaging
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"runtime"
+	"sync/atomic"
+)
+
+// ErrPanic means that the processing of the image panicked, e.g. because of a bug
+// in a decoder triggered by a malformed image. The returned errors wrap ErrPanic
+// together with the panic value.
+var ErrPanic = errors.New("imaging: panic during image processing")
+
+// UntrustedLimits are the decode limits of the Untrusted processors.
+var UntrustedLimits = Limits{
+	MaxWidth:  16384,
+	MaxHeight: 16384,
+	MaxPixels: 50000000,
+	MaxBytes:  50 << 20,
+}
+
+// Untrusted returns a Processor with the safe defaults for the images received from
+// untrusted sources, such as the uploads of a web service:
+//
+//   - Decode and Open reject the images exceeding UntrustedLimits with ErrLimitExceeded
+//     before allocating the pixels, and the decoded images failing ValidateImage
+//     with ErrInvalidImage. The images are auto-oriented and converted to sRGB.
+//   - The panics are recovered: Decode, Open and Encode return an error wrapping ErrPanic,
+//     the other operations return an empty image. They are counted in the statistics.
+//   - At most GOMAXPROCS operations run at the same time, the others wait.
+//   - Encode doesn't write the metadata, such as the ICC profile.
+//
+// The limits can be changed with WithDecodeLimits.
+//
+// Example:
+//
+//	var p = imaging.Untrusted()
+//
+//	img, err := p.Decode(r.Body)
+//	if err != nil {
+//		http.Error(w, "invalid image", http.StatusBadRequest)
+//		return
+//	}
+//	err = p.Encode(w, p.Fit(img, 800, 800, imaging.Lanczos), imaging.JPEG)
+//
+func Untrusted() *Processor {
+	p := NewProcessor(nil)
+	p.decodeOpts = []DecodeOption{AutoOrientation(true), ColorProfileConversion(true), DecodeLimits(UntrustedLimits)}
+	p.validate = true
+	p.recoverPanics = true
+	p.slots = make(chan struct{}, runtime.GOMAXPROCS(0))
+	p.stripMetadata = true
+	return p
+}
+
+// WithDecodeLimits returns a new Processor with the same settings that uses the limits
+// in Decode and Open. The new Processor has its own statistics.
+//
+// Example:
+//
+//	p := imaging.Untrusted().WithDecodeLimits(imaging.Limits{MaxPixels: 10000000, MaxBytes: 10 << 20})
+//
+func (p *Processor) WithDecodeLimits(limits Limits) *Processor {
+	q := *p
+	q.decodeOpts = append(p.decodeOpts[:len(p.decodeOpts):len(p.decodeOpts)], DecodeLimits(limits))
+	q.stats = &processorStats{}
+	return &q
+}
+
+// Decode reads an image from r like the Decode function, using the decode options
+// and the checks of the processor.
+func (p *Processor) Decode(r io.Reader) (image.Image, error) {
+	defer p.acquire()()
+	var img image.Image
+	err := p.protect(func() error {
+		var err error
+		img, err = Decode(r, p.decodeOpts...)
+		if err == nil && p.validate {
+			err = ValidateImage(img)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+// Open loads an image from file like the Open function, using the decode options
+// and the checks of the processor.
+func (p *Processor) Open(filename string) (image.Image, error) {
+	file, err := fs.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return p.Decode(file)
+}
+
+// Encode writes the image to w in the specified format like the Encode function.
+// The metadata options are ignored if the processor strips the metadata.
+func (p *Processor) Encode(w io.Writer, img image.Image, format Format, opts ...EncodeOption) error {
+	if p.stripMetadata {
+		opts = append(opts[:len(opts):len(opts)], func(c *encodeConfig) {
+			c.iccProfile = nil
+			c.targetProfile = nil
+		})
+	}
+	defer p.acquire()()
+	return p.protect(func() error {
+		return Encode(w, img, format, opts...)
+	})
+}
+
+// acquire waits for a free slot if the number of concurrent operations is bounded.
+// It returns the function releasing the slot.
+func (p *Processor) acquire() func() {
+	if p.slots == nil {
+		return func() {}
+	}
+	p.slots <- struct{}{}
+	return func() { <-p.slots }
+}
+
+// protect calls fn, turning its panic into an error wrapping ErrPanic
+// if the processor recovers panics.
+func (p *Processor) protect(fn func() error) (err error) {
+	if p.recoverPanics {
+		defer func() {
+			if r := recover(); r != nil {
+				atomic.AddInt64(&p.stats.panics, 1)
+				err = fmt.Errorf("%w: %v", ErrPanic, r)
+			}
+		}()
+	}
+	return fn()
+}
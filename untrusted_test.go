@@ -0,0 +1,123 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"sync"
+	"testing"
+	"time"
+)
+
+type panicReader struct{}
+
+func (panicReader) Read(p []byte) (int, error) { panic("bad reader") }
+
+type panicBackend struct{ cpuBackend }
+
+func (panicBackend) Resize(img image.Image, width, height int, filter ResampleFilter) *image.NRGBA {
+	panic("bad backend")
+}
+
+// concurrencyBackend records the largest number of the concurrent operations.
+type concurrencyBackend struct {
+	cpuBackend
+	mu       sync.Mutex
+	running  int
+	maxCount int
+}
+
+func (b *concurrencyBackend) ColorMatrix(img image.Image, matrix [20]float64) *image.NRGBA {
+	b.mu.Lock()
+	b.running++
+	b.maxCount = max(b.maxCount, b.running)
+	b.mu.Unlock()
+	time.Sleep(10 * time.Millisecond)
+	b.mu.Lock()
+	b.running--
+	b.mu.Unlock()
+	return b.cpuBackend.ColorMatrix(img, matrix)
+}
+
+func TestUntrustedDecode(t *testing.T) {
+	data, err := EncodeBytes(testdataFlowersSmallPNG, PNG)
+	if err != nil {
+		t.Fatalf("failed to encode image: %v", err)
+	}
+	p := Untrusted()
+	img, err := p.Decode(bytes.NewReader(data))
+	if err != nil || !Equal(img, testdataFlowersSmallPNG) {
+		t.Fatalf("got a different image, error %v", err)
+	}
+
+	small := p.WithDecodeLimits(Limits{MaxPixels: 1000})
+	if _, err := small.Decode(bytes.NewReader(data)); err != ErrLimitExceeded {
+		t.Fatalf("got error %v, want ErrLimitExceeded", err)
+	}
+	if _, err := p.Decode(bytes.NewReader(data)); err != nil {
+		t.Fatalf("the limits of the original processor changed: %v", err)
+	}
+
+	if _, err := p.Decode(panicReader{}); !errors.Is(err, ErrPanic) {
+		t.Fatalf("got error %v, want ErrPanic", err)
+	}
+	if got := p.Stats().Panics; got != 1 {
+		t.Fatalf("got %d panics", got)
+	}
+
+	if _, err := p.Open("testdata/missing.png"); err == nil {
+		t.Fatalf("expected error")
+	}
+	if _, err := p.Open("testdata/branches.png"); err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+}
+
+func TestUntrustedEncode(t *testing.T) {
+	p := Untrusted()
+	var buf bytes.Buffer
+	if err := p.Encode(&buf, testdataFlowersSmallPNG, PNG, ICCProfile(srgbICCProfile)); err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	want, _ := EncodeBytes(testdataFlowersSmallPNG, PNG)
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("the metadata isn't stripped")
+	}
+
+	// The ordinary processors keep the metadata.
+	buf.Reset()
+	if err := NewProcessor(nil).Encode(&buf, testdataFlowersSmallPNG, PNG, ICCProfile(srgbICCProfile)); err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	if profile, err := ReadICCProfile(&buf); err != nil || !bytes.Equal(profile, srgbICCProfile) {
+		t.Fatalf("got profile of %d bytes, error %v", len(profile), err)
+	}
+}
+
+func TestUntrustedOperations(t *testing.T) {
+	p := Untrusted()
+	p.backend = panicBackend{}
+	if got := p.Resize(testdataBranchesPNG, 10, 10, Lanczos); !got.Rect.Empty() {
+		t.Fatalf("got bounds %v", got.Rect)
+	}
+	if got := p.Stats(); got.Panics != 1 || got.Operations != 1 {
+		t.Fatalf("got stats %+v", got)
+	}
+
+	b := &concurrencyBackend{}
+	p = Untrusted()
+	p.backend = b
+	p.slots = make(chan struct{}, 2)
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.ColorMatrix(testdataBranchesPNG, [20]float64{0: 1, 6: 1, 12: 1, 18: 1})
+		}()
+	}
+	wg.Wait()
+	if b.maxCount != 2 {
+		t.Fatalf("got %d concurrent operations, want 2", b.maxCount)
+	}
+}
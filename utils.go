@@ -1,42 +1,175 @@
 package imaging
 
 import (
+	"context"
 	"image"
 	"math"
 	"runtime"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 var maxProcs int64
 
+// minParallelWork is the minimum estimated work per goroutine in nanoseconds.
+var minParallelWork = int64(100 * time.Microsecond)
+
+// The estimated costs of processing a single pixel in nanoseconds. The cost of an item
+// processed by parallel is the number of its pixels multiplied by one of these.
+const (
+	costCopy  = 1  // Copying or scanning the pixels.
+	costPoint = 4  // Arithmetic or table lookups on each pixel.
+	costTap   = 2  // A single tap of a filter kernel.
+	costHeavy = 40 // Callbacks, transcendental functions and interpolation of 3D tables.
+)
+
 // SetMaxProcs limits the number of concurrent processing goroutines to the given value.
 // A value <= 0 clears the limit.
 func SetMaxProcs(value int) {
 	atomic.StoreInt64(&maxProcs, int64(value))
 }
 
-// parallel processes the data in separate goroutines.
-func parallel(start, stop int, fn func(<-chan int)) {
-	count := stop - start
-	if count < 1 {
+// SetMinParallelWork sets the minimum estimated amount of work per processing goroutine.
+// The work of an operation is estimated from the number of the pixels and the cost of
+// the operation per pixel, and the number of goroutines is reduced so that each of them
+// gets at least the given amount of work. Small images are processed on the calling
+// goroutine, avoiding the scheduling overhead. The estimation doesn't depend on timing,
+// so the same image is always split the same way. A value <= 0 disables the estimation
+// so all the allowed goroutines are always used. The default value is 100µs.
+//
+// Example:
+//
+//	// Thumbnail service processing many small images concurrently.
+//	imaging.SetMinParallelWork(time.Millisecond)
+//
+func SetMinParallelWork(d time.Duration) {
+	atomic.StoreInt64(&minParallelWork, int64(d))
+}
+
+// ParallelOptions are the parameters of Parallel.
+type ParallelOptions struct {
+	// ChunkSize is the number of items passed to each call of the processing function.
+	// Larger chunks reduce the scheduling overhead and keep the memory accesses of each call
+	// together, smaller ones balance the load better. If ChunkSize <= 0, the items are split
+	// into about 8 chunks per goroutine.
+	ChunkSize int
+
+	// ItemCost is the estimated time needed to process a single item. If ItemCost > 0,
+	// the number of goroutines is reduced for small amounts of work according to
+	// SetMinParallelWork, otherwise all the allowed goroutines are used.
+	ItemCost time.Duration
+}
+
+// Parallel processes the items from 0 to n-1 in separate goroutines the same way
+// the functions of this package do: the items are split into chunks that are handed out
+// to the goroutines as they become free, the number of the goroutines is limited by
+// SetMaxProcs and reduced for small amounts of work according to SetMinParallelWork
+// and the ItemCost option. The function fn is called for each chunk with its range of
+// items [lo, hi). The options may be nil. Parallel returns when all the items are processed.
+//
+// Example:
+//
+//	// Invert the rows of an NRGBA image.
+//	imaging.Parallel(img.Rect.Dy(), func(lo, hi int) {
+//		for y := lo; y < hi; y++ {
+//			row := img.Pix[y*img.Stride : y*img.Stride+img.Rect.Dx()*4]
+//			for i := range row {
+//				if i%4 != 3 {
+//					row[i] = 255 - row[i]
+//				}
+//			}
+//		}
+//	}, &imaging.ParallelOptions{ItemCost: time.Duration(img.Rect.Dx()) * time.Nanosecond})
+//
+func Parallel(n int, fn func(lo, hi int), options *ParallelOptions) {
+	if n <= 0 {
 		return
 	}
+	chunk, cost := 0, int64(0)
+	if options != nil {
+		chunk, cost = options.ChunkSize, int64(options.ItemCost)
+	}
+	if chunk <= 0 {
+		chunks := parallelProcs(n, cost) * 8
+		chunk = (n + chunks - 1) / chunks
+	}
+	parallel(0, (n+chunk-1)/chunk, int(min(int64(chunk)*cost, math.MaxInt)), func(cs <-chan int) {
+		for c := range cs {
+			lo := c * chunk
+			fn(lo, min(lo+chunk, n))
+		}
+	})
+}
 
+// parallelProcs returns the number of goroutines used to process count items,
+// each of them estimated to take cost nanoseconds. A cost <= 0 is unknown,
+// then all the allowed goroutines are used.
+func parallelProcs(count int, cost int64) int {
 	procs := runtime.GOMAXPROCS(0)
 	limit := int(atomic.LoadInt64(&maxProcs))
 	if procs > limit && limit > 0 {
 		procs = limit
 	}
+	if procs > platformMaxProcs && platformMaxProcs > 0 {
+		procs = platformMaxProcs
+	}
 	if procs > count {
 		procs = count
 	}
+	if work := atomic.LoadInt64(&minParallelWork); work > 0 && cost > 0 {
+		if n := cost * int64(count) / work; n < int64(procs) {
+			procs = int(n)
+		}
+	}
+	if procs < 1 {
+		procs = 1
+	}
+	return procs
+}
+
+// parallel processes the data in separate goroutines.
+// The cost is the estimated time needed to process a single item in nanoseconds.
+func parallel(start, stop, cost int, fn func(<-chan int)) {
+	parallelCtx(context.Background(), start, stop, cost, fn)
+}
 
-	c := make(chan int, count)
-	for i := start; i < stop; i++ {
-		c <- i
+// parallelCtx processes the data in separate goroutines.
+// It stops handing out new indices to fn once the context is done.
+func parallelCtx(ctx context.Context, start, stop, cost int, fn func(<-chan int)) {
+	count := stop - start
+	if count < 1 {
+		return
+	}
+	fn = trackProgress(ctx, fn)
+	procs := parallelProcs(count, int64(cost))
+
+	var c chan int
+	if ctx.Done() == nil {
+		c = make(chan int, count)
+		for i := start; i < stop; i++ {
+			c <- i
+		}
+		close(c)
+	} else {
+		c = make(chan int)
+		go func() {
+			defer close(c)
+			for i := start; i < stop; i++ {
+				select {
+				case c <- i:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	if procs == 1 {
+		// No need to spawn a goroutine for a single worker.
+		fn(c)
+		return
 	}
-	close(c)
 
 	var wg sync.WaitGroup
 	for i := 0; i < procs; i++ {
@@ -87,6 +220,57 @@ func reverse(pix []uint8) {
 	}
 }
 
+// newNRGBA returns a zeroed image with the given bounds. If dst is not nil,
+// it's reused and its pixel buffer is reallocated only if it's too small.
+func newNRGBA(dst *image.NRGBA, r image.Rectangle) *image.NRGBA {
+	if dst == nil {
+		return image.NewNRGBA(r)
+	}
+	n := r.Dx() * r.Dy() * 4
+	if cap(dst.Pix) < n {
+		dst.Pix = make([]uint8, n)
+	} else {
+		dst.Pix = dst.Pix[:n]
+		for i := range dst.Pix {
+			dst.Pix[i] = 0
+		}
+	}
+	dst.Stride = r.Dx() * 4
+	dst.Rect = r
+	return dst
+}
+
+// withScratch returns dst and an image for the intermediate results with the bounds s
+// of a two-pass operation writing the result with the bounds r into dst. The scratch
+// image uses the capacity of the pixel buffer of dst after the result. A buffer too small
+// for the result is replaced by one with room for both, so repeated calls with the same dst
+// don't allocate the intermediate image. If dst is nil or only has room for the result,
+// the scratch image is nil and is allocated by the first pass.
+func withScratch(dst *image.NRGBA, r, s image.Rectangle) (*image.NRGBA, *image.NRGBA) {
+	if dst == nil {
+		return nil, nil
+	}
+	n, m := r.Dx()*r.Dy()*4, s.Dx()*s.Dy()*4
+	if cap(dst.Pix) < n+m {
+		if cap(dst.Pix) >= n {
+			return dst, nil
+		}
+		dst.Pix = make([]uint8, 0, n+m)
+	}
+	return dst, &image.NRGBA{Pix: dst.Pix[n : n : n+m]}
+}
+
+// emptyNRGBA returns an empty image. If dst is not nil, it's reset and returned.
+func emptyNRGBA(dst *image.NRGBA) *image.NRGBA {
+	if dst == nil {
+		return &image.NRGBA{}
+	}
+	dst.Pix = dst.Pix[:0]
+	dst.Stride = 0
+	dst.Rect = image.Rectangle{}
+	return dst
+}
+
 func toNRGBA(img image.Image) *image.NRGBA {
 	if img, ok := img.(*image.NRGBA); ok {
 		return &image.NRGBA{
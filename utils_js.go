@@ -0,0 +1,8 @@
+//go:build js
+
+package imaging
+
+// platformMaxProcs is the maximum number of processing goroutines
+// supported by the platform. WebAssembly in the browser runs on a single
+// thread, so spawning more than one worker only adds scheduling overhead.
+const platformMaxProcs = 1
@@ -0,0 +1,7 @@
+//go:build !js
+
+package imaging
+
+// platformMaxProcs is the maximum number of processing goroutines
+// supported by the platform. A value <= 0 means no limit.
+const platformMaxProcs = 0
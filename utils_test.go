@@ -1,11 +1,13 @@
 package imaging
 
 import (
+	"context"
 	"image"
 	"math"
 	"runtime"
 	"sync/atomic"
 	"testing"
+	"time"
 )
 
 var (
@@ -22,6 +24,17 @@ func mustOpen(filename string) image.Image {
 	return img
 }
 
+// allocatedBytes returns the average number of bytes allocated by a call to fn.
+func allocatedBytes(runs int, fn func()) uint64 {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	for i := 0; i < runs; i++ {
+		fn()
+	}
+	runtime.ReadMemStats(&after)
+	return (after.TotalAlloc - before.TotalAlloc) / uint64(runs)
+}
+
 func TestParallel(t *testing.T) {
 	for _, n := range []int{0, 1, 10, 100, 1000} {
 		for _, p := range []int{1, 2, 4, 8, 16, 100} {
@@ -36,7 +49,7 @@ func testParallelN(n, procs int) bool {
 	data := make([]bool, n)
 	before := runtime.GOMAXPROCS(0)
 	runtime.GOMAXPROCS(procs)
-	parallel(0, n, func(is <-chan int) {
+	parallel(0, n, 0, func(is <-chan int) {
 		for i := range is {
 			data[i] = true
 		}
@@ -63,7 +76,7 @@ func TestParallelMaxProcs(t *testing.T) {
 func testParallelMaxProcsN(n, procs int) bool {
 	data := make([]bool, n)
 	SetMaxProcs(procs)
-	parallel(0, n, func(is <-chan int) {
+	parallel(0, n, 0, func(is <-chan int) {
 		for i := range is {
 			data[i] = true
 		}
@@ -77,6 +90,73 @@ func testParallelMaxProcsN(n, procs int) bool {
 	return true
 }
 
+func TestParallelExported(t *testing.T) {
+	for _, n := range []int{0, 1, 10, 1000} {
+		for _, chunk := range []int{0, 1, 7, 2000} {
+			counts := make([]int32, n)
+			var calls int32
+			Parallel(n, func(lo, hi int) {
+				atomic.AddInt32(&calls, 1)
+				if lo >= hi || (chunk > 0 && hi-lo > chunk) {
+					t.Errorf("got chunk [%d, %d) for chunk size %d", lo, hi, chunk)
+				}
+				for i := lo; i < hi; i++ {
+					atomic.AddInt32(&counts[i], 1)
+				}
+			}, &ParallelOptions{ChunkSize: chunk})
+			for i, c := range counts {
+				if c != 1 {
+					t.Fatalf("item %d of %d processed %d times with chunk size %d", i, n, c, chunk)
+				}
+			}
+			if chunk > 0 && int(calls) != (n+chunk-1)/chunk {
+				t.Fatalf("got %d calls for %d items with chunk size %d", calls, n, chunk)
+			}
+		}
+	}
+
+	// The number of goroutines is limited by SetMaxProcs.
+	SetMaxProcs(1)
+	defer SetMaxProcs(0)
+	var active, maxActive int32
+	Parallel(100, func(lo, hi int) {
+		a := atomic.AddInt32(&active, 1)
+		if a > atomic.LoadInt32(&maxActive) {
+			atomic.StoreInt32(&maxActive, a)
+		}
+		time.Sleep(10 * time.Microsecond)
+		atomic.AddInt32(&active, -1)
+	}, &ParallelOptions{ChunkSize: 1})
+	if maxActive != 1 {
+		t.Fatalf("got %d concurrent calls with SetMaxProcs(1)", maxActive)
+	}
+}
+
+func TestParallelCtx(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var count int64
+	parallelCtx(ctx, 0, 1000, 0, func(is <-chan int) {
+		for range is {
+			if atomic.AddInt64(&count, 1) == 10 {
+				cancel()
+			}
+		}
+	})
+	if count >= 1000 {
+		t.Fatalf("got %d processed items after cancel, want less than 1000", count)
+	}
+
+	count = 0
+	parallelCtx(context.Background(), 0, 1000, 0, func(is <-chan int) {
+		for range is {
+			atomic.AddInt64(&count, 1)
+		}
+	})
+	if count != 1000 {
+		t.Fatalf("got %d processed items, want 1000", count)
+	}
+}
+
 func TestSetMaxProcs(t *testing.T) {
 	for _, p := range []int{-1, 0, 10} {
 		SetMaxProcs(p)
@@ -88,6 +168,60 @@ func TestSetMaxProcs(t *testing.T) {
 	SetMaxProcs(0)
 }
 
+func TestSetMinParallelWork(t *testing.T) {
+	defer SetMinParallelWork(100 * time.Microsecond)
+	before := runtime.GOMAXPROCS(4)
+	defer runtime.GOMAXPROCS(before)
+
+	testCases := []struct {
+		work  time.Duration
+		cost  int
+		calls int64
+	}{
+		{100 * time.Microsecond, 0, 4},
+		{100 * time.Microsecond, 1000, 1},
+		{100 * time.Microsecond, 2500, 2},
+		{100 * time.Microsecond, 1000000, 4},
+		{time.Hour, 1000000, 1},
+		{0, 1, 4},
+		{-1, 1, 4},
+	}
+	for _, tc := range testCases {
+		SetMinParallelWork(tc.work)
+		// The estimation doesn't depend on timing, so the results are the same every time.
+		for i := 0; i < 3; i++ {
+			var calls, count int64
+			parallel(0, 100, tc.cost, func(is <-chan int) {
+				atomic.AddInt64(&calls, 1)
+				for range is {
+					atomic.AddInt64(&count, 1)
+				}
+			})
+			if count != 100 {
+				t.Fatalf("work %v cost %d: got %d processed items want 100", tc.work, tc.cost, count)
+			}
+			if calls != tc.calls {
+				t.Fatalf("work %v cost %d: got %d calls want %d", tc.work, tc.cost, calls, tc.calls)
+			}
+		}
+	}
+
+	// Parallel uses a single goroutine for cheap items.
+	SetMinParallelWork(100 * time.Microsecond)
+	var active, maxActive int32
+	Parallel(100, func(lo, hi int) {
+		a := atomic.AddInt32(&active, 1)
+		if a > atomic.LoadInt32(&maxActive) {
+			atomic.StoreInt32(&maxActive, a)
+		}
+		time.Sleep(10 * time.Microsecond)
+		atomic.AddInt32(&active, -1)
+	}, &ParallelOptions{ItemCost: time.Nanosecond})
+	if maxActive != 1 {
+		t.Fatalf("got %d concurrent calls for cheap items", maxActive)
+	}
+}
+
 func TestClamp(t *testing.T) {
 	testCases := []struct {
 		f float64
@@ -0,0 +1,114 @@
+package imaging
+
+import (
+	"errors"
+	"image"
+)
+
+// ErrInvalidImage means that the image structure is inconsistent, e.g. its pixel
+// buffer is too short for its bounds or it refers to missing palette colors.
+var ErrInvalidImage = errors.New("imaging: invalid image")
+
+// ValidateImage checks that the pixel buffers of the standard library image types
+// (*image.NRGBA, *image.YCbCr, *image.Paletted etc.) are consistent with the image bounds
+// and that the paletted images use only the colors of their palette. Other image types
+// are considered valid. It returns ErrInvalidImage if the image is malformed.
+//
+// The functions of this package don't panic on such images: the images with inconsistent
+// buffers are processed as fully transparent, and the missing palette colors are treated
+// as transparent. ValidateImage allows to reject these images explicitly.
+//
+// Example:
+//
+//	if err := imaging.ValidateImage(img); err != nil {
+//		return err
+//	}
+//
+func ValidateImage(img image.Image) error {
+	if !validBuffers(img) {
+		return ErrInvalidImage
+	}
+	if p, ok := img.(*image.Paletted); ok {
+		w, h := p.Rect.Dx(), p.Rect.Dy()
+		for y := 0; y < h; y++ {
+			for _, c := range p.Pix[y*p.Stride : y*p.Stride+w] {
+				if int(c) >= len(p.Palette) {
+					return ErrInvalidImage
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// validBuffers reports whether the pixel buffers of the image are large enough
+// for its bounds. It takes constant time.
+func validBuffers(img image.Image) bool {
+	switch img := img.(type) {
+	case *image.NRGBA:
+		return validPix(len(img.Pix), img.Stride, img.Rect, 4)
+	case *image.NRGBA64:
+		return validPix(len(img.Pix), img.Stride, img.Rect, 8)
+	case *image.RGBA:
+		return validPix(len(img.Pix), img.Stride, img.Rect, 4)
+	case *image.RGBA64:
+		return validPix(len(img.Pix), img.Stride, img.Rect, 8)
+	case *image.Gray:
+		return validPix(len(img.Pix), img.Stride, img.Rect, 1)
+	case *image.Gray16:
+		return validPix(len(img.Pix), img.Stride, img.Rect, 2)
+	case *image.Paletted:
+		return validPix(len(img.Pix), img.Stride, img.Rect, 1)
+	case *image.CMYK:
+		return validPix(len(img.Pix), img.Stride, img.Rect, 4)
+	case *image.YCbCr:
+		r := img.Rect
+		if !validPix(len(img.Y), img.YStride, r, 1) {
+			return false
+		}
+		if r.Empty() {
+			return true
+		}
+		var cr image.Rectangle
+		switch img.SubsampleRatio {
+		case image.YCbCrSubsampleRatio444:
+			cr = image.Rect(0, 0, r.Dx(), r.Dy())
+		case image.YCbCrSubsampleRatio422:
+			cr = image.Rect(0, 0, (r.Max.X+1)/2-r.Min.X/2, r.Dy())
+		case image.YCbCrSubsampleRatio420:
+			cr = image.Rect(0, 0, (r.Max.X+1)/2-r.Min.X/2, (r.Max.Y+1)/2-r.Min.Y/2)
+		case image.YCbCrSubsampleRatio440:
+			cr = image.Rect(0, 0, r.Dx(), (r.Max.Y+1)/2-r.Min.Y/2)
+		case image.YCbCrSubsampleRatio411:
+			cr = image.Rect(0, 0, (r.Max.X+3)/4-r.Min.X/4, r.Dy())
+		case image.YCbCrSubsampleRatio410:
+			cr = image.Rect(0, 0, (r.Max.X+3)/4-r.Min.X/4, (r.Max.Y+1)/2-r.Min.Y/2)
+		default:
+			return false
+		}
+		return validPix(len(img.Cb), img.CStride, cr, 1) && validPix(len(img.Cr), img.CStride, cr, 1)
+	}
+	return true
+}
+
+// validPix reports whether a buffer of the given length and stride holds all the pixels
+// of the rectangle, with the first pixel at index 0.
+func validPix(n, stride int, r image.Rectangle, bytesPerPixel int) bool {
+	if r.Empty() {
+		return true
+	}
+	w, h := r.Dx(), r.Dy()
+	if w <= 0 || h <= 0 || w > maxInt/bytesPerPixel {
+		return false
+	}
+	row := w * bytesPerPixel
+	if h == 1 {
+		return n >= row
+	}
+	if stride < row || stride > (maxInt-row)/(h-1) {
+		return false
+	}
+	return n >= (h-1)*stride+row
+}
+
+const maxInt = int(^uint(0) >> 1)
@@ -0,0 +1,85 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestValidateImage(t *testing.T) {
+	short := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	short.Pix = short.Pix[:len(short.Pix)-1]
+
+	badStride := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	badStride.Stride = 4
+
+	ycbcr := image.NewYCbCr(image.Rect(0, 0, 5, 5), image.YCbCrSubsampleRatio420)
+	shortChroma := image.NewYCbCr(image.Rect(0, 0, 5, 5), image.YCbCrSubsampleRatio420)
+	shortChroma.Cr = shortChroma.Cr[:len(shortChroma.Cr)-1]
+	badRatio := image.NewYCbCr(image.Rect(0, 0, 5, 5), image.YCbCrSubsampleRatio444)
+	badRatio.SubsampleRatio = image.YCbCrSubsampleRatio(100)
+
+	paletted := image.NewPaletted(image.Rect(0, 0, 2, 2), []color.Color{color.Black, color.White})
+	paletted.Pix[3] = 1
+	badIndex := image.NewPaletted(image.Rect(0, 0, 2, 2), []color.Color{color.Black, color.White})
+	badIndex.Pix[3] = 2
+
+	testCases := []struct {
+		name  string
+		img   image.Image
+		valid bool
+	}{
+		{"NRGBA", image.NewNRGBA(image.Rect(-2, -2, 3, 3)), true},
+		{"NRGBA sub-image", image.NewNRGBA(image.Rect(0, 0, 30, 30)).SubImage(image.Rect(10, 10, 20, 20)), true},
+		{"NRGBA empty", &image.NRGBA{}, true},
+		{"NRGBA short", short, false},
+		{"RGBA stride", badStride, false},
+		{"NRGBA64", image.NewNRGBA64(image.Rect(0, 0, 3, 3)), true},
+		{"Gray16 short", &image.Gray16{Rect: image.Rect(0, 0, 3, 1), Stride: 6, Pix: make([]uint8, 5)}, false},
+		{"YCbCr", ycbcr, true},
+		{"YCbCr odd sub-image", ycbcr.SubImage(image.Rect(1, 1, 4, 4)), true},
+		{"YCbCr short chroma", shortChroma, false},
+		{"YCbCr ratio", badRatio, false},
+		{"Paletted", paletted, true},
+		{"Paletted index", badIndex, false},
+		{"other", image.NewUniform(color.White), true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateImage(tc.img)
+			if tc.valid && err != nil {
+				t.Fatalf("got error %v want nil", err)
+			}
+			if !tc.valid && err != ErrInvalidImage {
+				t.Fatalf("got error %v want ErrInvalidImage", err)
+			}
+		})
+	}
+}
+
+func TestScanInvalidImage(t *testing.T) {
+	short := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for i := range short.Pix {
+		short.Pix[i] = 0xff
+	}
+	short.Pix = short.Pix[:len(short.Pix)-4]
+	got := Resize(short, 2, 2, Linear)
+	if !compareNRGBA(got, image.NewNRGBA(image.Rect(0, 0, 2, 2)), 0) {
+		t.Fatalf("got result %#v want transparent image", got)
+	}
+
+	ycbcr := image.NewYCbCr(image.Rect(0, 0, 5, 5), image.YCbCrSubsampleRatio420)
+	ycbcr.Cb = ycbcr.Cb[:2]
+	got = Blur(ycbcr, 1)
+	if !compareNRGBA(got, image.NewNRGBA(image.Rect(0, 0, 5, 5)), 0) {
+		t.Fatalf("got result %#v want transparent image", got)
+	}
+
+	paletted := image.NewPaletted(image.Rect(0, 0, 2, 1), []color.Color{color.White})
+	paletted.Pix[1] = 7
+	got = Clone(paletted)
+	want := []uint8{0xff, 0xff, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00}
+	if !compareBytes(got.Pix, want, 0) {
+		t.Fatalf("got pixels %#v want %#v", got.Pix, want)
+	}
+}
@@ -0,0 +1,279 @@
+package imaging
+
+import (
+	"image"
+	"math"
+	"reflect"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// VariantMode specifies how a variant is produced from the source image.
+type VariantMode int
+
+// Variant modes.
+const (
+	// VariantResize resizes the image (see Resize).
+	VariantResize VariantMode = iota
+	// VariantFit scales down the image to fit the size (see Fit).
+	VariantFit
+	// VariantFill fills the size cropping the image (see Fill).
+	VariantFill
+)
+
+// VariantSpec describes a single variant produced by Variants.
+type VariantSpec struct {
+	Name    string         // Name is copied to the result to identify the variant.
+	Mode    VariantMode    // Mode specifies how the image is scaled.
+	Width   int            // Width of the variant.
+	Height  int            // Height of the variant.
+	Anchor  Anchor         // Anchor is used by the VariantFill mode.
+	Filter  ResampleFilter // Filter is the resampling filter.
+	Format  Format         // Format is the output format.
+	Options []EncodeOption // Options are the encoding options.
+}
+
+// Encoded is an encoded variant of an image.
+type Encoded struct {
+	Name   string
+	Format Format
+	Width  int
+	Height int
+	Data   []byte
+}
+
+// Variants produces the encoded variants of the source image described by the specs
+// and returns them in the same order. It is faster than processing each variant separately:
+//
+//   - the source image is converted to the internal pixel format only once;
+//   - when all the variants are much smaller than the source, the source is first scaled
+//     down once with the Box filter to twice the size of the largest variant;
+//   - the variants that differ only in the format or encoding options are scaled once;
+//   - the variants are encoded concurrently, the number of goroutines is limited
+//     by GOMAXPROCS and SetMaxProcs.
+//
+// If encoding of any variant fails, the first error is returned.
+//
+// Example:
+//
+//	variants, err := imaging.Variants(img, []imaging.VariantSpec{
+//		{Name: "large", Mode: imaging.VariantFit, Width: 1600, Height: 1600, Filter: imaging.Lanczos, Format: imaging.JPEG},
+//		{Name: "thumb", Mode: imaging.VariantFill, Width: 200, Height: 200, Filter: imaging.Lanczos, Format: imaging.JPEG},
+//		{Name: "thumb-png", Mode: imaging.VariantFill, Width: 200, Height: 200, Filter: imaging.Lanczos, Format: imaging.PNG},
+//	})
+//
+func Variants(src image.Image, specs []VariantSpec) ([]Encoded, error) {
+	img := toNRGBA(src)
+	srcW, srcH := img.Bounds().Dx(), img.Bounds().Dy()
+
+	// Scale of the largest variant relative to the source.
+	var maxScale float64
+	for _, spec := range specs {
+		w, h := variantSize(srcW, srcH, spec)
+		if srcW > 0 && srcH > 0 {
+			maxScale = math.Max(maxScale, math.Max(float64(w)/float64(srcW), float64(h)/float64(srcH)))
+		}
+	}
+	base := img
+	if maxScale > 0 && maxScale*4 <= 1 {
+		w := int(math.Ceil(float64(srcW) * maxScale * 2))
+		h := int(math.Ceil(float64(srcH) * maxScale * 2))
+		base = Resize(img, w, h, Box)
+	}
+
+	type scaled struct {
+		once sync.Once
+		img  *image.NRGBA
+	}
+	cache := make(map[variantKey]*scaled)
+	items := make([]*scaled, len(specs))
+	for i, spec := range specs {
+		key, ok := newVariantKey(spec)
+		if !ok {
+			items[i] = &scaled{}
+			continue
+		}
+		if cache[key] == nil {
+			cache[key] = &scaled{}
+		}
+		items[i] = cache[key]
+	}
+
+	results := make([]Encoded, len(specs))
+	errs := make([]error, len(specs))
+	process := func(i int) {
+		spec := specs[i]
+		item := items[i]
+		item.once.Do(func() {
+			item.img = scaleVariant(base, srcW, srcH, spec)
+		})
+		data, err := EncodeBytes(item.img, spec.Format, spec.Options...)
+		if err != nil {
+			errs[i] = err
+			return
+		}
+		results[i] = Encoded{
+			Name:   spec.Name,
+			Format: spec.Format,
+			Width:  item.img.Bounds().Dx(),
+			Height: item.img.Bounds().Dy(),
+			Data:   data,
+		}
+	}
+
+	procs := runtime.GOMAXPROCS(0)
+	if limit := int(atomic.LoadInt64(&maxProcs)); limit > 0 && procs > limit {
+		procs = limit
+	}
+	if procs > len(specs) {
+		procs = len(specs)
+	}
+	next := make(chan int, len(specs))
+	for i := range specs {
+		next <- i
+	}
+	close(next)
+	var wg sync.WaitGroup
+	for p := 0; p < procs; p++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				process(i)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// variantKey identifies the variants that have the same pixels.
+type variantKey struct {
+	mode          VariantMode
+	width, height int
+	anchor        Anchor
+	support       float64
+	kernel        uintptr
+}
+
+// newVariantKey returns the key of the variant. It returns false if the variant uses a custom
+// filter, as the kernels of custom filters can't be compared reliably.
+func newVariantKey(spec VariantSpec) (variantKey, bool) {
+	kernel := reflect.ValueOf(spec.Filter.Kernel).Pointer()
+	if kernel != 0 && !isPredefinedKernel(kernel) {
+		return variantKey{}, false
+	}
+	key := variantKey{
+		mode:    spec.Mode,
+		width:   spec.Width,
+		height:  spec.Height,
+		support: spec.Filter.Support,
+		kernel:  kernel,
+	}
+	if spec.Mode == VariantFill {
+		key.anchor = spec.Anchor
+	}
+	return key, true
+}
+
+// variantSize returns the size of the variant of a srcW x srcH image.
+func variantSize(srcW, srcH int, spec VariantSpec) (int, int) {
+	switch spec.Mode {
+	case VariantFit:
+		return fitSize(srcW, srcH, spec.Width, spec.Height)
+	case VariantFill:
+		if spec.Width <= 0 || spec.Height <= 0 {
+			return 0, 0
+		}
+		return spec.Width, spec.Height
+	default:
+		if spec.Width < 0 || spec.Height < 0 || (spec.Width == 0 && spec.Height == 0) || srcW <= 0 || srcH <= 0 {
+			return 0, 0
+		}
+		return resizeSize(srcW, srcH, spec.Width, spec.Height)
+	}
+}
+
+// scaleVariant produces the variant of a srcW x srcH image from img, which is
+// either the source image or its scaled down copy.
+func scaleVariant(img *image.NRGBA, srcW, srcH int, spec VariantSpec) *image.NRGBA {
+	if spec.Mode == VariantFill {
+		return Fill(img, spec.Width, spec.Height, spec.Anchor, spec.Filter)
+	}
+	// The size is calculated from the source size, so that it doesn't depend
+	// on the rounding of the scaled down copy size.
+	w, h := variantSize(srcW, srcH, spec)
+	if w <= 0 || h <= 0 {
+		return &image.NRGBA{}
+	}
+	return Resize(img, w, h, spec.Filter)
+}
+
+// Size is the width and height of an image. If one of them is 0, it's calculated
+// from the other one preserving the aspect ratio of the source image.
+type Size struct {
+	Width  int
+	Height int
+}
+
+// GenerateSizes resizes the image to each of the specified sizes using the specified
+// resample filter and returns the results in the same order. The largest sizes are
+// produced first, and each smaller one is derived from the smallest result that is
+// at least twice as large in both dimensions, so the full-resolution source is scanned
+// only for the largest sizes. An invalid size produces an empty image.
+//
+// Example:
+//
+//	images := imaging.GenerateSizes(srcImage, []imaging.Size{{1600, 0}, {800, 0}, {400, 0}, {200, 0}}, imaging.Lanczos)
+//
+func GenerateSizes(img image.Image, sizes []Size, filter ResampleFilter) []*image.NRGBA {
+	results := make([]*image.NRGBA, len(sizes))
+	srcW, srcH := img.Bounds().Dx(), img.Bounds().Dy()
+
+	type item struct {
+		index int
+		w, h  int
+	}
+	items := make([]item, 0, len(sizes))
+	for i, s := range sizes {
+		if s.Width < 0 || s.Height < 0 || (s.Width == 0 && s.Height == 0) || srcW <= 0 || srcH <= 0 {
+			results[i] = &image.NRGBA{}
+			continue
+		}
+		w, h := resizeSize(srcW, srcH, s.Width, s.Height)
+		items = append(items, item{i, w, h})
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].w*items[i].h > items[j].w*items[j].h
+	})
+
+	var src *image.NRGBA
+	var done []*image.NRGBA
+	for _, it := range items {
+		// Use the smallest of the produced images that is large enough.
+		var from *image.NRGBA
+		for _, d := range done {
+			if d.Rect.Dx() >= 2*it.w && d.Rect.Dy() >= 2*it.h &&
+				(from == nil || d.Rect.Dx()*d.Rect.Dy() < from.Rect.Dx()*from.Rect.Dy()) {
+				from = d
+			}
+		}
+		if from == nil {
+			if src == nil {
+				src = toNRGBA(img)
+			}
+			from = src
+		}
+		results[it.index] = Resize(from, it.w, it.h, filter)
+		done = append(done, results[it.index])
+	}
+	return results
+}
@@ -0,0 +1,115 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestVariants(t *testing.T) {
+	specs := []VariantSpec{
+		{Name: "fit", Mode: VariantFit, Width: 200, Height: 200, Filter: Lanczos, Format: PNG},
+		{Name: "fill", Mode: VariantFill, Width: 30, Height: 20, Anchor: TopLeft, Filter: Linear, Format: PNG},
+		{Name: "resize", Mode: VariantResize, Width: 40, Filter: CatmullRom, Format: BMP},
+		{Name: "resize-png", Mode: VariantResize, Width: 40, Filter: CatmullRom, Format: PNG},
+	}
+	want := []*image.NRGBA{
+		Fit(testdataBranchesPNG, 200, 200, Lanczos),
+		Fill(testdataBranchesPNG, 30, 20, TopLeft, Linear),
+		Resize(testdataBranchesPNG, 40, 0, CatmullRom),
+		Resize(testdataBranchesPNG, 40, 0, CatmullRom),
+	}
+
+	got, err := Variants(testdataBranchesPNG, specs)
+	if err != nil {
+		t.Fatalf("Variants: %v", err)
+	}
+	if len(got) != len(specs) {
+		t.Fatalf("got %d variants want %d", len(got), len(specs))
+	}
+	for i, v := range got {
+		if v.Name != specs[i].Name || v.Format != specs[i].Format {
+			t.Fatalf("variant %d: got name %q format %v want %q %v", i, v.Name, v.Format, specs[i].Name, specs[i].Format)
+		}
+		if v.Width != want[i].Bounds().Dx() || v.Height != want[i].Bounds().Dy() {
+			t.Fatalf("variant %q: got size %dx%d want %v", v.Name, v.Width, v.Height, want[i].Bounds().Size())
+		}
+		img, err := Decode(bytes.NewReader(v.Data))
+		if err != nil {
+			t.Fatalf("variant %q: decode: %v", v.Name, err)
+		}
+		if !compareNRGBA(Clone(img), want[i], 0) {
+			t.Fatalf("variant %q: result differs from the direct processing", v.Name)
+		}
+	}
+}
+
+func TestVariantsPrefilter(t *testing.T) {
+	src := New(400, 300, color.NRGBA{0x20, 0x40, 0x80, 0xff})
+	specs := []VariantSpec{
+		{Mode: VariantResize, Width: 30, Filter: Lanczos, Format: PNG},
+		{Mode: VariantFit, Width: 70, Height: 70, Filter: Lanczos, Format: PNG},
+		{Mode: VariantFill, Width: 20, Height: 20, Filter: Lanczos, Format: PNG},
+	}
+	got, err := Variants(src, specs)
+	if err != nil {
+		t.Fatalf("Variants: %v", err)
+	}
+	wantSizes := []image.Point{{30, 23}, {70, 52}, {20, 20}}
+	for i, v := range got {
+		if image.Pt(v.Width, v.Height) != wantSizes[i] {
+			t.Fatalf("variant %d: got size %dx%d want %v", i, v.Width, v.Height, wantSizes[i])
+		}
+		img, err := Decode(bytes.NewReader(v.Data))
+		if err != nil {
+			t.Fatalf("variant %d: decode: %v", i, err)
+		}
+		if !compareNRGBA(Clone(img), New(v.Width, v.Height, color.NRGBA{0x20, 0x40, 0x80, 0xff}), 1) {
+			t.Fatalf("variant %d: unexpected pixels", i)
+		}
+	}
+}
+
+func TestVariantsError(t *testing.T) {
+	specs := []VariantSpec{
+		{Mode: VariantResize, Width: 10, Filter: Box, Format: PNG},
+		{Mode: VariantResize, Width: 10, Filter: Box, Format: Format(100)},
+	}
+	if _, err := Variants(testdataBranchesPNG, specs); err != ErrUnsupportedFormat {
+		t.Fatalf("got error %v want ErrUnsupportedFormat", err)
+	}
+	got, err := Variants(testdataBranchesPNG, nil)
+	if err != nil || len(got) != 0 {
+		t.Fatalf("got %v, %v want no variants", got, err)
+	}
+}
+
+func TestGenerateSizes(t *testing.T) {
+	sizes := []Size{{100, 0}, {400, 0}, {0, 50}, {-1, 10}, {200, 200}, {0, 0}}
+	got := GenerateSizes(testdataBranchesPNG, sizes, Lanczos)
+	wantSizes := []image.Point{{100, 67}, {400, 267}, {75, 50}, {0, 0}, {200, 200}, {0, 0}}
+	if len(got) != len(sizes) {
+		t.Fatalf("got %d images want %d", len(got), len(sizes))
+	}
+	for i, img := range got {
+		if img.Bounds().Size() != wantSizes[i] {
+			t.Fatalf("size %v: got %v want %v", sizes[i], img.Bounds().Size(), wantSizes[i])
+		}
+	}
+	// The 400px image is produced directly from the source.
+	if !compareNRGBA(got[1], Resize(testdataBranchesPNG, 400, 0, Lanczos), 0) {
+		t.Fatalf("the largest size differs from the direct resizing")
+	}
+	// The smaller ones are close to the direct resizing.
+	for _, i := range []int{0, 2, 4} {
+		want := Resize(testdataBranchesPNG, sizes[i].Width, sizes[i].Height, Lanczos)
+		if !compareNRGBA(got[i], want, 16) {
+			t.Fatalf("size %v: the result differs too much from the direct resizing", sizes[i])
+		}
+	}
+
+	if got := GenerateSizes(&image.NRGBA{}, []Size{{10, 10}}, Lanczos); got[0].Bounds().Size() != (image.Point{}) {
+		t.Fatalf("got %v for an empty image", got[0].Bounds())
+	}
+}
@@ -0,0 +1,126 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// WatermarkOptions are watermark parameters.
+type WatermarkOptions struct {
+	// Opacity of the watermark, from 0.0 (fully transparent) to 1.0 (fully opaque),
+	// the same as in Overlay. If Opacity is nil, the watermark is fully opaque.
+	Opacity *float64
+
+	// Margin is the distance in pixels between the image edges and the watermark.
+	// A negative margin places the watermark partly outside the image, it's clipped
+	// to the image bounds.
+	Margin int
+
+	// Position is the position of a single watermark. It's ignored in the tile mode.
+	Position Anchor
+
+	// If Tile is true the watermark is repeated over the whole image.
+	Tile bool
+
+	// Spacing is the distance in pixels between the tiles.
+	Spacing int
+
+	// If Diagonal is true in the tile mode, the watermark is rotated by 45 degrees
+	// and every other row of the tiles is shifted by half of the tile width.
+	Diagonal bool
+}
+
+// Watermark draws the mark image over the img image and returns the combined image.
+// Default parameters are used if a nil *WatermarkOptions is passed: a single fully
+// opaque watermark in the center of the image.
+//
+// Examples:
+//
+//	// Semi-transparent logo in the bottom right corner.
+//	opacity := 0.5
+//	dstImage := imaging.Watermark(srcImage, logo, &imaging.WatermarkOptions{
+//		Opacity:  &opacity,
+//		Margin:   16,
+//		Position: imaging.BottomRight,
+//	})
+//
+//	// Diagonal tiles over the whole image.
+//	opacity := 0.3
+//	dstImage := imaging.Watermark(srcImage, logo, &imaging.WatermarkOptions{
+//		Opacity:  &opacity,
+//		Tile:     true,
+//		Spacing:  40,
+//		Diagonal: true,
+//	})
+//
+func Watermark(img, mark image.Image, options *WatermarkOptions) *image.NRGBA {
+	if options == nil {
+		options = &WatermarkOptions{}
+	}
+	opacity := 1.0
+	if options.Opacity != nil {
+		opacity = math.Min(math.Max(*options.Opacity, 0.0), 1.0) // Ensure 0.0 <= opacity <= 1.0.
+	}
+
+	dst := Clone(img)
+	if opacity == 0 {
+		return dst
+	}
+	var m *image.NRGBA
+	if options.Tile && options.Diagonal {
+		m = Rotate(mark, 45, color.Transparent)
+	} else {
+		m = toNRGBA(mark)
+	}
+	mw, mh := m.Bounds().Dx(), m.Bounds().Dy()
+	area := dst.Bounds().Inset(options.Margin)
+	if mw <= 0 || mh <= 0 || area.Empty() {
+		return dst
+	}
+
+	// markPixel returns the watermark pixel drawn over the dst pixel (x, y).
+	var markPixel func(x, y int) (color.NRGBA, bool)
+	if options.Tile {
+		spacing := options.Spacing
+		if spacing < 0 {
+			spacing = 0
+		}
+		stepX, stepY := mw+spacing, mh+spacing
+		origin := area.Min
+		markPixel = func(x, y int) (color.NRGBA, bool) {
+			dy := y - origin.Y
+			ty := dy % stepY
+			if ty >= mh {
+				return color.NRGBA{}, false
+			}
+			dx := x - origin.X
+			if options.Diagonal && dy/stepY%2 == 1 {
+				dx += stepX - stepX/2
+			}
+			tx := dx % stepX
+			if tx >= mw {
+				return color.NRGBA{}, false
+			}
+			return m.NRGBAAt(tx, ty), true
+		}
+	} else {
+		pos := anchorPt(area, mw, mh, options.Position)
+		area = area.Intersect(image.Rectangle{Min: pos, Max: pos.Add(image.Pt(mw, mh))})
+		markPixel = func(x, y int) (color.NRGBA, bool) {
+			return m.NRGBAAt(x-pos.X, y-pos.Y), true
+		}
+	}
+	area = area.Intersect(dst.Bounds())
+
+	parallel(area.Min.Y, area.Max.Y, area.Dx()*costPoint, func(ys <-chan int) {
+		for y := range ys {
+			for x := area.Min.X; x < area.Max.X; x++ {
+				if c, ok := markPixel(x, y); ok {
+					blendPixel(dst, x, y, c, opacity)
+				}
+			}
+		}
+	})
+	return dst
+}
@@ -0,0 +1,185 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestWatermark(t *testing.T) {
+	bg := New(8, 6, color.NRGBA{0x00, 0x00, 0x00, 0xff})
+	mark := New(2, 2, color.NRGBA{0xff, 0xff, 0xff, 0xff})
+	zero, half, over := 0.0, 0.5, 2.0
+
+	// marked returns the red channel values of the image rows, 0 or 0xff for each pixel.
+	marked := func(img *image.NRGBA) []string {
+		var rows []string
+		for y := 0; y < img.Rect.Dy(); y++ {
+			row := ""
+			for x := 0; x < img.Rect.Dx(); x++ {
+				switch img.NRGBAAt(x, y).R {
+				case 0x00:
+					row += "."
+				case 0xff:
+					row += "#"
+				default:
+					row += "+"
+				}
+			}
+			rows = append(rows, row)
+		}
+		return rows
+	}
+
+	testCases := []struct {
+		name    string
+		options *WatermarkOptions
+		want    []string
+	}{
+		{
+			"default",
+			nil,
+			[]string{
+				"........",
+				"........",
+				"...##...",
+				"...##...",
+				"........",
+				"........",
+			},
+		},
+		{
+			"bottom right with margin",
+			&WatermarkOptions{Margin: 1, Position: BottomRight},
+			[]string{
+				"........",
+				"........",
+				"........",
+				".....##.",
+				".....##.",
+				"........",
+			},
+		},
+		{
+			"opacity",
+			&WatermarkOptions{Opacity: &half, Position: TopLeft},
+			[]string{
+				"++......",
+				"++......",
+				"........",
+				"........",
+				"........",
+				"........",
+			},
+		},
+		{
+			"zero opacity",
+			&WatermarkOptions{Opacity: &zero, Tile: true},
+			[]string{
+				"........",
+				"........",
+				"........",
+				"........",
+				"........",
+				"........",
+			},
+		},
+		{
+			"opacity above 1",
+			&WatermarkOptions{Opacity: &over, Position: TopLeft},
+			[]string{
+				"##......",
+				"##......",
+				"........",
+				"........",
+				"........",
+				"........",
+			},
+		},
+		{
+			"negative margin",
+			&WatermarkOptions{Margin: -1, Position: TopLeft},
+			[]string{
+				"#.......",
+				"........",
+				"........",
+				"........",
+				"........",
+				"........",
+			},
+		},
+		{
+			"tile with negative margin",
+			&WatermarkOptions{Tile: true, Spacing: 1, Margin: -5},
+			[]string{
+				"........",
+				".##.##.#",
+				".##.##.#",
+				"........",
+				".##.##.#",
+				".##.##.#",
+			},
+		},
+		{
+			"tile",
+			&WatermarkOptions{Tile: true, Spacing: 1},
+			[]string{
+				"##.##.##",
+				"##.##.##",
+				"........",
+				"##.##.##",
+				"##.##.##",
+				"........",
+			},
+		},
+		{
+			"tile with margin",
+			&WatermarkOptions{Tile: true, Spacing: 2, Margin: 1},
+			[]string{
+				"........",
+				".##..##.",
+				".##..##.",
+				"........",
+				"........",
+				"........",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := marked(Watermark(bg, mark, tc.options))
+			for i := range tc.want {
+				if got[i] != tc.want[i] {
+					t.Fatalf("got\n%v\nwant\n%v", got, tc.want)
+				}
+			}
+		})
+	}
+
+	if got := Watermark(bg, mark, &WatermarkOptions{Opacity: &half}).NRGBAAt(3, 2); got != (color.NRGBA{0x80, 0x80, 0x80, 0xff}) {
+		t.Fatalf("got color %v want 50%% gray", got)
+	}
+	if !compareNRGBA(bg, New(8, 6, color.NRGBA{0x00, 0x00, 0x00, 0xff}), 0) {
+		t.Fatalf("source image is modified")
+	}
+}
+
+func TestWatermarkDiagonal(t *testing.T) {
+	bg := New(100, 100, color.Transparent)
+	mark := New(10, 4, color.White)
+	got := Watermark(bg, mark, &WatermarkOptions{Tile: true, Diagonal: true, Spacing: 5})
+	var covered int
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 100; x++ {
+			if got.NRGBAAt(x, y).A != 0 {
+				covered++
+			}
+		}
+	}
+	if covered == 0 || covered == 100*100 {
+		t.Fatalf("got %d covered pixels want partial coverage", covered)
+	}
+	if got := Watermark(bg, &image.NRGBA{}, &WatermarkOptions{Tile: true}); !compareNRGBA(got, bg, 0) {
+		t.Fatalf("empty watermark changed the image")
+	}
+}